@@ -16,6 +16,7 @@
 //      -v prints messages
 //      -no-load prints the boot image paths it was going to load, but doesn't load + exec them
 //      -no-exec loads the boot image, but doesn't exec it
+//      -lvm activates LVM2 logical volumes to look for boot images on them too
 //
 // Notes:
 //	The code is looking for boot/grub/grub.cfg file as to identify the
@@ -38,6 +39,7 @@ import (
 	"github.com/u-root/u-root/pkg/boot/localboot"
 	"github.com/u-root/u-root/pkg/boot/menu"
 	"github.com/u-root/u-root/pkg/cmdline"
+	"github.com/u-root/u-root/pkg/lvm"
 	"github.com/u-root/u-root/pkg/mount"
 	"github.com/u-root/u-root/pkg/mount/block"
 	"github.com/u-root/u-root/pkg/ulog"
@@ -47,6 +49,7 @@ var (
 	verbose = flag.Bool("v", false, "Print debug messages")
 	noLoad  = flag.Bool("no-load", false, "print chosen boot configuration, but do not load + exec it")
 	noExec  = flag.Bool("no-exec", false, "load boot configuration, but do not exec it")
+	useLVM  = flag.Bool("lvm", false, "activate LVM2 logical volumes and look for boot configurations on them")
 
 	removeCmdlineItem = flag.String("remove", "console", "comma separated list of kernel params value to remove from parsed kernel configuration (default to console)")
 	reuseCmdlineItem  = flag.String("reuse", "console", "comma separated list of kernel params value to reuse from current kernel (default to console)")
@@ -72,6 +75,13 @@ func main() {
 	if err != nil {
 		log.Fatal("No available block devices to boot from")
 	}
+	if *useLVM {
+		lvs, err := lvm.ActivateAll(blockDevs)
+		if err != nil {
+			log.Printf("Activating LVM2 logical volumes: %v", err)
+		}
+		blockDevs = append(blockDevs, lvs...)
+	}
 
 	// Try to only boot from "good" block devices.
 	blockDevs = blockDevs.FilterZeroSize()
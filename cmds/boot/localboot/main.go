@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 
 	"github.com/u-root/u-root/pkg/boot/jsonboot"
+	"github.com/u-root/u-root/pkg/lvm"
 	"github.com/u-root/u-root/pkg/mount"
 	"github.com/u-root/u-root/pkg/mount/block"
 )
@@ -30,6 +31,7 @@ var (
 	flagInitramfsPath  = flag.String("initramfs", "", "Specify the path of the initramfs to load. If using -grub, this argument is ignored")
 	flagKernelCmdline  = flag.String("cmdline", "", "Specify the kernel command line. If using -grub, this argument is ignored")
 	flagDeviceGUID     = flag.String("guid", "", "GUID of the device where the kernel (and optionally initramfs) are located. Ignored if -grub is set or if -kernel is not specified")
+	flagLVM            = flag.Bool("lvm", false, "Activate LVM2 logical volumes and look for boot configurations on them too")
 )
 
 var debug = func(string, ...interface{}) {}
@@ -205,6 +207,13 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *flagLVM {
+		lvs, err := lvm.ActivateAll(devices)
+		if err != nil {
+			log.Printf("Activating LVM2 logical volumes: %v", err)
+		}
+		devices = append(devices, lvs...)
+	}
 	// print partition info
 	if *flagDebug {
 		for _, dev := range devices {
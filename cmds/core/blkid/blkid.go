@@ -3,29 +3,97 @@
 // license that can be found in the LICENSE file.
 
 // Blkid prints information about blocks.
+//
+// Synopsis:
+//     blkid [DEVICE...]
+//
+// Description:
+//     blkid prints the label, UUID and type of the filesystem or container
+//     on each block device, and the label and UUID of partitions, in the
+//     same format as util-linux's blkid. Without arguments, every block
+//     device is listed. A DEVICE may also be an image file.
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 
 	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/mount/probe"
 )
 
-func main() {
-	devices, err := block.GetBlockDevices()
+// device returns what is known about the device or image at path.
+func device(path string) (*block.BlockDev, error) {
+	fi, err := os.Stat(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if fi.Mode()&os.ModeDevice != 0 {
+		return block.Device(path)
+	}
+	r, err := probe.File(path)
+	if err != nil {
+		return nil, err
+	}
+	return &block.BlockDev{Name: filepath.Base(path), FSType: r.Type, FsUUID: r.UUID, FsLabel: r.Label}, nil
+}
+
+// printDevice prints the tags of d. Devices without any are skipped.
+func printDevice(w io.Writer, path string, d *block.BlockDev) {
+	var s string
+	for _, tag := range []struct {
+		name, value string
+	}{
+		{"LABEL", d.FsLabel},
+		{"UUID", d.FsUUID},
+		{"TYPE", d.FSType},
+		{"PARTLABEL", d.PartLabel},
+		{"PARTUUID", d.PartUUID},
+	} {
+		if tag.value != "" {
+			s += fmt.Sprintf(" %s=%q", tag.name, tag.value)
+		}
+	}
+	if s != "" {
+		fmt.Fprintf(w, "%s:%s\n", path, s)
 	}
+}
 
-	for _, device := range devices {
-		fmt.Print(device.DevicePath())
-		if device.FsUUID != "" {
-			fmt.Printf(` UUID="%s"`, device.FsUUID)
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		devices, err := block.GetBlockDevices()
+		if err != nil {
+			return err
 		}
-		if device.FSType != "" {
-			fmt.Printf(` TYPE="%s"`, device.FSType)
+		for _, d := range devices {
+			printDevice(w, d.DevicePath(), d)
 		}
-		fmt.Println()
+		return nil
+	}
+
+	var failed bool
+	for _, a := range args {
+		d, err := device(a)
+		if err != nil {
+			log.Print(err)
+			failed = true
+			continue
+		}
+		printDevice(w, a, d)
+	}
+	if failed {
+		return fmt.Errorf("some devices could not be probed")
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := run(flag.Args(), os.Stdout); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,65 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeImage decompresses one of the prober's test images into dir.
+func writeImage(t *testing.T, dir, name string) string {
+	t.Helper()
+	f, err := os.Open(filepath.Join("../../../pkg/mount/probe/testdata", name+".img.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name+".img")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, z); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBlkid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blkid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ext4 := writeImage(t, dir, "ext4")
+	luks := writeImage(t, dir, "luks2")
+	var out bytes.Buffer
+	if err := run([]string{ext4, luks}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("%s: LABEL=\"myext4\" UUID=\"2183ead8-a510-4b3d-9777-19c7090f66d9\" TYPE=\"ext4\"\n", ext4) +
+		fmt.Sprintf("%s: LABEL=\"myluks\" UUID=\"2183ead8-a510-4b3d-9777-19c7090f66d9\" TYPE=\"crypto_LUKS\"\n", luks)
+	if got := out.String(); got != want {
+		t.Errorf("blkid printed\n%s\nwant\n%s", got, want)
+	}
+
+	out.Reset()
+	if err := run([]string{filepath.Join(dir, "missing")}, &out); err == nil {
+		t.Errorf("blkid on a missing file succeeded")
+	}
+}
@@ -5,7 +5,7 @@
 // kexec executes a new kernel over the running kernel (u-root).
 //
 // Synopsis:
-//     kexec [--initrd=FILE] [--command-line=STRING] [-l] [-e] [KERNELIMAGE]
+//     kexec [--initrd=FILE] [--acpi=FILE]... [--command-line=STRING] [-l] [-e] [KERNELIMAGE]
 //
 // Description:
 //		 Loads a kernel for later execution.
@@ -14,17 +14,26 @@
 //     --cmdline=STRING or -c=STRING: Set the kernel command line
 //     --reuse-commandline:           Use the kernel command line from running system
 //     --i=FILE or --initrd=FILE:     Use file as the kernel's initial ramdisk
+//     --acpi=FILE:                   Override or add the ACPI tables in file, e.g. from
+//                                    acpicat, in the new kernel (CONFIG_ACPI_TABLE_UPGRADE).
+//                                    A table replaces the firmware's table with the same
+//                                    signature, OEM ID and OEM table ID, its OEM revision
+//                                    raised if need be; other tables are added. Tables can
+//                                    not be dropped.
 //     -l or --load:                  Load the new kernel into the current kernel
 //     -e or --exec:                  Execute a currently loaded kernel
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"log"
 	"os"
 
 	flag "github.com/spf13/pflag"
 
+	"github.com/u-root/u-root/pkg/acpi"
 	"github.com/u-root/u-root/pkg/boot"
 	"github.com/u-root/u-root/pkg/boot/kexec"
 	"github.com/u-root/u-root/pkg/boot/multiboot"
@@ -40,6 +49,7 @@ type options struct {
 	exec         bool
 	debug        bool
 	modules      []string
+	acpi         []string
 }
 
 func registerFlags() *options {
@@ -52,10 +62,36 @@ func registerFlags() *options {
 	flag.BoolVarP(&o.load, "load", "l", false, "Load the new kernel into the current kernel")
 	flag.BoolVarP(&o.exec, "exec", "e", false, "Execute a currently loaded kernel")
 	flag.BoolVarP(&o.debug, "debug", "d", false, "Print debug info")
+	flag.StringArrayVar(&o.acpi, "acpi", nil, "Override or add the ACPI tables in file in the new kernel")
 	flag.StringArrayVar(&o.modules, "module", nil, `Load multiboot module with command line args (e.g --module="mod arg1")`)
 	return o
 }
 
+// acpiInitrd returns an initrd with the ACPI tables in files, made to
+// replace or add to the firmware's tables fw.
+func acpiInitrd(fw []acpi.Table, files []string) (io.ReaderAt, error) {
+	var tabs []acpi.Table
+	for _, f := range files {
+		t, err := acpi.RawFromName(f)
+		if err != nil {
+			return nil, err
+		}
+		if len(t) == 0 {
+			return nil, fmt.Errorf("%s: no ACPI tables", f)
+		}
+		tabs = append(tabs, t...)
+	}
+	tabs, err := acpi.Upgrade(fw, tabs...)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := acpi.WriteInitrd(&b, tabs...); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b.Bytes()), nil
+}
+
 func main() {
 	opts := registerFlags()
 	flag.Parse()
@@ -104,6 +140,23 @@ func main() {
 			if opts.initramfs != "" {
 				i = uio.NewLazyFile(opts.initramfs)
 			}
+			if len(opts.acpi) > 0 {
+				fw, err := acpi.RawTablesFromSys()
+				if err != nil {
+					log.Fatal(err)
+				}
+				tables, err := acpiInitrd(fw, opts.acpi)
+				if err != nil {
+					log.Fatal(err)
+				}
+				// The kernel only looks for tables in the first
+				// cpio archive of the initrd.
+				if i != nil {
+					i = boot.CatInitrds(tables, i)
+				} else {
+					i = tables
+				}
+			}
 			image = &boot.LinuxImage{
 				Kernel:  uio.NewLazyFile(kernelpath),
 				Initrd:  i,
@@ -5,65 +5,213 @@
 // losetup sets up and controls loop devices.
 //
 // Synopsis:
-//     losetup [-Ad] FILE
-//     losetup [-Ad] DEV FILE
+//     losetup [-a] [-l] [-j FILE]
+//     losetup [-f [-show]] [-o OFFSET] [-sizelimit SIZE] [-b SIZE] [-r] [-P] [-autoclear] [-direct-io] [DEV] FILE
+//     losetup -f
+//     losetup -c DEV
+//     losetup -d DEV...
+//     losetup -D
+//     losetup DEV
+//
+// Description:
+//     With FILE alone, or with -f, the first free loop device is attached
+//     to FILE. With -f alone, the first free device is printed. With DEV
+//     alone, its status is printed.
 //
 // Options:
-//     -A: pick any device
-//     -d: detach the device
+//     -a: list all attached devices
+//     -l: list as a table
+//     -j: list the devices attached to FILE
+//     -f: use the first free device
+//     -show: print the device name after attaching with -f
+//     -o: start at OFFSET bytes into FILE
+//     -sizelimit: expose at most SIZE bytes of FILE
+//     -b: logical block size
+//     -r: attach read-only
+//     -P: scan the device for partitions
+//     -autoclear: detach the device when it is last closed
+//     -direct-io: bypass the page cache
+//     -c: resize DEV to the size of its file
+//     -d: detach the devices
+//     -D: detach all devices
+//
+// Example:
+//     $ losetup -f -show -r -P disk.img
+//     /dev/loop0
+//     $ losetup -l
+//     NAME       SIZELIMIT OFFSET AUTOCLEAR RO BACK-FILE     DIO LOG-SEC
+//     /dev/loop0 0         0      0         1  /tmp/disk.img 0   512
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"text/tabwriter"
 
 	"github.com/u-root/u-root/pkg/mount/loop"
 )
 
-var (
-	detach = flag.Bool("d", false, "Detach the device")
-)
+type cmd struct {
+	stdout io.Writer
 
-func main() {
-	var (
-		filename, devicename string
-		err                  error
-	)
-
-	flag.Parse()
-	args := flag.Args()
-	if *detach {
-		if len(args) == 1 {
-			if err := loop.ClearFile(args[0]); err != nil {
-				log.Fatal("Error clearing device: ", err)
+	all, table bool
+	file       string
+	find, show bool
+	capacity   bool
+	detach     bool
+	detachAll  bool
+	config     loop.Config
+	blockSize  uint
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (c *cmd) list(ss []*loop.Status) error {
+	if !c.table {
+		for _, s := range ss {
+			fmt.Fprintf(c.stdout, "%s: (%s)", s.Dev, s.BackingFile)
+			if s.Offset != 0 {
+				fmt.Fprintf(c.stdout, ", offset %d", s.Offset)
 			}
-			log.Println("Detached", args[0])
-			os.Exit(0)
+			if s.SizeLimit != 0 {
+				fmt.Fprintf(c.stdout, ", sizelimit %d", s.SizeLimit)
+			}
+			fmt.Fprintln(c.stdout)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZELIMIT\tOFFSET\tAUTOCLEAR\tRO\tBACK-FILE\tDIO\tLOG-SEC")
+	for _, s := range ss {
+		bs := s.BlockSize
+		if bs == 0 {
+			bs = 512
 		}
-		flag.Usage()
-		log.Fatal("Syntax Error")
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\t%d\t%d\n", s.Dev, s.SizeLimit, s.Offset,
+			boolInt(s.AutoClear), boolInt(s.ReadOnly), s.BackingFile, boolInt(s.DirectIO), bs)
 	}
+	return w.Flush()
+}
 
-	if len(args) == 1 {
-		devicename, err = loop.FindDevice()
-		if err != nil {
-			log.Fatalf("can't find a loop: %v", err)
-			os.Exit(1)
+func isDevice(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode()&os.ModeDevice != 0
+}
+
+func (c *cmd) attach(dev, file string) error {
+	c.config.BlockSize = uint32(c.blockSize)
+	if dev == "" {
+		var err error
+		if dev, err = loop.FindDevice(); err != nil {
+			return fmt.Errorf("can't find a loop: %v", err)
 		}
-		filename = args[0]
-	} else if len(args) == 2 {
-		devicename = args[0]
-		filename = args[1]
-	} else {
-		flag.Usage()
-		log.Fatal("Syntax Error")
 	}
+	if err := loop.ConfigureFile(dev, file, c.config); err != nil {
+		return err
+	}
+	if c.show {
+		fmt.Fprintln(c.stdout, dev)
+	}
+	return nil
+}
 
-	if err := loop.SetFile(devicename, filename); err != nil {
-		log.Fatal("Could not set loop device:", err)
+func run(args []string, stdout io.Writer) error {
+	c := &cmd{stdout: stdout}
+	f := flag.NewFlagSet("losetup", flag.ContinueOnError)
+	f.BoolVar(&c.all, "a", false, "List all attached devices")
+	f.BoolVar(&c.table, "l", false, "List as a table")
+	f.StringVar(&c.file, "j", "", "List the devices attached to `FILE`")
+	f.BoolVar(&c.find, "f", false, "Use the first free device")
+	f.BoolVar(&c.show, "show", false, "Print the device name after attaching with -f")
+	f.Uint64Var(&c.config.Offset, "o", 0, "Start at `OFFSET` bytes into the file")
+	f.Uint64Var(&c.config.SizeLimit, "sizelimit", 0, "Expose at most `SIZE` bytes of the file")
+	f.UintVar(&c.blockSize, "b", 0, "Logical block `SIZE`")
+	f.BoolVar(&c.config.ReadOnly, "r", false, "Attach read-only")
+	f.BoolVar(&c.config.PartScan, "P", false, "Scan the device for partitions")
+	f.BoolVar(&c.config.AutoClear, "autoclear", false, "Detach the device when it is last closed")
+	f.BoolVar(&c.config.DirectIO, "direct-io", false, "Bypass the page cache")
+	f.BoolVar(&c.capacity, "c", false, "Resize the device to the size of its file")
+	f.BoolVar(&c.detach, "d", false, "Detach the devices")
+	f.BoolVar(&c.detachAll, "D", false, "Detach all devices")
+	if err := f.Parse(args); err != nil {
+		return err
 	}
+	a := f.Args()
 
-	log.Printf("Attached %s to %s", devicename, filename)
+	switch {
+	case c.detachAll && len(a) == 0:
+		ss, err := loop.List()
+		if err != nil {
+			return err
+		}
+		for _, s := range ss {
+			if err := loop.ClearFile(s.Dev); err != nil {
+				return err
+			}
+		}
+		return nil
 
+	case c.detach && len(a) > 0:
+		for _, dev := range a {
+			if err := loop.ClearFile(dev); err != nil {
+				return fmt.Errorf("error clearing device: %v", err)
+			}
+		}
+		return nil
+
+	case c.capacity && len(a) == 1:
+		return loop.SetCapacity(a[0])
+
+	case c.file != "" && len(a) == 0:
+		ss, err := loop.FindFile(c.file)
+		if err != nil {
+			return err
+		}
+		return c.list(ss)
+
+	case (c.all || c.table) && len(a) == 0:
+		ss, err := loop.List()
+		if err != nil {
+			return err
+		}
+		return c.list(ss)
+
+	case c.find && len(a) == 0:
+		dev, err := loop.FindDevice()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, dev)
+		return nil
+
+	case len(a) == 1 && !c.find && isDevice(a[0]):
+		s, err := loop.GetStatus(a[0])
+		if err != nil {
+			return err
+		}
+		return c.list([]*loop.Status{s})
+
+	case len(a) == 1:
+		return c.attach("", a[0])
+
+	case len(a) == 2 && !c.find:
+		return c.attach(a[0], a[1])
+	}
+	f.Usage()
+	return errors.New("syntax error")
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
 }
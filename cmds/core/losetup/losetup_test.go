@@ -0,0 +1,145 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/loop"
+)
+
+func TestList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "losetup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for path, content := range map[string]string{
+		"loop0/ro":                       "1\n",
+		"loop0/loop/backing_file":        "/tmp/disk.img\n",
+		"loop0/loop/offset":              "0\n",
+		"loop0/loop/sizelimit":           "0\n",
+		"loop1/ro":                       "0\n",
+		"loop3/ro":                       "0\n",
+		"loop3/loop/backing_file":        "/images/rootfs.ext4\n",
+		"loop3/loop/offset":              "1048576\n",
+		"loop3/loop/sizelimit":           "67108864\n",
+		"loop3/loop/autoclear":           "1\n",
+		"loop3/loop/dio":                 "1\n",
+		"loop3/queue/logical_block_size": "4096\n",
+	} {
+		p := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := loop.SysfsPath
+	loop.SysfsPath = dir
+	defer func() { loop.SysfsPath = old }()
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{
+			args: []string{"-a"},
+			want: `/dev/loop0: (/tmp/disk.img)
+/dev/loop3: (/images/rootfs.ext4), offset 1048576, sizelimit 67108864
+`,
+		},
+		{
+			args: []string{"-l"},
+			want: `NAME       SIZELIMIT OFFSET  AUTOCLEAR RO BACK-FILE           DIO LOG-SEC
+/dev/loop0 0         0       0         1  /tmp/disk.img       0   512
+/dev/loop3 67108864  1048576 1         0  /images/rootfs.ext4 1   4096
+`,
+		},
+		{
+			args: []string{"-j", "/tmp/disk.img"},
+			want: "/dev/loop0: (/tmp/disk.img)\n",
+		},
+		{
+			args: []string{"-j", "/tmp/other.img"},
+			want: "",
+		},
+	} {
+		var b bytes.Buffer
+		if err := run(tt.args, &b); err != nil {
+			t.Errorf("run(%q): %v", tt.args, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("run(%q) = \n%s\nwant\n%s", tt.args, b.String(), tt.want)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-d"},
+		{"-c"},
+		{"-f", "/dev/loop0", "file"},
+		{"a", "b", "c"},
+		{"-x"},
+	} {
+		if err := run(args, ioutil.Discard); err == nil {
+			t.Errorf("run(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestAttach(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Skipping test since we are not root")
+	}
+
+	f, err := ioutil.TempFile("", "losetup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if err := f.Truncate(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var b bytes.Buffer
+	if err := run([]string{"-f", "-show", "-r", "-o", "4096", "-sizelimit", "65536", f.Name()}, &b); err != nil {
+		t.Fatal(err)
+	}
+	dev := strings.TrimSpace(b.String())
+	defer loop.ClearFile(dev) //nolint:errcheck
+
+	b.Reset()
+	if err := run([]string{dev}, &b); err != nil {
+		t.Fatal(err)
+	}
+	if want := dev + ": (" + f.Name() + "), offset 4096, sizelimit 65536\n"; b.String() != want {
+		t.Errorf("status: got %q, want %q", b.String(), want)
+	}
+	s, err := loop.GetStatus(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.ReadOnly {
+		t.Errorf("%s is not read-only", dev)
+	}
+
+	if err := run([]string{"-d", dev}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loop.GetStatus(dev); err == nil {
+		t.Errorf("%s still attached after -d", dev)
+	}
+}
@@ -7,20 +7,49 @@
 // mount mounts a filesystem at the specified path.
 //
 // Synopsis:
-//     mount [-r] [-o options] [-t FSTYPE] DEV PATH
+//     mount [-i] [-t FSTYPE]
+//     mount [-r|-w] [-B|-R|-M] [-o options] [-t FSTYPE] DEV PATH
+//     mount [-o options] DEV|PATH
+//     mount -a [-F FSTAB] [-t FSTYPE,...] [-o options]
+//
+// Description:
+//     With no arguments, mount prints the mounted file systems, or, with -i,
+//     the detail of /proc/self/mountinfo.
+//
+//     With one argument, the device or mount point is looked up in fstab.
+//     With -a, all fstab entries except noauto and swap entries and those
+//     already mounted are mounted; failures of nofail entries are only
+//     logged.
+//
+//     DEV may be UUID=, LABEL=, PARTUUID= or PARTLABEL=, as in fstab.
+//     Options are mount(8)'s, e.g. "ro,nosuid,bind,rprivate,loop,mode=0755".
 //
 // Options:
+//     -a: mount all file systems in fstab
+//     -F: fstab file (default /etc/fstab)
+//     -i: print mounts with mountinfo detail
+//     -o: comma separated list of mount options
 //     -r: read only
+//     -w: read write
+//     -t: file system type, or types for -a and listing
+//     -B: bind mount, same as -o bind
+//     -R: recursive bind mount, same as -o rbind
+//     -M: move a mount, same as -o move
 package main
 
 import (
 	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/block"
 	"github.com/u-root/u-root/pkg/mount/loop"
 	"golang.org/x/sys/unix"
 )
@@ -32,20 +61,32 @@ func (o *mountOptions) String() string {
 }
 
 func (o *mountOptions) Set(value string) error {
-	for _, option := range strings.Split(value, ",") {
-		*o = append(*o, option)
-	}
+	*o = append(*o, value)
 	return nil
 }
 
 var (
-	ro      = flag.Bool("r", false, "Read only mount")
-	fsType  = flag.String("t", "", "File system type")
-	options mountOptions
+	// doMount and getBlockDevices are replaced in tests.
+	doMount = func(o *mount.Options, dev, path, fsType string) error {
+		_, err := o.Mount(dev, path, fsType)
+		return err
+	}
+	getBlockDevices = block.GetBlockDevices
 )
 
-func init() {
-	flag.Var(&options, "o", "Comma separated list of mount options")
+type cmd struct {
+	stdout io.Writer
+
+	all       bool
+	fstab     string
+	info      bool
+	ro, rw    bool
+	fsType    string
+	bind      bool
+	rbind     bool
+	move      bool
+	options   mountOptions
+	blockDevs block.BlockDevices
 }
 
 func loopSetup(filename string) (loopDevice string, err error) {
@@ -94,46 +135,221 @@ func informIfUnknownFS(originFS string) {
 	}
 }
 
-func main() {
-	flag.Parse()
-	a := flag.Args()
-	if len(a) < 2 {
-		flag.Usage()
-		os.Exit(1)
+// hasType reports whether fsType is in the comma separated list types. A
+// list starting with "no", as in "nonfs,tmpfs", excludes its types.
+func hasType(types, fsType string) bool {
+	if types == "" {
+		return true
+	}
+	not := strings.HasPrefix(types, "no")
+	if not {
+		types = types[2:]
+	}
+	for _, t := range strings.Split(types, ",") {
+		if strings.TrimPrefix(t, "no") == fsType {
+			return !not
+		}
 	}
+	return not
+}
 
-	dev := a[0]
-	path := a[1]
-	var flags uintptr
-	var data []string
-	var err error
-	for _, option := range options {
-		switch option {
-		case "loop":
-			dev, err = loopSetup(dev)
-			if err != nil {
-				log.Fatal("Error setting loop device:", err)
+// list prints the mounts, like mount(8) or, with -i, findmnt(8).
+func (c *cmd) list() error {
+	ms, err := mount.GetMountInfo()
+	if err != nil {
+		return err
+	}
+	if !c.info {
+		for _, m := range ms {
+			if hasType(c.fsType, m.FSType) {
+				fmt.Fprintf(c.stdout, "%s on %s type %s (%s)\n", m.Source, m.MountPoint, m.FSType, m.Options)
 			}
-		default:
-			if f, ok := opts[option]; ok {
-				flags |= f
-			} else {
-				data = append(data, option)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "ID\tPARENT\tMAJ:MIN\tROOT\tTARGET\tSOURCE\tFSTYPE\tOPTIONS\tSUPER\tPROPAGATION")
+	for _, m := range ms {
+		if hasType(c.fsType, m.FSType) {
+			fmt.Fprintf(w, "%d\t%d\t%d:%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", m.ID, m.ParentID, m.Major, m.Minor,
+				m.Root, m.MountPoint, m.Source, m.FSType, m.Options, m.SuperOptions, m.Propagation())
+		}
+	}
+	return w.Flush()
+}
+
+// resolve returns the device an fstab style spec names.
+func (c *cmd) resolve(spec string) (string, error) {
+	switch kv := strings.SplitN(spec, "=", 2); kv[0] {
+	case "UUID", "LABEL", "PARTUUID", "PARTLABEL":
+	default:
+		return spec, nil
+	}
+	if c.blockDevs == nil {
+		devs, err := getBlockDevices()
+		if err != nil {
+			return "", err
+		}
+		c.blockDevs = devs
+	}
+	b, err := c.blockDevs.Lookup(spec)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join("/dev", b.Name), nil
+}
+
+// parseOptions parses the fstab options, if any, followed by the command line.
+func (c *cmd) parseOptions(fstab string) (*mount.Options, error) {
+	o := &mount.Options{}
+	for _, s := range append([]string{fstab}, c.options...) {
+		if err := o.Parse(s); err != nil {
+			return nil, err
+		}
+	}
+	if c.ro {
+		o.Flags |= unix.MS_RDONLY
+	}
+	if c.rw {
+		o.Flags &^= unix.MS_RDONLY
+	}
+	switch {
+	case c.bind:
+		o.Flags |= unix.MS_BIND
+	case c.rbind:
+		o.Flags |= unix.MS_BIND | unix.MS_REC
+	case c.move:
+		o.Flags |= unix.MS_MOVE
+	}
+	return o, nil
+}
+
+func (c *cmd) mount(spec, path, fsType, fstabOptions string) error {
+	o, err := c.parseOptions(fstabOptions)
+	if err != nil {
+		return err
+	}
+	dev, err := c.resolve(spec)
+	if err != nil {
+		return err
+	}
+	if o.Loop {
+		if dev, err = loopSetup(dev); err != nil {
+			return fmt.Errorf("error setting loop device: %v", err)
+		}
+	}
+	if fsType == "none" {
+		fsType = ""
+	}
+	if err := doMount(o, dev, path, fsType); err != nil {
+		if fsType != "" && o.Flags&(unix.MS_BIND|unix.MS_MOVE|unix.MS_REMOUNT) == 0 {
+			informIfUnknownFS(fsType)
+		}
+		return err
+	}
+	return nil
+}
+
+// mountAll mounts the fstab entries, as mount -a.
+func (c *cmd) mountAll(es []*mount.FstabEntry) error {
+	ms, err := mount.GetMountInfo()
+	if err != nil {
+		return err
+	}
+	mounted := make(map[string]bool)
+	for _, m := range ms {
+		mounted[m.MountPoint] = true
+	}
+	var failed int
+	for _, e := range es {
+		o, err := e.Options()
+		if err != nil {
+			return err
+		}
+		if o.NoAuto || e.VFSType == "swap" || e.File == "none" || mounted[e.File] || !hasType(c.fsType, e.VFSType) {
+			continue
+		}
+		if err := c.mount(e.Spec, e.File, e.VFSType, e.MntOps); err != nil {
+			log.Printf("mount %s at %s: %v", e.Spec, e.File, err)
+			if !o.NoFail {
+				failed++
 			}
+			continue
 		}
+		mounted[e.File] = true
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file systems failed to mount", failed)
 	}
-	if *ro {
-		flags |= unix.MS_RDONLY
+	return nil
+}
+
+func run(args []string, stdout io.Writer) error {
+	c := &cmd{stdout: stdout}
+	f := flag.NewFlagSet("mount", flag.ContinueOnError)
+	f.BoolVar(&c.all, "a", false, "Mount all file systems in fstab")
+	f.StringVar(&c.fstab, "F", mount.FstabPath, "fstab file")
+	f.BoolVar(&c.info, "i", false, "Print mounts with mountinfo detail")
+	f.BoolVar(&c.ro, "r", false, "Read only mount")
+	f.BoolVar(&c.rw, "w", false, "Read write mount")
+	f.StringVar(&c.fsType, "t", "", "File system type")
+	f.BoolVar(&c.bind, "B", false, "Bind mount")
+	f.BoolVar(&c.rbind, "R", false, "Recursive bind mount")
+	f.BoolVar(&c.move, "M", false, "Move a mount")
+	f.Var(&c.options, "o", "Comma separated list of mount options")
+	if err := f.Parse(args); err != nil {
+		return err
 	}
-	if *fsType == "" {
-		if _, err := mount.TryMount(dev, path, strings.Join(data, ","), flags); err != nil {
-			log.Fatalf("%v", err)
+	a := f.Args()
+
+	var es []*mount.FstabEntry
+	if c.all || len(a) == 1 {
+		fd, err := os.Open(c.fstab)
+		switch {
+		case err == nil:
+			defer fd.Close()
+			if es, err = mount.ParseFstab(fd); err != nil {
+				return err
+			}
+		case c.all || !os.IsNotExist(err):
+			return err
 		}
-	} else {
-		if _, err := mount.Mount(dev, path, *fsType, strings.Join(data, ","), flags); err != nil {
-			log.Printf("%v", err)
-			informIfUnknownFS(*fsType)
-			os.Exit(1)
+	}
+
+	switch {
+	case c.all:
+		if len(a) != 0 {
+			break
 		}
+		return c.mountAll(es)
+	case len(a) == 0:
+		return c.list()
+	case len(a) == 1:
+		e := mount.FindFstab(es, a[0])
+		o, err := c.parseOptions("")
+		if err != nil {
+			return err
+		}
+		// A remount or propagation change needs only the mount point.
+		if o.Flags&unix.MS_REMOUNT != 0 || o.Propagation != 0 && o.Flags&(unix.MS_BIND|unix.MS_MOVE) == 0 {
+			if e == nil {
+				return c.mount("", a[0], "", "")
+			}
+			return c.mount("", e.File, "", e.MntOps)
+		}
+		if e == nil {
+			return fmt.Errorf("%s not found in %s", a[0], c.fstab)
+		}
+		return c.mount(e.Spec, e.File, e.VFSType, e.MntOps)
+	case len(a) == 2:
+		return c.mount(a[0], a[1], c.fsType, "")
+	}
+	f.Usage()
+	return fmt.Errorf("usage error")
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
 	}
 }
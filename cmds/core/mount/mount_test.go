@@ -0,0 +1,217 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !plan9
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+const mountinfo = `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw,errors=remount-ro
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+41 22 0:40 / /tmp rw,nosuid,nodev - tmpfs tmpfs rw
+`
+
+const fstab = `UUID=2c6e4f3a-9f41-4b1e-8d57-37a3c2e5f0a1 / ext4 errors=remount-ro 0 1
+LABEL=EFI /boot/efi vfat umask=0077 0 1
+/swapfile none swap sw 0 0
+proc /proc proc defaults 0 0
+/srv/data /mnt/data none bind,ro 0 0
+LABEL=backup /mnt/backup ext4 noauto 0 2
+LABEL=missing /mnt/missing ext4 nofail 0 2
+sysfs /sys sysfs nosuid,nodev,noexec
+`
+
+type call struct {
+	dev, path, fsType, opts string
+}
+
+func setup(t *testing.T) (string, *[]call, func()) {
+	dir, err := ioutil.TempDir("", "mount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "mountinfo"), []byte(mountinfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "fstab"), []byte(fstab), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldMountInfo, oldMount, oldBlock := mount.MountInfoPath, doMount, getBlockDevices
+	mount.MountInfoPath = filepath.Join(dir, "mountinfo")
+
+	var calls []call
+	doMount = func(o *mount.Options, dev, path, fsType string) error {
+		calls = append(calls, call{dev, path, fsType, o.String()})
+		if dev == "/dev/sdz1" {
+			return errors.New("no such device")
+		}
+		return nil
+	}
+	getBlockDevices = func() (block.BlockDevices, error) {
+		return block.BlockDevices{
+			{Name: "sda1", FsUUID: "A1B2-C3D4", FsLabel: "EFI"},
+			{Name: "sda2", FsUUID: "2c6e4f3a-9f41-4b1e-8d57-37a3c2e5f0a1"},
+			{Name: "sdz1", FsLabel: "missing"},
+		}, nil
+	}
+	return dir, &calls, func() {
+		mount.MountInfoPath, doMount, getBlockDevices = oldMountInfo, oldMount, oldBlock
+		os.RemoveAll(dir)
+	}
+}
+
+func TestList(t *testing.T) {
+	_, _, cleanup := setup(t)
+	defer cleanup()
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{
+			want: `/dev/sda2 on / type ext4 (rw,relatime)
+proc on /proc type proc (rw,nosuid,nodev,noexec,relatime)
+tmpfs on /tmp type tmpfs (rw,nosuid,nodev)
+`,
+		},
+		{
+			args: []string{"-t", "tmpfs"},
+			want: "tmpfs on /tmp type tmpfs (rw,nosuid,nodev)\n",
+		},
+		{
+			args: []string{"-t", "noproc,tmpfs"},
+			want: "/dev/sda2 on / type ext4 (rw,relatime)\n",
+		},
+		{
+			args: []string{"-i", "-t", "ext4,tmpfs"},
+			want: `ID PARENT MAJ:MIN ROOT TARGET SOURCE    FSTYPE OPTIONS         SUPER                PROPAGATION
+22 1      8:2     /    /      /dev/sda2 ext4   rw,relatime     rw,errors=remount-ro shared:1
+41 22     0:40    /    /tmp   tmpfs     tmpfs  rw,nosuid,nodev rw                   private
+`,
+		},
+	} {
+		var b bytes.Buffer
+		if err := run(tt.args, &b); err != nil {
+			t.Errorf("run(%q): %v", tt.args, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("run(%q) = \n%s\nwant\n%s", tt.args, b.String(), tt.want)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	dir, calls, cleanup := setup(t)
+	defer cleanup()
+	fstab := filepath.Join(dir, "fstab")
+
+	for _, tt := range []struct {
+		args []string
+		want []call
+		err  string
+	}{
+		{
+			args: []string{"-t", "tmpfs", "-o", "mode=0755,nosuid", "-o", "size=10%", "tmpfs", "/run"},
+			want: []call{{"tmpfs", "/run", "tmpfs", "rw,nosuid,mode=0755,size=10%"}},
+		},
+		{
+			args: []string{"-r", "/dev/sdb1", "/mnt"},
+			want: []call{{"/dev/sdb1", "/mnt", "", "ro"}},
+		},
+		{
+			args: []string{"-R", "-o", "rprivate", "/srv", "/mnt"},
+			want: []call{{"/srv", "/mnt", "", "rw,rbind,rprivate"}},
+		},
+		{
+			args: []string{"-M", "/mnt", "/srv"},
+			want: []call{{"/mnt", "/srv", "", "rw,move"}},
+		},
+		{
+			args: []string{"LABEL=EFI", "/efi"},
+			want: []call{{"/dev/sda1", "/efi", "", "rw"}},
+		},
+		{
+			args: []string{"-F", fstab, "/boot/efi"},
+			want: []call{{"/dev/sda1", "/boot/efi", "vfat", "rw,umask=0077"}},
+		},
+		{
+			args: []string{"-F", fstab, "-w", "/mnt/data"},
+			want: []call{{"/srv/data", "/mnt/data", "", "rw,bind"}},
+		},
+		{
+			args: []string{"-F", fstab, "-o", "remount,ro", "/"},
+			want: []call{{"", "/", "", "ro,remount,errors=remount-ro"}},
+		},
+		{
+			args: []string{"-F", fstab, "-o", "remount,ro", "/tmp"},
+			want: []call{{"", "/tmp", "", "ro,remount"}},
+		},
+		{
+			args: []string{"-F", fstab, "-o", "shared", "/tmp"},
+			want: []call{{"", "/tmp", "", "rw,shared"}},
+		},
+		{
+			args: []string{"-F", fstab, "/home"},
+			err:  "/home not found",
+		},
+		{
+			args: []string{"LABEL=nothing", "/mnt"},
+			err:  "no block device matches",
+		},
+		{
+			args: []string{"-F", fstab, "-o", "shared,private", "/mnt"},
+			err:  "more than one propagation type",
+		},
+		{
+			args: []string{"-F", fstab, "-a"},
+			want: []call{
+				{"/dev/sda1", "/boot/efi", "vfat", "rw,umask=0077"},
+				{"/srv/data", "/mnt/data", "", "ro,bind"},
+				{"/dev/sdz1", "/mnt/missing", "ext4", "rw,nofail"},
+				{"sysfs", "/sys", "sysfs", "rw,nosuid,nodev,noexec"},
+			},
+		},
+		{
+			args: []string{"-F", fstab, "-a", "-t", "sysfs,vfat"},
+			want: []call{
+				{"/dev/sda1", "/boot/efi", "vfat", "rw,umask=0077"},
+				{"sysfs", "/sys", "sysfs", "rw,nosuid,nodev,noexec"},
+			},
+		},
+		{
+			args: []string{"-F", filepath.Join(dir, "nofstab"), "-a"},
+			err:  "no such file",
+		},
+		{
+			args: []string{"a", "b", "c"},
+			err:  "usage",
+		},
+	} {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			*calls = nil
+			err := run(tt.args, ioutil.Discard)
+			if got := fmt.Sprint(err); (tt.err == "") != (err == nil) || !strings.Contains(got, tt.err) {
+				t.Fatalf("run = %v, want error containing %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(*calls, tt.want) {
+				t.Errorf("mounts: got %v, want %v", *calls, tt.want)
+			}
+		})
+	}
+}
@@ -3,72 +3,580 @@
 // license that can be found in the LICENSE file.
 
 // netcat creates arbitrary TCP and UDP connections and listens and sends arbitrary data.
+//
+// Synopsis:
+//     netcat [OPTIONS] ADDRESS
+//     netcat [OPTIONS] HOST PORT[-PORT][,PORT...]
+//     netcat -l [OPTIONS] [ADDRESS]
+//
+// Description:
+//     ADDRESS is a Go-style network address, e.g. "localhost:80", "[::1]:22"
+//     or a socket path for the unix networks.
+//
+// Options:
+//     -net:  network type (tcp, tcp4, tcp6, udp, udp4, udp6, unix, unixgram)
+//     -u:    use UDP instead of TCP (unixgram with -U)
+//     -U:    use unix domain sockets
+//     -4:    use IPv4 only
+//     -6:    use IPv6 only
+//     -l:    listen for an incoming connection
+//     -k:    keep listening after a connection is closed (with -l)
+//     -s:    local source address to bind to
+//     -w:    timeout for connects and idle reads, e.g. "5s"
+//     -z:    zero-I/O mode: only report which ports are open; UDP ports
+//            that neither answer nor refuse a probe are open|filtered
+//     -e:    program to exec for each connection
+//     -c:    shell command to run for each connection, via /bin/sh -c
+//     -x:    proxy address, e.g. "localhost:1080"
+//     -X:    proxy protocol: "5" (SOCKS5) or "connect" (HTTP CONNECT)
+//     -v:    verbose output
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/u-root/u-root/pkg/uroot/util"
 )
 
-const usage = "netcat [go-style network address]"
+const usage = "netcat [options] [go-style network address | host port]"
 
 var (
-	netType = flag.String("net", "tcp", "What net type to use, e.g. tcp, unix, etc.")
-	listen  = flag.Bool("l", false, "Listen for connections.")
-	verbose = flag.Bool("v", false, "Verbose output.")
+	netType   = flag.String("net", "tcp", "What net type to use, e.g. tcp, unix, etc.")
+	listen    = flag.Bool("l", false, "Listen for connections.")
+	verbose   = flag.Bool("v", false, "Verbose output.")
+	udp       = flag.Bool("u", false, "Use UDP (or unixgram with -U).")
+	unix      = flag.Bool("U", false, "Use unix domain sockets.")
+	ipv4      = flag.Bool("4", false, "Use IPv4 only.")
+	ipv6      = flag.Bool("6", false, "Use IPv6 only.")
+	keep      = flag.Bool("k", false, "Keep listening for new connections after the current one ends.")
+	source    = flag.String("s", "", "Local source address to bind to.")
+	timeout   = flag.Duration("w", 0, "Timeout for connects and idle reads; 0 means none.")
+	zero      = flag.Bool("z", false, "Zero-I/O mode: report open ports only.")
+	execProg  = flag.String("e", "", "Program to exec for each connection.")
+	execCmd   = flag.String("c", "", "Shell command to run for each connection.")
+	proxyAddr = flag.String("x", "", "Proxy address to connect through.")
+	proxyType = flag.String("X", "5", "Proxy protocol: 5 (SOCKS5) or connect (HTTP CONNECT).")
 )
 
 func init() {
 	util.Usage(usage)
 }
 
-func main() {
-	var c net.Conn
-	var err error
-	if flag.Parse(); len(flag.Args()) != 1 {
-		flag.Usage()
-		os.Exit(1)
+// config holds everything needed to establish and serve a connection.
+type config struct {
+	network string
+	source  string
+	timeout time.Duration
+	verbose bool
+
+	// command, if non-empty, is run with its stdio wired to each
+	// connection instead of our own stdio.
+	command []string
+
+	proxyAddr string
+	proxyType string
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// network returns the network implied by the command-line flags.
+func network() (string, error) {
+	if *ipv4 && *ipv6 {
+		return "", errors.New("-4 and -6 are mutually exclusive")
+	}
+	n := *netType
+	switch {
+	case *unix && *udp:
+		n = "unixgram"
+	case *unix:
+		n = "unix"
+	case *udp:
+		n = "udp"
+	}
+	if strings.HasPrefix(n, "tcp") || strings.HasPrefix(n, "udp") {
+		n = n[:3]
+		if *ipv4 {
+			n += "4"
+		}
+		if *ipv6 {
+			n += "6"
+		}
+	}
+	return n, nil
+}
+
+// parsePorts parses a port specification such as "22", "20-25" or
+// "22,80,8000-8002" into an ordered list of ports.
+func parsePorts(spec string) ([]int, error) {
+	var ports []int
+	for _, r := range strings.Split(spec, ",") {
+		lo, hi := r, r
+		if i := strings.Index(r, "-"); i >= 0 {
+			lo, hi = r[:i], r[i+1:]
+		}
+		l, err := strconv.ParseUint(lo, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %v", lo, err)
+		}
+		h, err := strconv.ParseUint(hi, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %v", hi, err)
+		}
+		if l > h {
+			return nil, fmt.Errorf("invalid port range %q", r)
+		}
+		for p := l; p <= h; p++ {
+			ports = append(ports, int(p))
+		}
 	}
+	return ports, nil
+}
 
-	addr := flag.Args()[0]
+// localAddr resolves the -s source address for network n.
+func (c *config) localAddr() (net.Addr, error) {
+	if c.source == "" {
+		return nil, nil
+	}
+	host := c.source
+	// Accept a bare address, including a bare IPv6 one, as well as host:port.
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "0")
+	}
+	switch {
+	case strings.HasPrefix(c.network, "tcp"):
+		return net.ResolveTCPAddr(c.network, host)
+	case strings.HasPrefix(c.network, "udp"):
+		return net.ResolveUDPAddr(c.network, host)
+	case strings.HasPrefix(c.network, "unix"):
+		return net.ResolveUnixAddr(c.network, c.source)
+	}
+	return nil, fmt.Errorf("source address not supported for network %q", c.network)
+}
 
-	if *listen {
-		ln, err := net.Listen(*netType, addr)
+// dial connects to addr, through a proxy if one is configured.
+func (c *config) dial(addr string) (net.Conn, error) {
+	la, err := c.localAddr()
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: c.timeout, LocalAddr: la}
+	if c.proxyAddr == "" {
+		return d.Dial(c.network, addr)
+	}
+	if !strings.HasPrefix(c.network, "tcp") {
+		return nil, fmt.Errorf("proxying is only supported over tcp, not %q", c.network)
+	}
+	conn, err := d.Dial(c.network, c.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if c.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	switch c.proxyType {
+	case "5":
+		err = socks5Connect(conn, addr)
+	case "connect":
+		err = httpConnect(conn, addr)
+	default:
+		err = fmt.Errorf("unknown proxy protocol %q", c.proxyType)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// udpProbeWait is how long a UDP scan waits for an answer to its probe
+// when there is no -w timeout.
+const udpProbeWait = time.Second
+
+// probeUDP tells whether a UDP port is open, closed or open|filtered.
+// Dialing UDP always succeeds, so a datagram is sent: the port is open if
+// anything answers and closed if an ICMP port unreachable comes back,
+// which shows up as ECONNREFUSED. Otherwise it cannot be told apart from
+// a filtered port.
+func (c *config) probeUDP(conn net.Conn) (string, error) {
+	wait := c.timeout
+	if wait <= 0 {
+		wait = udpProbeWait
+	}
+	if err := conn.SetDeadline(time.Now().Add(wait)); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return "", err
+	}
+	_, err := conn.Read(make([]byte, 1))
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return "open|filtered", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "open", nil
+}
+
+// scan probes each port on host and reports those that accept a
+// connection. No data is transferred over stream sockets.
+func (c *config) scan(host string, ports []int) error {
+	var open int
+	for _, p := range ports {
+		addr := net.JoinHostPort(host, strconv.Itoa(p))
+		conn, err := c.dial(addr)
+		state := "open"
+		if err == nil && strings.HasPrefix(c.network, "udp") {
+			state, err = c.probeUDP(conn)
+			conn.Close()
+		} else if err == nil {
+			conn.Close()
+		}
+		if err != nil {
+			if c.verbose {
+				fmt.Fprintf(c.stderr, "%s: %v\n", addr, err)
+			}
+			continue
+		}
+		open++
+		fmt.Fprintf(c.stderr, "%s (%s) %s\n", addr, c.network, state)
+	}
+	if open == 0 {
+		return errors.New("no open ports")
+	}
+	return nil
+}
+
+// listen accepts connections on addr and serves each of them. Unless keep
+// is set, it returns after the first connection is done.
+func (c *config) listen(addr string, keep bool) error {
+	if c.network == "udp" || c.network == "udp4" || c.network == "udp6" || c.network == "unixgram" {
+		return c.listenPacket(addr, keep)
+	}
+	ln, err := net.Listen(c.network, addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	if c.verbose {
+		fmt.Fprintln(c.stderr, "Listening on", ln.Addr())
+	}
+	for {
+		conn, err := ln.Accept()
 		if err != nil {
-			log.Fatalln(err)
+			return err
+		}
+		if c.verbose {
+			fmt.Fprintln(c.stderr, "Connection from", conn.RemoteAddr())
 		}
-		if *verbose {
-			fmt.Fprintln(os.Stderr, "Listening on", ln.Addr())
+		if !keep {
+			return c.serve(conn)
+		}
+		// Only exec'd commands can serve connections concurrently;
+		// our own stdio is shared, so serve those one at a time.
+		if len(c.command) > 0 {
+			go func() {
+				if err := c.serve(conn); err != nil {
+					fmt.Fprintln(c.stderr, err)
+				}
+			}()
+			continue
+		}
+		if err := c.serve(conn); err != nil {
+			fmt.Fprintln(c.stderr, err)
+		}
+	}
+}
+
+// listenPacket listens on a datagram socket. The peer of the first
+// datagram received becomes the peer for the rest of the session.
+func (c *config) listenPacket(addr string, keep bool) error {
+	for {
+		pc, err := net.ListenPacket(c.network, addr)
+		if err != nil {
+			return err
+		}
+		if c.verbose {
+			fmt.Fprintln(c.stderr, "Listening on", pc.LocalAddr())
+		}
+		err = c.serve(&packetConn{PacketConn: pc})
+		if c.network == "unixgram" {
+			os.Remove(addr)
+		}
+		if !keep {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
 		}
+	}
+}
+
+// packetConn adapts a listening net.PacketConn into a net.Conn talking to
+// whoever sent the first datagram.
+type packetConn struct {
+	net.PacketConn
+
+	mu   sync.Mutex
+	peer net.Addr
+	// ready is closed once peer is known.
+	ready chan struct{}
+}
 
-		c, err = ln.Accept()
+func (p *packetConn) init() {
+	p.mu.Lock()
+	if p.ready == nil {
+		p.ready = make(chan struct{})
+	}
+	p.mu.Unlock()
+}
+
+// Read implements io.Reader. Datagrams from anyone but the peer are dropped.
+func (p *packetConn) Read(b []byte) (int, error) {
+	p.init()
+	for {
+		n, from, err := p.ReadFrom(b)
 		if err != nil {
-			log.Fatalln(err)
+			return n, err
+		}
+		p.mu.Lock()
+		if p.peer == nil {
+			p.peer = from
+			close(p.ready)
+		}
+		ok := p.peer.String() == from.String()
+		p.mu.Unlock()
+		if ok {
+			return n, nil
+		}
+	}
+}
+
+// Write implements io.Writer. It blocks until the peer is known.
+func (p *packetConn) Write(b []byte) (int, error) {
+	p.init()
+	<-p.ready
+	return p.WriteTo(b, p.peer)
+}
+
+// RemoteAddr implements net.Conn.
+func (p *packetConn) RemoteAddr() net.Addr {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.peer
+}
+
+// idleConn extends the read deadline of a net.Conn on every read, so that
+// reads time out only after the connection has been idle for timeout.
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// Read implements io.Reader.
+func (i *idleConn) Read(b []byte) (int, error) {
+	if err := i.Conn.SetReadDeadline(time.Now().Add(i.timeout)); err != nil {
+		return 0, err
+	}
+	return i.Conn.Read(b)
+}
+
+// serve connects conn to either the configured command or our stdio, and
+// returns once the remote end is done.
+func (c *config) serve(conn net.Conn) error {
+	defer conn.Close()
+	var rw io.ReadWriter = conn
+	if c.timeout > 0 {
+		rw = &idleConn{Conn: conn, timeout: c.timeout}
+	}
+
+	if len(c.command) > 0 {
+		return c.serveCommand(conn, rw)
+	}
+
+	go func() {
+		if _, err := io.Copy(rw, c.stdin); err != nil {
+			fmt.Fprintln(c.stderr, err)
 		}
-	} else {
-		if c, err = net.Dial(*netType, addr); err != nil {
-			log.Fatalln(err)
+		// Let the other end know we're done sending, if we can.
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
 		}
+	}()
+	_, err := io.Copy(c.stdout, rw)
+	if c.verbose {
+		fmt.Fprintln(c.stderr, "Disconnected")
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return nil
 	}
-	if *verbose {
-		fmt.Fprintln(os.Stderr, "Connected to", c.RemoteAddr())
+	return err
+}
+
+// serveCommand runs the configured command with its stdio wired to conn.
+func (c *config) serveCommand(conn net.Conn, rw io.ReadWriter) error {
+	cmd := exec.Command(c.command[0], c.command[1:]...)
+	cmd.Stderr = c.stderr
+	if c.timeout <= 0 {
+		cmd.Stdin, cmd.Stdout = rw, rw
+		// Hand stream sockets to the command directly. Otherwise, Run
+		// would not return until the peer closed its end as well.
+		if fc, ok := conn.(interface{ File() (*os.File, error) }); ok {
+			f, err := fc.File()
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			cmd.Stdin, cmd.Stdout = f, f
+		}
+		return cmd.Run()
 	}
 
+	// With an idle timeout, the socket cannot be handed over, as the
+	// command would not time out reading it. Its input goes through rw
+	// instead, and once that has been idle for the timeout the session
+	// ends as it does without a command: the command is killed and the
+	// connection closed.
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+	cmd.Stdout = pw
+	err = cmd.Start()
+	pw.Close()
+	if err != nil {
+		return err
+	}
+	idle := make(chan struct{})
 	go func() {
-		if _, err := io.Copy(c, os.Stdin); err != nil {
-			fmt.Fprintln(os.Stderr, err)
+		_, err := io.Copy(stdin, rw)
+		stdin.Close()
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			close(idle)
 		}
 	}()
-	if _, err = io.Copy(os.Stdout, c); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	done := make(chan struct{})
+	go func() {
+		io.Copy(conn, pr)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return cmd.Wait()
+	case <-idle:
+		cmd.Process.Kill()
+		// Children of the command may still hold its stderr, which
+		// Wait would copy until they exit too.
+		go cmd.Wait()
+		if c.verbose {
+			fmt.Fprintln(c.stderr, "Idle timeout")
+		}
+		return nil
+	}
+}
+
+// address builds the address to dial or listen on from the positional
+// arguments: either a single Go-style address or a host and a port.
+func address(network string, args []string) (string, error) {
+	switch len(args) {
+	case 1:
+		return args[0], nil
+	case 2:
+		if strings.HasPrefix(network, "unix") {
+			return "", fmt.Errorf("%s takes a single socket path", network)
+		}
+		return net.JoinHostPort(args[0], args[1]), nil
+	}
+	return "", errors.New("expected an address or a host and a port")
+}
+
+func run(c *config, args []string) error {
+	if *zero {
+		if len(args) != 2 {
+			return errors.New("-z needs a host and a port range")
+		}
+		ports, err := parsePorts(args[1])
+		if err != nil {
+			return err
+		}
+		return c.scan(args[0], ports)
+	}
+
+	if *listen {
+		// "-l port" and "-l host port" are accepted as well as addresses.
+		if len(args) == 1 && !strings.HasPrefix(c.network, "unix") && !strings.Contains(args[0], ":") {
+			args = []string{"", args[0]}
+		}
+		addr, err := address(c.network, args)
+		if err != nil {
+			return err
+		}
+		return c.listen(addr, *keep)
+	}
+
+	addr, err := address(c.network, args)
+	if err != nil {
+		return err
+	}
+	conn, err := c.dial(addr)
+	if err != nil {
+		return err
+	}
+	if c.verbose {
+		fmt.Fprintln(c.stderr, "Connected to", conn.RemoteAddr())
+	}
+	return c.serve(conn)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	n, err := network()
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := &config{
+		network:   n,
+		source:    *source,
+		timeout:   *timeout,
+		verbose:   *verbose,
+		proxyAddr: *proxyAddr,
+		proxyType: *proxyType,
+		stdin:     os.Stdin,
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
+	}
+	switch {
+	case *execProg != "" && *execCmd != "":
+		log.Fatal("-e and -c are mutually exclusive")
+	case *execProg != "":
+		c.command = strings.Fields(*execProg)
+	case *execCmd != "":
+		c.command = []string{"/bin/sh", "-c", *execCmd}
 	}
-	if *verbose {
-		fmt.Fprintln(os.Stderr, "Disconnected")
+	if err := run(c, flag.Args()); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,392 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePorts(t *testing.T) {
+	for _, tt := range []struct {
+		spec  string
+		ports []int
+		err   bool
+	}{
+		{spec: "22", ports: []int{22}},
+		{spec: "20-23", ports: []int{20, 21, 22, 23}},
+		{spec: "22,80,8000-8001", ports: []int{22, 80, 8000, 8001}},
+		{spec: "23-20", err: true},
+		{spec: "ssh", err: true},
+		{spec: "70000", err: true},
+	} {
+		ports, err := parsePorts(tt.spec)
+		if (err != nil) != tt.err {
+			t.Errorf("parsePorts(%q) = %v, want error %t", tt.spec, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(ports, tt.ports) {
+			t.Errorf("parsePorts(%q) = %v, want %v", tt.spec, ports, tt.ports)
+		}
+	}
+}
+
+func newConfig(network string, stdin string) (*config, *bytes.Buffer) {
+	var out bytes.Buffer
+	return &config{
+		network: network,
+		timeout: 5 * time.Second,
+		stdin:   strings.NewReader(stdin),
+		stdout:  &out,
+		stderr:  ioutil.Discard,
+	}, &out
+}
+
+// echoOnce accepts one connection on ln and echoes it back.
+func echoOnce(t *testing.T, ln net.Listener) {
+	t.Helper()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(c, c)
+	}()
+}
+
+func TestDialTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	echoOnce(t, ln)
+
+	c, out := newConfig("tcp", "hello\n")
+	c.source = "127.0.0.1"
+	if err := run(c, []string{ln.Addr().String()}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "hello\n" {
+		t.Errorf("got %q, want %q", got, "hello\n")
+	}
+}
+
+func TestScan(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	c, _ := newConfig("tcp", "")
+	var stderr bytes.Buffer
+	c.stderr = &stderr
+	if err := c.scan("127.0.0.1", []int{port}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "open") {
+		t.Errorf("scan output %q does not report the port open", stderr.String())
+	}
+
+	ln.Close()
+	if err := c.scan("127.0.0.1", []int{port}); err == nil {
+		t.Errorf("scan of closed port succeeded")
+	}
+}
+
+func TestListenExec(t *testing.T) {
+	c, _ := newConfig("unix", "")
+	c.command = []string{"/bin/sh", "-c", "echo hi"}
+	dir, err := ioutil.TempDir("", "netcat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "sock")
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.listen(sock, false) }()
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("unix", sock); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hi\n" {
+		t.Errorf("got %q, want %q", b, "hi\n")
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("listen: %v", err)
+	}
+}
+
+func TestListenUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &packetConn{PacketConn: pc}
+	defer conn.Close()
+
+	client, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 16)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "ping" {
+		t.Errorf("got %q, want %q", b[:n], "ping")
+	}
+	if _, err := conn.Write([]byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if n, err = client.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "pong" {
+		t.Errorf("got %q, want %q", b[:n], "pong")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		// Hold the connection open without sending anything.
+		time.Sleep(2 * time.Second)
+		c.Close()
+	}()
+
+	c, _ := newConfig("tcp", "")
+	c.timeout = 100 * time.Millisecond
+	start := time.Now()
+	if err := run(c, []string{ln.Addr().String()}); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("idle connection closed after %v, want about %v", d, c.timeout)
+	}
+}
+
+// fakeSOCKS5 implements just enough of a SOCKS5 server to accept one
+// CONNECT request and echo the tunneled data.
+func fakeSOCKS5(t *testing.T, ln net.Listener, want string) {
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		greet := make([]byte, 3)
+		if _, err := io.ReadFull(c, greet); err != nil {
+			t.Errorf("reading greeting: %v", err)
+			return
+		}
+		c.Write([]byte{socks5Version, socks5NoAuth})
+		hdr := make([]byte, 5)
+		if _, err := io.ReadFull(c, hdr); err != nil {
+			t.Errorf("reading request: %v", err)
+			return
+		}
+		if hdr[3] != socks5Domain {
+			t.Errorf("address type = %d, want %d", hdr[3], socks5Domain)
+		}
+		host := make([]byte, int(hdr[4])+2)
+		if _, err := io.ReadFull(c, host); err != nil {
+			t.Errorf("reading request: %v", err)
+			return
+		}
+		if got := string(host[:len(host)-2]); got != want {
+			t.Errorf("proxy asked for %q, want %q", got, want)
+		}
+		c.Write([]byte{socks5Version, socks5Succeeded, 0, socks5IPv4, 0, 0, 0, 0, 0, 0})
+		io.Copy(c, c)
+	}()
+}
+
+func TestSOCKS5(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	fakeSOCKS5(t, ln, "example.com")
+
+	c, out := newConfig("tcp", "through the proxy")
+	c.proxyAddr = ln.Addr().String()
+	c.proxyType = "5"
+	if err := run(c, []string{"example.com", "80"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "through the proxy" {
+		t.Errorf("got %q, want %q", got, "through the proxy")
+	}
+}
+
+func TestHTTPConnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		br := bufio.NewReader(c)
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			t.Errorf("reading request: %v", err)
+			return
+		}
+		if req.Method != http.MethodConnect || req.Host != "example.com:443" {
+			t.Errorf("got %s %s, want CONNECT example.com:443", req.Method, req.Host)
+		}
+		// Send tunneled data along with the response header to make sure
+		// none of it is lost.
+		io.WriteString(c, "HTTP/1.1 200 Connection established\r\n\r\nhello")
+	}()
+
+	c, out := newConfig("tcp", "")
+	c.proxyAddr = ln.Addr().String()
+	c.proxyType = "connect"
+	if err := run(c, []string{"example.com:443"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "hello" {
+		t.Errorf("got %q, want %q", got, "hello")
+	}
+}
+
+func TestScanUDP(t *testing.T) {
+	// An echo server answers the probe, a silent one does not.
+	echo, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		b := make([]byte, 16)
+		for {
+			n, from, err := echo.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			echo.WriteTo(b[:n], from)
+		}
+	}()
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	// Nothing listens on a port that was just closed, so loopback
+	// answers with ICMP port unreachable.
+	closed, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	for _, tt := range []struct {
+		name string
+		pc   net.PacketConn
+		want string
+	}{
+		{name: "open", pc: echo, want: "(udp) open\n"},
+		{name: "open|filtered", pc: silent, want: "(udp) open|filtered\n"},
+		{name: "closed", pc: closed, want: ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newConfig("udp", "")
+			c.timeout = 200 * time.Millisecond
+			var stderr bytes.Buffer
+			c.stderr = &stderr
+			port := tt.pc.LocalAddr().(*net.UDPAddr).Port
+			err := c.scan("127.0.0.1", []int{port})
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("scan of closed port = %q, want an error", stderr.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(stderr.String(), tt.want) {
+				t.Errorf("scan output %q, want one ending in %q", stderr.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExecIdleTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		// Hold the connection open without sending anything.
+		time.Sleep(5 * time.Second)
+		c.Close()
+	}()
+
+	c, _ := newConfig("tcp", "")
+	c.timeout = 100 * time.Millisecond
+	c.command = []string{"/bin/sh", "-c", "sleep 5"}
+	start := time.Now()
+	if err := run(c, []string{ln.Addr().String()}); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("idle exec session ended after %v, want about %v", d, c.timeout)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// SOCKS5 constants from RFC 1928.
+const (
+	socks5Version    = 5
+	socks5NoAuth     = 0
+	socks5CmdConnect = 1
+	socks5IPv4       = 1
+	socks5Domain     = 3
+	socks5IPv6       = 4
+	socks5Succeeded  = 0
+)
+
+// socks5Connect asks the SOCKS5 proxy on the other end of conn to connect
+// to addr. Only the "no authentication" method is supported.
+func socks5Connect(conn net.Conn, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q: %v", port, err)
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, 1, socks5NoAuth}); err != nil {
+		return err
+	}
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return fmt.Errorf("socks5: reading method selection: %v", err)
+	}
+	if resp[0] != socks5Version {
+		return fmt.Errorf("socks5: unexpected version %d", resp[0])
+	}
+	if resp[1] != socks5NoAuth {
+		return fmt.Errorf("socks5: proxy requires authentication method %#x", resp[1])
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("socks5: host name %q too long", host)
+		}
+		req = append(req, socks5Domain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5IPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5IPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(p>>8), byte(p))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return fmt.Errorf("socks5: reading reply: %v", err)
+	}
+	if hdr[1] != socks5Succeeded {
+		return fmt.Errorf("socks5: connect to %s failed with code %d", addr, hdr[1])
+	}
+	// Skip the bound address and port.
+	var skip int
+	switch hdr[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return fmt.Errorf("socks5: reading reply: %v", err)
+		}
+		skip = int(l[0])
+	default:
+		return fmt.Errorf("socks5: unknown address type %d", hdr[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip+2)); err != nil {
+		return fmt.Errorf("socks5: reading reply: %v", err)
+	}
+	return nil
+}
+
+// httpConnect asks the HTTP proxy on the other end of conn to open a
+// tunnel to addr with the CONNECT method.
+func httpConnect(conn net.Conn, addr string) error {
+	if _, err := fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", addr, addr); err != nil {
+		return err
+	}
+	// Read byte by byte so that nothing past the header is consumed.
+	br := bufio.NewReaderSize(oneByteReader{conn}, 16)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return fmt.Errorf("http connect: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http connect: proxy returned %q", resp.Status)
+	}
+	return nil
+}
+
+// oneByteReader reads at most one byte per call.
+type oneByteReader struct {
+	r io.Reader
+}
+
+// Read implements io.Reader.
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return o.r.Read(p)
+}
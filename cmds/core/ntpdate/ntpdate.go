@@ -3,6 +3,26 @@
 // license that can be found in the LICENSE file.
 
 // ntpdate uses NTP to adjust the system clock.
+//
+// Synopsis:
+//     ntpdate [OPTIONS] [SERVER...]
+//
+// Description:
+//     Servers given on the command line take precedence over the config
+//     file. Each server is queried several times; the best samples of all
+//     servers are combined after rejecting outliers.
+//
+// Options:
+//     -config:   NTP config file to read servers from
+//     -samples:  number of queries per server
+//     -slew:     slew the clock instead of stepping it, for small offsets
+//     -rtc:      write the new time to the hardware clock
+//     -q:        query only, do not set the clock
+//     -d:        keep running and adjust the clock every -interval
+//     -interval: time between adjustments in daemon mode
+//     -keys:     ntp.keys file with symmetric keys
+//     -keyid:    ID of the key in -keys to authenticate with
+//     -verbose:  verbose output
 package main
 
 import (
@@ -12,16 +32,23 @@ import (
 	"log"
 	"os"
 	"strings"
-	"syscall"
 	"time"
 
-	"github.com/beevik/ntp"
+	"github.com/u-root/u-root/pkg/ntp"
 )
 
 var (
-	config  = flag.String("config", "/etc/ntp.conf", "NTP config file.")
-	verbose = flag.Bool("verbose", false, "Verbose output")
-	debug   = func(string, ...interface{}) {}
+	config   = flag.String("config", "/etc/ntp.conf", "NTP config file.")
+	verbose  = flag.Bool("verbose", false, "Verbose output")
+	samples  = flag.Int("samples", 4, "Number of queries per server")
+	slew     = flag.Bool("slew", false, "Slew the clock instead of stepping it when the offset is small")
+	setRTC   = flag.Bool("rtc", false, "Write the new time to the hardware clock")
+	query    = flag.Bool("q", false, "Query only, do not set the clock")
+	daemon   = flag.Bool("d", false, "Keep running and adjust the clock periodically")
+	interval = flag.Duration("interval", 15*time.Minute, "Time between adjustments in daemon mode")
+	keyFile  = flag.String("keys", "/etc/ntp.keys", "ntp.keys file with symmetric keys")
+	keyID    = flag.Uint("keyid", 0, "Authenticate with this key from the keys file; 0 means no authentication")
+	debug    = func(string, ...interface{}) {}
 )
 
 const (
@@ -48,20 +75,67 @@ func parseServers(r *bufio.Reader) []string {
 	return uri
 }
 
-func getTime(servers []string) (t time.Time, err error) {
-	for _, s := range servers {
-		debug("Getting time from %v", s)
-		if t, err = ntp.Time(s); err == nil {
-			// Right now we return on the first valid time.
-			// We can implement better heuristics here.
-			debug("Got time %v", t)
-			return
+func getTime(servers []string, opts *ntp.Options) (*ntp.Result, error) {
+	res, err := ntp.Sample(servers, opts)
+	if res != nil {
+		for _, s := range res.Samples {
+			switch {
+			case s.Err != nil:
+				debug("%s: %v", s.Server, s.Err)
+			case s.Outlier:
+				debug("%s: offset %v delay %v jitter %v (rejected as outlier)", s.Server, s.Best.Offset, s.Best.Delay, s.Jitter)
+			default:
+				debug("%s: offset %v delay %v jitter %v stratum %d", s.Server, s.Best.Offset, s.Best.Delay, s.Jitter, s.Best.Stratum)
+			}
 		}
-		debug("Error getting time: %v", err)
 	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get any time from servers %v: %v", servers, err)
+	}
+	return res, nil
+}
 
-	err = fmt.Errorf("unable to get any time from servers %v", servers)
-	return
+func readKey(path string, id uint32) (*ntp.Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keys, err := ntp.ParseKeys(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	k, ok := keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%s: no key with ID %d", path, id)
+	}
+	return k, nil
+}
+
+func update(servers []string, opts *ntp.Options) error {
+	res, err := getTime(servers, opts)
+	if err != nil {
+		return err
+	}
+	if *query {
+		fmt.Printf("offset %v\n", res.Offset)
+		return nil
+	}
+	stepped, err := ntp.Adjust(res.Offset, *slew)
+	if err != nil {
+		return fmt.Errorf("unable to set system time: %v", err)
+	}
+	if stepped {
+		debug("Stepped clock by %v", res.Offset)
+	} else {
+		debug("Slewing clock by %v", res.Offset)
+	}
+	if *setRTC {
+		if err := ntp.SetRTC(); err != nil {
+			return fmt.Errorf("unable to set hardware clock: %v", err)
+		}
+	}
+	return nil
 }
 
 func main() {
@@ -71,24 +145,40 @@ func main() {
 		debug = log.Printf
 	}
 
-	debug("Reading NTP servers from config file: %v", *config)
-	f, err := os.Open(*config)
-	if err == nil {
-		defer f.Close()
-		servers = parseServers(bufio.NewReader(f))
-		debug("Found %v servers", len(servers))
+	if flag.NArg() > 0 {
+		servers = flag.Args()
 	} else {
-		log.Printf("Unable to open config file: %v\nFalling back to : %v", err, fallback)
-		servers = []string{fallback}
+		debug("Reading NTP servers from config file: %v", *config)
+		f, err := os.Open(*config)
+		if err == nil {
+			defer f.Close()
+			servers = parseServers(bufio.NewReader(f))
+			debug("Found %v servers", len(servers))
+		} else {
+			log.Printf("Unable to open config file: %v\nFalling back to : %v", err, fallback)
+			servers = []string{fallback}
+		}
 	}
 
-	t, err := getTime(servers)
-	if err != nil {
-		log.Fatalf("Unable to get time: %v", err)
+	opts := &ntp.Options{Samples: *samples, Interval: time.Second}
+	if *keyID != 0 {
+		k, err := readKey(*keyFile, uint32(*keyID))
+		if err != nil {
+			log.Fatalf("Unable to read key: %v", err)
+		}
+		opts.Key = k
 	}
 
-	tv := syscall.NsecToTimeval(t.UnixNano())
-	if err = syscall.Settimeofday(&tv); err != nil {
-		log.Fatalf("Unable to set system time: %v", err)
+	if !*daemon {
+		if err := update(servers, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for {
+		if err := update(servers, opts); err != nil {
+			log.Print(err)
+		}
+		time.Sleep(*interval)
 	}
 }
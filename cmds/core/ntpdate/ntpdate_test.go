@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/u-root/u-root/pkg/ntp"
 )
 
 var configFileTests = []struct {
@@ -85,7 +87,7 @@ var getTimeTests = []struct {
 
 func TestGetNoTime(t *testing.T) {
 	for _, tt := range getTimeTests {
-		_, err := getTime(tt.servers)
+		_, err := getTime(tt.servers, &ntp.Options{Samples: 1})
 
 		if err == nil {
 			t.Errorf("%v: got nil, want err", tt)
@@ -11,6 +11,8 @@
 //     -n: just show numbers
 //     -c: dump config space
 //     -s: specify glob for choosing devices.
+//     -v: decode the config space like lspci -vvv; all of it needs root
+//     -t: show the buses and bridges as a tree
 package main
 
 import (
@@ -27,6 +29,8 @@ var (
 	numbers    = flag.Bool("n", false, "Show numeric IDs")
 	dumpConfig = flag.Bool("c", false, "Dump config space")
 	devs       = flag.String("s", "*", "Devices to match")
+	verbose    = flag.Bool("v", false, "Decode the config space: BARs, bridge windows and capabilities")
+	tree       = flag.Bool("t", false, "Show the buses and bridges as a tree")
 	format     = map[int]string{
 		32: "%08x:%08x",
 		16: "%08x:%04x",
@@ -120,9 +124,22 @@ func main() {
 	if !*numbers {
 		d.SetVendorDeviceName()
 	}
+	if *tree {
+		t, err := d.Tree()
+		if err != nil {
+			log.Fatalf("Tree: %v", err)
+		}
+		fmt.Print(t)
+		return
+	}
 	if len(flag.Args()) > 0 {
 		registers(d, flag.Args()...)
 	}
+	if *verbose {
+		if err := d.Decode(); err != nil {
+			log.Fatalf("Decode: %v", err)
+		}
+	}
 	if *dumpConfig {
 		d.ReadConfig()
 	}
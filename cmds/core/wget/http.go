@@ -0,0 +1,157 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/curl"
+)
+
+// httpScheme is a curl.FileScheme for HTTP and HTTPS that supports custom
+// methods, headers and request bodies, and resuming from an offset.
+//
+// Unlike curl.HTTPClient, it does not cache the response in memory: the
+// returned io.ReaderAt must be read sequentially from offset 0.
+type httpScheme struct {
+	client *http.Client
+	method string
+	header http.Header
+
+	// body returns a fresh request body for every attempt, so that
+	// uploads can be retried. It is nil for requests without a body.
+	body func() (io.Reader, error)
+
+	// offset is the number of bytes we already have. If non-zero, only
+	// the rest is requested.
+	offset int64
+
+	// resumed is set by Fetch if the server sent only the bytes after
+	// offset. Otherwise, the response starts at byte 0.
+	resumed bool
+
+	// size is the total size of the file, or -1 if unknown.
+	size int64
+}
+
+// Fetch implements curl.FileScheme.Fetch.
+func (h *httpScheme) Fetch(ctx context.Context, u *url.URL) (io.ReaderAt, error) {
+	var body io.Reader
+	if h.body != nil {
+		b, err := h.body()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	req, err := http.NewRequestWithContext(ctx, h.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h.header {
+		req.Header[k] = v
+	}
+	// net/http takes the Host header from req.Host, not from the map.
+	if host := h.header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	if h.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", h.offset))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	h.resumed = false
+	h.size = resp.ContentLength
+
+	switch {
+	case h.offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if start != h.offset {
+			resp.Body.Close()
+			return nil, fmt.Errorf("server resumed at byte %d, want %d", start, h.offset)
+		}
+		h.resumed = true
+		h.size = total
+
+	case h.offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// We asked for bytes past the end; if the file is exactly as
+		// long as what we have, it is complete.
+		resp.Body.Close()
+		_, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || total != h.offset {
+			return nil, &curl.HTTPClientCodeError{HTTPCode: resp.StatusCode}
+		}
+		h.resumed = true
+		h.size = total
+		return &streamReaderAt{r: ioutil.NopCloser(strings.NewReader(""))}, nil
+
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		resp.Body.Close()
+		return nil, &curl.HTTPClientCodeError{HTTPCode: resp.StatusCode}
+	}
+	return &streamReaderAt{r: resp.Body}, nil
+}
+
+// parseContentRange parses a Content-Range header such as
+// "bytes 100-199/1000" or "bytes */1000". total is -1 if unknown.
+func parseContentRange(s string) (start, total int64, err error) {
+	var rng, tot string
+	if n, _ := fmt.Sscanf(s, "bytes %s", &rng); n != 1 {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	i := strings.Index(rng, "/")
+	if i < 0 {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	rng, tot = rng[:i], rng[i+1:]
+	total = -1
+	if tot != "*" {
+		if _, err := fmt.Sscanf(tot, "%d", &total); err != nil {
+			return 0, 0, fmt.Errorf("invalid Content-Range %q: %v", s, err)
+		}
+	}
+	if rng == "*" {
+		return 0, total, nil
+	}
+	if _, err := fmt.Sscanf(rng, "%d-", &start); err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q: %v", s, err)
+	}
+	return start, total, nil
+}
+
+// streamReaderAt turns a stream into an io.ReaderAt that must be read
+// sequentially, which is all uio.Reader does.
+type streamReaderAt struct {
+	r   io.ReadCloser
+	off int64
+}
+
+// ReadAt implements io.ReaderAt.
+func (s *streamReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off != s.off {
+		return 0, fmt.Errorf("non-sequential read at offset %d, want %d", off, s.off)
+	}
+	n, err := s.r.Read(p)
+	s.off += int64(n)
+	return n, err
+}
+
+// Close closes the underlying stream.
+func (s *streamReaderAt) Close() error {
+	return s.r.Close()
+}
@@ -5,11 +5,25 @@
 // Wget reads one file from a url and writes to stdout.
 //
 // Synopsis:
-//     wget URL
+//     wget [OPTIONS] URL
 //
 // Description:
 //     Returns a non-zero code on failure.
 //
+// Options:
+//     -O:          output file, "-" for stdout
+//     -c:          resume a partially downloaded file (HTTP only)
+//     -tries:      number of attempts, retrying with backoff
+//     -progress:   print a progress bar to stderr
+//     -header:     extra HTTP header "Name: value", may be repeated
+//     -user:       user name for HTTP basic authentication
+//     -password:   password for HTTP basic authentication
+//     -bearer:     token for HTTP bearer authentication
+//     -method:     HTTP method, default GET, or POST with -data
+//     -data:       request body
+//     -data-file:  file to send as the request body, e.g. with -method PUT
+//     -sha256:     expected SHA-256 of the file, as hex
+//
 // Notes:
 //     There are a few differences with GNU wget:
 //     - Upon error, the return value is always 1.
@@ -17,77 +31,325 @@
 //
 // Example:
 //     wget -O google.txt http://google.com/
+//     wget -c -tries 10 -progress -sha256 d2a8... http://bmc/image.iso
 package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"flag"
+	"fmt"
+	"hash"
 	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"strings"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/u-root/u-root/pkg/curl"
 	"github.com/u-root/u-root/pkg/uio"
 )
 
+// headers collects repeated -header flags.
+type headers []string
+
+func (h *headers) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headers) Set(s string) error {
+	if !strings.Contains(s, ":") {
+		return fmt.Errorf("header %q is not of the form \"Name: value\"", s)
+	}
+	*h = append(*h, s)
+	return nil
+}
+
 var (
-	outPath = flag.String("O", "", "output file")
+	outPath  = flag.String("O", "", "output file")
+	resume   = flag.Bool("c", false, "resume a partially downloaded file")
+	tries    = flag.Int("tries", 1, "number of attempts")
+	progress = flag.Bool("progress", false, "print a progress bar to stderr")
+	user     = flag.String("user", "", "user name for HTTP basic authentication")
+	password = flag.String("password", "", "password for HTTP basic authentication")
+	bearer   = flag.String("bearer", "", "token for HTTP bearer authentication")
+	method   = flag.String("method", "", "HTTP method (default GET, or POST with -data)")
+	data     = flag.String("data", "", "request body")
+	dataFile = flag.String("data-file", "", "file to send as the request body")
+	wantSHA  = flag.String("sha256", "", "expected SHA-256 of the file, as hex")
+	header   headers
 )
 
+func init() {
+	flag.Var(&header, "header", "extra HTTP header \"Name: value\", may be repeated")
+}
+
 func usage() {
 	log.Printf("Usage: %s [ARGS] URL\n", os.Args[0])
 	flag.PrintDefaults()
 	os.Exit(2)
 }
 
-func main() {
-	log.SetPrefix("wget: ")
+// progressBarWidth is the number of symbols in a full progress bar.
+const progressBarWidth = 50
 
-	if flag.Parse(); flag.NArg() != 1 {
-		usage()
+// newHTTPScheme builds an httpScheme from the command-line flags.
+func newHTTPScheme() (*httpScheme, error) {
+	h := &httpScheme{
+		client: http.DefaultClient,
+		method: *method,
+		header: http.Header{},
+	}
+	for _, hdr := range header {
+		i := strings.Index(hdr, ":")
+		h.header.Add(strings.TrimSpace(hdr[:i]), strings.TrimSpace(hdr[i+1:]))
+	}
+	switch {
+	case *bearer != "" && *user != "":
+		return nil, errors.New("-bearer and -user are mutually exclusive")
+	case *bearer != "":
+		h.header.Set("Authorization", "Bearer "+*bearer)
+	case *user != "":
+		r := &http.Request{Header: http.Header{}}
+		r.SetBasicAuth(*user, *password)
+		h.header.Set("Authorization", r.Header.Get("Authorization"))
 	}
 
-	argURL := flag.Arg(0)
+	switch {
+	case *data != "" && *dataFile != "":
+		return nil, errors.New("-data and -data-file are mutually exclusive")
+	case *data != "":
+		h.body = func() (io.Reader, error) { return strings.NewReader(*data), nil }
+	case *dataFile != "":
+		// Read the file up front so every retry sends the same body.
+		b, err := ioutil.ReadFile(*dataFile)
+		if err != nil {
+			return nil, err
+		}
+		h.body = func() (io.Reader, error) { return strings.NewReader(string(b)), nil }
+	}
+	if h.method == "" {
+		h.method = http.MethodGet
+		if h.body != nil {
+			h.method = http.MethodPost
+		}
+	}
+	return h, nil
+}
+
+// retryFuncs decide which errors are worth retrying, per scheme.
+var retryFuncs = map[string]curl.DoRetry{
+	"http":  curl.RetryOr(curl.RetryHTTP, curl.RetryConnectErrors, curl.RetryTemporaryNetworkErrors),
+	"https": curl.RetryOr(curl.RetryHTTP, curl.RetryConnectErrors, curl.RetryTemporaryNetworkErrors),
+	"tftp":  curl.RetryTFTP,
+	"file":  func(*url.URL, error) bool { return false },
+}
+
+// download is a single fetch of u into an output file.
+type download struct {
+	u       *url.URL
+	schemes curl.Schemes
+	http    *httpScheme
+	out     string
+	hash    hash.Hash
+}
+
+// outputFile opens the output. It returns the file and how many bytes of
+// it are already there to resume from.
+func (d *download) outputFile() (*os.File, int64, error) {
+	if d.out == "-" {
+		return os.Stdout, 0, nil
+	}
+	if !*resume || d.http == nil {
+		f, err := os.Create(d.out)
+		return f, 0, err
+	}
+	f, err := os.OpenFile(d.out, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Hash what we already have so that -sha256 covers the whole file.
+	n, err := io.Copy(d.hash, f)
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+// fetch downloads d.u into f, which already holds offset bytes. Failures
+// after the transfer started are retried by resuming where they left
+// off, when the scheme supports it.
+func (d *download) fetch(f *os.File, offset int64) error {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = 0
+	schemes := curl.Schemes{}
+	for k, v := range d.schemes {
+		schemes[k] = &curl.SchemeWithRetries{
+			Scheme:  v,
+			DoRetry: retryFuncs[k],
+			BackOff: backoff.WithMaxRetries(b, uint64(*tries-1)),
+		}
+	}
+
+	var err error
+	for attempt := 0; attempt < *tries; attempt++ {
+		if d.http != nil {
+			d.http.offset = offset
+		}
+		s, ok := schemes[d.u.Scheme]
+		if !ok {
+			return curl.ErrNoSuchScheme
+		}
+		var r io.ReaderAt
+		r, err = s.Fetch(context.Background(), d.u)
+		if err != nil {
+			return err
+		}
+		if d.http != nil && offset > 0 && !d.http.resumed {
+			// The server sent the whole file; start over.
+			log.Printf("Server does not support resuming, restarting download")
+			if err := restart(f, d.hash); err != nil {
+				return err
+			}
+			offset = 0
+		}
+
+		var src io.Reader = uio.Reader(r)
+		if *progress {
+			src = newProgress(src, d.size(), offset)
+		}
+		var n int64
+		n, err = io.Copy(io.MultiWriter(f, d.hash), src)
+		if c, ok := r.(io.Closer); ok {
+			c.Close()
+		}
+		if *progress {
+			fmt.Fprintln(os.Stderr)
+		}
+		offset += n
+		if err == nil {
+			return nil
+		}
+		if d.http == nil || f == os.Stdout {
+			return fmt.Errorf("failed to read response data: %v", err)
+		}
+		log.Printf("Transfer interrupted after %d bytes: %v; resuming", offset, err)
+		time.Sleep(b.NextBackOff())
+	}
+	return fmt.Errorf("failed to read response data: %v", err)
+}
+
+// size returns the expected total size of the download, or -1.
+func (d *download) size() int64 {
+	if d.http != nil {
+		return d.http.size
+	}
+	return -1
+}
+
+// restart truncates f and resets h.
+func restart(f *os.File, h hash.Hash) error {
+	h.Reset()
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+// newProgress wraps r in a uio.ProgressReader drawing a bar of
+// progressBarWidth symbols for size bytes, of which done are already
+// there. Without a size, it prints one symbol per megabyte.
+func newProgress(r io.Reader, size, done int64) io.Reader {
+	interval := 1 << 20
+	if size > 0 {
+		interval = int(size / progressBarWidth)
+		if interval == 0 {
+			interval = 1
+		}
+		fmt.Fprint(os.Stderr, strings.Repeat("=", int(done)/interval))
+	}
+	return &uio.ProgressReader{
+		R:        r,
+		Symbol:   "=",
+		Interval: interval,
+		W:        os.Stderr,
+	}
+}
+
+func run(argURL string) error {
 	if argURL == "" {
-		log.Fatalln("Empty URL")
+		return errors.New("empty URL")
 	}
 
-	url, err := url.Parse(argURL)
+	u, err := url.Parse(argURL)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
 
 	if *outPath == "" {
-		if url.Path != "" && url.Path[len(url.Path)-1] != '/' {
-			*outPath = path.Base(url.Path)
+		if u.Path != "" && u.Path[len(u.Path)-1] != '/' {
+			*outPath = path.Base(u.Path)
 		} else {
 			*outPath = "index.html"
 		}
 	}
+	if *tries < 1 {
+		*tries = 1
+	}
 
-	schemes := curl.Schemes{
-		"tftp": curl.DefaultTFTPClient,
-		"http": curl.DefaultHTTPClient,
-
-		// curl.DefaultSchemes doesn't support HTTPS by default.
-		"https": curl.DefaultHTTPClient,
-		"file":  &curl.LocalFileClient{},
+	d := &download{
+		u:    u,
+		out:  *outPath,
+		hash: sha256.New(),
+		schemes: curl.Schemes{
+			"tftp": curl.DefaultTFTPClient,
+			"file": &curl.LocalFileClient{},
+		},
+	}
+	if u.Scheme == "http" || u.Scheme == "https" {
+		if d.http, err = newHTTPScheme(); err != nil {
+			return err
+		}
+		d.schemes["http"] = d.http
+		d.schemes["https"] = d.http
 	}
 
-	readerAt, err := schemes.Fetch(context.Background(), url)
+	f, offset, err := d.outputFile()
 	if err != nil {
-		log.Fatalf("Failed to download %v: %v", argURL, err)
+		return fmt.Errorf("failed to create output file %q: %v", *outPath, err)
+	}
+	if f != os.Stdout {
+		defer f.Close()
 	}
+	if err := d.fetch(f, offset); err != nil {
+		return fmt.Errorf("failed to download %v: %v", argURL, err)
+	}
+	if *wantSHA != "" {
+		got := hex.EncodeToString(d.hash.Sum(nil))
+		if !strings.EqualFold(got, strings.TrimSpace(*wantSHA)) {
+			return fmt.Errorf("%s: sha256 mismatch: got %s, want %s", *outPath, got, *wantSHA)
+		}
+	}
+	return nil
+}
 
-	w, err := os.Create(*outPath)
-	if err != nil {
-		log.Fatalf("Failed to create output file %q: %v", *outPath, err)
+func main() {
+	log.SetPrefix("wget: ")
+
+	if flag.Parse(); flag.NArg() != 1 {
+		usage()
 	}
-	defer w.Close()
 
-	if _, err := io.Copy(w, uio.Reader(readerAt)); err != nil {
-		log.Fatalf("Failed to read response data: %v", err)
+	if err := run(flag.Arg(0)); err != nil {
+		log.Fatalln(err)
 	}
 }
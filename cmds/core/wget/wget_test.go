@@ -8,13 +8,19 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/u-root/u-root/pkg/testutil"
 )
@@ -148,3 +154,227 @@ func TestWget(t *testing.T) {
 func TestMain(m *testing.M) {
 	testutil.Run(m, main)
 }
+
+// featureServer serves a fixed payload with Range support and records
+// what it saw.
+type featureServer struct {
+	payload []byte
+
+	// failFirst makes the first n requests fail with 503.
+	failFirst int
+
+	requests   int
+	lastHeader http.Header
+	lastMethod string
+	lastBody   []byte
+}
+
+func (s *featureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	s.lastHeader = r.Header
+	s.lastMethod = r.Method
+	s.lastBody, _ = ioutil.ReadAll(r.Body)
+	if s.requests <= s.failFirst {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	switch r.URL.Path {
+	case "/secret":
+		if u, p, ok := r.BasicAuth(); !ok || u != "alice" || p != "hunter2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case "/upload":
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("stored"))
+		return
+	}
+	http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(s.payload))
+}
+
+func startFeatureServer(t *testing.T, s *featureServer) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: s}
+	go srv.Serve(l)
+	return "http://" + l.Addr().String(), func() { srv.Close() }
+}
+
+func TestWgetFeatures(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(payload)
+	digest := hex.EncodeToString(sum[:])
+
+	dir, err := ioutil.TempDir("", "wget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		name    string
+		server  featureServer
+		partial []byte
+		flags   []string
+		path    string
+		retCode int
+		want    []byte
+		check   func(t *testing.T, s *featureServer)
+	}{
+		{
+			name:    "resume",
+			partial: payload[:1234],
+			flags:   []string{"-c"},
+			path:    "/file",
+			want:    payload,
+			check: func(t *testing.T, s *featureServer) {
+				if r := s.lastHeader.Get("Range"); r != "bytes=1234-" {
+					t.Errorf("Range = %q, want %q", r, "bytes=1234-")
+				}
+			},
+		},
+		{
+			name:    "resume complete file",
+			partial: payload,
+			flags:   []string{"-c", "-sha256", digest},
+			path:    "/file",
+			want:    payload,
+		},
+		{
+			name:  "checksum",
+			flags: []string{"-sha256", digest},
+			path:  "/file",
+			want:  payload,
+		},
+		{
+			name:    "bad checksum",
+			flags:   []string{"-sha256", strings.Repeat("0", 64)},
+			path:    "/file",
+			retCode: 1,
+		},
+		{
+			name:   "retries",
+			server: featureServer{failFirst: 2},
+			flags:  []string{"-tries", "3"},
+			path:   "/file",
+			want:   payload,
+		},
+		{
+			name:    "too few retries",
+			server:  featureServer{failFirst: 2},
+			flags:   []string{"-tries", "2"},
+			path:    "/file",
+			retCode: 1,
+		},
+		{
+			name:  "basic auth and headers",
+			flags: []string{"-user", "alice", "-password", "hunter2", "-header", "X-Test: yes"},
+			path:  "/secret",
+			want:  payload,
+			check: func(t *testing.T, s *featureServer) {
+				if h := s.lastHeader.Get("X-Test"); h != "yes" {
+					t.Errorf("X-Test = %q, want %q", h, "yes")
+				}
+			},
+		},
+		{
+			name:    "bad auth",
+			flags:   []string{"-user", "alice", "-password", "wrong"},
+			path:    "/secret",
+			retCode: 1,
+		},
+		{
+			name:  "upload",
+			flags: []string{"-method", "PUT", "-data", "new contents"},
+			path:  "/upload",
+			want:  []byte("stored"),
+			check: func(t *testing.T, s *featureServer) {
+				if s.lastMethod != "PUT" || string(s.lastBody) != "new contents" {
+					t.Errorf("got %s %q, want PUT %q", s.lastMethod, s.lastBody, "new contents")
+				}
+			},
+		},
+		{
+			name:  "bearer",
+			flags: []string{"-bearer", "tok"},
+			path:  "/file",
+			want:  payload,
+			check: func(t *testing.T, s *featureServer) {
+				if h := s.lastHeader.Get("Authorization"); h != "Bearer tok" {
+					t.Errorf("Authorization = %q, want %q", h, "Bearer tok")
+				}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.server
+			s.payload = payload
+			base, stop := startFeatureServer(t, &s)
+			defer stop()
+
+			out := filepath.Join(dir, strings.Replace(tt.name, " ", "_", -1))
+			if tt.partial != nil {
+				if err := ioutil.WriteFile(out, tt.partial, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			args := append([]string{"-O", out}, tt.flags...)
+			output, err := testutil.Command(t, append(args, base+tt.path)...).CombinedOutput()
+			if err := testutil.IsExitCode(err, tt.retCode); err != nil {
+				t.Fatalf("exit code: %v, output: %s", err, output)
+			}
+			if tt.want != nil {
+				got, err := ioutil.ReadFile(out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, tt.want) {
+					t.Errorf("got %d bytes, want %d bytes", len(got), len(tt.want))
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, &s)
+			}
+		})
+	}
+}
+
+func TestWgetStdout(t *testing.T) {
+	s := &featureServer{payload: []byte(content)}
+	base, stop := startFeatureServer(t, s)
+	defer stop()
+
+	output, err := testutil.Command(t, "-O", "-", base+"/file").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != content {
+		t.Errorf("got %q, want %q", output, content)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	for _, tt := range []struct {
+		s     string
+		start int64
+		total int64
+		err   bool
+	}{
+		{s: "bytes 100-199/1000", start: 100, total: 1000},
+		{s: "bytes 100-199/*", start: 100, total: -1},
+		{s: "bytes */1000", total: 1000},
+		{s: "items 1-2/3", err: true},
+		{s: "bytes 100-199", err: true},
+	} {
+		start, total, err := parseContentRange(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("parseContentRange(%q) = %v, want error %t", tt.s, err, tt.err)
+			continue
+		}
+		if start != tt.start || total != tt.total {
+			t.Errorf("parseContentRange(%q) = %d, %d; want %d, %d", tt.s, start, total, tt.start, tt.total)
+		}
+	}
+}
@@ -3,37 +3,108 @@
 // license that can be found in the LICENSE file.
 
 // acpicat cats ACPI tables from the kernel.
-// The default method is "files", commonly provided in Linux via /sys.
-// Other methods are available depending on the platform.
-// Further selection of which tables are used can be done with acpigrep.
+//
+// Synopsis:
+//     acpicat [-d] [-s METHOD] [-p|-j] [FILE...]
+//
+// Description:
+//     The default method is "files", commonly provided in Linux via /sys.
+//     Other methods are available depending on the platform. With FILEs,
+//     the tables are read from them instead, e.g. from the output of
+//     acpicat or acpigrep. Further selection of which tables are used can
+//     be done with acpigrep.
+//
+//     By default, the raw tables are written. With -p or -j, the tables
+//     acpicat knows (APIC, BERT, DMAR, FACP, HEST, HPET, MCFG, SLIT and
+//     SRAT) are decoded and printed as text or JSON; only the headers of
+//     other tables are.
+//
+// Options:
+//     -d: enable debug prints
+//     -s: source of the tables
+//     -p: print the decoded tables
+//     -j: print the decoded tables as a JSON array
+//
+// Example:
+//     $ acpicat | acpigrep 'APIC|SRAT' | acpicat -p /dev/stdin
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/u-root/u-root/pkg/acpi"
 )
 
-var (
-	source = flag.String("s", acpi.DefaultMethod, "source of the tables")
-	debug  = flag.Bool("d", false, "Enable debug prints")
-)
-
-func main() {
-	flag.Parse()
+func run(args []string, stdout io.Writer) error {
+	f := flag.NewFlagSet("acpicat", flag.ContinueOnError)
+	source := f.String("s", acpi.DefaultMethod, "source of the tables")
+	debug := f.Bool("d", false, "Enable debug prints")
+	pretty := f.Bool("p", false, "Print the decoded tables")
+	js := f.Bool("j", false, "Print the decoded tables as JSON")
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if *pretty && *js {
+		return fmt.Errorf("-p and -j are exclusive")
+	}
 	if *debug {
 		acpi.Debug = log.Printf
 	}
-	t, err := acpi.ReadTables(*source)
-	if err != nil {
-		log.Fatal(err)
+
+	var t []acpi.Table
+	if f.NArg() == 0 {
+		var err error
+		if t, err = acpi.ReadTables(*source); err != nil {
+			return err
+		}
+	}
+	for _, n := range f.Args() {
+		tabs, err := acpi.RawFromName(n)
+		if err != nil {
+			return err
+		}
+		t = append(t, tabs...)
 	}
 	if len(t) == 0 {
-		log.Fatalf("%s: no tables read", *source)
+		return fmt.Errorf("%s: no tables read", *source)
 	}
-	if err := acpi.WriteTables(os.Stdout, t[0], t[1:]...); err != nil {
+	if !*pretty && !*js {
+		return acpi.WriteTables(stdout, t[0], t[1:]...)
+	}
+
+	var decoded []interface{}
+	for _, tab := range t {
+		v, err := acpi.Decode(tab)
+		if err != nil {
+			return fmt.Errorf("%s: %v", tab.Sig(), err)
+		}
+		decoded = append(decoded, v)
+	}
+	if *js {
+		b, err := json.MarshalIndent(decoded, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", b)
+		return err
+	}
+	// Tables are separated by blank lines.
+	for _, v := range decoded {
+		if _, err := fmt.Fprintf(stdout, "%s\n\n", strings.TrimSuffix(fmt.Sprint(v), "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
 		log.Fatal(err)
 	}
 }
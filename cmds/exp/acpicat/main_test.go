@@ -0,0 +1,65 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const tables = "../../../pkg/acpi/testdata/firecracker.bin"
+
+func TestRun(t *testing.T) {
+	raw, err := ioutil.ReadFile(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := run([]string{tables}, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), raw) {
+		t.Errorf("raw output differs from %s", tables)
+	}
+
+	b.Reset()
+	if err := run([]string{"-p", tables}, &b); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"APIC rev 6, 64 bytes, OEM \"FIRECK\" \"FCVMMADT\" rev 0x0, creator \"FCAT\" rev 0x20240119\n",
+		"\nIOAPIC ID=0 Address=0xfec00000 GSIBase=0\n",
+		"\nFlags: 0x100030 PWR_BUTTON|SLP_BUTTON|HW_REDUCED_ACPI\n",
+		"\nSegment 0000 buses 00-00: 0xeec00000-0xeecfffff\n\n",
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("acpicat -p output\n%s\ndoes not contain %q", b.String(), s)
+		}
+	}
+
+	b.Reset()
+	if err := run([]string{"-j", tables}, &b); err != nil {
+		t.Fatal(err)
+	}
+	var js []map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &js); err != nil {
+		t.Fatal(err)
+	}
+	var sigs []string
+	for _, m := range js {
+		sigs = append(sigs, m["Signature"].(string))
+	}
+	if strings.Join(sigs, " ") != "APIC FACP MCFG" {
+		t.Errorf("acpicat -j printed %v, want APIC, FACP and MCFG", sigs)
+	}
+
+	if err := run([]string{"-p", "-j", tables}, &b); err == nil {
+		t.Errorf("acpicat -p -j succeeded, want error")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// dig queries DNS servers.
+//
+// Synopsis:
+//     dig [OPTIONS] [@SERVER] NAME [TYPE]
+//     dig [OPTIONS] [@SERVER] -x ADDRESS
+//
+// Description:
+//     dig sends a single query and prints every section of the response
+//     together with the time it took. Without @SERVER, the nameservers
+//     in /etc/resolv.conf, as written by dhclient, are tried in order.
+//     Queries go over UDP and are retried over TCP if the response was
+//     truncated.
+//
+//     TYPE is one of A, AAAA, CNAME, MX, NS, PTR, SOA, SRV, TXT or ANY.
+//     The default is A, or PTR with -x.
+//
+// Options:
+//     -x:       reverse lookup of ADDRESS
+//     -t:       record type, as an alternative to the TYPE argument
+//     -tcp:     use TCP only
+//     -short:   only print the answer data
+//     -norec:   do not ask the server to recurse
+//     -timeout: time to wait for each response
+//     -conf:    resolv.conf file to read nameservers from
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var (
+	reverse = flag.String("x", "", "Reverse lookup of this address")
+	qtype   = flag.String("t", "", "Record type")
+	tcp     = flag.Bool("tcp", false, "Use TCP only")
+	short   = flag.Bool("short", false, "Only print the answer data")
+	norec   = flag.Bool("norec", false, "Do not ask the server to recurse")
+	timeout = flag.Duration("timeout", dns.DefaultTimeout, "Time to wait for each response")
+	conf    = flag.String("conf", dns.DefaultResolvConf, "resolv.conf file to read nameservers from")
+)
+
+// query is what to ask and whom.
+type query struct {
+	server string
+	name   string
+	typ    dnsmessage.Type
+}
+
+// parseArgs parses the dig-style positional arguments: an optional
+// @server, a name and an optional type, in any order.
+func parseArgs(args []string, reverse, qtype string) (*query, error) {
+	q := &query{typ: dnsmessage.TypeA}
+	if qtype != "" {
+		t, err := dns.ParseType(qtype)
+		if err != nil {
+			return nil, err
+		}
+		q.typ = t
+	}
+	if reverse != "" {
+		ip := net.ParseIP(reverse)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address %q", reverse)
+		}
+		n, err := dns.ReverseName(ip)
+		if err != nil {
+			return nil, err
+		}
+		q.name = n
+		if qtype == "" {
+			q.typ = dnsmessage.TypePTR
+		}
+	}
+	for _, a := range args {
+		if strings.HasPrefix(a, "@") {
+			q.server = a[1:]
+			continue
+		}
+		// A type is only recognized after the name, so that names
+		// like "a" can still be looked up.
+		if q.name != "" {
+			t, err := dns.ParseType(a)
+			if err != nil {
+				return nil, err
+			}
+			q.typ = t
+			continue
+		}
+		q.name = a
+	}
+	if q.name == "" {
+		return nil, errors.New("no name to look up")
+	}
+	return q, nil
+}
+
+// servers returns the servers to try: q.server if set, else those in the
+// resolv.conf file.
+func servers(q *query, conf string) ([]string, error) {
+	if q.server != "" {
+		return []string{q.server}, nil
+	}
+	rc, err := dns.ReadResolvConf(conf)
+	if err != nil {
+		return nil, err
+	}
+	if len(rc.Nameservers) == 0 {
+		return nil, fmt.Errorf("no nameservers in %s", conf)
+	}
+	var s []string
+	for _, ip := range rc.Nameservers {
+		s = append(s, ip.String())
+	}
+	return s, nil
+}
+
+func dig(w io.Writer, q *query, srvs []string) error {
+	var err error
+	for _, s := range srvs {
+		c := &dns.Client{
+			Server:    s,
+			Timeout:   *timeout,
+			TCP:       *tcp,
+			NoRecurse: *norec,
+		}
+		var r *dns.Response
+		if r, err = c.Query(q.name, q.typ); err != nil {
+			log.Printf("%s: %v", s, err)
+			continue
+		}
+		if *short {
+			for _, a := range r.Answers {
+				fmt.Fprintln(w, a.Data())
+			}
+			return nil
+		}
+		r.Format(w)
+		fmt.Fprintf(w, ";; WHEN: %s\n", time.Now().Format(time.RFC1123))
+		return nil
+	}
+	return fmt.Errorf("no server answered: %v", err)
+}
+
+func main() {
+	flag.Parse()
+	q, err := parseArgs(flag.Args(), *reverse, *qtype)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srvs, err := servers(q, *conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := dig(os.Stdout, q, srvs); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestParseArgs(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		reverse string
+		qtype   string
+		want    *query
+		err     bool
+	}{
+		{
+			args: []string{"example.com"},
+			want: &query{name: "example.com", typ: dnsmessage.TypeA},
+		},
+		{
+			args: []string{"@192.0.2.53", "example.com", "aaaa"},
+			want: &query{server: "192.0.2.53", name: "example.com", typ: dnsmessage.TypeAAAA},
+		},
+		{
+			args:  []string{"_ldap._tcp.example.com", "@[2001:db8::53]:5353"},
+			qtype: "SRV",
+			want:  &query{server: "[2001:db8::53]:5353", name: "_ldap._tcp.example.com", typ: dnsmessage.TypeSRV},
+		},
+		{
+			args: []string{"a"},
+			want: &query{name: "a", typ: dnsmessage.TypeA},
+		},
+		{
+			reverse: "192.0.2.1",
+			want:    &query{name: "1.2.0.192.in-addr.arpa.", typ: dnsmessage.TypePTR},
+		},
+		{args: []string{"example.com", "bogus"}, err: true},
+		{reverse: "not-an-ip", err: true},
+		{args: []string{"@192.0.2.53"}, err: true},
+	} {
+		got, err := parseArgs(tt.args, tt.reverse, tt.qtype)
+		if (err != nil) != tt.err {
+			t.Errorf("parseArgs(%q, %q, %q) = %v, want error %t", tt.args, tt.reverse, tt.qtype, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseArgs(%q, %q, %q) = %+v, want %+v", tt.args, tt.reverse, tt.qtype, got, tt.want)
+		}
+	}
+}
+
+func TestServers(t *testing.T) {
+	f, err := ioutil.TempFile("", "resolv.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("nameserver 192.0.2.53\nnameserver 2001:db8::53\n")
+	f.Close()
+
+	got, err := servers(&query{name: "x"}, f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"192.0.2.53", "2001:db8::53"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("servers = %v, want %v", got, want)
+	}
+
+	got, err = servers(&query{server: "192.0.2.1"}, f.Name())
+	if err != nil || !reflect.DeepEqual(got, []string{"192.0.2.1"}) {
+		t.Errorf("servers with @server = %v, %v; want [192.0.2.1]", got, err)
+	}
+}
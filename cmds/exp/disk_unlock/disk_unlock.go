@@ -2,30 +2,63 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// The disk_unlock command is used to unlock a disk drive as follows:
-// 1. Via BMC, read a 32-byte secret seed known as the Host Secret Seed (HSS)
-//    using the OpenBMC IPMI blob transfer protocol
-// 2. Compute a password as follows:
-//	We get the deterministically computed 32-byte HDKF-SHA256 using:
-//	- salt: "SKM PROD_V2 ACCESS"
-//	- hss: 32-byte HSS
-//	- device identity: strings formed by concatenating the assembly serial
-//	  number, the _ character, and the assembly part number.
-// 3. Unlock the drive with the given password
-// 4. Update the partition table for the disk
+// The disk_unlock command unlocks an ATA security locked drive or a LUKS
+// encrypted disk.
+//
+// Synopsis:
+//     disk_unlock [OPTIONS]
+//
+// Description:
+//     The key comes from one of these sources, chosen with -key:
+//
+//     ipmi:    Via BMC, read the 32-byte secrets known as Host Secret Seeds
+//              (HSS) using the OpenBMC IPMI blob transfer protocol. Each
+//              gives a password, which is the 32-byte HKDF-SHA256 of:
+//              - salt: "SKM PROD_V2 ACCESS"
+//              - hss: 32-byte HSS
+//              - device identity: for ATA drives, the assembly serial
+//                number, the _ character, and the assembly part number;
+//                for LUKS disks, the LUKS UUID.
+//     prompt:  Read a passphrase from the terminal.
+//     keyfile: Use the contents of the -keyfile file.
+//     tpm:     Unseal a TPM2 object made by tpm2_create, whose public and
+//              private parts are in -tpm-public and -tpm-private, under
+//              -tpm-parent or a primary key made with tpm2_createprimary's
+//              default template, with a policy on -tpm-pcrs if given.
+//
+//     An ATA drive is unlocked with the password and its partition table
+//     is reread. A LUKS disk's volume key is unlocked with the passphrase
+//     and its data is mapped through dm-crypt to /dev/mapper/NAME.
+//
+// Options:
+//     -disk:         the disk to unlock (default /dev/sda)
+//     -mode:         ata, luks or auto to detect a LUKS header (default auto);
+//                    auto unlocks with ata if the disk has no LUKS header or
+//                    can not be read, as an ATA locked disk can not
+//     -key:          ipmi, prompt, keyfile or tpm (default ipmi)
+//     -keyfile:      the key file for -key keyfile, or - for stdin
+//     -name:         the device-mapper name for LUKS (default luks-UUID)
+//     -ro:           map a LUKS disk read-only
+//     -tpm-public:   the TPM2B_PUBLIC of the sealed object
+//     -tpm-private:  the TPM2B_PRIVATE of the sealed object
+//     -tpm-parent:   the persistent handle of the sealed object's parent
+//     -tpm-pcrs:     the SHA256 PCRs the object is sealed to, e.g. 0,2,4,7
+//     -d:            print debug output
+//     -no-reread-partitions: only unlock an ATA disk, don't reread its
+//                    partition table
 package main
 
 import (
 	"crypto/sha256"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"path/filepath"
-	"strings"
 
-	"github.com/u-root/u-root/pkg/ipmi"
-	"github.com/u-root/u-root/pkg/ipmi/blobs"
+	"github.com/u-root/u-root/pkg/luks"
 	"github.com/u-root/u-root/pkg/mount/block"
 	"github.com/u-root/u-root/pkg/mount/scuzz"
 	"golang.org/x/crypto/hkdf"
@@ -39,6 +72,11 @@ const (
 
 var (
 	disk               = flag.String("disk", "/dev/sda", "The disk to be unlocked")
+	mode               = flag.String("mode", "auto", "How the disk is locked: ata, luks or auto")
+	keySource          = flag.String("key", "ipmi", "Where the key comes from: ipmi, prompt, keyfile or tpm")
+	keyFile            = flag.String("keyfile", "", "The key file for -key keyfile, or - for stdin")
+	mapName            = flag.String("name", "", "The device-mapper name of an unlocked LUKS disk (default luks-UUID)")
+	readOnly           = flag.Bool("ro", false, "Map a LUKS disk read-only")
 	verbose            = flag.Bool("d", false, "print debug output")
 	verboseNoSanitize  = flag.Bool("dangerously-disable-sanitize", false, "Print sensitive information - this should only be used for testing!")
 	noRereadPartitions = flag.Bool("no-reread-partitions", false, "Only attempt to unlock the disk, don't re-read the partition table.")
@@ -50,80 +88,10 @@ func verboseLog(msg string) {
 	}
 }
 
-// readHssBlob reads a host secret seed from the given blob id.
-func readHssBlob(id string, h *blobs.BlobHandler) (data []uint8, rerr error) {
-	sessionID, err := h.BlobOpen(id, blobs.BMC_BLOB_OPEN_FLAG_READ)
-	if err != nil {
-		return nil, fmt.Errorf("IPMI BlobOpen for %s failed: %v", id, err)
-	}
-	defer func() {
-		// If the function returned successfully but failed to close the blob,
-		// return an error.
-		if err := h.BlobClose(sessionID); err != nil && rerr == nil {
-			rerr = fmt.Errorf("IPMI BlobClose %s failed: %v", id, err)
-		}
-	}()
-
-	data, err = h.BlobRead(sessionID, 0, hostSecretSeedLen)
-	if err != nil {
-		return nil, fmt.Errorf("IPMI BlobRead %s failed: %v", id, err)
-	}
-
-	if len(data) != hostSecretSeedLen {
-		return nil, fmt.Errorf("HSS size incorrect: got %d for %s", len(data), id)
-	}
-
-	return data, nil
-}
-
-// getAllHss reads all host secret seeds over IPMI.
-func getAllHss() ([][]uint8, error) {
-	i, err := ipmi.Open(0)
-	if err != nil {
-		return nil, err
-	}
-	h := blobs.NewBlobHandler(i)
-
-	blobCount, err := h.BlobGetCount()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get blob count: %v", err)
-	}
-
-	hssList := [][]uint8{}
-	skmSubstr := "/skm/hss/"
-
-	// Read from all */skm/hss/* blobs.
-	for j := 0; j < blobCount; j++ {
-		id, err := h.BlobEnumerate(j)
-		if err != nil {
-			return nil, fmt.Errorf("failed to enumerate blob %d: %v", j, err)
-		}
-
-		if !strings.Contains(id, skmSubstr) {
-			continue
-		}
-
-		hss, err := readHssBlob(id, h)
-		if err != nil {
-			log.Printf("failed to read HSS of id %s: %v", id, err)
-		} else {
-			msg := fmt.Sprintf("HSS Entry: Id=%s", id)
-			if *verboseNoSanitize {
-				msg = msg + fmt.Sprintf(",Seed=%x", hss)
-			}
-			verboseLog(msg)
-			hssList = append(hssList, hss)
-		}
-	}
-
-	return hssList, nil
-}
-
 // Compute the password deterministically as the 32-byte HDKF-SHA256 of the
 // HSS plus the device identity.
-func genPassword(hss []byte, info *scuzz.Info) ([]byte, error) {
+func genPassword(hss []byte, devID string) ([]byte, error) {
 	hash := sha256.New
-	devID := fmt.Sprintf("%s_%s", info.Serial, info.Model)
 
 	r := hkdf.New(hash, hss, ([]byte)(passwordSalt), ([]byte)(devID))
 	key := make([]byte, 32)
@@ -134,77 +102,113 @@ func genPassword(hss []byte, info *scuzz.Info) ([]byte, error) {
 	return key, nil
 }
 
-func main() {
-	flag.Parse()
-
-	// Obtain 32 byte Host Secret Seed (HSS) from IPMI.
-	hssList, err := getAllHss()
-	if err != nil {
-		log.Fatalf("error getting HSS: %v", err)
-	}
-
-	if len(hssList) == 0 {
-		log.Fatalf("no HSS found - can't unlock disk.")
-	}
-
-	verboseLog(fmt.Sprintf("Found %d Host Secret Seeds.", len(hssList)))
-
+// unlockATA unlocks an ATA security locked drive and rereads its
+// partition table.
+func unlockATA(path string) error {
 	// Open the disk. Read its identity, and use it to unlock the disk.
-	sgdisk, err := scuzz.NewSGDisk(*disk)
+	sgdisk, err := scuzz.NewSGDisk(path)
 	if err != nil {
-		log.Fatalf("failed to open disk %v: %v", *disk, err)
+		return fmt.Errorf("failed to open disk %v: %v", path, err)
 	}
 
 	info, err := sgdisk.Identify()
 	if err != nil {
-		log.Fatalf("failed to read disk %v identity: %v", *disk, err)
+		return fmt.Errorf("failed to read disk %v identity: %v", path, err)
 	}
 
-	verboseLog(fmt.Sprintf("Disk info for %s: %s", *disk, info.String()))
+	verboseLog(fmt.Sprintf("Disk info for %s: %s", path, info.String()))
 
-	// Try using each HSS to unlock the disk - only 1 should work.
-	unlocked := false
-	for i, hss := range hssList {
-		key, err := genPassword(hss, info)
-		if err != nil {
-			log.Printf("Couldn't generate password with HSS %d: %v", i, err)
-			continue
-		}
+	keys, err := getKeys(fmt.Sprintf("%s_%s", info.Serial, info.Model))
+	if err != nil {
+		return err
+	}
 
+	// Try each key - only 1 should work.
+	unlocked := false
+	for i, key := range keys {
 		if err := sgdisk.Unlock((string)(key), false); err != nil {
-			log.Printf("Couldn't unlock disk with HSS %d: %v", i, err)
+			log.Printf("Couldn't unlock disk with key %d: %v", i, err)
 		} else {
 			unlocked = true
 			break
 		}
 	}
 
-	if unlocked {
-		log.Printf("Successfully unlocked disk %s.", *disk)
-	} else {
-		log.Fatalf("Failed to unlock disk %s with any HSS.", *disk)
+	if !unlocked {
+		return fmt.Errorf("failed to unlock disk %s with any key", path)
 	}
+	log.Printf("Successfully unlocked disk %s.", path)
 
 	if *noRereadPartitions {
-		return
+		return nil
 	}
 
 	// Update partitions on the on the disk.
-	diskdev, err := block.Device(*disk)
+	diskdev, err := block.Device(path)
 	if err != nil {
-		log.Fatalf("Could not find %s: %v", *disk, err)
+		return fmt.Errorf("could not find %s: %v", path, err)
 	}
 
 	if err := diskdev.ReadPartitionTable(); err != nil {
-		log.Fatalf("Could not re-read partition table: %v", err)
+		return fmt.Errorf("could not re-read partition table: %v", err)
 	}
 
 	glob := filepath.Join("/sys/class/block", diskdev.Name+"*")
 	parts, err := filepath.Glob(glob)
 	if err != nil {
-		log.Fatalf("Could not find disk partitions: %v", err)
+		return fmt.Errorf("could not find disk partitions: %v", err)
 	}
 
-	verboseLog(fmt.Sprintf("Found these %s unlocked partitions: %v", *disk, parts))
+	verboseLog(fmt.Sprintf("Found these %s unlocked partitions: %v", path, parts))
+	return nil
+}
 
+// isLUKS reports whether the disk at path has a LUKS header.
+func isLUKS(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	_, err = luks.ReadHeader(f)
+	if errors.Is(err, luks.ErrNotLUKS) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// autoMode returns the mode to unlock the disk at path with: luks if it
+// has a LUKS header, ata otherwise. An ATA security locked disk fails
+// every read, so a disk that can not be read is taken to be one.
+func autoMode(path string) string {
+	l, err := isLUKS(path)
+	if err != nil {
+		verboseLog(fmt.Sprintf("Can not read %s, trying ATA unlock: %v", path, err))
+	}
+	if l {
+		return "luks"
+	}
+	return "ata"
+}
+
+func main() {
+	flag.Parse()
+
+	m := *mode
+	if m == "auto" {
+		m = autoMode(*disk)
+	}
+
+	var err error
+	switch m {
+	case "ata":
+		err = unlockATA(*disk)
+	case "luks":
+		err = unlockLUKS(*disk, *mapName, *readOnly)
+	default:
+		log.Fatalf("Unknown mode %q", m)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
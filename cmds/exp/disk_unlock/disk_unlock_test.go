@@ -0,0 +1,149 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenPassword(t *testing.T) {
+	hss := bytes.Repeat([]byte{0x5a}, hostSecretSeedLen)
+	a, err := genPassword(hss, "S123_M456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := genPassword(hss, "2183ead8-a510-4b3d-9777-19c7090f66d9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 32 || bytes.Equal(a, b) {
+		t.Errorf("passwords for different devices are %x and %x", a, b)
+	}
+	if c, _ := genPassword(hss, "S123_M456"); !bytes.Equal(a, c) {
+		t.Errorf("genPassword is not deterministic: %x, %x", a, c)
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk_unlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := filepath.Join(dir, "key")
+	if err := ioutil.WriteFile(key, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The newline is part of the key, as with cryptsetup.
+	if k, err := readKeyFile(key, nil); err != nil || string(k) != "secret\n" {
+		t.Errorf("readKeyFile = %q, %v, want %q", k, err, "secret\n")
+	}
+	if k, err := readKeyFile("-", strings.NewReader("from stdin")); err != nil || string(k) != "from stdin" {
+		t.Errorf("readKeyFile(-) = %q, %v", k, err)
+	}
+	for _, path := range []string{"", empty, filepath.Join(dir, "missing")} {
+		if _, err := readKeyFile(path, nil); err == nil {
+			t.Errorf("readKeyFile(%q) succeeded", path)
+		}
+	}
+}
+
+func TestPromptKey(t *testing.T) {
+	f, err := ioutil.TempFile("", "disk_unlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString("pass phrase\r\nignored\n")
+	f.Seek(0, 0)
+
+	var prompt bytes.Buffer
+	k, err := promptKey(f, &prompt)
+	if err != nil || string(k) != "pass phrase" {
+		t.Errorf("promptKey = %q, %v, want %q", k, err, "pass phrase")
+	}
+	if !strings.HasPrefix(prompt.String(), "Passphrase for") {
+		t.Errorf("prompt is %q", prompt.String())
+	}
+}
+
+func TestReadTPM2B(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disk_unlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		data []byte
+		want []byte
+		err  bool
+	}{
+		{data: []byte{0, 3, 1, 2, 3}, want: []byte{1, 2, 3}},
+		{data: []byte{0, 4, 1, 2, 3}, err: true},
+		{data: []byte{0}, err: true},
+	} {
+		path := filepath.Join(dir, "blob")
+		if err := ioutil.WriteFile(path, tt.data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readTPM2B(path)
+		if (err != nil) != tt.err || !bytes.Equal(got, tt.want) {
+			t.Errorf("readTPM2B(%x) = %x, %v, want %x", tt.data, got, err, tt.want)
+		}
+	}
+}
+
+func TestParsePCRs(t *testing.T) {
+	if got, err := parsePCRs("0, 2,4,7"); err != nil || !reflect.DeepEqual(got, []int{0, 2, 4, 7}) {
+		t.Errorf("parsePCRs = %v, %v", got, err)
+	}
+	for _, s := range []string{"", "1,,2", "24", "-1", "x"} {
+		if _, err := parsePCRs(s); err == nil {
+			t.Errorf("parsePCRs(%q) succeeded", s)
+		}
+	}
+}
+
+func TestIsLUKS(t *testing.T) {
+	for path, want := range map[string]bool{
+		"../../../pkg/luks/testdata/luks1.img": true,
+		"../../../pkg/luks/testdata/luks2.img": true,
+		"disk_unlock.go":                       false,
+	} {
+		if got, err := isLUKS(path); err != nil || got != want {
+			t.Errorf("isLUKS(%s) = %v, %v, want %v", path, got, err, want)
+		}
+	}
+	if _, err := isLUKS("missing"); err == nil {
+		t.Errorf("isLUKS of a missing file succeeded")
+	}
+}
+
+func TestAutoMode(t *testing.T) {
+	for path, want := range map[string]string{
+		"../../../pkg/luks/testdata/luks2.img": "luks",
+		"disk_unlock.go":                       "ata",
+		// Reads fail, as on an ATA security locked disk.
+		".": "ata",
+	} {
+		if got := autoMode(path); got != want {
+			t.Errorf("autoMode(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/ipmi"
+	"github.com/u-root/u-root/pkg/ipmi/blobs"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// getKeys returns the candidate keys from the -key source. devID
+// identifies the disk for keys derived from the HSS.
+func getKeys(devID string) ([][]byte, error) {
+	switch *keySource {
+	case "ipmi":
+		return ipmiKeys(devID)
+	case "prompt":
+		k, err := promptKey(os.Stdin, os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{k}, nil
+	case "keyfile":
+		k, err := readKeyFile(*keyFile, os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{k}, nil
+	case "tpm":
+		k, err := tpmKey()
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{k}, nil
+	default:
+		return nil, fmt.Errorf("unknown key source %q", *keySource)
+	}
+}
+
+// ipmiKeys returns a password for each HSS the BMC has.
+func ipmiKeys(devID string) ([][]byte, error) {
+	// Obtain 32 byte Host Secret Seed (HSS) from IPMI.
+	hssList, err := getAllHss()
+	if err != nil {
+		return nil, fmt.Errorf("error getting HSS: %v", err)
+	}
+
+	if len(hssList) == 0 {
+		return nil, fmt.Errorf("no HSS found - can't unlock disk")
+	}
+
+	verboseLog(fmt.Sprintf("Found %d Host Secret Seeds.", len(hssList)))
+
+	var keys [][]byte
+	for i, hss := range hssList {
+		key, err := genPassword(hss, devID)
+		if err != nil {
+			log.Printf("Couldn't generate password with HSS %d: %v", i, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readHssBlob reads a host secret seed from the given blob id.
+func readHssBlob(id string, h *blobs.BlobHandler) (data []uint8, rerr error) {
+	sessionID, err := h.BlobOpen(id, blobs.BMC_BLOB_OPEN_FLAG_READ)
+	if err != nil {
+		return nil, fmt.Errorf("IPMI BlobOpen for %s failed: %v", id, err)
+	}
+	defer func() {
+		// If the function returned successfully but failed to close the blob,
+		// return an error.
+		if err := h.BlobClose(sessionID); err != nil && rerr == nil {
+			rerr = fmt.Errorf("IPMI BlobClose %s failed: %v", id, err)
+		}
+	}()
+
+	data, err = h.BlobRead(sessionID, 0, hostSecretSeedLen)
+	if err != nil {
+		return nil, fmt.Errorf("IPMI BlobRead %s failed: %v", id, err)
+	}
+
+	if len(data) != hostSecretSeedLen {
+		return nil, fmt.Errorf("HSS size incorrect: got %d for %s", len(data), id)
+	}
+
+	return data, nil
+}
+
+// getAllHss reads all host secret seeds over IPMI.
+func getAllHss() ([][]uint8, error) {
+	i, err := ipmi.Open(0)
+	if err != nil {
+		return nil, err
+	}
+	h := blobs.NewBlobHandler(i)
+
+	blobCount, err := h.BlobGetCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob count: %v", err)
+	}
+
+	hssList := [][]uint8{}
+	skmSubstr := "/skm/hss/"
+
+	// Read from all */skm/hss/* blobs.
+	for j := 0; j < blobCount; j++ {
+		id, err := h.BlobEnumerate(j)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enumerate blob %d: %v", j, err)
+		}
+
+		if !strings.Contains(id, skmSubstr) {
+			continue
+		}
+
+		hss, err := readHssBlob(id, h)
+		if err != nil {
+			log.Printf("failed to read HSS of id %s: %v", id, err)
+		} else {
+			msg := fmt.Sprintf("HSS Entry: Id=%s", id)
+			if *verboseNoSanitize {
+				msg = msg + fmt.Sprintf(",Seed=%x", hss)
+			}
+			verboseLog(msg)
+			hssList = append(hssList, hss)
+		}
+	}
+
+	return hssList, nil
+}
+
+// promptKey reads a passphrase from in, without echo if it is a terminal.
+func promptKey(in *os.File, prompt io.Writer) ([]byte, error) {
+	fmt.Fprintf(prompt, "Passphrase for %s: ", *disk)
+	if terminal.IsTerminal(int(in.Fd())) {
+		defer fmt.Fprintln(prompt)
+		return terminal.ReadPassword(int(in.Fd()))
+	}
+	line, err := bufio.NewReader(in).ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, fmt.Errorf("reading passphrase: %v", err)
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// readKeyFile returns the contents of a key file, or of stdin for "-".
+// As with cryptsetup, the whole file, including any newline, is the key.
+func readKeyFile(path string, stdin io.Reader) ([]byte, error) {
+	var k []byte
+	var err error
+	switch path {
+	case "":
+		return nil, fmt.Errorf("-key keyfile needs -keyfile")
+	case "-":
+		k, err = ioutil.ReadAll(stdin)
+	default:
+		k, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(k) == 0 {
+		return nil, fmt.Errorf("key file %s is empty", path)
+	}
+	return k, nil
+}
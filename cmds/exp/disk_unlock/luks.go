@@ -0,0 +1,67 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/u-root/u-root/pkg/dm"
+	"github.com/u-root/u-root/pkg/luks"
+)
+
+// unlockLUKS unlocks the LUKS disk at path and maps it as name.
+func unlockLUKS(path, name string, readOnly bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h, err := luks.ReadHeader(f)
+	if err != nil {
+		return fmt.Errorf("reading the LUKS header of %s: %v", path, err)
+	}
+	verboseLog(fmt.Sprintf("LUKS%d header on %s: UUID %s, %d key slots", h.Version, path, h.UUID, len(h.Keyslots)))
+
+	keys, err := getKeys(h.UUID)
+	if err != nil {
+		return err
+	}
+	var volumeKey []byte
+	for i, key := range keys {
+		k, slot, err := h.VolumeKey(f, key)
+		if err != nil {
+			log.Printf("Couldn't unlock %s with key %d: %v", path, i, err)
+			continue
+		}
+		verboseLog(fmt.Sprintf("Key %d unlocked key slot %d", i, slot))
+		volumeKey = k
+		break
+	}
+	if volumeKey == nil {
+		return fmt.Errorf("failed to unlock %s with any key", path)
+	}
+	defer func() {
+		for i := range volumeKey {
+			volumeKey[i] = 0
+		}
+	}()
+
+	if name == "" {
+		name = "luks-" + h.UUID
+	}
+	c, err := dm.Open()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	d, err := h.Activate(c, name, path, volumeKey, readOnly)
+	if err != nil {
+		return err
+	}
+	log.Printf("Successfully unlocked %s as %s.", path, d.Path())
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+var (
+	tpmPublic  = flag.String("tpm-public", "", "The TPM2B_PUBLIC of the sealed key, as written by tpm2_create -u")
+	tpmPrivate = flag.String("tpm-private", "", "The TPM2B_PRIVATE of the sealed key, as written by tpm2_create -r")
+	tpmParent  = flag.String("tpm-parent", "", "The persistent handle of the sealed key's parent (default a primary key from the default template)")
+	tpmPCRs    = flag.String("tpm-pcrs", "", "The SHA256 PCRs the key is sealed to, e.g. 0,2,4,7")
+)
+
+// readTPM2B reads a TPM2B structure from a file and returns its contents,
+// without the size.
+func readTPM2B(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 2 || int(binary.BigEndian.Uint16(b)) != len(b)-2 {
+		return nil, fmt.Errorf("%s is not a TPM2B structure", path)
+	}
+	return b[2:], nil
+}
+
+func parsePCRs(s string) ([]int, error) {
+	var pcrs []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 0 || n > 23 {
+			return nil, fmt.Errorf("%q is not a PCR", f)
+		}
+		pcrs = append(pcrs, n)
+	}
+	return pcrs, nil
+}
+
+// tpmKey unseals the key in -tpm-public and -tpm-private.
+func tpmKey() ([]byte, error) {
+	if *tpmPublic == "" || *tpmPrivate == "" {
+		return nil, fmt.Errorf("-key tpm needs -tpm-public and -tpm-private")
+	}
+	pub, err := readTPM2B(*tpmPublic)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := readTPM2B(*tpmPrivate)
+	if err != nil {
+		return nil, err
+	}
+	var pcrs []int
+	if *tpmPCRs != "" {
+		if pcrs, err = parsePCRs(*tpmPCRs); err != nil {
+			return nil, err
+		}
+	}
+	var parent uint32
+	if *tpmParent != "" {
+		h, err := strconv.ParseUint(*tpmParent, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a TPM handle", *tpmParent)
+		}
+		parent = uint32(h)
+	}
+
+	t, err := tss.NewTPM()
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	if t.Version != tss.TPMVersion20 {
+		return nil, fmt.Errorf("sealed keys need a TPM 2.0")
+	}
+	return t.Unseal(&tss.SealedData{PCRs: pcrs, Public: pub, Private: priv, Parent: parent}, "")
+}
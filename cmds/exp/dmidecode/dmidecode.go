@@ -7,6 +7,7 @@ package main
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -20,6 +21,7 @@ var (
 	flagDumpBin  = flag.String("dump-bin", "", `Do not decode the entries, instead dump the DMI data to a file in binary form. The generated file is suitable to pass to --from-dump later.`)
 	flagFromDump = flag.String("from-dump", "", `Read the DMI data from a binary file previously generated using --dump-bin.`)
 	flagType     = flag.StringSliceP("type", "t", nil, `Only  display  the  entries of type TYPE. TYPE can be either a DMI type number, or a comma-separated list of type numbers, or a keyword from the following list: bios, system, baseboard, chassis, processor, memory, cache, connector, slot. If this option is used more than once, the set of displayed entries will be the union of all the given types. If TYPE is not provided or not valid, a list of all valid keywords is printed and dmidecode exits with an error.`)
+	flagString   = flag.StringP("string", "s", "", `Only display the value of the DMI string identified by KEYWORD. It must be a keyword from the following list: bios-vendor, bios-version, bios-release-date, system-manufacturer, system-product-name, system-version, system-serial-number, system-uuid, system-family, baseboard-manufacturer, baseboard-product-name, baseboard-version, baseboard-serial-number, baseboard-asset-tag, chassis-manufacturer, chassis-type, chassis-version, chassis-serial-number, chassis-asset-tag, processor-family, processor-manufacturer, processor-version, processor-frequency. Each keyword corresponds to a given DMI type and a given offset within this entry type. If KEYWORD is not provided or not valid, a list of all valid keywords is printed and dmidecode exits with an error.`)
 	// NB: When adding flags, update resetFlags in dmidecode_test.
 )
 
@@ -102,8 +104,19 @@ func dmiDecode(textOut io.Writer) *dmiDecodeError {
 	if err != nil {
 		return &dmiDecodeError{code: 2, error: fmt.Errorf("invalid --type: %v", err)}
 	}
-	fmt.Fprintf(textOut, "# dmidecode-go\n") // TODO: version.
-	entryData, tableData, err := getData(textOut, *flagFromDump, "/sys/firmware/dmi/tables")
+	var kw *stringKeyword
+	if *flagString != "" {
+		if kw = findStringKeyword(*flagString); kw == nil {
+			return &dmiDecodeError{code: 2, error: invalidStringKeyword(*flagString)}
+		}
+	}
+	// Only the values are printed for --string.
+	infoOut := textOut
+	if kw != nil {
+		infoOut = ioutil.Discard
+	}
+	fmt.Fprintf(infoOut, "# dmidecode-go\n") // TODO: version.
+	entryData, tableData, err := getData(infoOut, *flagFromDump, "/sys/firmware/dmi/tables")
 	if err != nil {
 		return &dmiDecodeError{code: 1, error: fmt.Errorf("error parsing loading data: %v", err)}
 	}
@@ -114,6 +127,16 @@ func dmiDecode(textOut io.Writer) *dmiDecodeError {
 	if err != nil {
 		return &dmiDecodeError{code: 1, error: fmt.Errorf("error parsing data: %v", err)}
 	}
+	if kw != nil {
+		values, err := kw.values(si)
+		if err != nil {
+			return &dmiDecodeError{code: 1, error: fmt.Errorf("error parsing data: %v", err)}
+		}
+		for _, v := range values {
+			fmt.Fprintf(textOut, "%s\n", v)
+		}
+		return nil
+	}
 	if si.Entry64 != nil {
 		fmt.Fprintf(textOut, "SMBIOS %d.%d.%d present.\n", si.MajorVersion(), si.MinorVersion(), si.DocRev())
 	} else {
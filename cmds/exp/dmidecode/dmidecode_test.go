@@ -25,6 +25,7 @@ const (
 func resetFlags() {
 	*flagFromDump = ""
 	*flagType = nil
+	*flagString = ""
 }
 
 func testOutput(t *testing.T, dumpFile string, args []string, expectedOutFile string) {
@@ -69,6 +70,37 @@ func TestDMIDecodeTypeFilters(t *testing.T) {
 	testOutput(t, "testdata/Asus-UX307LA.bin", []string{"-t", "1,131"}, "testdata/Asus-UX307LA.1_131.txt")
 }
 
+func TestDMIDecodeString(t *testing.T) {
+	for _, tt := range []struct {
+		keyword string
+		want    string
+	}{
+		{"bios-vendor", "American Megatrends Inc.\n"},
+		{"system-serial-number", "9000116105\n"},
+		{"system-uuid", "00000000-0000-0000-0000-0cc47a133878\n"},
+		{"chassis-type", "Main Server Chassis\n"},
+		{"processor-frequency", "1800 MHz\n1800 MHz\n"},
+		{"Processor-Version", "Intel(R) Xeon(R) CPU E5-2403 v2 @ 1.80GHz\nIntel(R) Xeon(R) CPU E5-2403 v2 @ 1.80GHz\n"},
+	} {
+		os.Args = []string{os.Args[0], "--from-dump", "testdata/SuperMicro-X9DBL.bin", "-s", tt.keyword}
+		flag.Parse()
+		out := &bytes.Buffer{}
+		if err := dmiDecode(out); err != nil {
+			t.Errorf("-s %s: %v", tt.keyword, err)
+		} else if out.String() != tt.want {
+			t.Errorf("-s %s = %q, want %q", tt.keyword, out.String(), tt.want)
+		}
+		resetFlags()
+	}
+
+	os.Args = []string{os.Args[0], "--from-dump", "testdata/SuperMicro-X9DBL.bin", "-s", "system-serial"}
+	flag.Parse()
+	defer resetFlags()
+	if err := dmiDecode(ioutil.Discard); err == nil || err.code != 2 {
+		t.Errorf("-s system-serial: got %v, want an invalid keyword error", err)
+	}
+}
+
 func testDumpBin(t *testing.T, entryData, expectedOutData []byte) {
 	tmpfile, err := ioutil.TempFile("", "dmidecode")
 	if err != nil {
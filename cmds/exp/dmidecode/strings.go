@@ -0,0 +1,92 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/smbios"
+)
+
+// stringKeyword is a keyword of --string: a field of tables of one type.
+type stringKeyword struct {
+	name string
+	tt   smbios.TableType
+	// off is the offset of the field; tables too short to have it are
+	// skipped.
+	off int
+	// value returns the field of the parsed table.
+	value func(pt interface{}) string
+}
+
+// stringKeywords are the keywords of --string, in the order dmidecode(8)
+// lists them.
+var stringKeywords = []stringKeyword{
+	{"bios-vendor", smbios.TableTypeBIOSInfo, 0x04, func(pt interface{}) string { return pt.(*smbios.BIOSInfo).Vendor }},
+	{"bios-version", smbios.TableTypeBIOSInfo, 0x05, func(pt interface{}) string { return pt.(*smbios.BIOSInfo).Version }},
+	{"bios-release-date", smbios.TableTypeBIOSInfo, 0x08, func(pt interface{}) string { return pt.(*smbios.BIOSInfo).ReleaseDate }},
+	{"system-manufacturer", smbios.TableTypeSystemInfo, 0x04, func(pt interface{}) string { return pt.(*smbios.SystemInfo).Manufacturer }},
+	{"system-product-name", smbios.TableTypeSystemInfo, 0x05, func(pt interface{}) string { return pt.(*smbios.SystemInfo).ProductName }},
+	{"system-version", smbios.TableTypeSystemInfo, 0x06, func(pt interface{}) string { return pt.(*smbios.SystemInfo).Version }},
+	{"system-serial-number", smbios.TableTypeSystemInfo, 0x07, func(pt interface{}) string { return pt.(*smbios.SystemInfo).SerialNumber }},
+	{"system-uuid", smbios.TableTypeSystemInfo, 0x08, func(pt interface{}) string { return pt.(*smbios.SystemInfo).UUID.String() }},
+	{"system-family", smbios.TableTypeSystemInfo, 0x1a, func(pt interface{}) string { return pt.(*smbios.SystemInfo).Family }},
+	{"baseboard-manufacturer", smbios.TableTypeBaseboardInfo, 0x04, func(pt interface{}) string { return pt.(*smbios.BaseboardInfo).Manufacturer }},
+	{"baseboard-product-name", smbios.TableTypeBaseboardInfo, 0x05, func(pt interface{}) string { return pt.(*smbios.BaseboardInfo).Product }},
+	{"baseboard-version", smbios.TableTypeBaseboardInfo, 0x06, func(pt interface{}) string { return pt.(*smbios.BaseboardInfo).Version }},
+	{"baseboard-serial-number", smbios.TableTypeBaseboardInfo, 0x07, func(pt interface{}) string { return pt.(*smbios.BaseboardInfo).SerialNumber }},
+	{"baseboard-asset-tag", smbios.TableTypeBaseboardInfo, 0x08, func(pt interface{}) string { return pt.(*smbios.BaseboardInfo).AssetTag }},
+	{"chassis-manufacturer", smbios.TableTypeChassisInfo, 0x04, func(pt interface{}) string { return pt.(*smbios.ChassisInfo).Manufacturer }},
+	{"chassis-type", smbios.TableTypeChassisInfo, 0x05, func(pt interface{}) string { return pt.(*smbios.ChassisInfo).Type.String() }},
+	{"chassis-version", smbios.TableTypeChassisInfo, 0x06, func(pt interface{}) string { return pt.(*smbios.ChassisInfo).Version }},
+	{"chassis-serial-number", smbios.TableTypeChassisInfo, 0x07, func(pt interface{}) string { return pt.(*smbios.ChassisInfo).SerialNumber }},
+	{"chassis-asset-tag", smbios.TableTypeChassisInfo, 0x08, func(pt interface{}) string { return pt.(*smbios.ChassisInfo).AssetTagNumber }},
+	{"processor-family", smbios.TableTypeProcessorInfo, 0x06, func(pt interface{}) string { return pt.(*smbios.ProcessorInfo).GetFamily().String() }},
+	{"processor-manufacturer", smbios.TableTypeProcessorInfo, 0x07, func(pt interface{}) string { return pt.(*smbios.ProcessorInfo).Manufacturer }},
+	{"processor-version", smbios.TableTypeProcessorInfo, 0x10, func(pt interface{}) string { return pt.(*smbios.ProcessorInfo).Version }},
+	{"processor-frequency", smbios.TableTypeProcessorInfo, 0x16, func(pt interface{}) string {
+		if s := pt.(*smbios.ProcessorInfo).CurrentSpeed; s != 0 {
+			return fmt.Sprintf("%d MHz", s)
+		}
+		return "Unknown"
+	}},
+}
+
+func findStringKeyword(name string) *stringKeyword {
+	for i, kw := range stringKeywords {
+		if kw.name == strings.ToLower(name) {
+			return &stringKeywords[i]
+		}
+	}
+	return nil
+}
+
+func invalidStringKeyword(name string) error {
+	lines := []string{
+		fmt.Sprintf("Invalid string keyword: %s", name),
+		"Valid string keywords are:",
+	}
+	for _, kw := range stringKeywords {
+		lines = append(lines, "  "+kw.name)
+	}
+	return fmt.Errorf("%s", strings.Join(lines, "\n"))
+}
+
+// values returns the value of the keyword for each table of its type.
+func (kw *stringKeyword) values(si *smbios.Info) ([]string, error) {
+	var values []string
+	for _, t := range si.GetTablesByType(kw.tt) {
+		if int(t.Length) <= kw.off {
+			continue
+		}
+		pt, err := smbios.ParseTypedTable(t)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, kw.value(pt))
+	}
+	return values, nil
+}
@@ -0,0 +1,256 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// efibootmgr prints and changes the UEFI boot entries, BootOrder and
+// BootNext in efivarfs.
+//
+// Synopsis:
+//     efibootmgr [-v]
+//     efibootmgr -c [-d DISK] [-p PART] -l LOADER [-L LABEL] [-@ FILE]
+//     efibootmgr -b XXXX -B
+//     efibootmgr -b XXXX -a|-A
+//     efibootmgr -o XXXX,YYYY,...
+//     efibootmgr -n XXXX
+//     efibootmgr -N
+//
+// Description:
+//     Without options, efibootmgr prints BootCurrent, BootNext, BootOrder
+//     and the boot entries, an active one with a *. Entry numbers are
+//     hexadecimal, as in the names of the BootXXXX vars.
+//
+//     -c creates an entry for the LOADER on partition PART of the GPT disk
+//     DISK, numbered with the lowest free number, and puts it first in
+//     BootOrder. -B removes entry XXXX, also from BootOrder.
+//
+//     After a change, efibootmgr prints the entries again.
+//
+// Options:
+//     -v: print the device paths and optional data of the entries
+//     -c: create an entry
+//     -d: the disk of the loader (default /dev/sda)
+//     -p: the partition of the loader, from 1 (default 1)
+//     -l: the path of the loader on the partition, e.g. /EFI/BOOT/BOOTX64.EFI
+//     -L: the description of the entry (default Linux)
+//     -@: a file with the optional data of the entry, e.g. a kernel command
+//         line in UTF-16
+//     -b: the entry to remove or change
+//     -B: remove the entry
+//     -a: make the entry active
+//     -A: make the entry inactive
+//     -o: set BootOrder
+//     -n: set BootNext
+//     -N: remove BootNext
+//
+// Example:
+//     $ efibootmgr -c -d /dev/nvme0n1 -p 1 -l /EFI/u-root/bootx64.efi -L u-root
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/uefivars"
+	"github.com/u-root/u-root/pkg/uefivars/boot"
+)
+
+var (
+	verbose    = flag.Bool("v", false, "print the device paths and optional data of the entries")
+	create     = flag.Bool("c", false, "create an entry")
+	disk       = flag.String("d", "/dev/sda", "disk of the loader")
+	part       = flag.Uint("p", 1, "partition of the loader, from 1")
+	loader     = flag.String("l", "", "path of the loader on the partition")
+	label      = flag.String("L", "Linux", "description of the entry")
+	optFile    = flag.String("@", "", "file with the optional data of the entry")
+	bootNum    = flag.String("b", "", "entry to remove or change, hexadecimal")
+	remove     = flag.Bool("B", false, "remove the entry")
+	active     = flag.Bool("a", false, "make the entry active")
+	inactive   = flag.Bool("A", false, "make the entry inactive")
+	bootOrder  = flag.String("o", "", "set BootOrder to the comma separated entries")
+	bootNext   = flag.String("n", "", "set BootNext")
+	deleteNext = flag.Bool("N", false, "remove BootNext")
+)
+
+func parseNum(s string) (uint16, error) {
+	n, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad entry number %q, want XXXX: %w", s, err)
+	}
+	return uint16(n), nil
+}
+
+func createEntry() (*boot.BootEntryVar, error) {
+	if *loader == "" {
+		return nil, fmt.Errorf("-c needs the loader, -l")
+	}
+	dev, err := block.Device(*disk)
+	if err != nil {
+		return nil, err
+	}
+	hdd, err := boot.NewDppMediaHDD(dev, uint32(*part))
+	if err != nil {
+		return nil, err
+	}
+	// Accept windows slashes, as efibootmgr does.
+	path := strings.Replace(*loader, "\\", "/", -1)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	o := boot.EfiLoadOption{
+		Attributes:   boot.LoadOptionActive,
+		Description:  *label,
+		FilePathList: boot.EfiDevicePathProtocolList{hdd, &boot.DppMediaFilePath{PathNameDecoded: path}},
+	}
+	if *optFile != "" {
+		if o.OptionalData, err = ioutil.ReadFile(*optFile); err != nil {
+			return nil, err
+		}
+	}
+	return boot.AddBootVar(o)
+}
+
+func setActive(num uint16, on bool) error {
+	b, err := boot.GetBootVar(num)
+	if err != nil {
+		return err
+	}
+	if on {
+		b.Attributes |= boot.LoadOptionActive
+	} else {
+		b.Attributes &^= boot.LoadOptionActive
+	}
+	return boot.SetBootVar(b)
+}
+
+func setOrder(s string) error {
+	var order []uint16
+	for _, f := range strings.Split(s, ",") {
+		n, err := parseNum(f)
+		if err != nil {
+			return err
+		}
+		if _, err := boot.GetBootVar(n); err != nil {
+			return fmt.Errorf("BootOrder entry %04X: %w", n, err)
+		}
+		order = append(order, n)
+	}
+	return boot.SetBootOrder(order)
+}
+
+// change makes the change the flags ask for, if any.
+func change() error {
+	var num uint16
+	if *bootNum != "" {
+		var err error
+		if num, err = parseNum(*bootNum); err != nil {
+			return err
+		}
+	} else if *remove || *active || *inactive {
+		return fmt.Errorf("-B, -a and -A need the entry, -b")
+	}
+
+	switch {
+	case *create:
+		b, err := createEntry()
+		if err != nil {
+			return err
+		}
+		log.Printf("Created Boot%04X", b.Number)
+	case *remove:
+		if err := boot.RemoveBootVar(num); err != nil {
+			return err
+		}
+	case *active || *inactive:
+		if err := setActive(num, *active); err != nil {
+			return err
+		}
+	}
+	if *bootOrder != "" {
+		if err := setOrder(*bootOrder); err != nil {
+			return err
+		}
+	}
+	if *bootNext != "" {
+		n, err := parseNum(*bootNext)
+		if err != nil {
+			return err
+		}
+		if _, err := boot.GetBootVar(n); err != nil {
+			return err
+		}
+		if err := boot.SetBootNext(n); err != nil {
+			return err
+		}
+	}
+	if *deleteNext {
+		if err := boot.DeleteBootNext(); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatOrder(order []uint16) string {
+	var s []string
+	for _, n := range order {
+		s = append(s, fmt.Sprintf("%04X", n))
+	}
+	return strings.Join(s, ",")
+}
+
+func printEntries() error {
+	if v, _, err := uefivars.GetVar(boot.BootUUID, "BootCurrent"); err == nil {
+		if c := boot.BootCurrent(uefivars.EfiVars{v}); c != nil {
+			fmt.Printf("BootCurrent: %04X\n", c.Current)
+		}
+	}
+	if n, err := boot.GetBootNext(); err == nil {
+		fmt.Printf("BootNext: %04X\n", n)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if order, err := boot.GetBootOrder(); err == nil {
+		fmt.Printf("BootOrder: %s\n", formatOrder(order))
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	entries, err := boot.GetBootEntryVars()
+	if err != nil {
+		return err
+	}
+	for _, b := range entries {
+		a := " "
+		if b.Attributes&boot.LoadOptionActive != 0 {
+			a = "*"
+		}
+		fmt.Printf("Boot%04X%s %s", b.Number, a, b.Description)
+		if *verbose {
+			fmt.Printf("\t%s", b.FilePathList)
+			if len(b.OptionalData) != 0 {
+				fmt.Printf(" %x", b.OptionalData)
+			}
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 || *active && *inactive {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := change(); err != nil {
+		log.Fatal(err)
+	}
+	if err := printEntries(); err != nil {
+		log.Fatal(err)
+	}
+}
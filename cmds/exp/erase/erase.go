@@ -0,0 +1,198 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// erase securely erases whole disks and certifies the erase.
+//
+// Synopsis:
+//     erase [OPTIONS] DEVICE...
+//
+// Description:
+//     Each DEVICE, e.g. /dev/sda or nvme0n1, is erased with the strongest
+//     method it supports: NVMe or SCSI sanitize, ATA security erase, NVMe
+//     format with secure erase, or overwriting from the host followed by a
+//     discard. Blocks are sampled before and after to check that no data
+//     survived.
+//
+//     For each disk a JSON certificate of the erase, signed with an
+//     ed25519 key, is written. Certificates of failed verifications are
+//     written too, and erase then exits with an error.
+//
+// Options:
+//     -list:         list the methods each disk supports, strongest first,
+//                    and exit
+//     -method M:     use method M rather than the strongest
+//     -passes N:     overwrite passes (default 1)
+//     -samples N:    blocks to sample for verification (default 64)
+//     -key FILE:     PEM ed25519 private key to sign certificates with
+//     -o FILE:       write certificates to FILE rather than stdout
+//     -force:        really erase
+//
+// Example:
+//     erase -list /dev/nvme0n1
+//     erase -key erase.pem -o /tmp/sda.json -force /dev/sda
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/crypto"
+	"github.com/u-root/u-root/pkg/erase"
+	"github.com/u-root/u-root/pkg/mount/block"
+	"golang.org/x/crypto/ed25519"
+)
+
+const usage = "usage: erase [-list] [-method M] [-passes N] [-samples N] [-key FILE] [-o FILE] -force DEVICE..."
+
+// loadKey reads an ed25519 private key, either as written by
+// pkg/crypto or in PKCS #8 form.
+func loadKey(path string) (ed25519.PrivateKey, error) {
+	b, err := crypto.LoadPrivateKeyFromFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == ed25519.PrivateKeySize {
+		return ed25519.PrivateKey(b), nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	key, ok := k.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %T is not an ed25519 key", path, k)
+	}
+	return key, nil
+}
+
+func open(name string) (*erase.Target, error) {
+	b, err := block.Device(name)
+	if err != nil {
+		return nil, err
+	}
+	return erase.Open(b)
+}
+
+// progress logs every tenth of the erase.
+func progress(w io.Writer, name string) func(erase.Method, float64) {
+	last := -1
+	return func(m erase.Method, done float64) {
+		if n := int(done * 10); n != last {
+			last = n
+			fmt.Fprintf(w, "%s: %s %d%%\n", name, m, n*10)
+		}
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer, open func(string) (*erase.Target, error)) error {
+	f := flag.NewFlagSet("erase", flag.ContinueOnError)
+	f.SetOutput(stderr)
+	var (
+		list    = f.Bool("list", false, "list the methods each disk supports")
+		method  = f.String("method", "", "erase method")
+		passes  = f.Int("passes", 1, "overwrite passes")
+		samples = f.Int("samples", 64, "blocks to sample for verification")
+		keyFile = f.String("key", "", "ed25519 private key to sign certificates with")
+		out     = f.String("o", "", "write certificates to this file")
+		force   = f.Bool("force", false, "really erase")
+	)
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() == 0 {
+		return errors.New(usage)
+	}
+	if *method != "" {
+		ok := false
+		for _, m := range erase.Methods {
+			ok = ok || m == erase.Method(*method)
+		}
+		if !ok {
+			return fmt.Errorf("unknown method %q", *method)
+		}
+	}
+
+	if *list {
+		for _, name := range f.Args() {
+			t, err := open(name)
+			if err != nil {
+				return err
+			}
+			ms, err := t.Available()
+			t.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			s := make([]string, len(ms))
+			for i, m := range ms {
+				s[i] = string(m)
+			}
+			fmt.Fprintf(stdout, "%s: %s\n", name, strings.Join(s, " "))
+		}
+		return nil
+	}
+
+	if !*force {
+		return errors.New("erase destroys all data on the disks; use -force")
+	}
+	if *keyFile == "" {
+		return errors.New("certificates must be signed; use -key")
+	}
+	key, err := loadKey(*keyFile)
+	if err != nil {
+		return err
+	}
+	w := stdout
+	if *out != "" {
+		o, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer o.Close()
+		w = o
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+
+	var failed error
+	for _, name := range f.Args() {
+		t, err := open(name)
+		if err != nil {
+			return err
+		}
+		c, err := erase.Erase(t, &erase.Options{
+			Method:   erase.Method(*method),
+			Passes:   *passes,
+			Samples:  *samples,
+			Progress: progress(stderr, name),
+		})
+		t.Close()
+		if c == nil {
+			return err
+		}
+		if err != nil {
+			failed = err
+		}
+		if err := c.Sign(key); err != nil {
+			return err
+		}
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	return failed
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr, open); err != nil {
+		log.Fatal(err)
+	}
+}
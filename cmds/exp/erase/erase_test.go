@@ -0,0 +1,125 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/erase"
+	"golang.org/x/crypto/ed25519"
+)
+
+type memDisk []byte
+
+func (d memDisk) ReadAt(b []byte, off int64) (int, error) {
+	return copy(b, d[off:]), nil
+}
+
+func (d memDisk) WriteAt(b []byte, off int64) (int, error) {
+	return copy(d[off:], b), nil
+}
+
+func (d memDisk) Discard(off, n int64) error {
+	return nil
+}
+
+func (d memDisk) Flush() error {
+	return nil
+}
+
+func TestErase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "erase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pub, key, err := ed25519.GenerateKey(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	disks := map[string]memDisk{}
+	open := func(name string) (*erase.Target, error) {
+		d, ok := disks[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: no such disk", name)
+		}
+		return &erase.Target{Name: name, Size: int64(len(d)), Disk: d}, nil
+	}
+	for _, n := range []string{"sda", "sdb"} {
+		d := make(memDisk, 1<<20)
+		rand.Read(d)
+		disks[n] = d
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-list", "sda"}, &stdout, &stderr, open); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "sda: overwrite\n"; got != want {
+		t.Errorf("erase -list: got %q, want %q", got, want)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		err  string
+	}{
+		{nil, "usage"},
+		{[]string{"sda"}, "-force"},
+		{[]string{"-force", "sda"}, "-key"},
+		{[]string{"-method", "magic", "-force", "sda"}, "unknown method"},
+		{[]string{"-method", "ata-erase", "-key", keyFile, "-force", "sda"}, "not available"},
+		{[]string{"-key", keyFile, "-force", "sdz"}, "no such disk"},
+	} {
+		err := run(tt.args, &stdout, &stderr, open)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("erase %v: got %v, want error containing %q", tt.args, err, tt.err)
+		}
+	}
+
+	out := filepath.Join(dir, "certs.json")
+	stderr.Reset()
+	if err := run([]string{"-key", keyFile, "-o", out, "-passes", "2", "-force", "sda", "sdb"}, &stdout, &stderr, open); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "sdb: overwrite 100%") {
+		t.Errorf("progress: got %q", stderr.String())
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for _, n := range []string{"sda", "sdb"} {
+		var c erase.Certificate
+		if err := dec.Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Verify(pub); err != nil {
+			t.Errorf("%s: %v", n, err)
+		}
+		if c.Device != n || c.Method != erase.Overwrite || c.Passes != 2 || !c.Verification.Passed {
+			t.Errorf("%s: got certificate %+v", n, c)
+		}
+		if !bytes.Equal(disks[n], make([]byte, len(disks[n]))) {
+			t.Errorf("%s: not erased", n)
+		}
+	}
+}
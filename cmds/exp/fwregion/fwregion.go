@@ -0,0 +1,379 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// fwregion shows, edits and flashes the regions of firmware images.
+//
+// Synopsis:
+//     fwregion COMMAND [OPTIONS] ARGS
+//
+// Description:
+//     Regions come from the image's Intel flash descriptor, named SI_DESC,
+//     SI_BIOS, SI_ME, SI_GBE etc., and from its FMAP. FLASH is an MTD
+//     device, e.g. /dev/mtd0, or a file. The commands are:
+//
+//     layout FILE:         print the descriptor and FMAP of an image or
+//                          flash
+//     read FLASH:          read region -r of FLASH into file -o
+//     verify IMAGE FLASH:  check that regions -r, or all of it, of FLASH
+//                          match IMAGE
+//     write IMAGE FLASH:   write regions -r of IMAGE to FLASH, leaving the
+//                          rest of FLASH alone, and verify them. On an
+//                          MTD device the regions are erased first, and
+//                          must be aligned to its erase blocks
+//     ifd IMAGE:           lock or unlock the descriptor master access in
+//                          an image file
+//
+// Options:
+//     -json:        print the layout as JSON
+//     -r REGIONS:   comma separated region names
+//     -o FILE:      output file for read and ifd (default: IMAGE for ifd)
+//     -lock:        let masters write only their own regions
+//     -unlock:      let masters read and write every region
+//     -force:       write even if the layout of FLASH differs from IMAGE's
+//
+// Example:
+//     fwregion layout /dev/mtd0
+//     fwregion write -r SI_BIOS coreboot.rom /dev/mtd0
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/fmap"
+	"github.com/u-root/u-root/pkg/ifd"
+	"github.com/u-root/u-root/pkg/mount/mtd"
+)
+
+const usage = "usage: fwregion COMMAND [OPTIONS] ARGS\n" +
+	"commands: layout FILE, read FLASH, verify IMAGE FLASH, write IMAGE FLASH, ifd IMAGE"
+
+type options struct {
+	json    bool
+	regions string
+	out     string
+	lock    bool
+	unlock  bool
+	force   bool
+}
+
+// layout is the regions of a firmware image.
+type layout struct {
+	Descriptor *ifd.Descriptor `json:",omitempty"`
+	FMAP       *fmap.FMap      `json:",omitempty"`
+	FMAPOffset int64           `json:",omitempty"`
+}
+
+func parseLayout(image []byte) (*layout, error) {
+	l := &layout{}
+	if ifd.Find(image) {
+		d, err := ifd.Parse(image)
+		if err != nil {
+			return nil, err
+		}
+		l.Descriptor = d
+	}
+	f, off, err := fmap.Find(image)
+	switch err {
+	case nil:
+		l.FMAP, l.FMAPOffset = f, off
+	case fmap.ErrNotFound:
+		if l.Descriptor == nil {
+			return nil, errors.New("no flash descriptor or FMAP")
+		}
+	default:
+		return nil, err
+	}
+	return l, nil
+}
+
+// regions returns the descriptor regions, then the FMAP areas not
+// already named by the descriptor.
+func (l *layout) regions() []mtd.Region {
+	var rs []mtd.Region
+	if l.Descriptor != nil {
+		rs = l.Descriptor.FlashRegions()
+	}
+	if l.FMAP != nil {
+		for _, r := range l.FMAP.Regions() {
+			if _, err := mtd.FindRegion(rs, r.Name); err != nil {
+				rs = append(rs, r)
+			}
+		}
+	}
+	return rs
+}
+
+// find returns the regions named in a comma separated list.
+func (l *layout) find(names string) ([]mtd.Region, error) {
+	if names == "" {
+		return nil, errors.New("no regions given; use -r, e.g. -r SI_BIOS")
+	}
+	var rs []mtd.Region
+	for _, n := range strings.Split(names, ",") {
+		r, err := mtd.FindRegion(l.regions(), n)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func regionList(m uint16) string {
+	var s []string
+	for i := 0; i < ifd.MaxRegions; i++ {
+		if m&(1<<uint(i)) != 0 {
+			s = append(s, ifd.RegionNames[i])
+		}
+	}
+	if len(s) == 0 {
+		return "none"
+	}
+	return strings.Join(s, " ")
+}
+
+func printLayout(w io.Writer, l *layout) {
+	if d := l.Descriptor; d != nil {
+		fmt.Fprintf(w, "Intel flash descriptor version %d\n", d.Version)
+		fmt.Fprintf(w, "FLMAP0 %#08x FLMAP1 %#08x FLMAP2 %#08x FLCOMP %#08x\n", d.FLMAP0, d.FLMAP1, d.FLMAP2, d.FLCOMP)
+		fmt.Fprintf(w, "Regions:\n")
+		for i, r := range d.Regions {
+			if r.Used() {
+				fmt.Fprintf(w, "  %-2d %-12s %08x-%08x\n", i, ifd.RegionNames[i], r.Offset(), r.Offset()+r.Size()-1)
+			} else {
+				fmt.Fprintf(w, "  %-2d %-12s unused\n", i, ifd.RegionNames[i])
+			}
+		}
+		fmt.Fprintf(w, "Masters:\n")
+		for i, m := range d.Masters {
+			name := "Master" + strconv.Itoa(i+1)
+			if i+1 < len(ifd.MasterNames) {
+				name = ifd.MasterNames[i+1]
+			}
+			fmt.Fprintf(w, "  %d %-9s read: %s\n", i+1, name, regionList(m.Read))
+			fmt.Fprintf(w, "    %-9s write: %s\n", "", regionList(m.Write))
+			if d.Version == ifd.Version1 {
+				fmt.Fprintf(w, "    %-9s requester ID: %#04x\n", "", m.RequesterID)
+			}
+		}
+		for i, s := range d.PCHStraps {
+			fmt.Fprintf(w, "PCH strap %d: %#08x\n", i, s)
+		}
+		for i, s := range d.ProcessorStraps {
+			fmt.Fprintf(w, "Processor strap %d: %#08x\n", i, s)
+		}
+	}
+	if f := l.FMAP; f != nil {
+		fmt.Fprintf(w, "FMAP %q at %#x, version %d.%d, base %#x, size %#x\n", f.Name, l.FMAPOffset, f.VerMajor, f.VerMinor, f.Base, f.Size)
+		for _, a := range f.Areas {
+			l := fmt.Sprintf("  %-24s %08x-%08x %s", a.Name, a.Offset, a.Offset+a.Size-1, a.FlagString())
+			fmt.Fprintln(w, strings.TrimRight(l, " "))
+		}
+	}
+}
+
+func readAll(f mtd.Flasher, size int64) ([]byte, error) {
+	return mtd.ReadRegion(f, mtd.Region{Name: "flash", Size: size})
+}
+
+// openFlash opens an MTD device or a file.
+func openFlash(path string) (mtd.Flasher, int64, error) {
+	f, err := mtd.NewDev(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if b, err := ioutil.ReadFile(filepath.Join("/sys/class/mtd", filepath.Base(path), "size")); err == nil {
+		size, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, size, nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+func command(w io.Writer, cmd string, args []string, o *options, open func(string) (mtd.Flasher, int64, error)) error {
+	nargs := map[string]int{"layout": 1, "read": 1, "verify": 2, "write": 2, "ifd": 1}
+	if n, ok := nargs[cmd]; !ok || len(args) != n {
+		return errors.New(usage)
+	}
+
+	if cmd == "ifd" {
+		image, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		d, err := ifd.Parse(image)
+		if err != nil {
+			return err
+		}
+		switch {
+		case o.lock == o.unlock:
+			return errors.New("ifd needs one of -lock and -unlock")
+		case o.lock:
+			d.Lock()
+		default:
+			d.Unlock()
+		}
+		if err := d.Write(image); err != nil {
+			return err
+		}
+		out := o.out
+		if out == "" {
+			out = args[0]
+		}
+		return ioutil.WriteFile(out, image, 0644)
+	}
+
+	var image []byte
+	if cmd == "verify" || cmd == "write" {
+		var err error
+		if image, err = ioutil.ReadFile(args[0]); err != nil {
+			return err
+		}
+		args = args[1:]
+	}
+	f, size, err := open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if image != nil && int64(len(image)) != size {
+		return fmt.Errorf("image is %#x bytes but flash is %#x", len(image), size)
+	}
+
+	switch cmd {
+	case "layout":
+		b, err := readAll(f, size)
+		if err != nil {
+			return err
+		}
+		l, err := parseLayout(b)
+		if err != nil {
+			return err
+		}
+		if o.json {
+			b, err := json.MarshalIndent(l, "", "\t")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s\n", b)
+			return err
+		}
+		printLayout(w, l)
+
+	case "read":
+		if o.out == "" {
+			return errors.New("read needs -o")
+		}
+		b, err := readAll(f, size)
+		if err != nil {
+			return err
+		}
+		l, err := parseLayout(b)
+		if err != nil {
+			return err
+		}
+		rs, err := l.find(o.regions)
+		if err != nil {
+			return err
+		}
+		if len(rs) != 1 {
+			return errors.New("read takes one region")
+		}
+		return ioutil.WriteFile(o.out, b[rs[0].Offset:rs[0].Offset+rs[0].Size], 0644)
+
+	case "verify":
+		rs := []mtd.Region{{Name: "all", Size: size}}
+		if o.regions != "" {
+			l, err := parseLayout(image)
+			if err != nil {
+				return err
+			}
+			if rs, err = l.find(o.regions); err != nil {
+				return err
+			}
+		}
+		for _, r := range rs {
+			if err := mtd.VerifyRegion(f, image, r); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s: OK\n", r.Name)
+		}
+
+	case "write":
+		l, err := parseLayout(image)
+		if err != nil {
+			return err
+		}
+		rs, err := l.find(o.regions)
+		if err != nil {
+			return err
+		}
+		if !o.force {
+			b, err := readAll(f, size)
+			if err != nil {
+				return err
+			}
+			cur, err := parseLayout(b)
+			if err != nil {
+				return fmt.Errorf("flash layout: %v; use -force to write anyway", err)
+			}
+			for _, r := range rs {
+				if c, err := mtd.FindRegion(cur.regions(), r.Name); err != nil || c != r {
+					return fmt.Errorf("region %v is not where it is on the flash; use -force to write anyway", r)
+				}
+			}
+		}
+		if err := mtd.WriteRegions(f, image, rs...); err != nil {
+			return err
+		}
+		for _, r := range rs {
+			fmt.Fprintf(w, "%s: written and verified\n", r.Name)
+		}
+	}
+	return nil
+}
+
+func run(args []string, w io.Writer, open func(string) (mtd.Flasher, int64, error)) error {
+	if len(args) < 1 {
+		return errors.New(usage)
+	}
+	cmd := args[0]
+	o := &options{}
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&o.json, "json", false, "Print JSON")
+	fs.StringVar(&o.regions, "r", "", "Comma separated regions")
+	fs.StringVar(&o.out, "o", "", "Output file")
+	fs.BoolVar(&o.lock, "lock", false, "Lock the descriptor")
+	fs.BoolVar(&o.unlock, "unlock", false, "Unlock the descriptor")
+	fs.BoolVar(&o.force, "force", false, "Write even if the flash layout differs")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%s: %v\n%s", cmd, err, usage)
+	}
+	return command(w, cmd, fs.Args(), o, open)
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, openFlash); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/ifd"
+	"github.com/u-root/u-root/pkg/mount/mtd"
+)
+
+const testImage = "../../../pkg/ifd/testdata/v2.bin"
+
+// memFlash is a flash chip in memory.
+type memFlash struct {
+	data   []byte
+	writes int
+}
+
+func (m *memFlash) ReadAt(b []byte, off int64) (int, error) {
+	return copy(b, m.data[off:]), nil
+}
+
+func (m *memFlash) QueueWrite(b []byte, off int64) (int, error) {
+	m.writes++
+	return copy(m.data[off:], b), nil
+}
+
+func (m *memFlash) SyncWrite() error {
+	return nil
+}
+
+func (m *memFlash) Close() error {
+	return nil
+}
+
+type testEnv struct {
+	dir   string
+	flash *memFlash
+}
+
+func (e *testEnv) open(path string) (mtd.Flasher, int64, error) {
+	if path != "/dev/mtd0" {
+		return nil, 0, fmt.Errorf("%s: no such flash", path)
+	}
+	return e.flash, int64(len(e.flash.data)), nil
+}
+
+func (e *testEnv) run(args ...string) (string, error) {
+	var b bytes.Buffer
+	err := run(args, &b, e.open)
+	return b.String(), err
+}
+
+func (e *testEnv) path(n string) string {
+	return filepath.Join(e.dir, n)
+}
+
+func setup(t *testing.T) (*testEnv, []byte, func()) {
+	image, err := ioutil.ReadFile(testImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "fwregion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &testEnv{dir: dir, flash: &memFlash{data: append([]byte(nil), image...)}}
+	return e, image, func() { os.RemoveAll(dir) }
+}
+
+func TestLayout(t *testing.T) {
+	e, _, done := setup(t)
+	defer done()
+
+	out, err := e.run("layout", "/dev/mtd0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Intel flash descriptor version 2\n",
+		"  1  BIOS         00008000-0000ffff\n",
+		"  4  Platform     unused\n",
+		"  1 BIOS      read: Descriptor BIOS GbE\n",
+		"              write: BIOS GbE\n",
+		"PCH strap 3: 0xdeadbeef\n",
+		"Processor strap 0: 0x00000042\n",
+		`FMAP "FLASH" at 0x8000, version 1.1, base 0xffff0000, size 0x10000` + "\n",
+		"  COREBOOT                 00008200-0000ffff\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("layout: %q not in\n%s", want, out)
+		}
+	}
+
+	out, err = e.run("layout", "-json", "/dev/mtd0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l layout
+	if err := json.Unmarshal([]byte(out), &l); err != nil {
+		t.Fatal(err)
+	}
+	if l.Descriptor.Version != 2 || len(l.FMAP.Areas) != 7 || l.FMAPOffset != 0x8000 {
+		t.Errorf("layout -json: got %+v", l)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	e, image, done := setup(t)
+	defer done()
+
+	if _, err := e.run("read", "-r", "SI_ME", "-o", e.path("me.bin"), "/dev/mtd0"); err != nil {
+		t.Fatal(err)
+	}
+	me, err := ioutil.ReadFile(e.path("me.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(me, bytes.Repeat([]byte{0x22}, 0x5000)) {
+		t.Errorf("read SI_ME: got %d bytes, want 0x5000 of 0x22", len(me))
+	}
+
+	// A new image with new BIOS and ME regions. Only the BIOS region
+	// should be written.
+	update := append([]byte(nil), image...)
+	copy(update[0x3000:0x8000], bytes.Repeat([]byte{0x44}, 0x5000))
+	copy(update[0x8200:], bytes.Repeat([]byte{0x55}, 0x7e00))
+	if err := ioutil.WriteFile(e.path("new.rom"), update, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := e.run("write", "-r", "SI_BIOS", e.path("new.rom"), "/dev/mtd0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "SI_BIOS: written and verified\n" {
+		t.Errorf("write: got %q", out)
+	}
+	if !bytes.Equal(e.flash.data[:0x8000], image[:0x8000]) || !bytes.Equal(e.flash.data[0x8000:], update[0x8000:]) {
+		t.Errorf("write SI_BIOS did not write just the BIOS region")
+	}
+
+	if out, err := e.run("verify", "-r", "SI_BIOS,COREBOOT", e.path("new.rom"), "/dev/mtd0"); err != nil || out != "SI_BIOS: OK\nCOREBOOT: OK\n" {
+		t.Errorf("verify -r SI_BIOS,COREBOOT: got %q, %v", out, err)
+	}
+	if _, err := e.run("verify", e.path("new.rom"), "/dev/mtd0"); err == nil || !strings.Contains(err.Error(), "differs at 0x3000") {
+		t.Errorf("verify: got %v, want difference at 0x3000", err)
+	}
+
+	// Writing again changes nothing.
+	writes := e.flash.writes
+	if _, err := e.run("write", "-r", "SI_BIOS", e.path("new.rom"), "/dev/mtd0"); err != nil || e.flash.writes != writes {
+		t.Errorf("write unchanged: got %v after %d more writes", err, e.flash.writes-writes)
+	}
+
+	// An image with a moved ME region is refused without -force.
+	d, err := ifd.Parse(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Regions[ifd.RegionME], _ = ifd.NewRegion(0x4000, 0x4000)
+	if err := d.Write(update); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(e.path("moved.rom"), update, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.run("write", "-r", "SI_ME", e.path("moved.rom"), "/dev/mtd0"); err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("write moved region: got %v, want error mentioning -force", err)
+	}
+	if _, err := e.run("write", "-force", "-r", "SI_ME", e.path("moved.rom"), "/dev/mtd0"); err != nil {
+		t.Errorf("write -force moved region: %v", err)
+	}
+}
+
+func TestIFD(t *testing.T) {
+	e, image, done := setup(t)
+	defer done()
+	if err := ioutil.WriteFile(e.path("a.rom"), image, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.run("ifd", "-unlock", "-o", e.path("b.rom"), e.path("a.rom")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(e.path("b.rom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ifd.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := d.Masters[ifd.MasterBIOS-1]; m.Read != 0xffff || m.Write != 0xffff {
+		t.Errorf("unlocked BIOS master: got %+v", m)
+	}
+	if !bytes.Equal(b[ifd.Size:], image[ifd.Size:]) {
+		t.Errorf("ifd -unlock changed more than the descriptor")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	e, _, done := setup(t)
+	defer done()
+	for _, tt := range []struct {
+		args []string
+		err  string
+	}{
+		{nil, "usage"},
+		{[]string{"frob", "/dev/mtd0"}, "usage"},
+		{[]string{"verify", "/dev/mtd0"}, "usage"},
+		{[]string{"read", "/dev/mtd0"}, "-o"},
+		{[]string{"read", "-o", "x", "/dev/mtd0"}, "-r"},
+		{[]string{"read", "-o", "x", "-r", "SI_EC", "/dev/mtd0"}, `no region "SI_EC"`},
+		{[]string{"layout", "/dev/mtd1"}, "no such flash"},
+		{[]string{"ifd", testImage}, "-lock"},
+		{[]string{"write", "-r", "RW_SECTION_A", "../../../pkg/fmap/testdata/image.bin", "/dev/mtd0"}, "-force"},
+	} {
+		_, err := e.run(tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("fwregion %v: got %v, want error containing %q", tt.args, err, tt.err)
+		}
+	}
+}
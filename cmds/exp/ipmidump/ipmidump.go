@@ -10,9 +10,17 @@
 // Options:
 //     -chassis : Print chassis power status.
 //     -sel     : Print SEL information.
+//     -sellist : Print SEL entries.
+//     -selclear: Clear SEL.
+//     -sdr     : Print sensor readings.
+//     -fru     : Print FRU inventory.
 //     -lan     : Print IP information.
 //     -device  : Print device information.
 //     -raw     : Send raw command and print response.
+//     -host    : Talk to the BMC at this address over LAN, with RMCP+.
+//     -user    : User name for -host.
+//     -password: Password for -host.
+//     -cipher  : Cipher suite for -host.
 //     -help    : Print help message.
 package main
 
@@ -32,12 +40,28 @@ const cmd = "ipmidump [options] "
 var (
 	flagChassis = flag.Bool("chassis", false, "print chassis power status")
 	flagSEL     = flag.Bool("sel", false, "print SEL information")
+	flagSELList = flag.Bool("sellist", false, "print SEL entries")
+	flagSELClr  = flag.Bool("selclear", false, "clear SEL")
+	flagSDR     = flag.Bool("sdr", false, "print sensor readings")
+	flagFRU     = flag.Bool("fru", false, "print FRU inventory")
 	flagLan     = flag.Bool("lan", false, "Print IP address")
 	flagRaw     = flag.Bool("raw", false, "Send IPMI raw command")
 	flagHelp    = flag.Bool("help", false, "print help message")
 	flagDev     = flag.Bool("device", false, "print device information")
+	flagHost    = flag.String("host", "", "talk to the BMC at this address over LAN")
+	flagUser    = flag.String("user", "", "user name for -host")
+	flagPass    = flag.String("password", "", "password for -host")
+	flagCipher  = flag.Uint("cipher", 3, "cipher suite for -host")
 )
 
+// open opens the local BMC, or the remote one if -host is set.
+func open() (*ipmi.IPMI, error) {
+	if *flagHost != "" {
+		return ipmi.OpenLAN(*flagHost, *flagUser, *flagPass, ipmi.WithCipherSuite(uint8(*flagCipher)))
+	}
+	return ipmi.Open(0)
+}
+
 func itob(i int) bool { return i != 0 }
 
 func init() {
@@ -64,6 +88,22 @@ func main() {
 		selInfo()
 	}
 
+	if *flagSELList {
+		selList()
+	}
+
+	if *flagSELClr {
+		selClear()
+	}
+
+	if *flagSDR {
+		sdrList()
+	}
+
+	if *flagFRU {
+		fruPrint()
+	}
+
 	if *flagLan {
 		lanConfig()
 	}
@@ -98,7 +138,7 @@ func chassisInfo() {
 		0x00: "none",
 	}
 
-	ipmi, err := ipmi.Open(0)
+	ipmi, err := open()
 	if err != nil {
 		fmt.Printf("Failed to open ipmi device: %v\n", err)
 	}
@@ -156,7 +196,7 @@ func chassisInfo() {
 func selInfo() {
 	support := map[bool]string{true: "supported", false: "unsupported"}
 
-	ipmi, err := ipmi.Open(0)
+	ipmi, err := open()
 	if err != nil {
 		fmt.Printf("Failed to open ipmi device: %v\n", err)
 	}
@@ -215,6 +255,95 @@ func selInfo() {
 	}
 }
 
+func selList() {
+	i, err := open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer i.Close()
+
+	events, err := ipmi.GetSELEntries(i)
+	if err != nil {
+		fmt.Printf("Failed to get SEL entries: %v\n", err)
+		return
+	}
+	if len(events) == 0 {
+		fmt.Println("SEL has no entries")
+	}
+	for _, e := range events {
+		fmt.Println(e)
+	}
+}
+
+func selClear() {
+	i, err := open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer i.Close()
+
+	if err := ipmi.ClearSEL(i); err != nil {
+		fmt.Printf("Failed to clear SEL: %v\n", err)
+		return
+	}
+	fmt.Println("Cleared SEL")
+}
+
+func sdrList() {
+	i, err := open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer i.Close()
+
+	sdrs, err := ipmi.GetSDRs(i)
+	if err != nil {
+		fmt.Printf("Failed to get SDRs: %v\n", err)
+		return
+	}
+	for _, r := range sdrs {
+		s, err := r.Sensor()
+		if err != nil {
+			// Not a sensor.
+			continue
+		}
+		value, status := "no reading", "ns"
+		if rd, err := ipmi.GetSensorReading(i, s.Number); err != nil {
+			fmt.Printf("Failed to read sensor %q: %v\n", s.Name, err)
+		} else if !rd.Unavailable && !rd.ScanningDisabled {
+			switch {
+			case s.IsThreshold() && s.Analog():
+				v, err := s.Convert(rd.Raw)
+				if err != nil {
+					break
+				}
+				value = fmt.Sprintf("%.2f %s", v, s.UnitString())
+				status = rd.ThresholdStatus()
+			default:
+				value = fmt.Sprintf("0x%04x", rd.State)
+				status = "ok"
+			}
+		}
+		fmt.Printf("%-16s | %-17s | %s\n", s.Name, value, status)
+	}
+}
+
+func fruPrint() {
+	i, err := open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer i.Close()
+
+	f, err := ipmi.GetFRU(i, 0)
+	if err != nil {
+		fmt.Printf("Failed to get FRU: %v\n", err)
+		return
+	}
+	fmt.Println("FRU Device Description : Builtin FRU Device (ID 0)")
+	fmt.Print(f)
+}
+
 func lanConfig() {
 	const (
 		setInProgress byte = iota
@@ -233,7 +362,7 @@ func lanConfig() {
 		"Unspecified", "Static Address", "DHCP Address", "BIOS Assigned Address",
 	}
 
-	ipmi, err := ipmi.Open(0)
+	ipmi, err := open()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -311,7 +440,7 @@ func deviceID() {
 		"Chassis Device",        /* bit 7 */
 	}
 
-	ipmi, err := ipmi.Open(0)
+	ipmi, err := open()
 	if err != nil {
 		fmt.Printf("Failed to open ipmi device: %v\n", err)
 	}
@@ -363,7 +492,7 @@ func deviceID() {
 }
 
 func sendRawCmd(cmds []string) {
-	ipmi, err := ipmi.Open(0)
+	ipmi, err := open()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,172 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Mkfs creates FAT and ext4 file systems.
+//
+// Synopsis:
+//     mkfs [OPTIONS] DEVICE
+//
+// Description:
+//     mkfs creates an empty file system on DEVICE, which is a block device
+//     or an image file. With -size, the image file is created or resized
+//     first. vfat file systems are FAT16 or FAT32, and are suitable for
+//     EFI System Partitions; ext4 file systems use extents and, with -j,
+//     have a journal.
+//
+// Options:
+//     -t vfat|ext4:    file system type (default vfat)
+//     -L LABEL:        volume label
+//     -size SIZE:      create an image file of SIZE, with a K, M, G or T suffix
+//     -F 16|32:        FAT type (default FAT32 for 512 MiB and more)
+//     -s N:            sectors per FAT cluster
+//     -i SERIAL:       FAT volume serial number in hex (default random)
+//     -b BYTES:        ext4 block size (default 4096)
+//     -I BYTES:        ext4 bytes per inode (default 16384)
+//     -j:              create an ext4 journal
+//     -J BLOCKS:       ext4 journal size in blocks
+//     -U UUID:         ext4 UUID (default random)
+//
+// Example:
+//     mkfs -t vfat -F 32 -L ESP /dev/sda1
+//     mkfs -t ext4 -j -L root -size 1G root.img
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mkfs"
+)
+
+type options struct {
+	typ   string
+	label string
+	size  string
+
+	fatType int
+	spc     int
+	serial  string
+
+	blockSize     int
+	inodeRatio    int
+	journal       bool
+	journalBlocks int
+	uuid          string
+}
+
+var o options
+
+func init() {
+	flag.StringVar(&o.typ, "t", "vfat", "file system `type`, vfat or ext4")
+	flag.StringVar(&o.label, "L", "", "volume `label`")
+	flag.StringVar(&o.size, "size", "", "create an image file of `SIZE`")
+	flag.IntVar(&o.fatType, "F", 0, "FAT `type`, 16 or 32")
+	flag.IntVar(&o.spc, "s", 0, "`sectors` per FAT cluster")
+	flag.StringVar(&o.serial, "i", "", "FAT volume `serial` number in hex")
+	flag.IntVar(&o.blockSize, "b", 0, "ext4 block size in `bytes`")
+	flag.IntVar(&o.inodeRatio, "I", 0, "ext4 `bytes` per inode")
+	flag.BoolVar(&o.journal, "j", false, "create an ext4 journal")
+	flag.IntVar(&o.journalBlocks, "J", 0, "ext4 journal size in `blocks`")
+	flag.StringVar(&o.uuid, "U", "", "ext4 `UUID`")
+}
+
+// parseSize parses a size in bytes with an optional K, M, G or T suffix.
+func parseSize(s string) (int64, error) {
+	num, shift := s, uint(0)
+	if i := strings.IndexAny(s, "KMGT"); i >= 0 && i == len(s)-1 {
+		num, shift = s[:i], 10*uint(strings.IndexByte("KMGT", s[i])+1)
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 || n > (1<<62)>>shift {
+		return 0, fmt.Errorf("%q is not a size", s)
+	}
+	return n << shift, nil
+}
+
+// parseUUID parses a UUID such as 2183ead8-a510-4b3d-9777-19c7090f66d9.
+func parseUUID(s string) ([16]byte, error) {
+	var u [16]byte
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(b) != len(u) {
+		return u, fmt.Errorf("%q is not a UUID", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+func run(path string, o options) error {
+	flags := os.O_RDWR
+	if o.size != "" {
+		flags |= os.O_CREATE
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if o.size != "" {
+		size, err := parseSize(o.size)
+		if err != nil {
+			return err
+		}
+		if err := f.Truncate(size); err != nil {
+			return err
+		}
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	switch o.typ {
+	case "vfat", "fat", "msdos":
+		fo := mkfs.FATOptions{Type: o.fatType, Label: o.label, SectorsPerCluster: o.spc}
+		if o.serial != "" {
+			n, err := strconv.ParseUint(strings.Replace(o.serial, "-", "", -1), 16, 32)
+			if err != nil {
+				return fmt.Errorf("%q is not a volume serial number", o.serial)
+			}
+			fo.Serial = uint32(n)
+		}
+		err = mkfs.FAT(f, size, fo)
+	case "ext4":
+		eo := mkfs.Ext4Options{
+			Label:         o.label,
+			BlockSize:     o.blockSize,
+			InodeRatio:    o.inodeRatio,
+			Journal:       o.journal || o.journalBlocks != 0,
+			JournalBlocks: o.journalBlocks,
+		}
+		if o.uuid != "" {
+			if eo.UUID, err = parseUUID(o.uuid); err != nil {
+				return err
+			}
+		}
+		err = mkfs.Ext4(f, size, eo)
+	default:
+		return fmt.Errorf("unsupported file system type %q", o.typ)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), o); err != nil {
+		log.Fatal(err)
+	}
+}
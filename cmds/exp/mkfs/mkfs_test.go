@@ -0,0 +1,98 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/probe"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		name string
+		o    options
+		want probe.Result
+	}{
+		{
+			name: "esp",
+			o:    options{typ: "vfat", size: "64M", fatType: 32, label: "esp", serial: "ACE5-5144"},
+			want: probe.Result{Type: "vfat", Version: "FAT32", Label: "ESP", UUID: "ace5-5144"},
+		},
+		{
+			name: "ext4",
+			o:    options{typ: "ext4", size: "32M", journal: true, label: "root", uuid: "2183ead8-a510-4b3d-9777-19c7090f66d9"},
+			want: probe.Result{Type: "ext4", Label: "root", UUID: "2183ead8-a510-4b3d-9777-19c7090f66d9"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := run(path, tt.o); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			fi, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := probe.Probe(f, fi.Size())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type != tt.want.Type || got.Label != tt.want.Label || got.UUID != tt.want.UUID ||
+				(tt.want.Version != "" && got.Version != tt.want.Version) {
+				t.Errorf("probe.Probe = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, o := range []options{
+		{typ: "btrfs", size: "1M"},
+		{typ: "vfat", size: "1X"},
+		{typ: "vfat", size: "16M", serial: "xyz"},
+		{typ: "ext4", size: "16M", uuid: "1234"},
+	} {
+		if err := run(filepath.Join(dir, "img"), o); err == nil {
+			t.Errorf("run(%+v) succeeded", o)
+		}
+	}
+	if err := run(filepath.Join(dir, "missing"), options{typ: "vfat"}); err == nil {
+		t.Errorf("formatting a missing file succeeded")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{"512": 512, "4K": 4096, "1M": 1 << 20, "3G": 3 << 30, "2T": 2 << 40} {
+		if got, err := parseSize(s); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "0", "-1", "M", "1KM", "1m"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("parseSize(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// nvme issues admin commands to NVMe devices.
+//
+// Synopsis:
+//     nvme COMMAND [OPTIONS] DEVICE
+//
+// Description:
+//     DEVICE is a controller, e.g. /dev/nvme0, or a namespace, e.g.
+//     /dev/nvme0n1. The commands are:
+//
+//     id-ctrl:       print the controller's identify data
+//     id-ns:         print a namespace's identify data
+//     list-ns:       list the active namespaces
+//     smart-log:     print the SMART/health log
+//     fw-log:        print the firmware slot log
+//     fw-download:   send a firmware image to the controller
+//     fw-commit:     write the downloaded firmware to a slot and activate it
+//     format:        format a namespace, destroying its data
+//     sanitize:      sanitize the whole device, destroying all data
+//     sanitize-log:  print the sanitize status log
+//     opal-discover: print the TCG level 0 discovery, showing Opal support
+//                    and the locking state
+//
+// Options:
+//     -json:         print JSON (id-ctrl, id-ns, smart-log, fw-log,
+//                    sanitize-log, opal-discover)
+//     -n NSID:       the namespace (default the device's, or 1; all for
+//                    smart-log)
+//     -f FILE:       the firmware image for fw-download
+//     -xfer BYTES:   the fw-download chunk size (default what the
+//                    controller asks for, or 4096)
+//     -s SLOT:       the fw-commit slot (default 0, the controller's choice)
+//     -a ACTION:     the fw-commit action, 0 to 3 (default 1, replace and
+//                    activate at reset); or the sanitize action: block,
+//                    crypto, overwrite or exit-failure
+//     -l LBAF:       the format's LBA format (default the current one)
+//     -ses N:        the format's secure erase: 0 none, 1 user data, 2
+//                    crypto
+//     -passes N:     the sanitize overwrite passes (default 1)
+//     -pattern N:    the sanitize overwrite pattern
+//     -force:        do a format or sanitize
+//
+// Example:
+//     nvme smart-log /dev/nvme0
+//     nvme fw-download -f fw.bin /dev/nvme0 && nvme fw-commit -s 2 /dev/nvme0
+//     nvme format -ses 2 -force /dev/nvme0n1
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mount/nvme"
+)
+
+const usage = "usage: nvme COMMAND [OPTIONS] DEVICE\n" +
+	"commands: id-ctrl id-ns list-ns smart-log fw-log fw-download fw-commit format sanitize sanitize-log opal-discover"
+
+type options struct {
+	json    bool
+	nsid    string
+	file    string
+	xfer    int
+	slot    int
+	action  string
+	lbaf    int
+	ses     int
+	passes  int
+	pattern uint
+	force   bool
+}
+
+var nsName = regexp.MustCompile(`^nvme\d+n(\d+)$`)
+
+// namespace returns the namespace -n selects, or the device's.
+func (o *options) namespace(dev string, all bool) (uint32, error) {
+	switch {
+	case o.nsid == "all":
+		return nvme.AllNamespaces, nil
+	case o.nsid != "":
+		n, err := strconv.ParseUint(o.nsid, 0, 32)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("%q is not a namespace ID", o.nsid)
+		}
+		return uint32(n), nil
+	}
+	if m := nsName.FindStringSubmatch(filepath.Base(dev)); m != nil {
+		n, err := strconv.ParseUint(m[1], 10, 32)
+		return uint32(n), err
+	}
+	if all {
+		return nvme.AllNamespaces, nil
+	}
+	return 1, nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+func version(v uint32) string {
+	return fmt.Sprintf("%d.%d.%d", v>>16, v>>8&0xff, v&0xff)
+}
+
+func yes(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func printController(w io.Writer, c *nvme.Controller) {
+	fmt.Fprintf(w, "vid       : %#04x\n", c.VendorID)
+	fmt.Fprintf(w, "ssvid     : %#04x\n", c.SubsystemVendorID)
+	fmt.Fprintf(w, "sn        : %s\n", c.Serial)
+	fmt.Fprintf(w, "mn        : %s\n", c.Model)
+	fmt.Fprintf(w, "fr        : %s\n", c.Firmware)
+	fmt.Fprintf(w, "ieee      : %06x\n", c.IEEE)
+	fmt.Fprintf(w, "mdts      : %d\n", c.MDTS)
+	fmt.Fprintf(w, "cntlid    : %#x\n", c.ControllerID)
+	fmt.Fprintf(w, "ver       : %s\n", version(c.Version))
+	fmt.Fprintf(w, "oacs      : %#x\n", c.OACS)
+	fmt.Fprintf(w, "frmw      : %d slots, slot 1 read-only: %s, activation without reset: %s\n",
+		c.FirmwareSlots, yes(c.FirmwareSlot1ReadOnly), yes(c.FirmwareActivateWithoutReset))
+	fmt.Fprintf(w, "tnvmcap   : %d\n", c.TotalCapacity)
+	fmt.Fprintf(w, "unvmcap   : %d\n", c.UnallocatedCapacity)
+	fmt.Fprintf(w, "sanicap   : %#x\n", c.SANICAP)
+	fmt.Fprintf(w, "nn        : %d\n", c.Namespaces)
+	fmt.Fprintf(w, "fna       : %#x\n", c.FNA)
+	fmt.Fprintf(w, "subnqn    : %s\n", c.SubsystemNQN)
+}
+
+func printNamespace(w io.Writer, n *nvme.Namespace) {
+	fmt.Fprintf(w, "nsid      : %d\n", n.ID)
+	fmt.Fprintf(w, "nsze      : %d\n", n.Size)
+	fmt.Fprintf(w, "ncap      : %d\n", n.Capacity)
+	fmt.Fprintf(w, "nuse      : %d\n", n.Utilization)
+	fmt.Fprintf(w, "nguid     : %x\n", n.NGUID)
+	fmt.Fprintf(w, "eui64     : %x\n", n.EUI64)
+	for i, f := range n.Formats {
+		inUse := ""
+		if i == n.Format {
+			inUse = " (in use)"
+		}
+		fmt.Fprintf(w, "lbaf %2d   : ms:%-3d lbads:%-4d rp:%d%s\n", i, f.MetadataSize, f.DataSize, f.Performance, inUse)
+	}
+}
+
+func printSMART(w io.Writer, s *nvme.SMARTLog) {
+	fmt.Fprintf(w, "critical_warning          : %#x\n", s.CriticalWarning)
+	fmt.Fprintf(w, "temperature               : %d C\n", int(s.Temperature)-273)
+	fmt.Fprintf(w, "available_spare           : %d%%\n", s.AvailableSpare)
+	fmt.Fprintf(w, "available_spare_threshold : %d%%\n", s.AvailableSpareThreshold)
+	fmt.Fprintf(w, "percentage_used           : %d%%\n", s.PercentageUsed)
+	fmt.Fprintf(w, "data_units_read           : %d\n", s.DataUnitsRead)
+	fmt.Fprintf(w, "data_units_written        : %d\n", s.DataUnitsWritten)
+	fmt.Fprintf(w, "host_read_commands        : %d\n", s.HostReads)
+	fmt.Fprintf(w, "host_write_commands       : %d\n", s.HostWrites)
+	fmt.Fprintf(w, "controller_busy_time      : %d\n", s.ControllerBusyTime)
+	fmt.Fprintf(w, "power_cycles              : %d\n", s.PowerCycles)
+	fmt.Fprintf(w, "power_on_hours            : %d\n", s.PowerOnHours)
+	fmt.Fprintf(w, "unsafe_shutdowns          : %d\n", s.UnsafeShutdowns)
+	fmt.Fprintf(w, "media_errors              : %d\n", s.MediaErrors)
+	fmt.Fprintf(w, "num_err_log_entries       : %d\n", s.ErrorLogEntries)
+	fmt.Fprintf(w, "warning_temp_time         : %d\n", s.WarningTemperatureTime)
+	fmt.Fprintf(w, "critical_comp_time        : %d\n", s.CriticalTemperatureTime)
+	for i, t := range s.Sensors {
+		fmt.Fprintf(w, "temperature_sensor_%d      : %d C\n", i+1, int(t)-273)
+	}
+}
+
+func printFirmwareLog(w io.Writer, f *nvme.FirmwareSlotLog) {
+	fmt.Fprintf(w, "active slot : %d\n", f.Active)
+	if f.Next != 0 {
+		fmt.Fprintf(w, "next slot   : %d\n", f.Next)
+	}
+	for i, r := range f.Revisions {
+		if r != "" {
+			fmt.Fprintf(w, "frs%d        : %s\n", i+1, r)
+		}
+	}
+}
+
+var sanitizeStates = []string{"never sanitized", "completed", "in progress", "failed", "completed without deallocation"}
+
+func printSanitizeStatus(w io.Writer, s *nvme.SanitizeStatus) {
+	state := fmt.Sprintf("%d", s.State)
+	if s.State < len(sanitizeStates) {
+		state = sanitizeStates[s.State]
+	}
+	fmt.Fprintf(w, "state              : %s\n", state)
+	if s.State == nvme.SanitizeStateInProgress {
+		fmt.Fprintf(w, "progress           : %.1f%%\n", float64(s.Progress)*100/65536)
+	}
+	fmt.Fprintf(w, "overwrite passes   : %d\n", s.Passes)
+	fmt.Fprintf(w, "global data erased : %s\n", yes(s.GlobalDataErased))
+	for _, t := range []struct {
+		name string
+		secs uint32
+	}{
+		{"overwrite time     ", s.OverwriteTime},
+		{"block erase time   ", s.BlockEraseTime},
+		{"crypto erase time  ", s.CryptoEraseTime},
+	} {
+		if t.secs == 0xffffffff {
+			fmt.Fprintf(w, "%s: unknown\n", t.name)
+		} else {
+			fmt.Fprintf(w, "%s: %ds\n", t.name, t.secs)
+		}
+	}
+}
+
+func printDiscovery(w io.Writer, d *nvme.Discovery) {
+	if d.SSC == nil {
+		fmt.Fprintf(w, "ssc        : none\n")
+	} else {
+		fmt.Fprintf(w, "ssc        : %s, ComIDs %#x-%#x\n", d.SSC.Name, d.SSC.BaseComID, int(d.SSC.BaseComID)+int(d.SSC.ComIDs)-1)
+	}
+	if l := d.Locking; l != nil {
+		fmt.Fprintf(w, "locking    : supported %s, enabled %s, locked %s\n", yes(l.Supported), yes(l.Enabled), yes(l.Locked))
+		fmt.Fprintf(w, "encryption : %s\n", yes(l.MediaEncryption))
+		fmt.Fprintf(w, "mbr shadow : enabled %s, done %s\n", yes(l.MBREnabled), yes(l.MBRDone))
+	}
+	for _, f := range d.Features {
+		fmt.Fprintf(w, "feature    : %#04x v%d, %d bytes\n", f.Code, f.Version, len(f.Data))
+	}
+}
+
+var sanitizeActions = map[string]nvme.SanitizeAction{
+	"exit-failure": nvme.SanitizeExitFailure,
+	"block":        nvme.SanitizeBlockErase,
+	"overwrite":    nvme.SanitizeOverwrite,
+	"crypto":       nvme.SanitizeCryptoErase,
+}
+
+// command runs cmd on d.
+func command(w io.Writer, d *nvme.Device, cmd, dev string, o *options) error {
+	var v interface{}
+	var text func()
+	switch cmd {
+	case "id-ctrl":
+		c, err := d.IdentifyController()
+		if err != nil {
+			return err
+		}
+		v, text = c, func() { printController(w, c) }
+	case "id-ns":
+		nsid, err := o.namespace(dev, false)
+		if err != nil {
+			return err
+		}
+		n, err := d.IdentifyNamespace(nsid)
+		if err != nil {
+			return err
+		}
+		v, text = n, func() { printNamespace(w, n) }
+	case "list-ns":
+		ids, err := d.ActiveNamespaces()
+		if err != nil {
+			return err
+		}
+		v, text = ids, func() {
+			for _, id := range ids {
+				fmt.Fprintf(w, "%d\n", id)
+			}
+		}
+	case "smart-log":
+		nsid, err := o.namespace(dev, true)
+		if err != nil {
+			return err
+		}
+		s, err := d.SMARTLog(nsid)
+		if err != nil {
+			return err
+		}
+		v, text = s, func() { printSMART(w, s) }
+	case "fw-log":
+		f, err := d.FirmwareSlots()
+		if err != nil {
+			return err
+		}
+		v, text = f, func() { printFirmwareLog(w, f) }
+	case "sanitize-log":
+		s, err := d.SanitizeStatus()
+		if err != nil {
+			return err
+		}
+		v, text = s, func() { printSanitizeStatus(w, s) }
+	case "opal-discover":
+		disc, err := d.Discover()
+		if err != nil {
+			return err
+		}
+		v, text = disc, func() { printDiscovery(w, disc) }
+	case "fw-download":
+		return download(w, d, o)
+	case "fw-commit":
+		return commit(w, d, o)
+	case "format":
+		return format(w, d, dev, o)
+	case "sanitize":
+		return sanitize(w, d, o)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", cmd, usage)
+	}
+	if o.json {
+		return printJSON(w, v)
+	}
+	text()
+	return nil
+}
+
+func download(w io.Writer, d *nvme.Device, o *options) error {
+	if o.file == "" {
+		return fmt.Errorf("fw-download needs -f")
+	}
+	image, err := ioutil.ReadFile(o.file)
+	if err != nil {
+		return err
+	}
+	chunk := o.xfer
+	if chunk == 0 {
+		c, err := d.IdentifyController()
+		if err != nil {
+			return err
+		}
+		chunk = c.FirmwareChunk()
+	}
+	if err := d.DownloadFirmware(image, chunk); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Downloaded %d bytes of firmware\n", len(image))
+	return nil
+}
+
+func commit(w io.Writer, d *nvme.Device, o *options) error {
+	a := o.action
+	if a == "" {
+		a = "1"
+	}
+	action, err := strconv.ParseUint(a, 0, 8)
+	if err != nil {
+		return fmt.Errorf("%q is not a firmware commit action", o.action)
+	}
+	err = d.CommitFirmware(o.slot, nvme.CommitAction(action))
+	if nvme.NeedsReset(err) {
+		fmt.Fprintf(w, "Firmware committed; it is activated by a reset: %v\n", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Firmware committed to slot %d with action %d\n", o.slot, action)
+	return nil
+}
+
+func format(w io.Writer, d *nvme.Device, dev string, o *options) error {
+	nsid, err := o.namespace(dev, false)
+	if err != nil {
+		return err
+	}
+	lbaf := o.lbaf
+	if lbaf < 0 && nsid != nvme.AllNamespaces {
+		n, err := d.IdentifyNamespace(nsid)
+		if err != nil {
+			return err
+		}
+		lbaf = n.Format
+	}
+	if lbaf < 0 {
+		return fmt.Errorf("formatting all namespaces needs -l")
+	}
+	if !o.force {
+		return fmt.Errorf("format destroys all data on namespace %d; use -force", nsid)
+	}
+	if err := d.Format(&nvme.Format{NSID: nsid, LBAFormat: lbaf, SecureErase: nvme.SecureErase(o.ses)}); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Formatted namespace %d with LBA format %d\n", nsid, lbaf)
+	return nil
+}
+
+func sanitize(w io.Writer, d *nvme.Device, o *options) error {
+	action, ok := sanitizeActions[o.action]
+	if !ok {
+		return fmt.Errorf("sanitize needs -a block, crypto, overwrite or exit-failure")
+	}
+	if !o.force && action != nvme.SanitizeExitFailure {
+		return fmt.Errorf("sanitize destroys all data on the device; use -force")
+	}
+	s := &nvme.Sanitize{Action: action, OverwritePasses: o.passes, Pattern: uint32(o.pattern)}
+	if err := d.Sanitize(s); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Sanitize started; see sanitize-log for its progress\n")
+	return nil
+}
+
+func run(args []string, w io.Writer, open func(string) (*nvme.Device, error)) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%s", usage)
+	}
+	cmd := args[0]
+	o := &options{}
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&o.json, "json", false, "Print JSON")
+	fs.StringVar(&o.nsid, "n", "", "The namespace ID, or all")
+	fs.StringVar(&o.file, "f", "", "The firmware image")
+	fs.IntVar(&o.xfer, "xfer", 0, "The firmware download chunk size")
+	fs.IntVar(&o.slot, "s", 0, "The firmware slot")
+	fs.StringVar(&o.action, "a", "", "The firmware commit or sanitize action")
+	fs.IntVar(&o.lbaf, "l", -1, "The LBA format")
+	fs.IntVar(&o.ses, "ses", 0, "The format's secure erase setting")
+	fs.IntVar(&o.passes, "passes", 1, "The sanitize overwrite passes")
+	fs.UintVar(&o.pattern, "pattern", 0, "The sanitize overwrite pattern")
+	fs.BoolVar(&o.force, "force", false, "Do a format or sanitize")
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%s: %v\n%s", cmd, err, usage)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%s", usage)
+	}
+	dev := fs.Arg(0)
+	d, err := open(dev)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := command(w, d, cmd, dev, o); err != nil {
+		return fmt.Errorf("%s: %v", strings.TrimPrefix(dev, "/dev/"), err)
+	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, nvme.Open); err != nil {
+		log.Fatal(err)
+	}
+}
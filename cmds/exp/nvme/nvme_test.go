@@ -0,0 +1,187 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/nvme"
+	"github.com/u-root/u-root/pkg/mount/nvme/nvmetest"
+)
+
+// fakeAdmin answers commands with the pages of nvmetest and records
+// the others.
+type fakeAdmin struct {
+	t    *testing.T
+	cmds []nvme.Command
+}
+
+func (f *fakeAdmin) AdminCommand(c *nvme.Command) (uint32, error) {
+	f.cmds = append(f.cmds, *c)
+	var name string
+	switch c.Opcode {
+	case 0x06:
+		name = map[uint32]string{0: "id-ns", 1: "id-ctrl"}[c.CDW10]
+	case 0x02:
+		name = map[uint32]string{nvme.LogSMART: "smart-log", nvme.LogFirmwareSlot: "fw-log", nvme.LogSanitizeStatus: "sanitize-log"}[c.CDW10&0xff]
+	case 0x82:
+		name = "opal-discovery"
+	default:
+		return 0, nil
+	}
+	page, ok := nvmetest.Pages()[name]
+	if !ok {
+		f.t.Fatalf("no page for command %+v", c)
+	}
+	copy(c.Data, page)
+	return 0, nil
+}
+
+func runFake(t *testing.T, args ...string) (string, *fakeAdmin, error) {
+	f := &fakeAdmin{t: t}
+	var out bytes.Buffer
+	err := run(args, &out, func(string) (*nvme.Device, error) {
+		return nvme.New(f), nil
+	})
+	return out.String(), f, err
+}
+
+func TestInfo(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"id-ctrl", "/dev/nvme0"}, []string{"sn        : S4EWNX0N123456\n", "ver       : 1.3.0\n", "frmw      : 3 slots, slot 1 read-only: no, activation without reset: yes\n"}},
+		{[]string{"id-ns", "/dev/nvme0n1"}, []string{"nsze      : 1953525168\n", "lbaf  0   : ms:0   lbads:512  rp:2 (in use)\n", "lbaf  1   : ms:0   lbads:4096 rp:0\n"}},
+		{[]string{"smart-log", "/dev/nvme0"}, []string{"temperature               : 37 C\n", "power_on_hours            : 9876\n", "temperature_sensor_2      : 45 C\n"}},
+		{[]string{"fw-log", "/dev/nvme0"}, []string{"active slot : 1\nnext slot   : 2\nfrs1        : 2B2QEXM7\nfrs2        : 3B2QEXM7\n"}},
+		{[]string{"sanitize-log", "/dev/nvme0"}, []string{"state              : in progress\nprogress           : 50.0%\n", "overwrite time     : unknown\n"}},
+		{[]string{"opal-discover", "/dev/nvme0"}, []string{"ssc        : Opal 2, ComIDs 0x1001-0x1001\n", "locking    : supported yes, enabled yes, locked no\n"}},
+	} {
+		out, _, err := runFake(t, tt.args...)
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(out, w) {
+				t.Errorf("%v output %q does not contain %q", tt.args, out, w)
+			}
+		}
+	}
+
+	out, _, err := runFake(t, "id-ctrl", "-json", "/dev/nvme0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c nvme.Controller
+	if err := json.Unmarshal([]byte(out), &c); err != nil || c.Model != "Example NVMe SSD 1TB" {
+		t.Errorf("JSON output %q decodes to %+v, %v", out, c, err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	for _, tt := range []struct {
+		dev  string
+		nsid string
+		all  bool
+		want uint32
+	}{
+		{"/dev/nvme0n3", "", false, 3},
+		{"/dev/nvme0", "", false, 1},
+		{"/dev/nvme0", "", true, nvme.AllNamespaces},
+		{"/dev/nvme0n1", "all", false, nvme.AllNamespaces},
+		{"/dev/nvme0", "0x2", false, 2},
+	} {
+		o := &options{nsid: tt.nsid}
+		if got, err := o.namespace(tt.dev, tt.all); err != nil || got != tt.want {
+			t.Errorf("namespace(%s, -n %q) = %d, %v, want %d", tt.dev, tt.nsid, got, err, tt.want)
+		}
+	}
+	if _, err := (&options{nsid: "0"}).namespace("/dev/nvme0", false); err == nil {
+		t.Errorf("namespace 0 was accepted")
+	}
+}
+
+func TestDestructive(t *testing.T) {
+	if _, _, err := runFake(t, "format", "/dev/nvme0n1"); err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("format without -force = %v", err)
+	}
+	_, f, err := runFake(t, "format", "-force", "-ses", "2", "/dev/nvme0n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The current LBA format is kept.
+	if c := f.cmds[len(f.cmds)-1]; c.Opcode != 0x80 || c.NSID != 1 || c.CDW10 != 2<<9 {
+		t.Errorf("format command is %+v", c)
+	}
+
+	if _, _, err := runFake(t, "sanitize", "-a", "crypto", "/dev/nvme0"); err == nil {
+		t.Errorf("sanitize without -force succeeded")
+	}
+	if _, _, err := runFake(t, "sanitize", "-a", "shred", "-force", "/dev/nvme0"); err == nil {
+		t.Errorf("sanitize with a bad action succeeded")
+	}
+	_, f, err = runFake(t, "sanitize", "-a", "overwrite", "-passes", "3", "-pattern", "0xff", "-force", "/dev/nvme0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := f.cmds[0]; c.Opcode != 0x84 || c.CDW10 != 3|3<<4 || c.CDW11 != 0xff {
+		t.Errorf("sanitize command is %+v", c)
+	}
+}
+
+func TestFirmware(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nvme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fw := filepath.Join(dir, "fw.bin")
+	if err := ioutil.WriteFile(fw, make([]byte, 10000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, f, err := runFake(t, "fw-download", "-f", fw, "/dev/nvme0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Identify, then three chunks of the default size.
+	if len(f.cmds) != 4 || len(f.cmds[3].Data) != 10000-2*4096 || out != "Downloaded 10000 bytes of firmware\n" {
+		t.Errorf("fw-download issued %d commands and printed %q", len(f.cmds), out)
+	}
+	if _, f, err = runFake(t, "fw-commit", "-s", "2", "-a", "3", "/dev/nvme0"); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.cmds[0]; c.Opcode != 0x10 || c.CDW10 != 2|3<<3 {
+		t.Errorf("fw-commit command is %+v", c)
+	}
+	if _, _, err := runFake(t, "fw-download", "/dev/nvme0"); err == nil {
+		t.Errorf("fw-download without -f succeeded")
+	}
+	if _, _, err := runFake(t, "fw-commit", "-a", "x", "/dev/nvme0"); err == nil {
+		t.Errorf("fw-commit with a bad action succeeded")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"id-ctrl"},
+		{"id-ctrl", "a", "b"},
+		{"frobnicate", "/dev/nvme0"},
+		{"id-ctrl", "-bogus", "/dev/nvme0"},
+	} {
+		if _, _, err := runFake(t, args...); err == nil {
+			t.Errorf("run(%q) succeeded", args)
+		}
+	}
+}
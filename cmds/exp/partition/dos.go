@@ -0,0 +1,273 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mount/mbr"
+)
+
+// dosTypes maps names to MBR partition types.
+var dosTypes = map[string]byte{
+	"linux":    mbr.TypeLinux,
+	"swap":     mbr.TypeLinuxSwap,
+	"lvm":      mbr.TypeLinuxLVM,
+	"raid":     mbr.TypeLinuxRAID,
+	"efi":      mbr.TypeEFI,
+	"fat16":    mbr.TypeFAT16,
+	"fat32":    mbr.TypeFAT32LBA,
+	"ntfs":     mbr.TypeNTFS,
+	"extended": mbr.TypeExtendedLBA,
+}
+
+type dosTable struct {
+	t       *mbr.Table
+	sectors uint64
+	// wipeGPT clears the GPT headers of a disk that had one.
+	wipeGPT bool
+}
+
+func newDOS(sectors uint64, bootCode []byte) (*dosTable, error) {
+	t := &mbr.Table{}
+	copy(t.BootCode[:], bootCode)
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	t.DiskSignature = binary.LittleEndian.Uint32(b[:])
+	return &dosTable{t: t, sectors: sectors}, nil
+}
+
+func (d *dosTable) kind() string {
+	return "dos"
+}
+
+func (d *dosTable) id() string {
+	return fmt.Sprintf("0x%08x", d.t.DiskSignature)
+}
+
+func dosTypeName(t byte) string {
+	for n, v := range dosTypes {
+		if v == t {
+			return n
+		}
+	}
+	return fmt.Sprintf("%02x", t)
+}
+
+func (d *dosTable) entries() []entry {
+	var es []entry
+	for i, p := range d.t.Primary {
+		if !p.IsEmpty() {
+			es = append(es, entry{num: i + 1, first: uint64(p.FirstLBA), last: uint64(p.LastLBA()), typ: dosTypeName(p.Type), boot: p.Bootable})
+		}
+	}
+	for i, p := range d.t.Logical {
+		es = append(es, entry{num: mbr.FirstLogical + i, first: uint64(p.FirstLBA), last: uint64(p.LastLBA()), typ: dosTypeName(p.Type), boot: p.Bootable})
+	}
+	return es
+}
+
+func (d *dosTable) part(n int) (*mbr.Partition, error) {
+	p, err := d.t.Partition(n)
+	if err != nil || p.IsEmpty() {
+		return nil, fmt.Errorf("no partition %d", n)
+	}
+	return p, nil
+}
+
+func (d *dosTable) number(n int) (int, error) {
+	ext := d.t.Extended()
+	next := mbr.FirstLogical + len(d.t.Logical)
+	switch {
+	case n == 0 && ext >= 0:
+		return next, nil
+	case n == 0:
+		for i, p := range d.t.Primary {
+			if p.IsEmpty() {
+				return i + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("all primary partitions are in use")
+	case n >= 1 && n <= mbr.NumPrimary:
+		if !d.t.Primary[n-1].IsEmpty() {
+			return 0, fmt.Errorf("partition %d exists", n)
+		}
+		return n, nil
+	case ext < 0:
+		return 0, fmt.Errorf("logical partition %d needs an extended partition", n)
+	case n != next:
+		// Logical partitions are numbered in the order of the EBR chain.
+		return 0, fmt.Errorf("the next logical partition is %d, not %d", next, n)
+	}
+	return n, nil
+}
+
+func (d *dosTable) space(n int) (uint64, uint64, []extent, error) {
+	var used []extent
+	if n <= mbr.NumPrimary {
+		for i, p := range d.t.Primary {
+			if i+1 != n && !p.IsEmpty() {
+				used = append(used, extent{uint64(p.FirstLBA), uint64(p.LastLBA())})
+			}
+		}
+		hi := d.sectors - 1
+		if hi > 1<<32-2 {
+			hi = 1<<32 - 2
+		}
+		return 1, hi, used, nil
+	}
+
+	e := d.t.Extended()
+	if e < 0 {
+		return 0, 0, nil, fmt.Errorf("no extended partition")
+	}
+	ext := d.t.Primary[e]
+	// Each logical partition is preceded by its EBR. Reserving the sector
+	// after each partition leaves room for the EBR of the next one.
+	for i, p := range d.t.Logical {
+		if mbr.FirstLogical+i != n {
+			used = append(used, extent{uint64(p.EBR), uint64(p.LastLBA()) + 1})
+		}
+	}
+	// The first sector of the extended partition holds the head of the
+	// EBR chain.
+	lo := uint64(ext.FirstLBA) + 1
+	if n != mbr.FirstLogical {
+		lo++
+	}
+	return lo, uint64(ext.LastLBA()), used, nil
+}
+
+func (d *dosTable) add(n int, first, last uint64) error {
+	p := mbr.Partition{Type: mbr.TypeLinux, FirstLBA: uint32(first), Sectors: uint32(last - first + 1)}
+	if n <= mbr.NumPrimary {
+		d.t.Primary[n-1] = p
+		return nil
+	}
+	p.EBR = p.FirstLBA - 1
+	d.t.Logical = append(d.t.Logical, p)
+	return nil
+}
+
+func (d *dosTable) remove(n int) error {
+	p, err := d.part(n)
+	if err != nil {
+		return err
+	}
+	if n > mbr.NumPrimary {
+		// Later logical partitions are renumbered.
+		i := n - mbr.FirstLogical
+		d.t.Logical = append(d.t.Logical[:i], d.t.Logical[i+1:]...)
+		return nil
+	}
+	if p.IsExtended() {
+		d.t.Logical = nil
+	}
+	*p = mbr.Partition{}
+	return nil
+}
+
+func (d *dosTable) resize(n int, last uint64) error {
+	p, err := d.part(n)
+	if err != nil {
+		return err
+	}
+	if p.IsExtended() {
+		for i, l := range d.t.Logical {
+			if uint64(l.LastLBA()) > last {
+				return fmt.Errorf("logical partition %d would be outside the extended partition", mbr.FirstLogical+i)
+			}
+		}
+	}
+	p.Sectors = uint32(last - uint64(p.FirstLBA) + 1)
+	return nil
+}
+
+func (d *dosTable) setType(n int, typ string) error {
+	p, err := d.part(n)
+	if err != nil {
+		return err
+	}
+	t, ok := dosTypes[typ]
+	if !ok {
+		v, err := strconv.ParseUint(strings.TrimPrefix(typ, "0x"), 16, 8)
+		if err != nil || v == mbr.TypeEmpty {
+			return fmt.Errorf("unknown partition type %q", typ)
+		}
+		t = byte(v)
+	}
+	q := *p
+	q.Type = t
+	switch {
+	case n > mbr.NumPrimary && q.IsExtended():
+		return fmt.Errorf("logical partition %d cannot be extended", n)
+	case p.IsExtended() && !q.IsExtended() && len(d.t.Logical) > 0:
+		return fmt.Errorf("partition %d holds logical partitions", n)
+	case !p.IsExtended() && q.IsExtended() && d.t.Extended() >= 0:
+		return fmt.Errorf("there already is an extended partition")
+	}
+	p.Type = t
+	return nil
+}
+
+func (d *dosTable) setName(n int, name string) error {
+	return fmt.Errorf("DOS partitions have no names")
+}
+
+func (d *dosTable) setBoot(n int) error {
+	p, err := d.part(n)
+	if err != nil {
+		return err
+	}
+	if n > mbr.NumPrimary {
+		return fmt.Errorf("logical partition %d cannot be bootable", n)
+	}
+	for i := range d.t.Primary {
+		d.t.Primary[i].Bootable = false
+	}
+	p.Bootable = true
+	return nil
+}
+
+func (d *dosTable) hybrid(nums []int) error {
+	return fmt.Errorf("hybrid MBRs are made from GPTs")
+}
+
+func (d *dosTable) write(f *os.File) error {
+	if err := d.t.Verify(d.sectors); err != nil {
+		return err
+	}
+	if d.wipeGPT {
+		zero := make([]byte, sectorSize)
+		for _, lba := range []uint64{1, d.sectors - 1} {
+			if _, err := f.WriteAt(zero, int64(lba)*sectorSize); err != nil {
+				return err
+			}
+		}
+	}
+	if err := d.t.Write(f); err != nil {
+		return err
+	}
+	return d.verify(f)
+}
+
+func (d *dosTable) verify(r io.ReaderAt) error {
+	t, err := mbr.Read(r)
+	if err != nil {
+		return err
+	}
+	if t.IsProtective() || t.IsHybrid() {
+		return fmt.Errorf("MBR is protective, but there is no GPT")
+	}
+	return t.Verify(d.sectors)
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mount/gpt"
+	"github.com/u-root/u-root/pkg/mount/mbr"
+)
+
+// attrLegacyBoot is the GPT attribute marking a partition bootable for
+// legacy BIOS.
+const attrLegacyBoot = 1 << 2
+
+// mbrTypes are the MBR types of GPT partition types in hybrid MBRs.
+var mbrTypes = map[string]byte{
+	"efi":    mbr.TypeEFI,
+	"linux":  mbr.TypeLinux,
+	"swap":   mbr.TypeLinuxSwap,
+	"lvm":    mbr.TypeLinuxLVM,
+	"raid":   mbr.TypeLinuxRAID,
+	"msdata": mbr.TypeFAT32LBA,
+}
+
+type gptTable struct {
+	p *gpt.PartitionTable
+	// hybridMBR replaces the protective MBR when set.
+	hybridMBR *mbr.Table
+}
+
+func newGPT(sectors uint64, bootCode []byte) (*gptTable, error) {
+	p, err := gpt.NewTable(sectors)
+	if err != nil {
+		return nil, err
+	}
+	copy(p.MasterBootRecord[:], bootCode)
+	return &gptTable{p: p}, nil
+}
+
+func (g *gptTable) kind() string {
+	return "gpt"
+}
+
+func (g *gptTable) id() string {
+	return g.p.Primary.DiskGUID.String()
+}
+
+func typeName(t gpt.GUID) string {
+	for n, g := range gpt.PartTypes {
+		if g == t {
+			return n
+		}
+	}
+	return t.String()
+}
+
+func (g *gptTable) entries() []entry {
+	var es []entry
+	for i, p := range g.p.Primary.Parts {
+		if p.IsEmpty() {
+			continue
+		}
+		es = append(es, entry{
+			num:   i + 1,
+			first: p.FirstLBA,
+			last:  p.LastLBA,
+			typ:   typeName(p.PartGUID),
+			name:  p.Name.String(),
+			boot:  p.Attribute&attrLegacyBoot != 0,
+		})
+	}
+	return es
+}
+
+func (g *gptTable) part(n int) (*gpt.Part, error) {
+	if n < 1 || n > len(g.p.Primary.Parts) || g.p.Primary.Parts[n-1].IsEmpty() {
+		return nil, fmt.Errorf("no partition %d", n)
+	}
+	return &g.p.Primary.Parts[n-1], nil
+}
+
+func (g *gptTable) number(n int) (int, error) {
+	parts := g.p.Primary.Parts
+	if n == 0 {
+		for i, p := range parts {
+			if p.IsEmpty() {
+				return i + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("all %d partitions are in use", len(parts))
+	}
+	if n < 1 || n > len(parts) {
+		return 0, fmt.Errorf("partition number %d is not in 1-%d", n, len(parts))
+	}
+	if !parts[n-1].IsEmpty() {
+		return 0, fmt.Errorf("partition %d exists", n)
+	}
+	return n, nil
+}
+
+func (g *gptTable) space(n int) (uint64, uint64, []extent, error) {
+	var used []extent
+	for i, p := range g.p.Primary.Parts {
+		if i+1 != n && !p.IsEmpty() {
+			used = append(used, extent{p.FirstLBA, p.LastLBA})
+		}
+	}
+	return g.p.Primary.FirstLBA, g.p.Primary.LastLBA, used, nil
+}
+
+func (g *gptTable) add(n int, first, last uint64) error {
+	u, err := gpt.NewGUID()
+	if err != nil {
+		return err
+	}
+	g.p.Primary.Parts[n-1] = gpt.Part{
+		PartGUID:   gpt.PartTypes["linux"],
+		UniqueGUID: u,
+		FirstLBA:   first,
+		LastLBA:    last,
+	}
+	return nil
+}
+
+func (g *gptTable) remove(n int) error {
+	if _, err := g.part(n); err != nil {
+		return err
+	}
+	g.p.Primary.Parts[n-1] = gpt.Part{}
+	return nil
+}
+
+func (g *gptTable) resize(n int, last uint64) error {
+	p, err := g.part(n)
+	if err != nil {
+		return err
+	}
+	p.LastLBA = last
+	return nil
+}
+
+func (g *gptTable) setType(n int, typ string) error {
+	p, err := g.part(n)
+	if err != nil {
+		return err
+	}
+	t, ok := gpt.PartTypes[typ]
+	if !ok {
+		if t, err = gpt.ParseGUID(strings.ToLower(typ)); err != nil {
+			return fmt.Errorf("unknown partition type %q", typ)
+		}
+	}
+	p.PartGUID = t
+	return nil
+}
+
+func (g *gptTable) setName(n int, name string) error {
+	p, err := g.part(n)
+	if err != nil {
+		return err
+	}
+	p.Name, err = gpt.NewPartName(name)
+	return err
+}
+
+func (g *gptTable) setBoot(n int) error {
+	p, err := g.part(n)
+	if err != nil {
+		return err
+	}
+	p.Attribute |= attrLegacyBoot
+	return nil
+}
+
+// hybrid makes a hybrid MBR listing up to three GPT partitions after a
+// protective partition covering the GPT.
+func (g *gptTable) hybrid(nums []int) error {
+	if len(nums) == 0 || len(nums) > mbr.NumPrimary-1 {
+		return fmt.Errorf("a hybrid MBR holds 1 to %d partitions, not %d", mbr.NumPrimary-1, len(nums))
+	}
+	t := &mbr.Table{}
+	copy(t.BootCode[:], g.p.MasterBootRecord[:])
+	t.DiskSignature = binary.LittleEndian.Uint32(g.p.MasterBootRecord[440:])
+	start := uint64(1 << 32)
+	for i, n := range nums {
+		p, err := g.part(n)
+		if err != nil {
+			return err
+		}
+		if p.LastLBA >= 1<<32 {
+			return fmt.Errorf("partition %d is beyond the reach of an MBR", n)
+		}
+		typ, ok := mbrTypes[typeName(p.PartGUID)]
+		if !ok {
+			typ = mbr.TypeLinux
+		}
+		t.Primary[i+1] = mbr.Partition{
+			Bootable: p.Attribute&attrLegacyBoot != 0,
+			Type:     typ,
+			FirstLBA: uint32(p.FirstLBA),
+			Sectors:  uint32(p.LastLBA - p.FirstLBA + 1),
+		}
+		if p.FirstLBA < start {
+			start = p.FirstLBA
+		}
+	}
+	t.Primary[0] = mbr.Partition{Type: mbr.TypeProtective, FirstLBA: 1, Sectors: uint32(start - 1)}
+	g.hybridMBR = t
+	return nil
+}
+
+func (g *gptTable) write(f *os.File) error {
+	if err := checkOverlap(g.entries(), g.p.Primary.FirstLBA, g.p.Primary.LastLBA); err != nil {
+		return err
+	}
+	g.p.SyncBackup()
+	if err := gpt.Write(f, g.p); err != nil {
+		return err
+	}
+	if g.hybridMBR != nil {
+		if err := g.hybridMBR.Write(f); err != nil {
+			return err
+		}
+	}
+	return g.verify(f)
+}
+
+func (g *gptTable) verify(r io.ReaderAt) error {
+	p, err := gpt.New(r)
+	if err != nil {
+		return err
+	}
+	m, err := mbr.Read(r)
+	if err != nil {
+		return fmt.Errorf("protective MBR: %v", err)
+	}
+	if !m.IsProtective() && !m.IsHybrid() {
+		return fmt.Errorf("MBR has no protective partition")
+	}
+	return checkOverlap((&gptTable{p: p}).entries(), p.Primary.FirstLBA, p.Primary.LastLBA)
+}
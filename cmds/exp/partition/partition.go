@@ -0,0 +1,339 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Partition creates, deletes, resizes and retypes partitions.
+//
+// Synopsis:
+//     partition [OPTIONS] DEVICE
+//
+// Description:
+//     partition edits the GPT or DOS partition table of DEVICE, which is a
+//     block device or an image file. Editing options are applied in the
+//     order given. The table is then written, read back and verified, and
+//     the kernel is asked to reread it.
+//
+//     Partitions are numbered from 1. On DOS disks, 1 to 4 are primary
+//     partitions, and logical partitions, numbered from 5 in the order they
+//     were made, live in the partition of type "extended". Number 0 picks
+//     the first free primary partition, or the next logical one if there
+//     is an extended partition.
+//
+//     START is a sector, or a size with a K, M, G or T suffix, and is
+//     rounded up to the alignment. 0 is the first free sector. END is a
+//     sector or size too; +SIZE is relative to START, -SIZE to the end of
+//     the free space, and 0 is the end of the free space. Partitions are a
+//     multiple of the device's physical block size.
+//
+//     TYPE is a GUID or hex byte, or one of linux, swap, lvm, raid, efi,
+//     bios and msdata (GPT) or linux, swap, lvm, raid, efi, fat16, fat32,
+//     ntfs and extended (DOS).
+//
+// Options:
+//     -o gpt|dos:          create a new, empty partition table
+//     -n N:START:END:      create partition N
+//     -d N:                delete partition N
+//     -r N:END:            move the end of partition N
+//     -t N:TYPE:           set the type of partition N
+//     -c N:NAME:           set the name of GPT partition N
+//     -b N:                make partition N bootable
+//     -h N[:N[:N]]:        make a hybrid MBR from GPT partitions
+//     -a SECTORS:          align partition starts (default 1 MiB)
+//     -p:                  print the partition table
+//     -v:                  verify the partition table
+//     -noreread:           do not ask the kernel to reread the table
+//
+// Example:
+//     partition -o gpt -n 1:0:+100M -t 1:efi -c 1:ESP -n 2:0:0 /dev/sda
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/mount/gpt"
+	"github.com/u-root/u-root/pkg/mount/mbr"
+)
+
+// op is an editing option.
+type op struct {
+	flag, arg string
+}
+
+// opFlag collects editing options in command line order.
+type opFlag struct {
+	name string
+	ops  *[]op
+}
+
+func (f opFlag) String() string {
+	return ""
+}
+
+func (f opFlag) Set(s string) error {
+	*f.ops = append(*f.ops, op{f.name, s})
+	return nil
+}
+
+type options struct {
+	// align is the alignment of partition starts in sectors. 0 is 1 MiB.
+	align    uint64
+	print    bool
+	verify   bool
+	noReread bool
+}
+
+var (
+	ops []op
+	o   options
+)
+
+func init() {
+	for _, f := range []struct{ name, usage string }{
+		{"o", "create a new, empty `gpt|dos` partition table"},
+		{"n", "create partition `N:START:END`"},
+		{"d", "delete partition `N`"},
+		{"r", "move the end of partition `N:END`"},
+		{"t", "set the type of partition `N:TYPE`"},
+		{"c", "set the name of GPT partition `N:NAME`"},
+		{"b", "make partition `N` bootable"},
+		{"h", "make a hybrid MBR from GPT partitions `N[:N[:N]]`"},
+	} {
+		flag.Var(opFlag{f.name, &ops}, f.name, f.usage)
+	}
+	flag.Uint64Var(&o.align, "a", 0, "align partition starts to `SECTORS` (default 1 MiB)")
+	flag.BoolVar(&o.print, "p", false, "print the partition table")
+	flag.BoolVar(&o.verify, "v", false, "verify the partition table")
+	flag.BoolVar(&o.noReread, "noreread", false, "do not ask the kernel to reread the partition table")
+}
+
+// diskGeometry returns the size and alignment of f and, if it is a block
+// device, the device.
+func diskGeometry(f *os.File, align uint64) (geometry, *block.BlockDev, error) {
+	g := geometry{phys: 1}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return g, nil, err
+	}
+	g.sectors = uint64(size) / sectorSize
+	fi, err := f.Stat()
+	if err != nil {
+		return g, nil, err
+	}
+	var dev *block.BlockDev
+	if fi.Mode()&os.ModeDevice != 0 {
+		if dev, err = block.Device(f.Name()); err != nil {
+			return g, nil, err
+		}
+		bs, err := dev.BlockSize()
+		if err != nil {
+			return g, nil, err
+		}
+		if bs != sectorSize {
+			return g, nil, fmt.Errorf("%s has %d byte sectors, only %d are supported", f.Name(), bs, sectorSize)
+		}
+		ps, err := dev.PhysicalBlockSize()
+		if err != nil {
+			return g, nil, err
+		}
+		if ps > sectorSize {
+			g.phys = uint64(ps / sectorSize)
+		}
+	}
+	if align == 0 {
+		align = 1 << 20 / sectorSize
+	}
+	g.align = roundUp(align, g.phys)
+	return g, dev, nil
+}
+
+// readTable returns the partition table on f, or nil if there is none.
+func readTable(f io.ReaderAt, sectors uint64) (table, error) {
+	p, gerr := gpt.New(f)
+	if p.Primary != nil {
+		if gerr != nil {
+			log.Printf("Warning: %v; writing will rebuild the backup GPT", gerr)
+		}
+		return &gptTable{p: p}, nil
+	}
+	t, err := mbr.Read(f)
+	if err == mbr.ErrNoMBR {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.IsProtective() || t.IsHybrid() {
+		return nil, fmt.Errorf("the MBR is protective, but the GPT is damaged: %v", gerr)
+	}
+	return &dosTable{t: t, sectors: sectors}, nil
+}
+
+func number(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a partition number", s)
+	}
+	return n, nil
+}
+
+// apply applies one editing option to t and returns the edited table.
+func apply(f io.ReaderAt, t table, o op, g geometry) (table, error) {
+	if o.flag == "o" {
+		boot := make([]byte, sectorSize)
+		if _, err := f.ReadAt(boot, 0); err != nil {
+			return nil, err
+		}
+		switch o.arg {
+		case "gpt":
+			return newGPT(g.sectors, boot[:440])
+		case "dos":
+			d, err := newDOS(g.sectors, boot[:440])
+			if err != nil {
+				return nil, err
+			}
+			d.wipeGPT = t != nil && t.kind() == "gpt"
+			return d, nil
+		}
+		return nil, fmt.Errorf("unknown partition table type %q", o.arg)
+	}
+	if t == nil {
+		return nil, fmt.Errorf("there is no partition table; create one with -o")
+	}
+
+	args := strings.SplitN(o.arg, ":", 3)
+	if o.flag == "h" {
+		args = strings.Split(o.arg, ":")
+		var nums []int
+		for _, a := range args {
+			n, err := number(a)
+			if err != nil {
+				return nil, err
+			}
+			nums = append(nums, n)
+		}
+		return t, t.hybrid(nums)
+	}
+	n, err := number(args[0])
+	if err != nil {
+		return nil, err
+	}
+	want := map[string]int{"n": 3, "d": 1, "r": 2, "t": 2, "c": 2, "b": 1}[o.flag]
+	if o.flag == "c" {
+		args = strings.SplitN(o.arg, ":", 2)
+	}
+	if len(args) != want {
+		return nil, fmt.Errorf("want %d fields separated by ':'", want)
+	}
+
+	switch o.flag {
+	case "n":
+		if n, err = t.number(n); err != nil {
+			return nil, err
+		}
+		var first, last uint64
+		if first, last, err = place(t, n, args[1], args[2], g); err != nil {
+			return nil, err
+		}
+		err = t.add(n, first, last)
+	case "d":
+		err = t.remove(n)
+	case "r":
+		err = resize(t, n, args[1], g)
+	case "t":
+		err = t.setType(n, args[1])
+	case "c":
+		err = t.setName(n, args[1])
+	case "b":
+		err = t.setBoot(n)
+	}
+	return t, err
+}
+
+func printTable(w io.Writer, path string, t table, g geometry) {
+	fmt.Fprintf(w, "Disk %s: %d sectors of %d bytes\n", path, g.sectors, sectorSize)
+	fmt.Fprintf(w, "Partition table: %s, identifier %s\n", t.kind(), t.id())
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Number\tStart\tEnd\tSectors\t Boot\t Type\t Name\t\n")
+	for _, e := range t.entries() {
+		var boot string
+		if e.boot {
+			boot = "*"
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t %s\t %s\t %s\t\n", e.num, e.first, e.last, e.last-e.first+1, boot, e.typ, e.name)
+	}
+	tw.Flush()
+}
+
+func edit(path string, ops []op, o options, w io.Writer) error {
+	write := len(ops) > 0
+	flags := os.O_RDONLY
+	if write {
+		flags = os.O_RDWR
+	}
+	f, err := os.OpenFile(path, flags, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	g, dev, err := diskGeometry(f, o.align)
+	if err != nil {
+		return err
+	}
+	t, err := readTable(f, g.sectors)
+	if err != nil && (len(ops) == 0 || ops[0].flag != "o") {
+		return err
+	}
+	for _, op := range ops {
+		if t, err = apply(f, t, op, g); err != nil {
+			return fmt.Errorf("-%s %s: %v", op.flag, op.arg, err)
+		}
+	}
+	if t == nil {
+		return fmt.Errorf("%s has no partition table", path)
+	}
+
+	if write {
+		if err := t.write(f); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		if dev != nil && !o.noReread {
+			if err := dev.ReadPartitionTable(); err != nil {
+				return fmt.Errorf("the partition table was written, but the kernel did not reread it: %v", err)
+			}
+		}
+	} else if o.verify {
+		if err := t.verify(f); err != nil {
+			return err
+		}
+	}
+	if o.verify {
+		fmt.Fprintf(w, "%s: no problems found\n", path)
+	}
+	if o.print {
+		printTable(w, path, t, g)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := edit(flag.Arg(0), ops, o, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
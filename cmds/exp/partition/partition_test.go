@@ -0,0 +1,271 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/gpt"
+	"github.com/u-root/u-root/pkg/mount/mbr"
+)
+
+// 8 MiB
+const diskSectors = 16384
+
+func tempDisk(t *testing.T) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", "partition")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(diskSectors * sectorSize); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+// parseOps turns "-o gpt -n 1:0:0" into ops.
+func parseOps(s string) []op {
+	var ops []op
+	f := strings.Fields(s)
+	for i := 0; i+1 < len(f); i += 2 {
+		ops = append(ops, op{strings.TrimPrefix(f[i], "-"), f[i+1]})
+	}
+	return ops
+}
+
+func entries(t *testing.T, path string) []entry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tab, err := readTable(f, diskSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tab.verify(f); err != nil {
+		t.Fatal(err)
+	}
+	return tab.entries()
+}
+
+func TestGPT(t *testing.T) {
+	path := tempDisk(t)
+	defer os.Remove(path)
+
+	for _, tt := range []struct {
+		ops  string
+		want []entry
+	}{
+		{
+			ops: "-o gpt -n 1:0:+1M -t 1:efi -c 1:EFI:system -n 0:0:-2M -b 2",
+			want: []entry{
+				{num: 1, first: 2048, last: 4095, typ: "efi", name: "EFI:system"},
+				{num: 2, first: 4096, last: diskSectors - 34 - 4096, typ: "linux", boot: true},
+			},
+		},
+		{
+			ops: "-r 2:12287 -n 3:6M:0 -t 3:0657FD6D-A4AB-43C4-84E5-0933C84B4F4F",
+			want: []entry{
+				{num: 1, first: 2048, last: 4095, typ: "efi", name: "EFI:system"},
+				{num: 2, first: 4096, last: 12287, typ: "linux", boot: true},
+				{num: 3, first: 12288, last: diskSectors - 34, typ: "swap"},
+			},
+		},
+		{
+			ops: "-d 1 -r 2:+1M -n 1:3M:5M -t 1:01234567-89ab-cdef-0123-456789abcdef",
+			want: []entry{
+				{num: 1, first: 6144, last: 10240, typ: "01234567-89ab-cdef-0123-456789abcdef"},
+				{num: 2, first: 4096, last: 6143, typ: "linux", boot: true},
+				{num: 3, first: 12288, last: diskSectors - 34, typ: "swap"},
+			},
+		},
+	} {
+		if err := edit(path, parseOps(tt.ops), options{}, ioutil.Discard); err != nil {
+			t.Fatalf("%s: %v", tt.ops, err)
+		}
+		if got := entries(t, path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: partitions are %+v, want %+v", tt.ops, got, tt.want)
+		}
+	}
+
+	// Both headers were written with valid CRCs.
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := gpt.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Backup.CurrentLBA != diskSectors-1 {
+		t.Errorf("backup GPT is at LBA %d, want %d", p.Backup.CurrentLBA, diskSectors-1)
+	}
+}
+
+func TestDOS(t *testing.T) {
+	path := tempDisk(t)
+	defer os.Remove(path)
+
+	ops := "-o dos -n 1:0:+1M -t 1:efi -b 1 -n 2:0:0 -t 2:extended -n 5:0:+1M -n 0:0:+1M -t 6:swap -n 7:0:0 -d 5 -t 6:8e"
+	if err := edit(path, parseOps(ops), options{}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	want := []entry{
+		{num: 1, first: 2048, last: 4095, typ: "efi", boot: true},
+		{num: 2, first: 4096, last: diskSectors - 1, typ: "extended"},
+		{num: 5, first: 10240, last: 12287, typ: "swap"},
+		{num: 6, first: 14336, last: diskSectors - 1, typ: "lvm"},
+	}
+	if got := entries(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("partitions are %+v, want %+v", got, want)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := mbr.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Logical[0].EBR != 10239 || m.Logical[1].EBR != 14335 {
+		t.Errorf("EBRs are at %d and %d, want 10239 and 14335", m.Logical[0].EBR, m.Logical[1].EBR)
+	}
+
+	// Growing a partition into the extended one fails, as does shrinking
+	// the extended partition below a logical one.
+	for _, ops := range []string{"-r 1:5000", "-r 2:+1M", "-n 4:0:0", "-c 1:name", "-h 1", "-t 6:extended", "-n 8:0:0"} {
+		if err := edit(path, parseOps(ops), options{}, ioutil.Discard); err == nil {
+			t.Errorf("%s succeeded", ops)
+		}
+	}
+}
+
+func TestHybrid(t *testing.T) {
+	path := tempDisk(t)
+	defer os.Remove(path)
+
+	ops := "-o gpt -n 1:0:+1M -t 1:efi -b 1 -n 2:0:0 -h 1:2"
+	if err := edit(path, parseOps(ops), options{verify: true}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := mbr.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [4]mbr.Partition{
+		{Type: mbr.TypeProtective, FirstLBA: 1, Sectors: 2047},
+		{Bootable: true, Type: mbr.TypeEFI, FirstLBA: 2048, Sectors: 2048},
+		{Type: mbr.TypeLinux, FirstLBA: 4096, Sectors: diskSectors - 34 - 4096 + 1},
+	}
+	if !m.IsHybrid() || m.Primary != want {
+		t.Errorf("hybrid MBR is %+v, want %+v", m.Primary, want)
+	}
+	if _, err := gpt.New(f); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	path := tempDisk(t)
+	defer os.Remove(path)
+
+	if err := edit(path, parseOps("-o gpt -n 1:0:0"), options{}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := edit(path, parseOps("-o dos -n 1:0:0"), options{}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if p, _ := gpt.New(f); p.Primary != nil {
+		t.Errorf("GPT survived conversion to DOS")
+	}
+	if got := entries(t, path); len(got) != 1 || got[0].typ != "linux" {
+		t.Errorf("partitions are %+v, want one linux partition", got)
+	}
+}
+
+func TestNoTable(t *testing.T) {
+	path := tempDisk(t)
+	defer os.Remove(path)
+
+	if err := edit(path, parseOps("-n 1:0:0"), options{}, ioutil.Discard); err == nil {
+		t.Errorf("creating a partition without a table succeeded")
+	}
+	if err := edit(path, nil, options{print: true}, ioutil.Discard); err == nil {
+		t.Errorf("printing a missing table succeeded")
+	}
+}
+
+func TestPrint(t *testing.T) {
+	path := tempDisk(t)
+	defer os.Remove(path)
+
+	if err := edit(path, parseOps("-o gpt -n 1:0:+1M -c 1:ESP -t 1:efi"), options{}, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := edit(path, nil, options{print: true, verify: true}, &b); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"no problems found", "16384 sectors", "Partition table: gpt", "2048  4095  2048  efi  ESP"} {
+		if !strings.Contains(strings.Join(strings.Fields(b.String()), "  "), s) && !strings.Contains(b.String(), s) {
+			t.Errorf("output %q does not contain %q", b.String(), s)
+		}
+	}
+}
+
+func TestPlace(t *testing.T) {
+	tab := &gptTable{p: &gpt.PartitionTable{Primary: &gpt.GPT{
+		Header: gpt.Header{FirstLBA: 34, LastLBA: 100000},
+		Parts: []gpt.Part{
+			{PartGUID: gpt.PartTypes["linux"], FirstLBA: 2048, LastLBA: 4095},
+			{},
+		},
+	}}}
+	g := geometry{sectors: 100034, align: 2048, phys: 8}
+	for _, tt := range []struct {
+		start, end  string
+		first, last uint64
+		err         bool
+	}{
+		{start: "0", end: "0", first: 4096, last: 100000 - 1},
+		{start: "0", end: "+1M", first: 4096, last: 6143},
+		{start: "0", end: "-1K", first: 4096, last: 99998 - 7},
+		{start: "3M", end: "8191", first: 6144, last: 8191},
+		{start: "1", end: "+1K", err: true},
+		{start: "2048", end: "0", err: true},
+		{start: "0", end: "4000", err: true},
+		{start: "0", end: "200000", err: true},
+		{start: "0", end: "+1000001", err: true},
+		{start: "0", end: "+3X", err: true},
+		{start: "0", end: "+1023", first: 4096, last: 5111},
+	} {
+		first, last, err := place(tab, 2, tt.start, tt.end, g)
+		if (err != nil) != tt.err || first != tt.first || last != tt.last {
+			t.Errorf("place(%q, %q) = %d, %d, %v, want %d, %d, error %v", tt.start, tt.end, first, last, err, tt.first, tt.last, tt.err)
+		}
+	}
+}
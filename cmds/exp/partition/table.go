@@ -0,0 +1,251 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sectorSize is the logical sector size of all supported tables.
+const sectorSize = 512
+
+// entry is a partition as printed.
+type entry struct {
+	num         int
+	first, last uint64
+	typ, name   string
+	boot        bool
+}
+
+// extent is a range of sectors, inclusive.
+type extent struct {
+	first, last uint64
+}
+
+// table is a GPT or DOS partition table being edited. Partitions are
+// numbered from 1.
+type table interface {
+	kind() string
+	id() string
+	entries() []entry
+
+	// number checks that n is free for a new partition. If n is 0, it
+	// returns the first free number.
+	number(n int) (int, error)
+	// space returns the sectors partition n may use and the extents
+	// used by other partitions in that range.
+	space(n int) (lo, hi uint64, used []extent, err error)
+
+	add(n int, first, last uint64) error
+	remove(n int) error
+	resize(n int, last uint64) error
+	setType(n int, typ string) error
+	setName(n int, name string) error
+	setBoot(n int) error
+	hybrid(nums []int) error
+
+	// write writes the table and reads it back to verify it.
+	write(f *os.File) error
+	// verify checks the table on disk.
+	verify(r io.ReaderAt) error
+}
+
+// geometry is the size of the disk and how to align partitions, all in
+// sectors.
+type geometry struct {
+	sectors uint64
+	// align is the alignment of partition starts.
+	align uint64
+	// phys is the physical block size. Partitions are a multiple of it.
+	phys uint64
+}
+
+func roundUp(n, a uint64) uint64 {
+	return (n + a - 1) / a * a
+}
+
+// free returns the unused extents in lo-hi.
+func free(lo, hi uint64, used []extent) []extent {
+	sort.Slice(used, func(i, j int) bool { return used[i].first < used[j].first })
+	var f []extent
+	for _, u := range used {
+		if u.first > lo {
+			f = append(f, extent{lo, u.first - 1})
+		}
+		if u.last >= lo {
+			lo = u.last + 1
+		}
+	}
+	if lo <= hi {
+		f = append(f, extent{lo, hi})
+	}
+	return f
+}
+
+// parseSectors parses a number of sectors, or a size in bytes with a K, M,
+// G or T suffix.
+func parseSectors(s string) (uint64, error) {
+	shift := uint(0)
+	if i := strings.IndexAny(s, "KMGT"); i > 0 && i == len(s)-1 {
+		shift = 10 * uint(strings.IndexByte("KMGT", s[i])+1)
+		s = s[:i]
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if shift == 0 {
+		return n, nil
+	}
+	b := n << shift
+	if b>>shift != n || b%sectorSize != 0 {
+		return 0, fmt.Errorf("size %s is not a whole number of sectors", s)
+	}
+	return b / sectorSize, nil
+}
+
+// place finds the sectors of partition n given START and END
+// specifications.
+//
+// START is a sector or size, rounded up to the alignment; 0 is the start
+// of the first free space. END is a sector or size; +SIZE is relative to
+// START, -SIZE is relative to the end of the free space and 0 is its end.
+// The partition is shrunk to a multiple of the physical block size.
+func place(t table, n int, start, end string, g geometry) (first, last uint64, err error) {
+	lo, hi, used, err := t.space(n)
+	if err != nil {
+		return 0, 0, err
+	}
+	f := free(lo, hi, used)
+
+	var region extent
+	if start == "" || start == "0" {
+		var found bool
+		for _, r := range f {
+			if a := roundUp(r.first, g.align); a <= r.last {
+				first, region, found = a, r, true
+				break
+			}
+		}
+		if !found {
+			return 0, 0, fmt.Errorf("no free space for partition %d", n)
+		}
+	} else {
+		s, err := parseSectors(start)
+		if err != nil {
+			return 0, 0, fmt.Errorf("start of partition %d: %v", n, err)
+		}
+		first = roundUp(s, g.align)
+		var found bool
+		for _, r := range f {
+			if first >= r.first && first <= r.last {
+				region, found = r, true
+				break
+			}
+		}
+		if !found {
+			return 0, 0, fmt.Errorf("sector %d is not free for partition %d", first, n)
+		}
+	}
+
+	if last, err = parseEnd(end, first, region.last); err != nil {
+		return 0, 0, fmt.Errorf("end of partition %d: %v", n, err)
+	}
+	if last > region.last {
+		return 0, 0, fmt.Errorf("partition %d (%d-%d) does not fit in free space %d-%d", n, first, last, region.first, region.last)
+	}
+	return first, shrink(first, last, g), nil
+}
+
+// parseEnd parses END as described at place, for a partition starting at
+// first in free space ending at max.
+func parseEnd(end string, first, max uint64) (uint64, error) {
+	if end == "" || end == "0" {
+		return max, nil
+	}
+	s, err := parseSectors(strings.TrimLeft(end, "+-"))
+	if err != nil {
+		return 0, err
+	}
+	var last uint64
+	switch end[0] {
+	case '+':
+		last = first + s - 1
+	case '-':
+		if s > max {
+			return 0, fmt.Errorf("%s is before the start %d", end, first)
+		}
+		last = max - s
+	default:
+		last = s
+	}
+	if s == 0 || last < first || last+1 < last {
+		return 0, fmt.Errorf("%s is before the start %d", end, first)
+	}
+	return last, nil
+}
+
+// shrink returns last, adjusted so that first-last is a multiple of the
+// physical block size, if possible.
+func shrink(first, last uint64, g geometry) uint64 {
+	n := (last - first + 1) / g.phys * g.phys
+	if n == 0 {
+		return last
+	}
+	return first + n - 1
+}
+
+// resize moves the end of partition n, given as for place.
+func resize(t table, n int, end string, g geometry) error {
+	var first uint64
+	for _, e := range t.entries() {
+		if e.num == n {
+			first = e.first
+		}
+	}
+	if first == 0 {
+		return fmt.Errorf("no partition %d", n)
+	}
+	lo, hi, used, err := t.space(n)
+	if err != nil {
+		return err
+	}
+	max := hi
+	for _, u := range used {
+		if u.first > first && u.first-1 < max {
+			max = u.first - 1
+		}
+	}
+	if first < lo {
+		return fmt.Errorf("partition %d starts before %d", n, lo)
+	}
+	last, err := parseEnd(end, first, max)
+	if err != nil {
+		return fmt.Errorf("end of partition %d: %v", n, err)
+	}
+	if last > max {
+		return fmt.Errorf("partition %d cannot grow beyond sector %d", n, max)
+	}
+	return t.resize(n, shrink(first, last, g))
+}
+
+// checkOverlap reports partitions that overlap or are outside lo-hi.
+func checkOverlap(es []entry, lo, hi uint64) error {
+	sort.Slice(es, func(i, j int) bool { return es[i].first < es[j].first })
+	for i, e := range es {
+		if e.first < lo || e.last > hi || e.last < e.first {
+			return fmt.Errorf("partition %d (%d-%d) is outside the usable sectors %d-%d", e.num, e.first, e.last, lo, hi)
+		}
+		if i > 0 && e.first <= es[i-1].last {
+			return fmt.Errorf("partitions %d and %d overlap", es[i-1].num, e.num)
+		}
+	}
+	return nil
+}
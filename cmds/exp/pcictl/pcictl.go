@@ -0,0 +1,185 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// pcictl removes, rescans, resets and rebinds PCI devices.
+//
+// Synopsis:
+//     pcictl rescan [DEVICE]
+//     pcictl remove DEVICE...
+//     pcictl reset DEVICE [METHOD...]
+//     pcictl busreset BRIDGE
+//     pcictl driver DEVICE
+//     pcictl unbind DEVICE
+//     pcictl bind DRIVER DEVICE
+//     pcictl override DRIVER DEVICE
+//     pcictl rebind DRIVER DEVICE
+//     pcictl sriov DEVICE [NUMVFS]
+//
+// Description:
+//     DEVICE is an address like 0000:01:00.0; the domain may be left out.
+//
+//     rescan enumerates all the buses, or those below a bridge, again.
+//     remove removes devices, e.g. to rescan them. reset resets a device
+//     with one of the METHODs, e.g. flr or bus, or the kernel's choice.
+//     busreset resets all the devices below a bridge; remove them first.
+//
+//     driver prints the driver of a device. unbind and bind unbind and
+//     bind a driver. override makes DRIVER the only one that binds to a
+//     device, or lets all bind again if it is "". rebind overrides the
+//     driver, unbinds the old one and probes for the new one.
+//
+//     sriov prints the enabled and total number of virtual functions and
+//     their addresses, or enables NUMVFS virtual functions.
+//
+// Example:
+//     $ pcictl rebind vfio-pci 01:00.0
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/u-root/u-root/pkg/pci"
+)
+
+const usage = `usage:
+	pcictl rescan [DEVICE]
+	pcictl remove DEVICE...
+	pcictl reset DEVICE [METHOD...]
+	pcictl busreset BRIDGE
+	pcictl driver DEVICE
+	pcictl unbind DEVICE
+	pcictl bind DRIVER DEVICE
+	pcictl override DRIVER DEVICE
+	pcictl rebind DRIVER DEVICE
+	pcictl sriov DEVICE [NUMVFS]`
+
+var errUsage = errors.New(usage)
+
+// commands are the minimum and maximum number of arguments of each
+// command; -1 is any number.
+var commands = map[string][2]int{
+	"rescan":   {0, 1},
+	"remove":   {1, -1},
+	"reset":    {1, -1},
+	"busreset": {1, 1},
+	"driver":   {1, 1},
+	"unbind":   {1, 1},
+	"bind":     {2, 2},
+	"override": {2, 2},
+	"rebind":   {2, 2},
+	"sriov":    {1, 2},
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, args := args[0], args[1:]
+	n, ok := commands[cmd]
+	if !ok || len(args) < n[0] || (n[1] >= 0 && len(args) > n[1]) {
+		return errUsage
+	}
+
+	switch cmd {
+	case "rescan":
+		if len(args) == 0 {
+			return pci.Rescan()
+		}
+	case "remove":
+		// Look them all up first, so that none is removed if one is
+		// mistyped.
+		var devs []*pci.PCI
+		for _, a := range args {
+			d, err := pci.NewDevice(a)
+			if err != nil {
+				return err
+			}
+			devs = append(devs, d)
+		}
+		for _, d := range devs {
+			if err := d.Remove(); err != nil {
+				return fmt.Errorf("removing %s: %v", d.Addr, err)
+			}
+		}
+		return nil
+	}
+
+	// The others take one device, after the driver if they have one.
+	var driver string
+	switch cmd {
+	case "bind", "override", "rebind":
+		driver, args = args[0], args[1:]
+	}
+	d, err := pci.NewDevice(args[0])
+	if err != nil {
+		return err
+	}
+	args = args[1:]
+
+	switch cmd {
+	case "rescan":
+		return d.Rescan()
+	case "reset":
+		if len(args) == 0 {
+			return d.Reset()
+		}
+		return d.ResetWith(args...)
+	case "busreset":
+		return d.SecondaryBusReset()
+	case "driver":
+		drv, err := d.Driver()
+		if err != nil {
+			return err
+		}
+		if drv == "" {
+			drv = "none"
+		}
+		_, err = fmt.Fprintln(stdout, drv)
+		return err
+	case "unbind":
+		return d.Unbind()
+	case "bind":
+		return d.Bind(driver)
+	case "override":
+		return d.SetDriverOverride(driver)
+	case "rebind":
+		return d.Rebind(driver)
+	case "sriov":
+		if len(args) == 1 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("number of virtual functions: %v", err)
+			}
+			return d.SetNumVFs(n)
+		}
+		total, err := d.TotalVFs()
+		if err != nil {
+			return err
+		}
+		num, err := d.NumVFs()
+		if err != nil {
+			return err
+		}
+		vfs, err := d.VirtualFunctions()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%d of %d virtual functions\n", num, total)
+		for _, vf := range vfs {
+			fmt.Fprintln(stdout, vf)
+		}
+	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"frob", "00:1f.0"},
+		{"rescan", "00:1c.0", "00:1d.0"},
+		{"remove"},
+		{"bind", "vfio-pci"},
+		{"busreset", "00:1c.0", "00:1d.0"},
+		{"sriov", "01:00.0", "4", "5"},
+	} {
+		if err := run(args, ioutil.Discard); err != errUsage {
+			t.Errorf("run(%q) = %v, want usage", args, err)
+		}
+	}
+}
+
+func TestNoDevice(t *testing.T) {
+	// Devices are looked up before anything is done to them.
+	if err := run([]string{"remove", "ffff:ff:1f.7"}, ioutil.Discard); err == nil || err == errUsage {
+		t.Errorf("remove of a device that does not exist: %v, want no device", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/u-root/u-root/pkg/pcap"
+	"golang.org/x/sys/unix"
+)
+
+// htons converts a short from host to network byte order.
+func htons(i uint16) uint16 {
+	return i<<8 | i>>8
+}
+
+// capture reads frames from an AF_PACKET socket.
+type capture struct {
+	fd  int
+	buf []byte
+}
+
+// listen opens an AF_PACKET socket receiving every frame on the
+// interface.
+func listen(name string, snapLen uint32, promisc bool) (*capture, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("AF_PACKET socket: %v", err)
+	}
+	c := &capture{fd: fd, buf: make([]byte, snapLen)}
+	sa := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: ifi.Index}
+	if err := unix.Bind(fd, sa); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("binding to %s: %v", name, err)
+	}
+	if promisc {
+		mreq := &unix.PacketMreq{Ifindex: int32(ifi.Index), Type: unix.PACKET_MR_PROMISC}
+		if err := unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("enabling promiscuous mode on %s: %v", name, err)
+		}
+	}
+	return c, nil
+}
+
+// ReadPacket implements source. MSG_TRUNC makes recvfrom return the full
+// length of frames longer than the snap length.
+func (c *capture) ReadPacket() (pcap.CaptureInfo, []byte, error) {
+	for {
+		n, _, err := unix.Recvfrom(c.fd, c.buf, unix.MSG_TRUNC)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return pcap.CaptureInfo{}, nil, err
+		}
+		ci := pcap.CaptureInfo{Timestamp: time.Now(), Length: n}
+		if n > len(c.buf) {
+			n = len(c.buf)
+		}
+		data := make([]byte, n)
+		copy(data, c.buf)
+		return ci, data, nil
+	}
+}
+
+// Close closes the socket. The kernel drops the promiscuous membership
+// with it.
+func (c *capture) Close() error {
+	return unix.Close(c.fd)
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// tcpdump captures and decodes network traffic.
+//
+// Synopsis:
+//     tcpdump [OPTIONS] [EXPRESSION]
+//
+// Description:
+//     tcpdump captures Ethernet frames on an interface, or reads them
+//     from a pcap file, and prints a one-line summary of each frame that
+//     matches EXPRESSION. ARP, IPv4, IPv6, UDP, TCP, ICMP, ICMPv6 and
+//     DHCPv4/DHCPv6 are decoded. With -w, the raw frames are written to
+//     a pcap file for offline analysis instead.
+//
+//     EXPRESSION is a subset of pcap-filter(7), e.g.
+//     "udp port 67 or udp port 68", "arp", "host 10.0.0.1 and tcp",
+//     "ether proto 0x88cc" or "not ip6". "dhcp" matches DHCPv4 and
+//     DHCPv6 messages.
+//
+// Options:
+//     -i: interface to capture on (default: the first non-loopback one that is up)
+//     -r: read frames from a pcap file instead of capturing
+//     -w: write frames to a pcap file instead of printing them
+//     -c: exit after this many frames
+//     -s: bytes to capture per frame
+//     -p: do not put the interface into promiscuous mode
+//     -v: print every decoded layer
+//     -t: do not print timestamps
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/packet"
+	"github.com/u-root/u-root/pkg/pcap"
+)
+
+var (
+	iface     = flag.String("i", "", "Interface to capture on")
+	readFile  = flag.String("r", "", "Read frames from this pcap file")
+	writeFile = flag.String("w", "", "Write frames to this pcap file")
+	count     = flag.Int("c", 0, "Exit after this many frames")
+	snapLen   = flag.Uint("s", pcap.DefaultSnapLen, "Bytes to capture per frame")
+	noPromisc = flag.Bool("p", false, "Do not put the interface into promiscuous mode")
+	verbose   = flag.Bool("v", false, "Print every decoded layer")
+	noTime    = flag.Bool("t", false, "Do not print timestamps")
+)
+
+// source is where frames come from: a live interface or a pcap file.
+type source interface {
+	ReadPacket() (pcap.CaptureInfo, []byte, error)
+}
+
+// options control what dump does with each frame.
+type options struct {
+	filter  *packet.Filter
+	count   int
+	verbose bool
+	noTime  bool
+}
+
+// dump reads frames from src until it is exhausted or o.count frames
+// matched. Matching frames are written to pw if it is non-nil and printed
+// to out otherwise.
+func dump(src source, pw *pcap.Writer, out io.Writer, o options) error {
+	for n := 0; o.count == 0 || n < o.count; {
+		ci, data, err := src.ReadPacket()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p := packet.Decode(data)
+		if !o.filter.Match(p) {
+			continue
+		}
+		n++
+		if pw != nil {
+			if err := pw.WritePacket(ci, data); err != nil {
+				return err
+			}
+			continue
+		}
+		if !o.noTime {
+			fmt.Fprintf(out, "%s ", ci.Timestamp.Format("15:04:05.000000"))
+		}
+		fmt.Fprintln(out, p)
+		if o.verbose {
+			fmt.Fprintln(out, strings.TrimRight(p.Details(), "\n"))
+		}
+	}
+	return nil
+}
+
+// defaultInterface returns the first interface that is up and not a
+// loopback.
+func defaultInterface() (string, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, i := range ifs {
+		if i.Flags&net.FlagUp != 0 && i.Flags&net.FlagLoopback == 0 {
+			return i.Name, nil
+		}
+	}
+	return "", errors.New("no interface is up; use -i")
+}
+
+func run(args []string, out io.Writer) error {
+	f, err := packet.ParseFilter(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	o := options{filter: f, count: *count, verbose: *verbose, noTime: *noTime}
+
+	var src source
+	if *readFile != "" {
+		r, err := os.Open(*readFile)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		pr, err := pcap.NewReader(r)
+		if err != nil {
+			return err
+		}
+		if pr.LinkType() != pcap.LinkTypeEthernet {
+			return fmt.Errorf("%s: unsupported link type %d", *readFile, pr.LinkType())
+		}
+		src = pr
+	} else {
+		name := *iface
+		if name == "" {
+			if name, err = defaultInterface(); err != nil {
+				return err
+			}
+		}
+		c, err := listen(name, uint32(*snapLen), !*noPromisc)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		src = c
+		log.Printf("listening on %s", name)
+	}
+
+	var pw *pcap.Writer
+	if *writeFile != "" {
+		w, err := os.Create(*writeFile)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		if pw, err = pcap.NewWriter(w, uint32(*snapLen), pcap.LinkTypeEthernet); err != nil {
+			return err
+		}
+	}
+	return dump(src, pw, out, o)
+}
+
+func main() {
+	flag.Parse()
+	if err := run(flag.Args(), os.Stdout); err != nil {
+		log.Fatalf("tcpdump: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/packet"
+	"github.com/u-root/u-root/pkg/pcap"
+)
+
+const fixture = "../../../pkg/packet/testdata/capture.pcap"
+
+func openFixture(t *testing.T) (*pcap.Reader, func()) {
+	t.Helper()
+	f, err := os.Open(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		f.Close()
+		t.Fatal(err)
+	}
+	return r, func() { f.Close() }
+}
+
+func TestDump(t *testing.T) {
+	for _, tt := range []struct {
+		expr  string
+		count int
+		want  string
+	}{
+		{
+			expr: "dhcp",
+			want: "IP 0.0.0.0.68 > 255.255.255.255.67: DHCPv4 DISCOVER, xid 0xdeadbeef\n" +
+				"IP6 fe80::5054:ff:fe12:3456.546 > ff02::1:2.547: DHCPv6 SOLICIT\n",
+		},
+		{
+			expr:  "",
+			count: 2,
+			want: "IP 0.0.0.0.68 > 255.255.255.255.67: DHCPv4 DISCOVER, xid 0xdeadbeef\n" +
+				"ARP, Request who-has 192.168.1.1 tell 192.168.1.10\n",
+		},
+		{
+			expr: "tcp port 22",
+			want: "",
+		},
+	} {
+		f, err := packet.ParseFilter(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, done := openFixture(t)
+		var out bytes.Buffer
+		err = dump(r, nil, &out, options{filter: f, count: tt.count, noTime: true})
+		done()
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("%q: got\n%s\nwant\n%s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestDumpWrite(t *testing.T) {
+	f, err := packet.ParseFilter("icmp or icmp6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, done := openFixture(t)
+	defer done()
+	var buf bytes.Buffer
+	pw, err := pcap.NewWriter(&buf, pcap.DefaultSnapLen, pcap.LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dump(r, pw, nil, options{filter: f}); err != nil {
+		t.Fatal(err)
+	}
+
+	r2, err := pcap.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := dump(r2, nil, &out, options{noTime: true, verbose: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"ICMP echo request, id 4660, seq 7", "ICMP6 echo reply", "IPv6 2001:db8::1 > 2001:db8::2"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output %q does not contain %q", out.String(), want)
+		}
+	}
+	if n := strings.Count(out.String(), "Ethernet "); n != 2 {
+		t.Errorf("got %d frames written, want 2", n)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// tpmlog prints the TCG event log of the firmware, replays it, and checks
+// that the PCRs of the TPM have the values it gives.
+//
+// Synopsis:
+//     tpmlog [-log FILE] [-firmware TYPE] [-replay] [-verify] [-json]
+//
+// Description:
+//     Without -replay or -verify, tpmlog prints the events of the log.
+//
+//     With -verify, tpmlog exits with status 1 if a PCR of a bank the
+//     log has digests for differs from the one the log gives.
+//
+// Options:
+//     -log:      the event log (default /sys/kernel/security/tpm0/binary_bios_measurements)
+//     -firmware: the firmware that wrote the log: UEFI, BIOS or TXT, for an
+//                Intel TXT event container (default UEFI)
+//     -replay:   print the PCR values the log gives
+//     -verify:   compare the PCRs of the TPM with those the log gives
+//     -json:     print the events, and the PCRs or mismatches, as JSON
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/u-root/u-root/pkg/tss"
+	"github.com/u-root/u-root/pkg/txtlog"
+)
+
+var (
+	logFile  = flag.String("log", txtlog.DefaultTCPABinaryLog, "event log")
+	firmware = flag.String("firmware", string(txtlog.Uefi), "firmware that wrote the log: UEFI, BIOS or TXT")
+	replay   = flag.Bool("replay", false, "print the PCR values the log gives")
+	verify   = flag.Bool("verify", false, "compare the PCRs of the TPM with those the log gives")
+	asJSON   = flag.Bool("json", false, "print JSON")
+)
+
+// report is the JSON output.
+type report struct {
+	Log           *txtlog.PCRLog                        `json:"log"`
+	PCRs          map[txtlog.IAlgHash]map[string]string `json:"pcrs,omitempty"`
+	VerifiedBanks []txtlog.IAlgHash                     `json:"verified_banks,omitempty"`
+	Mismatches    []txtlog.PCRMismatch                  `json:"mismatches,omitempty"`
+}
+
+func sortedBanks(banks txtlog.PCRBanks) []txtlog.IAlgHash {
+	var algs []txtlog.IAlgHash
+	for alg := range banks {
+		algs = append(algs, alg)
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+	return algs
+}
+
+func sortedPCRs(pcrs map[int][]byte) []int {
+	var idx []int
+	for i := range pcrs {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// verifyBanks reads the banks of the TPM the log has digests for, and
+// compares them with the log.
+func verifyBanks(t *tss.TPM, l *txtlog.PCRLog, banks txtlog.PCRBanks) ([]txtlog.IAlgHash, []txtlog.PCRMismatch, error) {
+	var verified []txtlog.IAlgHash
+	var pcrs []tss.PCR
+	for _, alg := range sortedBanks(banks) {
+		p, err := t.ReadPCRBank(alg.Hash())
+		if err != nil {
+			log.Printf("Not verifying the %v PCRs: %v", alg, err)
+			continue
+		}
+		verified = append(verified, alg)
+		pcrs = append(pcrs, p...)
+	}
+	if len(verified) == 0 {
+		return nil, nil, fmt.Errorf("the TPM has none of the PCR banks of the log")
+	}
+	bad, err := l.Verify(pcrs)
+	return verified, bad, err
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	version := tss.TPMVersion20
+	var t *tss.TPM
+	if *verify {
+		var err error
+		if t, err = tss.NewTPM(); err != nil {
+			log.Fatal(err)
+		}
+		defer t.Close()
+		version = t.Version
+	}
+
+	// ParseLog reads TPM 1.2 logs if the TPM 2.0 one is not crypto-agile.
+	txtlog.DefaultTCPABinaryLog = *logFile
+	l, err := txtlog.ParseLog(txtlog.FirmwareType(*firmware), version)
+	if err != nil {
+		log.Fatalf("Parsing %s: %v", *logFile, err)
+	}
+	banks, err := l.Replay()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := report{Log: l}
+	if *verify {
+		if r.VerifiedBanks, r.Mismatches, err = verifyBanks(t, l, banks); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch {
+	case *asJSON:
+		if *replay {
+			r.PCRs = map[txtlog.IAlgHash]map[string]string{}
+			for alg, pcrs := range banks {
+				r.PCRs[alg] = map[string]string{}
+				for i, v := range pcrs {
+					r.PCRs[alg][fmt.Sprint(i)] = hex.EncodeToString(v)
+				}
+			}
+		}
+		b, err := json.MarshalIndent(r, "", "\t")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s\n", b)
+
+	case *replay || *verify:
+		if *replay {
+			for _, alg := range sortedBanks(banks) {
+				for _, i := range sortedPCRs(banks[alg]) {
+					fmt.Printf("%v PCR %d: %x\n", alg, i, banks[alg][i])
+				}
+			}
+		}
+		for _, m := range r.Mismatches {
+			fmt.Println(m)
+		}
+		if *verify && len(r.Mismatches) == 0 {
+			fmt.Printf("The %v PCRs match the log\n", r.VerifiedBanks)
+		}
+
+	default:
+		if err := txtlog.DumpLog(l); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(r.Mismatches) != 0 {
+		os.Exit(1)
+	}
+}
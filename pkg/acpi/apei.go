@@ -0,0 +1,501 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/memio"
+)
+
+// The ACPI Platform Error Interfaces: the BERT, the errors of the
+// previous boot, and the HEST, the sources of hardware errors.
+
+// BERT is the Boot Error Record Table.
+type BERT struct {
+	Header
+
+	// RegionLength and RegionAddress are where the firmware left the
+	// generic error status block of the last boot's fatal error.
+	RegionLength  uint32
+	RegionAddress uint64
+}
+
+// NewBERT decodes a BERT.
+func NewBERT(t Table) (*BERT, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	b := t.TableData()
+	if h.Signature != "BERT" || len(b) < 12 {
+		return nil, fmt.Errorf("%s: not a BERT", h.Signature)
+	}
+	return &BERT{
+		Header:        h,
+		RegionLength:  binary.LittleEndian.Uint32(b),
+		RegionAddress: binary.LittleEndian.Uint64(b[4:]),
+	}, nil
+}
+
+// Marshal implements Marshaler.
+func (b *BERT) Marshal() (Table, error) {
+	var d bytes.Buffer
+	encode(&d, b.RegionLength)
+	encode(&d, b.RegionAddress)
+	return NewTable(b.Header, d.Bytes())
+}
+
+// ReadRegion reads the boot error region from physical memory.
+func (b *BERT) ReadRegion() (*ErrorStatusBlock, error) {
+	dat := memio.ByteSlice(make([]byte, b.RegionLength))
+	if err := memio.Read(int64(b.RegionAddress), &dat); err != nil {
+		return nil, err
+	}
+	return NewErrorStatusBlock(dat)
+}
+
+// String prints the BERT.
+func (b *BERT) String() string {
+	return fmt.Sprintf("%s\nBoot error region: %d bytes at %#x\n", &b.Header, b.RegionLength, b.RegionAddress)
+}
+
+// ErrorSeverities are the names of error severities.
+var ErrorSeverities = []string{"recoverable", "fatal", "corrected", "none"}
+
+func severity(s uint32) string {
+	if int(s) < len(ErrorSeverities) {
+		return ErrorSeverities[s]
+	}
+	return fmt.Sprintf("severity %d", s)
+}
+
+// Common section types of error data, from the UEFI specification's
+// Common Platform Error Record appendix.
+var SectionTypes = map[string]string{
+	"9876ccad-47b4-4bdb-b65e-16f193c4f3db": "Processor Generic",
+	"dc3ea0b0-a144-4797-b95b-53fa242b6e1d": "IA32/X64 Processor",
+	"e19e3d16-bc11-11e4-9caa-c2051d5d46b0": "ARM Processor",
+	"a5bc1114-6f64-4ede-b863-3e83ed7c83b1": "Platform Memory",
+	"d995e954-bbc1-430f-ad91-b44dcb3c6f35": "PCI Express",
+	"81212a96-09ed-4996-9471-8d729c8e69ed": "Firmware Error Record Reference",
+	"c5753963-3b84-4095-bf78-eddad3f9c9dd": "PCI/PCI-X Bus",
+	"eb5e4685-ca66-4769-b6a2-26068b001326": "DMAr Generic",
+}
+
+// guid formats a mixed endian EFI GUID.
+func guid(b []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
+
+// ErrorStatusBlock is a Generic Error Status Block, the error data a
+// GHES or the BERT points to.
+type ErrorStatusBlock struct {
+	// BlockStatus has bits for uncorrectable and correctable errors,
+	// and the count of error data entries in bits 4 to 13.
+	BlockStatus uint32
+	Severity    string
+	Entries     []ErrorData
+
+	// RawData is vendor specific.
+	RawData []byte
+}
+
+// ErrorData is a Generic Error Data Entry: an error section.
+type ErrorData struct {
+	SectionType string
+	Severity    string
+	Revision    uint16
+	Flags       uint8
+	FRUID       string `json:",omitempty"`
+	FRUText     string `json:",omitempty"`
+	Timestamp   uint64 `json:",omitempty"`
+	Data        []byte
+}
+
+// Name returns the name of the section type, or its GUID.
+func (e *ErrorData) Name() string {
+	if n, ok := SectionTypes[e.SectionType]; ok {
+		return n
+	}
+	return e.SectionType
+}
+
+// NewErrorStatusBlock decodes a generic error status block.
+func NewErrorStatusBlock(b []byte) (*ErrorStatusBlock, error) {
+	if len(b) < 20 {
+		return nil, fmt.Errorf("error status block: %d bytes is too short", len(b))
+	}
+	s := &ErrorStatusBlock{
+		BlockStatus: binary.LittleEndian.Uint32(b),
+		Severity:    severity(binary.LittleEndian.Uint32(b[16:])),
+	}
+	rawOff := binary.LittleEndian.Uint32(b[4:])
+	rawLen := binary.LittleEndian.Uint32(b[8:])
+	dataLen := binary.LittleEndian.Uint32(b[12:])
+	if uint64(dataLen)+20 > uint64(len(b)) || uint64(rawOff)+uint64(rawLen) > uint64(len(b)) {
+		return nil, fmt.Errorf("error status block: data past the end of %d bytes", len(b))
+	}
+	if rawLen != 0 {
+		s.RawData = b[rawOff : rawOff+rawLen]
+	}
+	for d := b[20 : 20+dataLen]; len(d) > 0; {
+		if len(d) < 64 {
+			return nil, fmt.Errorf("error status block: truncated error data entry")
+		}
+		e := ErrorData{
+			SectionType: guid(d),
+			Severity:    severity(binary.LittleEndian.Uint32(d[16:])),
+			Revision:    binary.LittleEndian.Uint16(d[20:]),
+			Flags:       d[23],
+		}
+		valid, n := d[22], 64
+		if valid&1 != 0 {
+			e.FRUID = guid(d[28:])
+		}
+		if valid&2 != 0 {
+			e.FRUText = cstr(d[44:64])
+		}
+		// Revision 3 added the timestamp.
+		if e.Revision >= 0x300 {
+			if len(d) < 72 {
+				return nil, fmt.Errorf("error status block: truncated error data entry")
+			}
+			if valid&4 != 0 {
+				e.Timestamp = binary.LittleEndian.Uint64(d[64:])
+			}
+			n = 72
+		}
+		l := int(binary.LittleEndian.Uint32(d[24:]))
+		if n+l > len(d) {
+			return nil, fmt.Errorf("error status block: %d bytes of error data past the end", l)
+		}
+		e.Data = d[n : n+l]
+		s.Entries = append(s.Entries, e)
+		d = d[n+l:]
+	}
+	return s, nil
+}
+
+// String prints the error sections.
+func (s *ErrorStatusBlock) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Error status %#x, %s, %d sections\n", s.BlockStatus, s.Severity, len(s.Entries))
+	for _, e := range s.Entries {
+		fmt.Fprintf(&b, "  %s: %s, %d bytes", e.Name(), e.Severity, len(e.Data))
+		if e.FRUText != "" {
+			fmt.Fprintf(&b, ", FRU %q", e.FRUText)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// HEST is the Hardware Error Source Table.
+type HEST struct {
+	Header
+	Sources []HESTSource
+}
+
+// HEST error source types.
+const (
+	HESTMachineCheck          = 0
+	HESTCorrectedMachineCheck = 1
+	HESTNMI                   = 2
+	HESTAERRootPort           = 6
+	HESTAEREndpoint           = 7
+	HESTAERBridge             = 8
+	HESTGHES                  = 9
+	HESTGHESv2                = 10
+	HESTDeferredMachineCheck  = 11
+)
+
+// HESTSourceTypes are the names of error source types.
+var HESTSourceTypes = map[uint16]string{
+	HESTMachineCheck:          "IA-32 Machine Check",
+	HESTCorrectedMachineCheck: "IA-32 Corrected Machine Check",
+	HESTNMI:                   "IA-32 NMI",
+	HESTAERRootPort:           "PCIe Root Port AER",
+	HESTAEREndpoint:           "PCIe Device AER",
+	HESTAERBridge:             "PCIe Bridge AER",
+	HESTGHES:                  "Generic Hardware Error Source",
+	HESTGHESv2:                "Generic Hardware Error Source v2",
+	HESTDeferredMachineCheck:  "IA-32 Deferred Machine Check",
+}
+
+// NotificationTypes are the names of the ways error sources notify the
+// OS.
+var NotificationTypes = []string{
+	"Polled", "External Interrupt", "Local Interrupt", "SCI", "NMI", "CMCI",
+	"MCE", "GPIO-Signal", "SEA", "SEI", "GSIV", "Software Delegated Exception",
+}
+
+// Notification is a Hardware Error Notification structure.
+type Notification struct {
+	Type                           uint8
+	Length                         uint8
+	ConfigWriteEnable              uint16
+	PollInterval                   uint32
+	Vector                         uint32
+	SwitchToPollingThresholdValue  uint32
+	SwitchToPollingThresholdWindow uint32
+	ErrorThresholdValue            uint32
+	ErrorThresholdWindow           uint32
+}
+
+// String returns the notification type.
+func (n *Notification) String() string {
+	if int(n.Type) < len(NotificationTypes) {
+		return NotificationTypes[n.Type]
+	}
+	return fmt.Sprintf("type %d", n.Type)
+}
+
+// AER is the configuration of a PCIe Advanced Error Reporting source.
+type AER struct {
+	// Bus, Device and Function are the device, unless Flags bit 1,
+	// GLOBAL, says the settings are for all devices of the type.
+	Bus      uint32
+	Device   uint16
+	Function uint16
+
+	DeviceControl              uint16
+	UncorrectableErrorMask     uint32
+	UncorrectableErrorSeverity uint32
+	CorrectableErrorMask       uint32
+	AdvancedErrorCapabilities  uint32
+
+	// RootErrorCommand is for root ports.
+	RootErrorCommand uint32 `json:",omitempty"`
+
+	// The secondary registers are for bridges.
+	SecondaryUncorrectableErrorMask     uint32 `json:",omitempty"`
+	SecondaryUncorrectableErrorSeverity uint32 `json:",omitempty"`
+	SecondaryAdvancedErrorCapabilities  uint32 `json:",omitempty"`
+}
+
+// HESTSource is an error source. Only the fields of its type are set.
+type HESTSource struct {
+	Type     uint16
+	SourceID uint16
+
+	// RelatedSourceID is the source a GHES is an alternate for, or
+	// 0xffff.
+	RelatedSourceID uint16 `json:",omitempty"`
+
+	// Flags are FIRMWARE_FIRST (bit 0), GLOBAL (bit 1) and
+	// GHES_ASSIST (bit 2).
+	Flags   uint8
+	Enabled bool
+
+	RecordsToPreallocate uint32
+	MaxSectionsPerRecord uint32
+	MaxRawDataLength     uint32 `json:",omitempty"`
+
+	// Banks is the number of machine check banks.
+	Banks int `json:",omitempty"`
+
+	Notification *Notification `json:",omitempty"`
+
+	// ErrorStatusAddress holds the address of the GHES's error status
+	// block, of ErrorStatusBlockLength bytes.
+	ErrorStatusAddress     *GAS   `json:",omitempty"`
+	ErrorStatusBlockLength uint32 `json:",omitempty"`
+
+	// ReadAckRegister is written with ReadAckWrite, keeping the bits
+	// in ReadAckPreserve, once the OS has read a GHESv2 error.
+	ReadAckRegister *GAS   `json:",omitempty"`
+	ReadAckPreserve uint64 `json:",omitempty"`
+	ReadAckWrite    uint64 `json:",omitempty"`
+
+	AER *AER `json:",omitempty"`
+}
+
+// Name returns the name of the source's type.
+func (s *HESTSource) Name() string {
+	if n, ok := HESTSourceTypes[s.Type]; ok {
+		return n
+	}
+	return fmt.Sprintf("type %d", s.Type)
+}
+
+const (
+	notificationSize = 28
+	bankSize         = 28
+)
+
+// hestSource decodes the error source at the start of b and returns its
+// length.
+func hestSource(b []byte) (*HESTSource, int, error) {
+	if len(b) < 20 {
+		return nil, 0, fmt.Errorf("truncated error source")
+	}
+	le := binary.LittleEndian
+	s := &HESTSource{
+		Type:                 le.Uint16(b),
+		SourceID:             le.Uint16(b[2:]),
+		Flags:                b[6],
+		Enabled:              b[7] != 0,
+		RecordsToPreallocate: le.Uint32(b[8:]),
+		MaxSectionsPerRecord: le.Uint32(b[12:]),
+	}
+	// need checks that b holds n bytes before they are decoded.
+	need := func(n int) error {
+		if len(b) < n {
+			return fmt.Errorf("%s source %d: %d bytes, need %d", s.Name(), s.SourceID, len(b), n)
+		}
+		return nil
+	}
+	notification := func(off int) error {
+		s.Notification = &Notification{}
+		return decode(b[off:off+notificationSize], s.Notification)
+	}
+	aer := func(n int) error {
+		if err := need(n); err != nil {
+			return err
+		}
+		s.AER = &AER{
+			Bus:                        le.Uint32(b[16:]),
+			Device:                     le.Uint16(b[20:]),
+			Function:                   le.Uint16(b[22:]),
+			DeviceControl:              le.Uint16(b[24:]),
+			UncorrectableErrorMask:     le.Uint32(b[28:]),
+			UncorrectableErrorSeverity: le.Uint32(b[32:]),
+			CorrectableErrorMask:       le.Uint32(b[36:]),
+			AdvancedErrorCapabilities:  le.Uint32(b[40:]),
+		}
+		return nil
+	}
+
+	var n int
+	switch s.Type {
+	case HESTMachineCheck:
+		if err := need(40); err != nil {
+			return nil, 0, err
+		}
+		s.Banks = int(b[32])
+		n = 40 + bankSize*s.Banks
+	case HESTCorrectedMachineCheck, HESTDeferredMachineCheck:
+		if err := need(48); err != nil {
+			return nil, 0, err
+		}
+		if err := notification(16); err != nil {
+			return nil, 0, err
+		}
+		s.Banks = int(b[44])
+		n = 48 + bankSize*s.Banks
+	case HESTNMI:
+		s.Flags, s.Enabled = 0, true
+		s.MaxRawDataLength = le.Uint32(b[16:])
+		n = 20
+	case HESTAERRootPort:
+		n = 48
+		if err := aer(n); err != nil {
+			return nil, 0, err
+		}
+		s.AER.RootErrorCommand = le.Uint32(b[44:])
+	case HESTAEREndpoint:
+		n = 44
+		if err := aer(n); err != nil {
+			return nil, 0, err
+		}
+	case HESTAERBridge:
+		n = 56
+		if err := aer(n); err != nil {
+			return nil, 0, err
+		}
+		s.AER.SecondaryUncorrectableErrorMask = le.Uint32(b[44:])
+		s.AER.SecondaryUncorrectableErrorSeverity = le.Uint32(b[48:])
+		s.AER.SecondaryAdvancedErrorCapabilities = le.Uint32(b[52:])
+	case HESTGHES, HESTGHESv2:
+		n = 64
+		if s.Type == HESTGHESv2 {
+			n = 92
+		}
+		if err := need(n); err != nil {
+			return nil, 0, err
+		}
+		s.RelatedSourceID = le.Uint16(b[4:])
+		s.MaxRawDataLength = le.Uint32(b[16:])
+		s.ErrorStatusAddress = &GAS{}
+		if err := decode(b[20:32], s.ErrorStatusAddress); err != nil {
+			return nil, 0, err
+		}
+		if err := notification(32); err != nil {
+			return nil, 0, err
+		}
+		s.ErrorStatusBlockLength = le.Uint32(b[60:])
+		if s.Type == HESTGHESv2 {
+			s.ReadAckRegister = &GAS{}
+			if err := decode(b[64:76], s.ReadAckRegister); err != nil {
+				return nil, 0, err
+			}
+			s.ReadAckPreserve = le.Uint64(b[76:])
+			s.ReadAckWrite = le.Uint64(b[84:])
+		}
+	default:
+		// The length of other sources is unknown, so nothing after
+		// them can be decoded either.
+		return nil, 0, fmt.Errorf("unknown error source type %d", s.Type)
+	}
+	if err := need(n); err != nil {
+		return nil, 0, err
+	}
+	return s, n, nil
+}
+
+// NewHEST decodes a HEST.
+func NewHEST(t Table) (*HEST, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	b := t.TableData()
+	if h.Signature != "HEST" || len(b) < 4 {
+		return nil, fmt.Errorf("%s: not a HEST", h.Signature)
+	}
+	count := binary.LittleEndian.Uint32(b)
+	e := &HEST{Header: h}
+	b = b[4:]
+	for i := uint32(0); i < count; i++ {
+		s, n, err := hestSource(b)
+		if err != nil {
+			return nil, fmt.Errorf("HEST error source %d: %v", i, err)
+		}
+		e.Sources = append(e.Sources, *s)
+		b = b[n:]
+	}
+	if len(b) != 0 {
+		return nil, fmt.Errorf("HEST: %d bytes after %d error sources", len(b), count)
+	}
+	return e, nil
+}
+
+// String prints an error source per line.
+func (e *HEST) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", &e.Header)
+	for _, s := range e.Sources {
+		fmt.Fprintf(&b, "Source %d: %s, enabled %t, flags %#x", s.SourceID, s.Name(), s.Enabled, s.Flags)
+		if s.Banks != 0 {
+			fmt.Fprintf(&b, ", %d banks", s.Banks)
+		}
+		if s.Notification != nil {
+			fmt.Fprintf(&b, ", notify %s", s.Notification)
+		}
+		if s.ErrorStatusAddress != nil {
+			fmt.Fprintf(&b, ", status at %s", s.ErrorStatusAddress)
+		}
+		if s.AER != nil {
+			fmt.Fprintf(&b, ", device %02x:%02x.%d", s.AER.Bus, s.AER.Device, s.AER.Function)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -4,7 +4,11 @@
 
 package acpi
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
 
 const (
 	llDSDTAddr = 140
@@ -90,3 +94,154 @@ func RawTablesFromMem() ([]Table, error) {
 	}
 	return x.Tables, err
 }
+
+// FADT offsets of the pointers to the FACS and DSDT.
+const (
+	lFACSAddr  = 36
+	llFACSAddr = 132
+)
+
+// align returns a rounded up to a multiple of n, a power of 2.
+func align(a, n int64) int64 {
+	return (a + n - 1) &^ (n - 1)
+}
+
+// drop returns tabs without the tables with signatures sigs.
+func drop(tabs []Table, sigs ...string) []Table {
+	var out []Table
+	for _, t := range tabs {
+		var d bool
+		for _, s := range sigs {
+			d = d || t.Sig() == s
+		}
+		if !d {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// NewBiosTable lays tabs out in memory from base: a new RSDP, a new XSDT
+// and, if everything is below 4 GiB, a new RSDT, followed by tabs. Any
+// RSDT or XSDT in tabs is dropped. The XSDT and RSDT point at all tables
+// but the DSDT and FACS, which the FADT is made to point at instead.
+//
+// Tables[0] is the XSDT and, if there is one, Tables[1] the RSDT. Image
+// returns the memory image to load at base.
+func NewBiosTable(base int64, oemID string, tabs []Table) (*BiosTable, error) {
+	if base%16 != 0 {
+		return nil, fmt.Errorf("tables base %#x is not 16 byte aligned", base)
+	}
+	tabs = drop(tabs, "RSDT", "XSDT")
+	var linked int
+	for _, t := range tabs {
+		if s := t.Sig(); s != "DSDT" && s != "FACS" {
+			linked++
+		}
+	}
+
+	xsdtAddr := align(base+headerLength, 16)
+	rsdtAddr := align(xsdtAddr+headerLength+8*int64(linked), 16)
+	next := align(rsdtAddr+headerLength+4*int64(linked), 16)
+	addrs := make([]int64, len(tabs))
+	placed := make(map[string]int64)
+	for i, t := range tabs {
+		// The FACS must be 64 byte aligned.
+		if t.Sig() == "FACS" {
+			next = align(next, 64)
+		}
+		addrs[i] = next
+		placed[t.Sig()] = next
+		next = align(next+int64(t.Len()), 16)
+	}
+	if next > 1<<32 {
+		rsdtAddr = 0
+	}
+
+	var xsdt, rsdt bytes.Buffer
+	out := make([]Table, 0, len(tabs)+2)
+	for i, t := range tabs {
+		switch t.Sig() {
+		case "DSDT", "FACS":
+		case "FACP":
+			f, err := linkFADT(t, placed["DSDT"], placed["FACS"])
+			if err != nil {
+				return nil, err
+			}
+			t = f
+			fallthrough
+		default:
+			encode(&xsdt, uint64(addrs[i]))
+			encode(&rsdt, uint32(addrs[i]))
+		}
+		out = append(out, &Raw{addr: addrs[i], data: t.Data()})
+	}
+
+	h := Header{Revision: 1, OEMID: oemID, OEMTableID: "U-ROOT", CreatorID: "UROT", CreatorRevision: 1}
+	var sdts []Table
+	for _, sdt := range []struct {
+		sig  string
+		addr int64
+		data []byte
+	}{
+		{"XSDT", xsdtAddr, xsdt.Bytes()},
+		{"RSDT", rsdtAddr, rsdt.Bytes()},
+	} {
+		if sdt.addr == 0 {
+			continue
+		}
+		h.Signature = sdt.sig
+		t, err := NewTable(h, sdt.data)
+		if err != nil {
+			return nil, err
+		}
+		sdts = append(sdts, &Raw{addr: sdt.addr, data: t.Data()})
+	}
+	return &BiosTable{
+		RSDP:   newRSDP(base, oemID, uint32(rsdtAddr), uint64(xsdtAddr)),
+		Tables: append(sdts, out...),
+	}, nil
+}
+
+// linkFADT returns the FADT t pointing at the DSDT and FACS at the given
+// addresses, or nowhere for 0.
+func linkFADT(t Table, dsdt, facs int64) (Table, error) {
+	b := append([]byte{}, t.Data()...)
+	le := binary.LittleEndian
+	if len(b) < lDSDTAddr+4 {
+		return nil, fmt.Errorf("FADT: %d bytes is too short", len(b))
+	}
+	// With the 64-bit pointers, the 32-bit FACS pointer must be 0 and
+	// the 32-bit DSDT pointer is only for old OSes.
+	if len(b) >= llDSDTAddr+8 {
+		le.PutUint64(b[llFACSAddr:], uint64(facs))
+		le.PutUint64(b[llDSDTAddr:], uint64(dsdt))
+		facs = 0
+		if dsdt >= 1<<32 {
+			dsdt = 0
+		}
+	} else if facs >= 1<<32 || dsdt >= 1<<32 {
+		return nil, fmt.Errorf("FADT: no 64-bit pointers for the DSDT at %#x and FACS at %#x", dsdt, facs)
+	}
+	le.PutUint32(b[lFACSAddr:], uint32(facs))
+	le.PutUint32(b[lDSDTAddr:], uint32(dsdt))
+	b[cSUMOffset] = 0
+	b[cSUMOffset] = gencsum(b)
+	return &Raw{data: b}, nil
+}
+
+// Image returns the RSDP and the tables of a BiosTable made by
+// NewBiosTable as laid out in memory, from the RSDP's address.
+func (b *BiosTable) Image() ([]byte, error) {
+	base := b.RSDP.RSDPAddr()
+	img := append([]byte{}, b.RSDP.AllData()...)
+	for _, t := range b.Tables {
+		off := t.Address() - base
+		if off < int64(len(img)) {
+			return nil, fmt.Errorf("%s at %#x overlaps the tables before it", t.Sig(), t.Address())
+		}
+		img = append(img, make([]byte, off-int64(len(img)))...)
+		img = append(img, t.Data()...)
+	}
+	return img, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// Marshaler is implemented by the decoded tables that can be encoded
+// into a Table again, e.g. to patch a table: decode it, change it and
+// marshal it. The HEST is not, as its decoder leaves out the machine check
+// banks; it can be patched with NewTable.
+type Marshaler interface {
+	Marshal() (Table, error)
+}
+
+// Encode encodes v, a decoded table such as a *MADT, into a Table.
+func Encode(v interface{}) (Table, error) {
+	m, ok := v.(Marshaler)
+	if !ok {
+		return nil, fmt.Errorf("can not encode %T", v)
+	}
+	return m.Marshal()
+}
+
+// NewTable returns the table with header h followed by data. The length
+// and checksum of h are ignored and computed; the strings of h are padded
+// with spaces.
+func NewTable(h Header, data []byte) (Table, error) {
+	for _, f := range []struct {
+		name, s string
+		n       int
+	}{
+		{"signature", h.Signature, 4},
+		{"OEM ID", h.OEMID, 6},
+		{"OEM table ID", h.OEMTableID, 8},
+		{"creator ID", h.CreatorID, 4},
+	} {
+		if len(f.s) > f.n || (f.name == "signature" && len(f.s) != f.n) {
+			return nil, fmt.Errorf("%s %q is not %d characters", f.name, f.s, f.n)
+		}
+	}
+	b := make([]byte, headerLength, headerLength+len(data))
+	copy(b[0:4], h.Signature)
+	binary.LittleEndian.PutUint32(b[lengthOffset:], uint32(headerLength+len(data)))
+	b[8] = h.Revision
+	copy(b[10:16], fmt.Sprintf("%-6s", h.OEMID))
+	copy(b[16:24], fmt.Sprintf("%-8s", h.OEMTableID))
+	binary.LittleEndian.PutUint32(b[24:], h.OEMRevision)
+	copy(b[28:32], fmt.Sprintf("%-4s", h.CreatorID))
+	binary.LittleEndian.PutUint32(b[32:], h.CreatorRevision)
+	b = append(b, data...)
+	b[cSUMOffset] = gencsum(b)
+	return &Raw{data: b}, nil
+}
+
+// Checksum reports whether the bytes of t add up to 0, as they must.
+func Checksum(t Table) bool {
+	return gencsum(t.Data()) == 0
+}
+
+// encode appends the packed little endian struct v to b, with zero for
+// the blank (reserved) fields.
+func encode(b *bytes.Buffer, v interface{}) error {
+	return binary.Write(b, binary.LittleEndian, v)
+}
+
+// encodeFixed is encode for structs that end in slices or strings, which
+// are left out.
+func encodeFixed(b *bytes.Buffer, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Field(i)
+		if k := f.Kind(); k == reflect.Slice || k == reflect.String {
+			continue
+		}
+		if rv.Type().Field(i).Name == "_" {
+			f = reflect.Zero(f.Type())
+		}
+		if err := encode(b, f.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeEntry appends a subtable of type typ with the packed struct v,
+// or data for unknown entries, with a type and length of size bytes
+// each.
+func encodeEntry(b *bytes.Buffer, size int, typ uint16, v interface{}) error {
+	var e bytes.Buffer
+	if d, ok := v.([]byte); ok {
+		e.Write(d)
+	} else if err := encode(&e, v); err != nil {
+		return err
+	}
+	n := e.Len() + 2*size
+	if size == 1 {
+		if n > 0xff {
+			return fmt.Errorf("subtable type %d: %d bytes is too long", typ, n)
+		}
+		b.Write([]byte{uint8(typ), uint8(n)})
+	} else {
+		encode(b, []uint16{typ, uint16(n)})
+	}
+	b.Write(e.Bytes())
+	return nil
+}
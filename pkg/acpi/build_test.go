@@ -0,0 +1,363 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/cpio"
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+func TestMarshal(t *testing.T) {
+	for _, platform := range platforms {
+		for sig, tab := range readTables(t, platform) {
+			v, err := Decode(tab)
+			if err != nil {
+				t.Fatalf("%s %s: %v", platform, sig, err)
+			}
+			n, err := Encode(v)
+			if sig == "HEST" {
+				if err == nil {
+					t.Errorf("%s HEST: Encode succeeded, want error", platform)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s %s: %v", platform, sig, err)
+				continue
+			}
+			if !bytes.Equal(n.Data(), tab.Data()) {
+				t.Errorf("%s %s: encoded\n%x\nwant\n%x", platform, sig, n.Data(), tab.Data())
+			}
+		}
+	}
+}
+
+func TestNewTable(t *testing.T) {
+	h := Header{Signature: "SSDT", Revision: 2, OEMID: "UROOT", OEMTableID: "PATCH", OEMRevision: 3, CreatorID: "INTL", CreatorRevision: 0x20200925}
+	tab, err := NewTable(h, []byte{0x10, 0x05, 0x5c, 0x5f, 0x53, 0x42, 0x5f})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Checksum(tab) {
+		t.Errorf("checksum of %x is not 0", tab.Data())
+	}
+	got, err := NewHeader(tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Length, h.Checksum = headerLength+7, tab.CheckSum()
+	if got != h {
+		t.Errorf("header %+v, want %+v", got, h)
+	}
+	if !bytes.Equal(tab.Data()[10:24], []byte("UROOT PATCH   ")) {
+		t.Errorf("OEM IDs %q are not space padded", tab.Data()[10:24])
+	}
+
+	for _, h := range []Header{
+		{Signature: "SSD"},
+		{Signature: "SSDT", OEMID: "TOOLONG"},
+		{Signature: "SSDT", OEMTableID: "MUCHTOOLONG"},
+		{Signature: "SSDT", CreatorID: "ABCDE"},
+	} {
+		if _, err := NewTable(h, nil); err == nil {
+			t.Errorf("NewTable(%+v) succeeded, want error", h)
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	// Drop the RMRR of a USB controller and move a DRHD.
+	d, err := NewDMAR(readTables(t, "intel")["DMAR"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rmrr := d.Entries[2].(*RMRR)
+	rmrr.Scopes = rmrr.Scopes[1:]
+	d.DRHDs()[0].RegisterBaseAddress = 0xd37fe000
+	d.OEMRevision++
+	tab, err := d.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Checksum(tab) {
+		t.Errorf("patched DMAR checksum is wrong")
+	}
+	got, err := NewDMAR(tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Length, d.Checksum = tab.Len(), tab.CheckSum()
+	if !reflect.DeepEqual(got, d) {
+		t.Errorf("patched DMAR decodes to %+v, want %+v", got, d)
+	}
+	if d.Length != 231-8 {
+		t.Errorf("patched DMAR is %d bytes, want %d", d.Length, 231-8)
+	}
+
+	// Entries too long for a one byte length.
+	m := &MADT{Header: Header{Signature: "APIC"}, Entries: []MADTEntry{&UnknownMADTEntry{Type: 0x80, Data: make([]byte, 300)}}}
+	if _, err := m.Marshal(); err == nil {
+		t.Errorf("MADT with a 302 byte entry marshaled, want error")
+	}
+	s := &SLIT{Header: Header{Signature: "SLIT"}, Distances: [][]int{{10, 20}, {20}}}
+	if _, err := s.Marshal(); err == nil {
+		t.Errorf("SLIT with a short row marshaled, want error")
+	}
+}
+
+func TestOverride(t *testing.T) {
+	ts, err := RawFromName("testdata/firecracker.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHeader(ts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcfg, err := NewTable(h, ts[2].TableData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.OEMID = "UROOT"
+	other, err := NewTable(h, ts[2].TableData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssdt, err := NewTable(Header{Signature: "SSDT", OEMID: "UROOT", OEMTableID: "EXTRA", CreatorID: "UROT"}, []byte{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An MCFG of another OEM does not replace the firmware's.
+	got := Override(ts, mcfg, other, ssdt)
+	want := []Table{ts[0], ts[1], mcfg, other, ssdt}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Override = %v, want %v", got, want)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	ts, err := RawFromName("testdata/firecracker.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apic := ts[0]
+	h, err := NewHeader(ts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.OEMRevision = 7
+	facp, err := NewTable(h, ts[1].TableData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssdt, err := NewTable(Header{Signature: "SSDT", OEMID: "UROOT", OEMTableID: "EXTRA"}, []byte{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dmar, err := NewTable(Header{Signature: "DMAR", OEMID: "UROOT", OEMTableID: "EXTRA"}, make([]byte, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Upgrade(ts, apic, facp, ssdt, dmar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("Upgrade returned %d tables, want 4", len(got))
+	}
+	// The firmware's APIC has OEM revision 0, the copy is raised to 1
+	// without changing anything else but the checksum.
+	if r := got[0].OEMRevision(); r != apic.OEMRevision()+1 {
+		t.Errorf("upgraded APIC has OEM revision %#x, want %#x", r, apic.OEMRevision()+1)
+	}
+	if !Checksum(got[0]) || got[0].OEMID() != apic.OEMID() || got[0].OEMTableID() != apic.OEMTableID() ||
+		!bytes.Equal(got[0].TableData(), apic.TableData()) {
+		t.Errorf("upgraded APIC %v changed more than its OEM revision", got[0])
+	}
+	for i, want := range []Table{facp, ssdt, dmar} {
+		if got[i+1] != want {
+			t.Errorf("Upgrade changed %v to %v, want it unchanged", want, got[i+1])
+		}
+	}
+
+	xsdt, err := NewTable(Header{Signature: "XSDT", OEMID: "UROOT", OEMTableID: "EXTRA"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Upgrade(ts, xsdt); err == nil {
+		t.Errorf("Upgrade of an XSDT that replaces nothing succeeded, want error")
+	}
+	h.OEMRevision = math.MaxUint32
+	top, err := NewTable(h, ts[1].TableData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Upgrade([]Table{top}, ts[1]); err == nil {
+		t.Errorf("Upgrade over OEM revision %#x succeeded, want error", uint32(math.MaxUint32))
+	}
+}
+
+func TestWriteInitrd(t *testing.T) {
+	ts, err := RawFromName("testdata/firecracker.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := WriteInitrd(&b, ts[0], ts[2]); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := cpio.ReadAllRecords(cpio.Newc.Reader(bytes.NewReader(b.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range recs {
+		names = append(names, r.Name)
+	}
+	if got, want := strings.Join(names, " "), "kernel kernel/firmware kernel/firmware/acpi kernel/firmware/acpi/00APIC.aml kernel/firmware/acpi/01MCFG.aml"; got != want {
+		t.Fatalf("initrd files %s, want %s", got, want)
+	}
+	for i, tab := range []Table{ts[0], ts[2]} {
+		d, err := uio.ReadAll(recs[3+i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(d, tab.Data()) {
+			t.Errorf("%s: initrd has %x, want %x", names[3+i], d, tab.Data())
+		}
+	}
+
+	bad := append([]byte{}, ts[0].Data()...)
+	bad[40]++
+	if err := WriteInitrd(ioutil.Discard, &Raw{data: bad}); err == nil {
+		t.Errorf("WriteInitrd of a table with a bad checksum succeeded, want error")
+	}
+}
+
+func TestNewBiosTable(t *testing.T) {
+	ts, err := RawFromName("testdata/firecracker.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Header{Signature: "DSDT", Revision: 2, OEMID: "FIRECK", OEMTableID: "FCVMDSDT", CreatorID: "FCAT"}
+	dsdt, err := NewTable(h, []byte{0x10, 0x05, 0x5c, 0x5f, 0x53, 0x42, 0x5f})
+	if err != nil {
+		t.Fatal(err)
+	}
+	facs := make([]byte, 64)
+	copy(facs, "FACS")
+	facs[4] = 64
+	xsdt, err := NewTable(Header{Signature: "XSDT"}, make([]byte, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tabs := []Table{xsdt, ts[0], dsdt, &Raw{data: facs}, ts[1], ts[2]}
+
+	for _, tt := range []struct {
+		base int64
+		rsdt bool
+	}{
+		{0xe0000, true},
+		{0x100000000, false},
+	} {
+		b, err := NewBiosTable(tt.base, "UROOT", tabs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := b.Image()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// table returns the table at addr in img.
+		table := func(addr int64) Table {
+			if addr < tt.base || addr >= tt.base+int64(len(img)) {
+				t.Fatalf("table address %#x is not in the image at %#x", addr, tt.base)
+			}
+			b := img[addr-tt.base:]
+			ts, err := NewRaw(b[:binary.LittleEndian.Uint32(b[4:])])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ts[0]
+		}
+
+		r := img[:headerLength]
+		if string(r[:8]) != "RSD PTR " || gencsum(r[:20]) != 0 || gencsum(r) != 0 {
+			t.Errorf("base %#x: bad RSDP %q", tt.base, r)
+		}
+		var addrs []int64
+		for _, sdt := range []struct {
+			sig  string
+			addr int64
+		}{
+			{"XSDT", int64(binary.LittleEndian.Uint64(r[24:]))},
+			{"RSDT", int64(binary.LittleEndian.Uint32(r[16:]))},
+		} {
+			if sdt.sig == "RSDT" && !tt.rsdt {
+				if sdt.addr != 0 {
+					t.Errorf("base %#x: RSDT at %#x, want none", tt.base, sdt.addr)
+				}
+				continue
+			}
+			x := table(sdt.addr)
+			if x.Sig() != sdt.sig || !Checksum(x) {
+				t.Fatalf("base %#x: %s at %#x is %s", tt.base, sdt.sig, sdt.addr, String(x))
+			}
+			s, err := NewSDT(x, sdt.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addrs != nil && !reflect.DeepEqual(s.Addrs, addrs) {
+				t.Errorf("base %#x: RSDT has %#x, XSDT %#x", tt.base, s.Addrs, addrs)
+			}
+			addrs = s.Addrs
+		}
+
+		var sigs []string
+		for _, a := range addrs {
+			tab := table(a)
+			sigs = append(sigs, tab.Sig())
+			if !Checksum(tab) {
+				t.Errorf("base %#x: %s checksum is wrong", tt.base, tab.Sig())
+			}
+			if tab.Sig() != "FACP" {
+				if !bytes.Equal(tab.Data(), readTables(t, "firecracker")[tab.Sig()].Data()) {
+					t.Errorf("base %#x: %s changed", tt.base, tab.Sig())
+				}
+				continue
+			}
+			f, err := NewFADT(tab)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := table(int64(f.DSDTAddress())); !bytes.Equal(d.Data(), dsdt.Data()) {
+				t.Errorf("base %#x: FADT points at %s, not the DSDT", tt.base, String(d))
+			}
+			if f.FirmwareCtrl != 0 || f.XFirmwareCtrl%64 != 0 || table(int64(f.XFirmwareCtrl)).Sig() != "FACS" {
+				t.Errorf("base %#x: FADT FACS pointers %#x, %#x", tt.base, f.FirmwareCtrl, f.XFirmwareCtrl)
+			}
+			if (f.DSDT != 0) != tt.rsdt {
+				t.Errorf("base %#x: FADT 32-bit DSDT pointer %#x", tt.base, f.DSDT)
+			}
+		}
+		if strings.Join(sigs, " ") != "APIC FACP MCFG" {
+			t.Errorf("base %#x: XSDT points at %v, want APIC, FACP and MCFG", tt.base, sigs)
+		}
+	}
+
+	if _, err := NewBiosTable(0xe0008, "UROOT", tabs); err == nil {
+		t.Errorf("NewBiosTable at an unaligned address succeeded, want error")
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+// Decoders decode tables into Go structs, by signature.
+var Decoders = map[string]func(Table) (interface{}, error){
+	"APIC": func(t Table) (interface{}, error) { return NewMADT(t) },
+	"BERT": func(t Table) (interface{}, error) { return NewBERT(t) },
+	"DMAR": func(t Table) (interface{}, error) { return NewDMAR(t) },
+	"FACP": func(t Table) (interface{}, error) { return NewFADT(t) },
+	"HEST": func(t Table) (interface{}, error) { return NewHEST(t) },
+	"HPET": func(t Table) (interface{}, error) { return NewHPET(t) },
+	"MCFG": func(t Table) (interface{}, error) { return NewMCFG(t) },
+	"SLIT": func(t Table) (interface{}, error) { return NewSLIT(t) },
+	"SRAT": func(t Table) (interface{}, error) { return NewSRAT(t) },
+}
+
+// Decode decodes t with its decoder, e.g. into a *MADT for an "APIC"
+// table. Tables without a decoder decode into their *Header.
+func Decode(t Table) (interface{}, error) {
+	if d, ok := Decoders[t.Sig()]; ok {
+		return d(t)
+	}
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
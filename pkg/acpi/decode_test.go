@@ -0,0 +1,533 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// platforms are the table sets the tests decode: firecracker's were read
+// from /sys/firmware/acpi/tables of a Firecracker VM into
+// testdata/firecracker.bin, the others are in specTables.
+var platforms = []string{"firecracker", "qemu", "intel", "arm"}
+
+func readTables(t *testing.T, platform string) map[string]Table {
+	var ts []Table
+	var err error
+	if gen, ok := specTables[platform]; ok {
+		ts, err = NewRaw(gen())
+	} else {
+		ts, err = RawFromName("testdata/" + platform + ".bin")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]Table)
+	for _, tab := range ts {
+		m[tab.Sig()] = tab
+	}
+	return m
+}
+
+// table returns a table made of a header with signature sig and data.
+func table(t *testing.T, sig string, data []byte) Table {
+	b := make([]byte, headerLength, headerLength+len(data))
+	copy(b, sig)
+	b = append(b, data...)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+	tabs, err := NewRaw(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tabs[0]
+}
+
+func TestDecodeAll(t *testing.T) {
+	for _, platform := range platforms {
+		for sig, tab := range readTables(t, platform) {
+			if _, ok := Decoders[sig]; !ok {
+				t.Errorf("%s %s: no decoder", platform, sig)
+			}
+			v, err := Decode(tab)
+			if err != nil {
+				t.Errorf("%s %s: %v", platform, sig, err)
+				continue
+			}
+			s, ok := v.(interface{ String() string })
+			if !ok || !strings.HasPrefix(s.String(), sig+" rev ") {
+				t.Errorf("%s %s: String() does not start with the header", platform, sig)
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				t.Errorf("%s %s: %v", platform, sig, err)
+				continue
+			}
+			var m map[string]interface{}
+			if err := json.Unmarshal(b, &m); err != nil || m["Signature"] != sig {
+				t.Errorf("%s %s: JSON %s has no signature", platform, sig, b)
+			}
+		}
+	}
+
+	v, err := Decode(table(t, "SSDT", []byte{0x10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := v.(*Header); !ok || h.Signature != "SSDT" || h.Length != headerLength+1 {
+		t.Errorf("Decode(SSDT) = %v, want its header", v)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for sig := range Decoders {
+		// Empty tables must not crash the decoders. Some, like a
+		// MADT without entries, are still valid.
+		Decode(table(t, sig, nil))
+	}
+	for _, tt := range []struct {
+		name string
+		tab  Table
+		err  string
+	}{
+		{"truncated MCFG", table(t, "MCFG", make([]byte, 8+ecamWindowSize+1)), "1 trailing bytes"},
+		{"MADT entry past the end", table(t, "APIC", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 0}), "bad length 8"},
+		{"zero length MADT entry", table(t, "APIC", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), "bad length 0"},
+		{"short DMAR", table(t, "DMAR", []byte{45}), "not a DMAR"},
+		{"truncated HEST source", table(t, "HEST", []byte{1, 0, 0, 0, 9, 0, 0, 0}), "truncated error source"},
+		{"unknown HEST source", table(t, "HEST", append([]byte{1, 0, 0, 0, 0x30}, make([]byte, 19)...)), "type 48"},
+	} {
+		if _, err := Decode(tt.tab); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want error containing %q", tt.name, err, tt.err)
+		}
+	}
+	if _, err := NewMCFG(table(t, "APIC", make([]byte, 8))); err == nil || !strings.Contains(err.Error(), "not a MCFG") {
+		t.Errorf("NewMCFG(APIC) = %v, want error", err)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	h, err := NewHeader(readTables(t, "firecracker")["APIC"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Header{
+		Signature:       "APIC",
+		Length:          64,
+		Revision:        6,
+		Checksum:        0x69,
+		OEMID:           "FIRECK",
+		OEMTableID:      "FCVMMADT",
+		CreatorID:       "FCAT",
+		CreatorRevision: 0x20240119,
+	}
+	if h != want {
+		t.Errorf("NewHeader = %+v, want %+v", h, want)
+	}
+	if s := h.String(); s != `APIC rev 6, 64 bytes, OEM "FIRECK" "FCVMMADT" rev 0x0, creator "FCAT" rev 0x20240119` {
+		t.Errorf("String() = %s", s)
+	}
+}
+
+func TestMADT(t *testing.T) {
+	for _, tt := range []struct {
+		platform string
+		entries  int
+		cpus     []CPU
+	}{
+		{
+			platform: "firecracker",
+			entries:  2,
+			cpus:     []CPU{{UID: 0, ID: 0, Enabled: true}},
+		},
+		{
+			platform: "qemu",
+			entries:  10,
+			cpus:     []CPU{{UID: 0, ID: 0, Enabled: true}, {UID: 1, ID: 1, Enabled: true}, {UID: 2, ID: 2}},
+		},
+		{
+			platform: "intel",
+			entries:  9,
+			cpus: []CPU{
+				{UID: 0, ID: 0, Enabled: true},
+				{UID: 1, ID: 0x40, Enabled: true},
+				{UID: 2, ID: 0x80, Enabled: true},
+				{UID: 3, ID: 0xc0, OnlineCapable: true},
+			},
+		},
+		{
+			platform: "arm",
+			entries:  8,
+			cpus: []CPU{
+				{UID: 0, ID: 0, Enabled: true},
+				{UID: 1, ID: 0x100, Enabled: true},
+				{UID: 2, ID: 0x10000, Enabled: true},
+				{UID: 3, ID: 0x10100, Enabled: true},
+			},
+		},
+	} {
+		m, err := NewMADT(readTables(t, tt.platform)["APIC"])
+		if err != nil {
+			t.Errorf("%s: %v", tt.platform, err)
+			continue
+		}
+		if len(m.Entries) != tt.entries {
+			t.Errorf("%s: got %d entries, want %d", tt.platform, len(m.Entries), tt.entries)
+		}
+		if cpus := m.CPUs(); !reflect.DeepEqual(cpus, tt.cpus) {
+			t.Errorf("%s: CPUs() = %+v, want %+v", tt.platform, cpus, tt.cpus)
+		}
+	}
+
+	m, err := NewMADT(readTables(t, "intel")["APIC"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MADTEntry{
+		&IOAPIC{ID: 8, Address: 0xfec00000},
+		&IOAPIC{ID: 9, Address: 0xfec01000, GSIBase: 24},
+		&InterruptOverride{GSI: 2},
+		&InterruptOverride{Source: 9, GSI: 9, Flags: 0xd},
+		&LocalX2APICNMI{Flags: 5, ProcessorUID: 0xffffffff, LINT: 1},
+	}
+	if !reflect.DeepEqual(m.Entries[4:], want) {
+		t.Errorf("entries %v, want %v", m.Entries[4:], want)
+	}
+
+	m, err = NewMADT(readTables(t, "arm")["APIC"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := m.Entries[4].(*GICD); !ok || d.GICVersion != 3 || d.PhysicalBaseAddress != 0x100100140000 {
+		t.Errorf("entry 4 = %+v, want the GICv3 distributor", m.Entries[4])
+	}
+	if u, ok := m.Entries[7].(*UnknownMADTEntry); !ok || u.Type != 0x11 || u.MADTType() != 0x11 || len(u.Data) != 14 {
+		t.Errorf("entry 7 = %+v, want an unknown entry of type 0x11", m.Entries[7])
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `{"Type":"GICR","Entry":{"DiscoveryRangeBaseAddress":17596482322432,"DiscoveryRangeLength":16777216}}`) {
+		t.Errorf("JSON %s does not name the GICR entry", b)
+	}
+}
+
+func TestFADT(t *testing.T) {
+	if n := binary.Size(FADTFields{}); n != 276-headerLength {
+		t.Errorf("FADTFields are %d bytes, want %d", n, 276-headerLength)
+	}
+
+	f, err := NewFADT(readTables(t, "firecracker")["FACP"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.HWReduced() || f.DSDTAddress() != 0x9fd30 || f.FACSAddress() != 0 || f.MinorVersion != 5 ||
+		f.IAPCBootArch != 4 || f.HypervisorVendorID != 0x4d564b4345524946 {
+		t.Errorf("FADT = %+v", f)
+	}
+	if s := f.String(); !strings.Contains(s, "\nFlags: 0x100030 PWR_BUTTON|SLP_BUTTON|HW_REDUCED_ACPI\n") ||
+		!strings.Contains(s, "\nIA-PC boot arch: 0x4 VGA_NOT_PRESENT\n") {
+		t.Errorf("String() = %s", s)
+	}
+
+	f, err = NewFADT(readTables(t, "arm")["FACP"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.HWReduced() || f.ARMBootArch != 1 || f.DSDTAddress() != 0x8000000 || f.Revision != 6 || f.MinorVersion != 3 {
+		t.Errorf("FADT = %+v", f)
+	}
+
+	// An ACPI 1.0 FADT is 116 bytes long, with 32-bit addresses only.
+	b := make([]byte, 116-headerLength)
+	binary.LittleEndian.PutUint32(b[0:], 0x6000)
+	binary.LittleEndian.PutUint32(b[4:], 0x7000)
+	f, err = NewFADT(table(t, "FACP", b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.FACSAddress() != 0x6000 || f.DSDTAddress() != 0x7000 || f.XDSDT != 0 {
+		t.Errorf("ACPI 1.0 FADT = %+v", f)
+	}
+}
+
+func TestMCFG(t *testing.T) {
+	for _, tt := range []struct {
+		platform string
+		windows  []ECAMWindow
+		str      string
+	}{
+		{
+			platform: "firecracker",
+			windows:  []ECAMWindow{{BaseAddress: 0xeec00000}},
+			str:      "Segment 0000 buses 00-00: 0xeec00000-0xeecfffff\n",
+		},
+		{
+			platform: "qemu",
+			windows:  []ECAMWindow{{BaseAddress: 0xb0000000, EndBus: 0xff}},
+			str:      "Segment 0000 buses 00-ff: 0xb0000000-0xbfffffff\n",
+		},
+		{
+			platform: "arm",
+			windows: []ECAMWindow{
+				{BaseAddress: 0x33fff0000000, EndBus: 0xff},
+				{BaseAddress: 0x37fff0000000, Segment: 1, EndBus: 0xff},
+			},
+			str: "Segment 0000 buses 00-ff: 0x33fff0000000-0x33ffffffffff\nSegment 0001 buses 00-ff: 0x37fff0000000-0x37ffffffffff\n",
+		},
+	} {
+		m, err := NewMCFG(readTables(t, tt.platform)["MCFG"])
+		if err != nil {
+			t.Errorf("%s: %v", tt.platform, err)
+			continue
+		}
+		if !reflect.DeepEqual(m.Windows, tt.windows) {
+			t.Errorf("%s: windows %+v, want %+v", tt.platform, m.Windows, tt.windows)
+		}
+		if s := m.String(); !strings.HasSuffix(s, "\n"+tt.str) {
+			t.Errorf("%s: String() = %s, want it to end with %s", tt.platform, s, tt.str)
+		}
+	}
+
+	w := ECAMWindow{BaseAddress: 0xe0000000, StartBus: 0x10, EndBus: 0x1f}
+	if s := w.Size(); s != 16<<20 {
+		t.Errorf("Size() = %#x, want %#x", s, 16<<20)
+	}
+	for _, tt := range []struct {
+		bus, dev, fn uint8
+		addr         uint64
+		ok           bool
+	}{
+		{0x10, 0, 0, 0xe1000000, true},
+		{0x12, 3, 1, 0xe1219000, true},
+		{0x1f, 31, 7, 0xe1fff000, true},
+		{0x0f, 0, 0, 0, false},
+		{0x20, 0, 0, 0, false},
+		{0x10, 32, 0, 0, false},
+		{0x10, 0, 8, 0, false},
+	} {
+		if a, ok := w.Address(tt.bus, tt.dev, tt.fn); a != tt.addr || ok != tt.ok {
+			t.Errorf("Address(%#x, %d, %d) = %#x, %t, want %#x, %t", tt.bus, tt.dev, tt.fn, a, ok, tt.addr, tt.ok)
+		}
+	}
+}
+
+func TestHPET(t *testing.T) {
+	p, err := NewHPET(readTables(t, "qemu")["HPET"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.VendorID() != 0x8086 || p.Comparators() != 3 || !p.Counter64() || !p.LegacyReplacement() {
+		t.Errorf("HPET capabilities %#x: vendor %#x, %d comparators", p.EventTimerBlockID, p.VendorID(), p.Comparators())
+	}
+	if want := (GAS{Address: 0xfed00000}); p.BaseAddress != want {
+		t.Errorf("BaseAddress = %v, want %v", p.BaseAddress, want)
+	}
+}
+
+func TestSRAT(t *testing.T) {
+	for _, tt := range []struct {
+		platform string
+		nodes    []*Node
+	}{
+		{
+			platform: "qemu",
+			nodes: []*Node{
+				{Domain: 0, CPUs: []uint32{0}, Memory: []MemoryAffinity{
+					{BaseAddress: 0, Length: 0xa0000, Flags: 1},
+					{BaseAddress: 0x100000, Length: 0x3ff00000, Flags: 1},
+				}},
+				{Domain: 1, CPUs: []uint32{1}, Memory: []MemoryAffinity{
+					{ProximityDomain: 1, BaseAddress: 0x40000000, Length: 0x40000000, Flags: 1},
+					{ProximityDomain: 1, BaseAddress: 0x100000000, Length: 0x40000000, Flags: 3},
+				}},
+			},
+		},
+		{
+			platform: "intel",
+			nodes: []*Node{
+				{Domain: 0, CPUs: []uint32{0, 0x40}, Memory: []MemoryAffinity{
+					{BaseAddress: 0, Length: 0x80000000, Flags: 1},
+				}},
+				{Domain: 1, CPUs: []uint32{0x80}, Memory: []MemoryAffinity{
+					{ProximityDomain: 1, BaseAddress: 0x80000000, Length: 0x80000000, Flags: 1},
+					{ProximityDomain: 1, BaseAddress: 0x200000000, Length: 0x100000000, Flags: 5},
+				}},
+			},
+		},
+		{
+			platform: "arm",
+			nodes: []*Node{
+				{Domain: 0, CPUs: []uint32{0, 1}, Memory: []MemoryAffinity{
+					{BaseAddress: 0x88300000, Length: 0x3c000000, Flags: 1},
+				}},
+				{Domain: 1, CPUs: []uint32{2, 3}, Memory: []MemoryAffinity{
+					{ProximityDomain: 1, BaseAddress: 0x80000000000, Length: 0x80000000, Flags: 1},
+				}},
+			},
+		},
+	} {
+		s, err := NewSRAT(readTables(t, tt.platform)["SRAT"])
+		if err != nil {
+			t.Errorf("%s: %v", tt.platform, err)
+			continue
+		}
+		if n := s.Nodes(); !reflect.DeepEqual(n, tt.nodes) {
+			t.Errorf("%s: Nodes() = %+v, want %+v", tt.platform, n, tt.nodes)
+		}
+	}
+
+	s, err := NewSRAT(readTables(t, "arm")["SRAT"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := s.Entries[6].(*GICITSAffinity); !ok || e.SRATType() != 4 || !e.Enabled() {
+		t.Errorf("entry 6 = %+v, want an ITS affinity", s.Entries[6])
+	}
+}
+
+func TestSLIT(t *testing.T) {
+	for _, tt := range []struct {
+		platform string
+		want     [][]int
+	}{
+		{"qemu", [][]int{{10, 20}, {20, 10}}},
+		{"intel", [][]int{{10, 21}, {21, 10}}},
+	} {
+		s, err := NewSLIT(readTables(t, tt.platform)["SLIT"])
+		if err != nil {
+			t.Errorf("%s: %v", tt.platform, err)
+			continue
+		}
+		if !reflect.DeepEqual(s.Distances, tt.want) {
+			t.Errorf("%s: distances %v, want %v", tt.platform, s.Distances, tt.want)
+		}
+	}
+	// 2 localities need 4 distances.
+	if _, err := NewSLIT(table(t, "SLIT", []byte{2, 0, 0, 0, 0, 0, 0, 0, 10, 20, 20})); err == nil {
+		t.Errorf("NewSLIT of a truncated matrix succeeded, want error")
+	}
+}
+
+func TestDMAR(t *testing.T) {
+	d, err := NewDMAR(readTables(t, "intel")["DMAR"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.HostAddressWidth != 46 || d.Flags != 5 {
+		t.Errorf("width %d, flags %#x, want 46, 0x5", d.HostAddressWidth, d.Flags)
+	}
+	want := []DMAREntry{
+		&DRHD{RegisterBaseAddress: 0xd37fc000, Scopes: []DeviceScope{
+			{Type: 1, StartBus: 0x16, Path: []PCIPath{{5, 0}}},
+			{Type: 2, StartBus: 0x16, Path: []PCIPath{{2, 0}}},
+		}},
+		&DRHD{Flags: 1, RegisterBaseAddress: 0xfbffc000, Scopes: []DeviceScope{
+			{Type: 3, EnumerationID: 8, Path: []PCIPath{{30, 7}}},
+			{Type: 4, Path: []PCIPath{{30, 6}}},
+		}},
+		&RMRR{BaseAddress: 0x6e6b6000, LimitAddress: 0x6e6b8fff, Scopes: []DeviceScope{
+			{Type: 1, Path: []PCIPath{{20, 0}}},
+			{Type: 1, Path: []PCIPath{{26, 0}}},
+		}},
+		&ATSR{Scopes: []DeviceScope{{Type: 2, StartBus: 0x16, Path: []PCIPath{{2, 0}}}}},
+		&RHSA{RegisterBaseAddress: 0xd37fc000},
+		&RHSA{RegisterBaseAddress: 0xfbffc000, ProximityDomain: 1},
+		&ANDD{DeviceNumber: 1, ObjectName: `\_SB.PC00.UA00`},
+	}
+	if !reflect.DeepEqual(d.Entries, want) {
+		t.Errorf("entries %+v, want %+v", d.Entries, want)
+	}
+	if u := d.DRHDs(); len(u) != 2 || u[1] != d.Entries[1] {
+		t.Errorf("DRHDs() = %v, want entries 0 and 1", u)
+	}
+	if s := d.String(); !strings.Contains(s, "\n  IOAPIC 8 at 00:1e.7\n") {
+		t.Errorf("String() = %s", s)
+	}
+}
+
+func TestHEST(t *testing.T) {
+	h, err := NewHEST(readTables(t, "intel")["HEST"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []uint16
+	for _, s := range h.Sources {
+		types = append(types, s.Type)
+	}
+	if want := []uint16{HESTMachineCheck, HESTCorrectedMachineCheck, HESTAERRootPort, HESTAEREndpoint, HESTGHES, HESTGHESv2}; !reflect.DeepEqual(types, want) {
+		t.Errorf("source types %v, want %v", types, want)
+	}
+	if s := h.Sources[0]; s.Banks != 2 || s.Notification != nil {
+		t.Errorf("machine check source %+v, want 2 banks", s)
+	}
+	if s := h.Sources[2]; s.AER == nil || s.AER.RootErrorCommand != 7 {
+		t.Errorf("root port source %+v, want its root error command", s)
+	}
+	ghes := h.Sources[5]
+	if ghes.Notification == nil || ghes.Notification.Type != 4 || ghes.ErrorStatusAddress == nil ||
+		ghes.ErrorStatusAddress.Address != 0x6f7f1008 || ghes.ReadAckRegister == nil || ghes.ReadAckWrite != 1 {
+		t.Errorf("GHESv2 source %+v", ghes)
+	}
+	if s := h.String(); !strings.Contains(s, "\nSource 4: Generic Hardware Error Source, enabled true, flags 0x0, notify SCI, status at SystemMemory:0x6f7f1000/64\n") {
+		t.Errorf("String() = %s", s)
+	}
+}
+
+func TestBERT(t *testing.T) {
+	b, err := NewBERT(readTables(t, "intel")["BERT"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.RegionLength != 4096 || b.RegionAddress != 0x6f7f0000 {
+		t.Errorf("BERT region %d bytes at %#x, want 4096 at 0x6f7f0000", b.RegionLength, b.RegionAddress)
+	}
+}
+
+func TestErrorStatusBlock(t *testing.T) {
+	// A fatal block with one revision 3 memory error section with a
+	// timestamp and FRU text, and 4 bytes of raw data.
+	d := make([]byte, 72+80)
+	copy(d, []byte{0x14, 0x11, 0xbc, 0xa5, 0x64, 0x6f, 0xde, 0x4e, 0xb8, 0x63, 0x3e, 0x83, 0xed, 0x7c, 0x83, 0xb1})
+	binary.LittleEndian.PutUint32(d[16:], 1)
+	binary.LittleEndian.PutUint16(d[20:], 0x300)
+	d[22] = 6
+	binary.LittleEndian.PutUint32(d[24:], 80)
+	copy(d[44:], "DIMM_A1")
+	binary.LittleEndian.PutUint64(d[64:], 0x20201231)
+
+	b := make([]byte, 20, 20+len(d)+4)
+	binary.LittleEndian.PutUint32(b[0:], 2|1<<4)
+	binary.LittleEndian.PutUint32(b[4:], uint32(20+len(d)))
+	binary.LittleEndian.PutUint32(b[8:], 4)
+	binary.LittleEndian.PutUint32(b[12:], uint32(len(d)))
+	binary.LittleEndian.PutUint32(b[16:], 1)
+	b = append(append(b, d...), 1, 2, 3, 4)
+
+	s, err := NewErrorStatusBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Entries) != 1 || !reflect.DeepEqual(s.RawData, []byte{1, 2, 3, 4}) {
+		t.Fatalf("NewErrorStatusBlock = %+v", s)
+	}
+	e := s.Entries[0]
+	if e.SectionType != "a5bc1114-6f64-4ede-b863-3e83ed7c83b1" || e.FRUText != "DIMM_A1" || e.Timestamp != 0x20201231 || len(e.Data) != 80 {
+		t.Errorf("section %+v", e)
+	}
+	if got, want := s.String(), "Error status 0x12, fatal, 1 sections\n  Platform Memory: fatal, 80 bytes, FRU \"DIMM_A1\"\n"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// The section claims more data than the block has.
+	binary.LittleEndian.PutUint32(b[20+24:], 81)
+	if _, err := NewErrorStatusBlock(b); err == nil {
+		t.Errorf("NewErrorStatusBlock of a truncated section succeeded, want error")
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DMAR is Intel's DMA Remapping table, which describes the IOMMUs (VT-d
+// remapping hardware units) and the devices behind them.
+type DMAR struct {
+	Header
+
+	// HostAddressWidth is the DMA address width in bits.
+	HostAddressWidth int
+
+	// Flags are INTR_REMAP (bit 0), X2APIC_OPT_OUT (bit 1) and
+	// DMA_CTRL_PLATFORM_OPT_IN_FLAG (bit 2).
+	Flags uint8
+
+	Entries []DMAREntry
+}
+
+// DMAREntry is a remapping structure of the DMAR.
+type DMAREntry interface {
+	DMARType() uint16
+}
+
+// Device scope types.
+const (
+	ScopePCIEndpoint     = 1
+	ScopePCISubHierarchy = 2
+	ScopeIOAPIC          = 3
+	ScopeHPET            = 4
+	ScopeNamespaceDevice = 5
+)
+
+var scopeNames = map[uint8]string{
+	ScopePCIEndpoint:     "PCI endpoint",
+	ScopePCISubHierarchy: "PCI sub-hierarchy",
+	ScopeIOAPIC:          "IOAPIC",
+	ScopeHPET:            "HPET",
+	ScopeNamespaceDevice: "ACPI namespace device",
+}
+
+// PCIPath is a hop from a bus to a device on it.
+type PCIPath struct {
+	Device   uint8
+	Function uint8
+}
+
+// DeviceScope is a device, or hierarchy of devices, a remapping
+// structure applies to.
+type DeviceScope struct {
+	Type  uint8
+	Flags uint8
+
+	// EnumerationID is the IOAPIC or HPET ID, or ACPI device number.
+	EnumerationID uint8
+
+	StartBus uint8
+
+	// Path leads from StartBus through bridges to the device.
+	Path []PCIPath
+}
+
+// String prints s as its type and bus:device.function path.
+func (s DeviceScope) String() string {
+	t, ok := scopeNames[s.Type]
+	if !ok {
+		t = fmt.Sprintf("type %d", s.Type)
+	}
+	p := fmt.Sprintf("%02x", s.StartBus)
+	for _, h := range s.Path {
+		p += fmt.Sprintf(":%02x.%d", h.Device, h.Function)
+	}
+	if s.Type == ScopeIOAPIC || s.Type == ScopeHPET || s.Type == ScopeNamespaceDevice {
+		return fmt.Sprintf("%s %d at %s", t, s.EnumerationID, p)
+	}
+	return fmt.Sprintf("%s %s", t, p)
+}
+
+// DRHD is a DMA remapping hardware unit, an IOMMU.
+type DRHD struct {
+	// Flags bit 0, INCLUDE_PCI_ALL, means the unit covers all devices
+	// of the segment not covered by other units.
+	Flags uint8
+
+	// Size is the log2 of the number of 4 KiB pages of registers.
+	Size                uint8
+	Segment             uint16
+	RegisterBaseAddress uint64
+	Scopes              []DeviceScope
+}
+
+// RMRR is memory a device uses for DMA before the OS takes over, e.g. for
+// USB legacy emulation, which must stay identity mapped.
+type RMRR struct {
+	_            uint16
+	Segment      uint16
+	BaseAddress  uint64
+	LimitAddress uint64
+	Scopes       []DeviceScope
+}
+
+// ATSR lists the root ports that support Address Translation Services.
+type ATSR struct {
+	Flags   uint8
+	_       uint8
+	Segment uint16
+	Scopes  []DeviceScope
+}
+
+// RHSA puts an IOMMU into a NUMA proximity domain.
+type RHSA struct {
+	_                   uint32
+	RegisterBaseAddress uint64
+	ProximityDomain     uint32
+}
+
+// ANDD names an ACPI namespace device that a DeviceScope refers to.
+type ANDD struct {
+	DeviceNumber uint8
+	ObjectName   string
+}
+
+// SATC lists the SoC integrated devices that support ATS.
+type SATC struct {
+	Flags   uint8
+	_       uint8
+	Segment uint16
+	Scopes  []DeviceScope
+}
+
+// UnknownDMAREntry is a remapping structure of a type not decoded.
+type UnknownDMAREntry struct {
+	Type uint16
+	Data []byte
+}
+
+// DMARType implements DMAREntry.
+func (*DRHD) DMARType() uint16 { return 0 }
+
+// DMARType implements DMAREntry.
+func (*RMRR) DMARType() uint16 { return 1 }
+
+// DMARType implements DMAREntry.
+func (*ATSR) DMARType() uint16 { return 2 }
+
+// DMARType implements DMAREntry.
+func (*RHSA) DMARType() uint16 { return 3 }
+
+// DMARType implements DMAREntry.
+func (*ANDD) DMARType() uint16 { return 4 }
+
+// DMARType implements DMAREntry.
+func (*SATC) DMARType() uint16 { return 5 }
+
+// DMARType implements DMAREntry.
+func (u *UnknownDMAREntry) DMARType() uint16 { return u.Type }
+
+// scopes decodes a list of device scopes.
+func scopes(b []byte) ([]DeviceScope, error) {
+	var ss []DeviceScope
+	for len(b) > 0 {
+		if len(b) < 6 || int(b[1]) < 6 || int(b[1]) > len(b) || b[1]%2 != 0 {
+			return nil, fmt.Errorf("bad device scope %#x", b)
+		}
+		s := DeviceScope{Type: b[0], Flags: b[2], EnumerationID: b[4], StartBus: b[5]}
+		for p := b[6:b[1]]; len(p) >= 2; p = p[2:] {
+			s.Path = append(s.Path, PCIPath{Device: p[0], Function: p[1]})
+		}
+		ss = append(ss, s)
+		b = b[b[1]:]
+	}
+	return ss, nil
+}
+
+// NewDMAR decodes a DMAR.
+func NewDMAR(t Table) (*DMAR, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	b := t.TableData()
+	if h.Signature != "DMAR" || len(b) < 12 {
+		return nil, fmt.Errorf("%s: not a DMAR", h.Signature)
+	}
+	d := &DMAR{Header: h, HostAddressWidth: int(b[0]) + 1, Flags: b[1]}
+	st, err := subtables(b[12:], 2)
+	if err != nil {
+		return nil, fmt.Errorf("DMAR: %v", err)
+	}
+	for _, s := range st {
+		var e DMAREntry
+		var sc *[]DeviceScope
+		// The size of the fixed part before any device scopes.
+		var n int
+		switch s.typ {
+		case 0:
+			u := &DRHD{}
+			e, sc, n = u, &u.Scopes, 12
+		case 1:
+			u := &RMRR{}
+			e, sc, n = u, &u.Scopes, 20
+		case 2:
+			u := &ATSR{}
+			e, sc, n = u, &u.Scopes, 4
+		case 3:
+			e = &RHSA{}
+		case 4:
+			if len(s.data) < 4 {
+				return nil, fmt.Errorf("DMAR: short ANDD")
+			}
+			e = &ANDD{DeviceNumber: s.data[3], ObjectName: cstr(s.data[4:])}
+		case 5:
+			u := &SATC{}
+			e, sc, n = u, &u.Scopes, 4
+		default:
+			e = &UnknownDMAREntry{Type: s.typ, Data: s.data}
+		}
+		switch {
+		case sc != nil:
+			if len(s.data) < n {
+				return nil, fmt.Errorf("DMAR: short remapping structure type %d", s.typ)
+			}
+			if err := decodeFixed(s.data[:n], e); err != nil {
+				return nil, err
+			}
+			if *sc, err = scopes(s.data[n:]); err != nil {
+				return nil, fmt.Errorf("DMAR type %d: %v", s.typ, err)
+			}
+		case s.typ == 3:
+			if err := decode(s.data, e); err != nil {
+				return nil, err
+			}
+		}
+		d.Entries = append(d.Entries, e)
+	}
+	return d, nil
+}
+
+// Marshal implements Marshaler.
+func (d *DMAR) Marshal() (Table, error) {
+	if d.HostAddressWidth < 1 || d.HostAddressWidth > 256 {
+		return nil, fmt.Errorf("DMAR: bad host address width %d", d.HostAddressWidth)
+	}
+	var b bytes.Buffer
+	b.Write([]byte{uint8(d.HostAddressWidth - 1), d.Flags})
+	b.Write(make([]byte, 10))
+	for _, e := range d.Entries {
+		var v bytes.Buffer
+		var sc []DeviceScope
+		switch e := e.(type) {
+		case *DRHD:
+			sc = e.Scopes
+		case *RMRR:
+			sc = e.Scopes
+		case *ATSR:
+			sc = e.Scopes
+		case *SATC:
+			sc = e.Scopes
+		}
+		switch e := e.(type) {
+		case *RHSA:
+			encode(&v, e)
+		case *ANDD:
+			v.Write([]byte{0, 0, 0, e.DeviceNumber})
+			v.WriteString(e.ObjectName)
+			v.WriteByte(0)
+		case *UnknownDMAREntry:
+			v.Write(e.Data)
+		default:
+			if err := encodeFixed(&v, e); err != nil {
+				return nil, err
+			}
+			for _, s := range sc {
+				n := 6 + 2*len(s.Path)
+				if n > 0xff {
+					return nil, fmt.Errorf("DMAR: device scope %v is too long", s)
+				}
+				v.Write([]byte{s.Type, uint8(n), s.Flags, 0, s.EnumerationID, s.StartBus})
+				for _, p := range s.Path {
+					v.Write([]byte{p.Device, p.Function})
+				}
+			}
+		}
+		if err := encodeEntry(&b, 2, e.DMARType(), v.Bytes()); err != nil {
+			return nil, fmt.Errorf("DMAR: %v", err)
+		}
+	}
+	return NewTable(d.Header, b.Bytes())
+}
+
+// DRHDs returns the remapping hardware units.
+func (d *DMAR) DRHDs() []*DRHD {
+	var u []*DRHD
+	for _, e := range d.Entries {
+		if e, ok := e.(*DRHD); ok {
+			u = append(u, e)
+		}
+	}
+	return u
+}
+
+// String prints the DMAR, a remapping structure per line followed by
+// its device scopes.
+func (d *DMAR) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", &d.Header)
+	fmt.Fprintf(&b, "Host address width: %d\n", d.HostAddressWidth)
+	fmt.Fprintf(&b, "Flags: %#x\n", d.Flags)
+	for _, e := range d.Entries {
+		var sc []DeviceScope
+		switch e := e.(type) {
+		case *DRHD:
+			sc = e.Scopes
+			fmt.Fprintf(&b, "DRHD segment %d at %#x, flags %#x\n", e.Segment, e.RegisterBaseAddress, e.Flags)
+		case *RMRR:
+			sc = e.Scopes
+			fmt.Fprintf(&b, "RMRR segment %d %#x-%#x\n", e.Segment, e.BaseAddress, e.LimitAddress)
+		case *ATSR:
+			sc = e.Scopes
+			fmt.Fprintf(&b, "ATSR segment %d, flags %#x\n", e.Segment, e.Flags)
+		case *SATC:
+			sc = e.Scopes
+			fmt.Fprintf(&b, "SATC segment %d, flags %#x\n", e.Segment, e.Flags)
+		default:
+			fmt.Fprintf(&b, "%s %s\n", typeName(e), fields(e))
+		}
+		for _, s := range sc {
+			fmt.Fprintf(&b, "  %s\n", s)
+		}
+	}
+	return b.String()
+}
+
+// MarshalJSON implements json.Marshaler, naming the type of each entry.
+func (d *DMAR) MarshalJSON() ([]byte, error) {
+	type dmar DMAR
+	e := make([]jsonEntry, len(d.Entries))
+	for i, v := range d.Entries {
+		e[i] = jsonEntry{Type: typeName(v), Entry: v}
+	}
+	return json.Marshal(&struct {
+		*dmar
+		Entries []jsonEntry
+	}{(*dmar)(d), e})
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// FADT is the Fixed ACPI Description Table, signature "FACP", which
+// describes the power management hardware and points at the DSDT.
+type FADT struct {
+	Header
+	FADTFields
+}
+
+// FADTFields are the fields of the FADT after the header, in table order
+// and named as in the ACPI specification. Fields past the end of older,
+// shorter FADTs are zero.
+type FADTFields struct {
+	FirmwareCtrl       uint32
+	DSDT               uint32
+	_                  uint8
+	PreferredPMProfile uint8
+	SCIInterrupt       uint16
+	SMICommand         uint32
+	ACPIEnable         uint8
+	ACPIDisable        uint8
+	S4BIOSRequest      uint8
+	PStateControl      uint8
+	PM1aEventBlock     uint32
+	PM1bEventBlock     uint32
+	PM1aControlBlock   uint32
+	PM1bControlBlock   uint32
+	PM2ControlBlock    uint32
+	PMTimerBlock       uint32
+	GPE0Block          uint32
+	GPE1Block          uint32
+	PM1EventLength     uint8
+	PM1ControlLength   uint8
+	PM2ControlLength   uint8
+	PMTimerLength      uint8
+	GPE0BlockLength    uint8
+	GPE1BlockLength    uint8
+	GPE1Base           uint8
+	CStateControl      uint8
+	C2Latency          uint16
+	C3Latency          uint16
+	FlushSize          uint16
+	FlushStride        uint16
+	DutyOffset         uint8
+	DutyWidth          uint8
+	DayAlarm           uint8
+	MonthAlarm         uint8
+	Century            uint8
+	IAPCBootArch       uint16
+	_                  uint8
+	Flags              uint32
+	ResetRegister      GAS
+	ResetValue         uint8
+	ARMBootArch        uint16
+	MinorVersion       uint8
+	XFirmwareCtrl      uint64
+	XDSDT              uint64
+	XPM1aEventBlock    GAS
+	XPM1bEventBlock    GAS
+	XPM1aControlBlock  GAS
+	XPM1bControlBlock  GAS
+	XPM2ControlBlock   GAS
+	XPMTimerBlock      GAS
+	XGPE0Block         GAS
+	XGPE1Block         GAS
+	SleepControl       GAS
+	SleepStatus        GAS
+	HypervisorVendorID uint64
+}
+
+// PMProfiles are the names of FADT PreferredPMProfile values.
+var PMProfiles = []string{
+	"Unspecified", "Desktop", "Mobile", "Workstation", "Enterprise Server",
+	"SOHO Server", "Appliance PC", "Performance Server", "Tablet",
+}
+
+// FADTFlags are the names of the bits of FADT Flags.
+var FADTFlags = []string{
+	"WBINVD", "WBINVD_FLUSH", "PROC_C1", "P_LVL2_UP", "PWR_BUTTON",
+	"SLP_BUTTON", "FIX_RTC", "RTC_S4", "TMR_VAL_EXT", "DCK_CAP",
+	"RESET_REG_SUP", "SEALED_CASE", "HEADLESS", "CPU_SW_SLP",
+	"PCI_EXP_WAK", "USE_PLATFORM_CLOCK", "S4_RTC_STS_VALID",
+	"REMOTE_POWER_ON_CAPABLE", "FORCE_APIC_CLUSTER_MODEL",
+	"FORCE_APIC_PHYSICAL_DESTINATION_MODE", "HW_REDUCED_ACPI",
+	"LOW_POWER_S0_IDLE_CAPABLE",
+}
+
+// IAPCBootArchFlags are the names of the bits of FADT IAPCBootArch.
+var IAPCBootArchFlags = []string{
+	"LEGACY_DEVICES", "8042", "VGA_NOT_PRESENT", "MSI_NOT_SUPPORTED",
+	"PCIE_ASPM_CONTROLS", "CMOS_RTC_NOT_PRESENT",
+}
+
+// ARMBootArchFlags are the names of the bits of FADT ARMBootArch.
+var ARMBootArchFlags = []string{"PSCI_COMPLIANT", "PSCI_USE_HVC"}
+
+// FADT Flags.
+const (
+	FADTHWReducedACPI = 1 << 20
+	FADTLowPowerS0    = 1 << 21
+)
+
+// flagNames returns the names of the set bits of v, or hex for unnamed
+// ones.
+func flagNames(v uint32, names []string) string {
+	var s []string
+	for i := uint(0); i < 32; i++ {
+		if v&(1<<i) == 0 {
+			continue
+		}
+		if int(i) < len(names) {
+			s = append(s, names[i])
+		} else {
+			s = append(s, fmt.Sprintf("%#x", uint32(1)<<i))
+		}
+	}
+	return strings.Join(s, "|")
+}
+
+// NewFADT decodes a FADT.
+func NewFADT(t Table) (*FADT, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	if h.Signature != "FACP" {
+		return nil, fmt.Errorf("%s: not a FADT", h.Signature)
+	}
+	f := &FADT{Header: h}
+	if err := decode(t.TableData(), &f.FADTFields); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// DSDTAddress returns the address of the DSDT, preferring the 64-bit
+// one.
+func (f *FADT) DSDTAddress() uint64 {
+	if f.XDSDT != 0 {
+		return f.XDSDT
+	}
+	return uint64(f.DSDT)
+}
+
+// FACSAddress returns the address of the FACS, preferring the 64-bit
+// one.
+func (f *FADT) FACSAddress() uint64 {
+	if f.XFirmwareCtrl != 0 {
+		return f.XFirmwareCtrl
+	}
+	return uint64(f.FirmwareCtrl)
+}
+
+// HWReduced reports whether the platform is hardware-reduced ACPI, as on
+// most Arm systems and VMs without legacy hardware.
+func (f *FADT) HWReduced() bool {
+	return f.Flags&FADTHWReducedACPI != 0
+}
+
+// String prints the FADT's main fields.
+func (f *FADT) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", &f.Header)
+	fmt.Fprintf(&b, "Version: %d.%d\n", f.Revision, f.MinorVersion)
+	fmt.Fprintf(&b, "FACS: %#x\n", f.FACSAddress())
+	fmt.Fprintf(&b, "DSDT: %#x\n", f.DSDTAddress())
+	p := fmt.Sprintf("%d", f.PreferredPMProfile)
+	if int(f.PreferredPMProfile) < len(PMProfiles) {
+		p = PMProfiles[f.PreferredPMProfile]
+	}
+	fmt.Fprintf(&b, "PM profile: %s\n", p)
+	fmt.Fprintf(&b, "SCI interrupt: %d\n", f.SCIInterrupt)
+	fmt.Fprintf(&b, "Flags: %#x %s\n", f.Flags, flagNames(f.Flags, FADTFlags))
+	fmt.Fprintf(&b, "IA-PC boot arch: %#x %s\n", f.IAPCBootArch, flagNames(uint32(f.IAPCBootArch), IAPCBootArchFlags))
+	fmt.Fprintf(&b, "Arm boot arch: %#x %s\n", f.ARMBootArch, flagNames(uint32(f.ARMBootArch), ARMBootArchFlags))
+	if !f.ResetRegister.IsZero() {
+		fmt.Fprintf(&b, "Reset: write %#x to %s\n", f.ResetValue, f.ResetRegister)
+	}
+	for _, r := range []struct {
+		name string
+		gas  GAS
+		io   uint32
+	}{
+		{"PM1a event", f.XPM1aEventBlock, f.PM1aEventBlock},
+		{"PM1a control", f.XPM1aControlBlock, f.PM1aControlBlock},
+		{"PM timer", f.XPMTimerBlock, f.PMTimerBlock},
+		{"GPE0", f.XGPE0Block, f.GPE0Block},
+		{"Sleep control", f.SleepControl, 0},
+		{"Sleep status", f.SleepStatus, 0},
+	} {
+		switch {
+		case !r.gas.IsZero():
+			fmt.Fprintf(&b, "%s: %s\n", r.name, r.gas)
+		case r.io != 0:
+			fmt.Fprintf(&b, "%s: SystemIO:%#x\n", r.name, r.io)
+		}
+	}
+	return b.String()
+}
+
+// Marshal implements Marshaler. A FADT shorter than FADTFields, as of
+// older ACPI revisions, keeps its length.
+func (f *FADT) Marshal() (Table, error) {
+	var b bytes.Buffer
+	if err := encode(&b, &f.FADTFields); err != nil {
+		return nil, err
+	}
+	d := b.Bytes()
+	if n := int(f.Length) - headerLength; n > 0 && n < len(d) {
+		d = d[:n]
+	}
+	return NewTable(f.Header, d)
+}
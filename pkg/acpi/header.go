@@ -0,0 +1,224 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Header is the decoded standard header of an ACPI table.
+type Header struct {
+	Signature   string
+	Length      uint32
+	Revision    uint8
+	Checksum    uint8
+	OEMID       string
+	OEMTableID  string
+	OEMRevision uint32
+
+	// CreatorID is the vendor of the tool that made the table, which
+	// is four ASCII characters such as "INTL" or "ACPI".
+	CreatorID       string
+	CreatorRevision uint32
+}
+
+// NewHeader decodes the header of t.
+func NewHeader(t Table) (Header, error) {
+	b := t.Data()
+	if len(b) < headerLength {
+		return Header{}, fmt.Errorf("%q: %d bytes is too short for an ACPI table header", t.Sig(), len(b))
+	}
+	return Header{
+		Signature:       cstr(b[0:4]),
+		Length:          binary.LittleEndian.Uint32(b[4:]),
+		Revision:        b[8],
+		Checksum:        b[9],
+		OEMID:           cstr(b[10:16]),
+		OEMTableID:      cstr(b[16:24]),
+		OEMRevision:     binary.LittleEndian.Uint32(b[24:]),
+		CreatorID:       cstr(b[28:32]),
+		CreatorRevision: binary.LittleEndian.Uint32(b[32:]),
+	}, nil
+}
+
+// String prints the header like iasl does.
+func (h *Header) String() string {
+	return fmt.Sprintf("%s rev %d, %d bytes, OEM %q %q rev %#x, creator %q rev %#x",
+		h.Signature, h.Revision, h.Length, h.OEMID, h.OEMTableID, h.OEMRevision, h.CreatorID, h.CreatorRevision)
+}
+
+// cstr returns a fixed length, NUL or space padded string.
+func cstr(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimRight(string(b), " ")
+}
+
+// Address space IDs of a GAS.
+const (
+	SystemMemory      = 0
+	SystemIO          = 1
+	PCIConfig         = 2
+	EmbeddedControl   = 3
+	SMBus             = 4
+	SystemCMOS        = 5
+	PCIBARTarget      = 6
+	IPMI              = 7
+	GPIO              = 8
+	GenericSerialBus  = 9
+	PCC               = 10
+	FunctionalFixedHW = 0x7f
+)
+
+var spaceNames = map[uint8]string{
+	SystemMemory:      "SystemMemory",
+	SystemIO:          "SystemIO",
+	PCIConfig:         "PCIConfig",
+	EmbeddedControl:   "EmbeddedControl",
+	SMBus:             "SMBus",
+	SystemCMOS:        "SystemCMOS",
+	PCIBARTarget:      "PCIBARTarget",
+	IPMI:              "IPMI",
+	GPIO:              "GPIO",
+	GenericSerialBus:  "GenericSerialBus",
+	PCC:               "PCC",
+	FunctionalFixedHW: "FunctionalFixedHW",
+}
+
+// GAS is a Generic Address Structure, the way ACPI tables describe
+// registers.
+type GAS struct {
+	SpaceID    uint8
+	BitWidth   uint8
+	BitOffset  uint8
+	AccessSize uint8
+	Address    uint64
+}
+
+// IsZero reports whether g is unused.
+func (g GAS) IsZero() bool {
+	return g == GAS{}
+}
+
+// String prints g as space:address, with the width in bits.
+func (g GAS) String() string {
+	if g.IsZero() {
+		return "none"
+	}
+	s, ok := spaceNames[g.SpaceID]
+	if !ok {
+		s = fmt.Sprintf("space %#x", g.SpaceID)
+	}
+	return fmt.Sprintf("%s:%#x/%d", s, g.Address, g.BitWidth)
+}
+
+// decode reads the packed little endian struct v from b, as far as b
+// goes: fields past the end of b, from later table revisions, are zero.
+func decode(b []byte, v interface{}) error {
+	n := binary.Size(v)
+	if n < 0 {
+		return fmt.Errorf("can not decode %T", v)
+	}
+	if len(b) < n {
+		b = append(append([]byte{}, b...), make([]byte, n-len(b))...)
+	}
+	return binary.Read(bytes.NewReader(b[:n]), binary.LittleEndian, v)
+}
+
+// decodeFixed is decode for structs that end in slices or strings, which
+// are left alone.
+func decodeFixed(b []byte, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	r := bytes.NewReader(b)
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Field(i)
+		if k := f.Kind(); k == reflect.Slice || k == reflect.String {
+			continue
+		}
+		p := reflect.New(f.Type())
+		if err := binary.Read(r, binary.LittleEndian, p.Interface()); err != nil {
+			return err
+		}
+		if f.CanSet() {
+			f.Set(p.Elem())
+		}
+	}
+	return nil
+}
+
+// subtable is a type-length-value entry in tables such as the MADT or
+// SRAT.
+type subtable struct {
+	typ  uint16
+	data []byte
+}
+
+// subtables splits b into entries with a type and length of size bytes
+// each, the length covering the whole entry. The returned data does not
+// include type and length.
+func subtables(b []byte, size int) ([]subtable, error) {
+	var s []subtable
+	for off := 0; off < len(b); {
+		if len(b)-off < 2*size {
+			return nil, fmt.Errorf("truncated subtable at %#x", off)
+		}
+		var typ, l int
+		if size == 1 {
+			typ, l = int(b[off]), int(b[off+1])
+		} else {
+			typ, l = int(binary.LittleEndian.Uint16(b[off:])), int(binary.LittleEndian.Uint16(b[off+2:]))
+		}
+		if l < 2*size || off+l > len(b) {
+			return nil, fmt.Errorf("subtable type %d at %#x: bad length %d", typ, off, l)
+		}
+		s = append(s, subtable{typ: uint16(typ), data: b[off+2*size : off+l]})
+		off += l
+	}
+	return s, nil
+}
+
+// jsonEntry is how subtables of different types are marshaled to JSON.
+type jsonEntry struct {
+	Type  string
+	Entry interface{}
+}
+
+// typeName returns the name of v's type, without the package name.
+func typeName(v interface{}) string {
+	s := fmt.Sprintf("%T", v)
+	return s[strings.LastIndex(s, ".")+1:]
+}
+
+// fields prints the fields of the struct v as "Name=value", with flags,
+// addresses and 64-bit values in hex.
+func fields(v interface{}) string {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	var s []string
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := rv.Field(i).Interface()
+		switch fv.(type) {
+		case uint8, uint16, uint32:
+			if strings.Contains(f.Name, "Flags") || strings.Contains(f.Name, "Address") {
+				fv = fmt.Sprintf("%#x", fv)
+			}
+		case uint64:
+			fv = fmt.Sprintf("%#x", fv)
+		case []byte:
+			fv = fmt.Sprintf("%#x", fv)
+		}
+		s = append(s, fmt.Sprintf("%s=%v", f.Name, fv))
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// HPET is the High Precision Event Timer table.
+type HPET struct {
+	Header
+	HPETFields
+}
+
+// HPETFields are the fields of the HPET table after the header.
+type HPETFields struct {
+	// EventTimerBlockID is the timer's capabilities register: see
+	// the methods of HPET.
+	EventTimerBlockID uint32
+	BaseAddress       GAS
+	Number            uint8
+	MinimumTick       uint16
+	PageProtection    uint8
+}
+
+// NewHPET decodes a HPET table.
+func NewHPET(t Table) (*HPET, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	if h.Signature != "HPET" || len(t.TableData()) < 20 {
+		return nil, fmt.Errorf("%s: not a HPET table", h.Signature)
+	}
+	p := &HPET{Header: h}
+	if err := decode(t.TableData(), &p.HPETFields); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Comparators returns the number of timers.
+func (p *HPET) Comparators() int {
+	return int(p.EventTimerBlockID>>8&0x1f) + 1
+}
+
+// Counter64 reports whether the main counter has 64 bits.
+func (p *HPET) Counter64() bool {
+	return p.EventTimerBlockID&(1<<13) != 0
+}
+
+// LegacyReplacement reports whether the HPET can replace the PIT and RTC
+// interrupts.
+func (p *HPET) LegacyReplacement() bool {
+	return p.EventTimerBlockID&(1<<15) != 0
+}
+
+// VendorID returns the PCI vendor ID of the HPET's maker.
+func (p *HPET) VendorID() uint16 {
+	return uint16(p.EventTimerBlockID >> 16)
+}
+
+// String prints the HPET.
+func (p *HPET) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", &p.Header)
+	fmt.Fprintf(&b, "HPET %d at %s\n", p.Number, p.BaseAddress)
+	fmt.Fprintf(&b, "Vendor %04x, %d comparators, 64-bit counter %t, legacy replacement %t\n",
+		p.VendorID(), p.Comparators(), p.Counter64(), p.LegacyReplacement())
+	fmt.Fprintf(&b, "Minimum tick: %d\n", p.MinimumTick)
+	return b.String()
+}
+
+// Marshal implements Marshaler.
+func (p *HPET) Marshal() (Table, error) {
+	var b bytes.Buffer
+	if err := encode(&b, &p.HPETFields); err != nil {
+		return nil, err
+	}
+	return NewTable(p.Header, b.Bytes())
+}
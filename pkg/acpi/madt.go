@@ -0,0 +1,333 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MADT is the Multiple APIC Description Table, signature "APIC", which
+// lists the interrupt controllers and so the processors.
+type MADT struct {
+	Header
+
+	// LocalAPICAddress is the 32-bit address of the local APICs, unless
+	// a LocalAPICOverride says otherwise.
+	LocalAPICAddress uint32
+
+	// Flags has bit 0 set if there are also legacy 8259 PICs.
+	Flags uint32
+
+	Entries []MADTEntry
+}
+
+// MADTEntry is an interrupt controller structure of the MADT.
+type MADTEntry interface {
+	MADTType() uint8
+}
+
+// MADT flags of processors.
+const (
+	// MADTEnabled is set for processors that can be used.
+	MADTEnabled = 1 << 0
+	// MADTOnlineCapable is set for disabled processors that can be
+	// brought online later.
+	MADTOnlineCapable = 1 << 1
+)
+
+// LocalAPIC is an x86 processor with an xAPIC.
+type LocalAPIC struct {
+	ProcessorUID uint8
+	APICID       uint8
+	Flags        uint32
+}
+
+// IOAPIC is an x86 I/O APIC.
+type IOAPIC struct {
+	ID      uint8
+	_       uint8
+	Address uint32
+	GSIBase uint32
+}
+
+// InterruptOverride maps an ISA interrupt to a global system interrupt.
+type InterruptOverride struct {
+	Bus    uint8
+	Source uint8
+	GSI    uint32
+	Flags  uint16
+}
+
+// NMISource is a global system interrupt that is an NMI.
+type NMISource struct {
+	Flags uint16
+	GSI   uint32
+}
+
+// LocalAPICNMI is a local APIC input connected to NMI. A ProcessorUID
+// of 0xff means all processors.
+type LocalAPICNMI struct {
+	ProcessorUID uint8
+	Flags        uint16
+	LINT         uint8
+}
+
+// LocalAPICOverride is the 64-bit address of the local APICs.
+type LocalAPICOverride struct {
+	_       uint16
+	Address uint64
+}
+
+// LocalX2APIC is an x86 processor with an x2APIC.
+type LocalX2APIC struct {
+	_            uint16
+	X2APICID     uint32
+	Flags        uint32
+	ProcessorUID uint32
+}
+
+// LocalX2APICNMI is a local x2APIC input connected to NMI.
+type LocalX2APICNMI struct {
+	Flags        uint16
+	ProcessorUID uint32
+	LINT         uint8
+	_            [3]uint8
+}
+
+// GICC is an Arm processor's GIC CPU interface.
+type GICC struct {
+	_                        uint16
+	CPUInterfaceNumber       uint32
+	ProcessorUID             uint32
+	Flags                    uint32
+	ParkingProtocolVersion   uint32
+	PerformanceInterruptGSIV uint32
+	ParkedAddress            uint64
+	PhysicalBaseAddress      uint64
+	GICV                     uint64
+	GICH                     uint64
+	VGICMaintenanceInterrupt uint32
+	GICRBaseAddress          uint64
+	MPIDR                    uint64
+	PowerEfficiencyClass     uint8
+	_                        uint8
+	SPEOverflowInterrupt     uint16
+}
+
+// GICD is an Arm GIC distributor.
+type GICD struct {
+	_                   uint16
+	GICID               uint32
+	PhysicalBaseAddress uint64
+	SystemVectorBase    uint32
+	GICVersion          uint8
+	_                   [3]uint8
+}
+
+// GICMSIFrame is an Arm GICv2m MSI frame.
+type GICMSIFrame struct {
+	_                   uint16
+	ID                  uint32
+	PhysicalBaseAddress uint64
+	Flags               uint32
+	SPICount            uint16
+	SPIBase             uint16
+}
+
+// GICR is a range of Arm GICv3 redistributors.
+type GICR struct {
+	_                         uint16
+	DiscoveryRangeBaseAddress uint64
+	DiscoveryRangeLength      uint32
+}
+
+// GICITS is an Arm GICv3 interrupt translation service.
+type GICITS struct {
+	_                   uint16
+	ID                  uint32
+	PhysicalBaseAddress uint64
+	_                   uint32
+}
+
+// UnknownMADTEntry is an entry of a type not decoded.
+type UnknownMADTEntry struct {
+	Type uint8
+	Data []byte
+}
+
+// MADTType implements MADTEntry.
+func (*LocalAPIC) MADTType() uint8 { return 0 }
+
+// MADTType implements MADTEntry.
+func (*IOAPIC) MADTType() uint8 { return 1 }
+
+// MADTType implements MADTEntry.
+func (*InterruptOverride) MADTType() uint8 { return 2 }
+
+// MADTType implements MADTEntry.
+func (*NMISource) MADTType() uint8 { return 3 }
+
+// MADTType implements MADTEntry.
+func (*LocalAPICNMI) MADTType() uint8 { return 4 }
+
+// MADTType implements MADTEntry.
+func (*LocalAPICOverride) MADTType() uint8 { return 5 }
+
+// MADTType implements MADTEntry.
+func (*LocalX2APIC) MADTType() uint8 { return 9 }
+
+// MADTType implements MADTEntry.
+func (*LocalX2APICNMI) MADTType() uint8 { return 0xa }
+
+// MADTType implements MADTEntry.
+func (*GICC) MADTType() uint8 { return 0xb }
+
+// MADTType implements MADTEntry.
+func (*GICD) MADTType() uint8 { return 0xc }
+
+// MADTType implements MADTEntry.
+func (*GICMSIFrame) MADTType() uint8 { return 0xd }
+
+// MADTType implements MADTEntry.
+func (*GICR) MADTType() uint8 { return 0xe }
+
+// MADTType implements MADTEntry.
+func (*GICITS) MADTType() uint8 { return 0xf }
+
+// MADTType implements MADTEntry.
+func (u *UnknownMADTEntry) MADTType() uint8 { return u.Type }
+
+var madtEntries = map[uint8]func() MADTEntry{
+	0:   func() MADTEntry { return &LocalAPIC{} },
+	1:   func() MADTEntry { return &IOAPIC{} },
+	2:   func() MADTEntry { return &InterruptOverride{} },
+	3:   func() MADTEntry { return &NMISource{} },
+	4:   func() MADTEntry { return &LocalAPICNMI{} },
+	5:   func() MADTEntry { return &LocalAPICOverride{} },
+	9:   func() MADTEntry { return &LocalX2APIC{} },
+	0xa: func() MADTEntry { return &LocalX2APICNMI{} },
+	0xb: func() MADTEntry { return &GICC{} },
+	0xc: func() MADTEntry { return &GICD{} },
+	0xd: func() MADTEntry { return &GICMSIFrame{} },
+	0xe: func() MADTEntry { return &GICR{} },
+	0xf: func() MADTEntry { return &GICITS{} },
+}
+
+// NewMADT decodes a MADT.
+func NewMADT(t Table) (*MADT, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	b := t.TableData()
+	if h.Signature != "APIC" || len(b) < 8 {
+		return nil, fmt.Errorf("%s: not a MADT", h.Signature)
+	}
+	m := &MADT{
+		Header:           h,
+		LocalAPICAddress: binary.LittleEndian.Uint32(b),
+		Flags:            binary.LittleEndian.Uint32(b[4:]),
+	}
+	st, err := subtables(b[8:], 1)
+	if err != nil {
+		return nil, fmt.Errorf("MADT: %v", err)
+	}
+	for _, s := range st {
+		n, ok := madtEntries[uint8(s.typ)]
+		if !ok {
+			m.Entries = append(m.Entries, &UnknownMADTEntry{Type: uint8(s.typ), Data: s.data})
+			continue
+		}
+		e := n()
+		if err := decode(s.data, e); err != nil {
+			return nil, fmt.Errorf("MADT entry type %d: %v", s.typ, err)
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	return m, nil
+}
+
+// CPU is a processor of the MADT.
+type CPU struct {
+	// UID is the ACPI processor UID, which is how the DSDT, SRAT and
+	// other tables refer to the processor.
+	UID uint32
+
+	// ID is the (x2)APIC ID on x86 and the MPIDR on Arm.
+	ID uint64
+
+	Enabled       bool
+	OnlineCapable bool
+}
+
+// CPUs returns the processors, in MADT order, which is the order
+// operating systems bring them up in.
+func (m *MADT) CPUs() []CPU {
+	var cpus []CPU
+	add := func(uid uint32, id uint64, flags uint32) {
+		cpus = append(cpus, CPU{UID: uid, ID: id, Enabled: flags&MADTEnabled != 0, OnlineCapable: flags&MADTOnlineCapable != 0})
+	}
+	for _, e := range m.Entries {
+		switch e := e.(type) {
+		case *LocalAPIC:
+			add(uint32(e.ProcessorUID), uint64(e.APICID), e.Flags)
+		case *LocalX2APIC:
+			add(e.ProcessorUID, uint64(e.X2APICID), e.Flags)
+		case *GICC:
+			add(e.ProcessorUID, e.MPIDR, e.Flags)
+		}
+	}
+	return cpus
+}
+
+// String prints the MADT, an entry per line.
+func (m *MADT) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", &m.Header)
+	fmt.Fprintf(&b, "Local APIC address: %#x\n", m.LocalAPICAddress)
+	fmt.Fprintf(&b, "Flags: %#x", m.Flags)
+	if m.Flags&1 != 0 {
+		b.WriteString(" (PC-AT compatible)")
+	}
+	b.WriteString("\n")
+	for _, e := range m.Entries {
+		fmt.Fprintf(&b, "%s %s\n", typeName(e), fields(e))
+	}
+	return b.String()
+}
+
+// MarshalJSON implements json.Marshaler, naming the type of each entry.
+func (m *MADT) MarshalJSON() ([]byte, error) {
+	type madt MADT
+	e := make([]jsonEntry, len(m.Entries))
+	for i, v := range m.Entries {
+		e[i] = jsonEntry{Type: typeName(v), Entry: v}
+	}
+	return json.Marshal(&struct {
+		*madt
+		Entries []jsonEntry
+	}{(*madt)(m), e})
+}
+
+// Marshal implements Marshaler. Entries are as long as their struct,
+// which is that of the latest ACPI revision decoded.
+func (m *MADT) Marshal() (Table, error) {
+	var b bytes.Buffer
+	encode(&b, []uint32{m.LocalAPICAddress, m.Flags})
+	for _, e := range m.Entries {
+		var v interface{} = e
+		if u, ok := e.(*UnknownMADTEntry); ok {
+			v = u.Data
+		}
+		if err := encodeEntry(&b, 1, uint16(e.MADTType()), v); err != nil {
+			return nil, fmt.Errorf("MADT: %v", err)
+		}
+	}
+	return NewTable(m.Header, b.Bytes())
+}
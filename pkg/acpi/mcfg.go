@@ -0,0 +1,90 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// MCFG is the PCI Express memory mapped configuration table, which lists
+// the ECAM windows.
+type MCFG struct {
+	Header
+	Windows []ECAMWindow
+}
+
+// ECAMWindow is the configuration space of buses StartBus to EndBus of a
+// PCI segment.
+type ECAMWindow struct {
+	BaseAddress uint64
+	Segment     uint16
+	StartBus    uint8
+	EndBus      uint8
+	_           uint32
+}
+
+// Size returns the size of the window.
+func (w *ECAMWindow) Size() uint64 {
+	return (uint64(w.EndBus) - uint64(w.StartBus) + 1) << 20
+}
+
+// Address returns the address of the configuration space of
+// bus:device.function, or false if w does not cover it.
+func (w *ECAMWindow) Address(bus, device, function uint8) (uint64, bool) {
+	if bus < w.StartBus || bus > w.EndBus || device > 31 || function > 7 {
+		return 0, false
+	}
+	// BaseAddress is that of bus 0, even if StartBus is not.
+	return w.BaseAddress + uint64(bus)<<20 + uint64(device)<<15 + uint64(function)<<12, true
+}
+
+const ecamWindowSize = 16
+
+// NewMCFG decodes a MCFG.
+func NewMCFG(t Table) (*MCFG, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	b := t.TableData()
+	if h.Signature != "MCFG" || len(b) < 8 {
+		return nil, fmt.Errorf("%s: not a MCFG", h.Signature)
+	}
+	m := &MCFG{Header: h}
+	for b = b[8:]; len(b) >= ecamWindowSize; b = b[ecamWindowSize:] {
+		var w ECAMWindow
+		if err := decode(b, &w); err != nil {
+			return nil, err
+		}
+		m.Windows = append(m.Windows, w)
+	}
+	if len(b) != 0 {
+		return nil, fmt.Errorf("MCFG: %d trailing bytes", len(b))
+	}
+	return m, nil
+}
+
+// String prints the ECAM windows.
+func (m *MCFG) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", &m.Header)
+	for _, w := range m.Windows {
+		fmt.Fprintf(&b, "Segment %04x buses %02x-%02x: %#x-%#x\n", w.Segment, w.StartBus, w.EndBus,
+			w.BaseAddress+uint64(w.StartBus)<<20, w.BaseAddress+uint64(w.EndBus)<<20+1<<20-1)
+	}
+	return b.String()
+}
+
+// Marshal implements Marshaler.
+func (m *MCFG) Marshal() (Table, error) {
+	var b bytes.Buffer
+	b.Write(make([]byte, 8))
+	if err := encode(&b, m.Windows); err != nil {
+		return nil, err
+	}
+	return NewTable(m.Header, b.Bytes())
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/u-root/u-root/pkg/cpio"
+)
+
+// sameTable reports whether a and b have the same signature, OEM ID and
+// OEM table ID, which is how Linux decides a table replaces another.
+func sameTable(a, b Table) bool {
+	return a.Sig() == b.Sig() && a.OEMID() == b.OEMID() && a.OEMTableID() == b.OEMTableID()
+}
+
+// Override returns tabs with the tables that have the signature, OEM ID
+// and OEM table ID of a table of with replaced by it. The other tables of
+// with, such as new SSDTs, are appended.
+func Override(tabs []Table, with ...Table) []Table {
+	out := append([]Table{}, tabs...)
+	for _, w := range with {
+		var replaced bool
+		for i, t := range out {
+			if sameTable(t, w) {
+				out[i], replaced = w, true
+			}
+		}
+		if !replaced {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// Upgrade returns tabs ready for WriteInitrd to make Linux boot with
+// Override(fw, tabs...), where fw are the firmware's tables.
+//
+// Linux only replaces a firmware table with one that has a higher OEM
+// revision, so Upgrade raises the OEM revision of the tables that have
+// not. The other tables are added, except RSDTs and XSDTs, which Linux
+// only uses as replacements, and for which Upgrade returns an error.
+func Upgrade(fw []Table, tabs ...Table) ([]Table, error) {
+	out := make([]Table, 0, len(tabs))
+	for _, t := range tabs {
+		var rev uint32
+		var replaces bool
+		for _, f := range fw {
+			if sameTable(f, t) && (!replaces || f.OEMRevision() > rev) {
+				rev, replaces = f.OEMRevision(), true
+			}
+		}
+		switch {
+		case !replaces && (t.Sig() == "RSDT" || t.Sig() == "XSDT"):
+			return nil, fmt.Errorf("%s %q %q replaces no firmware table, Linux would ignore it", t.Sig(), t.OEMID(), t.OEMTableID())
+		case replaces && t.OEMRevision() <= rev:
+			if rev == math.MaxUint32 {
+				return nil, fmt.Errorf("%s %q %q: the firmware's OEM revision %#x can not be raised", t.Sig(), t.OEMID(), t.OEMTableID(), rev)
+			}
+			// Patch the header in place rather than through
+			// NewTable, which would pad the IDs with spaces and
+			// so change them.
+			b := append([]byte{}, t.Data()...)
+			binary.LittleEndian.PutUint32(b[24:], rev+1)
+			b[cSUMOffset] = 0
+			b[cSUMOffset] = gencsum(b)
+			t = &Raw{data: b}
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// InitrdDir is where Linux looks for ACPI tables in the initrd.
+const InitrdDir = "kernel/firmware/acpi"
+
+// WriteInitrd writes tabs to w as an uncompressed cpio archive for Linux's
+// ACPI table upgrade (CONFIG_ACPI_TABLE_UPGRADE). The archive must come
+// first in the initrd, e.g. concatenated with another with
+// boot.CatInitrds.
+//
+// Linux replaces the firmware's tables that have the signature, OEM ID
+// and OEM table ID of one of tabs and a lower OEM revision, and adds the
+// others, see Upgrade. Tables can not be dropped: the next kernel still
+// finds the firmware's RSDP and every table it links.
+func WriteInitrd(w io.Writer, tabs ...Table) error {
+	recs := []cpio.Record{
+		cpio.Directory("kernel", 0755),
+		cpio.Directory("kernel/firmware", 0755),
+		cpio.Directory(InitrdDir, 0755),
+	}
+	for i, t := range tabs {
+		if !Checksum(t) {
+			return fmt.Errorf("%s: bad checksum", t.Sig())
+		}
+		name := fmt.Sprintf("%s/%02d%s.aml", InitrdDir, i, t.Sig())
+		recs = append(recs, cpio.StaticFile(name, string(t.Data()), 0644))
+	}
+	cw := cpio.Newc.Writer(w)
+	for _, r := range recs {
+		if err := cw.WriteRecord(cpio.MakeReproducible(r)); err != nil {
+			return err
+		}
+	}
+	return cpio.WriteTrailer(cw)
+}
@@ -38,6 +38,22 @@ func NewRSDP(addr uintptr, len uint) []byte {
 	return r[:]
 }
 
+// newRSDP returns an ACPI 2.0 RSDP at base that points at the RSDT, if
+// rsdt is not 0, and the XSDT.
+func newRSDP(base int64, oemID string, rsdt uint32, xsdt uint64) *RSDP {
+	r := &RSDP{base: base}
+	copy(r.data[:], "RSD PTR ")
+	copy(r.data[9:15], fmt.Sprintf("%-6s", oemID))
+	r.data[15] = 2
+	binary.LittleEndian.PutUint32(r.data[16:], rsdt)
+	binary.LittleEndian.PutUint32(r.data[xSDTLenOff:], uint32(len(r.data)))
+	binary.LittleEndian.PutUint64(r.data[xSDTAddrOff:], xsdt)
+	// The first checksum covers the ACPI 1.0 fields, the second all.
+	r.data[cSUM1Off] = gencsum(r.data[:20])
+	r.data[cSUM2Off] = gencsum(r.data[:])
+	return r
+}
+
 // Len returns the RSDP length
 func (r *RSDP) Len() uint32 {
 	return uint32(len(r.data))
@@ -0,0 +1,226 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// The tables below are not captures: they are encoded by hand from the
+// ACPI and VT-d specifications, laid out like those of a few platforms
+// whose tables we have no dumps of:
+//
+//   - qemu: a QEMU q35 machine with two NUMA nodes: APIC, HPET, MCFG,
+//     SRAT and SLIT.
+//   - intel: a two socket Intel server: x2APIC MADT, SRAT, SLIT,
+//     DMAR, HEST and BERT.
+//   - arm: an Arm server: hardware-reduced FADT, GIC MADT, MCFG
+//     with two segments and SRAT.
+//
+// The only captured tables are in testdata/firecracker.bin.
+var specTables = map[string]func() []byte{
+	"qemu":  qemu,
+	"intel": intel,
+	"arm":   arm,
+}
+
+func le(vs ...interface{}) []byte {
+	var b bytes.Buffer
+	for _, v := range vs {
+		if s, ok := v.(string); ok {
+			b.WriteString(s)
+			continue
+		}
+		if err := binary.Write(&b, binary.LittleEndian, v); err != nil {
+			panic(err)
+		}
+	}
+	return b.Bytes()
+}
+
+func pad(s string, n int) string {
+	for len(s) < n {
+		s += " "
+	}
+	return s
+}
+
+// sumTable returns a table with a header and checksum.
+func sumTable(sig string, rev uint8, oem, oemTable string, oemRev uint32, creator string, body ...[]byte) []byte {
+	b := le(sig, uint32(0), rev, uint8(0), pad(oem, 6), pad(oemTable, 8), oemRev, creator, uint32(0x20200925))
+	for _, p := range body {
+		b = append(b, p...)
+	}
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+	var sum uint8
+	for _, c := range b {
+		sum += c
+	}
+	b[9] = -sum
+	return b
+}
+
+// entry returns a subtable with a one byte type and length.
+func entry(typ uint8, body []byte) []byte {
+	return append([]byte{typ, uint8(len(body) + 2)}, body...)
+}
+
+// entry16 returns a subtable with a two byte type and length.
+func entry16(typ uint16, body ...[]byte) []byte {
+	b := le(typ, uint16(0))
+	for _, p := range body {
+		b = append(b, p...)
+	}
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(b)))
+	return b
+}
+
+func gas(space, width, offset, access uint8, addr uint64) []byte {
+	return le(space, width, offset, access, addr)
+}
+
+func cat(bs ...[]byte) []byte {
+	return bytes.Join(bs, nil)
+}
+
+func qemu() []byte {
+	oem := func(sig string, rev uint8, body ...[]byte) []byte {
+		return sumTable(sig, rev, "BOCHS", "BXPC"+sig, 1, "BXPC", body...)
+	}
+	apic := oem("APIC", 1, le(uint32(0xfee00000), uint32(1)),
+		entry(0, le(uint8(0), uint8(0), uint32(1))),
+		entry(0, le(uint8(1), uint8(1), uint32(1))),
+		entry(0, le(uint8(2), uint8(2), uint32(0))),
+		entry(1, le(uint8(0), uint8(0), uint32(0xfec00000), uint32(0))),
+		entry(2, le(uint8(0), uint8(0), uint32(2), uint16(0))),
+		entry(2, le(uint8(0), uint8(5), uint32(5), uint16(0xd))),
+		entry(2, le(uint8(0), uint8(9), uint32(9), uint16(0xd))),
+		entry(2, le(uint8(0), uint8(10), uint32(10), uint16(0xd))),
+		entry(2, le(uint8(0), uint8(11), uint32(11), uint16(0xd))),
+		entry(4, le(uint8(0xff), uint16(0), uint8(1))),
+	)
+	hpet := oem("HPET", 1, le(uint32(0x8086a201)), gas(0, 0, 0, 0, 0xfed00000), le(uint8(0), uint16(0), uint8(0)))
+	mcfg := oem("MCFG", 1, make([]byte, 8), le(uint64(0xb0000000), uint16(0), uint8(0), uint8(0xff), uint32(0)))
+	srat := oem("SRAT", 1, le(uint32(1), uint64(0)),
+		entry(0, le(uint8(0), uint8(0), uint32(1), uint8(0), [3]uint8{}, uint32(0))),
+		entry(0, le(uint8(1), uint8(1), uint32(1), uint8(0), [3]uint8{}, uint32(0))),
+		entry(0, le(uint8(1), uint8(2), uint32(0), uint8(0), [3]uint8{}, uint32(0))),
+		entry(1, le(uint32(0), uint16(0), uint64(0), uint64(0xa0000), uint32(0), uint32(1), uint64(0))),
+		entry(1, le(uint32(0), uint16(0), uint64(0x100000), uint64(0x3ff00000), uint32(0), uint32(1), uint64(0))),
+		entry(1, le(uint32(1), uint16(0), uint64(0x40000000), uint64(0x40000000), uint32(0), uint32(1), uint64(0))),
+		entry(1, le(uint32(1), uint16(0), uint64(0x100000000), uint64(0x40000000), uint32(0), uint32(3), uint64(0))),
+	)
+	slit := oem("SLIT", 1, le(uint64(2)), []byte{10, 20, 20, 10})
+	return cat(apic, hpet, mcfg, srat, slit)
+}
+
+func scope(typ, id, bus uint8, path ...uint8) []byte {
+	return cat(le(typ, uint8(6+len(path)), uint16(0), id, bus), path)
+}
+
+func intel() []byte {
+	oem := func(sig string, rev uint8, body ...[]byte) []byte {
+		return sumTable(sig, rev, "INTEL", "WHITLEY", 2, "INTL", body...)
+	}
+	var cpus [][]byte
+	var affinity [][]byte
+	for i := uint32(0); i < 4; i++ {
+		// Two sockets of two cores with hyperthreads, the siblings
+		// last as Linux likes it; the last is not populated.
+		flags := uint32(1)
+		if i == 3 {
+			flags = 2
+		}
+		cpus = append(cpus, entry(9, le(uint16(0), i*0x40, flags, i)))
+		affinity = append(affinity, entry(2, le(uint16(0), i/2, i*0x40, flags, uint32(0), uint32(0))))
+	}
+	apic := oem("APIC", 4, le(uint32(0xfee00000), uint32(1)),
+		cat(cpus...),
+		entry(1, le(uint8(8), uint8(0), uint32(0xfec00000), uint32(0))),
+		entry(1, le(uint8(9), uint8(0), uint32(0xfec01000), uint32(24))),
+		entry(2, le(uint8(0), uint8(0), uint32(2), uint16(0))),
+		entry(2, le(uint8(0), uint8(9), uint32(9), uint16(0xd))),
+		entry(0xa, le(uint16(5), uint32(0xffffffff), uint8(1), [3]uint8{})),
+	)
+	srat := oem("SRAT", 3, le(uint32(1), uint64(0)),
+		cat(affinity...),
+		entry(1, le(uint32(0), uint16(0), uint64(0), uint64(0x80000000), uint32(0), uint32(1), uint64(0))),
+		entry(1, le(uint32(1), uint16(0), uint64(0x80000000), uint64(0x80000000), uint32(0), uint32(1), uint64(0))),
+		entry(1, le(uint32(1), uint16(0), uint64(0x200000000), uint64(0x100000000), uint32(0), uint32(5), uint64(0))),
+	)
+	slit := oem("SLIT", 1, le(uint64(2)), []byte{10, 21, 21, 10})
+	dmar := oem("DMAR", 1, le(uint8(45), uint8(5), [10]uint8{}),
+		entry16(0, le(uint8(0), uint8(0), uint16(0), uint64(0xd37fc000)),
+			scope(1, 0, 0x16, 0x05, 0x00),
+			scope(2, 0, 0x16, 0x02, 0x00)),
+		entry16(0, le(uint8(1), uint8(0), uint16(0), uint64(0xfbffc000)),
+			scope(3, 8, 0x00, 0x1e, 0x07),
+			scope(4, 0, 0x00, 0x1e, 0x06)),
+		entry16(1, le(uint16(0), uint16(0), uint64(0x6e6b6000), uint64(0x6e6b8fff)),
+			scope(1, 0, 0x00, 0x14, 0x00),
+			scope(1, 0, 0x00, 0x1a, 0x00)),
+		entry16(2, le(uint8(0), uint8(0), uint16(0)),
+			scope(2, 0, 0x16, 0x02, 0x00)),
+		entry16(3, le(uint32(0), uint64(0xd37fc000), uint32(0))),
+		entry16(3, le(uint32(0), uint64(0xfbffc000), uint32(1))),
+		entry16(4, le([3]uint8{}, uint8(1), "\\_SB.PC00.UA00\x00")),
+	)
+	notify := func(typ uint8, poll uint32) []byte {
+		return le(typ, uint8(28), uint16(0x3e), poll, uint32(0), uint32(0), uint32(0), uint32(0), uint32(0))
+	}
+	bank := make([]byte, 28)
+	hest := oem("HEST", 1, le(uint32(6)),
+		cat(le(uint16(0), uint16(0), uint16(0), uint8(1), uint8(1), uint32(1), uint32(1), uint64(0), uint64(0), uint8(2), [7]uint8{}), bank, bank),
+		cat(le(uint16(1), uint16(1), uint16(0), uint8(1), uint8(1), uint32(1), uint32(1)), notify(0, 5000), le(uint8(1), [3]uint8{}), bank),
+		le(uint16(6), uint16(2), uint16(0), uint8(3), uint8(1), uint32(1), uint32(1),
+			uint32(0), uint16(0), uint16(0), uint16(0x2f), uint16(0),
+			uint32(0x00100000), uint32(0x00462030), uint32(0x00002000), uint32(0xa0), uint32(7)),
+		le(uint16(7), uint16(3), uint16(0), uint8(3), uint8(1), uint32(1), uint32(1),
+			uint32(0), uint16(0), uint16(0), uint16(0x2f), uint16(0),
+			uint32(0x00100000), uint32(0x00462030), uint32(0x00002000), uint32(0xa0)),
+		cat(le(uint16(9), uint16(4), uint16(0xffff), uint8(0), uint8(1), uint32(1), uint32(1), uint32(0x1000)),
+			gas(0, 64, 0, 4, 0x6f7f1000), notify(3, 0), le(uint32(0x1000))),
+		cat(le(uint16(10), uint16(5), uint16(0xffff), uint8(0), uint8(1), uint32(1), uint32(1), uint32(0x1000)),
+			gas(0, 64, 0, 4, 0x6f7f1008), notify(4, 0), le(uint32(0x1000)),
+			gas(0, 64, 0, 4, 0x6f7f2000), le(uint64(0xfffffffffffffffe), uint64(1))),
+	)
+	bert := oem("BERT", 1, le(uint32(0x1000), uint64(0x6f7f0000)))
+	return cat(apic, srat, slit, dmar, hest, bert)
+}
+
+func arm() []byte {
+	oem := func(sig string, rev uint8, body ...[]byte) []byte {
+		return sumTable(sig, rev, "Ampere", "Altra", 2, "AMP.", body...)
+	}
+	fadt := oem("FACP", 6, make([]byte, 0x70-36),
+		le(uint32(1<<20|1<<4|1<<5), gas(0, 0, 0, 0, 0), uint8(0), uint16(1), uint8(3), uint64(0), uint64(0x8000000)),
+		make([]byte, 120), le(uint64(0)))
+	var gicc [][]byte
+	var affinity [][]byte
+	for i := uint32(0); i < 4; i++ {
+		mpidr := uint64(i/2)<<16 | uint64(i%2)<<8
+		gicc = append(gicc, entry(0xb, le(uint16(0), i, i, uint32(1), uint32(0), uint32(23),
+			uint64(0), uint64(0), uint64(0), uint64(0), uint32(25), uint64(0), mpidr, uint8(0), uint8(0), uint16(0))))
+		affinity = append(affinity, entry(3, le(i/2, i, uint32(1), uint32(0))))
+	}
+	apic := oem("APIC", 5, le(uint32(0), uint32(0)),
+		cat(gicc...),
+		entry(0xc, le(uint16(0), uint32(0), uint64(0x100100140000), uint32(0), uint8(3), [3]uint8{})),
+		entry(0xe, le(uint16(0), uint64(0x100100140000), uint32(0x1000000))),
+		entry(0xf, le(uint16(0), uint32(0), uint64(0x100100040000), uint32(0))),
+		entry(0x11, le(uint16(0), uint32(0), uint64(0))),
+	)
+	mcfg := oem("MCFG", 1, make([]byte, 8),
+		le(uint64(0x33fff0000000), uint16(0), uint8(0), uint8(0xff), uint32(0)),
+		le(uint64(0x37fff0000000), uint16(1), uint8(0), uint8(0xff), uint32(0)))
+	srat := oem("SRAT", 3, le(uint32(1), uint64(0)),
+		cat(affinity...),
+		entry(1, le(uint32(0), uint16(0), uint64(0x88300000), uint64(0x3c000000), uint32(0), uint32(1), uint64(0))),
+		entry(1, le(uint32(1), uint16(0), uint64(0x80000000000), uint64(0x80000000), uint32(0), uint32(1), uint64(0))),
+		entry(4, le(uint32(0), uint16(0), uint32(0))),
+	)
+	return cat(fadt, apic, mcfg, srat)
+}
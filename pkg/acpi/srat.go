@@ -0,0 +1,372 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SRAT is the System Resource Affinity Table, which puts processors and
+// memory into NUMA proximity domains.
+type SRAT struct {
+	Header
+	Entries []SRATEntry
+}
+
+// SRATEntry is an affinity structure of the SRAT.
+type SRATEntry interface {
+	SRATType() uint8
+	// Domain returns the entry's proximity domain.
+	Domain() uint32
+	// Enabled reports whether the entry is in use.
+	Enabled() bool
+}
+
+// ProcessorAffinity puts an x86 xAPIC processor into a domain.
+type ProcessorAffinity struct {
+	DomainLow   uint8
+	APICID      uint8
+	Flags       uint32
+	SAPICEID    uint8
+	DomainHigh  [3]uint8
+	ClockDomain uint32
+}
+
+// MemoryAffinity puts a memory range into a domain.
+type MemoryAffinity struct {
+	ProximityDomain uint32
+	_               uint16
+	BaseAddress     uint64
+	Length          uint64
+	_               uint32
+	Flags           uint32
+	_               uint64
+}
+
+// MemoryAffinity Flags.
+const (
+	MemoryHotPluggable = 1 << 1
+	MemoryNonVolatile  = 1 << 2
+)
+
+// X2APICAffinity puts an x86 x2APIC processor into a domain.
+type X2APICAffinity struct {
+	_               uint16
+	ProximityDomain uint32
+	X2APICID        uint32
+	Flags           uint32
+	ClockDomain     uint32
+	_               uint32
+}
+
+// GICCAffinity puts an Arm processor into a domain.
+type GICCAffinity struct {
+	ProximityDomain uint32
+	ProcessorUID    uint32
+	Flags           uint32
+	ClockDomain     uint32
+}
+
+// GICITSAffinity puts an Arm GIC ITS into a domain.
+type GICITSAffinity struct {
+	ProximityDomain uint32
+	_               uint16
+	ITSID           uint32
+}
+
+// GenericInitiatorAffinity puts a device, such as an accelerator, into a
+// domain.
+type GenericInitiatorAffinity struct {
+	_               uint8
+	HandleType      uint8
+	ProximityDomain uint32
+	DeviceHandle    [16]uint8
+	Flags           uint32
+	_               uint32
+}
+
+// UnknownSRATEntry is an entry of a type not decoded.
+type UnknownSRATEntry struct {
+	Type uint8
+	Data []byte
+}
+
+// SRATType implements SRATEntry.
+func (*ProcessorAffinity) SRATType() uint8 { return 0 }
+
+// SRATType implements SRATEntry.
+func (*MemoryAffinity) SRATType() uint8 { return 1 }
+
+// SRATType implements SRATEntry.
+func (*X2APICAffinity) SRATType() uint8 { return 2 }
+
+// SRATType implements SRATEntry.
+func (*GICCAffinity) SRATType() uint8 { return 3 }
+
+// SRATType implements SRATEntry.
+func (*GICITSAffinity) SRATType() uint8 { return 4 }
+
+// SRATType implements SRATEntry.
+func (*GenericInitiatorAffinity) SRATType() uint8 { return 5 }
+
+// SRATType implements SRATEntry.
+func (u *UnknownSRATEntry) SRATType() uint8 { return u.Type }
+
+// Domain implements SRATEntry.
+func (a *ProcessorAffinity) Domain() uint32 {
+	return uint32(a.DomainLow) | uint32(a.DomainHigh[0])<<8 | uint32(a.DomainHigh[1])<<16 | uint32(a.DomainHigh[2])<<24
+}
+
+// Domain implements SRATEntry.
+func (a *MemoryAffinity) Domain() uint32 { return a.ProximityDomain }
+
+// Domain implements SRATEntry.
+func (a *X2APICAffinity) Domain() uint32 { return a.ProximityDomain }
+
+// Domain implements SRATEntry.
+func (a *GICCAffinity) Domain() uint32 { return a.ProximityDomain }
+
+// Domain implements SRATEntry.
+func (a *GICITSAffinity) Domain() uint32 { return a.ProximityDomain }
+
+// Domain implements SRATEntry.
+func (a *GenericInitiatorAffinity) Domain() uint32 { return a.ProximityDomain }
+
+// Domain implements SRATEntry.
+func (*UnknownSRATEntry) Domain() uint32 { return 0 }
+
+// Enabled implements SRATEntry.
+func (a *ProcessorAffinity) Enabled() bool { return a.Flags&1 != 0 }
+
+// Enabled implements SRATEntry.
+func (a *MemoryAffinity) Enabled() bool { return a.Flags&1 != 0 }
+
+// Enabled implements SRATEntry.
+func (a *X2APICAffinity) Enabled() bool { return a.Flags&1 != 0 }
+
+// Enabled implements SRATEntry.
+func (a *GICCAffinity) Enabled() bool { return a.Flags&1 != 0 }
+
+// Enabled implements SRATEntry. ITS entries have no flags.
+func (*GICITSAffinity) Enabled() bool { return true }
+
+// Enabled implements SRATEntry.
+func (a *GenericInitiatorAffinity) Enabled() bool { return a.Flags&1 != 0 }
+
+// Enabled implements SRATEntry.
+func (*UnknownSRATEntry) Enabled() bool { return false }
+
+var sratEntries = map[uint8]func() SRATEntry{
+	0: func() SRATEntry { return &ProcessorAffinity{} },
+	1: func() SRATEntry { return &MemoryAffinity{} },
+	2: func() SRATEntry { return &X2APICAffinity{} },
+	3: func() SRATEntry { return &GICCAffinity{} },
+	4: func() SRATEntry { return &GICITSAffinity{} },
+	5: func() SRATEntry { return &GenericInitiatorAffinity{} },
+}
+
+// NewSRAT decodes a SRAT.
+func NewSRAT(t Table) (*SRAT, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	b := t.TableData()
+	// After the header, 4 bytes that must be 1 and 8 reserved.
+	if h.Signature != "SRAT" || len(b) < 12 {
+		return nil, fmt.Errorf("%s: not a SRAT", h.Signature)
+	}
+	s := &SRAT{Header: h}
+	st, err := subtables(b[12:], 1)
+	if err != nil {
+		return nil, fmt.Errorf("SRAT: %v", err)
+	}
+	for _, e := range st {
+		n, ok := sratEntries[uint8(e.typ)]
+		if !ok {
+			s.Entries = append(s.Entries, &UnknownSRATEntry{Type: uint8(e.typ), Data: e.data})
+			continue
+		}
+		v := n()
+		if err := decode(e.data, v); err != nil {
+			return nil, fmt.Errorf("SRAT entry type %d: %v", e.typ, err)
+		}
+		s.Entries = append(s.Entries, v)
+	}
+	return s, nil
+}
+
+// Marshal implements Marshaler.
+func (s *SRAT) Marshal() (Table, error) {
+	var b bytes.Buffer
+	encode(&b, []uint32{1, 0, 0})
+	for _, e := range s.Entries {
+		var v interface{} = e
+		if u, ok := e.(*UnknownSRATEntry); ok {
+			v = u.Data
+		}
+		if err := encodeEntry(&b, 1, uint16(e.SRATType()), v); err != nil {
+			return nil, fmt.Errorf("SRAT: %v", err)
+		}
+	}
+	return NewTable(s.Header, b.Bytes())
+}
+
+// Node is a NUMA proximity domain.
+type Node struct {
+	Domain uint32
+
+	// CPUs are the APIC IDs on x86, or ACPI processor UIDs on Arm.
+	CPUs []uint32
+
+	Memory []MemoryAffinity
+}
+
+// Nodes returns the enabled processors and memory of each domain, in
+// order of domain.
+func (s *SRAT) Nodes() []*Node {
+	var nodes []*Node
+	node := func(d uint32) *Node {
+		for _, n := range nodes {
+			if n.Domain == d {
+				return n
+			}
+		}
+		n := &Node{Domain: d}
+		// Keep nodes sorted.
+		i := len(nodes)
+		for i > 0 && nodes[i-1].Domain > d {
+			i--
+		}
+		nodes = append(nodes[:i], append([]*Node{n}, nodes[i:]...)...)
+		return n
+	}
+	for _, e := range s.Entries {
+		if !e.Enabled() {
+			continue
+		}
+		switch e := e.(type) {
+		case *ProcessorAffinity:
+			n := node(e.Domain())
+			n.CPUs = append(n.CPUs, uint32(e.APICID))
+		case *X2APICAffinity:
+			n := node(e.Domain())
+			n.CPUs = append(n.CPUs, e.X2APICID)
+		case *GICCAffinity:
+			n := node(e.Domain())
+			n.CPUs = append(n.CPUs, e.ProcessorUID)
+		case *MemoryAffinity:
+			n := node(e.Domain())
+			n.Memory = append(n.Memory, *e)
+		}
+	}
+	return nodes
+}
+
+// String prints the SRAT, an entry per line, followed by a summary of
+// the nodes.
+func (s *SRAT) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", &s.Header)
+	for _, e := range s.Entries {
+		fmt.Fprintf(&b, "%s Domain=%d %s\n", typeName(e), e.Domain(), fields(e))
+	}
+	for _, n := range s.Nodes() {
+		var size uint64
+		for _, m := range n.Memory {
+			size += m.Length
+		}
+		fmt.Fprintf(&b, "Node %d: CPUs %v, %d MiB memory\n", n.Domain, n.CPUs, size>>20)
+	}
+	return b.String()
+}
+
+// MarshalJSON implements json.Marshaler, naming the type of each entry.
+func (s *SRAT) MarshalJSON() ([]byte, error) {
+	type srat SRAT
+	e := make([]jsonEntry, len(s.Entries))
+	for i, v := range s.Entries {
+		e[i] = jsonEntry{Type: typeName(v), Entry: v}
+	}
+	return json.Marshal(&struct {
+		*srat
+		Entries []jsonEntry
+	}{(*srat)(s), e})
+}
+
+// SLIT is the System Locality Information Table, the relative distances
+// between proximity domains.
+type SLIT struct {
+	Header
+
+	// Distances[i][j] is the distance from domain i to j, where 10 is
+	// the distance within a domain and 255 means unreachable.
+	Distances [][]int
+}
+
+// NewSLIT decodes a SLIT.
+func NewSLIT(t Table) (*SLIT, error) {
+	h, err := NewHeader(t)
+	if err != nil {
+		return nil, err
+	}
+	b := t.TableData()
+	if h.Signature != "SLIT" || len(b) < 8 {
+		return nil, fmt.Errorf("%s: not a SLIT", h.Signature)
+	}
+	var n uint64
+	if err := decode(b, &n); err != nil {
+		return nil, err
+	}
+	b = b[8:]
+	if n > 0xffff || uint64(len(b)) < n*n {
+		return nil, fmt.Errorf("SLIT: %d localities do not fit in %d bytes", n, len(b))
+	}
+	s := &SLIT{Header: h, Distances: make([][]int, n)}
+	for i := range s.Distances {
+		s.Distances[i] = make([]int, n)
+		for j := range s.Distances[i] {
+			s.Distances[i][j] = int(b[uint64(i)*n+uint64(j)])
+		}
+	}
+	return s, nil
+}
+
+// Marshal implements Marshaler.
+func (s *SLIT) Marshal() (Table, error) {
+	var b bytes.Buffer
+	encode(&b, uint64(len(s.Distances)))
+	for i, row := range s.Distances {
+		if len(row) != len(s.Distances) {
+			return nil, fmt.Errorf("SLIT: row %d has %d distances, want %d", i, len(row), len(s.Distances))
+		}
+		for _, d := range row {
+			b.WriteByte(uint8(d))
+		}
+	}
+	return NewTable(s.Header, b.Bytes())
+}
+
+// String prints the distance matrix.
+func (s *SLIT) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", &s.Header)
+	b.WriteString("    ")
+	for j := range s.Distances {
+		fmt.Fprintf(&b, " %3d", j)
+	}
+	b.WriteString("\n")
+	for i, row := range s.Distances {
+		fmt.Fprintf(&b, "%3d:", i)
+		for _, d := range row {
+			fmt.Fprintf(&b, " %3d", d)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
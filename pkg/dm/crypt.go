@@ -0,0 +1,69 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Crypt describes a dm-crypt target, which encrypts the sectors of a
+// device.
+type Crypt struct {
+	// Cipher is the cipher, chaining mode and IV generator, e.g.
+	// "aes-xts-plain64".
+	Cipher string
+
+	// Key is the volume key.
+	Key []byte
+
+	// IVOffset is added to the sector number to make the IV.
+	IVOffset uint64
+
+	// Device is the encrypted block device.
+	Device string
+
+	// Offset is the sector of Device at which the encrypted data starts.
+	Offset uint64
+
+	// SectorSize is the encryption sector size. If 0, it is 512.
+	SectorSize int
+
+	// IVLargeSectors counts IVs in SectorSize rather than 512 byte
+	// sectors, as LUKS2 does.
+	IVLargeSectors bool
+
+	// AllowDiscards passes discards through to Device.
+	AllowDiscards bool
+}
+
+// Target returns the dm-crypt target for length sectors at start.
+func (c *Crypt) Target(start, length uint64) (Target, error) {
+	if c.Cipher == "" || strings.ContainsAny(c.Cipher, " \t\n") {
+		return Target{}, fmt.Errorf("invalid cipher %q", c.Cipher)
+	}
+	if c.Device == "" {
+		return Target{}, fmt.Errorf("no device to encrypt")
+	}
+	var opts []string
+	if c.AllowDiscards {
+		opts = append(opts, "allow_discards")
+	}
+	if c.SectorSize != 0 && c.SectorSize != SectorSize {
+		if c.SectorSize&(c.SectorSize-1) != 0 || c.SectorSize > 4096 || c.SectorSize < SectorSize {
+			return Target{}, fmt.Errorf("invalid crypt sector size %d", c.SectorSize)
+		}
+		opts = append(opts, fmt.Sprintf("sector_size:%d", c.SectorSize))
+		if c.IVLargeSectors {
+			opts = append(opts, "iv_large_sectors")
+		}
+	}
+	params := fmt.Sprintf("%s %s %d %s %d", c.Cipher, hex.EncodeToString(c.Key), c.IVOffset, c.Device, c.Offset)
+	if len(opts) > 0 {
+		params += fmt.Sprintf(" %d %s", len(opts), strings.Join(opts, " "))
+	}
+	return Target{Start: start, Length: length, Type: "crypt", Params: params}, nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dm creates, lists and removes device-mapper devices.
+//
+// A device-mapper device is a block device whose sectors are mapped by a
+// table of targets, each of which maps a range of sectors to other block
+// devices, e.g. linearly or through dm-crypt. Devices are managed with
+// ioctls on /dev/mapper/control.
+package dm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/ubinary"
+)
+
+// The ioctl interface version this package speaks. The kernel accepts any
+// request with the same major version.
+const (
+	versionMajor = 4
+	versionMinor = 0
+	versionPatch = 0
+)
+
+// Sizes in struct dm_ioctl and struct dm_target_spec.
+const (
+	nameLen        = 128
+	uuidLen        = 129
+	headerSize     = 312
+	targetSpecSize = 40
+	targetTypeLen  = 16
+	nameListSize   = 12
+)
+
+// Command numbers.
+const (
+	cmdVersion     = 0
+	cmdRemoveAll   = 1
+	cmdListDevices = 2
+	cmdDevCreate   = 3
+	cmdDevRemove   = 4
+	cmdDevRename   = 5
+	cmdDevSuspend  = 6
+	cmdDevStatus   = 7
+	cmdDevWait     = 8
+	cmdTableLoad   = 9
+	cmdTableClear  = 10
+	cmdTableDeps   = 11
+	cmdTableStatus = 12
+)
+
+// Flags in dm_ioctl.flags.
+const (
+	flagReadOnly        = 1 << 0
+	flagSuspend         = 1 << 1
+	flagPersistentDev   = 1 << 3
+	flagStatusTable     = 1 << 4
+	flagActivePresent   = 1 << 5
+	flagInactivePresent = 1 << 6
+	flagBufferFull      = 1 << 8
+	flagSecureData      = 1 << 15
+)
+
+// SectorSize is the unit of target starts and lengths.
+const SectorSize = 512
+
+// ErrNameTooLong is returned for device names that do not fit the kernel's
+// buffer.
+var ErrNameTooLong = errors.New("device-mapper name too long")
+
+// Target maps Length sectors at Start of a device.
+type Target struct {
+	// Start is the first sector of the device the target maps.
+	Start uint64
+
+	// Length is the number of sectors.
+	Length uint64
+
+	// Type is the target type, e.g. "linear" or "crypt".
+	Type string
+
+	// Params are the target's parameters, as in a dmsetup table.
+	Params string
+}
+
+// String returns t as a line of a dmsetup table.
+func (t Target) String() string {
+	return fmt.Sprintf("%d %d %s %s", t.Start, t.Length, t.Type, t.Params)
+}
+
+// Device is a device-mapper device.
+type Device struct {
+	Name string
+	UUID string
+
+	// Major and Minor are the block device numbers.
+	Major, Minor uint32
+
+	// OpenCount is the number of times the device is open.
+	OpenCount int
+
+	// ReadOnly is true if the device cannot be written.
+	ReadOnly bool
+
+	// Suspended is true if I/O to the device is held.
+	Suspended bool
+
+	// Targets is the device's table. It is set only by functions that
+	// read the table.
+	Targets []Target
+}
+
+// Path returns the device node for d in /dev/mapper.
+func (d *Device) Path() string {
+	return "/dev/mapper/" + d.Name
+}
+
+// header is struct dm_ioctl.
+type header struct {
+	Version     [3]uint32
+	DataSize    uint32
+	DataStart   uint32
+	TargetCount uint32
+	OpenCount   int32
+	Flags       uint32
+	EventNr     uint32
+	Padding     uint32
+	Dev         uint64
+	Name        [nameLen]byte
+	UUID        [uuidLen]byte
+	Data        [7]byte
+}
+
+// targetSpec is struct dm_target_spec, which is followed by the target's
+// NUL-terminated parameters.
+type targetSpec struct {
+	SectorStart uint64
+	Length      uint64
+	Status      int32
+	Next        uint32
+	TargetType  [targetTypeLen]byte
+}
+
+// request is an ioctl argument: a header, followed by a payload.
+type request struct {
+	header
+	payload []byte
+
+	// size is the size of the buffer for the kernel's reply. It is at
+	// least the size of the request.
+	size int
+}
+
+func newRequest(name, uuid string) (*request, error) {
+	r := &request{header: header{Version: [3]uint32{versionMajor, versionMinor, versionPatch}}}
+	if len(name) >= nameLen {
+		return nil, ErrNameTooLong
+	}
+	if len(uuid) >= uuidLen {
+		return nil, fmt.Errorf("device-mapper UUID %q too long", uuid)
+	}
+	copy(r.Name[:], name)
+	copy(r.UUID[:], uuid)
+	return r, nil
+}
+
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+// marshal returns the ioctl buffer for r.
+func (r *request) marshal() []byte {
+	size := headerSize + len(r.payload)
+	if r.size > size {
+		size = r.size
+	}
+	h := r.header
+	h.DataStart = headerSize
+	h.DataSize = uint32(size)
+	var b bytes.Buffer
+	binary.Write(&b, ubinary.NativeEndian, &h)
+	b.Write(r.payload)
+	buf := make([]byte, size)
+	copy(buf, b.Bytes())
+	return buf
+}
+
+// unmarshalReply decodes the kernel's reply in buf, returning the header
+// and the payload.
+func unmarshalReply(buf []byte) (*header, []byte, error) {
+	var h header
+	if len(buf) < headerSize {
+		return nil, nil, fmt.Errorf("device-mapper reply of %d bytes is too short", len(buf))
+	}
+	if err := binary.Read(bytes.NewReader(buf), ubinary.NativeEndian, &h); err != nil {
+		return nil, nil, err
+	}
+	if h.DataStart < headerSize || h.DataSize > uint32(len(buf)) || h.DataStart > h.DataSize {
+		return nil, nil, fmt.Errorf("device-mapper reply has data at %d to %d of %d bytes", h.DataStart, h.DataSize, len(buf))
+	}
+	return &h, buf[h.DataStart:h.DataSize], nil
+}
+
+// marshalTargets returns the payload of a table load.
+func marshalTargets(targets []Target) ([]byte, error) {
+	var b bytes.Buffer
+	for _, t := range targets {
+		if len(t.Type) >= targetTypeLen {
+			return nil, fmt.Errorf("target type %q too long", t.Type)
+		}
+		if strings.IndexByte(t.Params, 0) >= 0 {
+			return nil, fmt.Errorf("%s target parameters contain a NUL", t.Type)
+		}
+		spec := targetSpec{SectorStart: t.Start, Length: t.Length}
+		copy(spec.TargetType[:], t.Type)
+
+		// When loading, next is relative to this spec.
+		n := align8(targetSpecSize + len(t.Params) + 1)
+		spec.Next = uint32(n)
+		binary.Write(&b, ubinary.NativeEndian, &spec)
+		b.WriteString(t.Params)
+		b.Write(make([]byte, n-targetSpecSize-len(t.Params)))
+	}
+	return b.Bytes(), nil
+}
+
+// unmarshalTargets decodes the n targets of a table status reply.
+func unmarshalTargets(data []byte, n int) ([]Target, error) {
+	var targets []Target
+	off := 0
+	for i := 0; i < n; i++ {
+		if off+targetSpecSize > len(data) {
+			return nil, fmt.Errorf("target %d at %d is beyond the %d byte reply", i, off, len(data))
+		}
+		var spec targetSpec
+		if err := binary.Read(bytes.NewReader(data[off:]), ubinary.NativeEndian, &spec); err != nil {
+			return nil, err
+		}
+		targets = append(targets, Target{
+			Start:  spec.SectorStart,
+			Length: spec.Length,
+			Type:   cstring(spec.TargetType[:]),
+			Params: cstring(data[off+targetSpecSize:]),
+		})
+
+		// In replies, next is relative to the first spec.
+		if int(spec.Next) <= off && i+1 < n {
+			return nil, fmt.Errorf("target %d points back to %d", i, spec.Next)
+		}
+		off = int(spec.Next)
+	}
+	return targets, nil
+}
+
+// unmarshalNames decodes the struct dm_name_list entries of a device list
+// reply.
+func unmarshalNames(data []byte) ([]*Device, error) {
+	var devices []*Device
+	for off := 0; ; {
+		if off+nameListSize > len(data) {
+			return nil, fmt.Errorf("device list entry at %d is beyond the %d byte reply", off, len(data))
+		}
+		dev := ubinary.NativeEndian.Uint64(data[off:])
+		next := ubinary.NativeEndian.Uint32(data[off+8:])
+		// An empty list is a single entry for device 0.
+		if dev == 0 && off == 0 && next == 0 {
+			return nil, nil
+		}
+		h := header{Dev: dev}
+		d := h.device()
+		d.Name = cstring(data[off+nameListSize:])
+		devices = append(devices, d)
+		if next == 0 {
+			return devices, nil
+		}
+		// next is relative to this entry.
+		off += int(next)
+	}
+}
+
+// device decodes the device described by a reply header.
+func (h *header) device() *Device {
+	return &Device{
+		Name:      cstring(h.Name[:]),
+		UUID:      cstring(h.UUID[:]),
+		Major:     uint32((h.Dev>>8)&0xfff | (h.Dev>>32)&^0xfff),
+		Minor:     uint32(h.Dev&0xff | (h.Dev>>12)&^0xff),
+		OpenCount: int(h.OpenCount),
+		ReadOnly:  h.Flags&flagReadOnly != 0,
+		Suspended: h.Flags&flagSuspend != 0,
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The device-mapper control device is misc device 10:236.
+const (
+	controlPath  = "/dev/mapper/control"
+	controlMajor = 10
+	controlMinor = 236
+)
+
+// Control is the device-mapper control device.
+type Control struct {
+	f *os.File
+
+	// ioctl issues a command. It is replaced in tests.
+	ioctl func(cmd int, buf []byte) error
+
+	// dir is where device nodes are made. If empty, none are.
+	dir string
+}
+
+// Open opens the device-mapper control device, making its node if there is
+// none.
+func Open() (*Control, error) {
+	f, err := os.OpenFile(controlPath, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(controlPath), 0755); err != nil {
+			return nil, err
+		}
+		if err := unix.Mknod(controlPath, unix.S_IFCHR|0600, int(unix.Mkdev(controlMajor, controlMinor))); err != nil && err != unix.EEXIST {
+			return nil, fmt.Errorf("making %s: %v", controlPath, err)
+		}
+		f, err = os.OpenFile(controlPath, os.O_RDWR, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Control{f: f, dir: filepath.Dir(controlPath)}
+	c.ioctl = func(cmd int, buf []byte) error {
+		req := uintptr(0xc0000000 | headerSize<<16 | 0xfd<<8 | cmd)
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+			return errno
+		}
+		return nil
+	}
+	return c, nil
+}
+
+// Close closes the control device.
+func (c *Control) Close() error {
+	if c.f == nil {
+		return nil
+	}
+	return c.f.Close()
+}
+
+// do issues cmd, growing the reply buffer until the reply fits.
+func (c *Control) do(cmd int, r *request) (*header, []byte, error) {
+	for size := 16 << 10; ; size *= 2 {
+		if r.size < size {
+			r.size = size
+		}
+		buf := r.marshal()
+		err := c.ioctl(cmd, buf)
+		if err == nil {
+			h, data, err := unmarshalReply(buf)
+			if err != nil {
+				return nil, nil, err
+			}
+			if h.Flags&flagBufferFull != 0 && size < 16<<20 {
+				continue
+			}
+			// Copy out the payload so that buf, which may hold keys,
+			// can be wiped.
+			data = append([]byte(nil), data...)
+			wipe(buf)
+			return h, data, nil
+		}
+		wipe(buf)
+		return nil, nil, err
+	}
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Version returns the kernel's device-mapper interface version.
+func (c *Control) Version() (string, error) {
+	r, err := newRequest("", "")
+	if err != nil {
+		return "", err
+	}
+	h, _, err := c.do(cmdVersion, r)
+	if err != nil {
+		return "", fmt.Errorf("device-mapper version: %v", err)
+	}
+	return fmt.Sprintf("%d.%d.%d", h.Version[0], h.Version[1], h.Version[2]), nil
+}
+
+// Create makes a device called name with the table targets, activates it
+// and makes its node in /dev/mapper. uuid may be empty.
+//
+// If any step fails, the device is removed again.
+func (c *Control) Create(name, uuid string, readOnly bool, targets []Target) (*Device, error) {
+	r, err := newRequest(name, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := c.do(cmdDevCreate, r); err != nil {
+		return nil, fmt.Errorf("creating device-mapper device %q: %v", name, err)
+	}
+	d, err := c.activate(name, readOnly, targets)
+	if err != nil {
+		if rerr := c.Remove(name); rerr != nil {
+			return nil, fmt.Errorf("%v; removing it: %v", err, rerr)
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// activate loads and resumes the table of a new device.
+func (c *Control) activate(name string, readOnly bool, targets []Target) (*Device, error) {
+	r, err := newRequest(name, "")
+	if err != nil {
+		return nil, err
+	}
+	if r.payload, err = marshalTargets(targets); err != nil {
+		return nil, err
+	}
+	defer wipe(r.payload)
+	r.TargetCount = uint32(len(targets))
+	// Ask the kernel to wipe its copies of the table, which may hold
+	// keys.
+	r.Flags = flagSecureData
+	if readOnly {
+		r.Flags |= flagReadOnly
+	}
+	if _, _, err := c.do(cmdTableLoad, r); err != nil {
+		return nil, fmt.Errorf("loading the table of %q: %v", name, err)
+	}
+
+	// Resuming is a suspend without the suspend flag.
+	if r, err = newRequest(name, ""); err != nil {
+		return nil, err
+	}
+	h, _, err := c.do(cmdDevSuspend, r)
+	if err != nil {
+		return nil, fmt.Errorf("resuming %q: %v", name, err)
+	}
+	d := h.device()
+	if err := c.mknod(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (c *Control) mknod(d *Device) error {
+	if c.dir == "" {
+		return nil
+	}
+	path := filepath.Join(c.dir, d.Name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := unix.Mknod(path, unix.S_IFBLK|0600, int(unix.Mkdev(d.Major, d.Minor))); err != nil {
+		return fmt.Errorf("making %s: %v", path, err)
+	}
+	return nil
+}
+
+// Remove removes the device called name and its node.
+func (c *Control) Remove(name string) error {
+	r, err := newRequest(name, "")
+	if err != nil {
+		return err
+	}
+	if _, _, err := c.do(cmdDevRemove, r); err != nil {
+		return fmt.Errorf("removing device-mapper device %q: %v", name, err)
+	}
+	if c.dir != "" {
+		if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Info returns the state of the device called name. Its Targets are not
+// set.
+func (c *Control) Info(name string) (*Device, error) {
+	r, err := newRequest(name, "")
+	if err != nil {
+		return nil, err
+	}
+	h, _, err := c.do(cmdDevStatus, r)
+	if err != nil {
+		return nil, fmt.Errorf("device-mapper device %q: %v", name, err)
+	}
+	return h.device(), nil
+}
+
+// List returns the device-mapper devices. Only their names and numbers are
+// set.
+func (c *Control) List() ([]*Device, error) {
+	r, err := newRequest("", "")
+	if err != nil {
+		return nil, err
+	}
+	_, data, err := c.do(cmdListDevices, r)
+	if err != nil {
+		return nil, fmt.Errorf("listing device-mapper devices: %v", err)
+	}
+	return unmarshalNames(data)
+}
+
+// tableStatus issues a table status command with flags.
+func (c *Control) tableStatus(name string, flags uint32) (*Device, error) {
+	r, err := newRequest(name, "")
+	if err != nil {
+		return nil, err
+	}
+	r.Flags = flags
+	h, data, err := c.do(cmdTableStatus, r)
+	if err != nil {
+		return nil, fmt.Errorf("device-mapper device %q: %v", name, err)
+	}
+	defer wipe(data)
+	d := h.device()
+	if d.Targets, err = unmarshalTargets(data, int(h.TargetCount)); err != nil {
+		return nil, fmt.Errorf("device-mapper device %q: %v", name, err)
+	}
+	return d, nil
+}
+
+// Table returns the device called name with its active table. Keys in the
+// table are shown unless the kernel hides them.
+func (c *Control) Table(name string) (*Device, error) {
+	return c.tableStatus(name, flagStatusTable)
+}
+
+// Status returns the device called name with the status of each of its
+// targets in their Params.
+func (c *Control) Status(name string) (*Device, error) {
+	return c.tableStatus(name, 0)
+}
+
+// Suspend holds I/O to the device called name until it is resumed.
+func (c *Control) Suspend(name string) error {
+	r, err := newRequest(name, "")
+	if err != nil {
+		return err
+	}
+	r.Flags = flagSuspend
+	if _, _, err := c.do(cmdDevSuspend, r); err != nil {
+		return fmt.Errorf("suspending %q: %v", name, err)
+	}
+	return nil
+}
+
+// Resume releases I/O to the device called name, switching to its
+// inactive table if one has been loaded.
+func (c *Control) Resume(name string) error {
+	r, err := newRequest(name, "")
+	if err != nil {
+		return err
+	}
+	if _, _, err := c.do(cmdDevSuspend, r); err != nil {
+		return fmt.Errorf("resuming %q: %v", name, err)
+	}
+	return nil
+}
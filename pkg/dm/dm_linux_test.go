@@ -0,0 +1,440 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/ubinary"
+	"golang.org/x/sys/unix"
+)
+
+type fakeDevice struct {
+	uuid     string
+	minor    uint64
+	flags    uint32
+	inactive []Target
+	active   []Target
+}
+
+// fakeKernel implements the ioctls the way the kernel does.
+type fakeKernel struct {
+	t       *testing.T
+	devices map[string]*fakeDevice
+	next    uint64
+	cmds    []int
+
+	// secure records whether every table load asked for wiping.
+	secure bool
+}
+
+func newFakeKernel(t *testing.T) *fakeKernel {
+	return &fakeKernel{t: t, devices: map[string]*fakeDevice{}, secure: true}
+}
+
+func (k *fakeKernel) control(dir string) *Control {
+	return &Control{ioctl: k.ioctl, dir: dir}
+}
+
+func (k *fakeKernel) ioctl(cmd int, buf []byte) error {
+	k.cmds = append(k.cmds, cmd)
+	var h header
+	if err := binary.Read(bytes.NewReader(buf), ubinary.NativeEndian, &h); err != nil {
+		k.t.Fatal(err)
+	}
+	if h.Version[0] != versionMajor || h.DataStart != headerSize || int(h.DataSize) != len(buf) {
+		k.t.Fatalf("bad request header %+v", h)
+	}
+	name := cstring(h.Name[:])
+	d := k.devices[name]
+	if d == nil && cmd != cmdVersion && cmd != cmdDevCreate && cmd != cmdListDevices {
+		return unix.ENXIO
+	}
+	h.Flags &^= flagBufferFull
+	switch cmd {
+	case cmdVersion:
+		h.Version = [3]uint32{4, 42, 0}
+	case cmdDevCreate:
+		if d != nil {
+			return unix.EBUSY
+		}
+		d = &fakeDevice{uuid: cstring(h.UUID[:]), minor: k.next}
+		k.next++
+		k.devices[name] = d
+	case cmdTableLoad:
+		data := buf[h.DataStart:]
+		var targets []Target
+		for i, off := 0, 0; i < int(h.TargetCount); i++ {
+			var spec targetSpec
+			binary.Read(bytes.NewReader(data[off:]), ubinary.NativeEndian, &spec)
+			targets = append(targets, Target{spec.SectorStart, spec.Length, cstring(spec.TargetType[:]), cstring(data[off+targetSpecSize:])})
+			if spec.Next%8 != 0 {
+				k.t.Errorf("target spec %d is %d bytes, not a multiple of 8", i, spec.Next)
+			}
+			off += int(spec.Next)
+		}
+		if len(targets) == 0 || targets[0].Type == "error-on-load" {
+			return unix.EINVAL
+		}
+		d.inactive = targets
+		d.flags = h.Flags & flagReadOnly
+		k.secure = k.secure && h.Flags&flagSecureData != 0
+	case cmdDevSuspend:
+		if h.Flags&flagSuspend != 0 {
+			d.flags |= flagSuspend
+			break
+		}
+		if d.inactive != nil {
+			d.active, d.inactive = d.inactive, nil
+		} else if d.active == nil {
+			return unix.EINVAL
+		}
+		d.flags &^= flagSuspend
+	case cmdDevRemove:
+		delete(k.devices, name)
+	case cmdDevStatus:
+	case cmdListDevices:
+		k.list(buf, &h)
+	case cmdTableStatus:
+		k.status(buf, &h, d)
+	default:
+		return unix.ENOTTY
+	}
+	if d != nil {
+		h.Dev = (253 << 8) | d.minor
+		h.Flags = h.Flags&^(flagReadOnly|flagSuspend) | d.flags
+		copy(h.UUID[:], d.uuid)
+	}
+	var b bytes.Buffer
+	binary.Write(&b, ubinary.NativeEndian, &h)
+	copy(buf, b.Bytes())
+	return nil
+}
+
+// list writes a device list reply, with the devices in name order.
+func (k *fakeKernel) list(buf []byte, h *header) {
+	var names []string
+	for name := range k.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	data := buf[h.DataStart:]
+	off := 0
+	for i, name := range names {
+		n := align8(nameListSize + len(name) + 1)
+		ubinary.NativeEndian.PutUint64(data[off:], 253<<8|k.devices[name].minor)
+		if i+1 < len(names) {
+			ubinary.NativeEndian.PutUint32(data[off+8:], uint32(n))
+		}
+		copy(data[off+nameListSize:], name+"\x00")
+		off += n
+	}
+}
+
+// status writes a table status reply, with the table or a status line
+// for each target.
+func (k *fakeKernel) status(buf []byte, h *header, d *fakeDevice) {
+	var targets []Target
+	for _, t := range d.active {
+		if h.Flags&flagStatusTable == 0 {
+			t.Params = ""
+			if t.Type == "striped" {
+				t.Params = "2 8:16 8:32 1 AA"
+			}
+		}
+		targets = append(targets, t)
+	}
+	data, err := marshalTargets(targets)
+	if err != nil {
+		k.t.Fatal(err)
+	}
+	// In replies, next is relative to the first spec.
+	for i, off := 0, uint32(0); i < len(targets); i++ {
+		next := ubinary.NativeEndian.Uint32(data[off+20:])
+		ubinary.NativeEndian.PutUint32(data[off+20:], off+next)
+		off += next
+	}
+	copy(buf[h.DataStart:], data)
+	h.TargetCount = uint32(len(targets))
+}
+
+func TestHeaderSize(t *testing.T) {
+	if n := binary.Size(header{}); n != headerSize {
+		t.Errorf("struct dm_ioctl is %d bytes, want %d", n, headerSize)
+	}
+	if n := binary.Size(targetSpec{}); n != targetSpecSize {
+		t.Errorf("struct dm_target_spec is %d bytes, want %d", n, targetSpecSize)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := ""
+	if os.Getuid() == 0 {
+		var err error
+		if dir, err = ioutil.TempDir("", "dm"); err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+	}
+	k := newFakeKernel(t)
+	c := k.control(dir)
+
+	v, err := c.Version()
+	if err != nil || v != "4.42.0" {
+		t.Errorf("Version = %q, %v, want 4.42.0", v, err)
+	}
+
+	crypt := &Crypt{Cipher: "aes-xts-plain64", Key: []byte{0xde, 0xad, 0xbe, 0xef}, Device: "/dev/sda2", Offset: 32768, SectorSize: 4096, IVLargeSectors: true}
+	ct, err := crypt.Target(0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := []Target{ct, {Start: 1000, Length: 24, Type: "zero"}}
+	d, err := c.Create("secret", "CRYPT-LUKS2-1234-secret", true, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Device{Name: "secret", UUID: "CRYPT-LUKS2-1234-secret", Major: 253, Minor: 0, ReadOnly: true}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Create = %+v, want %+v", d, want)
+	}
+	if got := k.devices["secret"].active; !reflect.DeepEqual(got, targets) {
+		t.Errorf("kernel has table %v, want %v", got, targets)
+	}
+	if ct.Params != "aes-xts-plain64 deadbeef 0 /dev/sda2 32768 2 sector_size:4096 iv_large_sectors" {
+		t.Errorf("crypt params are %q", ct.Params)
+	}
+	if !k.secure {
+		t.Errorf("table load did not ask the kernel to wipe its buffers")
+	}
+	if dir != "" {
+		var st unix.Stat_t
+		if err := unix.Stat(filepath.Join(dir, "secret"), &st); err != nil {
+			t.Error(err)
+		} else if st.Mode&unix.S_IFMT != unix.S_IFBLK || unix.Major(st.Rdev) != 253 || unix.Minor(st.Rdev) != 0 {
+			t.Errorf("node has mode %o and device %d:%d", st.Mode, unix.Major(st.Rdev), unix.Minor(st.Rdev))
+		}
+	}
+
+	if _, err := c.Info("secret"); err != nil {
+		t.Error(err)
+	}
+	if _, err := c.Create("secret", "", false, targets); err == nil {
+		t.Errorf("creating a device twice succeeded")
+	}
+	if err := c.Remove("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Info("secret"); err == nil {
+		t.Errorf("Info of a removed device succeeded")
+	}
+	if dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, "secret")); !os.IsNotExist(err) {
+			t.Errorf("node survived removal: %v", err)
+		}
+	}
+
+	// A failed load removes the device again.
+	if _, err := c.Create("bad", "", false, []Target{{Length: 1, Type: "error-on-load"}}); err == nil {
+		t.Errorf("creating a device with a bad table succeeded")
+	}
+	if len(k.devices) != 0 {
+		t.Errorf("devices %v left behind", k.devices)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	long := string(make([]byte, nameLen))
+	if _, err := newRequest(long, ""); err != ErrNameTooLong {
+		t.Errorf("newRequest with a long name = %v, want %v", err, ErrNameTooLong)
+	}
+	if _, err := marshalTargets([]Target{{Type: "a-very-long-target-type"}}); err == nil {
+		t.Errorf("marshaling a long target type succeeded")
+	}
+	if _, err := marshalTargets([]Target{{Type: "linear", Params: "a\x00b"}}); err == nil {
+		t.Errorf("marshaling parameters with a NUL succeeded")
+	}
+	for _, c := range []*Crypt{
+		{Cipher: "aes xts", Device: "/dev/sda"},
+		{Cipher: "aes-xts-plain64"},
+		{Cipher: "aes-xts-plain64", Device: "/dev/sda", SectorSize: 1000},
+	} {
+		if _, err := c.Target(0, 1); err == nil {
+			t.Errorf("%+v.Target succeeded", c)
+		}
+	}
+}
+
+func TestUnmarshalTargets(t *testing.T) {
+	targets := []Target{
+		{Start: 0, Length: 100, Type: "linear", Params: "8:16 2048"},
+		{Start: 100, Length: 50, Type: "striped", Params: "2 128 8:32 0 8:48 0"},
+	}
+	load, err := marshalTargets(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rewrite next to be relative to the first spec, as in replies.
+	status := append([]byte(nil), load...)
+	first := ubinary.NativeEndian.Uint32(status[20:])
+	ubinary.NativeEndian.PutUint32(status[first+20:], first+ubinary.NativeEndian.Uint32(status[first+20:]))
+	got, err := unmarshalTargets(status, len(targets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, targets) {
+		t.Errorf("unmarshalTargets = %v, want %v", got, targets)
+	}
+	if _, err := unmarshalTargets(status[:50], 2); err == nil {
+		t.Errorf("unmarshaling a short reply succeeded")
+	}
+}
+
+func TestListAndTable(t *testing.T) {
+	k := newFakeKernel(t)
+	c := k.control("")
+
+	if devs, err := c.List(); err != nil || len(devs) != 0 {
+		t.Errorf("List with no devices = %v, %v", devs, err)
+	}
+
+	lt, err := (&Linear{Device: "8:16", Offset: 2048}).Target(0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := (&Striped{Chunk: 8, Stripes: []Stripe{{"8:16", 4096}, {"8:32", 2048}}}).Target(100, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := []Target{lt, st}
+	if _, err := c.Create("vg-root", "LVM-abc", false, table); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Create("a-long-device-name", "", false, table[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	devs, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Device{
+		{Name: "a-long-device-name", Major: 253, Minor: 1},
+		{Name: "vg-root", Major: 253, Minor: 0},
+	}
+	if !reflect.DeepEqual(devs, want) {
+		t.Errorf("List = %+v, want %+v", devs, want)
+	}
+
+	d, err := c.Table("vg-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.UUID != "LVM-abc" || !reflect.DeepEqual(d.Targets, table) {
+		t.Errorf("Table = %+v, want table %v", d, table)
+	}
+	if d.Targets[1].Params != "2 8 8:16 4096 8:32 2048" {
+		t.Errorf("striped params are %q", d.Targets[1].Params)
+	}
+	d, err = c.Status("vg-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Targets) != 2 || d.Targets[1].Params != "2 8:16 8:32 1 AA" {
+		t.Errorf("Status = %+v", d.Targets)
+	}
+
+	if err := c.Suspend("vg-root"); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := c.Info("vg-root"); err != nil || !d.Suspended {
+		t.Errorf("Info of a suspended device = %+v, %v", d, err)
+	}
+	if err := c.Resume("vg-root"); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := c.Info("vg-root"); err != nil || d.Suspended {
+		t.Errorf("Info of a resumed device = %+v, %v", d, err)
+	}
+	if _, err := c.Table("missing"); err == nil {
+		t.Errorf("Table of a missing device succeeded")
+	}
+}
+
+func TestTargets(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		f    func(start, length uint64) (Target, error)
+		want string
+	}{
+		{
+			name: "linear",
+			f:    (&Linear{Device: "/dev/sda1", Offset: 8}).Target,
+			want: "0 64 linear /dev/sda1 8",
+		},
+		{
+			name: "striped",
+			f:    (&Striped{Chunk: 16, Stripes: []Stripe{{"8:0", 0}, {"8:16", 2048}}}).Target,
+			want: "0 64 striped 2 16 8:0 0 8:16 2048",
+		},
+		{
+			name: "verity",
+			f: (&Verity{
+				Version:       1,
+				DataDevice:    "/dev/sda1",
+				HashDevice:    "/dev/sda2",
+				DataBlockSize: 4096,
+				HashBlockSize: 4096,
+				DataBlocks:    8,
+				HashStart:     1,
+				Algorithm:     "sha256",
+				RootDigest:    []byte{0xab, 0xcd},
+				Options:       []string{"ignore_zero_blocks"},
+			}).Target,
+			want: "0 64 verity 1 /dev/sda1 /dev/sda2 4096 4096 8 1 sha256 abcd - 1 ignore_zero_blocks",
+		},
+		{
+			name: "snapshot",
+			f:    (&Snapshot{Origin: "253:0", COW: "253:1", Persistent: true, Chunk: 8}).Target,
+			want: "0 64 snapshot 253:0 253:1 P 8",
+		},
+		{
+			name: "snapshot-origin",
+			f: func(start, length uint64) (Target, error) {
+				return SnapshotOrigin("/dev/sda3", start, length)
+			},
+			want: "0 64 snapshot-origin /dev/sda3",
+		},
+	} {
+		got, err := tt.f(0, 64)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("%s target = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	for _, f := range []func(uint64, uint64) (Target, error){
+		(&Linear{}).Target,
+		(&Linear{Device: "a b"}).Target,
+		(&Striped{Chunk: 8}).Target,
+		(&Striped{Stripes: []Stripe{{"8:0", 0}}}).Target,
+		(&Striped{Chunk: 16, Stripes: []Stripe{{"8:0", 0}, {"8:16", 0}}}).Target,
+		(&Striped{Chunk: 8, Stripes: []Stripe{{"", 0}}}).Target,
+		(&Verity{DataDevice: "a", HashDevice: "b", DataBlockSize: 4096, HashBlockSize: 1000, Algorithm: "sha256", RootDigest: []byte{1}}).Target,
+		(&Verity{DataDevice: "a", HashDevice: "b", DataBlockSize: 4096, HashBlockSize: 4096, Algorithm: "sha256"}).Target,
+		(&Snapshot{Origin: "a", COW: "b", Chunk: 3}).Target,
+		(&Snapshot{Origin: "a", Chunk: 8}).Target,
+	} {
+		if got, err := f(0, 40); err == nil {
+			t.Errorf("making a bad target succeeded: %q", got)
+		}
+	}
+}
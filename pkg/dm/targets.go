@@ -0,0 +1,184 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// validDevice checks that dev can be a word of a table line.
+func validDevice(dev string) error {
+	if dev == "" {
+		return fmt.Errorf("no device")
+	}
+	if strings.ContainsAny(dev, " \t\n") {
+		return fmt.Errorf("invalid device %q", dev)
+	}
+	return nil
+}
+
+// Linear describes a linear target, which maps sectors to a contiguous
+// range of a device.
+type Linear struct {
+	// Device is the underlying block device, as a path or major:minor.
+	Device string
+
+	// Offset is the sector of Device at which the mapping starts.
+	Offset uint64
+}
+
+// Target returns the linear target for length sectors at start.
+func (l *Linear) Target(start, length uint64) (Target, error) {
+	if err := validDevice(l.Device); err != nil {
+		return Target{}, err
+	}
+	return Target{Start: start, Length: length, Type: "linear", Params: fmt.Sprintf("%s %d", l.Device, l.Offset)}, nil
+}
+
+// Stripe is one of the devices of a striped target.
+type Stripe struct {
+	Device string
+	Offset uint64
+}
+
+// Striped describes a striped target, which spreads chunks of sectors
+// across devices in turn.
+type Striped struct {
+	// Chunk is the number of sectors in each chunk.
+	Chunk uint64
+
+	Stripes []Stripe
+}
+
+// Target returns the striped target for length sectors at start. length
+// must be a multiple of the chunk size times the number of stripes.
+func (s *Striped) Target(start, length uint64) (Target, error) {
+	if len(s.Stripes) == 0 {
+		return Target{}, fmt.Errorf("striped target has no stripes")
+	}
+	if s.Chunk == 0 {
+		return Target{}, fmt.Errorf("striped target has no chunk size")
+	}
+	n := uint64(len(s.Stripes))
+	if length%n != 0 || (length/n)%s.Chunk != 0 {
+		return Target{}, fmt.Errorf("%d sectors are not a whole number of %d chunks of %d sectors", length, n, s.Chunk)
+	}
+	params := fmt.Sprintf("%d %d", n, s.Chunk)
+	for _, st := range s.Stripes {
+		if err := validDevice(st.Device); err != nil {
+			return Target{}, err
+		}
+		params += fmt.Sprintf(" %s %d", st.Device, st.Offset)
+	}
+	return Target{Start: start, Length: length, Type: "striped", Params: params}, nil
+}
+
+// Verity describes a dm-verity target, which checks the blocks of a
+// read-only device against a hash tree.
+type Verity struct {
+	// Version is the hash format version. 1 is what veritysetup makes.
+	Version int
+
+	// DataDevice holds the data, and HashDevice the hash tree. They may
+	// be the same device.
+	DataDevice string
+	HashDevice string
+
+	// DataBlockSize and HashBlockSize are in bytes.
+	DataBlockSize int
+	HashBlockSize int
+
+	// DataBlocks is the number of data blocks.
+	DataBlocks uint64
+
+	// HashStart is the block of HashDevice at which the tree starts.
+	HashStart uint64
+
+	// Algorithm is the hash, e.g. "sha256".
+	Algorithm string
+
+	// RootDigest is the hash of the root block, and Salt the salt of
+	// every hash.
+	RootDigest []byte
+	Salt       []byte
+
+	// Options are the optional parameters, e.g. "ignore_zero_blocks".
+	Options []string
+}
+
+// Target returns the dm-verity target for length sectors at start.
+func (v *Verity) Target(start, length uint64) (Target, error) {
+	if err := validDevice(v.DataDevice); err != nil {
+		return Target{}, err
+	}
+	if err := validDevice(v.HashDevice); err != nil {
+		return Target{}, err
+	}
+	for _, bs := range []int{v.DataBlockSize, v.HashBlockSize} {
+		if bs < SectorSize || bs&(bs-1) != 0 {
+			return Target{}, fmt.Errorf("invalid verity block size %d", bs)
+		}
+	}
+	if v.Algorithm == "" || strings.ContainsAny(v.Algorithm, " \t\n") {
+		return Target{}, fmt.Errorf("invalid verity hash %q", v.Algorithm)
+	}
+	if len(v.RootDigest) == 0 {
+		return Target{}, fmt.Errorf("verity target has no root digest")
+	}
+	salt := "-"
+	if len(v.Salt) > 0 {
+		salt = hex.EncodeToString(v.Salt)
+	}
+	params := fmt.Sprintf("%d %s %s %d %d %d %d %s %s %s", v.Version, v.DataDevice, v.HashDevice,
+		v.DataBlockSize, v.HashBlockSize, v.DataBlocks, v.HashStart, v.Algorithm, hex.EncodeToString(v.RootDigest), salt)
+	if len(v.Options) > 0 {
+		params += fmt.Sprintf(" %d %s", len(v.Options), strings.Join(v.Options, " "))
+	}
+	return Target{Start: start, Length: length, Type: "verity", Params: params}, nil
+}
+
+// Snapshot describes a snapshot target, which presents Origin as it was
+// when the snapshot was made, keeping changed chunks in COW.
+type Snapshot struct {
+	Origin string
+	COW    string
+
+	// Persistent keeps the snapshot's metadata in COW, so that it
+	// survives reboots.
+	Persistent bool
+
+	// Chunk is the number of sectors in each copied chunk.
+	Chunk uint64
+}
+
+// Target returns the snapshot target for length sectors at start.
+func (s *Snapshot) Target(start, length uint64) (Target, error) {
+	if err := validDevice(s.Origin); err != nil {
+		return Target{}, err
+	}
+	if err := validDevice(s.COW); err != nil {
+		return Target{}, err
+	}
+	if s.Chunk == 0 || s.Chunk&(s.Chunk-1) != 0 {
+		return Target{}, fmt.Errorf("invalid snapshot chunk size %d", s.Chunk)
+	}
+	p := "N"
+	if s.Persistent {
+		p = "P"
+	}
+	return Target{Start: start, Length: length, Type: "snapshot", Params: fmt.Sprintf("%s %s %s %d", s.Origin, s.COW, p, s.Chunk)}, nil
+}
+
+// SnapshotOrigin returns the snapshot-origin target that must replace
+// the table of a device with snapshots, so that writes to it copy chunks
+// to the snapshots first.
+func SnapshotOrigin(origin string, start, length uint64) (Target, error) {
+	if err := validDevice(origin); err != nil {
+		return Target{}, err
+	}
+	return Target{Start: start, Length: length, Type: "snapshot-origin", Params: origin}, nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dns implements a minimal DNS client for diagnosing name
+// resolution.
+//
+// Unlike the resolver in package net, it sends exactly the query it is asked
+// to, to exactly the server it is told to use, and returns every section of
+// the response along with timing information.
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DefaultTimeout is the time a Client waits for a response by default.
+const DefaultTimeout = 5 * time.Second
+
+// maxUDPSize is the largest response we accept over UDP. Without EDNS0,
+// servers truncate at 512 bytes; leave room for servers that don't.
+const maxUDPSize = 4096
+
+// Client sends queries to a single DNS server.
+type Client struct {
+	// Server is the server's address, with an optional port that
+	// defaults to 53.
+	Server string
+
+	// Timeout is how long to wait for each response. Zero means
+	// DefaultTimeout.
+	Timeout time.Duration
+
+	// TCP makes the client use TCP only. Otherwise, it uses UDP and
+	// falls back to TCP if the response is truncated.
+	TCP bool
+
+	// NoRecurse clears the recursion desired bit in queries.
+	NoRecurse bool
+}
+
+// Record is a resource record from a response.
+type Record struct {
+	Header dnsmessage.ResourceHeader
+
+	// Body is nil for record types the client does not decode.
+	Body dnsmessage.ResourceBody
+}
+
+// Response is a decoded DNS response.
+type Response struct {
+	dnsmessage.Header
+
+	Questions   []dnsmessage.Question
+	Answers     []Record
+	Authorities []Record
+	Additionals []Record
+
+	// Server is the address of the server that answered.
+	Server string
+
+	// TCP is set if the response was received over TCP.
+	TCP bool
+
+	// RTT is the time between sending the query and receiving the
+	// response.
+	RTT time.Duration
+
+	// Size is the length of the response message in bytes.
+	Size int
+}
+
+func (c *Client) addr() string {
+	if _, _, err := net.SplitHostPort(c.Server); err == nil {
+		return c.Server
+	}
+	return net.JoinHostPort(strings.Trim(c.Server, "[]"), "53")
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+// Query looks up records of type t for name. name is made fully
+// qualified if it is not already.
+func (c *Client) Query(name string, t dnsmessage.Type) (*Response, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+	q := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               uint16(rand.Uint32()),
+			RecursionDesired: !c.NoRecurse,
+		},
+		Questions: []dnsmessage.Question{{
+			Name:  n,
+			Type:  t,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	return c.Exchange(&q)
+}
+
+// Exchange sends q and returns the response.
+func (c *Client) Exchange(q *dnsmessage.Message) (*Response, error) {
+	b, err := q.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if !c.TCP {
+		r, err := c.exchange("udp", q.ID, b)
+		if err != nil || !r.Truncated {
+			return r, err
+		}
+	}
+	return c.exchange("tcp", q.ID, b)
+}
+
+func (c *Client) exchange(network string, id uint16, query []byte) (*Response, error) {
+	conn, err := net.DialTimeout(network, c.addr(), c.timeout())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(c.timeout())); err != nil {
+		return nil, err
+	}
+
+	var resp []byte
+	if network == "tcp" {
+		// Over TCP, messages are prefixed with a two byte length.
+		l := make([]byte, 2, 2+len(query))
+		binary.BigEndian.PutUint16(l, uint16(len(query)))
+		if _, err := conn.Write(append(l, query...)); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(l))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, maxUDPSize)
+		for {
+			n, err := conn.Read(resp)
+			if err != nil {
+				return nil, err
+			}
+			// Ignore stray datagrams with the wrong ID; they may be
+			// late answers to an earlier query.
+			if n >= 2 && binary.BigEndian.Uint16(resp) == id {
+				resp = resp[:n]
+				break
+			}
+		}
+	}
+	rtt := time.Since(start)
+
+	r, err := parse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if r.ID != id {
+		return nil, fmt.Errorf("response ID %d does not match query ID %d", r.ID, id)
+	}
+	if !r.Response {
+		return nil, errors.New("server sent a query, not a response")
+	}
+	r.Server = conn.RemoteAddr().String()
+	r.TCP = network == "tcp"
+	r.RTT = rtt
+	r.Size = len(resp)
+	return r, nil
+}
+
+// parse decodes a response message. Records of unknown type are kept with
+// a nil body rather than failing the whole message.
+func parse(msg []byte) (*Response, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+	r := &Response{Header: h}
+	if r.Questions, err = p.AllQuestions(); err != nil {
+		return nil, err
+	}
+
+	sections := []struct {
+		records *[]Record
+		header  func() (dnsmessage.ResourceHeader, error)
+		body    func() (dnsmessage.Resource, error)
+		skip    func() error
+	}{
+		{&r.Answers, p.AnswerHeader, p.Answer, p.SkipAnswer},
+		{&r.Authorities, p.AuthorityHeader, p.Authority, p.SkipAuthority},
+		{&r.Additionals, p.AdditionalHeader, p.Additional, p.SkipAdditional},
+	}
+	for _, s := range sections {
+		for {
+			rh, err := s.header()
+			if err == dnsmessage.ErrSectionDone {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !known(rh.Type) {
+				if err := s.skip(); err != nil {
+					return nil, err
+				}
+				*s.records = append(*s.records, Record{Header: rh})
+				continue
+			}
+			rr, err := s.body()
+			if err != nil {
+				return nil, err
+			}
+			*s.records = append(*s.records, Record{Header: rr.Header, Body: rr.Body})
+		}
+	}
+	return r, nil
+}
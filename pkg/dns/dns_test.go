@@ -0,0 +1,323 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubServer answers queries over UDP and TCP on the same port from a
+// fixed set of records.
+type stubServer struct {
+	udp     net.PacketConn
+	tcp     net.Listener
+	records []dnsmessage.Resource
+
+	// truncate makes UDP responses empty with the TC bit set.
+	truncate bool
+}
+
+func newStubServer(t *testing.T, records []dnsmessage.Resource) *stubServer {
+	t.Helper()
+	// The TCP port may be taken even if the UDP one is free; retry.
+	for i := 0; i < 10; i++ {
+		u, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.Listen("tcp", u.LocalAddr().String())
+		if err != nil {
+			u.Close()
+			continue
+		}
+		s := &stubServer{udp: u, tcp: l, records: records}
+		go s.serveUDP()
+		go s.serveTCP()
+		return s
+	}
+	t.Fatal("could not find a free port for both UDP and TCP")
+	return nil
+}
+
+func (s *stubServer) addr() string {
+	return s.udp.LocalAddr().String()
+}
+
+func (s *stubServer) close() {
+	s.udp.Close()
+	s.tcp.Close()
+}
+
+func (s *stubServer) answer(query []byte, tcp bool) []byte {
+	var q dnsmessage.Message
+	if err := q.Unpack(query); err != nil {
+		return nil
+	}
+	r := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 q.ID,
+			Response:           true,
+			Authoritative:      true,
+			RecursionDesired:   q.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Questions: q.Questions,
+	}
+	if s.truncate && !tcp {
+		r.Truncated = true
+	} else {
+		for _, rr := range s.records {
+			if rr.Header.Name == q.Questions[0].Name && rr.Header.Type == q.Questions[0].Type {
+				r.Answers = append(r.Answers, rr)
+			}
+		}
+		if len(r.Answers) == 0 {
+			r.RCode = dnsmessage.RCodeNameError
+		}
+	}
+	b, _ := r.Pack()
+	return b
+}
+
+func (s *stubServer) serveUDP() {
+	buf := make([]byte, 512)
+	for {
+		n, from, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.udp.WriteTo(s.answer(buf[:n], false), from)
+	}
+}
+
+func (s *stubServer) serveTCP() {
+	for {
+		c, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			l := make([]byte, 2)
+			if _, err := io.ReadFull(c, l); err != nil {
+				return
+			}
+			q := make([]byte, binary.BigEndian.Uint16(l))
+			if _, err := io.ReadFull(c, q); err != nil {
+				return
+			}
+			r := s.answer(q, true)
+			binary.BigEndian.PutUint16(l, uint16(len(r)))
+			c.Write(append(l, r...))
+		}()
+	}
+}
+
+func rr(name string, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	var t dnsmessage.Type
+	switch body.(type) {
+	case *dnsmessage.AResource:
+		t = dnsmessage.TypeA
+	case *dnsmessage.AAAAResource:
+		t = dnsmessage.TypeAAAA
+	case *dnsmessage.SRVResource:
+		t = dnsmessage.TypeSRV
+	case *dnsmessage.TXTResource:
+		t = dnsmessage.TypeTXT
+	case *dnsmessage.PTRResource:
+		t = dnsmessage.TypePTR
+	case *dnsmessage.CNAMEResource:
+		t = dnsmessage.TypeCNAME
+	}
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  t,
+			Class: dnsmessage.ClassINET,
+			TTL:   300,
+		},
+		Body: body,
+	}
+}
+
+var testRecords = []dnsmessage.Resource{
+	rr("host.example.", &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}),
+	rr("host.example.", &dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}),
+	rr("www.example.", &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("host.example.")}),
+	rr("_http._tcp.example.", &dnsmessage.SRVResource{Priority: 10, Weight: 5, Port: 80, Target: dnsmessage.MustNewName("host.example.")}),
+	rr("host.example.", &dnsmessage.TXTResource{TXT: []string{"v=1", "hello world"}}),
+	rr("1.2.0.192.in-addr.arpa.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("host.example.")}),
+}
+
+func TestQuery(t *testing.T) {
+	s := newStubServer(t, testRecords)
+	defer s.close()
+	c := &Client{Server: s.addr(), Timeout: time.Second}
+
+	for _, tt := range []struct {
+		name string
+		typ  string
+		want string
+	}{
+		{"host.example", "A", "192.0.2.1"},
+		{"host.example.", "AAAA", "2001:db8::1"},
+		{"www.example", "CNAME", "host.example."},
+		{"_http._tcp.example", "SRV", "10 5 80 host.example."},
+		{"host.example", "TXT", `"v=1" "hello world"`},
+		{"1.2.0.192.in-addr.arpa", "PTR", "host.example."},
+	} {
+		typ, err := ParseType(tt.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := c.Query(tt.name, typ)
+		if err != nil {
+			t.Errorf("Query(%q, %s): %v", tt.name, tt.typ, err)
+			continue
+		}
+		if r.TCP {
+			t.Errorf("Query(%q, %s) used TCP, want UDP", tt.name, tt.typ)
+		}
+		if len(r.Answers) != 1 {
+			t.Errorf("Query(%q, %s) = %d answers, want 1", tt.name, tt.typ, len(r.Answers))
+			continue
+		}
+		if got := r.Answers[0].Data(); got != tt.want {
+			t.Errorf("Query(%q, %s) = %q, want %q", tt.name, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestQueryNXDomain(t *testing.T) {
+	s := newStubServer(t, testRecords)
+	defer s.close()
+	c := &Client{Server: s.addr(), Timeout: time.Second}
+
+	r, err := c.Query("nope.example", dnsmessage.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("rcode = %s, want NXDOMAIN", RCodeString(r.RCode))
+	}
+}
+
+func TestTCPFallback(t *testing.T) {
+	s := newStubServer(t, testRecords)
+	defer s.close()
+	s.truncate = true
+	c := &Client{Server: s.addr(), Timeout: time.Second}
+
+	r, err := c.Query("host.example", dnsmessage.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.TCP || r.Truncated || len(r.Answers) != 1 {
+		t.Errorf("got TCP %t, truncated %t, %d answers; want true, false, 1", r.TCP, r.Truncated, len(r.Answers))
+	}
+
+	c.TCP = true
+	s.truncate = false
+	if r, err = c.Query("host.example", dnsmessage.TypeA); err != nil {
+		t.Fatal(err)
+	}
+	if !r.TCP {
+		t.Errorf("forced TCP query went over UDP")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	s := newStubServer(t, testRecords)
+	defer s.close()
+	c := &Client{Server: s.addr(), Timeout: time.Second}
+
+	r, err := c.Query("host.example", dnsmessage.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	r.Format(&b)
+	for _, want := range []string{
+		"status: NOERROR",
+		"flags: qr aa rd ra; QUERY: 1, ANSWER: 1, AUTHORITY: 0, ADDITIONAL: 0",
+		";host.example.\t\tIN\tA",
+		"host.example.\t300\tIN\tA\t192.0.2.1",
+		"SERVER: " + s.addr() + " (udp)",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	for _, tt := range []struct {
+		ip   string
+		want string
+	}{
+		{"192.0.2.1", "1.2.0.192.in-addr.arpa."},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	} {
+		got, err := ReverseName(net.ParseIP(tt.ip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("ReverseName(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want dnsmessage.Type
+		err  bool
+	}{
+		{s: "a", want: dnsmessage.TypeA},
+		{s: "AAAA", want: dnsmessage.TypeAAAA},
+		{s: "any", want: dnsmessage.TypeALL},
+		{s: "TYPE99", want: 99},
+		{s: "bogus", err: true},
+	} {
+		got, err := ParseType(tt.s)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseType(%q) = %v, %v; want %v, error %t", tt.s, got, err, tt.want, tt.err)
+		}
+	}
+	if s := TypeString(99); s != "TYPE99" {
+		t.Errorf("TypeString(99) = %q, want TYPE99", s)
+	}
+}
+
+func TestParseResolvConf(t *testing.T) {
+	rc, err := ParseResolvConf(strings.NewReader(`# written by dhclient
+domain example.com
+nameserver 192.0.2.53
+nameserver fe80::1%eth0
+nameserver bogus
+search example.com corp.example.com
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc.Domain != "example.com" {
+		t.Errorf("domain = %q, want example.com", rc.Domain)
+	}
+	if len(rc.Nameservers) != 2 || !rc.Nameservers[0].Equal(net.ParseIP("192.0.2.53")) || !rc.Nameservers[1].Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("nameservers = %v, want [192.0.2.53 fe80::1]", rc.Nameservers)
+	}
+	if strings.Join(rc.Search, " ") != "example.com corp.example.com" {
+		t.Errorf("search = %v, want [example.com corp.example.com]", rc.Search)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var typeNames = map[dnsmessage.Type]string{
+	dnsmessage.TypeA:     "A",
+	dnsmessage.TypeNS:    "NS",
+	dnsmessage.TypeCNAME: "CNAME",
+	dnsmessage.TypeSOA:   "SOA",
+	dnsmessage.TypePTR:   "PTR",
+	dnsmessage.TypeMX:    "MX",
+	dnsmessage.TypeTXT:   "TXT",
+	dnsmessage.TypeAAAA:  "AAAA",
+	dnsmessage.TypeSRV:   "SRV",
+	dnsmessage.TypeOPT:   "OPT",
+	dnsmessage.TypeALL:   "ANY",
+}
+
+var rcodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+// known reports whether records of type t can be decoded.
+func known(t dnsmessage.Type) bool {
+	_, ok := typeNames[t]
+	return ok && t != dnsmessage.TypeALL
+}
+
+// TypeString returns the mnemonic for t, e.g. "AAAA", or "TYPE<n>" for
+// types without one, as in RFC 3597.
+func TypeString(t dnsmessage.Type) string {
+	if s, ok := typeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+// ParseType parses a record type mnemonic such as "A" or "srv", or the
+// RFC 3597 form "TYPE<n>".
+func ParseType(s string) (dnsmessage.Type, error) {
+	u := strings.ToUpper(s)
+	for t, n := range typeNames {
+		if n == u {
+			return t, nil
+		}
+	}
+	if strings.HasPrefix(u, "TYPE") {
+		if n, err := strconv.ParseUint(u[4:], 10, 16); err == nil {
+			return dnsmessage.Type(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown record type %q", s)
+}
+
+// RCodeString returns the mnemonic for r, e.g. "NXDOMAIN".
+func RCodeString(r dnsmessage.RCode) string {
+	if s, ok := rcodeNames[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("RCODE%d", r)
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name used to look up
+// PTR records for ip.
+func ReverseName(ip net.IP) (string, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0]), nil
+	}
+	ip6 := ip.To16()
+	if ip6 == nil {
+		return "", fmt.Errorf("invalid IP address %v", ip)
+	}
+	var b strings.Builder
+	const hex = "0123456789abcdef"
+	for i := len(ip6) - 1; i >= 0; i-- {
+		b.WriteByte(hex[ip6[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hex[ip6[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String(), nil
+}
+
+// Data returns the record data of r in zone file presentation format.
+func (r Record) Data() string {
+	switch b := r.Body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(b.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(b.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return b.CNAME.String()
+	case *dnsmessage.NSResource:
+		return b.NS.String()
+	case *dnsmessage.PTRResource:
+		return b.PTR.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", b.Pref, b.MX)
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", b.Priority, b.Weight, b.Port, b.Target)
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d %d %d %d %d", b.NS, b.MBox, b.Serial, b.Refresh, b.Retry, b.Expire, b.MinTTL)
+	case *dnsmessage.TXTResource:
+		q := make([]string, len(b.TXT))
+		for i, t := range b.TXT {
+			q[i] = strconv.Quote(t)
+		}
+		return strings.Join(q, " ")
+	case *dnsmessage.OPTResource:
+		return fmt.Sprintf("; EDNS: udp %d, %d options", r.Header.Class, len(b.Options))
+	}
+	return fmt.Sprintf("\\# %d", r.Header.Length)
+}
+
+// String formats r like a zone file line.
+func (r Record) String() string {
+	return fmt.Sprintf("%s\t%d\tIN\t%s\t%s", r.Header.Name, r.Header.TTL, TypeString(r.Header.Type), r.Data())
+}
+
+func (r *Response) flags() string {
+	var f []string
+	for _, b := range []struct {
+		set  bool
+		name string
+	}{
+		{r.Response, "qr"},
+		{r.Authoritative, "aa"},
+		{r.Truncated, "tc"},
+		{r.RecursionDesired, "rd"},
+		{r.RecursionAvailable, "ra"},
+	} {
+		if b.set {
+			f = append(f, b.name)
+		}
+	}
+	return strings.Join(f, " ")
+}
+
+// Format writes r to w in the style of dig.
+func (r *Response) Format(w io.Writer) {
+	fmt.Fprintf(w, ";; ->>HEADER<<- opcode: %d, status: %s, id: %d\n", r.OpCode, RCodeString(r.RCode), r.ID)
+	fmt.Fprintf(w, ";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n",
+		r.flags(), len(r.Questions), len(r.Answers), len(r.Authorities), len(r.Additionals))
+
+	fmt.Fprintf(w, "\n;; QUESTION SECTION:\n")
+	for _, q := range r.Questions {
+		fmt.Fprintf(w, ";%s\t\tIN\t%s\n", q.Name, TypeString(q.Type))
+	}
+	for _, s := range []struct {
+		name    string
+		records []Record
+	}{
+		{"ANSWER", r.Answers},
+		{"AUTHORITY", r.Authorities},
+		{"ADDITIONAL", r.Additionals},
+	} {
+		if len(s.records) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n;; %s SECTION:\n", s.name)
+		for _, rr := range s.records {
+			fmt.Fprintln(w, rr)
+		}
+	}
+
+	proto := "udp"
+	if r.TCP {
+		proto = "tcp"
+	}
+	fmt.Fprintf(w, "\n;; Query time: %d msec\n", r.RTT.Milliseconds())
+	fmt.Fprintf(w, ";; SERVER: %s (%s)\n", r.Server, proto)
+	fmt.Fprintf(w, ";; MSG SIZE  rcvd: %d\n", r.Size)
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dns
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// DefaultResolvConf is where dhclient.WriteDNSSettings writes the DNS
+// configuration it received.
+const DefaultResolvConf = "/etc/resolv.conf"
+
+// ResolvConf is the part of a resolv.conf(5) file that matters for
+// choosing servers and completing names.
+type ResolvConf struct {
+	Nameservers []net.IP
+	Search      []string
+	Domain      string
+}
+
+// ParseResolvConf parses resolv.conf(5) contents. Unknown keywords and
+// malformed nameserver lines are ignored, like the C library does.
+func ParseResolvConf(r io.Reader) (*ResolvConf, error) {
+	rc := &ResolvConf{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 2 || strings.HasPrefix(f[0], "#") || strings.HasPrefix(f[0], ";") {
+			continue
+		}
+		switch f[0] {
+		case "nameserver":
+			// Drop any IPv6 zone, which net.ParseIP rejects.
+			if ip := net.ParseIP(strings.SplitN(f[1], "%", 2)[0]); ip != nil {
+				rc.Nameservers = append(rc.Nameservers, ip)
+			}
+		case "domain":
+			rc.Domain = f[1]
+		case "search":
+			rc.Search = f[1:]
+		}
+	}
+	return rc, s.Err()
+}
+
+// ReadResolvConf parses the resolv.conf file at path.
+func ReadResolvConf(path string) (*ResolvConf, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseResolvConf(f)
+}
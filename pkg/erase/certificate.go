@@ -0,0 +1,76 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package erase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Certificate records an erase for audit.
+type Certificate struct {
+	Device string
+	Model  string `json:",omitempty"`
+	Serial string `json:",omitempty"`
+	Size   int64
+
+	Method Method
+
+	// Passes is the number of overwrite passes.
+	Passes int `json:",omitempty"`
+
+	// Discarded is true if the overwritten blocks were discarded.
+	Discarded bool `json:",omitempty"`
+
+	Start time.Time
+	End   time.Time
+
+	Verification Verification
+
+	// PublicKey is the ed25519 key whose Signature covers the JSON
+	// encoding of the certificate without its Signature.
+	PublicKey []byte `json:",omitempty"`
+	Signature []byte `json:",omitempty"`
+}
+
+// ErrSignature is returned by Verify for a bad signature.
+var ErrSignature = errors.New("bad certificate signature")
+
+func (c *Certificate) signed() ([]byte, error) {
+	u := *c
+	u.Signature = nil
+	return json.Marshal(&u)
+}
+
+// Sign signs c with key.
+func (c *Certificate) Sign(key ed25519.PrivateKey) error {
+	c.PublicKey = key.Public().(ed25519.PublicKey)
+	b, err := c.signed()
+	if err != nil {
+		return err
+	}
+	c.Signature = ed25519.Sign(key, b)
+	return nil
+}
+
+// Verify checks the signature of c. If key is not nil, c must also have
+// been signed by key.
+func (c *Certificate) Verify(key ed25519.PublicKey) error {
+	if len(c.PublicKey) != ed25519.PublicKeySize || (key != nil && !bytes.Equal(key, c.PublicKey)) {
+		return ErrSignature
+	}
+	b, err := c.signed()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(c.PublicKey, b, c.Signature) {
+		return ErrSignature
+	}
+	return nil
+}
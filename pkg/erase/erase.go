@@ -0,0 +1,408 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package erase erases whole disks for decommissioning.
+//
+// For each disk the strongest method the hardware offers is chosen, from
+// NVMe and SCSI sanitize, through ATA security erase and NVMe format, down
+// to overwriting the disk from the host. The erase is checked by sampling
+// reads before and after, and described in a Certificate that can be
+// signed for audit.
+package erase
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/u-root/u-root/pkg/mount/nvme"
+	"github.com/u-root/u-root/pkg/mount/scuzz"
+)
+
+// Method is a way of erasing a disk.
+type Method string
+
+// These are the erase methods.
+const (
+	NVMeSanitizeCrypto    Method = "nvme-sanitize-crypto"
+	NVMeSanitizeBlock     Method = "nvme-sanitize-block"
+	NVMeFormatCrypto      Method = "nvme-format-crypto"
+	NVMeFormatUser        Method = "nvme-format-user"
+	SCSISanitizeCrypto    Method = "scsi-sanitize-crypto"
+	SCSISanitizeBlock     Method = "scsi-sanitize-block"
+	SCSISanitizeOverwrite Method = "scsi-sanitize-overwrite"
+	ATAEnhancedErase      Method = "ata-enhanced-erase"
+	ATAErase              Method = "ata-erase"
+	Overwrite             Method = "overwrite"
+)
+
+// Methods is every method, strongest first. Sanitize covers all media,
+// caches and spare blocks; an enhanced ATA erase covers reallocated
+// sectors; format and plain ATA erase only cover user addressable data,
+// and an overwrite from the host cannot reach spare or remapped blocks.
+var Methods = []Method{
+	NVMeSanitizeCrypto,
+	SCSISanitizeCrypto,
+	NVMeSanitizeBlock,
+	SCSISanitizeBlock,
+	ATAEnhancedErase,
+	NVMeFormatCrypto,
+	SCSISanitizeOverwrite,
+	NVMeFormatUser,
+	ATAErase,
+	Overwrite,
+}
+
+var (
+	// ErrUnavailable is returned when the requested method is not
+	// offered by the disk.
+	ErrUnavailable = errors.New("erase method not available")
+
+	// ErrVerification is returned when data survived the erase.
+	ErrVerification = errors.New("erase verification failed")
+)
+
+// Disk is the data of a disk being erased.
+type Disk interface {
+	io.ReaderAt
+	io.WriterAt
+
+	// Discard tells the disk that n bytes at off are no longer used.
+	Discard(off, n int64) error
+
+	// Flush writes out the data written so far and drops any cached
+	// data, so that the next reads come from the disk itself.
+	Flush() error
+}
+
+// ATA is a disk with the ATA security feature set, such as scuzz.SGDisk.
+type ATA interface {
+	Identify() (*scuzz.Info, error)
+	SetPassword(password string, admin bool) error
+	DisablePassword(password string, admin bool) error
+	SecurityErase(password string, admin, enhanced bool, timeout time.Duration) error
+}
+
+// NVMe is an NVMe controller, such as nvme.Device.
+type NVMe interface {
+	IdentifyController() (*nvme.Controller, error)
+	IdentifyNamespace(nsid uint32) (*nvme.Namespace, error)
+	Format(f *nvme.Format) error
+	Sanitize(s *nvme.Sanitize) error
+	SanitizeStatus() (*nvme.SanitizeStatus, error)
+}
+
+// SCSI is a disk taking SCSI commands, such as scuzz.SCSIDisk.
+type SCSI interface {
+	SanitizeSupported(a scuzz.SanitizeAction) (bool, error)
+	Sanitize(s *scuzz.Sanitize) error
+	SanitizeProgress() (bool, float64, error)
+}
+
+// Target is a disk to erase and the ways it can be reached. Any of ATA,
+// NVMe and SCSI may be nil.
+type Target struct {
+	// Name is the disk's name, e.g. "sda".
+	Name   string
+	Model  string
+	Serial string
+
+	// Size is the size of the disk in bytes.
+	Size int64
+
+	Disk Disk
+	ATA  ATA
+	SCSI SCSI
+	NVMe NVMe
+
+	// NSID is the NVMe namespace of Disk.
+	NSID uint32
+
+	closers []io.Closer
+}
+
+// Close releases the target's devices.
+func (t *Target) Close() error {
+	var err error
+	for _, c := range t.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Available returns the methods t offers, strongest first.
+//
+// SCSI disks that cannot report whether they support SANITIZE are taken
+// not to. ATA disks only offer security erase if security is not frozen
+// and no password is set, since we cannot know the password.
+func (t *Target) Available() ([]Method, error) {
+	have := map[Method]bool{}
+	if t.NVMe != nil {
+		c, err := t.NVMe.IdentifyController()
+		if err != nil {
+			return nil, err
+		}
+		have[NVMeSanitizeCrypto] = c.SANICAP&nvme.SANICAPCryptoErase != 0
+		have[NVMeSanitizeBlock] = c.SANICAP&nvme.SANICAPBlockErase != 0
+		have[NVMeFormatUser] = c.OACS&nvme.OACSFormat != 0
+		have[NVMeFormatCrypto] = have[NVMeFormatUser] && c.FNA&nvme.FormatCryptoErase != 0
+	}
+	if t.SCSI != nil {
+		for m, a := range map[Method]scuzz.SanitizeAction{
+			SCSISanitizeCrypto:    scuzz.SanitizeCryptoErase,
+			SCSISanitizeBlock:     scuzz.SanitizeBlockErase,
+			SCSISanitizeOverwrite: scuzz.SanitizeOverwrite,
+		} {
+			ok, err := t.SCSI.SanitizeSupported(a)
+			have[m] = ok && err == nil
+		}
+	}
+	if t.ATA != nil {
+		i, err := t.ATA.Identify()
+		if err != nil {
+			return nil, err
+		}
+		ok := i.SecuritySupported && !i.SecurityEnabled && !i.SecurityLocked && !i.SecurityFrozen && !i.SecurityCountExpired
+		have[ATAErase] = ok
+		have[ATAEnhancedErase] = ok && i.EnhancedEraseSupported
+	}
+	have[Overwrite] = t.Disk != nil
+
+	var ms []Method
+	for _, m := range Methods {
+		if have[m] {
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
+// Options control an erase.
+type Options struct {
+	// Method is the method to use. If empty, the strongest available
+	// method is used.
+	Method Method
+
+	// Passes is the number of overwrite passes, for Overwrite and
+	// SCSISanitizeOverwrite. All but the last Overwrite pass write
+	// random data; the last writes zeros. Default 1.
+	Passes int
+
+	// Samples is the number of SampleSize byte blocks read before and
+	// after the erase to check it. Default 64 and 4096.
+	Samples    int
+	SampleSize int
+
+	// Progress, if set, is called with the fraction of the erase done.
+	Progress func(m Method, done float64)
+
+	// Poll is how often to check on erases that run in the disk.
+	// Default 5 seconds.
+	Poll time.Duration
+
+	// Rand is the source of random data and sample offsets. Default
+	// crypto/rand.
+	Rand io.Reader
+}
+
+func (o *Options) defaults() Options {
+	d := Options{Passes: 1, Samples: 64, SampleSize: 4096, Poll: 5 * time.Second, Rand: rand.Reader, Progress: func(Method, float64) {}}
+	if o == nil {
+		return d
+	}
+	if o.Method != "" {
+		d.Method = o.Method
+	}
+	if o.Passes > 0 {
+		d.Passes = o.Passes
+	}
+	if o.Samples > 0 {
+		d.Samples = o.Samples
+	}
+	if o.SampleSize > 0 {
+		d.SampleSize = o.SampleSize
+	}
+	if o.Progress != nil {
+		d.Progress = o.Progress
+	}
+	if o.Poll > 0 {
+		d.Poll = o.Poll
+	}
+	if o.Rand != nil {
+		d.Rand = o.Rand
+	}
+	return d
+}
+
+// Erase erases t, destroying all its data, and returns a certificate of
+// the erase. If the erase ran but verification failed, the certificate
+// is returned along with an error wrapping ErrVerification.
+func Erase(t *Target, opts *Options) (*Certificate, error) {
+	o := opts.defaults()
+	avail, err := t.Available()
+	if err != nil {
+		return nil, err
+	}
+	m := o.Method
+	if m == "" {
+		if len(avail) == 0 {
+			return nil, fmt.Errorf("%s: %w", t.Name, ErrUnavailable)
+		}
+		m = avail[0]
+	} else if !hasMethod(avail, m) {
+		return nil, fmt.Errorf("%s: %s: %w", t.Name, m, ErrUnavailable)
+	}
+	o.Method = m
+
+	var s *samples
+	if t.Disk != nil {
+		if s, err = takeSamples(t.Disk, t.Size, o.Samples, o.SampleSize, o.Rand); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &Certificate{
+		Device: t.Name,
+		Model:  t.Model,
+		Serial: t.Serial,
+		Size:   t.Size,
+		Method: m,
+		Start:  time.Now().UTC(),
+	}
+	if m == Overwrite || m == SCSISanitizeOverwrite {
+		c.Passes = o.Passes
+	}
+	o.Progress(m, 0)
+	if err := run(t, m, &o); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", t.Name, m, err)
+	}
+	o.Progress(m, 1)
+
+	if s != nil {
+		if c.Verification, err = s.verify(t.Disk, m == Overwrite); err != nil {
+			return nil, err
+		}
+	}
+	if m == Overwrite {
+		c.Discarded = t.Disk.Discard(0, t.Size) == nil
+	}
+	c.End = time.Now().UTC()
+	if s != nil && !c.Verification.Passed {
+		return c, fmt.Errorf("%s: %d of %d samples unchanged: %w", t.Name, len(c.Verification.Unchanged), c.Verification.Samples, ErrVerification)
+	}
+	return c, nil
+}
+
+func hasMethod(ms []Method, m Method) bool {
+	for _, n := range ms {
+		if n == m {
+			return true
+		}
+	}
+	return false
+}
+
+// ataPassword is the temporary password set for an ATA security erase.
+// The erase clears it again; if the erase fails, it is disabled.
+const ataPassword = "u-root-erase"
+
+// ataTimeout is allowed for an ATA security erase when the disk gives
+// no estimate.
+const ataTimeout = 12 * time.Hour
+
+func run(t *Target, m Method, o *Options) error {
+	switch m {
+	case NVMeSanitizeCrypto:
+		return nvmeSanitize(t.NVMe, nvme.SanitizeCryptoErase, o)
+	case NVMeSanitizeBlock:
+		return nvmeSanitize(t.NVMe, nvme.SanitizeBlockErase, o)
+	case NVMeFormatCrypto, NVMeFormatUser:
+		ns, err := t.NVMe.IdentifyNamespace(t.NSID)
+		if err != nil {
+			return err
+		}
+		f := &nvme.Format{NSID: t.NSID, LBAFormat: ns.Format, MetadataAtEnd: ns.MetadataAtEnd, SecureErase: nvme.EraseUser}
+		if m == NVMeFormatCrypto {
+			f.SecureErase = nvme.EraseCrypto
+		}
+		return t.NVMe.Format(f)
+	case SCSISanitizeCrypto:
+		return scsiSanitize(t.SCSI, &scuzz.Sanitize{Action: scuzz.SanitizeCryptoErase}, o)
+	case SCSISanitizeBlock:
+		return scsiSanitize(t.SCSI, &scuzz.Sanitize{Action: scuzz.SanitizeBlockErase}, o)
+	case SCSISanitizeOverwrite:
+		return scsiSanitize(t.SCSI, &scuzz.Sanitize{Action: scuzz.SanitizeOverwrite, OverwritePasses: o.Passes, Pattern: make([]byte, 4)}, o)
+	case ATAEnhancedErase, ATAErase:
+		i, err := t.ATA.Identify()
+		if err != nil {
+			return err
+		}
+		enhanced := m == ATAEnhancedErase
+		timeout := i.SecurityEraseTime
+		if enhanced {
+			timeout = i.EnhancedEraseTime
+		}
+		if timeout == 0 {
+			timeout = ataTimeout
+		} else {
+			timeout *= 2
+		}
+		if err := t.ATA.SetPassword(ataPassword, false); err != nil {
+			return err
+		}
+		if err := t.ATA.SecurityErase(ataPassword, false, enhanced, timeout); err != nil {
+			// Do not leave the disk locked with our password.
+			if derr := t.ATA.DisablePassword(ataPassword, false); derr != nil {
+				return fmt.Errorf("%v; disabling password %q: %v", err, ataPassword, derr)
+			}
+			return err
+		}
+		return nil
+	case Overwrite:
+		return overwrite(t.Disk, t.Size, o)
+	}
+	return ErrUnavailable
+}
+
+func nvmeSanitize(n NVMe, a nvme.SanitizeAction, o *Options) error {
+	if err := n.Sanitize(&nvme.Sanitize{Action: a}); err != nil {
+		return err
+	}
+	for {
+		s, err := n.SanitizeStatus()
+		if err != nil {
+			return err
+		}
+		switch s.State {
+		case nvme.SanitizeStateCompleted, nvme.SanitizeStateCompletedNoDeallocate:
+			return nil
+		case nvme.SanitizeStateFailed:
+			return fmt.Errorf("sanitize failed")
+		case nvme.SanitizeStateInProgress:
+			o.Progress(o.Method, float64(s.Progress)/65536)
+		}
+		time.Sleep(o.Poll)
+	}
+}
+
+func scsiSanitize(d SCSI, s *scuzz.Sanitize, o *Options) error {
+	if err := d.Sanitize(s); err != nil {
+		return err
+	}
+	for {
+		busy, done, err := d.SanitizeProgress()
+		if err != nil {
+			return err
+		}
+		if !busy {
+			return nil
+		}
+		o.Progress(o.Method, done)
+		time.Sleep(o.Poll)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package erase
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/u-root/u-root/pkg/mount/block"
+	"github.com/u-root/u-root/pkg/mount/nvme"
+	"github.com/u-root/u-root/pkg/mount/scuzz"
+	"golang.org/x/sys/unix"
+)
+
+// SysfsPath is where block devices are described.
+var SysfsPath = "/sys/class/block"
+
+var nvmeName = regexp.MustCompile(`^(nvme[0-9]+)n([0-9]+)$`)
+
+// blkDiscard is the BLKDISCARD ioctl, _IO(0x12, 119).
+const blkDiscard = 0x1277
+
+// fileDisk is a block device file.
+type fileDisk struct {
+	*os.File
+}
+
+// Discard implements Disk.Discard with BLKDISCARD.
+func (f fileDisk) Discard(off, n int64) error {
+	r := [2]uint64{uint64(off), uint64(n)}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), blkDiscard, uintptr(unsafe.Pointer(&r))); errno != 0 {
+		return os.NewSyscallError("ioctl(BLKDISCARD)", errno)
+	}
+	return nil
+}
+
+// Flush implements Disk.Flush with fsync and BLKFLSBUF.
+func (f fileDisk) Flush() error {
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := unix.IoctlSetInt(int(f.Fd()), unix.BLKFLSBUF, 0); err != nil {
+		return os.NewSyscallError("ioctl(BLKFLSBUF)", err)
+	}
+	return nil
+}
+
+func sysfs(name, file string) string {
+	b, err := ioutil.ReadFile(filepath.Join(SysfsPath, name, file))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// Open opens the whole disk b for erasing. The disk is opened
+// exclusively, so it must not be mounted or otherwise in use.
+//
+// NVMe namespaces are reached through their controller. Other disks are
+// tried as ATA disks through SCSI generic, and as SCSI disks.
+func Open(b *block.BlockDev) (*Target, error) {
+	if _, err := os.Stat(filepath.Join(SysfsPath, b.Name, "partition")); err == nil {
+		return nil, fmt.Errorf("%s is a partition, not a whole disk", b.Name)
+	}
+	size, err := b.Size()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(b.DevicePath(), os.O_RDWR|unix.O_EXCL, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := &Target{
+		Name:    b.Name,
+		Model:   sysfs(b.Name, "device/model"),
+		Serial:  sysfs(b.Name, "device/serial"),
+		Size:    int64(size),
+		Disk:    fileDisk{f},
+		closers: []io.Closer{f},
+	}
+
+	if m := nvmeName.FindStringSubmatch(b.Name); m != nil {
+		nsid, err := strconv.ParseUint(sysfs(b.Name, "nsid"), 10, 32)
+		if err != nil {
+			nsid, _ = strconv.ParseUint(m[2], 10, 32)
+		}
+		d, err := nvme.Open(filepath.Join("/dev", m[1]))
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.NVMe, t.NSID = d, uint32(nsid)
+		t.closers = append(t.closers, d)
+		if c, err := d.IdentifyController(); err == nil {
+			t.Model, t.Serial = c.Model, c.Serial
+		}
+		return t, nil
+	}
+
+	if d, err := scuzz.NewSGDisk(b.DevicePath()); err == nil {
+		t.ATA = d
+		t.closers = append(t.closers, d)
+		if i, err := d.Identify(); err == nil {
+			t.Model, t.Serial = i.Model, i.Serial
+		}
+	}
+	if d, err := scuzz.NewSCSIDisk(b.DevicePath()); err == nil {
+		t.SCSI = d
+		t.closers = append(t.closers, d)
+	}
+	return t, nil
+}
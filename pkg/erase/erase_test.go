@@ -0,0 +1,548 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package erase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/mount/nvme"
+	"github.com/u-root/u-root/pkg/mount/scuzz"
+	"golang.org/x/crypto/ed25519"
+)
+
+const diskSize = 64 << 10
+
+// fakeDisk is a disk behind a cache, like a block device behind the page
+// cache: until it is flushed, reads see the data from before an erase in
+// the disk.
+type fakeDisk struct {
+	data      []byte
+	cache     []byte
+	flushes   int
+	discarded bool
+}
+
+func newFakeDisk() *fakeDisk {
+	d := &fakeDisk{data: make([]byte, diskSize)}
+	rand.New(rand.NewSource(1)).Read(d.data)
+	return d
+}
+
+func (d *fakeDisk) ReadAt(b []byte, off int64) (int, error) {
+	if d.cache != nil {
+		return copy(b, d.cache[off:]), nil
+	}
+	return copy(b, d.data[off:]), nil
+}
+
+func (d *fakeDisk) WriteAt(b []byte, off int64) (int, error) {
+	if d.cache != nil {
+		copy(d.cache[off:], b)
+	}
+	return copy(d.data[off:], b), nil
+}
+
+func (d *fakeDisk) Flush() error {
+	d.cache = nil
+	d.flushes++
+	return nil
+}
+
+// cached keeps the data as it is in the cache, for an erase in the disk.
+func (d *fakeDisk) cached() {
+	if d.cache == nil {
+		d.cache = append([]byte(nil), d.data...)
+	}
+}
+
+func (d *fakeDisk) Discard(off, n int64) error {
+	d.discarded = true
+	return nil
+}
+
+// zero is what a block erase in the disk looks like from the host.
+func (d *fakeDisk) zero() {
+	d.cached()
+	for i := range d.data {
+		d.data[i] = 0
+	}
+}
+
+// scramble is what a crypto erase in the disk looks like from the host.
+func (d *fakeDisk) scramble() {
+	d.cached()
+	rand.New(rand.NewSource(2)).Read(d.data)
+}
+
+type fakeNVMe struct {
+	ctrl    nvme.Controller
+	disk    *fakeDisk
+	format  *nvme.Format
+	action  nvme.SanitizeAction
+	polls   int
+	noop    bool
+	failing bool
+}
+
+func (n *fakeNVMe) IdentifyController() (*nvme.Controller, error) {
+	return &n.ctrl, nil
+}
+
+func (n *fakeNVMe) IdentifyNamespace(nsid uint32) (*nvme.Namespace, error) {
+	if nsid != 1 {
+		return nil, fmt.Errorf("bad nsid %d", nsid)
+	}
+	return &nvme.Namespace{ID: 1, Format: 1}, nil
+}
+
+func (n *fakeNVMe) Format(f *nvme.Format) error {
+	n.format = f
+	switch {
+	case n.noop:
+	case f.SecureErase == nvme.EraseCrypto:
+		n.disk.scramble()
+	default:
+		n.disk.zero()
+	}
+	return nil
+}
+
+func (n *fakeNVMe) Sanitize(s *nvme.Sanitize) error {
+	n.action = s.Action
+	return nil
+}
+
+func (n *fakeNVMe) SanitizeStatus() (*nvme.SanitizeStatus, error) {
+	n.polls++
+	if n.polls < 3 {
+		return &nvme.SanitizeStatus{State: nvme.SanitizeStateInProgress, Progress: uint16(n.polls * 0x5000)}, nil
+	}
+	if n.failing {
+		return &nvme.SanitizeStatus{State: nvme.SanitizeStateFailed}, nil
+	}
+	if n.action == nvme.SanitizeCryptoErase {
+		n.disk.scramble()
+	} else {
+		n.disk.zero()
+	}
+	return &nvme.SanitizeStatus{State: nvme.SanitizeStateCompleted}, nil
+}
+
+type fakeATA struct {
+	info     scuzz.Info
+	disk     *fakeDisk
+	password string
+	enhanced bool
+	timeout  time.Duration
+	failing  bool
+}
+
+func (a *fakeATA) Identify() (*scuzz.Info, error) {
+	i := a.info
+	return &i, nil
+}
+
+func (a *fakeATA) SetPassword(password string, admin bool) error {
+	if admin {
+		return fmt.Errorf("unexpected admin password")
+	}
+	a.password = password
+	a.info.SecurityEnabled = true
+	return nil
+}
+
+func (a *fakeATA) SecurityErase(password string, admin, enhanced bool, timeout time.Duration) error {
+	if !a.info.SecurityEnabled || password != a.password {
+		return fmt.Errorf("security erase: wrong password")
+	}
+	if a.failing {
+		return fmt.Errorf("security erase: aborted")
+	}
+	a.enhanced, a.timeout = enhanced, timeout
+	a.info.SecurityEnabled = false
+	a.disk.zero()
+	return nil
+}
+
+func (a *fakeATA) DisablePassword(password string, admin bool) error {
+	if admin || !a.info.SecurityEnabled || password != a.password {
+		return fmt.Errorf("disable password: wrong password")
+	}
+	a.info.SecurityEnabled = false
+	return nil
+}
+
+type fakeSCSI struct {
+	supported map[scuzz.SanitizeAction]bool
+	disk      *fakeDisk
+	sanitize  *scuzz.Sanitize
+	polls     int
+}
+
+func (s *fakeSCSI) SanitizeSupported(a scuzz.SanitizeAction) (bool, error) {
+	if s.supported == nil {
+		return false, fmt.Errorf("REPORT SUPPORTED OPERATION CODES not supported")
+	}
+	return s.supported[a], nil
+}
+
+func (s *fakeSCSI) Sanitize(z *scuzz.Sanitize) error {
+	s.sanitize = z
+	return nil
+}
+
+func (s *fakeSCSI) SanitizeProgress() (bool, float64, error) {
+	s.polls++
+	if s.polls < 2 {
+		return true, 0.5, nil
+	}
+	if s.sanitize.Action == scuzz.SanitizeCryptoErase {
+		s.disk.scramble()
+	} else {
+		s.disk.zero()
+	}
+	return false, 1, nil
+}
+
+var (
+	nvmeAll = nvme.Controller{
+		OACS:    nvme.OACSFormat,
+		FNA:     nvme.FormatCryptoErase,
+		SANICAP: nvme.SANICAPCryptoErase | nvme.SANICAPBlockErase,
+	}
+	ataOK = scuzz.Info{SecuritySupported: true, EnhancedEraseSupported: true, EnhancedEraseTime: time.Hour}
+)
+
+func TestAvailable(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		t    *Target
+		want []Method
+	}{
+		{
+			name: "nvme",
+			t:    &Target{Disk: newFakeDisk(), NVMe: &fakeNVMe{ctrl: nvmeAll}},
+			want: []Method{NVMeSanitizeCrypto, NVMeSanitizeBlock, NVMeFormatCrypto, NVMeFormatUser, Overwrite},
+		},
+		{
+			name: "nvme format only",
+			t:    &Target{Disk: newFakeDisk(), NVMe: &fakeNVMe{ctrl: nvme.Controller{OACS: nvme.OACSFormat}}},
+			want: []Method{NVMeFormatUser, Overwrite},
+		},
+		{
+			name: "ata",
+			t:    &Target{Disk: newFakeDisk(), ATA: &fakeATA{info: ataOK}},
+			want: []Method{ATAEnhancedErase, ATAErase, Overwrite},
+		},
+		{
+			name: "ata frozen",
+			t:    &Target{Disk: newFakeDisk(), ATA: &fakeATA{info: scuzz.Info{SecuritySupported: true, SecurityFrozen: true}}},
+			want: []Method{Overwrite},
+		},
+		{
+			name: "ata password set",
+			t:    &Target{Disk: newFakeDisk(), ATA: &fakeATA{info: scuzz.Info{SecuritySupported: true, SecurityEnabled: true}}},
+			want: []Method{Overwrite},
+		},
+		{
+			name: "sas",
+			t: &Target{Disk: newFakeDisk(), SCSI: &fakeSCSI{supported: map[scuzz.SanitizeAction]bool{
+				scuzz.SanitizeBlockErase: true,
+				scuzz.SanitizeOverwrite:  true,
+			}}},
+			want: []Method{SCSISanitizeBlock, SCSISanitizeOverwrite, Overwrite},
+		},
+		{
+			name: "ata and scsi",
+			t: &Target{
+				Disk: newFakeDisk(),
+				ATA:  &fakeATA{info: scuzz.Info{SecuritySupported: true}},
+				SCSI: &fakeSCSI{supported: map[scuzz.SanitizeAction]bool{scuzz.SanitizeCryptoErase: true}},
+			},
+			want: []Method{SCSISanitizeCrypto, ATAErase, Overwrite},
+		},
+		{
+			name: "scsi without opcode reports",
+			t:    &Target{Disk: newFakeDisk(), SCSI: &fakeSCSI{}},
+			want: []Method{Overwrite},
+		},
+		{
+			name: "nothing",
+			t:    &Target{},
+		},
+	} {
+		got, err := tt.t.Available()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func newTarget(d *fakeDisk) *Target {
+	return &Target{
+		Name:   "disk0",
+		Model:  "Fake Disk",
+		Serial: "F00",
+		Size:   diskSize,
+		Disk:   d,
+		NSID:   1,
+	}
+}
+
+var testOpts = Options{Samples: 16, SampleSize: 512, Poll: time.Nanosecond}
+
+func TestErase(t *testing.T) {
+	for _, tt := range []struct {
+		m      Method
+		setup  func(*Target)
+		crypto bool
+		check  func(*testing.T, *Target)
+	}{
+		{
+			m:      NVMeSanitizeCrypto,
+			setup:  func(t *Target) { t.NVMe = &fakeNVMe{ctrl: nvmeAll, disk: t.Disk.(*fakeDisk)} },
+			crypto: true,
+		},
+		{
+			m:     NVMeSanitizeBlock,
+			setup: func(t *Target) { t.NVMe = &fakeNVMe{ctrl: nvmeAll, disk: t.Disk.(*fakeDisk)} },
+		},
+		{
+			m:      NVMeFormatCrypto,
+			setup:  func(t *Target) { t.NVMe = &fakeNVMe{ctrl: nvmeAll, disk: t.Disk.(*fakeDisk)} },
+			crypto: true,
+			check: func(t *testing.T, tg *Target) {
+				f := tg.NVMe.(*fakeNVMe).format
+				if f.NSID != 1 || f.LBAFormat != 1 || f.SecureErase != nvme.EraseCrypto {
+					t.Errorf("format: got %+v", f)
+				}
+			},
+		},
+		{
+			m:     NVMeFormatUser,
+			setup: func(t *Target) { t.NVMe = &fakeNVMe{ctrl: nvmeAll, disk: t.Disk.(*fakeDisk)} },
+		},
+		{
+			m:     ATAEnhancedErase,
+			setup: func(t *Target) { t.ATA = &fakeATA{info: ataOK, disk: t.Disk.(*fakeDisk)} },
+			check: func(t *testing.T, tg *Target) {
+				a := tg.ATA.(*fakeATA)
+				if !a.enhanced || a.timeout != 2*time.Hour || a.info.SecurityEnabled {
+					t.Errorf("ata erase: enhanced %v, timeout %v, password left set %v", a.enhanced, a.timeout, a.info.SecurityEnabled)
+				}
+			},
+		},
+		{
+			m:     ATAErase,
+			setup: func(t *Target) { t.ATA = &fakeATA{info: ataOK, disk: t.Disk.(*fakeDisk)} },
+			check: func(t *testing.T, tg *Target) {
+				if a := tg.ATA.(*fakeATA); a.enhanced || a.timeout != ataTimeout {
+					t.Errorf("ata erase: enhanced %v, timeout %v", a.enhanced, a.timeout)
+				}
+			},
+		},
+		{
+			m: SCSISanitizeCrypto,
+			setup: func(t *Target) {
+				t.SCSI = &fakeSCSI{supported: map[scuzz.SanitizeAction]bool{scuzz.SanitizeCryptoErase: true}, disk: t.Disk.(*fakeDisk)}
+			},
+			crypto: true,
+		},
+		{
+			m: SCSISanitizeOverwrite,
+			setup: func(t *Target) {
+				t.SCSI = &fakeSCSI{supported: map[scuzz.SanitizeAction]bool{scuzz.SanitizeOverwrite: true}, disk: t.Disk.(*fakeDisk)}
+			},
+			check: func(t *testing.T, tg *Target) {
+				if s := tg.SCSI.(*fakeSCSI).sanitize; s.OverwritePasses != 1 || len(s.Pattern) != 4 {
+					t.Errorf("scsi overwrite: got %+v", s)
+				}
+			},
+		},
+		{
+			m: Overwrite,
+			check: func(t *testing.T, tg *Target) {
+				if !tg.Disk.(*fakeDisk).discarded {
+					t.Errorf("overwrite: disk not discarded")
+				}
+			},
+		},
+	} {
+		t.Run(string(tt.m), func(t *testing.T) {
+			d := newFakeDisk()
+			orig := append([]byte(nil), d.data...)
+			tg := newTarget(d)
+			if tt.setup != nil {
+				tt.setup(tg)
+			}
+			var progress []float64
+			o := testOpts
+			o.Method = tt.m
+			o.Progress = func(m Method, done float64) {
+				if m != tt.m {
+					t.Errorf("progress for %q, want %q", m, tt.m)
+				}
+				progress = append(progress, done)
+			}
+			c, err := Erase(tg, &o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Method != tt.m || c.Device != "disk0" || c.Serial != "F00" || c.Size != diskSize {
+				t.Errorf("certificate: got %+v", c)
+			}
+			if v := c.Verification; !v.Passed || v.Samples == 0 || v.Changed != v.Samples || v.SampleSize != 512 {
+				t.Errorf("verification: got %+v", v)
+			}
+			if c.End.Before(c.Start) {
+				t.Errorf("certificate ends %v before it starts %v", c.End, c.Start)
+			}
+			if len(progress) < 2 || progress[0] != 0 || progress[len(progress)-1] != 1 {
+				t.Errorf("progress: got %v", progress)
+			}
+			if tt.crypto {
+				if bytes.Equal(d.data, orig) {
+					t.Errorf("data unchanged by crypto erase")
+				}
+			} else if !bytes.Equal(d.data, make([]byte, diskSize)) {
+				t.Errorf("data not zeroed")
+			}
+			if tt.check != nil {
+				tt.check(t, tg)
+			}
+		})
+	}
+}
+
+func TestEraseStrongest(t *testing.T) {
+	d := newFakeDisk()
+	tg := newTarget(d)
+	tg.ATA = &fakeATA{info: ataOK, disk: d}
+	tg.SCSI = &fakeSCSI{supported: map[scuzz.SanitizeAction]bool{scuzz.SanitizeOverwrite: true}, disk: d}
+	c, err := Erase(tg, &testOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Method != ATAEnhancedErase {
+		t.Errorf("method: got %v, want %v", c.Method, ATAEnhancedErase)
+	}
+}
+
+func TestEraseUnavailable(t *testing.T) {
+	tg := newTarget(newFakeDisk())
+	tg.ATA = &fakeATA{info: scuzz.Info{SecuritySupported: true, SecurityFrozen: true}}
+	o := testOpts
+	o.Method = ATAErase
+	if _, err := Erase(tg, &o); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Erase(frozen ATA): got %v, want %v", err, ErrUnavailable)
+	}
+	if _, err := Erase(&Target{Name: "empty"}, &testOpts); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Erase(no methods): got %v, want %v", err, ErrUnavailable)
+	}
+}
+
+func TestEraseFailures(t *testing.T) {
+	d := newFakeDisk()
+	tg := newTarget(d)
+	tg.NVMe = &fakeNVMe{ctrl: nvme.Controller{OACS: nvme.OACSFormat}, disk: d, noop: true}
+	c, err := Erase(tg, &testOpts)
+	if !errors.Is(err, ErrVerification) {
+		t.Fatalf("Erase(no-op format): got %v, want %v", err, ErrVerification)
+	}
+	if c == nil || c.Verification.Passed || len(c.Verification.Unchanged) != c.Verification.Samples {
+		t.Errorf("Erase(no-op format): got certificate %+v", c)
+	}
+
+	tg.NVMe = &fakeNVMe{ctrl: nvmeAll, disk: d, failing: true}
+	if _, err := Erase(tg, &testOpts); err == nil {
+		t.Errorf("Erase(failing sanitize): got nil, want error")
+	}
+
+	tg = newTarget(newFakeDisk())
+	a := &fakeATA{info: scuzz.Info{SecuritySupported: true}, failing: true}
+	tg.ATA = a
+	if _, err := Erase(tg, &testOpts); err == nil {
+		t.Errorf("Erase(failing ATA erase): got nil, want error")
+	}
+	if a.info.SecurityEnabled {
+		t.Errorf("Erase(failing ATA erase) left the password set")
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	d := newFakeDisk()
+	// Start with zeros, which prove nothing, and a little data that a
+	// broken overwrite could leave behind.
+	d.data = make([]byte, diskSize)
+	copy(d.data[4096:], "secret")
+	tg := newTarget(d)
+	o := testOpts
+	o.Passes = 3
+	o.Samples = 1000
+	o.Rand = rand.New(rand.NewSource(5))
+	c, err := Erase(tg, &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := c.Verification
+	if c.Passes != 3 || !c.Discarded || !v.Passed || v.Zero != v.Samples || v.Uniform != v.Samples-1 || v.Changed != 1 {
+		t.Errorf("certificate: got %+v", c)
+	}
+	// Each pass is flushed, and the disk again before it is verified.
+	if d.flushes != 4 {
+		t.Errorf("overwrite flushed %d times, want 4", d.flushes)
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.New(rand.NewSource(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Erase(newTarget(newFakeDisk()), &testOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify(nil); err != ErrSignature {
+		t.Errorf("Verify(unsigned): got %v, want %v", err, ErrSignature)
+	}
+	if err := c.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Certificate
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Verify(pub); err != nil {
+		t.Errorf("Verify: got %v, want nil", err)
+	}
+
+	other, _, err := ed25519.GenerateKey(rand.New(rand.NewSource(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Verify(other); err != ErrSignature {
+		t.Errorf("Verify(other key): got %v, want %v", err, ErrSignature)
+	}
+	got.Method = NVMeSanitizeCrypto
+	if err := got.Verify(nil); err != ErrSignature {
+		t.Errorf("Verify(tampered): got %v, want %v", err, ErrSignature)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package erase
+
+import (
+	"io"
+)
+
+// overwriteChunk is the size of each write of an overwrite.
+const overwriteChunk = 1 << 20
+
+// overwrite writes o.Passes passes over the size bytes of d: random data,
+// then zeros on the last pass.
+func overwrite(d Disk, size int64, o *Options) error {
+	buf := make([]byte, overwriteChunk)
+	total := float64(size) * float64(o.Passes)
+	for pass := 0; pass < o.Passes; pass++ {
+		last := pass == o.Passes-1
+		if last {
+			for i := range buf {
+				buf[i] = 0
+			}
+		}
+		for off := int64(0); off < size; off += int64(len(buf)) {
+			b := buf
+			if n := size - off; n < int64(len(b)) {
+				b = b[:n]
+			}
+			if !last {
+				if _, err := io.ReadFull(o.Rand, b); err != nil {
+					return err
+				}
+			}
+			if _, err := d.WriteAt(b, off); err != nil {
+				return err
+			}
+			o.Progress(o.Method, (float64(pass)*float64(size)+float64(off+int64(len(b))))/total)
+		}
+		if err := d.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package erase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// Verification describes the reads that check an erase.
+type Verification struct {
+	// Samples blocks of SampleSize bytes were read before and after.
+	Samples    int
+	SampleSize int
+
+	// Changed is the number of samples that differ after the erase, and
+	// Zero the number that read as zeros after it.
+	Changed int
+	Zero    int
+
+	// Uniform is the number of samples that were a single repeated byte
+	// before the erase. They are no evidence either way: a block erase
+	// may well leave them as they were.
+	Uniform int
+
+	// Unchanged are the offsets of samples that held data and still
+	// hold it.
+	Unchanged []int64 `json:",omitempty"`
+
+	// Passed is true if no data survived and, for an overwrite, every
+	// sample reads as zeros.
+	Passed bool
+}
+
+// samples are blocks of a disk read before an erase.
+type samples struct {
+	size int
+	off  []int64
+	data [][]byte
+}
+
+// takeSamples reads n blocks of size bytes at random block aligned
+// offsets of a disk of total bytes.
+func takeSamples(d io.ReaderAt, total int64, n, size int, rnd io.Reader) (*samples, error) {
+	if int64(size) > total {
+		size = int(total)
+	}
+	s := &samples{size: size}
+	if size == 0 {
+		return s, nil
+	}
+	blocks := uint64(total / int64(size))
+	seen := map[int64]bool{}
+	var r [8]byte
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(rnd, r[:]); err != nil {
+			return nil, err
+		}
+		off := int64(binary.LittleEndian.Uint64(r[:])%blocks) * int64(size)
+		if seen[off] {
+			continue
+		}
+		seen[off] = true
+		s.off = append(s.off, off)
+	}
+	sort.Slice(s.off, func(i, j int) bool { return s.off[i] < s.off[j] })
+	for _, off := range s.off {
+		b := make([]byte, size)
+		if _, err := d.ReadAt(b, off); err != nil {
+			return nil, err
+		}
+		s.data = append(s.data, b)
+	}
+	return s, nil
+}
+
+// uniform reports whether b is one repeated byte.
+func uniform(b []byte) bool {
+	for _, c := range b {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// verify rereads the samples after an erase. The disk is flushed first,
+// so that the reads see what is on the disk rather than cached data from
+// before the erase.
+func (s *samples) verify(d Disk, wantZero bool) (Verification, error) {
+	v := Verification{Samples: len(s.off), SampleSize: s.size, Passed: true}
+	if err := d.Flush(); err != nil {
+		return v, err
+	}
+	b := make([]byte, s.size)
+	for i, off := range s.off {
+		if _, err := d.ReadAt(b, off); err != nil {
+			return v, err
+		}
+		zero := uniform(b) && (len(b) == 0 || b[0] == 0)
+		if zero {
+			v.Zero++
+		} else if wantZero {
+			v.Passed = false
+		}
+		switch {
+		case !bytes.Equal(b, s.data[i]):
+			v.Changed++
+		case uniform(s.data[i]):
+			v.Uniform++
+		default:
+			v.Unchanged = append(v.Unchanged, off)
+			v.Passed = false
+		}
+	}
+	return v, nil
+}
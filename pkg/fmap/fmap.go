@@ -0,0 +1,244 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fmap parses and edits coreboot/ChromeOS flash maps.
+//
+// An FMAP is a table, somewhere in a flash image, naming areas of the
+// image, e.g. RO_SECTION, RW_SECTION_A or COREBOOT. Areas may nest.
+package fmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/mount/mtd"
+)
+
+// Signature starts every FMAP.
+const Signature = "__FMAP__"
+
+const (
+	headerSize = 56
+	areaSize   = 42
+	nameSize   = 32
+)
+
+// These are the FMAP versions we understand.
+const (
+	VersionMajor = 1
+	VersionMinor = 1
+)
+
+// Area flags.
+const (
+	AreaStatic     = 1 << 0
+	AreaCompressed = 1 << 1
+	AreaReadOnly   = 1 << 2
+	AreaPreserve   = 1 << 3
+)
+
+var flagNames = []string{"static", "compressed", "ro", "preserve"}
+
+// ErrNotFound is returned when an image has no FMAP.
+var ErrNotFound = errors.New("no FMAP found")
+
+// Area is a named area of the image.
+type Area struct {
+	Offset uint32
+	Size   uint32
+	Name   string
+	Flags  uint16
+}
+
+// FlagString returns the names of the area's flags.
+func (a *Area) FlagString() string {
+	var s []string
+	for i, n := range flagNames {
+		if a.Flags&(1<<uint(i)) != 0 {
+			s = append(s, n)
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+// FMap is a flash map.
+type FMap struct {
+	VerMajor uint8
+	VerMinor uint8
+
+	// Base is the physical address of the flash image, and Size its
+	// size.
+	Base uint64
+	Size uint32
+	Name string
+
+	Areas []Area
+}
+
+func cstring(b []byte) (string, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", fmt.Errorf("name %q is not NUL terminated", b)
+	}
+	return string(b[:i]), nil
+}
+
+// Parse parses the FMAP at the start of b.
+func Parse(b []byte) (*FMap, error) {
+	if len(b) < headerSize || string(b[:8]) != Signature {
+		return nil, ErrNotFound
+	}
+	le := binary.LittleEndian
+	f := &FMap{
+		VerMajor: b[8],
+		VerMinor: b[9],
+		Base:     le.Uint64(b[10:]),
+		Size:     le.Uint32(b[18:]),
+	}
+	if f.VerMajor != VersionMajor {
+		return nil, fmt.Errorf("unsupported FMAP version %d.%d", f.VerMajor, f.VerMinor)
+	}
+	var err error
+	if f.Name, err = cstring(b[22:54]); err != nil {
+		return nil, err
+	}
+	n := int(le.Uint16(b[54:]))
+	if len(b) < headerSize+n*areaSize {
+		return nil, fmt.Errorf("FMAP has %d areas but only %d bytes", n, len(b))
+	}
+	for i := 0; i < n; i++ {
+		a := b[headerSize+i*areaSize:]
+		name, err := cstring(a[8:40])
+		if err != nil {
+			return nil, fmt.Errorf("area %d: %v", i, err)
+		}
+		f.Areas = append(f.Areas, Area{
+			Offset: le.Uint32(a[0:]),
+			Size:   le.Uint32(a[4:]),
+			Name:   name,
+			Flags:  le.Uint16(a[40:]),
+		})
+	}
+	return f, nil
+}
+
+// Find finds and parses the FMAP in a flash image, returning it and its
+// offset. Signatures in data that do not parse as an FMAP, or whose
+// size does not match the image, are skipped.
+func Find(image []byte) (*FMap, int64, error) {
+	for off := 0; ; {
+		i := bytes.Index(image[off:], []byte(Signature))
+		if i < 0 {
+			return nil, 0, ErrNotFound
+		}
+		off += i
+		if f, err := Parse(image[off:]); err == nil && int(f.Size) == len(image) && f.Validate() == nil {
+			return f, int64(off), nil
+		}
+		off++
+	}
+}
+
+// Validate checks that the areas fit in the image and have good names.
+func (f *FMap) Validate() error {
+	if len(f.Name) >= nameSize {
+		return fmt.Errorf("FMAP name %q is too long", f.Name)
+	}
+	seen := map[string]bool{}
+	for _, a := range f.Areas {
+		if a.Name == "" || len(a.Name) >= nameSize {
+			return fmt.Errorf("bad area name %q", a.Name)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("duplicate area %q", a.Name)
+		}
+		seen[a.Name] = true
+		if uint64(a.Offset)+uint64(a.Size) > uint64(f.Size) {
+			return fmt.Errorf("area %q (%#x+%#x) is outside the %#x byte image", a.Name, a.Offset, a.Size, f.Size)
+		}
+	}
+	return nil
+}
+
+// Area returns the area called name, or nil.
+func (f *FMap) Area(name string) *Area {
+	for i := range f.Areas {
+		if f.Areas[i].Name == name {
+			return &f.Areas[i]
+		}
+	}
+	return nil
+}
+
+// Remove removes the area called name.
+func (f *FMap) Remove(name string) error {
+	for i := range f.Areas {
+		if f.Areas[i].Name == name {
+			f.Areas = append(f.Areas[:i], f.Areas[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no area %q", name)
+}
+
+// Len returns the size of the encoded FMAP.
+func (f *FMap) Len() int {
+	return headerSize + len(f.Areas)*areaSize
+}
+
+// Marshal encodes f.
+func (f *FMap) Marshal() ([]byte, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if len(f.Areas) > 0xffff {
+		return nil, fmt.Errorf("too many areas: %d", len(f.Areas))
+	}
+	le := binary.LittleEndian
+	b := make([]byte, f.Len())
+	copy(b, Signature)
+	b[8], b[9] = f.VerMajor, f.VerMinor
+	le.PutUint64(b[10:], f.Base)
+	le.PutUint32(b[18:], f.Size)
+	copy(b[22:54], f.Name)
+	le.PutUint16(b[54:], uint16(len(f.Areas)))
+	for i, a := range f.Areas {
+		p := b[headerSize+i*areaSize:]
+		le.PutUint32(p[0:], a.Offset)
+		le.PutUint32(p[4:], a.Size)
+		copy(p[8:40], a.Name)
+		le.PutUint16(p[40:], a.Flags)
+	}
+	return b, nil
+}
+
+// Write encodes f into image at off, the offset it was found at. If the
+// image has an area called "FMAP", the encoded FMAP must fit in it.
+func (f *FMap) Write(image []byte, off int64) error {
+	b, err := f.Marshal()
+	if err != nil {
+		return err
+	}
+	end := int64(len(image))
+	if a := f.Area("FMAP"); a != nil && int64(a.Offset) == off {
+		end = off + int64(a.Size)
+	}
+	if off < 0 || off+int64(len(b)) > end {
+		return fmt.Errorf("FMAP of %d bytes does not fit at %#x", len(b), off)
+	}
+	copy(image[off:], b)
+	return nil
+}
+
+// Regions returns the areas as flash regions.
+func (f *FMap) Regions() []mtd.Region {
+	rs := make([]mtd.Region, len(f.Areas))
+	for i, a := range f.Areas {
+		rs[i] = mtd.Region{Name: a.Name, Offset: int64(a.Offset), Size: int64(a.Size)}
+	}
+	return rs
+}
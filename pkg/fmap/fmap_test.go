@@ -0,0 +1,125 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fmap
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/mtd"
+)
+
+func readImage(t *testing.T) []byte {
+	b, err := ioutil.ReadFile("testdata/image.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestFind(t *testing.T) {
+	image := readImage(t)
+	f, off, err := Find(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off != 0x1000 {
+		t.Errorf("offset: got %#x, want 0x1000", off)
+	}
+	want := &FMap{
+		VerMajor: 1,
+		VerMinor: 1,
+		Base:     0xffff0000,
+		Size:     0x10000,
+		Name:     "FLASH",
+		Areas: []Area{
+			{0x0, 0x8000, "WP_RO", 0},
+			{0x0, 0x8000, "RO_SECTION", AreaStatic},
+			{0x1000, 0x800, "FMAP", AreaStatic},
+			{0x2000, 0x1000, "RO_VPD", AreaPreserve},
+			{0x8000, 0x4000, "RW_SECTION_A", 0},
+			{0xc000, 0x4000, "RW_SECTION_B", 0},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("Find: got %+v, want %+v", f, want)
+	}
+	if got := f.Area("RO_VPD").FlagString(); got != "preserve" {
+		t.Errorf("RO_VPD flags: got %q, want preserve", got)
+	}
+	if f.Area("GBB") != nil {
+		t.Errorf("Area(GBB): got an area, want nil")
+	}
+	wantRegion := mtd.Region{Name: "RW_SECTION_B", Offset: 0xc000, Size: 0x4000}
+	if got := f.Regions()[5]; got != wantRegion {
+		t.Errorf("Regions()[5]: got %v, want %v", got, wantRegion)
+	}
+
+	if _, _, err := Find(image[:0x8000]); err != ErrNotFound {
+		t.Errorf("Find(half image): got %v, want %v", err, ErrNotFound)
+	}
+	if _, _, err := Find(bytes.Repeat([]byte{0xff}, 0x1000)); err != ErrNotFound {
+		t.Errorf("Find(blank): got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	image := readImage(t)
+	f, off, err := Find(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := f.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, image[off:off+int64(len(b))]) {
+		t.Errorf("Marshal does not round trip")
+	}
+
+	if err := f.Remove("RW_SECTION_B"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove("RW_SECTION_B"); err == nil {
+		t.Errorf("Remove(RW_SECTION_B) twice: got nil, want error")
+	}
+	f.Area("RW_SECTION_A").Size = 0x8000
+	f.Areas = append(f.Areas, Area{Offset: 0x3000, Size: 0x1000, Name: "RO_GSCVD", Flags: AreaReadOnly})
+	if err := f.Write(image, off); err != nil {
+		t.Fatal(err)
+	}
+	g, _, err := Find(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, g) {
+		t.Errorf("after Write: got %+v, want %+v", g, f)
+	}
+
+	for _, tt := range []struct {
+		name string
+		edit func(*FMap)
+	}{
+		{"outside", func(f *FMap) { f.Area("RO_GSCVD").Offset = 0xf800 }},
+		{"duplicate", func(f *FMap) { f.Area("RO_GSCVD").Name = "FMAP" }},
+		{"long name", func(f *FMap) { f.Area("RO_GSCVD").Name = "THIS_NAME_IS_FAR_TOO_LONG_FOR_AN_FMAP" }},
+		{"overflow", func(f *FMap) {
+			for i := 0; i < 60; i++ {
+				f.Areas = append(f.Areas, Area{Name: string(rune('A'+i%26)) + string(rune('a'+i/26))})
+			}
+		}},
+	} {
+		g, _, err := Find(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.edit(g)
+		if err := g.Write(image, off); err == nil {
+			t.Errorf("%s: Write got nil, want error", tt.name)
+		}
+	}
+}
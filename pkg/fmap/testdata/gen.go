@@ -0,0 +1,61 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// gen writes image.bin, a 64KiB flash image with an FMAP at 0x1000 in an
+// area of its own. A stray "__FMAP__" at 0x100, as might be found in
+// code, must be skipped.
+//
+// Run it from this directory with "go run gen.go".
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"log"
+)
+
+type area struct {
+	off, size uint32
+	name      string
+	flags     uint16
+}
+
+func main() {
+	image := bytes.Repeat([]byte{0xff}, 0x10000)
+	copy(image[0x100:], "__FMAP__ is where the map is")
+
+	areas := []area{
+		{0x0, 0x8000, "WP_RO", 0},
+		{0x0, 0x8000, "RO_SECTION", 1},
+		{0x1000, 0x800, "FMAP", 1},
+		{0x2000, 0x1000, "RO_VPD", 8},
+		{0x8000, 0x4000, "RW_SECTION_A", 0},
+		{0xc000, 0x4000, "RW_SECTION_B", 0},
+	}
+	var b bytes.Buffer
+	b.WriteString("__FMAP__")
+	b.Write([]byte{1, 1})
+	binary.Write(&b, binary.LittleEndian, uint64(0xffff0000))
+	binary.Write(&b, binary.LittleEndian, uint32(len(image)))
+	name := make([]byte, 32)
+	copy(name, "FLASH")
+	b.Write(name)
+	binary.Write(&b, binary.LittleEndian, uint16(len(areas)))
+	for _, a := range areas {
+		binary.Write(&b, binary.LittleEndian, a.off)
+		binary.Write(&b, binary.LittleEndian, a.size)
+		name := make([]byte, 32)
+		copy(name, a.name)
+		b.Write(name)
+		binary.Write(&b, binary.LittleEndian, a.flags)
+	}
+	copy(image[0x1000:], b.Bytes())
+
+	if err := ioutil.WriteFile("image.bin", image, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ifd parses and edits Intel flash descriptors.
+//
+// The flash descriptor, at the start of the SPI flash of Intel
+// platforms, divides the flash into regions (descriptor, BIOS, ME, GbE,
+// ...), says which bus masters may read and write each region, and holds
+// the PCH and processor soft straps.
+package ifd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/u-root/u-root/pkg/mount/mtd"
+)
+
+// Signature is the flash valid signature, FLVALSIG, at offset 0x10.
+const Signature = 0x0ff0a55a
+
+const (
+	sigOffset = 0x10
+
+	// Size is the size of the descriptor region in bytes.
+	Size = 0x1000
+
+	regionShift = 12
+	regionMask  = 0x7fff
+)
+
+// These are the descriptor versions, which differ in the master access
+// layout.
+const (
+	Version1 = 1 // up to Broadwell (ICH8 to 9 series PCH)
+	Version2 = 2 // Skylake (100 series PCH) and later
+)
+
+// Region indexes.
+const (
+	RegionDescriptor = 0
+	RegionBIOS       = 1
+	RegionME         = 2
+	RegionGbE        = 3
+	RegionPlatform   = 4
+	RegionDevExp     = 5
+	RegionBIOS2      = 6
+	RegionEC         = 8
+	RegionDevExp2    = 9
+	RegionIE         = 10
+	Region10GbE0     = 11
+	Region10GbE1     = 12
+	RegionPTT        = 15
+
+	// MaxRegions is the most regions a descriptor has.
+	MaxRegions = 16
+)
+
+// RegionNames are the regions' names, as used by ifdtool and coreboot's
+// SI_* FMAP areas.
+var RegionNames = [MaxRegions]string{
+	"Descriptor", "BIOS", "ME", "GbE", "Platform", "DevExp", "BIOS2", "Reserved7",
+	"EC", "DevExp2", "IE", "10GbE0", "10GbE1", "Reserved13", "Reserved14", "PTT",
+}
+
+// FMAPNames are the names coreboot gives the regions in its FMAP.
+var FMAPNames = [MaxRegions]string{
+	"SI_DESC", "SI_BIOS", "SI_ME", "SI_GBE", "SI_PDR", "SI_DEVICEEXT", "SI_BIOS2", "SI_RESERVED7",
+	"SI_EC", "SI_DEVICEEXT2", "SI_IE", "SI_10GBE0", "SI_10GBE1", "SI_RESERVED13", "SI_RESERVED14", "SI_PTT",
+}
+
+// Master indexes, counting from 1 as the datasheets do.
+const (
+	MasterBIOS = 1
+	MasterME   = 2
+	MasterGbE  = 3
+	MasterEC   = 5
+)
+
+// MasterNames are the masters' names, indexed by master index.
+var MasterNames = []string{"", "BIOS", "ME", "GbE", "Reserved4", "EC"}
+
+// ErrNotFound is returned for an image without a descriptor.
+var ErrNotFound = errors.New("no Intel flash descriptor")
+
+// Region is a flash region.
+type Region struct {
+	// Base and Limit are the first and last 4KiB blocks of the region.
+	// A region with Base > Limit is unused.
+	Base  uint16
+	Limit uint16
+}
+
+// Unused marks a region as unused.
+var Unused = Region{Base: regionMask, Limit: 0}
+
+// Used reports whether the region is in use.
+func (r Region) Used() bool {
+	return r.Base <= r.Limit
+}
+
+// Offset returns the region's offset in bytes.
+func (r Region) Offset() int64 {
+	return int64(r.Base) << regionShift
+}
+
+// Size returns the region's size in bytes, or 0 if it is unused.
+func (r Region) Size() int64 {
+	if !r.Used() {
+		return 0
+	}
+	return int64(r.Limit-r.Base+1) << regionShift
+}
+
+// NewRegion returns a region of size bytes at offset.
+func NewRegion(offset, size int64) (Region, error) {
+	const block = 1 << regionShift
+	if offset%block != 0 || size%block != 0 || size <= 0 {
+		return Region{}, fmt.Errorf("region %#x+%#x is not a multiple of 4KiB", offset, size)
+	}
+	end := (offset + size) >> regionShift
+	if end-1 > regionMask {
+		return Region{}, fmt.Errorf("region %#x+%#x is too far into the flash", offset, size)
+	}
+	return Region{Base: uint16(offset >> regionShift), Limit: uint16(end - 1)}, nil
+}
+
+func (r Region) flreg() uint32 {
+	return uint32(r.Base&regionMask) | uint32(r.Limit&regionMask)<<16
+}
+
+// Master is a bus master's access to the regions.
+type Master struct {
+	// Read and Write are bitmasks of the regions the master may read
+	// and write.
+	Read  uint16
+	Write uint16
+
+	// RequesterID is the master's requester ID. Version 2 descriptors
+	// do not have one.
+	RequesterID uint16
+}
+
+// Descriptor is a flash descriptor.
+type Descriptor struct {
+	Version int
+
+	// FLMAP0-2 locate the descriptor sections. FLCOMP describes the
+	// flash components.
+	FLMAP0, FLMAP1, FLMAP2 uint32
+	FLCOMP                 uint32
+
+	// Regions are the regions, by index. Their number depends on the
+	// chipset.
+	Regions []Region
+
+	// Masters are the masters' access, Masters[0] being master 1.
+	Masters []Master
+
+	// PCHStraps and ProcessorStraps are the soft straps.
+	PCHStraps       []uint32
+	ProcessorStraps []uint32
+}
+
+// Section bases and counts, from the flash map.
+func (d *Descriptor) fcba() int  { return int(d.FLMAP0&0xff) << 4 }
+func (d *Descriptor) frba() int  { return int(d.FLMAP0>>16&0xff) << 4 }
+func (d *Descriptor) fmba() int  { return int(d.FLMAP1&0xff) << 4 }
+func (d *Descriptor) fpsba() int { return int(d.FLMAP1>>16&0xff) << 4 }
+func (d *Descriptor) isl() int   { return int(d.FLMAP1 >> 24) }
+func (d *Descriptor) fmsba() int { return int(d.FLMAP2&0xff) << 4 }
+func (d *Descriptor) psl() int   { return int(d.FLMAP2 >> 8 & 0xff) }
+
+// entries returns how many 32-bit entries fit between the section at off
+// and the next section, up to max.
+func (d *Descriptor) entries(off, max int) int {
+	end := Size
+	for _, s := range []int{d.fcba(), d.frba(), d.fmba(), d.fpsba(), d.fmsba()} {
+		if s > off && s < end {
+			end = s
+		}
+	}
+	if n := (end - off) / 4; n < max {
+		return n
+	}
+	return max
+}
+
+// Find reports whether image starts with a flash descriptor.
+func Find(image []byte) bool {
+	return len(image) >= Size && binary.LittleEndian.Uint32(image[sigOffset:]) == Signature
+}
+
+// Parse parses the flash descriptor at the start of image.
+func Parse(image []byte) (*Descriptor, error) {
+	if !Find(image) {
+		return nil, ErrNotFound
+	}
+	le := binary.LittleEndian
+	d := &Descriptor{
+		FLMAP0: le.Uint32(image[sigOffset+4:]),
+		FLMAP1: le.Uint32(image[sigOffset+8:]),
+		FLMAP2: le.Uint32(image[sigOffset+12:]),
+	}
+	for _, s := range []int{d.fcba(), d.frba(), d.fmba(), d.fpsba(), d.fmsba()} {
+		if s < sigOffset+16 || s >= Size {
+			return nil, fmt.Errorf("descriptor section at %#x is outside the descriptor", s)
+		}
+	}
+	d.FLCOMP = le.Uint32(image[d.fcba():])
+
+	// ifdtool tells the versions apart by the read clock frequency:
+	// 20MHz in version 1, 17MHz in version 2.
+	switch f := d.FLCOMP >> 17 & 7; f {
+	case 0:
+		d.Version = Version1
+	case 6:
+		d.Version = Version2
+	default:
+		return nil, fmt.Errorf("unknown descriptor version: read clock %d", f)
+	}
+
+	for i, n := 0, d.entries(d.frba(), MaxRegions); i < n; i++ {
+		v := le.Uint32(image[d.frba()+4*i:])
+		d.Regions = append(d.Regions, Region{Base: uint16(v & regionMask), Limit: uint16(v >> 16 & regionMask)})
+	}
+	maxMasters := 3
+	if d.Version == Version2 {
+		maxMasters = 5
+	}
+	for i, n := 0, d.entries(d.fmba(), maxMasters); i < n; i++ {
+		d.Masters = append(d.Masters, d.master(le.Uint32(image[d.fmba()+4*i:])))
+	}
+	if d.fpsba()+4*d.isl() > Size || d.fmsba()+4*d.psl() > Size {
+		return nil, fmt.Errorf("soft straps run past the descriptor")
+	}
+	for i := 0; i < d.isl(); i++ {
+		d.PCHStraps = append(d.PCHStraps, le.Uint32(image[d.fpsba()+4*i:]))
+	}
+	for i := 0; i < d.psl(); i++ {
+		d.ProcessorStraps = append(d.ProcessorStraps, le.Uint32(image[d.fmsba()+4*i:]))
+	}
+	return d, nil
+}
+
+// master decodes an FLMSTR register.
+func (d *Descriptor) master(v uint32) Master {
+	if d.Version == Version1 {
+		return Master{
+			RequesterID: uint16(v),
+			Read:        uint16(v >> 16 & 0xff),
+			Write:       uint16(v >> 24 & 0xff),
+		}
+	}
+	// Regions 0-11 are in the upper bits, 12-15 in the extended bits.
+	return Master{
+		Read:  uint16(v>>8&0xfff) | uint16(v&0xf)<<12,
+		Write: uint16(v>>20&0xfff) | uint16(v>>4&0xf)<<12,
+	}
+}
+
+// flmstr encodes an FLMSTR register.
+func (d *Descriptor) flmstr(m Master) uint32 {
+	if d.Version == Version1 {
+		return uint32(m.RequesterID) | uint32(m.Read&0xff)<<16 | uint32(m.Write&0xff)<<24
+	}
+	return uint32(m.Read&0xfff)<<8 | uint32(m.Read>>12) | uint32(m.Write&0xfff)<<20 | uint32(m.Write>>12)<<4
+}
+
+// Write writes the regions, masters and straps of d back into image,
+// which must hold the descriptor d was parsed from.
+func (d *Descriptor) Write(image []byte) error {
+	o, err := Parse(image)
+	if err != nil {
+		return err
+	}
+	if o.FLMAP0 != d.FLMAP0 || o.FLMAP1 != d.FLMAP1 || o.FLMAP2 != d.FLMAP2 {
+		return fmt.Errorf("descriptor flash map has changed")
+	}
+	if len(d.Regions) != len(o.Regions) || len(d.Masters) != len(o.Masters) ||
+		len(d.PCHStraps) != len(o.PCHStraps) || len(d.ProcessorStraps) != len(o.ProcessorStraps) {
+		return fmt.Errorf("descriptor regions, masters or straps added or removed")
+	}
+	if err := d.Validate(int64(len(image))); err != nil {
+		return err
+	}
+	le := binary.LittleEndian
+	le.PutUint32(image[d.fcba():], d.FLCOMP)
+	for i, r := range d.Regions {
+		le.PutUint32(image[d.frba()+4*i:], r.flreg())
+	}
+	for i, m := range d.Masters {
+		le.PutUint32(image[d.fmba()+4*i:], d.flmstr(m))
+	}
+	for i, s := range d.PCHStraps {
+		le.PutUint32(image[d.fpsba()+4*i:], s)
+	}
+	for i, s := range d.ProcessorStraps {
+		le.PutUint32(image[d.fmsba()+4*i:], s)
+	}
+	return nil
+}
+
+// Validate checks that the used regions fit in a flash of size bytes
+// and do not overlap, and that the descriptor region holds the
+// descriptor.
+func (d *Descriptor) Validate(size int64) error {
+	if len(d.Regions) == 0 || d.Regions[RegionDescriptor].Offset() != 0 || d.Regions[RegionDescriptor].Size() < Size {
+		return fmt.Errorf("descriptor region does not hold the descriptor")
+	}
+	for i, r := range d.Regions {
+		if !r.Used() {
+			continue
+		}
+		if r.Offset()+r.Size() > size {
+			return fmt.Errorf("%s region %#x+%#x is outside the %#x byte flash", RegionNames[i], r.Offset(), r.Size(), size)
+		}
+		for j := i + 1; j < len(d.Regions); j++ {
+			s := d.Regions[j]
+			if s.Used() && r.Base <= s.Limit && s.Base <= r.Limit {
+				return fmt.Errorf("%s and %s regions overlap", RegionNames[i], RegionNames[j])
+			}
+		}
+	}
+	return nil
+}
+
+// FlashRegions returns the used regions as flash regions, with their
+// FMAPNames.
+func (d *Descriptor) FlashRegions() []mtd.Region {
+	var rs []mtd.Region
+	for i, r := range d.Regions {
+		if !r.Used() {
+			continue
+		}
+		rs = append(rs, mtd.Region{Name: FMAPNames[i], Offset: r.Offset(), Size: r.Size()})
+	}
+	return rs
+}
+
+// Unlock gives every master read and write access to every region, as
+// "ifdtool -u" does.
+func (d *Descriptor) Unlock() {
+	for i := range d.Masters {
+		d.Masters[i].Read, d.Masters[i].Write = 0xffff, 0xffff
+		if d.Version == Version1 {
+			d.Masters[i].Read, d.Masters[i].Write = 0xff, 0xff
+		}
+	}
+}
+
+// Lock restricts the masters to the access they need, as "ifdtool -l"
+// does: each may write only its own regions, and the host cannot write
+// the descriptor or the ME.
+func (d *Descriptor) Lock() {
+	const (
+		desc = 1 << RegionDescriptor
+		bios = 1 << RegionBIOS
+		me   = 1 << RegionME
+		gbe  = 1 << RegionGbE
+		ec   = 1 << RegionEC
+	)
+	access := map[int]Master{
+		MasterBIOS: {Read: desc | bios | gbe, Write: bios | gbe},
+		MasterME:   {Read: desc | me | gbe, Write: me},
+		MasterGbE:  {Read: desc | gbe, Write: gbe},
+		MasterEC:   {Read: desc | ec, Write: ec},
+	}
+	for i := range d.Masters {
+		a := access[i+1]
+		d.Masters[i].Read, d.Masters[i].Write = a.Read, a.Write
+	}
+}
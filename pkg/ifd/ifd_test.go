@@ -0,0 +1,182 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ifd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/mtd"
+)
+
+func readImage(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func unusedRegions(n int) []Region {
+	r := make([]Region, n)
+	for i := range r {
+		r[i] = Unused
+	}
+	return r
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		file string
+		want *Descriptor
+	}{
+		{
+			file: "v2.bin",
+			want: &Descriptor{
+				Version: Version2,
+				FLMAP0:  0x00040003,
+				FLMAP1:  0x04100308,
+				FLMAP2:  0x00000130,
+				FLCOMP:  0x06cc0004,
+				Regions: append([]Region{{0, 0}, {8, 15}, {3, 7}, {1, 2}}, unusedRegions(12)...),
+				Masters: []Master{
+					{Read: 0xb, Write: 0xa},
+					{Read: 0xd, Write: 0x4},
+					{Read: 0x9, Write: 0x8},
+					{},
+					{Read: 0x101, Write: 0x100},
+				},
+				PCHStraps:       []uint32{0x00000001, 0x00100000, 0x80000000, 0xdeadbeef},
+				ProcessorStraps: []uint32{0x42},
+			},
+		},
+		{
+			file: "v1.bin",
+			want: &Descriptor{
+				Version: Version1,
+				FLMAP0:  0x04040003,
+				FLMAP1:  0x02100206,
+				FLMAP2:  0x00000020,
+				FLCOMP:  1,
+				Regions: append([]Region{{0, 0}, {4, 15}, {1, 3}}, unusedRegions(5)...),
+				Masters: []Master{
+					{Read: 0x0b, Write: 0x0a},
+					{Read: 0x0d, Write: 0x0c},
+					{Read: 0x08, Write: 0x08, RequesterID: 0x118},
+				},
+				PCHStraps: []uint32{0x12345678, 0x9abcdef0},
+			},
+		},
+	} {
+		d, err := Parse(readImage(t, tt.file))
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.file, d, tt.want)
+		}
+	}
+
+	if _, err := Parse(bytes.Repeat([]byte{0xff}, Size)); err != ErrNotFound {
+		t.Errorf("Parse(blank): got %v, want %v", err, ErrNotFound)
+	}
+	bad := readImage(t, "v2.bin")
+	bad[0x32] = 0x0a // 50MHz reads
+	if _, err := Parse(bad); err == nil {
+		t.Errorf("Parse(unknown version): got nil, want error")
+	}
+}
+
+func TestFlashRegions(t *testing.T) {
+	d, err := Parse(readImage(t, "v2.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mtd.Region{
+		{Name: "SI_DESC", Offset: 0, Size: 0x1000},
+		{Name: "SI_BIOS", Offset: 0x8000, Size: 0x8000},
+		{Name: "SI_ME", Offset: 0x3000, Size: 0x5000},
+		{Name: "SI_GBE", Offset: 0x1000, Size: 0x2000},
+	}
+	if got := d.FlashRegions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FlashRegions: got %v, want %v", got, want)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	for _, file := range []string{"v1.bin", "v2.bin"} {
+		image := readImage(t, file)
+		d, err := Parse(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := append([]byte(nil), image...)
+		if err := d.Write(b); err != nil || !bytes.Equal(b, image) {
+			t.Errorf("%s: Write(unchanged): got %v, changed %v", file, err, !bytes.Equal(b, image))
+		}
+
+		d.Unlock()
+		if err := d.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		u, err := Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all := uint16(0xffff)
+		if u.Version == Version1 {
+			all = 0xff
+		}
+		for i, m := range u.Masters {
+			if m.Read != all || m.Write != all {
+				t.Errorf("%s: unlocked master %d: got %+v", file, i+1, m)
+			}
+		}
+
+		d.Lock()
+		d.PCHStraps[0] = 0x5a5a5a5a
+		r, err := NewRegion(0x4000, 0xc000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Regions[RegionBIOS] = r
+		d.Regions[RegionME], _ = NewRegion(0x3000, 0x1000)
+		if err := d.Write(b); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		l, err := Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(l, d) {
+			t.Errorf("%s: after Write: got %+v, want %+v", file, l, d)
+		}
+		if bios := l.Masters[MasterBIOS-1]; bios.Write&(1<<RegionME|1<<RegionDescriptor) != 0 {
+			t.Errorf("%s: locked BIOS master can write ME or descriptor: %+v", file, bios)
+		}
+
+		d.Regions[RegionME], _ = NewRegion(0x3000, 0x2000)
+		if err := d.Write(b); err == nil {
+			t.Errorf("%s: Write(overlapping regions): got nil, want error", file)
+		}
+		d.Regions[RegionME], _ = NewRegion(0x3000, 0x1000)
+		d.Regions[RegionBIOS], _ = NewRegion(0x4000, 0x10000)
+		if err := d.Write(b); err == nil {
+			t.Errorf("%s: Write(region past flash): got nil, want error", file)
+		}
+		d.Regions[RegionBIOS] = r
+		d.PCHStraps = append(d.PCHStraps, 0)
+		if err := d.Write(b); err == nil {
+			t.Errorf("%s: Write(added strap): got nil, want error", file)
+		}
+	}
+
+	if _, err := NewRegion(0x1800, 0x1000); err == nil {
+		t.Errorf("NewRegion(unaligned): got nil, want error")
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// gen writes two 64KiB flash images with Intel flash descriptors.
+//
+// v2.bin has a Skylake style descriptor: descriptor, GbE, ME and BIOS
+// regions, five masters and PCH and processor straps. The BIOS region
+// starts with an FMAP naming the SI_* regions, as coreboot's does. Each
+// region is filled with its own byte so region writes can be checked.
+//
+// v1.bin has an ICH9 style descriptor, with requester IDs in the masters,
+// and no GbE region.
+//
+// Run it from this directory with "go run gen.go".
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"log"
+)
+
+const size = 0x10000
+
+func put(b []byte, off int, vs ...uint32) {
+	for i, v := range vs {
+		binary.LittleEndian.PutUint32(b[off+4*i:], v)
+	}
+}
+
+func region(base, limit uint32) uint32 {
+	return base | limit<<16
+}
+
+const unused = 0x7fff
+
+type area struct {
+	off, size uint32
+	name      string
+}
+
+func fmap(areas []area) []byte {
+	var b bytes.Buffer
+	b.WriteString("__FMAP__")
+	b.Write([]byte{1, 1})
+	binary.Write(&b, binary.LittleEndian, uint64(0xffff0000))
+	binary.Write(&b, binary.LittleEndian, uint32(size))
+	name := make([]byte, 32)
+	copy(name, "FLASH")
+	b.Write(name)
+	binary.Write(&b, binary.LittleEndian, uint16(len(areas)))
+	for _, a := range areas {
+		binary.Write(&b, binary.LittleEndian, a.off)
+		binary.Write(&b, binary.LittleEndian, a.size)
+		name := make([]byte, 32)
+		copy(name, a.name)
+		b.Write(name)
+		binary.Write(&b, binary.LittleEndian, uint16(0))
+	}
+	return b.Bytes()
+}
+
+func v2() []byte {
+	b := bytes.Repeat([]byte{0xff}, size)
+	copy(b[0x1000:0x3000], bytes.Repeat([]byte{0x11}, 0x2000))
+	copy(b[0x3000:0x8000], bytes.Repeat([]byte{0x22}, 0x5000))
+	copy(b[0x8000:], bytes.Repeat([]byte{0x33}, 0x8000))
+
+	put(b, 0x10, 0x0ff0a55a,
+		0x03|0x04<<16,            // FCBA 0x30, FRBA 0x40
+		0x08|3<<8|0x10<<16|4<<24, // FMBA 0x80, FPSBA 0x100, 4 PCH straps
+		0x30|1<<8)                // FMSBA 0x300, 1 processor strap
+	put(b, 0x30, 6<<17|6<<21|6<<24|4) // 17MHz reads, 16MiB
+	regions := []uint32{
+		region(0, 0),
+		region(0x8, 0xf),
+		region(0x3, 0x7),
+		region(0x1, 0x2),
+	}
+	for len(regions) < 16 {
+		regions = append(regions, unused)
+	}
+	put(b, 0x40, regions...)
+	// Read bits 8-19, write bits 20-31.
+	put(b, 0x80,
+		0xb<<8|0xa<<20, // BIOS reads desc, BIOS and GbE; writes BIOS and GbE
+		0xd<<8|0x4<<20, // ME reads desc, ME and GbE; writes ME
+		0x9<<8|0x8<<20, // GbE reads desc and GbE; writes GbE
+		0,
+		0x101<<8|0x100<<20) // EC
+	put(b, 0x100, 0x00000001, 0x00100000, 0x80000000, 0xdeadbeef)
+	put(b, 0x300, 0x00000042)
+	copy(b[0x8000:], fmap([]area{
+		{0x0, 0x8000, "SI_ALL"},
+		{0x0, 0x1000, "SI_DESC"},
+		{0x1000, 0x2000, "SI_GBE"},
+		{0x3000, 0x5000, "SI_ME"},
+		{0x8000, 0x8000, "SI_BIOS"},
+		{0x8000, 0x200, "FMAP"},
+		{0x8200, 0x7e00, "COREBOOT"},
+	}))
+	return b
+}
+
+func v1() []byte {
+	b := bytes.Repeat([]byte{0xff}, size)
+	put(b, 0x10, 0x0ff0a55a,
+		0x03|0x04<<16|4<<24,      // FCBA 0x30, FRBA 0x40
+		0x06|2<<8|0x10<<16|2<<24, // FMBA 0x60, FPSBA 0x100, 2 PCH straps
+		0x20)                     // FMSBA 0x200, no processor straps
+	put(b, 0x30, 1)
+	put(b, 0x40,
+		region(0, 0),
+		region(0x4, 0xf),
+		region(0x1, 0x3),
+		unused, unused, unused, unused, unused)
+	put(b, 0x60, 0x0a0b0000, 0x0c0d0000, 0x08080118)
+	put(b, 0x100, 0x12345678, 0x9abcdef0)
+	return b
+}
+
+func main() {
+	for n, b := range map[string][]byte{"v1.bin": v1(), "v2.bin": v2()} {
+		if err := ioutil.WriteFile(n, b, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CompletionCode is the first byte of a response. It is returned as an error
+// if it is not 0.
+type CompletionCode byte
+
+// Completion codes, from the IPMI specification, section 5.2.
+const (
+	CCNodeBusy                 CompletionCode = 0xc0
+	CCInvalidCommand           CompletionCode = 0xc1
+	CCInvalidCommandForLUN     CompletionCode = 0xc2
+	CCTimeout                  CompletionCode = 0xc3
+	CCOutOfSpace               CompletionCode = 0xc4
+	CCReservationCanceled      CompletionCode = 0xc5
+	CCRequestTruncated         CompletionCode = 0xc6
+	CCRequestLengthInvalid     CompletionCode = 0xc7
+	CCRequestLengthExceeded    CompletionCode = 0xc8
+	CCParameterOutOfRange      CompletionCode = 0xc9
+	CCCannotReturnBytes        CompletionCode = 0xca
+	CCNotPresent               CompletionCode = 0xcb
+	CCInvalidDataField         CompletionCode = 0xcc
+	CCIllegalCommand           CompletionCode = 0xcd
+	CCNoResponse               CompletionCode = 0xce
+	CCDuplicateRequest         CompletionCode = 0xcf
+	CCSDRUpdateMode            CompletionCode = 0xd0
+	CCFirmwareUpdateMode       CompletionCode = 0xd1
+	CCInitializationInProgress CompletionCode = 0xd2
+	CCDestinationUnavailable   CompletionCode = 0xd3
+	CCInsufficientPrivilege    CompletionCode = 0xd4
+	CCNotSupportedInState      CompletionCode = 0xd5
+	CCSubFunctionDisabled      CompletionCode = 0xd6
+	CCUnspecified              CompletionCode = 0xff
+)
+
+var completionCodes = map[CompletionCode]string{
+	CCNodeBusy:                 "node busy",
+	CCInvalidCommand:           "invalid command",
+	CCInvalidCommandForLUN:     "invalid command for LUN",
+	CCTimeout:                  "timeout",
+	CCOutOfSpace:               "out of space",
+	CCReservationCanceled:      "reservation canceled or invalid",
+	CCRequestTruncated:         "request data truncated",
+	CCRequestLengthInvalid:     "request data length invalid",
+	CCRequestLengthExceeded:    "request data field length limit exceeded",
+	CCParameterOutOfRange:      "parameter out of range",
+	CCCannotReturnBytes:        "cannot return number of requested data bytes",
+	CCNotPresent:               "requested sensor, data, or record not present",
+	CCInvalidDataField:         "invalid data field in request",
+	CCIllegalCommand:           "command illegal for specified sensor or record type",
+	CCNoResponse:               "command response could not be provided",
+	CCDuplicateRequest:         "cannot execute duplicated request",
+	CCSDRUpdateMode:            "SDR repository in update mode",
+	CCFirmwareUpdateMode:       "device in firmware update mode",
+	CCInitializationInProgress: "BMC initialization in progress",
+	CCDestinationUnavailable:   "destination unavailable",
+	CCInsufficientPrivilege:    "insufficient privilege level",
+	CCNotSupportedInState:      "command not supported in present state",
+	CCSubFunctionDisabled:      "command sub-function has been disabled or is unavailable",
+	CCUnspecified:              "unspecified error",
+}
+
+func (c CompletionCode) Error() string {
+	if s, ok := completionCodes[c]; ok {
+		return fmt.Sprintf("completion code %#02x: %s", byte(c), s)
+	}
+	return fmt.Sprintf("completion code %#02x", byte(c))
+}
+
+// isCompletionCode reports whether err is the completion code c.
+func isCompletionCode(err error, c CompletionCode) bool {
+	var cc CompletionCode
+	return errors.As(err, &cc) && cc == c
+}
+
+// sendRecv sends a request and returns the response data after the
+// completion code, which must have at least n bytes.
+func sendRecv(s SendRecver, netfn NetFn, cmd Command, data []byte, n int) ([]byte, error) {
+	r, err := s.SendRecv(netfn, cmd, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(r) == 0 {
+		return nil, fmt.Errorf("netfn %#x command %#x: empty response", netfn, cmd)
+	}
+	if r[0] != 0 {
+		return nil, CompletionCode(r[0])
+	}
+	if len(r)-1 < n {
+		return nil, fmt.Errorf("netfn %#x command %#x: response has %d bytes, want at least %d", netfn, cmd, len(r)-1, n)
+	}
+	return r[1:], nil
+}
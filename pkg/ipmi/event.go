@@ -0,0 +1,295 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"fmt"
+)
+
+// Event/reading type codes, from the IPMI specification, section 42.1.
+const (
+	EventTypeThreshold      = 0x01
+	EventTypeSensorSpecific = 0x6f
+)
+
+// genericEvents are the states of the generic event/reading types 0x02 to
+// 0x0c, by type and offset, from table 42-2.
+var genericEvents = map[uint8][]string{
+	0x02: {"Transition to Idle", "Transition to Active", "Transition to Busy"},
+	0x03: {"State Deasserted", "State Asserted"},
+	0x04: {"Predictive Failure Deasserted", "Predictive Failure Asserted"},
+	0x05: {"Limit Not Exceeded", "Limit Exceeded"},
+	0x06: {"Performance Met", "Performance Lags"},
+	0x07: {
+		"Transition to OK",
+		"Transition to Non-critical from OK",
+		"Transition to Critical from less severe",
+		"Transition to Non-recoverable from less severe",
+		"Transition to Non-critical from more severe",
+		"Transition to Critical from Non-recoverable",
+		"Transition to Non-recoverable",
+		"Monitor",
+		"Informational",
+	},
+	0x08: {"Device Absent", "Device Present"},
+	0x09: {"Device Disabled", "Device Enabled"},
+	0x0a: {
+		"Transition to Running",
+		"Transition to In Test",
+		"Transition to Power Off",
+		"Transition to On Line",
+		"Transition to Off Line",
+		"Transition to Off Duty",
+		"Transition to Degraded",
+		"Transition to Power Save",
+		"Install Error",
+	},
+	0x0b: {
+		"Fully Redundant",
+		"Redundancy Lost",
+		"Redundancy Degraded",
+		"Non-redundant: Sufficient Resources from Redundant",
+		"Non-redundant: Sufficient Resources from Insufficient Resources",
+		"Non-redundant: Insufficient Resources",
+		"Redundancy Degraded from Fully Redundant",
+		"Redundancy Degraded from Non-redundant",
+	},
+	0x0c: {"D0 Power State", "D1 Power State", "D2 Power State", "D3 Power State"},
+}
+
+var thresholdEvents = []string{
+	"Lower Non-critical going low",
+	"Lower Non-critical going high",
+	"Lower Critical going low",
+	"Lower Critical going high",
+	"Lower Non-recoverable going low",
+	"Lower Non-recoverable going high",
+	"Upper Non-critical going low",
+	"Upper Non-critical going high",
+	"Upper Critical going low",
+	"Upper Critical going high",
+	"Upper Non-recoverable going low",
+	"Upper Non-recoverable going high",
+}
+
+// sensorSpecificEvents are the states of sensor-specific events, by sensor
+// type and offset, from table 42-3.
+var sensorSpecificEvents = map[SensorType][]string{
+	0x05: {
+		"General Chassis intrusion",
+		"Drive Bay intrusion",
+		"I/O Card area intrusion",
+		"Processor area intrusion",
+		"System unplugged from LAN",
+		"Unauthorized dock",
+		"FAN area intrusion",
+	},
+	0x06: {
+		"Front Panel Lockout violation attempted",
+		"Pre-boot password violation - user password",
+		"Pre-boot password violation - setup password",
+		"Pre-boot password violation - network boot password",
+		"Other pre-boot password violation",
+		"Out-of-band access password violation",
+	},
+	0x07: {
+		"IERR",
+		"Thermal Trip",
+		"FRB1/BIST failure",
+		"FRB2/Hang in POST failure",
+		"FRB3/Processor startup/init failure",
+		"Configuration Error",
+		"SM BIOS Uncorrectable CPU-complex Error",
+		"Presence detected",
+		"Disabled",
+		"Terminator presence detected",
+		"Throttled",
+		"Uncorrectable machine check exception",
+		"Correctable machine check error",
+	},
+	0x08: {
+		"Presence detected",
+		"Failure detected",
+		"Predictive failure",
+		"Power Supply AC lost",
+		"AC lost or out-of-range",
+		"AC out-of-range, but present",
+		"Config Error",
+		"Power Supply Inactive",
+	},
+	0x09: {
+		"Power off/down",
+		"Power cycle",
+		"240VA power down",
+		"Interlock power down",
+		"AC lost",
+		"Soft-power control failure",
+		"Failure detected",
+		"Predictive failure",
+	},
+	0x0c: {
+		"Correctable ECC",
+		"Uncorrectable ECC",
+		"Parity",
+		"Memory Scrub Failed",
+		"Memory Device Disabled",
+		"Correctable ECC logging limit reached",
+		"Presence Detected",
+		"Configuration Error",
+		"Spare",
+		"Throttled",
+		"Critical Overtemperature",
+	},
+	0x0d: {
+		"Drive Present",
+		"Drive Fault",
+		"Predictive Failure",
+		"Hot Spare",
+		"Parity Check In Progress",
+		"In Critical Array",
+		"In Failed Array",
+		"Rebuild In Progress",
+		"Rebuild Aborted",
+	},
+	0x0f: {
+		"System Firmware Error",
+		"System Firmware Hang",
+		"System Firmware Progress",
+	},
+	0x10: {
+		"Correctable memory error logging disabled",
+		"Event logging disabled",
+		"Log area reset/cleared",
+		"All event logging disabled",
+		"Log full",
+		"Log almost full",
+	},
+	0x11: {
+		"BIOS Reset",
+		"OS Reset",
+		"OS Shut Down",
+		"OS Power Down",
+		"OS Power Cycle",
+		"OS NMI/Diag Interrupt",
+		"OS Expired",
+		"OS pre-timeout Interrupt",
+	},
+	0x12: {
+		"System Reconfigured",
+		"OEM System boot event",
+		"Undetermined system hardware failure",
+		"Entry added to auxiliary log",
+		"PEF Action",
+		"Timestamp Clock Sync",
+	},
+	0x13: {
+		"NMI/Diag Interrupt",
+		"Bus Timeout",
+		"I/O Channel check NMI",
+		"Software NMI",
+		"PCI PERR",
+		"PCI SERR",
+		"EISA failsafe timeout",
+		"Bus Correctable error",
+		"Bus Uncorrectable error",
+		"Fatal NMI",
+		"Bus Fatal Error",
+		"Bus Degraded",
+	},
+	0x14: {
+		"Power Button pressed",
+		"Sleep Button pressed",
+		"Reset Button pressed",
+		"FRU Latch",
+		"FRU Service",
+	},
+	0x1d: {
+		"Initiated by power up",
+		"Initiated by hard reset",
+		"Initiated by warm reset",
+		"User requested PXE boot",
+		"Automatic boot to diagnostic",
+		"OS initiated hard reset",
+		"OS initiated warm reset",
+		"System Restart",
+	},
+	0x1e: {
+		"No bootable media",
+		"Non-bootable disk in drive",
+		"PXE server not found",
+		"Invalid boot sector",
+		"Timeout waiting for selection",
+	},
+	0x1f: {
+		"A: boot completed",
+		"C: boot completed",
+		"PXE boot completed",
+		"Diagnostic boot completed",
+		"CD-ROM boot completed",
+		"ROM boot completed",
+		"boot completed - device not specified",
+		"Installation started",
+		"Installation completed",
+		"Installation aborted",
+		"Installation failed",
+	},
+	0x20: {
+		"Stop during OS load/init",
+		"Run-time critical stop",
+		"OS graceful stop",
+		"OS graceful shutdown",
+		"PEF initiated soft shutdown",
+		"Agent not responding",
+	},
+	0x22: {
+		"S0/G0: working",
+		"S1: sleeping with system hw & processor context maintained",
+		"S2: sleeping, processor context lost",
+		"S3: sleeping, processor & hw context lost, memory retained",
+		"S4: non-volatile sleep/suspend-to-disk",
+		"S5/G2: soft-off",
+		"S4/S5: soft-off",
+		"G3: mechanical off",
+		"Sleeping in S1/S2/S3 state",
+		"G1: sleeping",
+		"S5: entered by override",
+		"Legacy ON state",
+		"Legacy OFF state",
+		"Unknown",
+	},
+	0x23: {
+		"Timer expired",
+		"Hard reset",
+		"Power down",
+		"Power cycle",
+		"reserved",
+		"reserved",
+		"reserved",
+		"reserved",
+		"Timer interrupt",
+	},
+	0x25: {"Present", "Absent", "Disabled"},
+	0x29: {"Low", "Failed", "Presence Detected"},
+}
+
+// EventDescription describes the state at offset of a sensor with the event/
+// reading type code eventType, e.g. "Upper Critical going high".
+func EventDescription(st SensorType, eventType, offset uint8) string {
+	var states []string
+	switch {
+	case eventType == EventTypeThreshold:
+		states = thresholdEvents
+	case eventType == EventTypeSensorSpecific:
+		states = sensorSpecificEvents[st]
+	case eventType >= 0x70 && eventType <= 0x7f:
+		return fmt.Sprintf("OEM event %#02x offset %#x", eventType, offset)
+	default:
+		states = genericEvents[eventType]
+	}
+	if int(offset) < len(states) {
+		return states[offset]
+	}
+	return fmt.Sprintf("Unknown event %#02x offset %#x", eventType, offset)
+}
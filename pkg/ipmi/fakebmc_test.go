@@ -0,0 +1,205 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+// fakeBMC answers the SDR, sensor, FRU and SEL commands from the fixtures
+// in testdata.
+type fakeBMC struct {
+	t *testing.T
+
+	sdr      []byte
+	readings map[uint8][]byte
+	fru      []byte
+	sel      []byte
+
+	// maxRead is the most bytes Get SDR and Read FRU Data return.
+	maxRead int
+	// cancel cancels the SDR reservation on the Get SDR request with
+	// this number, counting from 1.
+	cancel   int
+	requests int
+
+	reservation uint16
+	fruWords    bool
+	// clearPolls is how often Clear SEL reports that erasure is in
+	// progress.
+	clearPolls int
+}
+
+func newFakeBMC(t *testing.T) *fakeBMC {
+	b := &fakeBMC{
+		t:       t,
+		maxRead: 32,
+		readings: map[uint8][]byte{
+			0x01: {45, 0xc0, 0x00},
+			0x02: {0xbe, 0xc0, 0x00},
+			0x03: {4, 0xc0, 0x01},
+			0x04: {0, 0xc0, 0x02, 0x80},
+			0x05: {0, 0xe0, 0x00},
+		},
+	}
+	for _, f := range []struct {
+		name string
+		b    *[]byte
+	}{
+		{"sdr.bin", &b.sdr},
+		{"fru.bin", &b.fru},
+		{"sel.bin", &b.sel},
+	} {
+		d, err := ioutil.ReadFile("testdata/" + f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*f.b = d
+	}
+	return b
+}
+
+// sdrRecord returns the record with the given ID, 0 for the first, and the
+// ID of the next.
+func (b *fakeBMC) sdrRecord(id uint16) ([]byte, uint16) {
+	for off := 0; off+sdrHeaderSize <= len(b.sdr); {
+		end := off + sdrHeaderSize + int(b.sdr[off+4])
+		if end > len(b.sdr) {
+			end = len(b.sdr)
+		}
+		r := b.sdr[off:end]
+		off = end
+		if id != 0 && binary.LittleEndian.Uint16(r) != id {
+			continue
+		}
+		next := uint16(lastRecord)
+		if off < len(b.sdr) {
+			next = binary.LittleEndian.Uint16(b.sdr[off:])
+		}
+		return r, next
+	}
+	return nil, 0
+}
+
+func (b *fakeBMC) selEntry(id uint16) ([]byte, uint16) {
+	for off := 0; off+selEntrySize <= len(b.sel); off += selEntrySize {
+		if id != 0 && binary.LittleEndian.Uint16(b.sel[off:]) != id {
+			continue
+		}
+		next := uint16(lastRecord)
+		if off+selEntrySize < len(b.sel) {
+			next = binary.LittleEndian.Uint16(b.sel[off+selEntrySize:])
+		}
+		return b.sel[off : off+selEntrySize], next
+	}
+	return nil, 0
+}
+
+func (b *fakeBMC) SendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error) {
+	ok := func(d ...byte) ([]byte, error) {
+		return append([]byte{0}, d...), nil
+	}
+	u16 := func(v uint16) []byte {
+		return []byte{byte(v), byte(v >> 8)}
+	}
+
+	switch {
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_GET_SDR_REPOSITORY_INFO:
+		return ok(0x51, 6, 0, 0x00, 0x10, 1, 2, 3, 4, 0xff, 0xff, 0xff, 0xff, 0x2f)
+
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_RESERVE_SDR_REPOSITORY:
+		b.reservation++
+		return ok(u16(b.reservation)...)
+
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_GET_SDR:
+		b.requests++
+		if b.requests == b.cancel {
+			b.reservation++
+		}
+		res, id, off, n := binary.LittleEndian.Uint16(data), binary.LittleEndian.Uint16(data[2:]), int(data[4]), int(data[5])
+		// Reading the header does not need a reservation.
+		if off != 0 && res != b.reservation {
+			return nil, CCReservationCanceled
+		}
+		if n > b.maxRead {
+			return nil, CCCannotReturnBytes
+		}
+		r, next := b.sdrRecord(id)
+		if r == nil {
+			return nil, CCNotPresent
+		}
+		if off+n > len(r) {
+			return nil, CCParameterOutOfRange
+		}
+		return ok(append(u16(next), r[off:off+n]...)...)
+
+	case netfn == _IPMI_NETFN_SENSOR && cmd == BMC_GET_SENSOR_READING:
+		r, found := b.readings[data[0]]
+		if !found {
+			return nil, CCNotPresent
+		}
+		return ok(r...)
+
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_GET_FRU_INVENTORY_AREA_INFO:
+		if data[0] != 0 {
+			return nil, CCNotPresent
+		}
+		var access byte
+		if b.fruWords {
+			access = 1
+		}
+		return ok(append(u16(uint16(len(b.fru))), access)...)
+
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_READ_FRU_DATA:
+		off, n := int(binary.LittleEndian.Uint16(data[1:])), int(data[3])
+		if b.fruWords {
+			off, n = off*2, n*2
+		}
+		if n > b.maxRead {
+			return nil, CCRequestLengthExceeded
+		}
+		if off+n > len(b.fru) {
+			return nil, CCParameterOutOfRange
+		}
+		count := byte(n)
+		if b.fruWords {
+			count /= 2
+		}
+		return ok(append([]byte{count}, b.fru[off:off+n]...)...)
+
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_GET_SEL_ENTRY:
+		if data[4] != 0 || data[5] != 0xff {
+			b.t.Errorf("Get SEL Entry of %d bytes at %d, want whole entries", data[5], data[4])
+		}
+		e, next := b.selEntry(binary.LittleEndian.Uint16(data[2:]))
+		if e == nil {
+			return nil, CCNotPresent
+		}
+		return ok(append(u16(next), e...)...)
+
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_RESERVE_SEL:
+		b.reservation++
+		return ok(u16(b.reservation)...)
+
+	case netfn == _IPMI_NETFN_STORAGE && cmd == BMC_CLEAR_SEL:
+		if binary.LittleEndian.Uint16(data) != b.reservation {
+			return nil, CCReservationCanceled
+		}
+		if string(data[2:5]) != "CLR" {
+			return nil, CCInvalidDataField
+		}
+		if data[5] == selClearInitiate {
+			b.sel = nil
+		}
+		if b.clearPolls > 0 {
+			b.clearPolls--
+			return ok(0)
+		}
+		return ok(selClearCompleted)
+	}
+	return nil, CCInvalidCommand
+}
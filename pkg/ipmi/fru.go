@@ -0,0 +1,324 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/u-root/u-root/pkg/smbios"
+)
+
+// FRU inventory data is described in the Platform Management FRU Information
+// Storage Definition, v1.0.
+
+const (
+	fruHeaderSize = 8
+	fruVersion    = 1
+
+	// fruEnd ends the fields of an area.
+	fruEnd = 0xc1
+
+	// fruChunk is how much is read at once. If the BMC can not return
+	// that much, it is halved.
+	fruChunk = 16
+)
+
+// fruEpoch is the epoch of the manufacturing date of boards.
+var fruEpoch = time.Date(1996, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FRU is the inventory information of a field replaceable unit. Areas that
+// are not in the FRU are nil.
+type FRU struct {
+	Chassis *FRUChassis
+	Board   *FRUBoard
+	Product *FRUProduct
+}
+
+// FRUChassis is the chassis info area.
+type FRUChassis struct {
+	Type         smbios.ChassisType
+	PartNumber   string
+	SerialNumber string
+	Custom       []string
+}
+
+// FRUBoard is the board info area.
+type FRUBoard struct {
+	Language     uint8
+	MfgDate      time.Time
+	Manufacturer string
+	Product      string
+	SerialNumber string
+	PartNumber   string
+	FileID       string
+	Custom       []string
+}
+
+// FRUProduct is the product info area.
+type FRUProduct struct {
+	Language     uint8
+	Manufacturer string
+	Name         string
+	PartNumber   string
+	Version      string
+	SerialNumber string
+	AssetTag     string
+	FileID       string
+	Custom       []string
+}
+
+// ReadFRU reads the inventory data of the FRU with the given ID, 0 for the
+// BMC's own.
+func ReadFRU(s SendRecver, id uint8) ([]byte, error) {
+	info, err := sendRecv(s, _IPMI_NETFN_STORAGE, BMC_GET_FRU_INVENTORY_AREA_INFO, []byte{id}, 3)
+	if err != nil {
+		return nil, fmt.Errorf("FRU %d: %w", id, err)
+	}
+	size := int(binary.LittleEndian.Uint16(info))
+	// Offsets and counts are in words if bit 0 is set.
+	shift := uint(info[2] & 1)
+
+	b := make([]byte, 0, size)
+	chunk := fruChunk
+	for len(b) < size {
+		n := chunk
+		if rest := size - len(b); n > rest {
+			n = rest
+		}
+		req := []byte{id, 0, 0, byte(n >> shift)}
+		binary.LittleEndian.PutUint16(req[1:], uint16(len(b)>>shift))
+		data, err := sendRecv(s, _IPMI_NETFN_STORAGE, BMC_READ_FRU_DATA, req, 1)
+		if (isCompletionCode(err, CCRequestLengthExceeded) || isCompletionCode(err, CCCannotReturnBytes)) && chunk > 2 {
+			chunk /= 2
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading FRU %d at %#x: %w", id, len(b), err)
+		}
+		got := int(data[0]) << shift
+		if got == 0 || got > len(data)-1 {
+			return nil, fmt.Errorf("reading FRU %d at %#x: got %d bytes, response has %d", id, len(b), got, len(data)-1)
+		}
+		b = append(b, data[1:1+got]...)
+	}
+	return b, nil
+}
+
+func checksum(b []byte) byte {
+	var sum byte
+	for _, v := range b {
+		sum += v
+	}
+	return sum
+}
+
+// fruArea returns the area at the offset given by the header byte at off, or
+// nil if the FRU does not have it.
+func fruArea(b []byte, off int, name string) ([]byte, error) {
+	start := int(b[off]) * 8
+	if start == 0 {
+		return nil, nil
+	}
+	if start+2 > len(b) {
+		return nil, fmt.Errorf("FRU %s area at %#x is past the end (%#x)", name, start, len(b))
+	}
+	end := start + int(b[start+1])*8
+	if end > len(b) || end < start+3 {
+		return nil, fmt.Errorf("FRU %s area at %#x has bad length %d", name, start, int(b[start+1])*8)
+	}
+	a := b[start:end]
+	if a[0]&0xf != fruVersion {
+		return nil, fmt.Errorf("FRU %s area has version %d, want %d", name, a[0]&0xf, fruVersion)
+	}
+	if checksum(a) != 0 {
+		return nil, fmt.Errorf("FRU %s area has bad checksum", name)
+	}
+	return a, nil
+}
+
+// fruFields returns the type/length encoded fields of an area from off to
+// the end marker.
+func fruFields(a []byte, off int, name string) ([]string, error) {
+	var fields []string
+	for {
+		if off >= len(a) {
+			return nil, fmt.Errorf("FRU %s area has no end marker", name)
+		}
+		tl := a[off]
+		if tl == fruEnd {
+			return fields, nil
+		}
+		n := int(tl & 0x3f)
+		if off+1+n > len(a) {
+			return nil, fmt.Errorf("FRU %s area field at %#x is past the end", name, off)
+		}
+		fields = append(fields, decodeString(tl>>6, a[off+1:off+1+n]))
+		off += 1 + n
+	}
+}
+
+// assign sets the strings to the first fields and returns the rest.
+func assign(fields []string, s ...*string) []string {
+	for i := range s {
+		if i >= len(fields) {
+			return nil
+		}
+		*s[i] = fields[i]
+	}
+	return fields[len(s):]
+}
+
+// ParseFRU parses FRU inventory data.
+func ParseFRU(b []byte) (*FRU, error) {
+	if len(b) < fruHeaderSize {
+		return nil, fmt.Errorf("FRU has %d bytes, want at least %d", len(b), fruHeaderSize)
+	}
+	if b[0]&0xf != fruVersion {
+		return nil, fmt.Errorf("FRU has format version %d, want %d", b[0]&0xf, fruVersion)
+	}
+	if checksum(b[:fruHeaderSize]) != 0 {
+		return nil, fmt.Errorf("FRU header has bad checksum")
+	}
+
+	var f FRU
+	a, err := fruArea(b, 2, "chassis")
+	if err != nil {
+		return nil, err
+	}
+	if a != nil {
+		fields, err := fruFields(a, 3, "chassis")
+		if err != nil {
+			return nil, err
+		}
+		f.Chassis = &FRUChassis{Type: smbios.ChassisType(a[2])}
+		f.Chassis.Custom = assign(fields, &f.Chassis.PartNumber, &f.Chassis.SerialNumber)
+	}
+
+	if a, err = fruArea(b, 3, "board"); err != nil {
+		return nil, err
+	}
+	if a != nil {
+		if len(a) < 6 {
+			return nil, fmt.Errorf("FRU board area has %d bytes", len(a))
+		}
+		fields, err := fruFields(a, 6, "board")
+		if err != nil {
+			return nil, err
+		}
+		f.Board = &FRUBoard{Language: a[2]}
+		// 0 is unspecified.
+		if m := int(a[3]) | int(a[4])<<8 | int(a[5])<<16; m != 0 {
+			f.Board.MfgDate = fruEpoch.Add(time.Duration(m) * time.Minute)
+		}
+		p := f.Board
+		p.Custom = assign(fields, &p.Manufacturer, &p.Product, &p.SerialNumber, &p.PartNumber, &p.FileID)
+	}
+
+	if a, err = fruArea(b, 4, "product"); err != nil {
+		return nil, err
+	}
+	if a != nil {
+		fields, err := fruFields(a, 3, "product")
+		if err != nil {
+			return nil, err
+		}
+		f.Product = &FRUProduct{Language: a[2]}
+		p := f.Product
+		p.Custom = assign(fields, &p.Manufacturer, &p.Name, &p.PartNumber, &p.Version, &p.SerialNumber, &p.AssetTag, &p.FileID)
+	}
+	return &f, nil
+}
+
+// GetFRU reads and parses the inventory data of a FRU.
+func GetFRU(s SendRecver, id uint8) (*FRU, error) {
+	b, err := ReadFRU(s, id)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFRU(b)
+}
+
+// String formats the FRU as ipmitool fru print does.
+func (f *FRU) String() string {
+	var b strings.Builder
+	line := func(name, v string) {
+		if v != "" {
+			fmt.Fprintf(&b, " %-22s: %s\n", name, v)
+		}
+	}
+	custom := func(name string, c []string) {
+		for _, v := range c {
+			line(name, v)
+		}
+	}
+	if c := f.Chassis; c != nil {
+		line("Chassis Type", c.Type.String())
+		line("Chassis Part Number", c.PartNumber)
+		line("Chassis Serial", c.SerialNumber)
+		custom("Chassis Extra", c.Custom)
+	}
+	if p := f.Board; p != nil {
+		if !p.MfgDate.IsZero() {
+			line("Board Mfg Date", p.MfgDate.Format(time.ANSIC))
+		}
+		line("Board Mfg", p.Manufacturer)
+		line("Board Product", p.Product)
+		line("Board Serial", p.SerialNumber)
+		line("Board Part Number", p.PartNumber)
+		line("Board FRU ID", p.FileID)
+		custom("Board Extra", p.Custom)
+	}
+	if p := f.Product; p != nil {
+		line("Product Manufacturer", p.Manufacturer)
+		line("Product Name", p.Name)
+		line("Product Part Number", p.PartNumber)
+		line("Product Version", p.Version)
+		line("Product Serial", p.SerialNumber)
+		line("Product Asset Tag", p.AssetTag)
+		line("Product FRU ID", p.FileID)
+		custom("Product Extra", p.Custom)
+	}
+	return b.String()
+}
+
+// Types of type/length encoded strings in FRUs and SDRs.
+const (
+	stringBinary    = 0
+	stringBCDPlus   = 1
+	stringASCII6Bit = 2
+	stringASCII8Bit = 3
+)
+
+// decodeString decodes a type/length encoded string of the given type.
+// Binary data, and the Unicode that type 0 is in SDRs, are formatted in hex.
+func decodeString(typ uint8, b []byte) string {
+	switch typ {
+	case stringBCDPlus:
+		const digits = "0123456789 -.???"
+		s := make([]byte, 0, 2*len(b))
+		for _, v := range b {
+			s = append(s, digits[v>>4], digits[v&0xf])
+		}
+		return string(s)
+	case stringASCII6Bit:
+		// Every 3 bytes have 4 characters, from the low bits up.
+		s := make([]byte, 0, len(b)*8/6)
+		var bits, n uint
+		for _, v := range b {
+			bits |= uint(v) << n
+			for n += 8; n >= 6; n -= 6 {
+				s = append(s, byte(bits&0x3f)+0x20)
+				bits >>= 6
+			}
+		}
+		return string(s)
+	case stringASCII8Bit:
+		return strings.TrimRight(string(b), "\x00")
+	}
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/smbios"
+)
+
+func TestReadFRU(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		words   bool
+		maxRead int
+	}{
+		{name: "bytes", maxRead: 32},
+		{name: "words", words: true, maxRead: 32},
+		{name: "small reads", maxRead: 6},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newFakeBMC(t)
+			b.fruWords, b.maxRead = tt.words, tt.maxRead
+			got, err := ReadFRU(b, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, b.fru) {
+				t.Errorf("ReadFRU() = %x, want %x", got, b.fru)
+			}
+		})
+	}
+
+	if _, err := ReadFRU(newFakeBMC(t), 1); !isCompletionCode(err, CCNotPresent) {
+		t.Errorf("ReadFRU(1) = %v, want %v", err, CCNotPresent)
+	}
+}
+
+func TestGetFRU(t *testing.T) {
+	f, err := GetFRU(newFakeBMC(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &FRU{
+		Chassis: &FRUChassis{
+			Type:         smbios.ChassisTypeRackMountChassis,
+			PartNumber:   "CSE-829U",
+			SerialNumber: "S3C1234X",
+			Custom:       []string{"Rack 12"},
+		},
+		Board: &FRUBoard{
+			Language:     25,
+			MfgDate:      time.Date(2020, 3, 14, 15, 9, 0, 0, time.UTC),
+			Manufacturer: "Supermicro",
+			Product:      "X11DPU",
+			SerialNumber: "ZM19AS001234",
+			PartNumber:   "X11DPU-1.02",
+			FileID:       "",
+			Custom:       []string{"2020-10.19"},
+		},
+		Product: &FRUProduct{
+			Language:     25,
+			Manufacturer: "Supermicro",
+			Name:         "SYS-6029U",
+			PartNumber:   "SYS-6029U-TR4",
+			Version:      "0123456789",
+			SerialNumber: "S123456X9",
+			AssetTag:     "ASSET-7",
+			FileID:       "",
+			Custom:       []string{},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("GetFRU() = \n%+v %+v %+v\nwant\n%+v %+v %+v", f.Chassis, f.Board, f.Product, want.Chassis, want.Board, want.Product)
+	}
+
+	s := f.String()
+	for _, l := range []string{
+		" Chassis Type          : Rack Mount Chassis\n",
+		" Board Mfg Date        : Sat Mar 14 15:09:00 2020\n",
+		" Board Extra           : 2020-10.19\n",
+		" Product Asset Tag     : ASSET-7\n",
+	} {
+		if !strings.Contains(s, l) {
+			t.Errorf("String() = \n%s\nwant it to have %q", s, l)
+		}
+	}
+}
+
+func TestParseFRUErrors(t *testing.T) {
+	fru := newFakeBMC(t).fru
+	for _, tt := range []struct {
+		name   string
+		modify func(b []byte) []byte
+		err    string
+	}{
+		{"short", func(b []byte) []byte { return b[:4] }, "FRU has 4 bytes"},
+		{"version", func(b []byte) []byte { b[0] = 2; return b }, "format version 2"},
+		{"header checksum", func(b []byte) []byte { b[7]++; return b }, "FRU header has bad checksum"},
+		{"area checksum", func(b []byte) []byte { b[0x10]++; return b }, "FRU chassis area has bad checksum"},
+		{"area past the end", func(b []byte) []byte { return b[:0x40] }, "FRU board area at 0x28 has bad length 64"},
+		{"field past the end", func(b []byte) []byte {
+			// Make the last field of the chassis area cover the
+			// end marker, and fix the checksum.
+			b[0x1b]++
+			b[0x27]--
+			return b
+		}, "FRU chassis area field at 0x1f is past the end"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.modify(append([]byte(nil), fru...))
+			if _, err := ParseFRU(b); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseFRU() = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecodeString(t *testing.T) {
+	for _, tt := range []struct {
+		typ  uint8
+		b    []byte
+		want string
+	}{
+		{stringBinary, []byte{0xde, 0xad}, "dead"},
+		{stringBCDPlus, []byte{0x12, 0xab, 0xc9}, "12 -.9"},
+		// "IPMI" packed into 6 bits per character.
+		{stringASCII6Bit, []byte{0x29, 0xdc, 0xa6}, "IPMI"},
+		{stringASCII8Bit, []byte("X11DPU\x00\x00"), "X11DPU"},
+	} {
+		if got := decodeString(tt.typ, tt.b); got != tt.want {
+			t.Errorf("decodeString(%d, %x) = %q, want %q", tt.typ, tt.b, got, tt.want)
+		}
+	}
+}
@@ -34,6 +34,7 @@ const (
 
 	// Net functions
 	_IPMI_NETFN_CHASSIS   NetFn = 0x0
+	_IPMI_NETFN_SENSOR    NetFn = 0x4
 	_IPMI_NETFN_APP       NetFn = 0x6
 	_IPMI_NETFN_STORAGE   NetFn = 0xA
 	_IPMI_NETFN_TRANSPORT NetFn = 0xC
@@ -49,11 +50,31 @@ const (
 	SET_SYSTEM_INFO_PARAMETERS Command = 0x58
 	BMC_ADD_SEL                Command = 0x44
 
+	// Session Commands
+	BMC_GET_CHANNEL_AUTH_CAPABILITIES Command = 0x38
+	BMC_SET_SESSION_PRIVILEGE_LEVEL   Command = 0x3B
+	BMC_CLOSE_SESSION                 Command = 0x3C
+
 	// Chassis Device Commands
 	BMC_GET_CHASSIS_STATUS Command = 0x01
 
+	// Sensor Device Commands
+	BMC_GET_SENSOR_READING Command = 0x2D
+
+	// FRU Device Commands
+	BMC_GET_FRU_INVENTORY_AREA_INFO Command = 0x10
+	BMC_READ_FRU_DATA               Command = 0x11
+
+	// SDR Device Commands
+	BMC_GET_SDR_REPOSITORY_INFO Command = 0x20
+	BMC_RESERVE_SDR_REPOSITORY  Command = 0x22
+	BMC_GET_SDR                 Command = 0x23
+
 	// SEL device Commands
-	BMC_GET_SEL_INFO Command = 0x40
+	BMC_GET_SEL_INFO  Command = 0x40
+	BMC_RESERVE_SEL   Command = 0x42
+	BMC_GET_SEL_ENTRY Command = 0x43
+	BMC_CLEAR_SEL     Command = 0x47
 
 	//LAN Device Commands
 	BMC_GET_LAN_CONFIG Command = 0x02
@@ -88,9 +109,31 @@ var (
 
 // IPMI represents access to the IPMI interface.
 type IPMI struct {
+	Transport
+}
+
+// Transport carries requests to a BMC and responses back: the OpenIPMI
+// driver for the local BMC, or an RMCP+ session for a remote one.
+type Transport interface {
+	SendRecver
+	Close() error
+}
+
+// dev is the Transport of the OpenIPMI driver, /dev/ipmiN.
+type dev struct {
 	*os.File
 }
 
+// SendRecver sends requests to a BMC and returns the responses, starting
+// with the completion code. A completion code other than 0 is returned as a
+// CompletionCode error.
+//
+// IPMI and its Transports are SendRecvers. The SDR, sensor, FRU and SEL
+// functions take one, so that they can be tested without a BMC.
+type SendRecver interface {
+	SendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error)
+}
+
 // Command is the command code for a given message.
 type Command byte
 
@@ -223,9 +266,8 @@ func ioctlGetRecv(fd, name uintptr, recv *recv) error {
 }
 
 // SendRecv sends the IPMI message, receives the response, and returns the
-// response data. This is recommended for use unless the user must be able to
-// specify the data pointer and length on their own.
-func (i *IPMI) SendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error) {
+// response data.
+func (d *dev) SendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error) {
 	var dataPtr unsafe.Pointer
 	if data != nil {
 		dataPtr = unsafe.Pointer(&data[0])
@@ -236,12 +278,21 @@ func (i *IPMI) SendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error) {
 		Data:    dataPtr,
 		DataLen: uint16(len(data)),
 	}
-	return i.RawSendRecv(msg)
+	return d.rawSendRecv(msg)
 }
 
 // RawSendRecv sends the IPMI message, receives the response, and returns the
-// response data.
+// response data. SendRecv is recommended for use unless the user must be
+// able to specify the data pointer and length on their own.
 func (i *IPMI) RawSendRecv(msg Msg) ([]byte, error) {
+	var data []byte
+	if msg.Data != nil {
+		data = (*[1 << 16]byte)(msg.Data)[:msg.DataLen:msg.DataLen]
+	}
+	return i.SendRecv(msg.Netfn, msg.Cmd, data)
+}
+
+func (d *dev) rawSendRecv(msg Msg) ([]byte, error) {
 	addr := &systemInterfaceAddr{
 		addrType: _IPMI_SYSTEM_INTERFACE_ADDR_TYPE,
 		channel:  _IPMI_BMC_CHANNEL,
@@ -255,7 +306,7 @@ func (i *IPMI) RawSendRecv(msg Msg) ([]byte, error) {
 
 	// Send request.
 	for {
-		switch err := ioctlSetReq(i.Fd(), _IPMICTL_SEND_COMMAND, req); {
+		switch err := ioctlSetReq(d.Fd(), _IPMICTL_SEND_COMMAND, req); {
 		case err == syscall.EINTR:
 			continue
 		case err != nil:
@@ -293,7 +344,7 @@ func (i *IPMI) RawSendRecv(msg Msg) ([]byte, error) {
 		if recv.msg.DataLen >= _IPMI_BUF_SIZE {
 			rerr = fmt.Errorf("data length received too large: %d > %d", recv.msg.DataLen, _IPMI_BUF_SIZE)
 		} else if buf[0] != 0 {
-			rerr = fmt.Errorf("invalid response, expected first byte of response to be 0, got: %v", buf[0])
+			rerr = CompletionCode(buf[0])
 		} else {
 			result = buf[:recv.msg.DataLen:recv.msg.DataLen]
 			rerr = nil
@@ -302,11 +353,11 @@ func (i *IPMI) RawSendRecv(msg Msg) ([]byte, error) {
 	}
 
 	// Read response.
-	conn, err := i.File.SyscallConn()
+	conn, err := d.File.SyscallConn()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file rawconn: %v", err)
 	}
-	if err := i.File.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+	if err := d.File.SetReadDeadline(time.Now().Add(timeout)); err != nil {
 		return nil, fmt.Errorf("failed to set read deadline: %v", err)
 	}
 	if err := conn.Read(readMsg); err != nil {
@@ -0,0 +1,682 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"sync"
+	"time"
+)
+
+// RMCP+ sessions, IPMI v2.0 over LAN, are described in the IPMI
+// specification, sections 13 and 22.
+
+const (
+	// RMCP header: version 1.0, no acknowledge, class IPMI.
+	rmcpVersion   = 0x06
+	rmcpNoAck     = 0xff
+	rmcpClassIPMI = 0x07
+	rmcpPort      = "623"
+
+	authTypeNone     = 0x00
+	authTypeRMCPPlus = 0x06
+
+	payloadIPMI                = 0x00
+	payloadOpenSessionRequest  = 0x10
+	payloadOpenSessionResponse = 0x11
+	payloadRAKP1               = 0x12
+	payloadRAKP2               = 0x13
+	payloadRAKP3               = 0x14
+	payloadRAKP4               = 0x15
+	payloadEncrypted           = 0x80
+	payloadAuthenticated       = 0x40
+	payloadTypeMask            = 0x3f
+
+	// nextHeader follows the integrity pad.
+	nextHeader = 0x07
+
+	bmcSlaveAddr = 0x20
+	remoteSWID   = 0x81
+
+	// nameOnlyLookup in the requested role makes the BMC look the user
+	// up by name only, not by name and privilege level.
+	nameOnlyLookup = 0x10
+
+	// channelIPMIv2 in the channel of Get Channel Authentication
+	// Capabilities asks for the IPMI v2.0 extended data, and
+	// extCapIPMIv2 is set in it if the channel supports RMCP+.
+	currentChannel = 0x0e
+	channelIPMIv2  = 0x80
+	extCapIPMIv2   = 0x02
+
+	maxUsername = 16
+	maxPassword = 20
+	maxPacket   = 1024
+)
+
+// Privilege levels of sessions.
+const (
+	PrivilegeCallback      = 1
+	PrivilegeUser          = 2
+	PrivilegeOperator      = 3
+	PrivilegeAdministrator = 4
+	PrivilegeOEM           = 5
+)
+
+// Authentication, integrity and confidentiality algorithms.
+const (
+	authNone       = 0x00
+	authHMACSHA1   = 0x01
+	authHMACSHA256 = 0x03
+
+	integrityNone          = 0x00
+	integrityHMACSHA1_96   = 0x01
+	integrityHMACSHA256128 = 0x04
+
+	confidentialityNone      = 0x00
+	confidentialityAESCBC128 = 0x01
+)
+
+type cipherSuite struct {
+	auth, integrity, confidentiality uint8
+}
+
+// cipherSuites are the supported cipher suites, from table 22-20.
+var cipherSuites = map[uint8]cipherSuite{
+	0:  {authNone, integrityNone, confidentialityNone},
+	1:  {authHMACSHA1, integrityNone, confidentialityNone},
+	2:  {authHMACSHA1, integrityHMACSHA1_96, confidentialityNone},
+	3:  {authHMACSHA1, integrityHMACSHA1_96, confidentialityAESCBC128},
+	15: {authHMACSHA256, integrityNone, confidentialityNone},
+	16: {authHMACSHA256, integrityHMACSHA256128, confidentialityNone},
+	17: {authHMACSHA256, integrityHMACSHA256128, confidentialityAESCBC128},
+}
+
+// authHash returns the hash of the authentication algorithm, and the length
+// of the integrity check value of RAKP 4.
+func (c cipherSuite) authHash() (func() hash.Hash, int) {
+	switch c.auth {
+	case authHMACSHA1:
+		return sha1.New, 12
+	case authHMACSHA256:
+		return sha256.New, 16
+	}
+	return nil, 0
+}
+
+// integrityHash returns the hash of the integrity algorithm and the length
+// of the AuthCode.
+func (c cipherSuite) integrityHash() (func() hash.Hash, int) {
+	switch c.integrity {
+	case integrityHMACSHA1_96:
+		return sha1.New, 12
+	case integrityHMACSHA256128:
+		return sha256.New, 16
+	}
+	return nil, 0
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data ...[]byte) []byte {
+	m := hmac.New(h, key)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+// RMCPStatus is the status code of an RMCP+ session setup message. It is
+// returned as an error if it is not 0.
+type RMCPStatus uint8
+
+var rmcpStatus = []string{
+	0x01: "insufficient resources to create a session",
+	0x02: "invalid session ID",
+	0x03: "invalid payload type",
+	0x04: "invalid authentication algorithm",
+	0x05: "invalid integrity algorithm",
+	0x06: "no matching authentication payload",
+	0x07: "no matching integrity payload",
+	0x08: "inactive session ID",
+	0x09: "invalid role",
+	0x0a: "unauthorized role or privilege level requested",
+	0x0b: "insufficient resources to create a session at the requested role",
+	0x0c: "invalid name length",
+	0x0d: "unauthorized name",
+	0x0e: "unauthorized GUID",
+	0x0f: "invalid integrity check value",
+	0x10: "invalid confidentiality algorithm",
+	0x11: "no cipher suite match with proposed security algorithms",
+	0x12: "illegal or unrecognized parameter",
+}
+
+func (s RMCPStatus) Error() string {
+	if int(s) < len(rmcpStatus) && rmcpStatus[s] != "" {
+		return fmt.Sprintf("RMCP+ status %#02x: %s", uint8(s), rmcpStatus[s])
+	}
+	return fmt.Sprintf("RMCP+ status %#02x", uint8(s))
+}
+
+// lan is the Transport of an RMCP+ session.
+type lan struct {
+	mu   sync.Mutex
+	conn net.Conn
+
+	username  []byte
+	password  []byte
+	kg        []byte
+	privilege uint8
+	suiteID   uint8
+	suite     cipherSuite
+	timeout   time.Duration
+	retries   int
+
+	consoleID, bmcID uint32
+	active           bool
+	// seq is the session sequence number of the last request, rqSeq
+	// the IPMI sequence number and tag the message tag of the session
+	// setup.
+	seq    uint32
+	rqSeq  uint8
+	tag    uint8
+	k1, k2 []byte
+}
+
+// LANOpt sets an option of OpenLAN.
+type LANOpt func(*lan)
+
+// WithCipherSuite sets the cipher suite of the session. The default is 3,
+// HMAC-SHA1 authentication and integrity and AES-CBC-128 encryption. 0, 1,
+// 2, 3, 15, 16 and 17 are supported.
+func WithCipherSuite(id uint8) LANOpt {
+	return func(l *lan) {
+		l.suiteID = id
+	}
+}
+
+// WithPrivilege sets the privilege level of the session. The default is
+// PrivilegeAdministrator.
+func WithPrivilege(level uint8) LANOpt {
+	return func(l *lan) {
+		l.privilege = level
+	}
+}
+
+// WithLANTimeout sets how long to wait for a response before sending a
+// request again, and how often to send it again.
+func WithLANTimeout(timeout time.Duration, retries int) LANOpt {
+	return func(l *lan) {
+		l.timeout, l.retries = timeout, retries
+	}
+}
+
+// WithBMCKey sets the BMC key, K_G. The password is used if it is not set.
+func WithBMCKey(kg []byte) LANOpt {
+	return func(l *lan) {
+		l.kg = kg
+	}
+}
+
+// OpenLAN opens an RMCP+ session to the BMC at addr, the host and
+// optionally the port, 623 by default.
+func OpenLAN(addr, username, password string, opts ...LANOpt) (*IPMI, error) {
+	l := &lan{
+		username:  []byte(username),
+		password:  []byte(password),
+		privilege: PrivilegeAdministrator,
+		suiteID:   3,
+		timeout:   2 * time.Second,
+		retries:   3,
+	}
+	for _, o := range opts {
+		o(l)
+	}
+	var ok bool
+	if l.suite, ok = cipherSuites[l.suiteID]; !ok {
+		return nil, fmt.Errorf("cipher suite %d is not supported", l.suiteID)
+	}
+	if len(l.username) > maxUsername {
+		return nil, fmt.Errorf("user name is %d bytes, want at most %d", len(l.username), maxUsername)
+	}
+	if len(l.password) > maxPassword {
+		return nil, fmt.Errorf("password is %d bytes, want at most %d", len(l.password), maxPassword)
+	}
+	if l.kg == nil {
+		l.kg = l.password
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, rmcpPort)
+	}
+
+	var err error
+	if l.conn, err = net.Dial("udp", addr); err != nil {
+		return nil, err
+	}
+	if err := l.open(); err != nil {
+		l.conn.Close()
+		return nil, fmt.Errorf("opening RMCP+ session to %s: %w", addr, err)
+	}
+	return &IPMI{Transport: l}, nil
+}
+
+func (l *lan) open() error {
+	// The BMC must support IPMI v2.0 on the channel.
+	caps, err := l.sendRecv(_IPMI_NETFN_APP, BMC_GET_CHANNEL_AUTH_CAPABILITIES, []byte{channelIPMIv2 | currentChannel, l.privilege})
+	if err != nil {
+		return err
+	}
+	if len(caps) < 5 || caps[2]&channelIPMIv2 == 0 || caps[4]&extCapIPMIv2 == 0 {
+		return errors.New("BMC does not support IPMI v2.0")
+	}
+
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+	// 0 means no session.
+	l.consoleID = binary.LittleEndian.Uint32(id[:]) | 1
+	if err := l.openSession(); err != nil {
+		return err
+	}
+	if err := l.rakp(); err != nil {
+		return err
+	}
+	l.active = true
+
+	// Sessions start at user level.
+	if _, err := l.sendRecv(_IPMI_NETFN_APP, BMC_SET_SESSION_PRIVILEGE_LEVEL, []byte{l.privilege}); err != nil {
+		l.active = false
+		return fmt.Errorf("setting privilege level %d: %w", l.privilege, err)
+	}
+	return nil
+}
+
+// exchange sends packets built by build until it receives a valid response
+// that want accepts, and returns it.
+func (l *lan) exchange(build func() ([]byte, error), want func(typ uint8, payload []byte) bool) error {
+	buf := make([]byte, maxPacket)
+	var last error
+	for try := 0; try <= l.retries; try++ {
+		p, err := build()
+		if err != nil {
+			return err
+		}
+		if _, err := l.conn.Write(p); err != nil {
+			return err
+		}
+		if err := l.conn.SetReadDeadline(time.Now().Add(l.timeout)); err != nil {
+			return err
+		}
+		for {
+			n, err := l.conn.Read(buf)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			// Invalid packets and late responses to requests that
+			// were sent again are ignored.
+			typ, payload, err := l.parse(buf[:n])
+			if err != nil {
+				last = err
+				continue
+			}
+			if want(typ, payload) {
+				return nil
+			}
+		}
+	}
+	if last != nil {
+		return fmt.Errorf("no valid response after %d tries, last: %v", l.retries+1, last)
+	}
+	return fmt.Errorf("no response after %d tries", l.retries+1)
+}
+
+// packet builds an RMCP+ packet, authenticated and encrypted if the session
+// is active and its cipher suite says so.
+func (l *lan) packet(typ uint8, payload []byte) ([]byte, error) {
+	var id, seq uint32
+	if l.active {
+		id = l.bmcID
+		l.seq++
+		seq = l.seq
+		if l.suite.confidentiality != confidentialityNone {
+			var err error
+			if payload, err = l.encrypt(payload); err != nil {
+				return nil, err
+			}
+			typ |= payloadEncrypted
+		}
+		if l.suite.integrity != integrityNone {
+			typ |= payloadAuthenticated
+		}
+	}
+	b := []byte{rmcpVersion, 0, rmcpNoAck, rmcpClassIPMI, authTypeRMCPPlus, typ}
+	b = append(b, le32(id)...)
+	b = append(b, le32(seq)...)
+	b = append(b, byte(len(payload)), byte(len(payload)>>8))
+	b = append(b, payload...)
+	if typ&payloadAuthenticated != 0 {
+		// The AuthCode covers the session header to the next header,
+		// which is padded to a multiple of 4 bytes.
+		pad := (4 - (len(b)-4+2)%4) % 4
+		for i := 0; i < pad; i++ {
+			b = append(b, 0xff)
+		}
+		b = append(b, byte(pad), nextHeader)
+		h, n := l.suite.integrityHash()
+		b = append(b, hmacSum(h, l.k1, b[4:])[:n]...)
+	}
+	return b, nil
+}
+
+// v15Packet builds an IPMI v1.5 packet without a session, for Get Channel
+// Authentication Capabilities.
+func v15Packet(msg []byte) []byte {
+	b := []byte{rmcpVersion, 0, rmcpNoAck, rmcpClassIPMI, authTypeNone, 0, 0, 0, 0, 0, 0, 0, 0, byte(len(msg))}
+	return append(b, msg...)
+}
+
+// parse checks a packet and returns its payload type and decrypted payload.
+func (l *lan) parse(b []byte) (uint8, []byte, error) {
+	if len(b) < 5 || b[0] != rmcpVersion || b[3] != rmcpClassIPMI {
+		return 0, nil, errors.New("not an RMCP IPMI packet")
+	}
+	b = b[4:]
+	if b[0] == authTypeNone {
+		if len(b) < 10 || len(b) < 10+int(b[9]) {
+			return 0, nil, errors.New("short IPMI v1.5 packet")
+		}
+		return payloadIPMI, b[10 : 10+int(b[9])], nil
+	}
+	if b[0] != authTypeRMCPPlus || len(b) < 12 {
+		return 0, nil, fmt.Errorf("packet with auth type %#x", b[0])
+	}
+	typ := b[1]
+	id := binary.LittleEndian.Uint32(b[2:])
+	n := int(binary.LittleEndian.Uint16(b[10:]))
+	if 12+n > len(b) {
+		return 0, nil, fmt.Errorf("payload of %d bytes in a packet of %d", n, len(b))
+	}
+	payload := b[12 : 12+n]
+	if !l.active {
+		if typ&(payloadAuthenticated|payloadEncrypted) != 0 {
+			return 0, nil, errors.New("authenticated or encrypted packet before the session is active")
+		}
+		return typ, payload, nil
+	}
+
+	if id != l.consoleID {
+		return 0, nil, fmt.Errorf("packet for session %#x, want %#x", id, l.consoleID)
+	}
+	if l.suite.integrity != integrityNone {
+		if typ&payloadAuthenticated == 0 {
+			return 0, nil, errors.New("unauthenticated packet")
+		}
+		h, size := l.suite.integrityHash()
+		end := len(b) - size
+		if end < 12+n+2 || b[end-1] != nextHeader || end != 12+n+int(b[end-2])+2 {
+			return 0, nil, errors.New("bad integrity trailer")
+		}
+		if !hmac.Equal(hmacSum(h, l.k1, b[:end])[:size], b[end:]) {
+			return 0, nil, errors.New("bad AuthCode")
+		}
+	}
+	if l.suite.confidentiality != confidentialityNone {
+		if typ&payloadEncrypted == 0 {
+			return 0, nil, errors.New("unencrypted packet")
+		}
+		var err error
+		if payload, err = l.decrypt(payload); err != nil {
+			return 0, nil, err
+		}
+	}
+	return typ & payloadTypeMask, payload, nil
+}
+
+// encrypt encrypts a payload with AES-CBC-128: a random IV, followed by the
+// payload, padded with 1, 2, 3, ... and the pad length to a multiple of the
+// block size.
+func (l *lan) encrypt(p []byte) ([]byte, error) {
+	c, err := aes.NewCipher(l.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+	pad := (aes.BlockSize - (len(p)+1)%aes.BlockSize) % aes.BlockSize
+	b := make([]byte, aes.BlockSize, aes.BlockSize+len(p)+pad+1)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	b = append(b, p...)
+	for i := 1; i <= pad; i++ {
+		b = append(b, byte(i))
+	}
+	b = append(b, byte(pad))
+	cipher.NewCBCEncrypter(c, b[:aes.BlockSize]).CryptBlocks(b[aes.BlockSize:], b[aes.BlockSize:])
+	return b, nil
+}
+
+func (l *lan) decrypt(b []byte) ([]byte, error) {
+	if len(b) < 2*aes.BlockSize || len(b)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted payload has %d bytes", len(b))
+	}
+	c, err := aes.NewCipher(l.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+	p := make([]byte, len(b)-aes.BlockSize)
+	cipher.NewCBCDecrypter(c, b[:aes.BlockSize]).CryptBlocks(p, b[aes.BlockSize:])
+	pad := int(p[len(p)-1])
+	if pad >= len(p) {
+		return nil, fmt.Errorf("bad confidentiality pad length %d", pad)
+	}
+	return p[:len(p)-1-pad], nil
+}
+
+func (l *lan) openSession() error {
+	l.tag++
+	req := []byte{l.tag, l.privilege, 0, 0}
+	req = append(req, le32(l.consoleID)...)
+	req = append(req,
+		0x00, 0, 0, 8, l.suite.auth, 0, 0, 0,
+		0x01, 0, 0, 8, l.suite.integrity, 0, 0, 0,
+		0x02, 0, 0, 8, l.suite.confidentiality, 0, 0, 0)
+
+	var r []byte
+	err := l.exchange(func() ([]byte, error) {
+		return l.packet(payloadOpenSessionRequest, req)
+	}, func(typ uint8, p []byte) bool {
+		r = p
+		return typ == payloadOpenSessionResponse && len(p) >= 2 && p[0] == l.tag
+	})
+	if err != nil {
+		return err
+	}
+	if r[1] != 0 {
+		return RMCPStatus(r[1])
+	}
+	if len(r) < 36 {
+		return fmt.Errorf("open session response has %d bytes, want 36", len(r))
+	}
+	if id := binary.LittleEndian.Uint32(r[4:]); id != l.consoleID {
+		return fmt.Errorf("open session response for session %#x, want %#x", id, l.consoleID)
+	}
+	if r[16] != l.suite.auth || r[24] != l.suite.integrity || r[32] != l.suite.confidentiality {
+		return fmt.Errorf("BMC chose algorithms %d, %d, %d, not those of cipher suite %d", r[16], r[24], r[32], l.suiteID)
+	}
+	l.bmcID = binary.LittleEndian.Uint32(r[8:])
+	return nil
+}
+
+// rakp authenticates the user and the BMC to each other with RAKP messages 1
+// to 4, and derives the session keys.
+func (l *lan) rakp() error {
+	rm := make([]byte, 16)
+	if _, err := rand.Read(rm); err != nil {
+		return err
+	}
+	role := []byte{l.privilege | nameOnlyLookup}
+	user := append([]byte{byte(len(l.username))}, l.username...)
+	h, icvSize := l.suite.authHash()
+
+	l.tag++
+	req := []byte{l.tag, 0, 0, 0}
+	req = append(req, le32(l.bmcID)...)
+	req = append(req, rm...)
+	req = append(req, role[0], 0, 0)
+	req = append(req, user...)
+	var r []byte
+	err := l.exchange(func() ([]byte, error) {
+		return l.packet(payloadRAKP1, req)
+	}, func(typ uint8, p []byte) bool {
+		r = p
+		return typ == payloadRAKP2 && len(p) >= 2 && p[0] == l.tag
+	})
+	if err != nil {
+		return err
+	}
+	if r[1] != 0 {
+		return RMCPStatus(r[1])
+	}
+	if len(r) < 40 {
+		return fmt.Errorf("RAKP 2 has %d bytes, want at least 40", len(r))
+	}
+	rc, guid := r[8:24], r[24:40]
+	if h != nil {
+		want := hmacSum(h, l.password, le32(l.consoleID), le32(l.bmcID), rm, rc, guid, role, user)
+		if !hmac.Equal(r[40:], want) {
+			return errors.New("RAKP 2 has a bad key exchange authentication code: wrong password?")
+		}
+	}
+
+	l.tag++
+	req = []byte{l.tag, 0, 0, 0}
+	req = append(req, le32(l.bmcID)...)
+	if h != nil {
+		req = append(req, hmacSum(h, l.password, rc, le32(l.consoleID), role, user)...)
+	}
+	err = l.exchange(func() ([]byte, error) {
+		return l.packet(payloadRAKP3, req)
+	}, func(typ uint8, p []byte) bool {
+		r = p
+		return typ == payloadRAKP4 && len(p) >= 2 && p[0] == l.tag
+	})
+	if err != nil {
+		return err
+	}
+	if r[1] != 0 {
+		return RMCPStatus(r[1])
+	}
+	if h == nil {
+		return nil
+	}
+
+	sik := hmacSum(h, l.kg, rm, rc, role, user)
+	if len(r) < 8+icvSize || !hmac.Equal(r[8:8+icvSize], hmacSum(h, sik, rm, le32(l.bmcID), guid)[:icvSize]) {
+		return errors.New("RAKP 4 has a bad integrity check value: wrong BMC key?")
+	}
+	size := h().Size()
+	k := make([]byte, size)
+	for i := range k {
+		k[i] = 1
+	}
+	l.k1 = hmacSum(h, sik, k)
+	for i := range k {
+		k[i] = 2
+	}
+	l.k2 = hmacSum(h, sik, k)
+	return nil
+}
+
+// message encodes an IPMI request.
+func message(netfn NetFn, cmd Command, seq uint8, data []byte) []byte {
+	m := []byte{bmcSlaveAddr, byte(netfn) << 2, 0, remoteSWID, seq << 2, byte(cmd)}
+	m[2] = -(m[0] + m[1])
+	m = append(m, data...)
+	return append(m, -checksum(m[3:]))
+}
+
+// response checks an IPMI response to the request and returns the
+// completion code and data.
+func response(m []byte, netfn NetFn, cmd Command, seq uint8) ([]byte, error) {
+	if len(m) < 8 {
+		return nil, fmt.Errorf("response has %d bytes", len(m))
+	}
+	if checksum(m[:3]) != 0 || checksum(m[3:]) != 0 {
+		return nil, errors.New("bad response checksum")
+	}
+	if m[0] != remoteSWID || NetFn(m[1]>>2) != netfn|1 || m[4]>>2 != seq || Command(m[5]) != cmd {
+		return nil, errors.New("response to another request")
+	}
+	return m[6 : len(m)-1], nil
+}
+
+func (l *lan) sendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error) {
+	l.rqSeq = (l.rqSeq + 1) & 0x3f
+	msg := message(netfn, cmd, l.rqSeq, data)
+	var r []byte
+	err := l.exchange(func() ([]byte, error) {
+		if !l.active {
+			return v15Packet(msg), nil
+		}
+		return l.packet(payloadIPMI, msg)
+	}, func(typ uint8, p []byte) bool {
+		if typ != payloadIPMI {
+			return false
+		}
+		var err error
+		r, err = response(p, netfn, cmd, l.rqSeq)
+		return err == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("netfn %#x command %#x: %w", netfn, cmd, err)
+	}
+	if r[0] != 0 {
+		return nil, CompletionCode(r[0])
+	}
+	return r, nil
+}
+
+// SendRecv sends the IPMI message, receives the response, and returns the
+// response data.
+func (l *lan) SendRecv(netfn NetFn, cmd Command, data []byte) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.active {
+		return nil, errors.New("RMCP+ session is closed")
+	}
+	return l.sendRecv(netfn, cmd, data)
+}
+
+// Close closes the session.
+func (l *lan) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var err error
+	if l.active {
+		_, err = l.sendRecv(_IPMI_NETFN_APP, BMC_CLOSE_SESSION, le32(l.bmcID))
+		l.active = false
+	}
+	if cerr := l.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
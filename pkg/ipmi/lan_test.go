@@ -0,0 +1,474 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simBMC is a BMC that serves RMCP+ sessions on a loopback UDP port, and
+// answers commands with a fakeBMC.
+type simBMC struct {
+	t    *testing.T
+	conn *net.UDPConn
+	bmc  *fakeBMC
+
+	username, password, kg string
+	guid                   []byte
+
+	// drop drops the IPMI requests in the session with these numbers,
+	// counting from 1.
+	drop     map[int]bool
+	requests int
+
+	mu        sync.Mutex
+	consoleID uint32
+	bmcID     uint32
+	auth      uint8
+	integrity uint8
+	conf      uint8
+	rm, rc    []byte
+	role      byte
+	k1, k2    []byte
+	active    bool
+	closed    bool
+	privilege byte
+	seq       uint32
+	// errs are the packets the BMC rejected.
+	errs []string
+}
+
+func newSimBMC(t *testing.T) *simBMC {
+	c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &simBMC{
+		t:        t,
+		conn:     c,
+		bmc:      newFakeBMC(t),
+		username: "admin",
+		password: "secret",
+		guid:     []byte("0123456789abcdef"),
+		drop:     map[int]bool{},
+	}
+	go s.serve()
+	return s
+}
+
+func (s *simBMC) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *simBMC) stop() {
+	s.conn.Close()
+}
+
+func (s *simBMC) serve() {
+	buf := make([]byte, maxPacket)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		r, err := s.handle(buf[:n])
+		if err != nil {
+			s.errs = append(s.errs, err.Error())
+		}
+		s.mu.Unlock()
+		if r != nil {
+			s.conn.WriteTo(r, addr)
+		}
+	}
+}
+
+func (s *simBMC) hashes() (auth, integrity func() hash.Hash) {
+	switch s.auth {
+	case authHMACSHA1:
+		auth = sha1.New
+	case authHMACSHA256:
+		auth = sha256.New
+	}
+	switch s.integrity {
+	case integrityHMACSHA1_96:
+		integrity = sha1.New
+	case integrityHMACSHA256128:
+		integrity = sha256.New
+	}
+	return auth, integrity
+}
+
+func mac(h func() hash.Hash, key string, data ...[]byte) []byte {
+	m := hmac.New(h, []byte(key))
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+func (s *simBMC) handle(p []byte) ([]byte, error) {
+	if len(p) < 5 || !bytes.Equal(p[:4], []byte{0x06, 0x00, 0xff, 0x07}) {
+		return nil, errors.New("bad RMCP header")
+	}
+	if p[4] == 0x00 {
+		// Get Channel Authentication Capabilities, outside a session.
+		m := p[14:]
+		if NetFn(m[1]>>2) != _IPMI_NETFN_APP || Command(m[5]) != BMC_GET_CHANNEL_AUTH_CAPABILITIES {
+			return nil, fmt.Errorf("IPMI v1.5 command %#x", m[5])
+		}
+		r := s.response(m, []byte{0, 1, 0x80 | 0x01, 0x04, 0x02, 0, 0, 0, 0})
+		h := []byte{0x06, 0x00, 0xff, 0x07, 0, 0, 0, 0, 0, 0, 0, 0, 0, byte(len(r))}
+		return append(h, r...), nil
+	}
+
+	typ := p[5]
+	id := binary.LittleEndian.Uint32(p[6:])
+	n := int(binary.LittleEndian.Uint16(p[14:]))
+	payload := p[16 : 16+n]
+	switch typ & 0x3f {
+	case payloadOpenSessionRequest:
+		s.consoleID = binary.LittleEndian.Uint32(payload[4:])
+		s.auth, s.integrity, s.conf = payload[12], payload[20], payload[28]
+		s.bmcID = 0x1000 + s.consoleID%0x1000
+		s.active, s.closed, s.seq = false, false, 0
+		r := []byte{payload[0], 0, 4, 0}
+		r = append(r, le32(s.consoleID)...)
+		r = append(r, le32(s.bmcID)...)
+		r = append(r, payload[8:32]...)
+		return s.plain(payloadOpenSessionResponse, r), nil
+
+	case payloadRAKP1:
+		if binary.LittleEndian.Uint32(payload[4:]) != s.bmcID {
+			return s.plain(payloadRAKP2, []byte{payload[0], 0x02, 0, 0}), nil
+		}
+		s.rm = append([]byte{}, payload[8:24]...)
+		s.role = payload[24]
+		name := string(payload[28 : 28+int(payload[27])])
+		if name != s.username {
+			return s.plain(payloadRAKP2, []byte{payload[0], 0x0d, 0, 0}), nil
+		}
+		s.rc = []byte("fedcba9876543210")
+		r := []byte{payload[0], 0, 0, 0}
+		r = append(r, le32(s.consoleID)...)
+		r = append(r, s.rc...)
+		r = append(r, s.guid...)
+		if h, _ := s.hashes(); h != nil {
+			r = append(r, mac(h, s.password, le32(s.consoleID), le32(s.bmcID), s.rm, s.rc, s.guid, []byte{s.role, byte(len(name))}, []byte(name))...)
+		}
+		return s.plain(payloadRAKP2, r), nil
+
+	case payloadRAKP3:
+		h, _ := s.hashes()
+		user := append([]byte{s.role, byte(len(s.username))}, s.username...)
+		if h != nil && !hmac.Equal(payload[8:], mac(h, s.password, s.rc, le32(s.consoleID), user)) {
+			return s.plain(payloadRAKP4, []byte{payload[0], 0x0f, 0, 0}), nil
+		}
+		r := []byte{payload[0], 0, 0, 0}
+		r = append(r, le32(s.consoleID)...)
+		if h != nil {
+			kg := s.kg
+			if kg == "" {
+				kg = s.password
+			}
+			sik := string(mac(h, kg, s.rm, s.rc, user))
+			icv := mac(h, sik, s.rm, le32(s.bmcID), s.guid)
+			if s.auth == authHMACSHA1 {
+				r = append(r, icv[:12]...)
+			} else {
+				r = append(r, icv[:16]...)
+			}
+			s.k1 = mac(h, sik, bytes.Repeat([]byte{1}, h().Size()))
+			s.k2 = mac(h, sik, bytes.Repeat([]byte{2}, h().Size()))
+		}
+		s.active = true
+		return s.plain(payloadRAKP4, r), nil
+
+	case payloadIPMI:
+		if !s.active || id != s.bmcID {
+			return nil, fmt.Errorf("IPMI request for session %#x", id)
+		}
+		m, err := s.open(p, typ, payload)
+		if err != nil {
+			return nil, err
+		}
+		s.requests++
+		if s.drop[s.requests] {
+			return nil, nil
+		}
+		return s.seal(s.response(m, s.command(m)))
+	}
+	return nil, fmt.Errorf("payload type %#x", typ)
+}
+
+// plain returns an unauthenticated RMCP+ packet.
+func (s *simBMC) plain(typ uint8, payload []byte) []byte {
+	b := []byte{0x06, 0x00, 0xff, 0x07, 0x06, typ, 0, 0, 0, 0, 0, 0, 0, 0, byte(len(payload)), byte(len(payload) >> 8)}
+	return append(b, payload...)
+}
+
+// open checks and decrypts a request in the session.
+func (s *simBMC) open(p []byte, typ uint8, payload []byte) ([]byte, error) {
+	if _, h := s.hashes(); h != nil {
+		if typ&0x40 == 0 {
+			return nil, errors.New("unauthenticated request")
+		}
+		size := 12
+		if s.integrity == integrityHMACSHA256128 {
+			size = 16
+		}
+		end := len(p) - size
+		if (end-4)%4 != 0 || p[end-1] != 0x07 {
+			return nil, fmt.Errorf("bad integrity pad, %d bytes authenticated", end-4)
+		}
+		if !hmac.Equal(p[end:], mac(h, string(s.k1), p[4:end])[:size]) {
+			return nil, errors.New("bad AuthCode")
+		}
+	}
+	if s.conf == confidentialityNone {
+		return payload, nil
+	}
+	if typ&0x80 == 0 {
+		return nil, errors.New("unencrypted request")
+	}
+	c, err := aes.NewCipher(s.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+	m := make([]byte, len(payload)-16)
+	cipher.NewCBCDecrypter(c, payload[:16]).CryptBlocks(m, payload[16:])
+	pad := int(m[len(m)-1])
+	for i := 0; i < pad; i++ {
+		if m[len(m)-1-pad+i] != byte(i+1) {
+			return nil, fmt.Errorf("bad confidentiality pad %x", m[len(m)-1-pad:])
+		}
+	}
+	return m[:len(m)-1-pad], nil
+}
+
+// seal encrypts and authenticates a response in the session.
+func (s *simBMC) seal(m []byte) ([]byte, error) {
+	typ := byte(payloadIPMI)
+	if s.conf != confidentialityNone {
+		c, err := aes.NewCipher(s.k2[:16])
+		if err != nil {
+			return nil, err
+		}
+		pad := 15 - len(m)%16
+		e := append([]byte("an IV of 16 byte"), m...)
+		for i := 1; i <= pad; i++ {
+			e = append(e, byte(i))
+		}
+		e = append(e, byte(pad))
+		cipher.NewCBCEncrypter(c, e[:16]).CryptBlocks(e[16:], e[16:])
+		m = e
+		typ |= 0x80
+	}
+	_, h := s.hashes()
+	if h != nil {
+		typ |= 0x40
+	}
+	s.seq++
+	b := []byte{0x06, 0x00, 0xff, 0x07, 0x06, typ}
+	b = append(b, le32(s.consoleID)...)
+	b = append(b, le32(s.seq)...)
+	b = append(b, byte(len(m)), byte(len(m)>>8))
+	b = append(b, m...)
+	if h != nil {
+		pad := 0
+		for (len(b)+2-4)%4 != 0 {
+			b = append(b, 0xff)
+			pad++
+		}
+		b = append(b, byte(pad), 0x07)
+		code := mac(h, string(s.k1), b[4:])
+		if s.integrity == integrityHMACSHA1_96 {
+			b = append(b, code[:12]...)
+		} else {
+			b = append(b, code[:16]...)
+		}
+	}
+	return b, nil
+}
+
+// response builds the response to the request m.
+func (s *simBMC) response(m, data []byte) []byte {
+	r := []byte{m[3], m[1] + 4, 0, m[0], m[4], m[5]}
+	r[2] = -(r[0] + r[1])
+	r = append(r, data...)
+	var sum byte
+	for _, v := range r[3:] {
+		sum += v
+	}
+	return append(r, -sum)
+}
+
+// command answers the request m.
+func (s *simBMC) command(m []byte) []byte {
+	netfn, cmd, data := NetFn(m[1]>>2), Command(m[5]), m[6:len(m)-1]
+	switch {
+	case netfn == _IPMI_NETFN_APP && cmd == BMC_GET_DEVICE_ID:
+		return []byte{0, 0x20, 0x81, 0x02, 0x31, 0x02, 0xbf, 0x57, 0x01, 0x00, 0x34, 0x12, 0, 0, 0, 0}
+	case netfn == _IPMI_NETFN_APP && cmd == BMC_SET_SESSION_PRIVILEGE_LEVEL:
+		s.privilege = data[0]
+		return []byte{0, data[0]}
+	case netfn == _IPMI_NETFN_APP && cmd == BMC_CLOSE_SESSION:
+		if binary.LittleEndian.Uint32(data) != s.bmcID {
+			return []byte{0x87}
+		}
+		s.closed = true
+		return []byte{0}
+	}
+	r, err := s.bmc.SendRecv(netfn, cmd, data)
+	var cc CompletionCode
+	if errors.As(err, &cc) {
+		return []byte{byte(cc)}
+	}
+	if err != nil {
+		s.t.Error(err)
+		return []byte{byte(CCUnspecified)}
+	}
+	return r
+}
+
+func TestLAN(t *testing.T) {
+	for _, suite := range []uint8{0, 1, 2, 3, 15, 16, 17} {
+		t.Run(fmt.Sprintf("suite %d", suite), func(t *testing.T) {
+			s := newSimBMC(t)
+			defer s.stop()
+
+			i, err := OpenLAN(s.addr(), s.username, s.password, WithCipherSuite(suite), WithPrivilege(PrivilegeOperator))
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, err := i.GetDeviceID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id.DeviceID != 0x20 || id.ProductID != [2]byte{0x34, 0x12} {
+				t.Errorf("GetDeviceID() = %+v, want device 0x20, product 0x1234", id)
+			}
+			// Many requests, of different sizes.
+			sdrs, err := GetSDRs(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sdrs) != 6 {
+				t.Errorf("GetSDRs() returned %d records, want 6", len(sdrs))
+			}
+			if _, err := GetSensorReading(i, 0x99); !isCompletionCode(err, CCNotPresent) {
+				t.Errorf("GetSensorReading(0x99) = %v, want %v", err, CCNotPresent)
+			}
+			if err := i.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.privilege != PrivilegeOperator {
+				t.Errorf("session privilege is %d, want %d", s.privilege, PrivilegeOperator)
+			}
+			if !s.closed {
+				t.Errorf("session was not closed")
+			}
+			if s.errs != nil {
+				t.Errorf("BMC rejected packets: %q", s.errs)
+			}
+		})
+	}
+}
+
+func TestLANAuthFails(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		username string
+		password string
+		kg       string
+		opts     []LANOpt
+		want     string
+	}{
+		{name: "wrong password", username: "admin", password: "guess", want: "wrong password"},
+		{name: "wrong user", username: "root", password: "secret", want: RMCPStatus(0x0d).Error()},
+		{name: "wrong BMC key", username: "admin", password: "secret", kg: "key", want: "wrong BMC key"},
+		{name: "unsupported suite", username: "admin", password: "secret", opts: []LANOpt{WithCipherSuite(4)}, want: "cipher suite 4"},
+		{name: "long password", username: "admin", password: strings.Repeat("x", 21), want: "password is 21 bytes"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSimBMC(t)
+			defer s.stop()
+			s.mu.Lock()
+			s.kg = tt.kg
+			s.mu.Unlock()
+
+			i, err := OpenLAN(s.addr(), tt.username, tt.password, tt.opts...)
+			if err == nil {
+				i.Close()
+				t.Fatalf("OpenLAN() succeeded, want error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("OpenLAN() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLANBMCKey(t *testing.T) {
+	s := newSimBMC(t)
+	defer s.stop()
+	s.mu.Lock()
+	s.kg = "key"
+	s.mu.Unlock()
+
+	i, err := OpenLAN(s.addr(), s.username, s.password, WithBMCKey([]byte("key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+	if _, err := i.GetDeviceID(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLANRetry(t *testing.T) {
+	s := newSimBMC(t)
+	defer s.stop()
+	i, err := OpenLAN(s.addr(), s.username, s.password, WithLANTimeout(50*time.Millisecond, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+
+	// The first request after Set Session Privilege Level is lost, and
+	// sent again.
+	s.mu.Lock()
+	s.drop[2] = true
+	s.mu.Unlock()
+	if _, err := i.GetDeviceID(); err != nil {
+		t.Fatalf("GetDeviceID() with a lost request = %v", err)
+	}
+
+	s.mu.Lock()
+	for n := s.requests + 1; n <= s.requests+3; n++ {
+		s.drop[n] = true
+	}
+	s.mu.Unlock()
+	if _, err := i.GetDeviceID(); err == nil || !strings.Contains(err.Error(), "no response after 3 tries") {
+		t.Errorf("GetDeviceID() with all requests lost = %v, want no response after 3 tries", err)
+	}
+}
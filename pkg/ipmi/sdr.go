@@ -0,0 +1,456 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// SDRType is the type of a sensor data record.
+type SDRType uint8
+
+// SDR types, from the IPMI specification, section 43.
+const (
+	SDRFullSensor           SDRType = 0x01
+	SDRCompactSensor        SDRType = 0x02
+	SDREventOnly            SDRType = 0x03
+	SDREntityAssociation    SDRType = 0x08
+	SDRGenericDeviceLocator SDRType = 0x10
+	SDRFRUDeviceLocator     SDRType = 0x11
+	SDRMCDeviceLocator      SDRType = 0x12
+	SDROEM                  SDRType = 0xc0
+)
+
+var sdrTypes = map[SDRType]string{
+	SDRFullSensor:           "Full Sensor",
+	SDRCompactSensor:        "Compact Sensor",
+	SDREventOnly:            "Event-Only",
+	SDREntityAssociation:    "Entity Association",
+	SDRGenericDeviceLocator: "Generic Device Locator",
+	SDRFRUDeviceLocator:     "FRU Device Locator",
+	SDRMCDeviceLocator:      "MC Device Locator",
+	SDROEM:                  "OEM",
+}
+
+func (t SDRType) String() string {
+	if s, ok := sdrTypes[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown (%#02x)", uint8(t))
+}
+
+const (
+	sdrHeaderSize = 5
+
+	// sdrChunk is how much of a record is read at once. The whole record
+	// does not fit in the response of many BMCs; if even this does not,
+	// it is halved.
+	sdrChunk = 16
+
+	// sdrRetries is how often the repository is reserved again when
+	// another client cancels the reservation while reading a record.
+	sdrRetries = 5
+
+	// lastRecord is the ID of the next record after the last one.
+	lastRecord = 0xffff
+)
+
+// SDRRepositoryInfo is the response to Get SDR Repository Info.
+type SDRRepositoryInfo struct {
+	Version       byte
+	Records       uint16
+	FreeSpace     uint16
+	LastAddTime   uint32
+	LastEraseTime uint32
+	OpSupport     byte
+}
+
+// GetSDRRepositoryInfo returns information about the SDR repository.
+func GetSDRRepositoryInfo(s SendRecver) (*SDRRepositoryInfo, error) {
+	data, err := sendRecv(s, _IPMI_NETFN_STORAGE, BMC_GET_SDR_REPOSITORY_INFO, nil, 14)
+	if err != nil {
+		return nil, err
+	}
+	var info SDRRepositoryInfo
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// SDR is a sensor data record.
+type SDR struct {
+	ID      uint16
+	Version uint8
+	Type    SDRType
+	// Data is the record after the header.
+	Data []byte
+}
+
+// sdrReader reads the records of the repository.
+type sdrReader struct {
+	s           SendRecver
+	reservation uint16
+	chunk       int
+}
+
+func (r *sdrReader) reserve() error {
+	data, err := sendRecv(r.s, _IPMI_NETFN_STORAGE, BMC_RESERVE_SDR_REPOSITORY, nil, 2)
+	if err != nil {
+		return fmt.Errorf("reserving SDR repository: %w", err)
+	}
+	r.reservation = binary.LittleEndian.Uint16(data)
+	return nil
+}
+
+// read reads n bytes at off of the record id, and returns them and the ID
+// of the next record.
+func (r *sdrReader) read(id uint16, off, n int) ([]byte, uint16, error) {
+	req := make([]byte, 6)
+	binary.LittleEndian.PutUint16(req[0:], r.reservation)
+	binary.LittleEndian.PutUint16(req[2:], id)
+	req[4], req[5] = byte(off), byte(n)
+	data, err := sendRecv(r.s, _IPMI_NETFN_STORAGE, BMC_GET_SDR, req, 2+n)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data[2 : 2+n], binary.LittleEndian.Uint16(data), nil
+}
+
+// record reads the record id and returns it and the ID of the next one.
+func (r *sdrReader) record(id uint16) (*SDR, uint16, error) {
+	h, next, err := r.read(id, 0, sdrHeaderSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	sdr := &SDR{
+		ID:      binary.LittleEndian.Uint16(h),
+		Version: h[2],
+		Type:    SDRType(h[3]),
+		Data:    make([]byte, 0, h[4]),
+	}
+	for n := int(h[4]); len(sdr.Data) < n; {
+		c := r.chunk
+		if rest := n - len(sdr.Data); c > rest {
+			c = rest
+		}
+		b, _, err := r.read(id, sdrHeaderSize+len(sdr.Data), c)
+		if isCompletionCode(err, CCCannotReturnBytes) && r.chunk > 1 {
+			r.chunk /= 2
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		sdr.Data = append(sdr.Data, b...)
+	}
+	return sdr, next, nil
+}
+
+// GetSDRs reads all the records of the SDR repository.
+func GetSDRs(s SendRecver) ([]*SDR, error) {
+	r := &sdrReader{s: s, chunk: sdrChunk}
+	if err := r.reserve(); err != nil {
+		return nil, err
+	}
+	var sdrs []*SDR
+	retries := 0
+	for id := uint16(0); id != lastRecord; {
+		sdr, next, err := r.record(id)
+		if isCompletionCode(err, CCReservationCanceled) && retries < sdrRetries {
+			retries++
+			if err := r.reserve(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading SDR %#04x: %w", id, err)
+		}
+		sdrs = append(sdrs, sdr)
+		id = next
+	}
+	return sdrs, nil
+}
+
+// Analog data formats of sensor readings.
+const (
+	AnalogUnsigned       = 0
+	AnalogOnesComplement = 1
+	AnalogTwosComplement = 2
+	AnalogNone           = 3
+)
+
+// Linearizations of sensor readings: the function applied to a reading after
+// the linear conversion.
+const (
+	LinearizationLinear = iota
+	LinearizationLn
+	LinearizationLog10
+	LinearizationLog2
+	LinearizationE
+	LinearizationExp10
+	LinearizationExp2
+	LinearizationInverse
+	LinearizationSquare
+	LinearizationCube
+	LinearizationSqrt
+	LinearizationCubeRoot
+)
+
+// Threshold is a threshold of a sensor. Its value is the bit of the
+// threshold in the threshold masks and the threshold comparison status.
+type Threshold uint
+
+// Thresholds, from the least to the most severe for each direction.
+const (
+	LowerNonCritical    Threshold = 0
+	LowerCritical       Threshold = 1
+	LowerNonRecoverable Threshold = 2
+	UpperNonCritical    Threshold = 3
+	UpperCritical       Threshold = 4
+	UpperNonRecoverable Threshold = 5
+)
+
+var thresholds = []string{"lnc", "lcr", "lnr", "unc", "ucr", "unr"}
+
+func (t Threshold) String() string {
+	if int(t) < len(thresholds) {
+		return thresholds[t]
+	}
+	return fmt.Sprintf("threshold %d", uint(t))
+}
+
+// SensorRecord is a full or compact sensor record. The conversion factors
+// and thresholds are only in full records.
+type SensorRecord struct {
+	Type             SDRType
+	OwnerID          uint8
+	OwnerLUN         uint8
+	Number           uint8
+	EntityID         uint8
+	EntityInstance   uint8
+	SensorType       SensorType
+	EventReadingType uint8
+
+	// ReadableThresholds has the bits of the readable thresholds.
+	ReadableThresholds uint8
+
+	// Units are the sensor units 1 byte: the analog data format in
+	// bits 7:6, the modifier unit in bits 2:1 and percentage in bit 0.
+	Units        uint8
+	BaseUnit     uint8
+	ModifierUnit uint8
+
+	Linearization uint8
+	M, B          int16
+	RExp, BExp    int8
+
+	// Thresholds are the raw readings of the thresholds, indexed by
+	// Threshold.
+	Thresholds [6]uint8
+
+	Name string
+}
+
+// Offsets in full and compact sensor records, after the header.
+const (
+	sensorOwnerID          = 0
+	sensorOwnerLUN         = 1
+	sensorNumber           = 2
+	sensorEntityID         = 3
+	sensorEntityInstance   = 4
+	sensorType             = 7
+	sensorEventReadingType = 8
+	sensorReadableMask     = 13
+	sensorUnits            = 15
+	sensorBaseUnit         = 16
+	sensorModifierUnit     = 17
+	sensorLinearization    = 18
+	sensorM                = 19
+	sensorB                = 21
+	sensorExponents        = 24
+	sensorUNR              = 31
+	fullSensorName         = 42
+	compactSensorName      = 26
+)
+
+// sign extends the n bit two's complement number v.
+func sign(v int, n uint) int {
+	if v&(1<<(n-1)) != 0 {
+		return v - 1<<n
+	}
+	return v
+}
+
+// Sensor decodes a full or compact sensor record.
+func (r *SDR) Sensor() (*SensorRecord, error) {
+	var nameOff int
+	switch r.Type {
+	case SDRFullSensor:
+		nameOff = fullSensorName
+	case SDRCompactSensor:
+		nameOff = compactSensorName
+	default:
+		return nil, fmt.Errorf("SDR %#04x is a %v record, not a sensor record", r.ID, r.Type)
+	}
+	d := r.Data
+	if len(d) < nameOff+1 {
+		return nil, fmt.Errorf("%v record %#04x has %d bytes, want at least %d", r.Type, r.ID, len(d), nameOff+1)
+	}
+	s := &SensorRecord{
+		Type:               r.Type,
+		OwnerID:            d[sensorOwnerID],
+		OwnerLUN:           d[sensorOwnerLUN] & 0x3,
+		Number:             d[sensorNumber],
+		EntityID:           d[sensorEntityID],
+		EntityInstance:     d[sensorEntityInstance],
+		SensorType:         SensorType(d[sensorType]),
+		EventReadingType:   d[sensorEventReadingType],
+		ReadableThresholds: d[sensorReadableMask] & 0x3f,
+		Units:              d[sensorUnits],
+		BaseUnit:           d[sensorBaseUnit],
+		ModifierUnit:       d[sensorModifierUnit],
+	}
+	if r.Type == SDRFullSensor {
+		s.Linearization = d[sensorLinearization] & 0x7f
+		s.M = int16(sign(int(d[sensorM])|int(d[sensorM+1]>>6)<<8, 10))
+		s.B = int16(sign(int(d[sensorB])|int(d[sensorB+1]>>6)<<8, 10))
+		s.RExp = int8(sign(int(d[sensorExponents]>>4), 4))
+		s.BExp = int8(sign(int(d[sensorExponents]&0xf), 4))
+		// The record has them from UNR down to LNC.
+		for i := range s.Thresholds {
+			s.Thresholds[len(s.Thresholds)-1-i] = d[sensorUNR+i]
+		}
+	}
+	tl := d[nameOff]
+	name := d[nameOff+1:]
+	if n := int(tl & 0x1f); n < len(name) {
+		name = name[:n]
+	}
+	s.Name = decodeString(tl>>6, name)
+	return s, nil
+}
+
+// AnalogFormat returns the analog data format of the readings, e.g.
+// AnalogUnsigned.
+func (s *SensorRecord) AnalogFormat() int {
+	return int(s.Units >> 6)
+}
+
+// IsThreshold reports whether the sensor is a threshold based sensor.
+func (s *SensorRecord) IsThreshold() bool {
+	return s.EventReadingType == EventTypeThreshold
+}
+
+// Analog reports whether raw readings can be converted to values.
+func (s *SensorRecord) Analog() bool {
+	return s.Type == SDRFullSensor && s.AnalogFormat() != AnalogNone
+}
+
+// Convert converts a raw reading to a value in the units of the sensor:
+//
+//	y = L((M*x + B*10^BExp) * 10^RExp)
+//
+// where L is the linearization.
+func (s *SensorRecord) Convert(raw uint8) (float64, error) {
+	if !s.Analog() {
+		return 0, fmt.Errorf("sensor %q has no analog readings", s.Name)
+	}
+	var x float64
+	switch s.AnalogFormat() {
+	case AnalogUnsigned:
+		x = float64(raw)
+	case AnalogOnesComplement:
+		if v := int8(raw); v < 0 {
+			x = float64(v + 1)
+		} else {
+			x = float64(v)
+		}
+	case AnalogTwosComplement:
+		x = float64(int8(raw))
+	}
+	y := (float64(s.M)*x + float64(s.B)*math.Pow10(int(s.BExp))) * math.Pow10(int(s.RExp))
+	switch s.Linearization {
+	case LinearizationLinear:
+	case LinearizationLn:
+		y = math.Log(y)
+	case LinearizationLog10:
+		y = math.Log10(y)
+	case LinearizationLog2:
+		y = math.Log2(y)
+	case LinearizationE:
+		y = math.Exp(y)
+	case LinearizationExp10:
+		y = math.Pow(10, y)
+	case LinearizationExp2:
+		y = math.Exp2(y)
+	case LinearizationInverse:
+		y = 1 / y
+	case LinearizationSquare:
+		y = y * y
+	case LinearizationCube:
+		y = y * y * y
+	case LinearizationSqrt:
+		y = math.Sqrt(y)
+	case LinearizationCubeRoot:
+		y = math.Cbrt(y)
+	default:
+		return 0, fmt.Errorf("sensor %q has non-linear linearization %#02x", s.Name, s.Linearization)
+	}
+	return y, nil
+}
+
+// Threshold returns the value of a threshold, if the threshold is readable.
+func (s *SensorRecord) Threshold(t Threshold) (float64, bool) {
+	if int(t) >= len(s.Thresholds) || s.ReadableThresholds&(1<<t) == 0 {
+		return 0, false
+	}
+	v, err := s.Convert(s.Thresholds[t])
+	return v, err == nil
+}
+
+// UnitString returns the units of the sensor, e.g. "degrees C" or
+// "Watts/hour".
+func (s *SensorRecord) UnitString() string {
+	u := unitName(s.BaseUnit)
+	switch (s.Units >> 1) & 0x3 {
+	case 1:
+		u += "/" + unitName(s.ModifierUnit)
+	case 2:
+		u += "*" + unitName(s.ModifierUnit)
+	}
+	if s.Units&1 != 0 {
+		u = "% " + u
+	}
+	return u
+}
+
+var units = []string{
+	"unspecified", "degrees C", "degrees F", "degrees K", "Volts", "Amps",
+	"Watts", "Joules", "Coulombs", "VA", "Nits", "lumen", "lux", "Candela",
+	"kPa", "PSI", "Newton", "CFM", "RPM", "Hz", "microsecond", "millisecond",
+	"second", "minute", "hour", "day", "week", "mil", "inches", "feet",
+	"cu in", "cu feet", "mm", "cm", "m", "cu cm", "cu m", "liters",
+	"fluid ounce", "radians", "steradians", "revolutions", "cycles",
+	"gravities", "ounce", "pound", "ft-lb", "oz-in", "gauss", "gilberts",
+	"henry", "millihenry", "farad", "microfarad", "ohms", "siemens", "mole",
+	"becquerel", "PPM", "reserved", "Decibels", "DbA", "DbC", "gray",
+	"sievert", "color temp deg K", "bit", "kilobit", "megabit", "gigabit",
+	"byte", "kilobyte", "megabyte", "gigabyte", "word", "dword", "qword",
+	"line", "hit", "miss", "retry", "reset", "overflow", "underrun",
+	"collision", "packets", "messages", "characters", "error",
+	"correctable error", "uncorrectable error", "fatal error", "grams",
+}
+
+func unitName(u uint8) string {
+	if int(u) < len(units) {
+		return units[u]
+	}
+	return fmt.Sprintf("unit %d", u)
+}
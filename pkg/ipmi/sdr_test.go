@@ -0,0 +1,238 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestGetSDRRepositoryInfo(t *testing.T) {
+	info, err := GetSDRRepositoryInfo(newFakeBMC(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SDRRepositoryInfo{Version: 0x51, Records: 6, FreeSpace: 0x1000, LastAddTime: 0x04030201, LastEraseTime: 0xffffffff, OpSupport: 0x2f}
+	if *info != want {
+		t.Errorf("GetSDRRepositoryInfo() = %+v, want %+v", *info, want)
+	}
+}
+
+func TestGetSDRs(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		maxRead int
+		cancel  int
+	}{
+		{name: "whole chunks", maxRead: 32},
+		{name: "small chunks", maxRead: 5},
+		{name: "canceled reservation", maxRead: 32, cancel: 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newFakeBMC(t)
+			b.maxRead, b.cancel = tt.maxRead, tt.cancel
+			sdrs, err := GetSDRs(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range sdrs {
+				got = append(got, fmt.Sprintf("%d %v %d", r.ID, r.Type, len(r.Data)))
+			}
+			want := []string{
+				"1 Full Sensor 51",
+				"2 Full Sensor 46",
+				"3 Full Sensor 47",
+				"4 Compact Sensor 38",
+				"5 Full Sensor 53",
+				"6 FRU Device Locator 19",
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("GetSDRs() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGetSDRsErrors(t *testing.T) {
+	b := newFakeBMC(t)
+	// The BMC can not return even the header.
+	b.maxRead = 0
+	if _, err := GetSDRs(b); !isCompletionCode(err, CCCannotReturnBytes) {
+		t.Errorf("GetSDRs() = %v, want %v", err, CCCannotReturnBytes)
+	}
+
+	b = newFakeBMC(t)
+	b.sdr = b.sdr[:len(b.sdr)-1]
+	if _, err := GetSDRs(b); !isCompletionCode(err, CCParameterOutOfRange) {
+		t.Errorf("GetSDRs() of truncated record = %v, want %v", err, CCParameterOutOfRange)
+	}
+}
+
+func sensors(t *testing.T) map[string]*SensorRecord {
+	sdrs, err := GetSDRs(newFakeBMC(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := map[string]*SensorRecord{}
+	for _, r := range sdrs {
+		sr, err := r.Sensor()
+		if r.Type != SDRFullSensor && r.Type != SDRCompactSensor {
+			if err == nil {
+				t.Errorf("SDR %d is a %v record, but Sensor() succeeded", r.ID, r.Type)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		s[sr.Name] = sr
+	}
+	return s
+}
+
+func TestSensorRecord(t *testing.T) {
+	s := sensors(t)
+	for _, tt := range []struct {
+		name   string
+		number uint8
+		typ    SensorType
+		units  string
+		analog bool
+		raw    uint8
+		value  float64
+		thr    map[Threshold]float64
+	}{
+		{
+			name: "CPU Temp", number: 1, typ: 0x01, units: "degrees C", analog: true,
+			raw: 45, value: 45,
+			thr: map[Threshold]float64{UpperNonCritical: 85, UpperCritical: 90, UpperNonRecoverable: 95},
+		},
+		{
+			name: "12V", number: 2, typ: 0x02, units: "Volts", analog: true,
+			raw: 0xbe, value: 11.97,
+			thr: map[Threshold]float64{LowerCritical: 10.71, UpperCritical: 13.23},
+		},
+		{
+			name: "FAN1", number: 3, typ: 0x04, units: "RPM", analog: true,
+			raw: 4, value: 400,
+			thr: map[Threshold]float64{LowerNonCritical: 500, LowerCritical: 300},
+		},
+		{
+			name: "PSU1 Status", number: 4, typ: 0x08, units: "unspecified",
+		},
+		{
+			// Two's complement readings, and B = -5.
+			name: "Inlet Temp", number: 5, typ: 0x01, units: "degrees C", analog: true,
+			raw: 0xf6, value: -15,
+			thr: map[Threshold]float64{UpperCritical: 40},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := s[tt.name]
+			if !ok {
+				t.Fatalf("no sensor %q in %v", tt.name, s)
+			}
+			if r.Number != tt.number || r.SensorType != tt.typ {
+				t.Errorf("sensor %#x of type %v, want %#x of type %v", r.Number, r.SensorType, tt.number, tt.typ)
+			}
+			if u := r.UnitString(); u != tt.units {
+				t.Errorf("UnitString() = %q, want %q", u, tt.units)
+			}
+			if r.Analog() != tt.analog {
+				t.Errorf("Analog() = %v, want %v", r.Analog(), tt.analog)
+			}
+			v, err := r.Convert(tt.raw)
+			if !tt.analog {
+				if err == nil {
+					t.Errorf("Convert(%#x) = %v, want error", tt.raw, v)
+				}
+				return
+			}
+			if err != nil || math.Abs(v-tt.value) > 1e-9 {
+				t.Errorf("Convert(%#x) = %v, %v, want %v", tt.raw, v, err, tt.value)
+			}
+			for th := LowerNonCritical; th <= UpperNonRecoverable; th++ {
+				v, ok := r.Threshold(th)
+				want, wantOK := tt.thr[th]
+				if ok != wantOK || math.Abs(v-want) > 1e-9 {
+					t.Errorf("Threshold(%v) = %v, %v, want %v, %v", th, v, ok, want, wantOK)
+				}
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	for _, tt := range []struct {
+		r    SensorRecord
+		raw  uint8
+		want float64
+	}{
+		{SensorRecord{M: 1}, 200, 200},
+		{SensorRecord{M: 1, Units: AnalogOnesComplement << 6}, 0xfe, -1},
+		{SensorRecord{M: 1, Units: AnalogOnesComplement << 6}, 0xff, 0},
+		{SensorRecord{M: 1, Units: AnalogTwosComplement << 6}, 0xff, -1},
+		{SensorRecord{M: 2, B: 3, BExp: 1, RExp: -1}, 10, 5},
+		{SensorRecord{M: 1, Linearization: LinearizationInverse}, 4, 0.25},
+		{SensorRecord{M: 1, Linearization: LinearizationSquare}, 12, 144},
+		{SensorRecord{M: 1, Linearization: LinearizationLog10}, 100, 2},
+	} {
+		tt.r.Type = SDRFullSensor
+		if got, err := tt.r.Convert(tt.raw); err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%+v.Convert(%#x) = %v, %v, want %v", tt.r, tt.raw, got, err, tt.want)
+		}
+	}
+
+	r := SensorRecord{Type: SDRFullSensor, M: 1, Linearization: 0x70}
+	if _, err := r.Convert(1); err == nil {
+		t.Errorf("Convert with non-linear linearization succeeded")
+	}
+}
+
+func TestGetSensorReading(t *testing.T) {
+	b := newFakeBMC(t)
+	for _, tt := range []struct {
+		number uint8
+		raw    uint8
+		state  uint16
+		status string
+	}{
+		{0x01, 45, 0, "ok"},
+		{0x03, 4, 1 << LowerNonCritical, "nc"},
+		{0x04, 0, 1 << LowerCritical, "cr"},
+		{0x05, 0, 0, "ns"},
+	} {
+		r, err := GetSensorReading(b, tt.number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Raw != tt.raw || r.State != tt.state || r.ThresholdStatus() != tt.status {
+			t.Errorf("GetSensorReading(%#x) = %+v with status %q, want reading %d, state %#x, status %q", tt.number, r, r.ThresholdStatus(), tt.raw, tt.state, tt.status)
+		}
+	}
+
+	_, err := GetSensorReading(b, 0x99)
+	var cc CompletionCode
+	if !errors.As(err, &cc) || cc != CCNotPresent {
+		t.Errorf("GetSensorReading(0x99) = %v, want %v", err, CCNotPresent)
+	}
+}
+
+func TestCompletionCode(t *testing.T) {
+	for _, tt := range []struct {
+		cc   CompletionCode
+		want string
+	}{
+		{CCReservationCanceled, "completion code 0xc5: reservation canceled or invalid"},
+		{0x80, "completion code 0x80"},
+	} {
+		if got := tt.cc.Error(); got != tt.want {
+			t.Errorf("%#x.Error() = %q, want %q", byte(tt.cc), got, tt.want)
+		}
+	}
+}
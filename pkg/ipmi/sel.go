@@ -0,0 +1,193 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const (
+	selEntrySize = 16
+
+	// Record types of OEM SEL entries. Types below them have the layout
+	// of system events, type 0x02.
+	selOEMTs    = 0xc0
+	selOEMNonTs = 0xe0
+
+	// Timestamps up to selPreInit count the seconds since the BMC was
+	// initialized, not since the epoch.
+	selPreInit = 0x20000000
+
+	// Arguments and response of Clear SEL.
+	selClearInitiate   = 0xaa
+	selClearGetStatus  = 0x00
+	selClearCompleted  = 0x01
+	selClearPollPeriod = 100 * time.Millisecond
+	selClearPolls      = 50
+)
+
+// sleep is replaced by the tests.
+var sleep = time.Sleep
+
+// unmarshalEvent decodes an SEL entry. It is the reverse of Event.marshall.
+func unmarshalEvent(b []byte) (*Event, error) {
+	if len(b) < selEntrySize {
+		return nil, fmt.Errorf("SEL entry has %d bytes, want %d", len(b), selEntrySize)
+	}
+	e := &Event{
+		RecordID:   binary.LittleEndian.Uint16(b[0:]),
+		RecordType: b[2],
+	}
+	switch {
+	case e.RecordType < selOEMTs:
+		e.StandardEvent = StandardEvent{
+			Timestamp:    binary.LittleEndian.Uint32(b[3:]),
+			GenID:        binary.LittleEndian.Uint16(b[7:]),
+			EvMRev:       b[9],
+			SensorType:   b[10],
+			SensorNum:    b[11],
+			EventTypeDir: b[12],
+		}
+		copy(e.EventData[:], b[13:16])
+	case e.RecordType < selOEMNonTs:
+		e.OEMTsEvent.Timestamp = binary.LittleEndian.Uint32(b[3:])
+		copy(e.ManfID[:], b[7:10])
+		copy(e.OEMTsDefinedData[:], b[10:16])
+	default:
+		copy(e.OEMNontsDefinedData[:], b[3:16])
+	}
+	return e, nil
+}
+
+// timestamp returns the timestamp of the event in seconds, and whether it
+// has one.
+func (e *Event) timestamp() (uint32, bool) {
+	switch {
+	case e.RecordType < selOEMTs:
+		return e.StandardEvent.Timestamp, true
+	case e.RecordType < selOEMNonTs:
+		return e.OEMTsEvent.Timestamp, true
+	}
+	return 0, false
+}
+
+// Time returns the time of the event. It is the zero time if the event has
+// no timestamp or was logged before the BMC's clock was set.
+func (e *Event) Time() time.Time {
+	ts, ok := e.timestamp()
+	if !ok || ts <= selPreInit {
+		return time.Time{}
+	}
+	return time.Unix(int64(ts), 0).UTC()
+}
+
+// Asserted reports whether a system event is an assertion, not a
+// deassertion.
+func (e *Event) Asserted() bool {
+	return e.EventTypeDir&0x80 == 0
+}
+
+// Description describes a system event, e.g. "Upper Critical going high".
+func (e *Event) Description() string {
+	return EventDescription(SensorType(e.SensorType), e.EventTypeDir&0x7f, e.EventData[0]&0xf)
+}
+
+// String formats the event as ipmitool sel list does, e.g.
+//
+//	1 | 10/19/2020 | 09:00:00 | Temperature #0x30 | Upper Critical going high | Asserted
+func (e *Event) String() string {
+	var when string
+	if ts, ok := e.timestamp(); !ok {
+		when = " | "
+	} else if ts <= selPreInit {
+		when = fmt.Sprintf("Pre-Init | %010d", ts)
+	} else {
+		when = e.Time().Format("01/02/2006 | 15:04:05")
+	}
+	s := fmt.Sprintf("%4x | %s | ", e.RecordID, when)
+	switch {
+	case e.RecordType < selOEMTs:
+		dir := "Asserted"
+		if !e.Asserted() {
+			dir = "Deasserted"
+		}
+		s += fmt.Sprintf("%s #%#02x | %s | %s", SensorType(e.SensorType), e.SensorNum, e.Description(), dir)
+	case e.RecordType < selOEMNonTs:
+		s += fmt.Sprintf("OEM record %02x | %02x%02x%02x | %x", e.RecordType, e.ManfID[2], e.ManfID[1], e.ManfID[0], e.OEMTsDefinedData)
+	default:
+		s += fmt.Sprintf("OEM record %02x | %x", e.RecordType, e.OEMNontsDefinedData)
+	}
+	return s
+}
+
+// GetSELEntry reads the SEL entry with the given record ID, 0 for the first
+// one. It returns the entry and the ID of the next one, 0xffff after the
+// last.
+func GetSELEntry(s SendRecver, id uint16) (*Event, uint16, error) {
+	// Entries are read whole, which does not need a reservation.
+	req := make([]byte, 6)
+	binary.LittleEndian.PutUint16(req[2:], id)
+	req[5] = 0xff
+	data, err := sendRecv(s, _IPMI_NETFN_STORAGE, BMC_GET_SEL_ENTRY, req, 2+selEntrySize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading SEL entry %#04x: %w", id, err)
+	}
+	e, err := unmarshalEvent(data[2:])
+	if err != nil {
+		return nil, 0, err
+	}
+	return e, binary.LittleEndian.Uint16(data), nil
+}
+
+// GetSELEntries reads all the SEL entries.
+func GetSELEntries(s SendRecver) ([]*Event, error) {
+	var events []*Event
+	for id := uint16(0); id != lastRecord; {
+		e, next, err := GetSELEntry(s, id)
+		// An empty SEL has no first entry.
+		if id == 0 && isCompletionCode(err, CCNotPresent) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+		id = next
+	}
+	return events, nil
+}
+
+func reserveSEL(s SendRecver) ([]byte, error) {
+	data, err := sendRecv(s, _IPMI_NETFN_STORAGE, BMC_RESERVE_SEL, nil, 2)
+	if err != nil {
+		return nil, fmt.Errorf("reserving SEL: %w", err)
+	}
+	return data[:2], nil
+}
+
+// ClearSEL erases all SEL entries and waits until they are erased.
+func ClearSEL(s SendRecver) error {
+	r, err := reserveSEL(s)
+	if err != nil {
+		return err
+	}
+	req := []byte{r[0], r[1], 'C', 'L', 'R', selClearInitiate}
+	for i := 0; ; i++ {
+		data, err := sendRecv(s, _IPMI_NETFN_STORAGE, BMC_CLEAR_SEL, req, 1)
+		if err != nil {
+			return fmt.Errorf("clearing SEL: %w", err)
+		}
+		if data[0]&0xf == selClearCompleted {
+			return nil
+		}
+		if i == selClearPolls {
+			return fmt.Errorf("clearing SEL: not done after %v", selClearPolls*selClearPollPeriod)
+		}
+		sleep(selClearPollPeriod)
+		req[len(req)-1] = selClearGetStatus
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetSELEntries(t *testing.T) {
+	b := newFakeBMC(t)
+	events, err := GetSELEntries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.String())
+	}
+	want := []string{
+		"   1 | 10/19/2020 | 09:00:00 | Temperature #0x01 | Upper Critical going high | Asserted",
+		"   2 | 10/19/2020 | 10:00:00 | Power Supply #0x04 | Failure detected | Deasserted",
+		"   3 | Pre-Init | 0000004660 | System Event #0x80 | Undetermined system hardware failure | Asserted",
+		"   4 | 10/19/2020 | 09:01:00 | OEM record c1 | 002a7c | 010203040506",
+		"   5 |  |  | OEM record e0 | 101112131415161718191a1b1c",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSELEntries() = \n%q\nwant\n%q", got, want)
+	}
+
+	// The events are decoded as they are encoded.
+	for _, e := range events {
+		m, err := e.marshall()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e2, err := unmarshalEvent(m); err != nil || !reflect.DeepEqual(e, e2) {
+			t.Errorf("unmarshalEvent(%x) = %+v, %v, want %+v", m, e2, err, e)
+		}
+	}
+
+	if want := time.Date(2020, 10, 19, 9, 0, 0, 0, time.UTC); !events[0].Time().Equal(want) {
+		t.Errorf("Time() = %v, want %v", events[0].Time(), want)
+	}
+	if !events[2].Time().IsZero() || !events[4].Time().IsZero() {
+		t.Errorf("pre-init and non-timestamped events have times %v and %v, want zero", events[2].Time(), events[4].Time())
+	}
+}
+
+func TestClearSEL(t *testing.T) {
+	b := newFakeBMC(t)
+	b.clearPolls = 2
+	var slept time.Duration
+	sleep = func(d time.Duration) { slept += d }
+	defer func() { sleep = time.Sleep }()
+
+	if err := ClearSEL(b); err != nil {
+		t.Fatal(err)
+	}
+	if want := 2 * selClearPollPeriod; slept != want {
+		t.Errorf("ClearSEL() waited %v, want %v", slept, want)
+	}
+	events, err := GetSELEntries(b)
+	if err != nil || len(events) != 0 {
+		t.Errorf("GetSELEntries() after ClearSEL() = %v, %v, want none", events, err)
+	}
+
+	b.clearPolls = selClearPolls + 1
+	if err := ClearSEL(b); err == nil {
+		t.Errorf("ClearSEL() that does not complete succeeded")
+	}
+}
+
+func TestEventDescription(t *testing.T) {
+	for _, tt := range []struct {
+		st        SensorType
+		eventType uint8
+		offset    uint8
+		want      string
+	}{
+		{0x01, EventTypeThreshold, 0x0b, "Upper Non-recoverable going high"},
+		{0x04, 0x07, 0x02, "Transition to Critical from less severe"},
+		{0x0c, EventTypeSensorSpecific, 0x01, "Uncorrectable ECC"},
+		{0x0c, EventTypeSensorSpecific, 0x0f, "Unknown event 0x6f offset 0xf"},
+		{0xc0, 0x70, 0x01, "OEM event 0x70 offset 0x1"},
+	} {
+		if got := EventDescription(tt.st, tt.eventType, tt.offset); got != tt.want {
+			t.Errorf("EventDescription(%v, %#x, %#x) = %q, want %q", tt.st, tt.eventType, tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ipmi
+
+import (
+	"fmt"
+)
+
+// SensorType is the type of a sensor, from the IPMI specification, table
+// 42-3.
+type SensorType uint8
+
+var sensorTypes = []string{
+	0x00: "Reserved",
+	0x01: "Temperature",
+	0x02: "Voltage",
+	0x03: "Current",
+	0x04: "Fan",
+	0x05: "Physical Security",
+	0x06: "Platform Security",
+	0x07: "Processor",
+	0x08: "Power Supply",
+	0x09: "Power Unit",
+	0x0a: "Cooling Device",
+	0x0b: "Other",
+	0x0c: "Memory",
+	0x0d: "Drive Slot / Bay",
+	0x0e: "POST Memory Resize",
+	0x0f: "System Firmware Progress",
+	0x10: "Event Logging Disabled",
+	0x11: "Watchdog 1",
+	0x12: "System Event",
+	0x13: "Critical Interrupt",
+	0x14: "Button / Switch",
+	0x15: "Module / Board",
+	0x16: "Microcontroller / Coprocessor",
+	0x17: "Add-in Card",
+	0x18: "Chassis",
+	0x19: "Chip Set",
+	0x1a: "Other FRU",
+	0x1b: "Cable / Interconnect",
+	0x1c: "Terminator",
+	0x1d: "System Boot Initiated",
+	0x1e: "Boot Error",
+	0x1f: "OS Boot",
+	0x20: "OS Critical Stop",
+	0x21: "Slot / Connector",
+	0x22: "System ACPI Power State",
+	0x23: "Watchdog 2",
+	0x24: "Platform Alert",
+	0x25: "Entity Presence",
+	0x26: "Monitor ASIC",
+	0x27: "LAN",
+	0x28: "Management Subsystem Health",
+	0x29: "Battery",
+	0x2a: "Session Audit",
+	0x2b: "Version Change",
+	0x2c: "FRU State",
+}
+
+func (t SensorType) String() string {
+	switch {
+	case int(t) < len(sensorTypes):
+		return sensorTypes[t]
+	case t >= 0xc0:
+		return fmt.Sprintf("OEM (%#02x)", uint8(t))
+	}
+	return fmt.Sprintf("Unknown (%#02x)", uint8(t))
+}
+
+// Bits of the second byte of the Get Sensor Reading response. The first two
+// are clear if events or scanning are disabled.
+const (
+	readingEventsEnabled   = 1 << 7
+	readingScanningEnabled = 1 << 6
+	readingUnavailable     = 1 << 5
+)
+
+// SensorReading is the response to Get Sensor Reading.
+type SensorReading struct {
+	// Raw is the reading, which SensorRecord.Convert converts.
+	Raw uint8
+
+	EventsDisabled   bool
+	ScanningDisabled bool
+	Unavailable      bool
+
+	// State has the threshold comparison status of threshold based
+	// sensors, with the bits of the Thresholds that are crossed, or the
+	// asserted states of discrete sensors.
+	State uint16
+}
+
+// GetSensorReading reads the sensor with the given number. The BMC must own
+// the sensor, on LUN 0.
+func GetSensorReading(s SendRecver, number uint8) (*SensorReading, error) {
+	data, err := sendRecv(s, _IPMI_NETFN_SENSOR, BMC_GET_SENSOR_READING, []byte{number}, 2)
+	if err != nil {
+		return nil, fmt.Errorf("reading sensor %#02x: %w", number, err)
+	}
+	r := &SensorReading{
+		Raw:              data[0],
+		EventsDisabled:   data[1]&readingEventsEnabled == 0,
+		ScanningDisabled: data[1]&readingScanningEnabled == 0,
+		Unavailable:      data[1]&readingUnavailable != 0,
+	}
+	// The states are optional.
+	if len(data) > 2 {
+		r.State = uint16(data[2])
+	}
+	if len(data) > 3 {
+		r.State |= uint16(data[3]&0x7f) << 8
+	}
+	return r, nil
+}
+
+// Crossed reports whether the reading of a threshold based sensor crossed
+// the threshold t.
+func (r *SensorReading) Crossed(t Threshold) bool {
+	return r.State&(1<<t) != 0
+}
+
+// ThresholdStatus returns the status of a threshold based sensor as
+// ipmitool does: "ns" if there is no reading, "nr", "cr" or "nc" if it
+// crossed a non-recoverable, critical or non-critical threshold, and "ok"
+// otherwise.
+func (r *SensorReading) ThresholdStatus() string {
+	switch {
+	case r.Unavailable || r.ScanningDisabled:
+		return "ns"
+	case r.Crossed(UpperNonRecoverable) || r.Crossed(LowerNonRecoverable):
+		return "nr"
+	case r.Crossed(UpperCritical) || r.Crossed(LowerCritical):
+		return "cr"
+	case r.Crossed(UpperNonCritical) || r.Crossed(LowerNonCritical):
+		return "nc"
+	}
+	return "ok"
+}
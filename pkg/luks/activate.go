@@ -0,0 +1,53 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/dm"
+)
+
+// CryptTarget returns the dm-crypt target that decrypts the data of the
+// LUKS device at path, which is size bytes, with key.
+func (h *Header) CryptTarget(path string, size int64, key []byte) (dm.Target, error) {
+	s, err := h.Segment()
+	if err != nil {
+		return dm.Target{}, err
+	}
+	ss := s.SectorSize
+	if ss == 0 {
+		ss = sectorSize
+	}
+	length := s.Size
+	if length < 0 {
+		length = size - s.Offset
+	}
+	if length <= 0 || s.Offset+length > size {
+		return dm.Target{}, fmt.Errorf("LUKS data at %d of %d bytes does not fit the %d byte device", s.Offset, length, size)
+	}
+	if s.Offset%sectorSize != 0 {
+		return dm.Target{}, fmt.Errorf("LUKS data offset %d is not a multiple of %d", s.Offset, sectorSize)
+	}
+	length -= length % int64(ss)
+
+	c := dm.Crypt{
+		Cipher:         s.Encryption,
+		Key:            key,
+		IVOffset:       s.IVTweak,
+		Device:         path,
+		Offset:         uint64(s.Offset / sectorSize),
+		SectorSize:     ss,
+		IVLargeSectors: h.Version == 2,
+	}
+	return c.Target(0, uint64(length/dm.SectorSize))
+}
+
+// DMUUID returns the device-mapper UUID for name that cryptsetup would
+// use, so that tools that know cryptsetup devices recognize it.
+func (h *Header) DMUUID(name string) string {
+	return fmt.Sprintf("CRYPT-LUKS%d-%s-%s", h.Version, strings.Replace(h.UUID, "-", "", -1), name)
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"io"
+	"os"
+
+	"github.com/u-root/u-root/pkg/dm"
+)
+
+// Activate makes a dm-crypt device called name for the LUKS device at
+// path, decrypting its data with key.
+func (h *Header) Activate(c *dm.Control, name, path string, key []byte, readOnly bool) (*dm.Device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	t, err := h.CryptTarget(path, size, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.Create(name, h.DMUUID(name), readOnly, []dm.Target{t})
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// diffuse replaces b with its hash, computed a digest-sized block at a
+// time, each prefixed with its big-endian block number.
+func diffuse(b []byte, h hash.Hash) {
+	var iv [4]byte
+	ds := h.Size()
+	var sum []byte
+	for i := 0; i*ds < len(b); i++ {
+		block := b[i*ds:]
+		if len(block) > ds {
+			block = block[:ds]
+		}
+		binary.BigEndian.PutUint32(iv[:], uint32(i))
+		h.Reset()
+		h.Write(iv[:])
+		h.Write(block)
+		sum = h.Sum(sum[:0])
+		copy(block, sum)
+	}
+}
+
+func xor(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+// AFMerge recovers a key of keySize bytes from material split into
+// stripes by the anti-forensic splitter with hashName.
+func AFMerge(material []byte, keySize, stripes int, hashName string) ([]byte, error) {
+	if len(material) != keySize*stripes {
+		return nil, fmt.Errorf("%d bytes of key material are not %d stripes of %d bytes", len(material), stripes, keySize)
+	}
+	newHash, err := hashFunc(hashName)
+	if err != nil {
+		return nil, err
+	}
+	h := newHash()
+	d := make([]byte, keySize)
+	for i := 0; i < stripes-1; i++ {
+		xor(d, d, material[i*keySize:])
+		diffuse(d, h)
+	}
+	xor(d, d, material[(stripes-1)*keySize:])
+	return d, nil
+}
+
+// AFSplit splits key into stripes with the anti-forensic splitter, using
+// random data from rand.
+func AFSplit(key []byte, stripes int, hashName string, rand io.Reader) ([]byte, error) {
+	if stripes < 1 {
+		return nil, fmt.Errorf("%d stripes", stripes)
+	}
+	newHash, err := hashFunc(hashName)
+	if err != nil {
+		return nil, err
+	}
+	h := newHash()
+	n := len(key)
+	material := make([]byte, n*stripes)
+	if _, err := io.ReadFull(rand, material[:n*(stripes-1)]); err != nil {
+		return nil, err
+	}
+	d := make([]byte, n)
+	for i := 0; i < stripes-1; i++ {
+		xor(d, d, material[i*n:])
+		diffuse(d, h)
+	}
+	xor(material[(stripes-1)*n:], d, key)
+	return material, nil
+}
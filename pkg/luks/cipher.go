@@ -0,0 +1,122 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/xts"
+)
+
+// decryptSectors decrypts data, which starts at sector 0 of a key slot
+// area, in place. spec is a dm-crypt cipher specification such as
+// "aes-xts-plain64" or "aes-cbc-essiv:sha256".
+func decryptSectors(spec string, key, data []byte) error {
+	if len(data)%sectorSize != 0 {
+		return fmt.Errorf("%d bytes are not whole sectors", len(data))
+	}
+	f := strings.SplitN(spec, "-", 3)
+	if len(f) < 2 || f[0] != "aes" {
+		return fmt.Errorf("unsupported cipher %q", spec)
+	}
+	mode, iv := f[1], ""
+	if len(f) == 3 {
+		iv = f[2]
+	}
+	ivOpts := ""
+	if i := strings.IndexByte(iv, ':'); i >= 0 {
+		iv, ivOpts = iv[:i], iv[i+1:]
+	}
+
+	switch mode {
+	case "xts":
+		// XTS's tweak is the little-endian sector number, which is
+		// plain64, or plain when the sector number fits 32 bits.
+		if iv != "plain64" && iv != "plain" {
+			return fmt.Errorf("unsupported IV %q for XTS in %q", iv, spec)
+		}
+		c, err := xts.NewCipher(aes.NewCipher, key)
+		if err != nil {
+			return fmt.Errorf("%s: %v", spec, err)
+		}
+		for s := 0; s*sectorSize < len(data); s++ {
+			sector := data[s*sectorSize : (s+1)*sectorSize]
+			c.Decrypt(sector, sector, uint64(s))
+		}
+		return nil
+
+	case "cbc":
+		b, err := aes.NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("%s: %v", spec, err)
+		}
+		ivGen, err := newIVGenerator(iv, ivOpts, key)
+		if err != nil {
+			return fmt.Errorf("%s: %v", spec, err)
+		}
+		ivb := make([]byte, aes.BlockSize)
+		for s := 0; s*sectorSize < len(data); s++ {
+			sector := data[s*sectorSize : (s+1)*sectorSize]
+			ivGen(ivb, uint64(s))
+			cipher.NewCBCDecrypter(b, ivb).CryptBlocks(sector, sector)
+		}
+		return nil
+
+	case "ecb":
+		if iv != "" {
+			return fmt.Errorf("ECB takes no IV in %q", spec)
+		}
+		b, err := aes.NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("%s: %v", spec, err)
+		}
+		for i := 0; i < len(data); i += aes.BlockSize {
+			b.Decrypt(data[i:], data[i:])
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported cipher mode %q in %q", mode, spec)
+	}
+}
+
+// newIVGenerator returns a function that sets the IV for a sector.
+func newIVGenerator(name, opts string, key []byte) (func(iv []byte, sector uint64), error) {
+	switch name {
+	case "plain":
+		return func(iv []byte, sector uint64) {
+			wipe(iv)
+			binary.LittleEndian.PutUint32(iv, uint32(sector))
+		}, nil
+	case "plain64":
+		return func(iv []byte, sector uint64) {
+			wipe(iv)
+			binary.LittleEndian.PutUint64(iv, sector)
+		}, nil
+	case "essiv":
+		// ESSIV encrypts the sector number with the hash of the key.
+		newHash, err := hashFunc(opts)
+		if err != nil {
+			return nil, err
+		}
+		h := newHash()
+		h.Write(key)
+		salt, err := aes.NewCipher(h.Sum(nil))
+		if err != nil {
+			return nil, fmt.Errorf("ESSIV hash %s: %v", opts, err)
+		}
+		return func(iv []byte, sector uint64) {
+			wipe(iv)
+			binary.LittleEndian.PutUint64(iv, sector)
+			salt.Encrypt(iv, iv)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported IV generator %q", name)
+	}
+}
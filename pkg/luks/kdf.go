@@ -0,0 +1,78 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// hashes are the hashes LUKS headers name, as cryptsetup spells them.
+var hashes = map[string]func() hash.Hash{
+	"sha1":      sha1.New,
+	"sha224":    sha256.New224,
+	"sha256":    sha256.New,
+	"sha384":    sha512.New384,
+	"sha512":    sha512.New,
+	"ripemd160": ripemd160.New,
+}
+
+func hashFunc(name string) (func() hash.Hash, error) {
+	h, ok := hashes[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash %q", name)
+	}
+	return h, nil
+}
+
+// derive returns a key of n bytes derived from passphrase.
+func (k *KDF) derive(passphrase []byte, n int) ([]byte, error) {
+	if len(k.Salt) == 0 {
+		return nil, fmt.Errorf("%s KDF has no salt", k.Type)
+	}
+	switch k.Type {
+	case "pbkdf2":
+		h, err := hashFunc(k.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if k.Iterations < 1 {
+			return nil, fmt.Errorf("PBKDF2 with %d iterations", k.Iterations)
+		}
+		return pbkdf2.Key(passphrase, k.Salt, k.Iterations, n, h), nil
+	case "argon2i", "argon2id":
+		if k.Time < 1 || k.Memory < 8*k.CPUs || k.CPUs < 1 || k.CPUs > 255 {
+			return nil, fmt.Errorf("%s with time %d, memory %d KiB and %d CPUs", k.Type, k.Time, k.Memory, k.CPUs)
+		}
+		if k.Type == "argon2i" {
+			return argon2.Key(passphrase, k.Salt, uint32(k.Time), uint32(k.Memory), uint8(k.CPUs), uint32(n)), nil
+		}
+		return argon2.IDKey(passphrase, k.Salt, uint32(k.Time), uint32(k.Memory), uint8(k.CPUs), uint32(n)), nil
+	default:
+		return nil, fmt.Errorf("unsupported KDF %q", k.Type)
+	}
+}
+
+// verify reports whether key matches d.
+func (d *Digest) verify(key []byte) (bool, error) {
+	if d.Type != "pbkdf2" {
+		return false, fmt.Errorf("unsupported digest %q", d.Type)
+	}
+	k := KDF{Type: d.Type, Hash: d.Hash, Iterations: d.Iterations, Salt: d.Salt}
+	got, err := k.derive(key, len(d.Digest))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(got, d.Digest) == 1, nil
+}
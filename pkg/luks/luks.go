@@ -0,0 +1,295 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package luks reads LUKS1 and LUKS2 headers and unlocks their volume keys.
+//
+// A LUKS device starts with a header that describes the encrypted data
+// and holds key slots. Each key slot stores the volume key, split with an
+// anti-forensic splitter and encrypted with a key derived from a
+// passphrase by PBKDF2 or Argon2. A digest of the volume key tells which
+// passphrase is right.
+//
+// Both header versions are read into a Header, which is modeled on the
+// LUKS2 JSON metadata.
+package luks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+var (
+	// ErrNotLUKS is returned when a device has no LUKS header.
+	ErrNotLUKS = errors.New("no LUKS header")
+
+	// ErrNoKey is returned when no key slot can be unlocked with a
+	// passphrase.
+	ErrNoKey = errors.New("no key slot matches the passphrase")
+)
+
+var (
+	magic1 = []byte("LUKS\xba\xbe")
+	magic2 = []byte("SKUL\xba\xbe")
+)
+
+// sectorSize is the unit of LUKS1 offsets and of key slot encryption.
+const sectorSize = 512
+
+// KDF derives a key slot's key from a passphrase.
+type KDF struct {
+	// Type is "pbkdf2", "argon2i" or "argon2id".
+	Type string
+
+	// Hash is the PBKDF2 hash, e.g. "sha256".
+	Hash string
+
+	// Iterations is the PBKDF2 iteration count.
+	Iterations int
+
+	// Time is the Argon2 iteration count.
+	Time int
+
+	// Memory is the Argon2 memory cost in KiB.
+	Memory int
+
+	// CPUs is the Argon2 parallelism.
+	CPUs int
+
+	Salt []byte
+}
+
+// Keyslot is a key slot.
+type Keyslot struct {
+	// ID is the slot number.
+	ID int
+
+	// KeySize is the size of the volume key in bytes.
+	KeySize int
+
+	// Priority is 0 to ignore the slot unless it is asked for, 1 for
+	// normal and 2 for high priority.
+	Priority int
+
+	// Offset and Size are the location of the key material in bytes.
+	Offset int64
+	Size   int64
+
+	// Encryption is the key material's cipher, e.g. "aes-xts-plain64".
+	Encryption string
+
+	// AreaKeySize is the size of the key derived by the KDF.
+	AreaKeySize int
+
+	// Stripes and AFHash are the anti-forensic splitter's parameters.
+	Stripes int
+	AFHash  string
+
+	KDF KDF
+}
+
+// Segment is a range of encrypted data.
+type Segment struct {
+	ID int
+
+	// Offset is the start of the data in bytes.
+	Offset int64
+
+	// Size is the size of the data in bytes, or -1 if it extends to the
+	// end of the device.
+	Size int64
+
+	// IVTweak is the IV of the first sector.
+	IVTweak uint64
+
+	// Encryption is the cipher, as dm-crypt names it, e.g.
+	// "aes-xts-plain64".
+	Encryption string
+
+	// SectorSize is the encryption sector size.
+	SectorSize int
+}
+
+// Digest verifies volume keys.
+type Digest struct {
+	// Type is "pbkdf2".
+	Type string
+
+	Hash       string
+	Iterations int
+	Salt       []byte
+	Digest     []byte
+
+	// Keyslots and Segments are the slots and segments the digest
+	// applies to.
+	Keyslots []int
+	Segments []int
+}
+
+// Header is a LUKS header.
+type Header struct {
+	// Version is 1 or 2.
+	Version int
+
+	UUID string
+
+	// Label and Subsystem are only set on LUKS2.
+	Label     string
+	Subsystem string
+
+	// Keyslots are the active key slots, in slot order.
+	Keyslots []Keyslot
+
+	Segments []Segment
+	Digests  []Digest
+}
+
+// ReadHeader reads the LUKS header at the start of r.
+func ReadHeader(r io.ReaderAt) (*Header, error) {
+	b := make([]byte, 8)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		if err == io.EOF {
+			return nil, ErrNotLUKS
+		}
+		return nil, err
+	}
+	if !bytes.Equal(b[:6], magic1) && !bytes.Equal(b[:6], magic2) {
+		// The primary LUKS2 header may be damaged, so look for the
+		// secondary one too.
+		h, err := readLUKS2(r)
+		if err == errNoLUKS2 {
+			return nil, ErrNotLUKS
+		}
+		return h, err
+	}
+	switch v := int(b[6])<<8 | int(b[7]); v {
+	case 1:
+		return readLUKS1(r)
+	case 2:
+		return readLUKS2(r)
+	default:
+		return nil, fmt.Errorf("unsupported LUKS version %d", v)
+	}
+}
+
+// Keyslot returns slot id.
+func (h *Header) Keyslot(id int) (*Keyslot, error) {
+	for i := range h.Keyslots {
+		if h.Keyslots[i].ID == id {
+			return &h.Keyslots[i], nil
+		}
+	}
+	return nil, fmt.Errorf("key slot %d is not active", id)
+}
+
+// Segment returns the segment that holds the data.
+func (h *Header) Segment() (*Segment, error) {
+	if len(h.Segments) != 1 {
+		return nil, fmt.Errorf("LUKS header has %d segments; only one is supported", len(h.Segments))
+	}
+	return &h.Segments[0], nil
+}
+
+// digest returns the digest for slot.
+func (h *Header) digest(slot int) (*Digest, error) {
+	for i, d := range h.Digests {
+		for _, s := range d.Keyslots {
+			if s == slot {
+				return &h.Digests[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("key slot %d has no digest", slot)
+}
+
+// VolumeKey returns the volume key and the slot that was unlocked with
+// passphrase. Slots are tried in order of priority, skipping those of
+// priority 0.
+func (h *Header) VolumeKey(r io.ReaderAt, passphrase []byte) ([]byte, int, error) {
+	slots := make([]*Keyslot, 0, len(h.Keyslots))
+	for i := range h.Keyslots {
+		if h.Keyslots[i].Priority > 0 {
+			slots = append(slots, &h.Keyslots[i])
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].Priority > slots[j].Priority
+	})
+	var errs []error
+	for _, s := range slots {
+		key, err := h.unlock(r, s, passphrase)
+		if err == nil {
+			return key, s.ID, nil
+		}
+		if err != ErrNoKey {
+			errs = append(errs, fmt.Errorf("key slot %d: %v", s.ID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, -1, fmt.Errorf("%v; %v", ErrNoKey, errs)
+	}
+	return nil, -1, ErrNoKey
+}
+
+// UnlockKeyslot returns the volume key from slot id.
+func (h *Header) UnlockKeyslot(r io.ReaderAt, id int, passphrase []byte) ([]byte, error) {
+	s, err := h.Keyslot(id)
+	if err != nil {
+		return nil, err
+	}
+	return h.unlock(r, s, passphrase)
+}
+
+// unlock returns the volume key in s, or ErrNoKey if passphrase does not
+// unlock it.
+func (h *Header) unlock(r io.ReaderAt, s *Keyslot, passphrase []byte) ([]byte, error) {
+	d, err := h.digest(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	if s.Stripes < 1 || s.KeySize < 1 {
+		return nil, fmt.Errorf("key slot has %d stripes of %d bytes", s.Stripes, s.KeySize)
+	}
+	afSize := int64(s.KeySize) * int64(s.Stripes)
+	encSize := (afSize + sectorSize - 1) / sectorSize * sectorSize
+	if encSize > s.Size {
+		return nil, fmt.Errorf("%d bytes of key material do not fit the %d byte area", encSize, s.Size)
+	}
+
+	areaKey, err := s.KDF.derive(passphrase, s.AreaKeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(areaKey)
+
+	material := make([]byte, encSize)
+	if _, err := r.ReadAt(material, s.Offset); err != nil {
+		return nil, fmt.Errorf("reading key material: %v", err)
+	}
+	defer wipe(material)
+	if err := decryptSectors(s.Encryption, areaKey, material); err != nil {
+		return nil, err
+	}
+	key, err := AFMerge(material[:afSize], s.KeySize, s.Stripes, s.AFHash)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := d.verify(key)
+	if err != nil || !ok {
+		wipe(key)
+		if err == nil {
+			err = ErrNoKey
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
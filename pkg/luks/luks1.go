@@ -0,0 +1,120 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	luks1NumKeys       = 8
+	luks1SaltSize      = 32
+	luks1DigestSize    = 20
+	luks1KeyActive     = 0x00ac71f3
+	luks1KeyDisabled   = 0x0000dead
+	luks1HeaderSize    = 592
+	luks1DefaultSector = sectorSize
+)
+
+// luks1Keyslot is a LUKS1 key slot, all big endian.
+type luks1Keyslot struct {
+	Active            uint32
+	Iterations        uint32
+	Salt              [luks1SaltSize]byte
+	KeyMaterialOffset uint32
+	Stripes           uint32
+}
+
+// luks1Header is the LUKS1 on-disk header, all big endian.
+type luks1Header struct {
+	Magic              [6]byte
+	Version            uint16
+	CipherName         [32]byte
+	CipherMode         [32]byte
+	HashSpec           [32]byte
+	PayloadOffset      uint32
+	KeyBytes           uint32
+	MKDigest           [luks1DigestSize]byte
+	MKDigestSalt       [luks1SaltSize]byte
+	MKDigestIterations uint32
+	UUID               [40]byte
+	Keyslots           [luks1NumKeys]luks1Keyslot
+}
+
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func readLUKS1(r io.ReaderAt) (*Header, error) {
+	b := make([]byte, luks1HeaderSize)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return nil, fmt.Errorf("reading LUKS1 header: %v", err)
+	}
+	var l luks1Header
+	if err := binary.Read(bytes.NewReader(b), binary.BigEndian, &l); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(l.Magic[:], magic1) || l.Version != 1 {
+		return nil, ErrNotLUKS
+	}
+
+	hash := cstring(l.HashSpec[:])
+	cipher := cstring(l.CipherName[:]) + "-" + cstring(l.CipherMode[:])
+	keySize := int(l.KeyBytes)
+	h := &Header{
+		Version: 1,
+		UUID:    cstring(l.UUID[:]),
+		Segments: []Segment{{
+			Offset:     int64(l.PayloadOffset) * sectorSize,
+			Size:       -1,
+			Encryption: cipher,
+			SectorSize: luks1DefaultSector,
+		}},
+	}
+	d := Digest{
+		Type:       "pbkdf2",
+		Hash:       hash,
+		Iterations: int(l.MKDigestIterations),
+		Salt:       append([]byte(nil), l.MKDigestSalt[:]...),
+		Digest:     append([]byte(nil), l.MKDigest[:]...),
+		Segments:   []int{0},
+	}
+	for i, k := range l.Keyslots {
+		switch k.Active {
+		case luks1KeyDisabled:
+			continue
+		case luks1KeyActive:
+		default:
+			return nil, fmt.Errorf("LUKS1 key slot %d has bad state %#x", i, k.Active)
+		}
+		afSize := int64(keySize) * int64(k.Stripes)
+		h.Keyslots = append(h.Keyslots, Keyslot{
+			ID:          i,
+			KeySize:     keySize,
+			Priority:    1,
+			Offset:      int64(k.KeyMaterialOffset) * sectorSize,
+			Size:        (afSize + sectorSize - 1) / sectorSize * sectorSize,
+			Encryption:  cipher,
+			AreaKeySize: keySize,
+			Stripes:     int(k.Stripes),
+			AFHash:      hash,
+			KDF: KDF{
+				Type:       "pbkdf2",
+				Hash:       hash,
+				Iterations: int(k.Iterations),
+				Salt:       append([]byte(nil), k.Salt[:]...),
+			},
+		})
+		d.Keyslots = append(d.Keyslots, i)
+	}
+	h.Digests = []Digest{d}
+	return h, nil
+}
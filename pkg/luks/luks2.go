@@ -0,0 +1,328 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+const (
+	luks2BinarySize = 4096
+	luks2MinHdrSize = 16 << 10
+	luks2MaxHdrSize = 4 << 20
+	luks2CsumOffset = 448
+	luks2CsumSize   = 64
+)
+
+// luks2Offsets are where the secondary header may be, if the primary one
+// does not say.
+var luks2Offsets = []int64{0x4000, 0x8000, 0x10000, 0x20000, 0x40000, 0x80000, 0x100000, 0x200000, 0x400000}
+
+var errNoLUKS2 = errors.New("no LUKS2 header")
+
+// luks2Binary is the binary header that precedes the JSON metadata, all
+// big endian.
+type luks2Binary struct {
+	Magic       [6]byte
+	Version     uint16
+	HdrSize     uint64
+	SeqID       uint64
+	Label       [48]byte
+	CsumAlg     [32]byte
+	Salt        [64]byte
+	UUID        [40]byte
+	Subsystem   [48]byte
+	HdrOffset   uint64
+	Padding     [184]byte
+	Csum        [luks2CsumSize]byte
+	Padding4096 [7 * 512]byte
+}
+
+type luks2Keyslot struct {
+	Type     string `json:"type"`
+	KeySize  int    `json:"key_size"`
+	Priority *int   `json:"priority"`
+	AF       struct {
+		Type    string `json:"type"`
+		Stripes int    `json:"stripes"`
+		Hash    string `json:"hash"`
+	} `json:"af"`
+	Area struct {
+		Type       string `json:"type"`
+		Offset     string `json:"offset"`
+		Size       string `json:"size"`
+		Encryption string `json:"encryption"`
+		KeySize    int    `json:"key_size"`
+	} `json:"area"`
+	KDF struct {
+		Type       string `json:"type"`
+		Hash       string `json:"hash"`
+		Iterations int    `json:"iterations"`
+		Time       int    `json:"time"`
+		Memory     int    `json:"memory"`
+		CPUs       int    `json:"cpus"`
+		Salt       string `json:"salt"`
+	} `json:"kdf"`
+}
+
+type luks2Segment struct {
+	Type       string `json:"type"`
+	Offset     string `json:"offset"`
+	Size       string `json:"size"`
+	IVTweak    string `json:"iv_tweak"`
+	Encryption string `json:"encryption"`
+	SectorSize int    `json:"sector_size"`
+}
+
+type luks2Digest struct {
+	Type       string   `json:"type"`
+	Keyslots   []string `json:"keyslots"`
+	Segments   []string `json:"segments"`
+	Hash       string   `json:"hash"`
+	Iterations int      `json:"iterations"`
+	Salt       string   `json:"salt"`
+	Digest     string   `json:"digest"`
+}
+
+type luks2Metadata struct {
+	Keyslots map[string]luks2Keyslot `json:"keyslots"`
+	Segments map[string]luks2Segment `json:"segments"`
+	Digests  map[string]luks2Digest  `json:"digests"`
+}
+
+// luks2Copy is one of the two header copies.
+type luks2Copy struct {
+	bin  luks2Binary
+	json []byte
+}
+
+// readLUKS2Copy reads the header copy at off. It returns errNoLUKS2 if
+// there is none.
+func readLUKS2Copy(r io.ReaderAt, off int64, magic []byte) (*luks2Copy, error) {
+	b := make([]byte, luks2BinarySize)
+	if _, err := r.ReadAt(b, off); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errNoLUKS2
+		}
+		return nil, err
+	}
+	var c luks2Copy
+	if err := binary.Read(bytes.NewReader(b), binary.BigEndian, &c.bin); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(c.bin.Magic[:], magic) || c.bin.Version != 2 {
+		return nil, errNoLUKS2
+	}
+	size := c.bin.HdrSize
+	if size < luks2MinHdrSize || size > luks2MaxHdrSize || size%4096 != 0 {
+		return nil, fmt.Errorf("LUKS2 header at %d has bad size %d", off, size)
+	}
+	if c.bin.HdrOffset != uint64(off) {
+		return nil, fmt.Errorf("LUKS2 header at %d says it is at %d", off, c.bin.HdrOffset)
+	}
+	hdr := make([]byte, size)
+	copy(hdr, b)
+	if _, err := r.ReadAt(hdr[luks2BinarySize:], off+luks2BinarySize); err != nil {
+		return nil, fmt.Errorf("reading LUKS2 metadata at %d: %v", off, err)
+	}
+
+	// The checksum covers the header and metadata, with the checksum
+	// zeroed.
+	newHash, err := hashFunc(cstring(c.bin.CsumAlg[:]))
+	if err != nil {
+		return nil, fmt.Errorf("LUKS2 header at %d: %v", off, err)
+	}
+	wipe(hdr[luks2CsumOffset : luks2CsumOffset+luks2CsumSize])
+	h := newHash()
+	h.Write(hdr)
+	sum := h.Sum(nil)
+	if subtle.ConstantTimeCompare(sum, c.bin.Csum[:len(sum)]) != 1 {
+		return nil, fmt.Errorf("LUKS2 header at %d has a bad checksum", off)
+	}
+	c.json = hdr[luks2BinarySize:]
+	if i := bytes.IndexByte(c.json, 0); i >= 0 {
+		c.json = c.json[:i]
+	}
+	return &c, nil
+}
+
+// readLUKS2 reads the newer of the valid LUKS2 header copies.
+func readLUKS2(r io.ReaderAt) (*Header, error) {
+	primary, perr := readLUKS2Copy(r, 0, magic1)
+	offsets := luks2Offsets
+	if perr == nil {
+		offsets = []int64{int64(primary.bin.HdrSize)}
+	}
+	var secondary *luks2Copy
+	serr := errNoLUKS2
+	for _, off := range offsets {
+		if secondary, serr = readLUKS2Copy(r, off, magic2); serr != errNoLUKS2 {
+			break
+		}
+	}
+
+	c := primary
+	switch {
+	case perr != nil && serr != nil:
+		switch {
+		case perr == errNoLUKS2:
+			return nil, serr
+		case serr == errNoLUKS2:
+			return nil, perr
+		}
+		return nil, fmt.Errorf("%v; %v", perr, serr)
+	case perr != nil:
+		c = secondary
+	case serr == nil && secondary.bin.SeqID > primary.bin.SeqID:
+		c = secondary
+	}
+	return c.header()
+}
+
+func parseInt(s, what string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("LUKS2 %s %q is not a number", what, s)
+	}
+	return n, nil
+}
+
+func parseIDs(ids []string) ([]int, error) {
+	var n []int
+	for _, s := range ids {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("LUKS2 reference %q is not a number", s)
+		}
+		n = append(n, id)
+	}
+	return n, nil
+}
+
+func decodeBase64(s, what string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("LUKS2 %s: %v", what, err)
+	}
+	return b, nil
+}
+
+func (c *luks2Copy) header() (*Header, error) {
+	var m luks2Metadata
+	if err := json.Unmarshal(c.json, &m); err != nil {
+		return nil, fmt.Errorf("LUKS2 metadata: %v", err)
+	}
+	h := &Header{
+		Version:   2,
+		UUID:      cstring(c.bin.UUID[:]),
+		Label:     cstring(c.bin.Label[:]),
+		Subsystem: cstring(c.bin.Subsystem[:]),
+	}
+
+	for id, k := range m.Keyslots {
+		// Other key slot types, e.g. reencryption state, hold no keys.
+		if k.Type != "luks2" {
+			continue
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("LUKS2 key slot %q", id)
+		}
+		if k.AF.Type != "luks1" || k.Area.Type != "raw" {
+			return nil, fmt.Errorf("LUKS2 key slot %d has unsupported %s splitter and %s area", n, k.AF.Type, k.Area.Type)
+		}
+		s := Keyslot{
+			ID:          n,
+			KeySize:     k.KeySize,
+			Priority:    1,
+			Encryption:  k.Area.Encryption,
+			AreaKeySize: k.Area.KeySize,
+			Stripes:     k.AF.Stripes,
+			AFHash:      k.AF.Hash,
+			KDF: KDF{
+				Type:       k.KDF.Type,
+				Hash:       k.KDF.Hash,
+				Iterations: k.KDF.Iterations,
+				Time:       k.KDF.Time,
+				Memory:     k.KDF.Memory,
+				CPUs:       k.KDF.CPUs,
+			},
+		}
+		if k.Priority != nil {
+			s.Priority = *k.Priority
+		}
+		if s.Offset, err = parseInt(k.Area.Offset, "key slot offset"); err != nil {
+			return nil, err
+		}
+		if s.Size, err = parseInt(k.Area.Size, "key slot size"); err != nil {
+			return nil, err
+		}
+		if s.KDF.Salt, err = decodeBase64(k.KDF.Salt, "key slot salt"); err != nil {
+			return nil, err
+		}
+		h.Keyslots = append(h.Keyslots, s)
+	}
+	sort.Slice(h.Keyslots, func(i, j int) bool { return h.Keyslots[i].ID < h.Keyslots[j].ID })
+
+	for id, seg := range m.Segments {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("LUKS2 segment %q", id)
+		}
+		if seg.Type != "crypt" {
+			return nil, fmt.Errorf("LUKS2 segment %d has unsupported type %q", n, seg.Type)
+		}
+		s := Segment{ID: n, Size: -1, Encryption: seg.Encryption, SectorSize: seg.SectorSize}
+		if s.Offset, err = parseInt(seg.Offset, "segment offset"); err != nil {
+			return nil, err
+		}
+		if seg.Size != "dynamic" {
+			if s.Size, err = parseInt(seg.Size, "segment size"); err != nil {
+				return nil, err
+			}
+		}
+		tweak, err := parseInt(seg.IVTweak, "IV tweak")
+		if err != nil {
+			return nil, err
+		}
+		s.IVTweak = uint64(tweak)
+		h.Segments = append(h.Segments, s)
+	}
+	sort.Slice(h.Segments, func(i, j int) bool { return h.Segments[i].ID < h.Segments[j].ID })
+
+	ids := make([]string, 0, len(m.Digests))
+	for id := range m.Digests {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		d := m.Digests[id]
+		digest := Digest{Type: d.Type, Hash: d.Hash, Iterations: d.Iterations}
+		var err error
+		if digest.Keyslots, err = parseIDs(d.Keyslots); err != nil {
+			return nil, err
+		}
+		if digest.Segments, err = parseIDs(d.Segments); err != nil {
+			return nil, err
+		}
+		if digest.Salt, err = decodeBase64(d.Salt, "digest salt"); err != nil {
+			return nil, err
+		}
+		if digest.Digest, err = decodeBase64(d.Digest, "digest"); err != nil {
+			return nil, err
+		}
+		h.Digests = append(h.Digests, digest)
+	}
+	return h, nil
+}
@@ -0,0 +1,307 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package luks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testUUID = "2183ead8-a510-4b3d-9777-19c7090f66d9"
+
+// testKey is the volume key of the images in testdata.
+var testKey = sha256.Sum256([]byte("u-root LUKS volume key"))
+
+func readImage(t *testing.T, name string) *bytes.Reader {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(b)
+}
+
+// summary is the part of a header the tests compare.
+type summary struct {
+	version   int
+	uuid      string
+	label     string
+	slots     []string
+	segment   Segment
+	digestFor []int
+}
+
+func summarize(h *Header) summary {
+	s := summary{version: h.Version, uuid: h.UUID, label: h.Label}
+	for _, k := range h.Keyslots {
+		kdf := k.KDF.Type
+		if k.KDF.Hash != "" {
+			kdf += "-" + k.KDF.Hash
+		}
+		s.slots = append(s.slots, fmt.Sprintf("%d:%s:%d:%s:%d@%d", k.ID, k.Encryption, k.KeySize, kdf, k.Priority, k.Offset))
+	}
+	if len(h.Segments) > 0 {
+		s.segment = h.Segments[0]
+	}
+	for _, d := range h.Digests {
+		s.digestFor = append(s.digestFor, d.Keyslots...)
+	}
+	return s
+}
+
+func TestReadHeader(t *testing.T) {
+	for _, tt := range []struct {
+		image string
+		want  summary
+	}{
+		{
+			image: "luks1.img",
+			want: summary{
+				version:   1,
+				uuid:      testUUID,
+				slots:     []string{"0:aes-xts-plain64:32:pbkdf2-sha256:1@4096"},
+				segment:   Segment{Offset: 4096 * 512, Size: -1, Encryption: "aes-xts-plain64", SectorSize: 512},
+				digestFor: []int{0},
+			},
+		},
+		{
+			image: "luks1-essiv.img",
+			want: summary{
+				version: 1,
+				uuid:    testUUID,
+				slots: []string{
+					"0:aes-cbc-essiv:sha256:16:pbkdf2-sha1:1@4096",
+					"2:aes-cbc-essiv:sha256:16:pbkdf2-sha1:1@135168",
+				},
+				segment:   Segment{Offset: 2048 * 512, Size: -1, Encryption: "aes-cbc-essiv:sha256", SectorSize: 512},
+				digestFor: []int{0, 2},
+			},
+		},
+		{
+			image: "luks2.img",
+			want: summary{
+				version: 2,
+				uuid:    testUUID,
+				label:   "test-label",
+				slots: []string{
+					"0:aes-xts-plain64:32:argon2id:1@32768",
+					"1:aes-xts-plain64:32:pbkdf2-sha256:2@163840",
+				},
+				segment:   Segment{Offset: 1 << 20, Size: -1, Encryption: "aes-xts-plain64", SectorSize: 4096},
+				digestFor: []int{0, 1},
+			},
+		},
+		{
+			// The primary header is newer, but damaged.
+			image: "luks2-damaged.img",
+			want: summary{
+				version:   2,
+				uuid:      testUUID,
+				label:     "test-label",
+				slots:     []string{"0:aes-xts-plain64:32:pbkdf2-sha256:1@32768"},
+				segment:   Segment{Offset: 1 << 20, Size: -1, Encryption: "aes-xts-plain64", SectorSize: 4096},
+				digestFor: []int{0},
+			},
+		},
+	} {
+		t.Run(tt.image, func(t *testing.T) {
+			h, err := ReadHeader(readImage(t, tt.image))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summarize(h); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadHeader = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolumeKey(t *testing.T) {
+	for _, tt := range []struct {
+		image      string
+		passphrase string
+		slot       int
+		keySize    int
+	}{
+		{"luks1.img", "u-root", 0, 32},
+		{"luks1-essiv.img", "u-root", 0, 16},
+		{"luks1-essiv.img", "second", 2, 16},
+		{"luks2.img", "u-root", 0, 32},
+		{"luks2.img", "second", 1, 32},
+		{"luks2-damaged.img", "u-root", 0, 32},
+	} {
+		t.Run(tt.image+"/"+tt.passphrase, func(t *testing.T) {
+			r := readImage(t, tt.image)
+			h, err := ReadHeader(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, slot, err := h.VolumeKey(r, []byte(tt.passphrase))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if slot != tt.slot || !bytes.Equal(key, testKey[:tt.keySize]) {
+				t.Errorf("VolumeKey = %x in slot %d, want %x in slot %d", key, slot, testKey[:tt.keySize], tt.slot)
+			}
+			if _, _, err := h.VolumeKey(r, []byte("wrong")); err != ErrNoKey {
+				t.Errorf("VolumeKey with the wrong passphrase = %v, want %v", err, ErrNoKey)
+			}
+		})
+	}
+}
+
+func TestKeyslotPriority(t *testing.T) {
+	r := readImage(t, "luks2.img")
+	h, err := ReadHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Keyslots[1].Priority = 0
+	if _, _, err := h.VolumeKey(r, []byte("second")); err != ErrNoKey {
+		t.Errorf("VolumeKey tried an ignored slot: %v", err)
+	}
+	key, err := h.UnlockKeyslot(r, 1, []byte("second"))
+	if err != nil || !bytes.Equal(key, testKey[:]) {
+		t.Errorf("UnlockKeyslot = %x, %v, want %x", key, err, testKey)
+	}
+	if _, err := h.UnlockKeyslot(r, 0, []byte("second")); err != ErrNoKey {
+		t.Errorf("UnlockKeyslot with the wrong passphrase = %v, want %v", err, ErrNoKey)
+	}
+	if _, err := h.UnlockKeyslot(r, 5, []byte("second")); err == nil {
+		t.Errorf("UnlockKeyslot of an inactive slot succeeded")
+	}
+}
+
+func TestReadHeaderErrors(t *testing.T) {
+	luks2 := readImage(t, "luks2.img")
+	image := func(f func(b []byte)) *bytes.Reader {
+		b := make([]byte, luks2.Size())
+		luks2.ReadAt(b, 0)
+		f(b)
+		return bytes.NewReader(b)
+	}
+	for _, tt := range []struct {
+		name string
+		r    *bytes.Reader
+		err  string
+	}{
+		{"empty", bytes.NewReader(nil), ErrNotLUKS.Error()},
+		{"zeros", bytes.NewReader(make([]byte, 1<<20)), ErrNotLUKS.Error()},
+		{"version", image(func(b []byte) { b[7] = 3 }), "unsupported LUKS version 3"},
+		{"both checksums", image(func(b []byte) { b[5000]++; b[16384+5000]++ }), "bad checksum"},
+		{"checksum algorithm", image(func(b []byte) { copy(b[72:], "md5\x00"); copy(b[16384+72:], "md5\x00") }), "unsupported hash"},
+		{"offset", image(func(b []byte) { b[16384+263] = 1; b[5000]++ }), "says it is at"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadHeader(tt.r)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ReadHeader = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+
+	// A damaged primary header is skipped even if its magic is gone.
+	r := image(func(b []byte) { copy(b, "\x00\x00\x00\x00") })
+	if h, err := ReadHeader(r); err != nil || h.Label != "test-label" {
+		t.Errorf("ReadHeader without a primary header = %v", err)
+	}
+}
+
+func TestAF(t *testing.T) {
+	for _, hash := range []string{"sha1", "sha256", "sha512"} {
+		for _, keySize := range []int{16, 32, 64} {
+			for _, stripes := range []int{1, 2, 4000} {
+				key := make([]byte, keySize)
+				rand.Read(key)
+				m, err := AFSplit(key, stripes, hash, rand.Reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := AFMerge(m, keySize, stripes, hash)
+				if err != nil || !bytes.Equal(got, key) {
+					t.Errorf("AFMerge(AFSplit(%x, %d, %s)) = %x, %v", key, stripes, hash, got, err)
+				}
+			}
+		}
+	}
+	if _, err := AFMerge(make([]byte, 10), 4, 2, "sha1"); err == nil {
+		t.Errorf("AFMerge of short material succeeded")
+	}
+	if _, err := AFSplit(make([]byte, 4), 2, "md5", rand.Reader); err == nil {
+		t.Errorf("AFSplit with an unknown hash succeeded")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	key := make([]byte, 32)
+	for _, spec := range []string{"twofish-xts-plain64", "aes", "aes-xts-essiv:sha256", "aes-cbc-lmk", "aes-cbc-essiv:md5", "aes-ecb-plain", "aes-ctr-plain64"} {
+		if err := decryptSectors(spec, key, make([]byte, 512)); err == nil {
+			t.Errorf("decryptSectors(%q) succeeded", spec)
+		}
+	}
+	if err := decryptSectors("aes-xts-plain64", key, make([]byte, 100)); err == nil {
+		t.Errorf("decrypting a partial sector succeeded")
+	}
+	if err := decryptSectors("aes-xts-plain64", key[:20], make([]byte, 512)); err == nil {
+		t.Errorf("decrypting with a bad key size succeeded")
+	}
+}
+
+func TestKDFErrors(t *testing.T) {
+	for _, k := range []KDF{
+		{Type: "scrypt", Salt: []byte{1}},
+		{Type: "pbkdf2", Hash: "sha256"},
+		{Type: "pbkdf2", Hash: "whirlpool", Iterations: 1, Salt: []byte{1}},
+		{Type: "pbkdf2", Hash: "sha256", Iterations: 0, Salt: []byte{1}},
+		{Type: "argon2id", Time: 1, Memory: 4, CPUs: 1, Salt: []byte{1}},
+		{Type: "argon2i", Time: 0, Memory: 64, CPUs: 1, Salt: []byte{1}},
+	} {
+		if _, err := k.derive([]byte("x"), 32); err == nil {
+			t.Errorf("%+v.derive succeeded", k)
+		}
+	}
+}
+
+func TestCryptTarget(t *testing.T) {
+	key := testKey[:]
+	hexKey := hex.EncodeToString(key)
+	for _, tt := range []struct {
+		image  string
+		size   int64
+		length uint64
+		params string
+	}{
+		{"luks1.img", 4 << 20, 8192 - 4096, "aes-xts-plain64 " + hexKey + " 0 /dev/sda2 4096"},
+		// The length is rounded down to the 4 KiB sector size.
+		{"luks2.img", 2<<20 + 1000, (2<<20 - 1<<20) / 512, "aes-xts-plain64 " + hexKey + " 0 /dev/sda2 2048 2 sector_size:4096 iv_large_sectors"},
+	} {
+		h, err := ReadHeader(readImage(t, tt.image))
+		if err != nil {
+			t.Fatal(err)
+		}
+		target, err := h.CryptTarget("/dev/sda2", tt.size, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target.Start != 0 || target.Length != tt.length || target.Type != "crypt" || target.Params != tt.params {
+			t.Errorf("%s: CryptTarget = %v, want 0 %d crypt %s", tt.image, target, tt.length, tt.params)
+		}
+		if _, err := h.CryptTarget("/dev/sda2", 4096, key); err == nil {
+			t.Errorf("%s: CryptTarget for a device smaller than the header succeeded", tt.image)
+		}
+		wantUUID := fmt.Sprintf("CRYPT-LUKS%d-2183ead8a5104b3d977719c7090f66d9-data", h.Version)
+		if got := h.DMUUID("data"); got != wantUUID {
+			t.Errorf("DMUUID = %q, want %q", got, wantUUID)
+		}
+	}
+}
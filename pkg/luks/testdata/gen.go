@@ -0,0 +1,236 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// gen writes the test headers with libcryptsetup, the library behind
+// cryptsetup luksFormat and luksAddKey, with small KDF costs so that the
+// tests run quickly. The images end after the last used key slot; the
+// encrypted data is not needed.
+//
+// luks2-damaged.img holds a primary header one update newer than the
+// secondary, with a byte of its JSON area flipped.
+//
+// Run it from this directory with "go run gen.go". It needs
+// libcryptsetup.so.12 but not its headers.
+package main
+
+/*
+#cgo LDFLAGS: -l:libcryptsetup.so.12
+#include <stdint.h>
+#include <stdlib.h>
+
+struct crypt_device;
+
+struct crypt_pbkdf_type {
+	const char *type;
+	const char *hash;
+	uint32_t time_ms;
+	uint32_t iterations;
+	uint32_t max_memory_kb;
+	uint32_t parallel_threads;
+	uint32_t flags;
+};
+
+struct crypt_params_luks1 {
+	const char *hash;
+	size_t data_alignment;
+	const char *data_device;
+};
+
+struct crypt_params_luks2 {
+	const struct crypt_pbkdf_type *pbkdf;
+	const char *integrity;
+	const void *integrity_params;
+	size_t data_alignment;
+	const char *data_device;
+	uint32_t sector_size;
+	const char *label;
+	const char *subsystem;
+};
+
+int crypt_init(struct crypt_device **cd, const char *device);
+void crypt_free(struct crypt_device *cd);
+int crypt_set_pbkdf_type(struct crypt_device *cd, const struct crypt_pbkdf_type *pbkdf);
+int crypt_set_metadata_size(struct crypt_device *cd, uint64_t metadata_size, uint64_t keyslots_size);
+int crypt_format(struct crypt_device *cd, const char *type, const char *cipher, const char *cipher_mode,
+	const char *uuid, const char *volume_key, size_t volume_key_size, void *params);
+int crypt_keyslot_add_by_volume_key(struct crypt_device *cd, int keyslot, const char *volume_key,
+	size_t volume_key_size, const char *passphrase, size_t passphrase_size);
+int crypt_keyslot_set_priority(struct crypt_device *cd, int keyslot, int priority);
+int crypt_set_label(struct crypt_device *cd, const char *label, const char *subsystem);
+*/
+import "C"
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"log"
+	"os"
+	"unsafe"
+)
+
+const (
+	uuid = "2183ead8-a510-4b3d-9777-19c7090f66d9"
+
+	// CRYPT_PBKDF_NO_BENCHMARK and CRYPT_SLOT_PRIORITY_PREFER.
+	noBenchmark  = 1 << 1
+	priorityHigh = 2
+
+	// luks2Slot is the size of a key slot area for a 32 byte key.
+	luks2Slot = 128 << 10
+)
+
+// The volume keys the tests expect.
+var (
+	key32 = sha256.Sum256([]byte("u-root LUKS volume key"))
+	key16 = key32[:16]
+)
+
+type device struct {
+	name string
+	cd   *C.struct_crypt_device
+}
+
+func check(what string, r C.int) {
+	if r < 0 {
+		log.Fatalf("%s: %d", what, r)
+	}
+}
+
+// newDevice opens a fresh 4 MiB image.
+func newDevice(name string) *device {
+	if err := ioutil.WriteFile(name, make([]byte, 4<<20), 0644); err != nil {
+		log.Fatal(err)
+	}
+	d := &device{name: name}
+	n := C.CString(name)
+	defer C.free(unsafe.Pointer(n))
+	check("crypt_init", C.crypt_init(&d.cd, n))
+	return d
+}
+
+// pbkdf sets the KDF of the key slots added next.
+func (d *device) pbkdf(typ, hash string, iterations, memory uint32) {
+	p := C.struct_crypt_pbkdf_type{
+		_type:      C.CString(typ),
+		iterations: C.uint32_t(iterations),
+		flags:      noBenchmark,
+	}
+	defer C.free(unsafe.Pointer(p._type))
+	if hash != "" {
+		p.hash = C.CString(hash)
+		defer C.free(unsafe.Pointer(p.hash))
+	}
+	if memory != 0 {
+		p.max_memory_kb = C.uint32_t(memory)
+		p.parallel_threads = 1
+	}
+	check("crypt_set_pbkdf_type", C.crypt_set_pbkdf_type(d.cd, &p))
+}
+
+func (d *device) format(typ, cipher, mode string, key []byte, params unsafe.Pointer) {
+	cs := []*C.char{C.CString(typ), C.CString(cipher), C.CString(mode), C.CString(uuid)}
+	for _, s := range cs {
+		defer C.free(unsafe.Pointer(s))
+	}
+	k := C.CBytes(key)
+	defer C.free(k)
+	check("crypt_format", C.crypt_format(d.cd, cs[0], cs[1], cs[2], cs[3], (*C.char)(k), C.size_t(len(key)), params))
+}
+
+func (d *device) addKey(slot int, key []byte, pass string) {
+	k := C.CBytes(key)
+	defer C.free(k)
+	p := C.CString(pass)
+	defer C.free(unsafe.Pointer(p))
+	check("crypt_keyslot_add_by_volume_key", C.crypt_keyslot_add_by_volume_key(d.cd, C.int(slot), (*C.char)(k), C.size_t(len(key)), p, C.size_t(len(pass))))
+}
+
+func (d *device) label(l string) {
+	s := C.CString(l)
+	defer C.free(unsafe.Pointer(s))
+	check("crypt_set_label", C.crypt_set_label(d.cd, s, nil))
+}
+
+// image returns the first n bytes of the image.
+func (d *device) image(n int) []byte {
+	b, err := ioutil.ReadFile(d.name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return b[:n]
+}
+
+func (d *device) close() {
+	C.crypt_free(d.cd)
+	os.Remove(d.name)
+}
+
+func write(name string, b []byte) {
+	if err := ioutil.WriteFile(name, b, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func luks1(name, mode, hash string, key []byte, passes []string) {
+	d := newDevice(name + ".tmp")
+	defer d.close()
+	h := C.CString(hash)
+	defer C.free(unsafe.Pointer(h))
+	d.pbkdf("pbkdf2", hash, 1000, 0)
+	d.format("LUKS1", "aes", mode, key, unsafe.Pointer(&C.struct_crypt_params_luks1{hash: h}))
+	// Key slots are 4000 stripes of the key, in 4 KiB aligned areas
+	// after the 4 KiB header.
+	slot := (len(key)*4000 + 4095) &^ 4095
+	var end int
+	for i, p := range passes {
+		if p == "" {
+			continue
+		}
+		d.pbkdf("pbkdf2", hash, uint32(1000*(i+1)), 0)
+		d.addKey(i, key, p)
+		end = 4096 + (i+1)*slot
+	}
+	write(name, d.image(end))
+}
+
+// luks2 formats a LUKS2 header with room for slots key slots and a 4 KiB
+// sector size.
+func luks2(name string, slots int) *device {
+	d := newDevice(name + ".tmp")
+	check("crypt_set_metadata_size", C.crypt_set_metadata_size(d.cd, 16<<10, C.uint64_t(slots*luks2Slot)))
+	l := C.CString("test-label")
+	defer C.free(unsafe.Pointer(l))
+	d.pbkdf("pbkdf2", "sha256", 1000, 0)
+	d.format("LUKS2", "aes", "xts-plain64", key32[:], unsafe.Pointer(&C.struct_crypt_params_luks2{
+		sector_size: 4096,
+		label:       l,
+	}))
+	return d
+}
+
+func main() {
+	luks1("luks1.img", "xts-plain64", "sha256", key32[:], []string{"u-root"})
+	luks1("luks1-essiv.img", "cbc-essiv:sha256", "sha1", key16, []string{"u-root", "", "second"})
+
+	d := luks2("luks2.img", 2)
+	d.pbkdf("argon2id", "", 4, 32)
+	d.addKey(0, key32[:], "u-root")
+	d.pbkdf("pbkdf2", "sha256", 1000, 0)
+	d.addKey(1, key32[:], "second")
+	check("crypt_keyslot_set_priority", C.crypt_keyslot_set_priority(d.cd, 1, priorityHigh))
+	write("luks2.img", d.image(32<<10+2*luks2Slot))
+	d.close()
+
+	d = luks2("luks2-damaged.img", 1)
+	d.addKey(0, key32[:], "u-root")
+	older := d.image(32<<10 + luks2Slot)
+	d.label("newer-label")
+	b := d.image(32<<10 + luks2Slot)
+	d.close()
+	copy(b[16<<10:32<<10], older[16<<10:32<<10])
+	b[4096+100] ^= 1
+	write("luks2-damaged.img", b)
+}
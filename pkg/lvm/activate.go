@@ -0,0 +1,63 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/dm"
+)
+
+// DMName returns the device-mapper name LVM2 gives lv of vg, e.g.
+// "vg0-root". Dashes in either name are doubled.
+func DMName(vg *VolumeGroup, lv *LogicalVolume) string {
+	return strings.Replace(vg.Name, "-", "--", -1) + "-" + strings.Replace(lv.Name, "-", "--", -1)
+}
+
+// DMUUID returns the device-mapper UUID LVM2 gives lv of vg.
+func DMUUID(vg *VolumeGroup, lv *LogicalVolume) string {
+	return "LVM-" + rawUUID(vg.UUID) + rawUUID(lv.UUID)
+}
+
+// Targets returns the device-mapper table of lv. Only linear and striped
+// segments, and error and zero ones, are supported. The devices of the
+// PVs lv uses must have been found.
+func (vg *VolumeGroup) Targets(lv *LogicalVolume) ([]dm.Target, error) {
+	var targets []dm.Target
+	for i, s := range lv.Segments {
+		start, length := s.StartExtent*vg.ExtentSize, s.ExtentCount*vg.ExtentSize
+		var t dm.Target
+		var err error
+		switch s.Type {
+		case "error", "zero":
+			t = dm.Target{Start: start, Length: length, Type: s.Type}
+		case "striped":
+			var stripes []dm.Stripe
+			for _, a := range s.Stripes {
+				pv := vg.PV(a.PV)
+				if pv == nil || pv.Device == "" {
+					return nil, fmt.Errorf("%s/%s: physical volume %s is missing", vg.Name, lv.Name, a.PV)
+				}
+				stripes = append(stripes, dm.Stripe{Device: pv.Device, Offset: pv.PEStart + a.Extent*vg.ExtentSize})
+			}
+			if len(stripes) == 1 {
+				t, err = (&dm.Linear{Device: stripes[0].Device, Offset: stripes[0].Offset}).Target(start, length)
+			} else {
+				t, err = (&dm.Striped{Chunk: s.StripeSize, Stripes: stripes}).Target(start, length)
+			}
+		default:
+			return nil, fmt.Errorf("%s/%s: %w %q", vg.Name, lv.Name, ErrUnsupported, s.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s segment %d: %v", vg.Name, lv.Name, i+1, err)
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s/%s has no segments", vg.Name, lv.Name)
+	}
+	return targets, nil
+}
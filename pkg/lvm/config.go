@@ -0,0 +1,267 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// section is a section of LVM2's text metadata format:
+//
+//	name {
+//	    key = "string"
+//	    key = 42
+//	    key = ["a", 1]
+//	    child { ... }
+//	}
+//
+// Values are strings, int64s or []interface{} of those.
+type section struct {
+	name     string
+	values   map[string]interface{}
+	children []*section
+}
+
+func (s *section) child(name string) *section {
+	for _, c := range s.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *section) str(key string) (string, error) {
+	v, ok := s.values[key].(string)
+	if !ok {
+		return "", fmt.Errorf("%s: %s is not a string", s.name, key)
+	}
+	return v, nil
+}
+
+func (s *section) int(key string) (int64, error) {
+	v, ok := s.values[key].(int64)
+	if !ok {
+		return 0, fmt.Errorf("%s: %s is not a number", s.name, key)
+	}
+	return v, nil
+}
+
+func (s *section) uint(key string) (uint64, error) {
+	v, err := s.int(key)
+	if err == nil && v < 0 {
+		return 0, fmt.Errorf("%s: %s is negative", s.name, key)
+	}
+	return uint64(v), err
+}
+
+// strings returns a list of strings, or nil if key is not set.
+func (s *section) strings(key string) ([]string, error) {
+	v, ok := s.values[key]
+	if !ok {
+		return nil, nil
+	}
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: %s is not a list", s.name, key)
+	}
+	var strs []string
+	for _, e := range l {
+		str, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is not a list of strings", s.name, key)
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
+
+// parser parses the text metadata format.
+type parser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("metadata line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skip skips white space and comments.
+func (p *parser) skip() {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_.+-", c) >= 0
+}
+
+func (p *parser) name() (string, error) {
+	p.skip()
+	start := p.pos
+	for p.pos < len(p.s) && isNameByte(p.s[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos == len(p.s) {
+			return "", p.errorf("unexpected end")
+		}
+		return "", p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return p.s[start:p.pos], nil
+}
+
+// expect consumes c, which must be the next byte.
+func (p *parser) expect(c byte) error {
+	p.skip()
+	if p.pos >= len(p.s) || p.s[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// peek returns the next byte, or 0 at the end.
+func (p *parser) peek() byte {
+	p.skip()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// body parses the contents of s up to end, which is '}' or 0 for the end
+// of the text.
+func (p *parser) body(s *section, end byte) error {
+	for {
+		if p.peek() == end {
+			if end != 0 {
+				p.pos++
+			}
+			return nil
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		switch p.peek() {
+		case '{':
+			p.pos++
+			c := &section{name: name, values: map[string]interface{}{}}
+			if err := p.body(c, '}'); err != nil {
+				return err
+			}
+			s.children = append(s.children, c)
+		case '=':
+			p.pos++
+			v, err := p.value(true)
+			if err != nil {
+				return err
+			}
+			s.values[name] = v
+		default:
+			return p.errorf("expected { or = after %s", name)
+		}
+	}
+}
+
+// value parses a string, number, or a list if list is true.
+func (p *parser) value(list bool) (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		return p.quoted()
+	case c == '[' && list:
+		p.pos++
+		l := []interface{}{}
+		if p.peek() == ']' {
+			p.pos++
+			return l, nil
+		}
+		for {
+			v, err := p.value(false)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			switch p.peek() {
+			case ',':
+				p.pos++
+			case ']':
+				p.pos++
+				return l, nil
+			default:
+				return nil, p.errorf("expected , or ] in list")
+			}
+		}
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+			p.pos++
+		}
+		tok := p.s[start:p.pos]
+		if strings.IndexByte(tok, '.') >= 0 {
+			// LVM2 never writes floats that matter here; keep them as
+			// strings.
+			return tok, nil
+		}
+		n, err := strconv.ParseInt(tok, 10, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %q", tok)
+		}
+		return n, nil
+	default:
+		return nil, p.errorf("expected a value")
+	}
+}
+
+func (p *parser) quoted() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.pos == len(p.s) {
+				break
+			}
+			c = p.s[p.pos]
+			p.pos++
+		case '\n':
+			p.line++
+		}
+		b.WriteByte(c)
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseConfig parses text metadata into a section named "".
+func parseConfig(text string) (*section, error) {
+	p := &parser{s: text, line: 1}
+	root := &section{values: map[string]interface{}{}}
+	if err := p.body(root, 0); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
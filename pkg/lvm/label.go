@@ -0,0 +1,193 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// See lib/format_text/layout.h in LVM2. Everything is little-endian.
+const (
+	sectorSize = 512
+	labelScan  = 4
+	labelID    = "LABELONE"
+	labelType  = "LVM2 001"
+	uuidLen    = 32
+
+	mdaHeaderSize = 512
+	mdaMagic      = "\x20LVM2\x20x[5A%r0N*>"
+	mdaVersion    = 1
+
+	// rawLocnIgnored marks a metadata area that is not used.
+	rawLocnIgnored = 1
+
+	// crcInit is the initial value of LVM2's CRC.
+	crcInit = 0xf597a6cf
+
+	// maxMetadata bounds the text metadata we read.
+	maxMetadata = 64 << 20
+)
+
+// crc is LVM2's calc_crc: a CRC-32 with an initial value and no final
+// inversion.
+func crc(p []byte) uint32 {
+	return ^crc32.Update(^uint32(crcInit), crc32.IEEETable, p)
+}
+
+// DiskArea is a region of a PV in bytes.
+type DiskArea struct {
+	Offset uint64
+	Size   uint64
+}
+
+// Label is the label of a PV.
+type Label struct {
+	// UUID is the PV's UUID.
+	UUID string
+
+	// DeviceSize is the size of the PV in bytes.
+	DeviceSize uint64
+
+	// DataAreas hold the extents, and MetadataAreas the VG metadata.
+	DataAreas     []DiskArea
+	MetadataAreas []DiskArea
+}
+
+// ReadLabel reads the label of the PV r.
+func ReadLabel(r io.ReaderAt) (*Label, error) {
+	sector := make([]byte, sectorSize)
+	for s := int64(0); s < labelScan; s++ {
+		if _, err := r.ReadAt(sector, s*sectorSize); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, ErrNotPV
+			}
+			return nil, err
+		}
+		if string(sector[:8]) != labelID || binary.LittleEndian.Uint64(sector[8:]) != uint64(s) {
+			continue
+		}
+		if string(sector[24:32]) != labelType {
+			return nil, fmt.Errorf("unsupported LVM label type %q", sector[24:32])
+		}
+		if want, got := binary.LittleEndian.Uint32(sector[16:]), crc(sector[20:]); got != want {
+			return nil, fmt.Errorf("LVM label checksum is %#x, want %#x", got, want)
+		}
+		return parseLabel(sector, int(binary.LittleEndian.Uint32(sector[20:])))
+	}
+	return nil, ErrNotPV
+}
+
+// parseLabel parses the pv_header at off in the label sector.
+func parseLabel(sector []byte, off int) (*Label, error) {
+	if off < 32 || off+uuidLen+8 > len(sector) {
+		return nil, fmt.Errorf("LVM PV header at %d is out of range", off)
+	}
+	l := &Label{
+		UUID:       formatUUID(string(sector[off : off+uuidLen])),
+		DeviceSize: binary.LittleEndian.Uint64(sector[off+uuidLen:]),
+	}
+	// Two lists of areas follow, each ended by a zero entry.
+	p := sector[off+uuidLen+8:]
+	for _, list := range []*[]DiskArea{&l.DataAreas, &l.MetadataAreas} {
+		for {
+			if len(p) < 16 {
+				return nil, fmt.Errorf("LVM PV header area list overflows the label")
+			}
+			a := DiskArea{Offset: binary.LittleEndian.Uint64(p), Size: binary.LittleEndian.Uint64(p[8:])}
+			p = p[16:]
+			if a.Offset == 0 {
+				break
+			}
+			*list = append(*list, a)
+		}
+	}
+	return l, nil
+}
+
+// ReadMetadata reads the VG metadata from the first usable metadata area
+// of the PV r.
+func (l *Label) ReadMetadata(r io.ReaderAt) (*VolumeGroup, error) {
+	var errs []string
+	for _, a := range l.MetadataAreas {
+		text, err := readMetadataArea(r, a)
+		if err == errIgnored {
+			continue
+		}
+		if err == nil {
+			var vg *VolumeGroup
+			if vg, err = parseMetadata(text); err == nil {
+				return vg, nil
+			}
+		}
+		errs = append(errs, fmt.Sprintf("metadata area at %d: %v", a.Offset, err))
+	}
+	if len(errs) == 0 {
+		return nil, ErrNoMetadata
+	}
+	return nil, errors.New(strings.Join(errs, "; "))
+}
+
+var errIgnored = errors.New("metadata area is ignored")
+
+// readMetadataArea reads the current metadata text of the area a.
+func readMetadataArea(r io.ReaderAt, a DiskArea) (string, error) {
+	h := make([]byte, mdaHeaderSize)
+	if _, err := r.ReadAt(h, int64(a.Offset)); err != nil {
+		return "", err
+	}
+	if string(h[4:20]) != mdaMagic {
+		return "", fmt.Errorf("bad magic")
+	}
+	if want, got := binary.LittleEndian.Uint32(h), crc(h[4:]); got != want {
+		return "", fmt.Errorf("header checksum is %#x, want %#x", got, want)
+	}
+	if v := binary.LittleEndian.Uint32(h[20:]); v != mdaVersion {
+		return "", fmt.Errorf("unsupported version %d", v)
+	}
+	if start := binary.LittleEndian.Uint64(h[24:]); start != a.Offset {
+		return "", fmt.Errorf("header says it is at %d", start)
+	}
+	size := binary.LittleEndian.Uint64(h[32:])
+
+	// The first raw_locn is the current metadata.
+	off := binary.LittleEndian.Uint64(h[40:])
+	n := binary.LittleEndian.Uint64(h[48:])
+	sum := binary.LittleEndian.Uint32(h[56:])
+	flags := binary.LittleEndian.Uint32(h[60:])
+	if flags&rawLocnIgnored != 0 {
+		return "", errIgnored
+	}
+	if off == 0 || n == 0 {
+		return "", fmt.Errorf("no metadata")
+	}
+	if off < mdaHeaderSize || off >= size || n > maxMetadata || n > size-mdaHeaderSize {
+		return "", fmt.Errorf("metadata of %d bytes at %d is outside the %d byte area", n, off, size)
+	}
+
+	// The area is a ring buffer after the header, so the text may wrap.
+	text := make([]byte, n)
+	first := n
+	if off+n > size {
+		first = size - off
+	}
+	if _, err := r.ReadAt(text[:first], int64(a.Offset+off)); err != nil {
+		return "", err
+	}
+	if first < n {
+		if _, err := r.ReadAt(text[first:], int64(a.Offset+mdaHeaderSize)); err != nil {
+			return "", err
+		}
+	}
+	if got := crc(text); got != sum {
+		return "", fmt.Errorf("metadata checksum is %#x, want %#x", got, sum)
+	}
+	return string(bytes.TrimRight(text, "\x00")), nil
+}
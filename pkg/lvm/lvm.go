@@ -0,0 +1,298 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lvm finds LVM2 volume groups and activates their logical volumes
+// through device-mapper.
+//
+// Each physical volume (PV) has a label near its start pointing to one or
+// more metadata areas, which hold the text metadata of its volume group
+// (VG): its PVs, and the logical volumes (LVs) made of extents on them.
+// See lib/format_text/layout.h in LVM2 for the on-disk format, and the
+// files vgcfgbackup writes for the metadata.
+package lvm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotPV is returned for devices without an LVM2 label.
+	ErrNotPV = errors.New("not an LVM2 physical volume")
+
+	// ErrNoMetadata is returned for PVs without a metadata area, which
+	// can only be used with the metadata of other PVs in their VG.
+	ErrNoMetadata = errors.New("physical volume has no metadata")
+
+	// ErrUnsupported is returned for LVs with segments that cannot be
+	// activated, e.g. thin or RAID ones.
+	ErrUnsupported = errors.New("unsupported segment type")
+)
+
+// VolumeGroup is an LVM2 volume group.
+type VolumeGroup struct {
+	Name string
+	UUID string
+
+	// Seqno is incremented by every change to the metadata.
+	Seqno int64
+
+	// ExtentSize is the size of physical extents in sectors.
+	ExtentSize uint64
+
+	Status []string
+
+	PVs []*PhysicalVolume
+	LVs []*LogicalVolume
+}
+
+// PhysicalVolume is a PV of a volume group.
+type PhysicalVolume struct {
+	// Name is the PV's name in the metadata, e.g. "pv0".
+	Name string
+	UUID string
+
+	// DeviceHint is the device the PV was last seen on.
+	DeviceHint string
+
+	// PEStart is the sector at which extents start, and PECount the
+	// number of extents.
+	PEStart uint64
+	PECount uint64
+
+	// Device is the path of the PV's device, if it has been found.
+	Device string
+}
+
+// LogicalVolume is an LV of a volume group.
+type LogicalVolume struct {
+	Name   string
+	UUID   string
+	Status []string
+
+	Segments []Segment
+}
+
+// Segment maps a range of an LV's extents.
+type Segment struct {
+	// StartExtent and ExtentCount are the LV extents the segment maps.
+	StartExtent uint64
+	ExtentCount uint64
+
+	// Type is the segment type, e.g. "striped" or "thin".
+	Type string
+
+	// StripeSize is the chunk size of a segment with several stripes,
+	// in sectors.
+	StripeSize uint64
+
+	// Stripes are the PV areas of a striped segment.
+	Stripes []Area
+}
+
+// Area is a range of extents on a PV.
+type Area struct {
+	// PV is the PV's name in the metadata.
+	PV string
+
+	// Extent is the first extent.
+	Extent uint64
+}
+
+func hasFlag(flags []string, f string) bool {
+	for _, s := range flags {
+		if s == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Visible reports whether lv is one users see, rather than part of
+// another LV, e.g. a RAID image or a thin pool's metadata.
+func (lv *LogicalVolume) Visible() bool {
+	return hasFlag(lv.Status, "VISIBLE")
+}
+
+// Extents returns the number of extents of lv.
+func (lv *LogicalVolume) Extents() uint64 {
+	var n uint64
+	for _, s := range lv.Segments {
+		n += s.ExtentCount
+	}
+	return n
+}
+
+// PV returns the PV of vg called name, or nil.
+func (vg *VolumeGroup) PV(name string) *PhysicalVolume {
+	for _, pv := range vg.PVs {
+		if pv.Name == name {
+			return pv
+		}
+	}
+	return nil
+}
+
+// LV returns the LV of vg called name, or nil.
+func (vg *VolumeGroup) LV(name string) *LogicalVolume {
+	for _, lv := range vg.LVs {
+		if lv.Name == name {
+			return lv
+		}
+	}
+	return nil
+}
+
+// Complete reports whether the devices of all of vg's PVs have been
+// found.
+func (vg *VolumeGroup) Complete() bool {
+	for _, pv := range vg.PVs {
+		if pv.Device == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// rawUUID strips the dashes from an LVM2 UUID.
+func rawUUID(u string) string {
+	return strings.Replace(u, "-", "", -1)
+}
+
+// formatUUID formats a 32 character UUID in LVM2's groups of
+// 6-4-4-4-4-4-6.
+func formatUUID(u string) string {
+	if len(u) != 32 {
+		return u
+	}
+	return strings.Join([]string{u[0:6], u[6:10], u[10:14], u[14:18], u[18:22], u[22:26], u[26:32]}, "-")
+}
+
+// parseMetadata parses the text metadata of a volume group.
+func parseMetadata(text string) (*VolumeGroup, error) {
+	root, err := parseConfig(text)
+	if err != nil {
+		return nil, err
+	}
+	// The VG is the only top-level section.
+	if len(root.children) != 1 {
+		return nil, fmt.Errorf("metadata has %d volume groups, want 1", len(root.children))
+	}
+	s := root.children[0]
+	vg := &VolumeGroup{Name: s.name}
+	if vg.UUID, err = s.str("id"); err != nil {
+		return nil, err
+	}
+	if vg.Seqno, err = s.int("seqno"); err != nil {
+		return nil, err
+	}
+	if vg.ExtentSize, err = s.uint("extent_size"); err != nil {
+		return nil, err
+	}
+	if vg.ExtentSize == 0 {
+		return nil, fmt.Errorf("volume group %s has no extent size", vg.Name)
+	}
+	if vg.Status, err = s.strings("status"); err != nil {
+		return nil, err
+	}
+
+	if pvs := s.child("physical_volumes"); pvs != nil {
+		for _, p := range pvs.children {
+			pv := &PhysicalVolume{Name: p.name}
+			if pv.UUID, err = p.str("id"); err != nil {
+				return nil, err
+			}
+			// The device hint is optional.
+			pv.DeviceHint, _ = p.str("device")
+			if pv.PEStart, err = p.uint("pe_start"); err != nil {
+				return nil, err
+			}
+			if pv.PECount, err = p.uint("pe_count"); err != nil {
+				return nil, err
+			}
+			vg.PVs = append(vg.PVs, pv)
+		}
+	}
+
+	if lvs := s.child("logical_volumes"); lvs != nil {
+		for _, l := range lvs.children {
+			lv, err := parseLV(vg, l)
+			if err != nil {
+				return nil, fmt.Errorf("logical volume %s: %v", l.name, err)
+			}
+			vg.LVs = append(vg.LVs, lv)
+		}
+	}
+	return vg, nil
+}
+
+func parseLV(vg *VolumeGroup, l *section) (*LogicalVolume, error) {
+	lv := &LogicalVolume{Name: l.name}
+	var err error
+	if lv.UUID, err = l.str("id"); err != nil {
+		return nil, err
+	}
+	if lv.Status, err = l.strings("status"); err != nil {
+		return nil, err
+	}
+	count, err := l.int("segment_count")
+	if err != nil {
+		return nil, err
+	}
+	for i := int64(1); i <= count; i++ {
+		s := l.child(fmt.Sprintf("segment%d", i))
+		if s == nil {
+			return nil, fmt.Errorf("segment%d is missing", i)
+		}
+		seg := Segment{}
+		if seg.StartExtent, err = s.uint("start_extent"); err != nil {
+			return nil, err
+		}
+		if seg.ExtentCount, err = s.uint("extent_count"); err != nil {
+			return nil, err
+		}
+		if seg.Type, err = s.str("type"); err != nil {
+			return nil, err
+		}
+		if seg.Type == "striped" {
+			if seg.Stripes, err = parseStripes(vg, s); err != nil {
+				return nil, err
+			}
+			if len(seg.Stripes) > 1 {
+				if seg.StripeSize, err = s.uint("stripe_size"); err != nil {
+					return nil, err
+				}
+			}
+		}
+		lv.Segments = append(lv.Segments, seg)
+	}
+	return lv, nil
+}
+
+// parseStripes parses the list of "pvN", extent pairs of a striped
+// segment.
+func parseStripes(vg *VolumeGroup, s *section) ([]Area, error) {
+	n, err := s.int("stripe_count")
+	if err != nil {
+		return nil, err
+	}
+	l, ok := s.values["stripes"].([]interface{})
+	if !ok || n < 1 || int64(len(l)) != 2*n {
+		return nil, fmt.Errorf("%s: want %d stripes", s.name, n)
+	}
+	var areas []Area
+	for i := 0; i < len(l); i += 2 {
+		pv, ok := l[i].(string)
+		ext, ok2 := l[i+1].(int64)
+		if !ok || !ok2 || ext < 0 {
+			return nil, fmt.Errorf("%s: stripe %d is not a PV and an extent", s.name, i/2)
+		}
+		if vg.PV(pv) == nil {
+			return nil, fmt.Errorf("%s: no physical volume %s", s.name, pv)
+		}
+		areas = append(areas, Area{PV: pv, Extent: uint64(ext)})
+	}
+	return areas, nil
+}
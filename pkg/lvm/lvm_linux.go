@@ -0,0 +1,91 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/u-root/u-root/pkg/dm"
+	"github.com/u-root/u-root/pkg/mount/block"
+)
+
+// Scan returns the volume groups on the PVs among devs.
+func Scan(devs block.BlockDevices) ([]*VolumeGroup, error) {
+	var paths []string
+	for _, d := range devs {
+		if d.FSType == "LVM2_member" {
+			paths = append(paths, d.DevicePath())
+		}
+	}
+	return ScanDevices(paths)
+}
+
+// Activate maps lv of vg to /dev/mapper/DMName(vg, lv), unless it is
+// already mapped. LVs without write permission are mapped read-only.
+func Activate(c *dm.Control, vg *VolumeGroup, lv *LogicalVolume, readOnly bool) (*dm.Device, error) {
+	name := DMName(vg, lv)
+	readOnly = readOnly || !hasFlag(lv.Status, "WRITE")
+	if d, err := c.Info(name); err == nil {
+		return d, nil
+	}
+	targets, err := vg.Targets(lv)
+	if err != nil {
+		return nil, err
+	}
+	return c.Create(name, DMUUID(vg, lv), readOnly, targets)
+}
+
+// ActivateAll activates the visible LVs of the complete volume groups on
+// devs, and returns the block devices of the ones that were not already
+// active.
+//
+// LVs that cannot be activated are skipped, and the ones that can are
+// returned with an error listing the rest.
+func ActivateAll(devs block.BlockDevices) (block.BlockDevices, error) {
+	vgs, err := Scan(devs)
+	var errs []string
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	var active block.BlockDevices
+	var c *dm.Control
+	for _, vg := range vgs {
+		if !vg.Complete() {
+			errs = append(errs, fmt.Sprintf("volume group %s is missing physical volumes", vg.Name))
+			continue
+		}
+		for _, lv := range vg.LVs {
+			if !lv.Visible() {
+				continue
+			}
+			if c == nil {
+				if c, err = dm.Open(); err != nil {
+					return nil, err
+				}
+				defer c.Close()
+			}
+			if _, err := c.Info(DMName(vg, lv)); err == nil {
+				continue
+			}
+			d, err := Activate(c, vg, lv, false)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			b, err := block.Device(fmt.Sprintf("dm-%d", d.Minor))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s/%s: %v", vg.Name, lv.Name, err))
+				continue
+			}
+			active = append(active, b)
+		}
+	}
+	if len(errs) > 0 {
+		return active, errors.New(strings.Join(errs, "; "))
+	}
+	return active, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/dm"
+)
+
+var pvs = []string{"testdata/pv1.img", "testdata/pv2.img", "testdata/pv3.img"}
+
+func scan(t *testing.T) *VolumeGroup {
+	vgs, err := ScanDevices(append([]string{"lvm.go"}, pvs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vgs) != 1 {
+		t.Fatalf("ScanDevices found %d volume groups, want 1", len(vgs))
+	}
+	return vgs[0]
+}
+
+func TestScanDevices(t *testing.T) {
+	vg := scan(t)
+	if vg.Name != "vg-data" || vg.UUID != "Uc1Vkh-Yv9b-K1dA-W5xq-2wQo-vS8c-Ih7ZfT" || vg.ExtentSize != 8 {
+		t.Errorf("volume group is %s %s with %d sector extents", vg.Name, vg.UUID, vg.ExtentSize)
+	}
+	// pv2.img has older metadata.
+	if vg.Seqno != 5 {
+		t.Errorf("metadata seqno is %d, want 5", vg.Seqno)
+	}
+	if !vg.Complete() {
+		t.Errorf("volume group is incomplete")
+	}
+	for i, pv := range vg.PVs {
+		if pv.Device != pvs[i] || pv.PEStart != 128 || pv.PECount != 16 {
+			t.Errorf("PV %d is %+v", i, pv)
+		}
+	}
+	var names []string
+	for _, lv := range vg.LVs {
+		names = append(names, lv.Name)
+	}
+	if want := []string{"root", "data", "pool", "lvol0_pmspare", "read-only"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("LVs are %v, want %v", names, want)
+	}
+	if vg.LV("lvol0_pmspare").Visible() || !vg.LV("root").Visible() {
+		t.Errorf("visibility of LVs is wrong")
+	}
+	if n := vg.LV("data").Extents(); n != 10 {
+		t.Errorf("data has %d extents, want 10", n)
+	}
+
+	vgs, err := ScanDevices(pvs[:2])
+	if err != nil || len(vgs) != 1 || vgs[0].Complete() {
+		t.Errorf("volume group without pv3 is complete")
+	}
+	if _, err := ScanDevices([]string{"testdata/missing"}); err == nil {
+		t.Errorf("scanning a missing device succeeded")
+	}
+}
+
+func TestTargets(t *testing.T) {
+	vg := scan(t)
+	for _, tt := range []struct {
+		lv   string
+		want []dm.Target
+	}{
+		{"root", []dm.Target{{Start: 0, Length: 32, Type: "linear", Params: "testdata/pv1.img 128"}}},
+		{"data", []dm.Target{
+			{Start: 0, Length: 64, Type: "striped", Params: "2 8 testdata/pv1.img 160 testdata/pv2.img 128"},
+			{Start: 64, Length: 16, Type: "linear", Params: "testdata/pv3.img 128"},
+		}},
+		{"read-only", []dm.Target{{Start: 0, Length: 8, Type: "linear", Params: "testdata/pv3.img 144"}}},
+	} {
+		got, err := vg.Targets(vg.LV(tt.lv))
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Targets(%s) = %v, %v, want %v", tt.lv, got, err, tt.want)
+		}
+	}
+	if _, err := vg.Targets(vg.LV("pool")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Targets of a thin pool = %v, want %v", err, ErrUnsupported)
+	}
+	vg.PV("pv2").Device = ""
+	if _, err := vg.Targets(vg.LV("data")); err == nil {
+		t.Errorf("Targets with a missing PV succeeded")
+	}
+
+	lv := vg.LV("read-only")
+	if got := DMName(vg, lv); got != "vg--data-read--only" {
+		t.Errorf("DMName = %q", got)
+	}
+	if got := DMUUID(vg, lv); got != "LVM-Uc1VkhYv9bK1dAW5xq2wQovS8cIh7ZfTQa1Ws2Ed3RTg4YHu5JIk6OLp7ZXc8VbN" {
+		t.Errorf("DMUUID = %q", got)
+	}
+}
+
+func TestReadLabel(t *testing.T) {
+	img, err := ioutil.ReadFile(pvs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := ReadLabel(bytes.NewReader(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Label{
+		UUID:          "Q2Zw3e-9fPj-Ti3D-6bNu-L0Ka-c2Vx-mT5sEy",
+		DeviceSize:    1 << 30,
+		DataAreas:     []DiskArea{{Offset: 65536}},
+		MetadataAreas: []DiskArea{{Offset: 4096, Size: 61440}},
+	}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("ReadLabel = %+v, want %+v", l, want)
+	}
+
+	f, err := ioutil.ReadFile(pvs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, err := ReadLabel(bytes.NewReader(f)); err != nil {
+		t.Error(err)
+	} else if _, err := l.ReadMetadata(bytes.NewReader(f)); err != ErrNoMetadata {
+		t.Errorf("ReadMetadata of a PV without metadata = %v, want %v", err, ErrNoMetadata)
+	}
+	if _, err := ReadLabel(bytes.NewReader(make([]byte, 4096))); err != ErrNotPV {
+		t.Errorf("ReadLabel of zeros = %v, want %v", err, ErrNotPV)
+	}
+	if _, err := ReadLabel(strings.NewReader("short")); err != ErrNotPV {
+		t.Errorf("ReadLabel of a short device = %v, want %v", err, ErrNotPV)
+	}
+
+	for _, tt := range []struct {
+		name string
+		off  int
+		err  string
+	}{
+		{"label", 512 + 40, "label checksum"},
+		{"mda header", 4096 + 30, "header checksum"},
+		// The metadata wraps around to just after the header.
+		{"metadata", 4096 + 512 + 10, "metadata checksum"},
+	} {
+		bad := append([]byte(nil), img...)
+		bad[tt.off] ^= 1
+		l, err := ReadLabel(bytes.NewReader(bad))
+		if err == nil {
+			_, err = l.ReadMetadata(bytes.NewReader(bad))
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("reading with a damaged %s = %v, want an error about the %s", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	root, err := parseConfig(`# comment
+vg { id = "a\"b\\c" # trailing
+  list = [ "x", 1, -2 ] empty = []
+  sub { n = 7 f = 1.5 }
+}
+version = 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vg := root.child("vg")
+	if s, _ := vg.str("id"); s != `a"b\c` {
+		t.Errorf("escaped string is %q", s)
+	}
+	if !reflect.DeepEqual(vg.values["list"], []interface{}{"x", int64(1), int64(-2)}) {
+		t.Errorf("list is %#v", vg.values["list"])
+	}
+	if n, _ := vg.child("sub").int("n"); n != 7 {
+		t.Errorf("n = %d, want 7", n)
+	}
+	if _, err := vg.strings("list"); err == nil {
+		t.Errorf("strings of a mixed list succeeded")
+	}
+
+	for _, text := range []string{
+		"vg {",
+		"vg { a = }",
+		"vg { a = [1 2] }",
+		`vg { a = "open }`,
+		"vg }",
+		"a b",
+	} {
+		if _, err := parseConfig(text); err == nil {
+			t.Errorf("parseConfig(%q) succeeded", text)
+		}
+	}
+
+	for _, text := range []string{
+		"",
+		"a {} b {}",
+		`vg { seqno = 1 extent_size = 8 }`,
+		`vg { id = "x" seqno = 1 extent_size = 0 }`,
+		`vg { id = "x" seqno = 1 extent_size = 8 logical_volumes { lv { id = "y" segment_count = 1 } } }`,
+		`vg { id = "x" seqno = 1 extent_size = 8 logical_volumes { lv { id = "y" segment_count = 1
+			segment1 { start_extent = 0 extent_count = 1 type = "striped" stripe_count = 1 stripes = ["pv9", 0] } } } }`,
+	} {
+		if _, err := parseMetadata(text); err == nil {
+			t.Errorf("parseMetadata(%q) succeeded", text)
+		}
+	}
+}
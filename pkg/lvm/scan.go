@@ -0,0 +1,84 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lvm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type pvLabel struct {
+	path  string
+	label *Label
+}
+
+// ScanDevices reads the LVM2 labels of the devices at paths and returns
+// the volume groups whose metadata they hold, with the Device of each PV
+// found among paths set. Where PVs disagree, the newest metadata wins.
+//
+// Devices that are not PVs are skipped. If any other device cannot be
+// read, the volume groups found on the rest are returned with an error.
+func ScanDevices(paths []string) ([]*VolumeGroup, error) {
+	var (
+		vgs    []*VolumeGroup
+		labels []pvLabel
+		errs   []string
+	)
+	for _, path := range paths {
+		l, vg, err := scanDevice(path)
+		if err == ErrNotPV {
+			continue
+		}
+		if l != nil {
+			labels = append(labels, pvLabel{path, l})
+		}
+		if err != nil && err != ErrNoMetadata {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+		}
+		if vg == nil {
+			continue
+		}
+		i := 0
+		for i < len(vgs) && vgs[i].UUID != vg.UUID {
+			i++
+		}
+		if i == len(vgs) {
+			vgs = append(vgs, vg)
+		} else if vg.Seqno > vgs[i].Seqno {
+			vgs[i] = vg
+		}
+	}
+
+	for _, vg := range vgs {
+		for _, pv := range vg.PVs {
+			for _, l := range labels {
+				if rawUUID(l.label.UUID) == rawUUID(pv.UUID) {
+					pv.Device = l.path
+					break
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return vgs, errors.New(strings.Join(errs, "; "))
+	}
+	return vgs, nil
+}
+
+func scanDevice(path string) (*Label, *VolumeGroup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	l, err := ReadLabel(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	vg, err := l.ReadMetadata(f)
+	return l, vg, err
+}
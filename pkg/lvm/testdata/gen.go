@@ -0,0 +1,261 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// gen writes the test PVs. It follows the layout pvcreate uses: a label
+// in sector 1 and a metadata area from 4KiB up to the first extent. The
+// images end there; the extents are not needed. Padded to 2MiB, as
+// libblkid skips smaller devices for LVM2, "blkid -p" recognizes them.
+//
+// pv1.img and pv2.img hold the metadata of vg-data, pv2.img's one
+// version older and pv1.img's wrapped around the end of its area.
+// pv3.img has no metadata area.
+//
+// Run it from this directory with "go run gen.go".
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+const (
+	sector  = 512
+	mdaOff  = 4096
+	peStart = 128
+	mdaSize = peStart*sector - mdaOff
+)
+
+var (
+	vgUUID  = "Uc1Vkh-Yv9b-K1dA-W5xq-2wQo-vS8c-Ih7ZfT"
+	pvUUIDs = []string{
+		"Q2Zw3e-9fPj-Ti3D-6bNu-L0Ka-c2Vx-mT5sEy",
+		"e4xQyD-mKb8-Hc2L-rS5w-Yt1A-u9Fn-P3vJzO",
+		"Hj8KlM-2nBv-Cx4Z-qW6e-Rt7Y-u8Io-P1aSdF",
+	}
+)
+
+func crc(p []byte) uint32 {
+	return ^crc32.Update(^uint32(0xf597a6cf), crc32.IEEETable, p)
+}
+
+func metadata(seqno int, lvs string) string {
+	var pvs strings.Builder
+	for i, u := range pvUUIDs {
+		fmt.Fprintf(&pvs, `
+pv%d {
+id = "%s"
+device = "/dev/sd%c1"	# Hint only
+
+status = ["ALLOCATABLE"]
+flags = []
+dev_size = 2097152	# 1 Gigabytes
+pe_start = %d
+pe_count = 16	# 64 Kilobytes
+}
+`, i, u, 'a'+i, peStart)
+	}
+	return fmt.Sprintf(`vg-data {
+id = "%s"
+seqno = %d
+format = "lvm2"	# informational
+status = ["RESIZEABLE", "READ", "WRITE"]
+flags = []
+extent_size = 8	# 4 Kilobytes
+max_lv = 0
+max_pv = 0
+metadata_copies = 0
+
+physical_volumes {
+%s
+}
+
+logical_volumes {
+%s
+}
+
+}
+# Generated by LVM2 version 2.03.02(2) (2018-12-18): Mon Jun 22 12:00:00 2020
+
+contents = "Text Format Volume"
+version = 1
+
+description = "Write from \"lvcreate -n root vg-data\"."
+
+creation_host = "u-root"	# Linux u-root 5.4.0 #1 SMP x86_64
+creation_time = 1592827200	# Mon Jun 22 12:00:00 2020
+
+`, vgUUID, seqno, pvs.String(), lvs)
+}
+
+const oldLVs = `
+root {
+id = "7bWq1c-0mVd-Zx3K-Lp9e-Ty6U-i2Oa-Sd4FgH"
+status = ["READ", "WRITE", "VISIBLE"]
+flags = []
+segment_count = 1
+
+segment1 {
+start_extent = 0
+extent_count = 4
+type = "striped"
+stripe_count = 1	# linear
+stripes = [
+"pv0", 0
+]
+}
+}
+`
+
+const newLVs = oldLVs + `
+data {
+id = "Jk2Lm3-Nb4V-Cx5Z-As6D-Fg7H-Jk8L-Qw9ErT"
+status = ["READ", "WRITE", "VISIBLE"]
+flags = []
+creation_time = 1592827200
+creation_host = "u-root"
+segment_count = 2
+
+segment1 {
+start_extent = 0
+extent_count = 8
+type = "striped"
+stripe_count = 2
+stripe_size = 8	# 4 Kilobytes
+stripes = [
+"pv0", 4,
+"pv1", 0
+]
+}
+segment2 {
+start_extent = 8
+extent_count = 2
+type = "striped"
+stripe_count = 1
+stripes = [
+"pv2", 0
+]
+}
+}
+
+pool {
+id = "Zx1Cv2-Bn3M-As4D-Fg5H-Jk6L-Qw7E-Rt8YuI"
+status = ["READ", "WRITE", "VISIBLE"]
+flags = []
+segment_count = 1
+
+segment1 {
+start_extent = 0
+extent_count = 2
+type = "thin-pool"
+metadata = "pool_tmeta"
+pool = "pool_tdata"
+transaction_id = 0
+chunk_size = 128
+}
+}
+
+lvol0_pmspare {
+id = "Po9Iu8-Yt7R-Ew6Q-Lk5J-Hg4F-Ds3A-Mn2BvC"
+status = ["READ", "WRITE"]
+flags = []
+segment_count = 1
+
+segment1 {
+start_extent = 0
+extent_count = 1
+type = "striped"
+stripe_count = 1
+stripes = [
+"pv1", 4
+]
+}
+}
+
+read-only {
+id = "Qa1Ws2-Ed3R-Tg4Y-Hu5J-Ik6O-Lp7Z-Xc8VbN"
+status = ["READ", "VISIBLE"]
+flags = []
+segment_count = 1
+
+segment1 {
+start_extent = 0
+extent_count = 1
+type = "striped"
+stripe_count = 1
+stripes = [
+"pv2", 2
+]
+}
+}
+`
+
+// pv returns a PV image with the given metadata text at off in its
+// metadata area, or without a metadata area if text is empty.
+func pv(i int, text string, off int) []byte {
+	size := sector * 8
+	if text != "" {
+		size = peStart * sector
+	}
+	img := make([]byte, size)
+
+	// The label is in sector 1, and the PV header follows it.
+	l := img[sector : 2*sector]
+	copy(l, "LABELONE")
+	binary.LittleEndian.PutUint64(l[8:], 1)
+	binary.LittleEndian.PutUint32(l[20:], 32)
+	copy(l[24:], "LVM2 001")
+	copy(l[32:], strings.Replace(pvUUIDs[i], "-", "", -1))
+	binary.LittleEndian.PutUint64(l[64:], 2097152*sector)
+	areas := l[72:]
+	binary.LittleEndian.PutUint64(areas, peStart*sector)
+	areas = areas[32:]
+	if text != "" {
+		binary.LittleEndian.PutUint64(areas, mdaOff)
+		binary.LittleEndian.PutUint64(areas[8:], mdaSize)
+		areas = areas[16:]
+	}
+	// The header extension of LVM2 2.02.116 and later follows the
+	// lists: version 2, in use by a VG, and no bootloader areas.
+	ext := areas[16:]
+	binary.LittleEndian.PutUint32(ext, 2)
+	binary.LittleEndian.PutUint32(ext[4:], 1)
+	binary.LittleEndian.PutUint32(l[16:], crc(l[20:]))
+	if text == "" {
+		return img
+	}
+
+	// LVM2 writes the text with its NUL.
+	t := append([]byte(text), 0)
+	m := img[mdaOff : mdaOff+mdaSize]
+	copy(m[4:], "\x20LVM2\x20x[5A%r0N*>")
+	binary.LittleEndian.PutUint32(m[20:], 1)
+	binary.LittleEndian.PutUint64(m[24:], mdaOff)
+	binary.LittleEndian.PutUint64(m[32:], mdaSize)
+	binary.LittleEndian.PutUint64(m[40:], uint64(off))
+	binary.LittleEndian.PutUint64(m[48:], uint64(len(t)))
+	binary.LittleEndian.PutUint32(m[56:], crc(t))
+	binary.LittleEndian.PutUint32(m, crc(m[4:sector]))
+	n := copy(m[off:], t)
+	copy(m[sector:], t[n:])
+	return img
+}
+
+func main() {
+	for name, img := range map[string][]byte{
+		// The new metadata wraps around the end of the area.
+		"pv1.img": pv(0, metadata(5, newLVs), mdaSize-1024),
+		"pv2.img": pv(1, metadata(4, oldLVs), sector),
+		"pv3.img": pv(2, "", 0),
+	} {
+		if err := ioutil.WriteFile(name, img, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mkfs creates empty FAT and ext4 file systems.
+//
+// The file systems are written through an io.WriterAt, so images can be
+// made in files or memory as well as on block devices, without help from
+// the kernel.
+package mkfs
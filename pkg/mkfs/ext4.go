@@ -0,0 +1,589 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mkfs
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// See https://www.kernel.org/doc/html/latest/filesystems/ext4/ for the
+// on-disk format.
+//
+// The file systems made here are deliberately simple: every block group
+// has its own bitmaps and inode table (no flex_bg), there are no metadata
+// checksums, and block numbers are 32 bits, which limits the size to 16
+// TiB.
+const (
+	ext4SuperOff   = 1024
+	ext4SuperSize  = 1024
+	ext4Magic      = 0xef53
+	ext4InodeSize  = 256
+	ext4ExtraIsize = 32
+	ext4DescSize   = 32
+
+	ext4RootIno     = 2
+	ext4JournalIno  = 8
+	ext4FirstIno    = 11
+	ext4LostFound   = 11
+	ext4LostFoundSz = 16 << 10
+
+	ext4CompatHasJournal = 0x4
+	ext4CompatExtAttr    = 0x8
+	ext4CompatDirIndex   = 0x20
+
+	ext4IncompatFiletype = 0x2
+	ext4IncompatExtents  = 0x40
+
+	ext4ROCompatSparseSuper = 0x1
+	ext4ROCompatLargeFile   = 0x2
+	ext4ROCompatHugeFile    = 0x8
+	ext4ROCompatDirNlink    = 0x20
+	ext4ROCompatExtraIsize  = 0x40
+
+	ext4ExtentsFlag  = 0x80000
+	ext4ExtentMagic  = 0xf30a
+	ext4MaxExtentLen = 32768
+	ext4InodeExtents = 4
+
+	ext4FileTypeDir = 2
+
+	// Directories are 040755 and 040700, the journal 0100600.
+	ext4ModeRoot      = 0x41ed
+	ext4ModeLostFound = 0x41c0
+	ext4ModeJournal   = 0x8180
+
+	// The journal superblock is big endian.
+	jbd2Magic        = 0xc03b3998
+	jbd2SuperblockV2 = 4
+
+	// ext4MinGroupBlocks is the fewest data blocks a trailing block group
+	// must have not to be dropped, as mke2fs does.
+	ext4MinGroupBlocks = 50
+)
+
+// Ext4Options describes an ext4 file system.
+type Ext4Options struct {
+	// Label is the volume label, up to 16 bytes.
+	Label string
+
+	// UUID is the file system UUID. If zero, it is random.
+	UUID [16]byte
+
+	// BlockSize is 1024, 2048 or 4096. If 0, it is 4096.
+	BlockSize int
+
+	// InodeRatio is the number of bytes per inode. If 0, it is 16384.
+	InodeRatio int
+
+	// Journal adds a journal, making the file system ext4 rather than a
+	// journal-less one.
+	Journal bool
+
+	// JournalBlocks is the size of the journal. If 0, it is chosen from
+	// the file system size as mke2fs does, up to 32768 blocks.
+	JournalBlocks int
+
+	// Time is the creation time. If zero, the current time is used.
+	Time time.Time
+}
+
+// ext4Extent is a run of blocks.
+type ext4Extent struct {
+	start, len uint32
+}
+
+// ext4Layout is where things are on an ext4 file system, in blocks.
+type ext4Layout struct {
+	bs        uint32
+	blocks    uint32
+	firstData uint32
+	bpg       uint32
+	groups    uint32
+	ipg       uint32
+	itb       uint32
+	gdtBlocks uint32
+
+	// allocated are data blocks in use, in ascending order.
+	allocated []ext4Extent
+	next      uint32
+}
+
+func isPowerOf(n, b uint32) bool {
+	for n > 1 && n%b == 0 {
+		n /= b
+	}
+	return n == 1
+}
+
+// hasSuper reports whether group g has a copy of the superblock and group
+// descriptors. With sparse_super, that is groups 0, 1 and powers of 3, 5
+// and 7.
+func (l *ext4Layout) hasSuper(g uint32) bool {
+	return g <= 1 || isPowerOf(g, 3) || isPowerOf(g, 5) || isPowerOf(g, 7)
+}
+
+func (l *ext4Layout) groupStart(g uint32) uint32 {
+	return l.firstData + g*l.bpg
+}
+
+func (l *ext4Layout) groupBlocks(g uint32) uint32 {
+	if n := l.blocks - l.groupStart(g); n < l.bpg {
+		return n
+	}
+	return l.bpg
+}
+
+func (l *ext4Layout) blockBitmap(g uint32) uint32 {
+	b := l.groupStart(g)
+	if l.hasSuper(g) {
+		b += 1 + l.gdtBlocks
+	}
+	return b
+}
+
+func (l *ext4Layout) inodeBitmap(g uint32) uint32 {
+	return l.blockBitmap(g) + 1
+}
+
+func (l *ext4Layout) inodeTable(g uint32) uint32 {
+	return l.blockBitmap(g) + 2
+}
+
+// overhead returns the number of metadata blocks at the start of group g.
+func (l *ext4Layout) overhead(g uint32) uint32 {
+	return l.inodeTable(g) + l.itb - l.groupStart(g)
+}
+
+func ext4Geometry(size int64, o Ext4Options) (*ext4Layout, error) {
+	bs := uint32(o.BlockSize)
+	if bs == 0 {
+		bs = 4096
+	}
+	if bs != 1024 && bs != 2048 && bs != 4096 {
+		return nil, fmt.Errorf("block size %d is not 1024, 2048 or 4096", bs)
+	}
+	ratio := int64(o.InodeRatio)
+	if ratio == 0 {
+		ratio = 16384
+	}
+	if ratio < int64(bs) || ratio > 64<<20 {
+		return nil, fmt.Errorf("inode ratio %d is not between the block size and 64 MiB", ratio)
+	}
+	blocks := size / int64(bs)
+	if blocks > 0xffffffff {
+		return nil, fmt.Errorf("%d blocks are too many for a file system without 64bit block numbers", blocks)
+	}
+
+	l := &ext4Layout{bs: bs, blocks: uint32(blocks), bpg: 8 * bs}
+	if bs == 1024 {
+		l.firstData = 1
+	}
+	if l.blocks <= l.firstData {
+		return nil, fmt.Errorf("%d bytes are too few for ext4", size)
+	}
+	ipb := bs / ext4InodeSize
+	for {
+		l.groups = (l.blocks - l.firstData + l.bpg - 1) / l.bpg
+		l.gdtBlocks = (l.groups*ext4DescSize + bs - 1) / bs
+
+		inodes := uint64(l.blocks) * uint64(bs) / uint64(ratio)
+		ipg := (inodes + uint64(l.groups) - 1) / uint64(l.groups)
+		// Inode tables fill whole blocks and inode bitmaps whole bytes.
+		align := uint64(8)
+		if uint64(ipb) > align {
+			align = uint64(ipb)
+		}
+		ipg = (ipg + align - 1) / align * align
+		if ipg < 16 {
+			ipg = 16
+		}
+		if ipg > uint64(8*bs) {
+			ipg = uint64(8 * bs)
+		}
+		l.ipg = uint32(ipg)
+		l.itb = l.ipg / ipb
+
+		// Drop a last group too small to be useful.
+		last := l.groups - 1
+		if last == 0 || l.groupBlocks(last) >= l.overhead(last)+ext4MinGroupBlocks {
+			break
+		}
+		l.blocks = l.groupStart(last)
+	}
+	if l.groupBlocks(0) < l.overhead(0)+ext4MinGroupBlocks {
+		return nil, fmt.Errorf("%d bytes are too few for ext4", size)
+	}
+	l.next = l.inodeTable(0) + l.itb
+	return l, nil
+}
+
+// alloc allocates n blocks in as few extents as it can.
+func (l *ext4Layout) alloc(n uint32) ([]ext4Extent, error) {
+	var es []ext4Extent
+	for n > 0 {
+		if l.next >= l.blocks {
+			return nil, fmt.Errorf("file system is full")
+		}
+		g := (l.next - l.firstData) / l.bpg
+		if start := l.inodeTable(g) + l.itb; l.next < start {
+			l.next = start
+			continue
+		}
+		run := l.groupStart(g) + l.groupBlocks(g) - l.next
+		if run > n {
+			run = n
+		}
+		if run > ext4MaxExtentLen {
+			run = ext4MaxExtentLen
+		}
+		es = append(es, ext4Extent{l.next, run})
+		l.allocated = append(l.allocated, ext4Extent{l.next, run})
+		l.next += run
+		n -= run
+	}
+	return es, nil
+}
+
+// defaultJournalBlocks returns the journal size mke2fs picks, capped to
+// what fits in the inode's extents.
+func defaultJournalBlocks(blocks uint32) (uint32, error) {
+	switch {
+	case blocks < 2048:
+		return 0, fmt.Errorf("%d blocks are too few for a journal", blocks)
+	case blocks < 32768:
+		return 1024, nil
+	case blocks < 256*1024:
+		return 4096, nil
+	case blocks < 512*1024:
+		return 8192, nil
+	case blocks < 4096*1024:
+		return 16384, nil
+	}
+	return 32768, nil
+}
+
+func setBits(b []byte, from, to uint32) {
+	for i := from; i < to; i++ {
+		b[i/8] |= 1 << (i % 8)
+	}
+}
+
+// ext4Inode encodes an inode whose data is in the given extents.
+func ext4Inode(mode uint16, links uint16, size uint64, es []ext4Extent, bs uint32, t uint32) ([]byte, error) {
+	if len(es) > ext4InodeExtents {
+		return nil, fmt.Errorf("%d extents do not fit in an inode", len(es))
+	}
+	b := make([]byte, ext4InodeSize)
+	binary.LittleEndian.PutUint16(b[0:], mode)
+	binary.LittleEndian.PutUint32(b[4:], uint32(size))
+	for _, off := range []int{8, 12, 16, 144} { // atime, ctime, mtime, crtime
+		binary.LittleEndian.PutUint32(b[off:], t)
+	}
+	binary.LittleEndian.PutUint16(b[26:], links)
+	var blocks uint64
+	for _, e := range es {
+		blocks += uint64(e.len)
+	}
+	binary.LittleEndian.PutUint32(b[28:], uint32(blocks*uint64(bs)/512))
+	binary.LittleEndian.PutUint32(b[32:], ext4ExtentsFlag)
+
+	// The extent tree's root is in i_block.
+	eh := b[40:]
+	binary.LittleEndian.PutUint16(eh[0:], ext4ExtentMagic)
+	binary.LittleEndian.PutUint16(eh[2:], uint16(len(es)))
+	binary.LittleEndian.PutUint16(eh[4:], ext4InodeExtents)
+	var logical uint32
+	for i, e := range es {
+		x := eh[12+12*i:]
+		binary.LittleEndian.PutUint32(x[0:], logical)
+		binary.LittleEndian.PutUint16(x[4:], uint16(e.len))
+		binary.LittleEndian.PutUint32(x[8:], e.start)
+		logical += e.len
+	}
+	binary.LittleEndian.PutUint32(b[108:], uint32(size>>32))
+	binary.LittleEndian.PutUint16(b[128:], ext4ExtraIsize)
+	return b, nil
+}
+
+// ext4DirEntry appends a directory entry taking recLen bytes to b.
+func ext4DirEntry(b []byte, ino uint32, name string, recLen int) []byte {
+	e := make([]byte, recLen)
+	binary.LittleEndian.PutUint32(e[0:], ino)
+	binary.LittleEndian.PutUint16(e[4:], uint16(recLen))
+	e[6] = byte(len(name))
+	e[7] = ext4FileTypeDir
+	copy(e[8:], name)
+	return append(b, e...)
+}
+
+// Ext4 writes an empty ext4 file system of size bytes to w.
+func Ext4(w io.WriterAt, size int64, o Ext4Options) error {
+	l, err := ext4Geometry(size, o)
+	if err != nil {
+		return err
+	}
+	if len(o.Label) > 16 {
+		return fmt.Errorf("ext4 label %q is longer than 16 bytes", o.Label)
+	}
+	uuid := o.UUID
+	if uuid == [16]byte{} {
+		if _, err := rand.Read(uuid[:]); err != nil {
+			return err
+		}
+		uuid[6] = uuid[6]&0x0f | 0x40
+		uuid[8] = uuid[8]&0x3f | 0x80
+	}
+	var hashSeed [16]byte
+	if _, err := rand.Read(hashSeed[:]); err != nil {
+		return err
+	}
+	now := o.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	t := uint32(now.Unix())
+	bs := int64(l.bs)
+
+	// Data blocks.
+	rootBlocks, err := l.alloc(1)
+	if err != nil {
+		return err
+	}
+	lfSize := uint32(ext4LostFoundSz)
+	if lfSize < l.bs {
+		lfSize = l.bs
+	}
+	lfBlocks, err := l.alloc(lfSize / l.bs)
+	if err != nil {
+		return err
+	}
+	var jBlocks []ext4Extent
+	var jSize uint32
+	if o.Journal {
+		jSize = uint32(o.JournalBlocks)
+		if jSize == 0 {
+			if jSize, err = defaultJournalBlocks(l.blocks); err != nil {
+				return err
+			}
+		}
+		if jSize < 1024 || jSize > l.blocks/2 {
+			return fmt.Errorf("journal of %d blocks is not between 1024 blocks and half the file system", jSize)
+		}
+		if jBlocks, err = l.alloc(jSize); err != nil {
+			return err
+		}
+	}
+
+	// Inodes.
+	type inode struct {
+		ino uint32
+		b   []byte
+	}
+	var inodes []inode
+	for _, i := range []struct {
+		ino   uint32
+		mode  uint16
+		links uint16
+		es    []ext4Extent
+		size  uint64
+	}{
+		{ext4RootIno, ext4ModeRoot, 3, rootBlocks, uint64(l.bs)},
+		{ext4LostFound, ext4ModeLostFound, 2, lfBlocks, uint64(lfSize)},
+		{ext4JournalIno, ext4ModeJournal, 1, jBlocks, uint64(jSize) * uint64(l.bs)},
+	} {
+		if i.es == nil {
+			continue
+		}
+		b, err := ext4Inode(i.mode, i.links, i.size, i.es, l.bs, t)
+		if err != nil {
+			return err
+		}
+		inodes = append(inodes, inode{i.ino, b})
+	}
+
+	// Group descriptors, bitmaps and free counts.
+	gdt := make([]byte, l.gdtBlocks*l.bs)
+	var freeBlocks, freeInodes uint64
+	for g := uint32(0); g < l.groups; g++ {
+		start, n := l.groupStart(g), l.groupBlocks(g)
+		bb := make([]byte, l.bs)
+		setBits(bb, 0, l.overhead(g))
+		for _, e := range l.allocated {
+			if e.start >= start && e.start < start+n {
+				setBits(bb, e.start-start, e.start-start+e.len)
+			}
+		}
+		setBits(bb, n, 8*l.bs)
+		ib := make([]byte, l.bs)
+		usedInodes := uint32(0)
+		if g == 0 {
+			usedInodes = ext4FirstIno
+		}
+		setBits(ib, 0, usedInodes)
+		setBits(ib, l.ipg, 8*l.bs)
+
+		free := n
+		for i := uint32(0); i < n; i++ {
+			if bb[i/8]&(1<<(i%8)) != 0 {
+				free--
+			}
+		}
+		freeBlocks += uint64(free)
+		freeInodes += uint64(l.ipg - usedInodes)
+
+		d := gdt[g*ext4DescSize:]
+		binary.LittleEndian.PutUint32(d[0:], l.blockBitmap(g))
+		binary.LittleEndian.PutUint32(d[4:], l.inodeBitmap(g))
+		binary.LittleEndian.PutUint32(d[8:], l.inodeTable(g))
+		binary.LittleEndian.PutUint16(d[12:], uint16(free))
+		binary.LittleEndian.PutUint16(d[14:], uint16(l.ipg-usedInodes))
+		if g == 0 {
+			binary.LittleEndian.PutUint16(d[16:], 2) // root and lost+found
+		}
+
+		if _, err := w.WriteAt(bb, int64(l.blockBitmap(g))*bs); err != nil {
+			return err
+		}
+		if _, err := w.WriteAt(ib, int64(l.inodeBitmap(g))*bs); err != nil {
+			return err
+		}
+		if err := zero(w, int64(l.inodeTable(g))*bs, int64(l.itb)*bs); err != nil {
+			return err
+		}
+	}
+
+	// Superblock.
+	sb := make([]byte, ext4SuperSize)
+	le := binary.LittleEndian
+	le.PutUint32(sb[0:], l.ipg*l.groups)
+	le.PutUint32(sb[4:], l.blocks)
+	le.PutUint32(sb[8:], l.blocks/20)
+	le.PutUint32(sb[12:], uint32(freeBlocks))
+	le.PutUint32(sb[16:], uint32(freeInodes))
+	le.PutUint32(sb[20:], l.firstData)
+	logBS := uint32(0)
+	for 1024<<logBS < l.bs {
+		logBS++
+	}
+	le.PutUint32(sb[24:], logBS)
+	le.PutUint32(sb[28:], logBS)
+	le.PutUint32(sb[32:], l.bpg)
+	le.PutUint32(sb[36:], l.bpg)
+	le.PutUint32(sb[40:], l.ipg)
+	le.PutUint32(sb[48:], t)      // s_wtime
+	le.PutUint16(sb[54:], 0xffff) // no forced checks
+	le.PutUint16(sb[56:], ext4Magic)
+	le.PutUint16(sb[58:], 1) // clean
+	le.PutUint16(sb[60:], 1) // continue on errors
+	le.PutUint32(sb[64:], t) // s_lastcheck
+	le.PutUint32(sb[76:], 1) // dynamic inode sizes
+	le.PutUint32(sb[84:], ext4FirstIno)
+	le.PutUint16(sb[88:], ext4InodeSize)
+	compat := uint32(ext4CompatExtAttr | ext4CompatDirIndex)
+	if o.Journal {
+		compat |= ext4CompatHasJournal
+	}
+	le.PutUint32(sb[92:], compat)
+	le.PutUint32(sb[96:], ext4IncompatFiletype|ext4IncompatExtents)
+	le.PutUint32(sb[100:], ext4ROCompatSparseSuper|ext4ROCompatLargeFile|ext4ROCompatHugeFile|ext4ROCompatDirNlink|ext4ROCompatExtraIsize)
+	copy(sb[104:120], uuid[:])
+	copy(sb[120:136], o.Label)
+	copy(sb[236:252], hashSeed[:])
+	sb[252] = 1                           // half_md4 directory hashes
+	le.PutUint32(sb[256:], 0x0004|0x0008) // user_xattr and acl
+	le.PutUint32(sb[264:], t)             // s_mkfs_time
+	le.PutUint16(sb[348:], ext4ExtraIsize)
+	le.PutUint16(sb[350:], ext4ExtraIsize)
+	le.PutUint32(sb[352:], 0x1) // signed directory hashes
+	if o.Journal {
+		le.PutUint32(sb[224:], ext4JournalIno)
+		// A backup of the journal inode's blocks and size.
+		sb[253] = 1
+		for _, i := range inodes {
+			if i.ino == ext4JournalIno {
+				copy(sb[268:328], i.b[40:100])
+				le.PutUint32(sb[328:], le.Uint32(i.b[108:]))
+				le.PutUint32(sb[332:], le.Uint32(i.b[4:]))
+			}
+		}
+	}
+
+	// Clear any boot sector, as mke2fs does, to get rid of other file
+	// systems' signatures.
+	if err := zero(w, 0, ext4SuperOff); err != nil {
+		return err
+	}
+	for g := uint32(0); g < l.groups; g++ {
+		if !l.hasSuper(g) {
+			continue
+		}
+		le.PutUint16(sb[90:], uint16(g))
+		off := int64(l.groupStart(g)) * bs
+		if g == 0 {
+			off = ext4SuperOff
+		}
+		if _, err := w.WriteAt(sb, off); err != nil {
+			return err
+		}
+		if _, err := w.WriteAt(gdt, int64(l.groupStart(g)+1)*bs); err != nil {
+			return err
+		}
+	}
+
+	for _, i := range inodes {
+		g, n := (i.ino-1)/l.ipg, (i.ino-1)%l.ipg
+		if _, err := w.WriteAt(i.b, int64(l.inodeTable(g))*bs+int64(n)*ext4InodeSize); err != nil {
+			return err
+		}
+	}
+
+	// Directories.
+	var root []byte
+	root = ext4DirEntry(root, ext4RootIno, ".", 12)
+	root = ext4DirEntry(root, ext4RootIno, "..", 12)
+	root = ext4DirEntry(root, ext4LostFound, "lost+found", int(l.bs)-24)
+	if _, err := w.WriteAt(root, int64(rootBlocks[0].start)*bs); err != nil {
+		return err
+	}
+	lf := make([]byte, lfSize)
+	copy(lf, ext4DirEntry(ext4DirEntry(nil, ext4LostFound, ".", 12), ext4RootIno, "..", int(l.bs)-12))
+	for off := l.bs; off < lfSize; off += l.bs {
+		binary.LittleEndian.PutUint16(lf[off+4:], uint16(l.bs))
+	}
+	for _, e := range lfBlocks {
+		if _, err := w.WriteAt(lf[:e.len*l.bs], int64(e.start)*bs); err != nil {
+			return err
+		}
+		lf = lf[e.len*l.bs:]
+	}
+
+	// Journal.
+	for i, e := range jBlocks {
+		if err := zero(w, int64(e.start)*bs, int64(e.len)*bs); err != nil {
+			return err
+		}
+		if i > 0 {
+			continue
+		}
+		js := make([]byte, l.bs)
+		be := binary.BigEndian
+		be.PutUint32(js[0:], jbd2Magic)
+		be.PutUint32(js[4:], jbd2SuperblockV2)
+		be.PutUint32(js[12:], l.bs)
+		be.PutUint32(js[16:], jSize)
+		be.PutUint32(js[20:], 1) // first log block
+		be.PutUint32(js[24:], 1) // first sequence
+		copy(js[48:64], uuid[:])
+		be.PutUint32(js[64:], 1) // users
+		if _, err := w.WriteAt(js, int64(e.start)*bs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
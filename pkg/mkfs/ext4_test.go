@@ -0,0 +1,246 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mkfs
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/probe"
+)
+
+var testUUID = [16]byte{0x21, 0x83, 0xea, 0xd8, 0xa5, 0x10, 0x4b, 0x3d, 0x97, 0x77, 0x19, 0xc7, 0x09, 0x0f, 0x66, 0xd9}
+
+// ext4Volume is what a reader finds on an ext4 file system.
+type ext4Volume struct {
+	bs          int
+	blocks      int
+	groups      int
+	freeInodes  int
+	rootEntries []string
+	journal     int
+}
+
+// ext4Reader reads an ext4 file system the way a driver would.
+type ext4Reader struct {
+	t   *testing.T
+	f   *os.File
+	bs  int64
+	ipg uint32
+	gdt []byte
+}
+
+func (r *ext4Reader) block(n uint32) []byte {
+	return readAt(r.t, r.f, int64(n)*r.bs, int(r.bs))
+}
+
+func (r *ext4Reader) inode(ino uint32) []byte {
+	g, i := (ino-1)/r.ipg, (ino-1)%r.ipg
+	table := binary.LittleEndian.Uint32(r.gdt[g*ext4DescSize+8:])
+	return readAt(r.t, r.f, int64(table)*r.bs+int64(i)*ext4InodeSize, ext4InodeSize)
+}
+
+// extents returns the blocks of an inode with an extent tree of depth 0.
+func (r *ext4Reader) extents(inode []byte) []uint32 {
+	le := binary.LittleEndian
+	if le.Uint32(inode[32:])&ext4ExtentsFlag == 0 {
+		r.t.Fatalf("inode does not use extents")
+	}
+	eh := inode[40:100]
+	if le.Uint16(eh[0:]) != ext4ExtentMagic || le.Uint16(eh[6:]) != 0 {
+		r.t.Fatalf("bad extent header %x", eh[:12])
+	}
+	var blocks []uint32
+	for i := 0; i < int(le.Uint16(eh[2:])); i++ {
+		x := eh[12+12*i:]
+		if int(le.Uint32(x[0:])) != len(blocks) {
+			r.t.Errorf("extent %d starts at logical block %d, want %d", i, le.Uint32(x[0:]), len(blocks))
+		}
+		for b := uint32(0); b < uint32(le.Uint16(x[4:])); b++ {
+			blocks = append(blocks, le.Uint32(x[8:])+b)
+		}
+	}
+	if n := le.Uint32(inode[28:]); int64(n) != int64(len(blocks))*r.bs/512 {
+		r.t.Errorf("inode has i_blocks %d, want %d", n, int64(len(blocks))*r.bs/512)
+	}
+	return blocks
+}
+
+func countZeroBits(b []byte, n uint32) int {
+	var z int
+	for i := uint32(0); i < n; i++ {
+		if b[i/8]&(1<<(i%8)) == 0 {
+			z++
+		}
+	}
+	return z
+}
+
+func readExt4(t *testing.T, f *os.File) ext4Volume {
+	t.Helper()
+	le := binary.LittleEndian
+	sb := readAt(t, f, ext4SuperOff, ext4SuperSize)
+	if le.Uint16(sb[56:]) != ext4Magic {
+		t.Fatalf("no ext4 superblock")
+	}
+	r := &ext4Reader{t: t, f: f, bs: 1024 << le.Uint32(sb[24:]), ipg: le.Uint32(sb[40:])}
+	blocks := le.Uint32(sb[4:])
+	firstData := le.Uint32(sb[20:])
+	bpg := le.Uint32(sb[32:])
+	groups := (blocks - firstData + bpg - 1) / bpg
+	if le.Uint32(sb[0:]) != groups*r.ipg {
+		t.Errorf("%d inodes in %d groups of %d", le.Uint32(sb[0:]), groups, r.ipg)
+	}
+	r.gdt = readAt(t, f, int64(firstData+1)*r.bs, int(groups*ext4DescSize))
+
+	// The free counts in the superblock and group descriptors match the
+	// bitmaps, and the superblock backups are where sparse_super puts
+	// them.
+	var freeBlocks, freeInodes int
+	for g := uint32(0); g < groups; g++ {
+		d := r.gdt[g*ext4DescSize:]
+		start := firstData + g*bpg
+		n := bpg
+		if blocks-start < n {
+			n = blocks - start
+		}
+		fb := countZeroBits(r.block(le.Uint32(d[0:])), n)
+		fi := countZeroBits(r.block(le.Uint32(d[4:])), r.ipg)
+		if fb != int(le.Uint16(d[12:])) || fi != int(le.Uint16(d[14:])) {
+			t.Errorf("group %d: bitmaps have %d free blocks and %d free inodes, descriptor says %d and %d",
+				g, fb, fi, le.Uint16(d[12:]), le.Uint16(d[14:]))
+		}
+		freeBlocks += fb
+		freeInodes += fi
+
+		backup := g <= 1 || isPowerOf(g, 3) || isPowerOf(g, 5) || isPowerOf(g, 7)
+		if g > 0 {
+			b := r.block(start)
+			if got := le.Uint16(b[56:]) == ext4Magic; got != backup {
+				t.Errorf("group %d has a superblock backup: %v, want %v", g, got, backup)
+			}
+			if backup && le.Uint16(b[90:]) != uint16(g) {
+				t.Errorf("superblock backup in group %d says it is in group %d", g, le.Uint16(b[90:]))
+			}
+		}
+	}
+	if int(le.Uint32(sb[12:])) != freeBlocks || int(le.Uint32(sb[16:])) != freeInodes {
+		t.Errorf("superblock has %d free blocks and %d free inodes, bitmaps have %d and %d",
+			le.Uint32(sb[12:]), le.Uint32(sb[16:]), freeBlocks, freeInodes)
+	}
+
+	v := ext4Volume{bs: int(r.bs), blocks: int(blocks), groups: int(groups), freeInodes: freeInodes}
+
+	// The root directory holds lost+found.
+	root := r.inode(ext4RootIno)
+	if le.Uint16(root[0:]) != ext4ModeRoot || le.Uint16(root[26:]) != 3 {
+		t.Errorf("root inode has mode %o and %d links", le.Uint16(root[0:]), le.Uint16(root[26:]))
+	}
+	dir := r.block(r.extents(root)[0])
+	for off := 0; off < len(dir); {
+		recLen := int(le.Uint16(dir[off+4:]))
+		if recLen < 8 {
+			t.Fatalf("directory entry at %d has length %d", off, recLen)
+		}
+		v.rootEntries = append(v.rootEntries, string(dir[off+8:off+8+int(dir[off+6])]))
+		off += recLen
+	}
+	lf := r.inode(ext4LostFound)
+	if le.Uint16(lf[0:]) != ext4ModeLostFound || len(r.extents(lf))*int(r.bs) != int(le.Uint32(lf[4:])) {
+		t.Errorf("lost+found inode has mode %o and size %d", le.Uint16(lf[0:]), le.Uint32(lf[4:]))
+	}
+
+	if le.Uint32(sb[92:])&ext4CompatHasJournal != 0 {
+		ji := r.inode(le.Uint32(sb[224:]))
+		jb := r.extents(ji)
+		v.journal = len(jb)
+		js := r.block(jb[0])
+		be := binary.BigEndian
+		if be.Uint32(js[0:]) != jbd2Magic || be.Uint32(js[16:]) != uint32(len(jb)) {
+			t.Errorf("journal superblock has magic %#x and %d blocks, want %d", be.Uint32(js[0:]), be.Uint32(js[16:]), len(jb))
+		}
+		if string(sb[268:328]) != string(ji[40:100]) {
+			t.Errorf("superblock's journal backup differs from the inode")
+		}
+	}
+	return v
+}
+
+func TestExt4(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		size int64
+		o    Ext4Options
+		want ext4Volume
+	}{
+		{"small", 8 << 20, Ext4Options{}, ext4Volume{bs: 4096, blocks: 2048, groups: 1, freeInodes: 501, journal: 0}},
+		{"journal", 64 << 20, Ext4Options{Journal: true}, ext4Volume{bs: 4096, blocks: 16384, groups: 1, freeInodes: 4085, journal: 1024}},
+		{"1k blocks", 64 << 20, Ext4Options{BlockSize: 1024, Journal: true}, ext4Volume{bs: 1024, blocks: 65536, groups: 8, freeInodes: 4085, journal: 4096}},
+		{"2k blocks", 300 << 20, Ext4Options{BlockSize: 2048, InodeRatio: 4096, Journal: true, JournalBlocks: 20000},
+			ext4Volume{bs: 2048, blocks: 153600, groups: 10, freeInodes: 76789, journal: 20000}},
+		{"many groups", 5 << 30, Ext4Options{Journal: true}, ext4Volume{bs: 4096, blocks: 1310720, groups: 40, freeInodes: 327669, journal: 16384}},
+		{"short last group", 128<<20 + 4096*100, Ext4Options{}, ext4Volume{bs: 4096, blocks: 32768, groups: 1, freeInodes: 8181}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tempImage(t, tt.size)
+			defer os.Remove(f.Name())
+			defer f.Close()
+
+			tt.o.Label = "myext4"
+			tt.o.UUID = testUUID
+			if err := Ext4(f, tt.size, tt.o); err != nil {
+				t.Fatal(err)
+			}
+			tt.want.rootEntries = []string{".", "..", "lost+found"}
+			if got := readExt4(t, f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Ext4 = %+v, want %+v", got, tt.want)
+			}
+
+			r, err := probe.Probe(f, tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Type != "ext4" || r.UUID != "2183ead8-a510-4b3d-9777-19c7090f66d9" || r.Label != "myext4" {
+				t.Errorf("probe.Probe = %+v, want ext4 labeled myext4", r)
+			}
+
+			// e2fsck is the reference, if it is there.
+			if _, err := exec.LookPath("e2fsck"); err != nil {
+				return
+			}
+			if out, err := exec.Command("e2fsck", "-fn", f.Name()).CombinedOutput(); err != nil {
+				t.Errorf("e2fsck: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestExt4Errors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		size int64
+		o    Ext4Options
+	}{
+		{"too small", 64 << 10, Ext4Options{}},
+		{"block size", 8 << 20, Ext4Options{BlockSize: 8192}},
+		{"inode ratio", 8 << 20, Ext4Options{InodeRatio: 512}},
+		{"long label", 8 << 20, Ext4Options{Label: "0123456789abcdefg"}},
+		{"too small for a journal", 4 << 20, Ext4Options{Journal: true}},
+		{"journal too large", 8 << 20, Ext4Options{Journal: true, JournalBlocks: 1500}},
+		{"journal too fragmented", 1 << 30, Ext4Options{BlockSize: 1024, Journal: true, JournalBlocks: 100000}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tempImage(t, tt.size)
+			defer os.Remove(f.Name())
+			defer f.Close()
+			if err := Ext4(f, tt.size, tt.o); err == nil {
+				t.Errorf("Ext4(%d, %+v) succeeded", tt.size, tt.o)
+			}
+		})
+	}
+}
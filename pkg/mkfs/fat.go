@@ -0,0 +1,348 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mkfs
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// See the Microsoft FAT specification, "FAT: General Overview of On-Disk
+// Format", for the layout and the cluster size and FAT size computations.
+const (
+	fatSectorSize   = 512
+	fatNumFATs      = 2
+	fatDirEntrySize = 32
+	fatRootEntries  = 512
+	fatMedia        = 0xf8
+
+	// The type of a FAT is determined only by its number of clusters.
+	fat16MinClusters = 4085
+	fat32MinClusters = 65525
+	fat32MaxClusters = 0x0ffffff5
+
+	// FAT32 volumes have their FSInfo sector at 1 and a backup of the
+	// first three sectors at 6.
+	fat32Reserved  = 32
+	fat32FSInfo    = 1
+	fat32Backup    = 6
+	fat32RootClust = 2
+
+	fatAttrVolumeID = 0x08
+
+	// fatNoLabel is written to the boot sector of unlabeled volumes.
+	fatNoLabel = "NO NAME    "
+)
+
+// fatBootCode is placed where the boot sector's jump goes. It asks the
+// BIOS to try the next boot device and halts if it returns.
+var fatBootCode = []byte{
+	0xcd, 0x18, // int $0x18
+	0xf4,       // hlt
+	0xeb, 0xfd, // jmp hlt
+}
+
+// FATOptions describes a FAT file system.
+type FATOptions struct {
+	// Type is 16 or 32. If 0, FAT32 is used for volumes of 512 MiB and
+	// more, as the UEFI spec wants for EFI system partitions, and FAT16
+	// for smaller ones.
+	Type int
+
+	// Label is the volume label, up to 11 characters. It is upper cased.
+	Label string
+
+	// Serial is the volume serial number. If 0, it is random.
+	Serial uint32
+
+	// SectorsPerCluster is a power of 2 up to 128. If 0, it is chosen
+	// from the volume size.
+	SectorsPerCluster int
+
+	// HiddenSectors is the number of sectors before the volume on the
+	// disk, that is the partition's start.
+	HiddenSectors uint32
+
+	// Time is the creation time. If zero, the current time is used.
+	Time time.Time
+}
+
+// fatLayout is where things are on a FAT volume, in sectors.
+type fatLayout struct {
+	fat32       bool
+	sectors     uint32
+	spc         uint32
+	reserved    uint32
+	fatSectors  uint32
+	rootEntries uint32
+	clusters    uint32
+}
+
+func (l *fatLayout) rootSectors() uint32 {
+	return (l.rootEntries*fatDirEntrySize + fatSectorSize - 1) / fatSectorSize
+}
+
+// dataStart returns the first sector of cluster 2.
+func (l *fatLayout) dataStart() uint32 {
+	return l.reserved + fatNumFATs*l.fatSectors + l.rootSectors()
+}
+
+// spcLimits are the sectors per cluster the FAT specification recommends
+// for volumes up to a number of sectors.
+var spcLimits = map[bool][]struct {
+	max uint64
+	spc uint32
+}{
+	false: {{32680, 2}, {262144, 4}, {524288, 8}, {1048576, 16}, {2097152, 32}},
+	true:  {{532480, 1}, {16777216, 8}, {33554432, 16}, {67108864, 32}},
+}
+
+func defaultSPC(sectors uint64, fat32 bool) uint32 {
+	for _, l := range spcLimits[fat32] {
+		if sectors <= l.max {
+			return l.spc
+		}
+	}
+	return 64
+}
+
+// layout computes the FAT size and cluster count for spc.
+func (l *fatLayout) layout(spc uint32) {
+	l.spc = spc
+	// The specification's FAT size computation. It overestimates a bit.
+	n := uint64(l.sectors) - uint64(l.reserved+l.rootSectors())
+	d := uint64(256*spc + fatNumFATs)
+	if l.fat32 {
+		d /= 2
+	}
+	l.fatSectors = uint32((n + d - 1) / d)
+	l.clusters = 0
+	if data := int64(l.sectors) - int64(l.dataStart()); data > 0 {
+		l.clusters = uint32(data / int64(spc))
+	}
+}
+
+// fatGeometry lays out a FAT of the given type on sectors sectors.
+func fatGeometry(sectors uint64, typ, spc int) (*fatLayout, error) {
+	if typ == 0 {
+		typ = 16
+		if sectors >= 512<<20/fatSectorSize {
+			typ = 32
+		}
+	}
+	if typ != 16 && typ != 32 {
+		return nil, fmt.Errorf("FAT%d is not supported", typ)
+	}
+	if sectors > 0xffffffff {
+		return nil, fmt.Errorf("%d sectors are too many for FAT", sectors)
+	}
+	l := &fatLayout{fat32: typ == 32, sectors: uint32(sectors), reserved: 1, rootEntries: fatRootEntries}
+	if l.fat32 {
+		l.reserved, l.rootEntries = fat32Reserved, 0
+	}
+	min, max := uint32(fat16MinClusters), uint32(fat32MinClusters-1)
+	if l.fat32 {
+		min, max = fat32MinClusters, fat32MaxClusters-1
+	}
+
+	if spc != 0 {
+		if spc > 128 || spc&(spc-1) != 0 {
+			return nil, fmt.Errorf("%d sectors per cluster is not a power of 2 up to 128", spc)
+		}
+		l.layout(uint32(spc))
+	} else {
+		// Start with the recommended size and adjust it until the
+		// cluster count is right for the type.
+		for s := defaultSPC(sectors, l.fat32); ; {
+			l.layout(s)
+			if l.clusters < min && s > 1 {
+				s /= 2
+			} else if l.clusters > max && s < 128 {
+				s *= 2
+			} else {
+				break
+			}
+		}
+	}
+	if l.clusters < min || l.clusters > max {
+		return nil, fmt.Errorf("%d sectors with %d sectors per cluster make %d clusters, FAT%d needs %d to %d",
+			sectors, l.spc, l.clusters, typ, min, max)
+	}
+	return l, nil
+}
+
+// fatLabel validates and pads a volume label.
+func fatLabel(s string) (string, error) {
+	if s == "" {
+		return fatNoLabel, nil
+	}
+	s = strings.ToUpper(s)
+	if len(s) > 11 {
+		return "", fmt.Errorf("FAT label %q is longer than 11 characters", s)
+	}
+	for _, c := range s {
+		if c < 0x20 || c > 0x7e || strings.ContainsRune(`"*+,./:;<=>?[\]|`, c) {
+			return "", fmt.Errorf("FAT label %q contains %q", s, c)
+		}
+	}
+	return s + strings.Repeat(" ", 11-len(s)), nil
+}
+
+// fatTime returns t in FAT directory entry format.
+func fatTime(t time.Time) (date, tod uint16) {
+	if t.Year() < 1980 {
+		return 0x21, 0 // 1980-01-01
+	}
+	date = uint16(t.Year()-1980)<<9 | uint16(t.Month())<<5 | uint16(t.Day())
+	tod = uint16(t.Hour())<<11 | uint16(t.Minute())<<5 | uint16(t.Second()/2)
+	return date, tod
+}
+
+// zero writes n zero bytes at off.
+func zero(w io.WriterAt, off, n int64) error {
+	b := make([]byte, 1<<16)
+	for n > 0 {
+		if n < int64(len(b)) {
+			b = b[:n]
+		}
+		if _, err := w.WriteAt(b, off); err != nil {
+			return err
+		}
+		off += int64(len(b))
+		n -= int64(len(b))
+	}
+	return nil
+}
+
+// FAT writes an empty FAT file system of size bytes to w.
+func FAT(w io.WriterAt, size int64, o FATOptions) error {
+	l, err := fatGeometry(uint64(size/fatSectorSize), o.Type, o.SectorsPerCluster)
+	if err != nil {
+		return err
+	}
+	label, err := fatLabel(o.Label)
+	if err != nil {
+		return err
+	}
+	serial := o.Serial
+	if serial == 0 {
+		var b [4]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return err
+		}
+		serial = binary.LittleEndian.Uint32(b[:])
+	}
+	t := o.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	// Boot sector and BIOS parameter block.
+	bs := make([]byte, fatSectorSize)
+	copy(bs[3:], "u-root  ")
+	binary.LittleEndian.PutUint16(bs[11:], fatSectorSize)
+	bs[13] = byte(l.spc)
+	binary.LittleEndian.PutUint16(bs[14:], uint16(l.reserved))
+	bs[16] = fatNumFATs
+	binary.LittleEndian.PutUint16(bs[17:], uint16(l.rootEntries))
+	if l.sectors < 1<<16 {
+		binary.LittleEndian.PutUint16(bs[19:], uint16(l.sectors))
+	} else {
+		binary.LittleEndian.PutUint32(bs[32:], l.sectors)
+	}
+	bs[21] = fatMedia
+	binary.LittleEndian.PutUint16(bs[24:], 63)  // sectors per track
+	binary.LittleEndian.PutUint16(bs[26:], 255) // heads
+	binary.LittleEndian.PutUint32(bs[28:], o.HiddenSectors)
+	// The extended BPB is at 36 on FAT16 and at 64 on FAT32.
+	ext, fsType, firstEntries := 36, "FAT16   ", []uint32{0xff00 | fatMedia, 0xffff}
+	if l.fat32 {
+		binary.LittleEndian.PutUint32(bs[36:], l.fatSectors)
+		binary.LittleEndian.PutUint32(bs[44:], fat32RootClust)
+		binary.LittleEndian.PutUint16(bs[48:], fat32FSInfo)
+		binary.LittleEndian.PutUint16(bs[50:], fat32Backup)
+		ext, fsType = 64, "FAT32   "
+		// The root directory takes cluster 2.
+		firstEntries = []uint32{0x0fffff00 | fatMedia, 0x0fffffff, 0x0fffffff}
+	} else {
+		binary.LittleEndian.PutUint16(bs[22:], uint16(l.fatSectors))
+	}
+	bs[ext] = 0x80 // drive number
+	bs[ext+2] = 0x29
+	binary.LittleEndian.PutUint32(bs[ext+3:], serial)
+	copy(bs[ext+7:], label)
+	copy(bs[ext+18:], fsType)
+	code := ext + 26
+	bs[0], bs[1], bs[2] = 0xeb, byte(code-2), 0x90
+	copy(bs[code:], fatBootCode)
+	bs[510], bs[511] = 0x55, 0xaa
+
+	// Clear the reserved sectors, the FATs and the root directory.
+	rootSize := int64(l.rootSectors())
+	if l.fat32 {
+		rootSize = int64(l.spc)
+	}
+	if err := zero(w, 0, (int64(l.dataStart())+rootSize)*fatSectorSize); err != nil {
+		return err
+	}
+
+	boot := [][]byte{bs}
+	if l.fat32 {
+		fsinfo := make([]byte, fatSectorSize)
+		binary.LittleEndian.PutUint32(fsinfo[0:], 0x41615252)
+		binary.LittleEndian.PutUint32(fsinfo[484:], 0x61417272)
+		binary.LittleEndian.PutUint32(fsinfo[488:], l.clusters-1)
+		binary.LittleEndian.PutUint32(fsinfo[492:], fat32RootClust+1)
+		binary.LittleEndian.PutUint32(fsinfo[508:], 0xaa550000)
+		third := make([]byte, fatSectorSize)
+		third[510], third[511] = 0x55, 0xaa
+		boot = append(boot, fsinfo, third)
+	}
+	for i, s := range boot {
+		if _, err := w.WriteAt(s, int64(i)*fatSectorSize); err != nil {
+			return err
+		}
+		if l.fat32 {
+			if _, err := w.WriteAt(s, int64(fat32Backup+i)*fatSectorSize); err != nil {
+				return err
+			}
+		}
+	}
+
+	entSize := 2
+	if l.fat32 {
+		entSize = 4
+	}
+	fat := make([]byte, len(firstEntries)*entSize)
+	for i, e := range firstEntries {
+		if l.fat32 {
+			binary.LittleEndian.PutUint32(fat[4*i:], e)
+		} else {
+			binary.LittleEndian.PutUint16(fat[2*i:], uint16(e))
+		}
+	}
+	for i := uint32(0); i < fatNumFATs; i++ {
+		if _, err := w.WriteAt(fat, int64(l.reserved+i*l.fatSectors)*fatSectorSize); err != nil {
+			return err
+		}
+	}
+
+	if label == fatNoLabel {
+		return nil
+	}
+	e := make([]byte, fatDirEntrySize)
+	copy(e, label)
+	e[11] = fatAttrVolumeID
+	date, tod := fatTime(t)
+	binary.LittleEndian.PutUint16(e[22:], tod)
+	binary.LittleEndian.PutUint16(e[24:], date)
+	root := l.reserved + fatNumFATs*l.fatSectors
+	_, err = w.WriteAt(e, int64(root)*fatSectorSize)
+	return err
+}
@@ -0,0 +1,236 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mkfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/u-root/u-root/pkg/mount/probe"
+)
+
+// tempImage returns a sparse file of size bytes.
+func tempImage(t *testing.T, size int64) *os.File {
+	t.Helper()
+	f, err := ioutil.TempFile("", "mkfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func readAt(t *testing.T, f *os.File, off int64, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := f.ReadAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// fatVolume is what a reader finds on a FAT volume.
+type fatVolume struct {
+	fat32    bool
+	spc      int
+	clusters int
+	label    string
+}
+
+// readFAT reads a FAT volume the way a driver would, checking its
+// consistency.
+func readFAT(t *testing.T, f *os.File) fatVolume {
+	t.Helper()
+	bs := readAt(t, f, 0, fatSectorSize)
+	le := binary.LittleEndian
+	if bs[0] != 0xeb || bs[510] != 0x55 || bs[511] != 0xaa {
+		t.Fatalf("no boot sector signature")
+	}
+	if code := int(bs[1]) + 2; !bytes.Equal(bs[code:code+len(fatBootCode)], fatBootCode) {
+		t.Errorf("the boot sector's jump does not go to the boot code")
+	}
+	bps := int64(le.Uint16(bs[11:]))
+	spc := int64(bs[13])
+	reserved := int64(le.Uint16(bs[14:]))
+	nfats := int64(bs[16])
+	rootEntries := int64(le.Uint16(bs[17:]))
+	sectors := int64(le.Uint16(bs[19:]))
+	if sectors == 0 {
+		sectors = int64(le.Uint32(bs[32:]))
+	}
+	fatSize := int64(le.Uint16(bs[22:]))
+	if fatSize == 0 {
+		fatSize = int64(le.Uint32(bs[36:]))
+	}
+	if bps != fatSectorSize || nfats != 2 || bs[21] != fatMedia {
+		t.Errorf("BPB has %d bytes per sector, %d FATs, media %#x", bps, nfats, bs[21])
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sectors*bps > fi.Size() {
+		t.Errorf("volume of %d sectors is larger than the image of %d bytes", sectors, fi.Size())
+	}
+
+	rootSectors := (rootEntries*32 + bps - 1) / bps
+	data := reserved + nfats*fatSize + rootSectors
+	clusters := (sectors - data) / spc
+	v := fatVolume{fat32: clusters >= fat32MinClusters, spc: int(spc), clusters: int(clusters)}
+	entSize := int64(2)
+	if v.fat32 {
+		entSize = 4
+	}
+	if (clusters+2)*entSize > fatSize*bps {
+		t.Errorf("FAT of %d sectors is too small for %d clusters", fatSize, clusters)
+	}
+
+	// Both FATs have the reserved entries and, on FAT32, the root
+	// directory's cluster.
+	fats := make([][]byte, nfats)
+	for i := range fats {
+		fats[i] = readAt(t, f, (reserved+int64(i)*fatSize)*bps, int(bps))
+	}
+	if !bytes.Equal(fats[0], fats[1]) {
+		t.Errorf("FATs differ")
+	}
+	rootDir := (reserved + nfats*fatSize) * bps
+	if v.fat32 {
+		if le.Uint32(fats[0][0:]) != 0x0ffffff8 || le.Uint32(fats[0][8:]) < 0x0ffffff8 {
+			t.Errorf("FAT32 starts with %x", fats[0][:12])
+		}
+		rootClust := int64(le.Uint32(bs[44:]))
+		rootDir = (data + (rootClust-2)*spc) * bps
+
+		if !bytes.Equal(bs, readAt(t, f, fat32Backup*bps, int(bps))) {
+			t.Errorf("backup boot sector differs")
+		}
+		fsinfo := readAt(t, f, int64(le.Uint16(bs[48:]))*bps, int(bps))
+		if le.Uint32(fsinfo[0:]) != 0x41615252 || le.Uint32(fsinfo[484:]) != 0x61417272 {
+			t.Errorf("bad FSInfo signatures")
+		}
+		if free := le.Uint32(fsinfo[488:]); int64(free) != clusters-1 {
+			t.Errorf("FSInfo has %d free clusters, want %d", free, clusters-1)
+		}
+		if string(bs[82:90]) != "FAT32   " {
+			t.Errorf("FAT32 type string is %q", bs[82:90])
+		}
+	} else {
+		if le.Uint16(fats[0][0:]) != 0xfff8 || le.Uint16(fats[0][2:]) != 0xffff {
+			t.Errorf("FAT16 starts with %x", fats[0][:4])
+		}
+		if string(bs[54:62]) != "FAT16   " {
+			t.Errorf("FAT16 type string is %q", bs[54:62])
+		}
+	}
+	first := int64(2)
+	if v.fat32 {
+		first = 3
+	}
+	for _, b := range fats[0][first*entSize:] {
+		if b != 0 {
+			t.Errorf("FAT has used clusters")
+			break
+		}
+	}
+
+	// The label is the only root directory entry.
+	root := readAt(t, f, rootDir, 64)
+	if root[0] != 0 {
+		if root[11] != fatAttrVolumeID {
+			t.Errorf("root directory entry has attributes %#x, want a volume label", root[11])
+		}
+		v.label = string(bytes.TrimRight(root[:11], " "))
+	}
+	if root[32] != 0 {
+		t.Errorf("root directory has more than a label")
+	}
+	return v
+}
+
+func TestFAT(t *testing.T) {
+	serial := uint32(0xace55144)
+	for _, tt := range []struct {
+		name string
+		size int64
+		o    FATOptions
+		want fatVolume
+	}{
+		{"fat16", 16 << 20, FATOptions{Label: "esp"}, fatVolume{spc: 4, clusters: 8167, label: "ESP"}},
+		{"fat16 spc", 16 << 20, FATOptions{Type: 16, SectorsPerCluster: 1}, fatVolume{spc: 1, clusters: 32481}},
+		{"fat16 large", 511 << 20, FATOptions{Label: "DATA"}, fatVolume{spc: 16, clusters: 65373, label: "DATA"}},
+		{"fat32", 64 << 20, FATOptions{Type: 32, Label: "EFI SYSTEM"}, fatVolume{fat32: true, spc: 1, clusters: 129008, label: "EFI SYSTEM"}},
+		{"esp", 512 << 20, FATOptions{Label: "ESP"}, fatVolume{fat32: true, spc: 8, clusters: 130812, label: "ESP"}},
+		{"fat32 large", 40 << 30, FATOptions{}, fatVolume{fat32: true, spc: 64, clusters: 1310399}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tempImage(t, tt.size)
+			defer os.Remove(f.Name())
+			defer f.Close()
+
+			tt.o.Serial = serial
+			if err := FAT(f, tt.size, tt.o); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFAT(t, f); got != tt.want {
+				t.Errorf("FAT = %+v, want %+v", got, tt.want)
+			}
+
+			r, err := probe.Probe(f, tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			version := "FAT16"
+			if tt.want.fat32 {
+				version = "FAT32"
+			}
+			if r.Type != "vfat" || r.UUID != "ace5-5144" || r.Label != tt.want.label || r.Version != version {
+				t.Errorf("probe.Probe = %+v, want a %s volume labeled %q with UUID ace5-5144", r, version, tt.want.label)
+			}
+		})
+	}
+}
+
+func TestFATErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		size int64
+		o    FATOptions
+	}{
+		{"too small for FAT16", 1 << 20, FATOptions{}},
+		{"too small for FAT32", 16 << 20, FATOptions{Type: 32}},
+		{"too large for FAT16", 4 << 30, FATOptions{Type: 16}},
+		{"FAT12", 16 << 20, FATOptions{Type: 12}},
+		{"cluster size", 16 << 20, FATOptions{SectorsPerCluster: 3}},
+		{"cluster count", 16 << 20, FATOptions{SectorsPerCluster: 128}},
+		{"long label", 16 << 20, FATOptions{Label: "012345678901"}},
+		{"label character", 16 << 20, FATOptions{Label: "A/B"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tempImage(t, tt.size)
+			defer os.Remove(f.Name())
+			defer f.Close()
+			if err := FAT(f, tt.size, tt.o); err == nil {
+				t.Errorf("FAT(%d, %+v) succeeded", tt.size, tt.o)
+			}
+		})
+	}
+}
+
+func TestFATTime(t *testing.T) {
+	d, tod := fatTime(time.Date(2020, 11, 19, 10, 38, 3, 0, time.UTC))
+	if d != 40<<9|11<<5|19 || tod != 10<<11|38<<5|1 {
+		t.Errorf("fatTime = %#x, %#x", d, tod)
+	}
+	if d, tod := fatTime(time.Unix(0, 0)); d != 0x21 || tod != 0 {
+		t.Errorf("fatTime(1970) = %#x, %#x, want 1980-01-01", d, tod)
+	}
+}
@@ -18,10 +18,12 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf16"
 	"unsafe"
 
 	"github.com/rekby/gpt"
 	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/probe"
 	"github.com/u-root/u-root/pkg/pci"
 	"golang.org/x/sys/unix"
 )
@@ -36,9 +38,20 @@ var (
 
 // BlockDev maps a device name to a BlockStat structure for a given block device
 type BlockDev struct {
-	Name   string
-	FSType string
-	FsUUID string
+	Name    string
+	FSType  string
+	FsUUID  string
+	FsLabel string
+
+	// PartUUID and PartLabel are set for partitions. MBR partitions have
+	// no label, and a PartUUID made of the disk signature and the
+	// partition number.
+	PartUUID  string
+	PartLabel string
+
+	// DMName is the name of a device-mapper device, e.g. "vg0-root"
+	// for an LVM2 logical volume.
+	DMName string
 }
 
 // Device makes sure the block device exists and returns a handle to it.
@@ -51,11 +64,67 @@ func Device(maybeDevpath string) (*BlockDev, error) {
 		return nil, err
 	}
 
-	devpath := filepath.Join("/dev/", devname)
-	if uuid, err := getFSUUID(devpath); err == nil {
-		return &BlockDev{Name: devname, FsUUID: uuid}, nil
+	b := &BlockDev{Name: devname}
+	if r, err := probe.File(b.DevicePath()); err == nil {
+		b.FSType, b.FsUUID, b.FsLabel = r.Type, r.UUID, r.Label
+	}
+	b.PartUUID, b.PartLabel = partInfo(devname)
+	if n, err := ioutil.ReadFile(filepath.Join("/sys/class/block", devname, "dm", "name")); err == nil {
+		b.DMName = strings.TrimSpace(string(n))
 	}
-	return &BlockDev{Name: devname}, nil
+	return b, nil
+}
+
+// partInfo returns the partition UUID and label of devname from its disk's
+// partition table, or nothing if devname is not a partition.
+func partInfo(devname string) (uuid, label string) {
+	sysPath := filepath.Join("/sys/class/block", devname)
+	n, err := ioutil.ReadFile(filepath.Join(sysPath, "partition"))
+	if err != nil {
+		return "", ""
+	}
+	num, err := strconv.Atoi(strings.TrimSpace(string(n)))
+	if err != nil || num < 1 {
+		return "", ""
+	}
+	// The partition's sysfs directory is inside its disk's.
+	p, err := filepath.EvalSymlinks(sysPath)
+	if err != nil {
+		return "", ""
+	}
+	disk := &BlockDev{Name: filepath.Base(filepath.Dir(p))}
+
+	if table, err := disk.GPTTable(); err == nil {
+		if num > len(table.Partitions) {
+			return "", ""
+		}
+		part := table.Partitions[num-1]
+		return strings.ToLower(part.Id.String()), partName(part.PartNameUTF16[:])
+	}
+
+	f, err := os.Open(disk.DevicePath())
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	mbr := make([]byte, 512)
+	if _, err := f.ReadAt(mbr, 0); err != nil || mbr[510] != 0x55 || mbr[511] != 0xaa {
+		return "", ""
+	}
+	return fmt.Sprintf("%08x-%02x", binary.LittleEndian.Uint32(mbr[440:]), num), ""
+}
+
+// partName decodes a GPT partition name, which is NUL-padded UTF-16LE.
+func partName(b []byte) string {
+	var u []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
 }
 
 // String implements fmt.Stringer.
@@ -80,7 +149,11 @@ func (b *BlockDev) DevName() string {
 func (b *BlockDev) Mount(path string, flags uintptr) (*mount.MountPoint, error) {
 	devpath := filepath.Join("/dev", b.Name)
 	if len(b.FSType) > 0 {
-		return mount.Mount(devpath, path, b.FSType, "", flags)
+		// The kernel may know the filesystem under another name, e.g.
+		// ext2 as ext4, which TryMount takes care of.
+		if mp, err := mount.Mount(devpath, path, b.FSType, "", flags); err == nil {
+			return mp, nil
+		}
 	}
 
 	return mount.TryMount(devpath, path, "", flags)
@@ -269,166 +342,6 @@ func GetBlockDevices() (BlockDevices, error) {
 	return blockdevs, nil
 }
 
-func getFSUUID(devpath string) (string, error) {
-	file, err := os.Open(devpath)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	fsuuid, err := tryFAT32(file)
-	if err == nil {
-		return fsuuid, nil
-	}
-	fsuuid, err = tryFAT16(file)
-	if err == nil {
-		return fsuuid, nil
-	}
-	fsuuid, err = tryEXT4(file)
-	if err == nil {
-		return fsuuid, nil
-	}
-	fsuuid, err = tryXFS(file)
-	if err == nil {
-		return fsuuid, nil
-	}
-	return "", fmt.Errorf("unknown UUID (not vfat, ext4, nor xfs)")
-}
-
-// See https://www.nongnu.org/ext2-doc/ext2.html#DISK-ORGANISATION.
-const (
-	// Offset of superblock in partition.
-	ext2SprblkOff = 1024
-
-	// Offset of magic number in suberblock.
-	ext2SprblkMagicOff  = 56
-	ext2SprblkMagicSize = 2
-
-	ext2SprblkMagic = 0xEF53
-
-	// Offset of UUID in superblock.
-	ext2SprblkUUIDOff  = 104
-	ext2SprblkUUIDSize = 16
-)
-
-func tryEXT4(file io.ReaderAt) (string, error) {
-	var off int64
-
-	// Read magic number.
-	b := make([]byte, ext2SprblkMagicSize)
-	off = ext2SprblkOff + ext2SprblkMagicOff
-	if _, err := file.ReadAt(b, off); err != nil {
-		return "", err
-	}
-	magic := binary.LittleEndian.Uint16(b[:2])
-	if magic != ext2SprblkMagic {
-		return "", fmt.Errorf("ext4 magic not found")
-	}
-
-	// Filesystem UUID.
-	b = make([]byte, ext2SprblkUUIDSize)
-	off = ext2SprblkOff + ext2SprblkUUIDOff
-	if _, err := file.ReadAt(b, off); err != nil {
-		return "", err
-	}
-
-	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
-}
-
-// See https://de.wikipedia.org/wiki/File_Allocation_Table#Aufbau.
-const (
-	fat12Magic = "FAT12   "
-	fat16Magic = "FAT16   "
-
-	// Offset of magic number.
-	fat16MagicOff  = 0x36
-	fat16MagicSize = 8
-
-	// Offset of filesystem ID / serial number. Treated as short filesystem UUID.
-	fat16IDOff  = 0x27
-	fat16IDSize = 4
-)
-
-func tryFAT16(file io.ReaderAt) (string, error) {
-	// Read magic number.
-	b := make([]byte, fat16MagicSize)
-	if _, err := file.ReadAt(b, fat16MagicOff); err != nil {
-		return "", err
-	}
-	magic := string(b)
-	if magic != fat16Magic && magic != fat12Magic {
-		return "", fmt.Errorf("fat16 magic not found")
-	}
-
-	// Filesystem UUID.
-	b = make([]byte, fat16IDSize)
-	if _, err := file.ReadAt(b, fat16IDOff); err != nil {
-		return "", err
-	}
-
-	return fmt.Sprintf("%02x%02x-%02x%02x", b[3], b[2], b[1], b[0]), nil
-}
-
-// See https://de.wikipedia.org/wiki/File_Allocation_Table#Aufbau.
-const (
-	fat32Magic = "FAT32   "
-
-	// Offset of magic number.
-	fat32MagicOff  = 0x52
-	fat32MagicSize = 8
-
-	// Offset of filesystem ID / serial number. Treated as short filesystem UUID.
-	fat32IDOff  = 67
-	fat32IDSize = 4
-)
-
-func tryFAT32(file io.ReaderAt) (string, error) {
-	// Read magic number.
-	b := make([]byte, fat32MagicSize)
-	if _, err := file.ReadAt(b, fat32MagicOff); err != nil {
-		return "", err
-	}
-	magic := string(b)
-	if magic != fat32Magic {
-		return "", fmt.Errorf("fat32 magic not found")
-	}
-
-	// Filesystem UUID.
-	b = make([]byte, fat32IDSize)
-	if _, err := file.ReadAt(b, fat32IDOff); err != nil {
-		return "", err
-	}
-
-	return fmt.Sprintf("%02x%02x-%02x%02x", b[3], b[2], b[1], b[0]), nil
-}
-
-const (
-	xfsMagic     = "XFSB"
-	xfsMagicSize = 4
-	xfsUUIDOff   = 32
-	xfsUUIDSize  = 16
-)
-
-func tryXFS(file io.ReaderAt) (string, error) {
-	// Read magic number.
-	b := make([]byte, xfsMagicSize)
-	if _, err := file.ReadAt(b, 0); err != nil {
-		return "", err
-	}
-	magic := string(b)
-	if magic != xfsMagic {
-		return "", fmt.Errorf("xfs magic not found")
-	}
-
-	// Filesystem UUID.
-	b = make([]byte, xfsUUIDSize)
-	if _, err := file.ReadAt(b, xfsUUIDOff); err != nil {
-		return "", err
-	}
-
-	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
-}
-
 // BlockDevices is a list of block devices.
 type BlockDevices []*BlockDev
 
@@ -520,13 +433,57 @@ func (b BlockDevices) FilterNames(names ...string) BlockDevices {
 func (b BlockDevices) FilterFSUUID(fsuuid string) BlockDevices {
 	partitions := make(BlockDevices, 0)
 	for _, device := range b {
-		if device.FsUUID == fsuuid {
+		// vfat and NTFS serial numbers are often written in upper case.
+		if strings.EqualFold(device.FsUUID, fsuuid) {
 			partitions = append(partitions, device)
 		}
 	}
 	return partitions
 }
 
+// FilterFSLabel returns a list of BlockDev objects whose underlying block
+// device has a filesystem with the given label.
+func (b BlockDevices) FilterFSLabel(label string) BlockDevices {
+	var devices BlockDevices
+	for _, device := range b {
+		if device.FsLabel == label {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// Lookup returns the block device a fstab(5) style spec names: UUID=,
+// LABEL=, PARTUUID= or PARTLABEL=, or a device path such as /dev/sda1.
+// Unlike FilterPartLabel, the partition fields found by Device are used,
+// so udev's /dev/disk links are not needed.
+func (b BlockDevices) Lookup(spec string) (*BlockDev, error) {
+	var found BlockDevices
+	kv := strings.SplitN(spec, "=", 2)
+	switch kv[0] {
+	case "UUID":
+		found = b.FilterFSUUID(kv[1])
+	case "LABEL":
+		found = b.FilterFSLabel(kv[1])
+	case "PARTUUID", "PARTLABEL":
+		for _, device := range b {
+			if kv[0] == "PARTUUID" && strings.EqualFold(device.PartUUID, kv[1]) ||
+				kv[0] == "PARTLABEL" && device.PartLabel == kv[1] {
+				found = append(found, device)
+			}
+		}
+	default:
+		found = b.FilterNames(spec)
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no block device matches %q", spec)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("%d block devices match %q: %v", len(found), spec, found)
+}
+
 // filterUsingSymlink resolves the given symlink and filters out all block
 // devices which do not match the resolved symlink. The intended purpose is to
 // filter using a symlink like "/dev/disk/by-partlabel/UBUNTU".
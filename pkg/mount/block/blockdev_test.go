@@ -110,3 +110,37 @@ func TestParsePCIBlockList(t *testing.T) {
 		})
 	}
 }
+
+func TestLookup(t *testing.T) {
+	devs := BlockDevices{
+		{Name: "sda1", FSType: "vfat", FsUUID: "A1B2-C3D4", FsLabel: "EFI", PartUUID: "0e2f1c47-7d63-4a55-9a1f-6b4c0d1e2f30", PartLabel: "EFI System"},
+		{Name: "sda2", FSType: "ext4", FsUUID: "2c6e4f3a-9f41-4b1e-8d57-37a3c2e5f0a1", FsLabel: "root", PartUUID: "5f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b"},
+		{Name: "sdb1", FSType: "ext4", FsUUID: "7d6c5b4a-3928-1706-f5e4-d3c2b1a09f8e", FsLabel: "root", PartUUID: "12345678-01"},
+	}
+	for _, tt := range []struct {
+		spec string
+		want string
+		err  bool
+	}{
+		{spec: "UUID=a1b2-c3d4", want: "sda1"},
+		{spec: "UUID=2c6e4f3a-9f41-4b1e-8d57-37a3c2e5f0a1", want: "sda2"},
+		{spec: "LABEL=EFI", want: "sda1"},
+		{spec: "LABEL=root", err: true},
+		{spec: "LABEL=home", err: true},
+		{spec: "PARTUUID=0E2F1C47-7D63-4A55-9A1F-6B4C0D1E2F30", want: "sda1"},
+		{spec: "PARTUUID=12345678-01", want: "sdb1"},
+		{spec: "PARTLABEL=EFI System", want: "sda1"},
+		{spec: "/dev/sdb1", want: "sdb1"},
+		{spec: "sda2", want: "sda2"},
+		{spec: "/dev/sdc1", err: true},
+	} {
+		got, err := devs.Lookup(tt.spec)
+		if (err != nil) != tt.err {
+			t.Errorf("Lookup(%q): got err %v, want err %v", tt.spec, err, tt.err)
+			continue
+		}
+		if err == nil && got.Name != tt.want {
+			t.Errorf("Lookup(%q): got %s, want %s", tt.spec, got.Name, tt.want)
+		}
+	}
+}
@@ -52,3 +52,32 @@ func internalGetFilesystems(file string) (fstypes []string, err error) {
 	}
 	return fstypes, nil
 }
+
+// kernelNames are the file system types the kernel may know a probed type
+// by, in the order to try them.
+var kernelNames = map[string][]string{
+	"ext2": {"ext2", "ext4"},
+	"ext3": {"ext3", "ext4"},
+	"ntfs": {"ntfs3", "ntfs"},
+}
+
+// preferFilesystems returns fstypes with the kernel names of probed moved
+// to the front. They are added even if missing from fstypes, as mounting
+// may load the module.
+func preferFilesystems(fstypes []string, probed string) []string {
+	first, ok := kernelNames[probed]
+	if !ok {
+		first = []string{probed}
+	}
+	fs := append([]string{}, first...)
+	for _, t := range fstypes {
+		found := false
+		for _, f := range first {
+			found = found || f == t
+		}
+		if !found {
+			fs = append(fs, t)
+		}
+	}
+	return fs
+}
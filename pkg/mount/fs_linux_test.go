@@ -59,3 +59,20 @@ nodev   ramfs
 		})
 	}
 }
+
+func TestPreferFilesystems(t *testing.T) {
+	fstypes := []string{"vfat", "ext4", "ext3", "xfs"}
+	for _, tt := range []struct {
+		probed string
+		want   []string
+	}{
+		{"xfs", []string{"xfs", "vfat", "ext4", "ext3"}},
+		{"ext3", []string{"ext3", "ext4", "vfat", "xfs"}},
+		{"ext2", []string{"ext2", "ext4", "vfat", "ext3", "xfs"}},
+		{"btrfs", []string{"btrfs", "vfat", "ext4", "ext3", "xfs"}},
+	} {
+		if got := preferFilesystems(fstypes, tt.probed); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("preferFilesystems(%q, %q) = %q, want %q", fstypes, tt.probed, got, tt.want)
+		}
+	}
+}
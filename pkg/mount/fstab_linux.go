@@ -0,0 +1,98 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FstabPath is the file system table.
+var FstabPath = "/etc/fstab"
+
+// FstabEntry is a line of fstab(5).
+type FstabEntry struct {
+	// Spec is the device or file system: a path, UUID=, LABEL=,
+	// PARTUUID= or PARTLABEL=, or e.g. "proc" for virtual file systems.
+	Spec string
+
+	// File is the mount point, or "none" for swap.
+	File string
+
+	// VFSType is the file system type, or "auto".
+	VFSType string
+
+	// MntOps are the mount options.
+	MntOps string
+
+	Freq   int
+	PassNo int
+}
+
+// Options parses the entry's mount options.
+func (e *FstabEntry) Options() (*Options, error) {
+	return ParseOptions(e.MntOps)
+}
+
+// ParseFstab parses fstab(5). As in util-linux, the type, options, freq
+// and passno fields may be left out.
+func ParseFstab(r io.Reader) ([]*FstabEntry, error) {
+	var es []*FstabEntry
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || l[0] == '#' {
+			continue
+		}
+		f := strings.Fields(l)
+		if len(f) < 2 || len(f) > 6 {
+			return nil, fmt.Errorf("fstab line %d: want 2 to 6 fields, got %d", line, len(f))
+		}
+		e := &FstabEntry{Spec: unescape(f[0]), File: unescape(f[1]), VFSType: "auto", MntOps: "defaults"}
+		if len(f) > 2 {
+			e.VFSType = f[2]
+		}
+		if len(f) > 3 {
+			e.MntOps = f[3]
+		}
+		var err error
+		if len(f) > 4 {
+			e.Freq, err = strconv.Atoi(f[4])
+		}
+		if len(f) > 5 && err == nil {
+			e.PassNo, err = strconv.Atoi(f[5])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fstab line %d: %v", line, err)
+		}
+		es = append(es, e)
+	}
+	return es, s.Err()
+}
+
+// GetFstab reads FstabPath.
+func GetFstab() ([]*FstabEntry, error) {
+	f, err := os.Open(FstabPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseFstab(f)
+}
+
+// FindFstab returns the last entry whose spec or mount point is s, as
+// mount(8) does when given only one of them, or nil.
+func FindFstab(es []*FstabEntry, s string) *FstabEntry {
+	for i := len(es) - 1; i >= 0; i-- {
+		if es[i].File == s || es[i].Spec == s {
+			return es[i]
+		}
+	}
+	return nil
+}
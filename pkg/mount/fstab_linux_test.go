@@ -0,0 +1,69 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fstab = `# /etc/fstab: static file system information.
+UUID=2c6e4f3a-9f41-4b1e-8d57-37a3c2e5f0a1 /               ext4    errors=remount-ro 0       1
+LABEL=EFI  /boot/efi       vfat    umask=0077      0       1
+
+/swapfile                                 none            swap    sw              0       0
+proc /proc proc
+/srv/my\040data /mnt/data none bind,ro
+LABEL=EFI /efi
+`
+
+func TestParseFstab(t *testing.T) {
+	es, err := ParseFstab(strings.NewReader(fstab))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*FstabEntry{
+		{Spec: "UUID=2c6e4f3a-9f41-4b1e-8d57-37a3c2e5f0a1", File: "/", VFSType: "ext4", MntOps: "errors=remount-ro", PassNo: 1},
+		{Spec: "LABEL=EFI", File: "/boot/efi", VFSType: "vfat", MntOps: "umask=0077", PassNo: 1},
+		{Spec: "/swapfile", File: "none", VFSType: "swap", MntOps: "sw"},
+		{Spec: "proc", File: "/proc", VFSType: "proc", MntOps: "defaults"},
+		{Spec: "/srv/my data", File: "/mnt/data", VFSType: "none", MntOps: "bind,ro"},
+		{Spec: "LABEL=EFI", File: "/efi", VFSType: "auto", MntOps: "defaults"},
+	}
+	if !reflect.DeepEqual(es, want) {
+		for _, e := range es {
+			t.Logf("%+v", e)
+		}
+		t.Fatalf("ParseFstab: got %d entries, want %d", len(es), len(want))
+	}
+
+	for _, tt := range []struct {
+		s    string
+		want int
+	}{
+		{"/proc", 3},
+		{"proc", 3},
+		{"LABEL=EFI", 5},
+		{"/boot/efi", 1},
+		{"/home", -1},
+	} {
+		got := FindFstab(es, tt.s)
+		if (tt.want < 0 && got != nil) || (tt.want >= 0 && got != es[tt.want]) {
+			t.Errorf("FindFstab(%q) = %+v, want entry %d", tt.s, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"/dev/sda1",
+		"/dev/sda1 / ext4 defaults 0 1 extra",
+		"/dev/sda1 / ext4 defaults x 1",
+		"/dev/sda1 / ext4 defaults 0 y",
+	} {
+		if _, err := ParseFstab(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseFstab(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gpt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// PartTypes maps names to common partition type GUIDs.
+var PartTypes = map[string]GUID{
+	"efi":    MustParseGUID("c12a7328-f81f-11d2-ba4b-00a0c93ec93b"),
+	"bios":   MustParseGUID("21686148-6449-6e6f-744e-656564454649"),
+	"linux":  MustParseGUID("0fc63daf-8483-4772-8e79-3d69d8477de4"),
+	"swap":   MustParseGUID("0657fd6d-a4ab-43c4-84e5-0933c84b4f4f"),
+	"lvm":    MustParseGUID("e6d6d379-f507-44c2-a23c-238f2a3df928"),
+	"raid":   MustParseGUID("a19d880f-05fc-4d3b-a006-743f0f84911e"),
+	"msdata": MustParseGUID("ebd0a0a2-b9e5-4433-87c0-68b6b72699c7"),
+}
+
+// ParseGUID parses a GUID in its canonical textual form, as printed by
+// String.
+func ParseGUID(s string) (GUID, error) {
+	var g GUID
+	p := strings.Split(s, "-")
+	if len(p) != 5 || len(p[0]) != 8 || len(p[1]) != 4 || len(p[2]) != 4 || len(p[3]) != 4 || len(p[4]) != 12 {
+		return g, fmt.Errorf("%q is not a GUID", s)
+	}
+	b, err := hex.DecodeString(strings.Join(p, ""))
+	if err != nil {
+		return g, fmt.Errorf("%q is not a GUID: %v", s, err)
+	}
+	g.L = binary.BigEndian.Uint32(b[0:])
+	g.W1 = binary.BigEndian.Uint16(b[4:])
+	g.W2 = binary.BigEndian.Uint16(b[6:])
+	copy(g.B[:], b[8:])
+	return g, nil
+}
+
+// MustParseGUID is ParseGUID for constants. It panics on errors.
+func MustParseGUID(s string) GUID {
+	g, err := ParseGUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// NewGUID returns a random (version 4) GUID.
+func NewGUID() (GUID, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return GUID{}, err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return GUID{
+		L:  binary.BigEndian.Uint32(b[0:]),
+		W1: binary.BigEndian.Uint16(b[4:]),
+		W2: binary.BigEndian.Uint16(b[6:]),
+		B:  [8]byte{b[8], b[9], b[10], b[11], b[12], b[13], b[14], b[15]},
+	}, nil
+}
+
+// IsZero reports whether g is all zeroes, as in unused partition entries.
+func (g GUID) IsZero() bool {
+	return g == GUID{}
+}
+
+// String returns the partition name up to the first NUL.
+func (n *PartName) String() string {
+	u := make([]uint16, 0, len(n)/2)
+	for i := 0; i < len(n); i += 2 {
+		c := binary.LittleEndian.Uint16(n[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+// NewPartName encodes s as a partition name.
+func NewPartName(s string) (PartName, error) {
+	var n PartName
+	u := utf16.Encode([]rune(s))
+	if len(u) > len(n)/2 {
+		return n, fmt.Errorf("partition name %q is longer than %d UTF-16 code units", s, len(n)/2)
+	}
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(n[2*i:], c)
+	}
+	return n, nil
+}
+
+// IsEmpty reports whether the partition entry is unused.
+func (p Part) IsEmpty() bool {
+	return p.PartGUID.IsZero()
+}
+
+// NewTable returns an empty partition table for a disk of the given number
+// of blocks, with a protective MBR, a new disk GUID and MaxNPart entries.
+func NewTable(blocks uint64) (*PartitionTable, error) {
+	// MBR, primary header and entries, backup entries and header.
+	partBlocks := uint64(MaxNPart * 0x80 / BlockSize)
+	if blocks < 2*(partBlocks+1)+2 {
+		return nil, fmt.Errorf("%d blocks are too few for a GPT", blocks)
+	}
+	g, err := NewGUID()
+	if err != nil {
+		return nil, err
+	}
+	h := Header{
+		Signature:  Signature,
+		Revision:   Revision,
+		HeaderSize: HeaderSize,
+		CurrentLBA: 1,
+		BackupLBA:  blocks - 1,
+		FirstLBA:   2 + partBlocks,
+		LastLBA:    blocks - 2 - partBlocks,
+		DiskGUID:   g,
+		PartStart:  2,
+		NPart:      MaxNPart,
+		PartSize:   0x80,
+	}
+	p := &PartitionTable{
+		MasterBootRecord: &MBR{},
+		Primary:          &GPT{Header: h, Parts: make([]Part, MaxNPart)},
+	}
+	p.SyncBackup()
+
+	// The protective MBR covers the whole disk, as far as it can.
+	size := blocks - 1
+	if size > 0xffffffff {
+		size = 0xffffffff
+	}
+	e := p.MasterBootRecord[446:]
+	copy(e, []byte{0x00, 0x00, 0x02, 0x00, 0xee, 0xff, 0xff, 0xff})
+	binary.LittleEndian.PutUint32(e[8:], 1)
+	binary.LittleEndian.PutUint32(e[12:], uint32(size))
+	p.MasterBootRecord[510], p.MasterBootRecord[511] = 0x55, 0xaa
+	return p, nil
+}
+
+// SyncBackup makes the backup GPT a copy of the primary one, which is
+// needed after changing the primary header or partitions.
+func (p *PartitionTable) SyncBackup() {
+	h := p.Primary.Header
+	h.CurrentLBA, h.BackupLBA = h.BackupLBA, h.CurrentLBA
+	h.PartStart = h.LastLBA + 1
+	parts := make([]Part, len(p.Primary.Parts))
+	copy(parts, p.Primary.Parts)
+	p.Backup = &GPT{Header: h, Parts: parts}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gpt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParseGUID(t *testing.T) {
+	for _, s := range []string{
+		"c12a7328-f81f-11d2-ba4b-00a0c93ec93b",
+		"0fc63daf-8483-4772-8e79-3d69d8477de4",
+	} {
+		g, err := ParseGUID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.String() != s {
+			t.Errorf("ParseGUID(%q).String() = %q", s, g.String())
+		}
+	}
+	for _, s := range []string{"", "c12a7328-f81f-11d2-ba4b", "c12a7328f81f11d2ba4b00a0c93ec93b", "g12a7328-f81f-11d2-ba4b-00a0c93ec93b"} {
+		if _, err := ParseGUID(s); err == nil {
+			t.Errorf("ParseGUID(%q) succeeded", s)
+		}
+	}
+}
+
+func TestNewGUID(t *testing.T) {
+	a, err := NewGUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewGUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || a.IsZero() {
+		t.Errorf("NewGUID returned %v and %v", a.String(), b.String())
+	}
+	if s := a.String(); s[14] != '4' {
+		t.Errorf("NewGUID = %v, want version 4", s)
+	}
+}
+
+func TestPartName(t *testing.T) {
+	for _, s := range []string{"", "EFI system partition", "räksmörgås"} {
+		n, err := NewPartName(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := n.String(); got != s {
+			t.Errorf("NewPartName(%q).String() = %q", s, got)
+		}
+	}
+	if _, err := NewPartName("0123456789012345678901234567890123456"); err == nil {
+		t.Errorf("NewPartName with 37 characters succeeded")
+	}
+}
+
+func TestNewTable(t *testing.T) {
+	const blocks = 2048
+	f, err := ioutil.TempFile("", "gpt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := f.Truncate(blocks * BlockSize); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewTable(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Primary.FirstLBA != 34 || p.Primary.LastLBA != blocks-34 || p.Backup.PartStart != blocks-33 {
+		t.Errorf("NewTable(%d) = %v, want usable blocks 34-%d", blocks, p.Primary, blocks-34)
+	}
+	p.Primary.Parts[0] = Part{PartGUID: PartTypes["linux"], FirstLBA: 34, LastLBA: 1000}
+	p.SyncBackup()
+	if err := Write(f, p); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EqualParts(p.Primary, q.Backup); err != nil {
+		t.Error(err)
+	}
+	if q.MasterBootRecord[450] != 0xee {
+		t.Errorf("protective MBR partition type is %#x, want 0xee", q.MasterBootRecord[450])
+	}
+
+	if _, err := NewTable(10); err == nil {
+		t.Errorf("NewTable(10) succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loop
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SysfsPath is where the kernel shows block devices.
+var SysfsPath = "/sys/block"
+
+// List returns the status of the attached loop devices, in order, from
+// sysfs. Device and Inode are not set.
+func List() ([]*Status, error) {
+	ds, err := filepath.Glob(filepath.Join(SysfsPath, "loop*", "loop"))
+	if err != nil {
+		return nil, err
+	}
+	var ss []*Status
+	for _, d := range ds {
+		s, err := sysfsStatus(filepath.Dir(d))
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Number < ss[j].Number })
+	return ss, nil
+}
+
+// FindFile returns the status of the loop devices attached to filename.
+func FindFile(filename string) ([]*Status, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var found []*Status
+	for _, s := range ss {
+		if s.BackingFile == abs || s.BackingFile == filename {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
+func sysfsStatus(dir string) (*Status, error) {
+	name := filepath.Base(dir)
+	n, err := strconv.ParseUint(strings.TrimPrefix(name, "loop"), 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	s := &Status{Dev: filepath.Join("/dev", name), Number: uint32(n)}
+	read := func(file string) string {
+		if err != nil {
+			return ""
+		}
+		var b []byte
+		b, err = ioutil.ReadFile(filepath.Join(dir, file))
+		if os.IsNotExist(err) {
+			// Older kernels lack some attributes.
+			err = nil
+		}
+		return strings.TrimSpace(string(b))
+	}
+	readUint := func(file string) uint64 {
+		v := read(file)
+		if err != nil || v == "" {
+			return 0
+		}
+		var u uint64
+		u, err = strconv.ParseUint(v, 10, 64)
+		return u
+	}
+	s.BackingFile = read("loop/backing_file")
+	s.Offset = readUint("loop/offset")
+	s.SizeLimit = readUint("loop/sizelimit")
+	s.AutoClear = readUint("loop/autoclear") != 0
+	s.PartScan = readUint("loop/partscan") != 0
+	s.DirectIO = readUint("loop/dio") != 0
+	s.ReadOnly = readUint("ro") != 0
+	if bs := readUint("queue/logical_block_size"); bs != 512 {
+		s.BlockSize = uint32(bs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
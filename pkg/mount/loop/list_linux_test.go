@@ -0,0 +1,81 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loop
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "u-root-loop-sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for path, content := range map[string]string{
+		"loop0/ro":                        "0\n",
+		"loop0/queue/logical_block_size":  "512\n",
+		"loop1/ro":                        "0\n",
+		"loop10/loop/backing_file":        "/images/disk with space.img\n",
+		"loop10/loop/offset":              "1048576\n",
+		"loop10/loop/sizelimit":           "0\n",
+		"loop10/loop/autoclear":           "1\n",
+		"loop10/loop/partscan":            "1\n",
+		"loop10/loop/dio":                 "0\n",
+		"loop10/ro":                       "1\n",
+		"loop10/queue/logical_block_size": "4096\n",
+		"loop2/loop/backing_file":         "/tmp/rootfs.squashfs\n",
+		"loop2/loop/offset":               "0\n",
+		"loop2/loop/sizelimit":            "4096\n",
+		"loop2/ro":                        "0\n",
+		"sda/ro":                          "0\n",
+	} {
+		p := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := SysfsPath
+	SysfsPath = dir
+	defer func() { SysfsPath = old }()
+
+	ss, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Status{
+		{
+			Dev:         "/dev/loop2",
+			Number:      2,
+			BackingFile: "/tmp/rootfs.squashfs",
+			Config:      Config{SizeLimit: 4096},
+		},
+		{
+			Dev:         "/dev/loop10",
+			Number:      10,
+			BackingFile: "/images/disk with space.img",
+			Config:      Config{Offset: 1 << 20, ReadOnly: true, PartScan: true, AutoClear: true, BlockSize: 4096},
+		},
+	}
+	if !reflect.DeepEqual(ss, want) {
+		for _, s := range ss {
+			t.Logf("%+v", s)
+		}
+		t.Errorf("List() = %d devices, want %+v %+v", len(ss), want[0], want[1])
+	}
+
+	ss, err = FindFile("/tmp/rootfs.squashfs")
+	if err != nil || len(ss) != 1 || ss[0].Dev != "/dev/loop2" {
+		t.Errorf("FindFile = %+v, %v, want /dev/loop2", ss, err)
+	}
+}
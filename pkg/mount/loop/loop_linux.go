@@ -8,7 +8,13 @@
 package loop
 
 import (
+	"fmt"
+	"io"
+	"os"
+
 	"github.com/u-root/u-root/pkg/mount"
+	"github.com/u-root/u-root/pkg/mount/gpt"
+	"github.com/u-root/u-root/pkg/mount/mbr"
 )
 
 // Loop represents a regular file exposed as a loop block device.
@@ -50,6 +56,69 @@ func New(source, fstype string, data string) (*Loop, error) {
 	}, nil
 }
 
+// NewWithConfig is New with a loop device configured as c says, e.g. with
+// an offset into source or read-only.
+func NewWithConfig(source, fstype, data string, c Config) (*Loop, error) {
+	devicename, err := FindDevice()
+	if err != nil {
+		return nil, err
+	}
+	if err := ConfigureFile(devicename, source, c); err != nil {
+		return nil, err
+	}
+	return &Loop{
+		Dev:    devicename,
+		Source: source,
+		FSType: fstype,
+		Data:   data,
+	}, nil
+}
+
+// NewPartition is NewWithConfig for partition number part, counted from 1,
+// of the disk image source, as its GPT or MBR describes it. The loop device
+// covers only the partition, so no partition scanning is needed.
+func NewPartition(source string, part int, fstype, data string, c Config) (*Loop, error) {
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	off, size, err := findPartition(f, part)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	c.Offset, c.SizeLimit = off, size
+	return NewWithConfig(source, fstype, data, c)
+}
+
+// findPartition returns the offset and size of partition part in a disk
+// image.
+func findPartition(r io.ReaderAt, part int) (uint64, uint64, error) {
+	t, err := mbr.Read(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	if t.IsProtective() {
+		g, err := gpt.Table(r, gpt.HeaderOff)
+		if err != nil {
+			return 0, 0, err
+		}
+		if part < 1 || part > len(g.Parts) || g.Parts[part-1].IsEmpty() {
+			return 0, 0, fmt.Errorf("no GPT partition %d", part)
+		}
+		p := g.Parts[part-1]
+		return p.FirstLBA * gpt.BlockSize, (p.LastLBA - p.FirstLBA + 1) * gpt.BlockSize, nil
+	}
+	p, err := t.Partition(part)
+	if err == nil && (p.IsEmpty() || p.IsExtended()) {
+		err = fmt.Errorf("no partition %d", part)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint64(p.FirstLBA) * mbr.SectorSize, uint64(p.Sectors) * mbr.SectorSize, nil
+}
+
 // DevName implements mount.Mounter.
 func (l *Loop) DevName() string {
 	return l.Dev
@@ -5,24 +5,31 @@
 package loop
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
 const (
 	// Loop ioctl commands --- we will commandeer 0x4C ('L')
-	_LOOP_SET_CAPACITY = 0x4C07
-	_LOOP_CHANGE_FD    = 0x4C06
-	_LOOP_GET_STATUS64 = 0x4C05
-	_LOOP_SET_STATUS64 = 0x4C04
-	_LOOP_GET_STATUS   = 0x4C03
-	_LOOP_SET_STATUS   = 0x4C02
-	_LOOP_CLR_FD       = 0x4C01
-	_LOOP_SET_FD       = 0x4C00
-	_LO_NAME_SIZE      = 64
-	_LO_KEY_SIZE       = 32
+	_LOOP_SET_CAPACITY   = 0x4C07
+	_LOOP_CHANGE_FD      = 0x4C06
+	_LOOP_GET_STATUS64   = 0x4C05
+	_LOOP_SET_STATUS64   = 0x4C04
+	_LOOP_GET_STATUS     = 0x4C03
+	_LOOP_SET_STATUS     = 0x4C02
+	_LOOP_CLR_FD         = 0x4C01
+	_LOOP_SET_FD         = 0x4C00
+	_LOOP_SET_DIRECT_IO  = 0x4C08
+	_LOOP_SET_BLOCK_SIZE = 0x4C09
+	_LOOP_CONFIGURE      = 0x4C0A
+	_LO_NAME_SIZE        = 64
+	_LO_KEY_SIZE         = 32
 
 	// /dev/loop-control interface
 	_LOOP_CTL_ADD      = 0x4C80
@@ -105,3 +112,248 @@ func ClearFile(devicename string) error {
 
 	return ClearFD(int(device.Fd()))
 }
+
+// Config is how a loop device exposes its file.
+type Config struct {
+	// Offset is where the device starts in the file, in bytes.
+	Offset uint64
+
+	// SizeLimit is the size of the device, or 0 for the rest of the
+	// file.
+	SizeLimit uint64
+
+	// ReadOnly makes the device read-only.
+	ReadOnly bool
+
+	// PartScan makes the kernel scan the device for partitions,
+	// which then appear as /dev/loopNpM.
+	PartScan bool
+
+	// AutoClear detaches the device when it is last closed, e.g. when
+	// the file system on it is unmounted.
+	AutoClear bool
+
+	// DirectIO bypasses the page cache for the file.
+	DirectIO bool
+
+	// BlockSize is the logical block size, or 0 for 512.
+	BlockSize uint32
+}
+
+func (c *Config) flags() uint32 {
+	var f uint32
+	if c.ReadOnly {
+		f |= unix.LO_FLAGS_READ_ONLY
+	}
+	if c.PartScan {
+		f |= unix.LO_FLAGS_PARTSCAN
+	}
+	if c.AutoClear {
+		f |= unix.LO_FLAGS_AUTOCLEAR
+	}
+	if c.DirectIO {
+		f |= unix.LO_FLAGS_DIRECT_IO
+	}
+	return f
+}
+
+func (c *Config) info(filename string) unix.LoopInfo64 {
+	info := unix.LoopInfo64{
+		Offset:    c.Offset,
+		Sizelimit: c.SizeLimit,
+		Flags:     c.flags(),
+	}
+	copy(info.File_name[:_LO_NAME_SIZE-1], filename)
+	return info
+}
+
+// loopConfig is struct loop_config, the argument of LOOP_CONFIGURE.
+type loopConfig struct {
+	fd        uint32
+	blockSize uint32
+	info      unix.LoopInfo64
+	_         [8]uint64
+}
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	if _, _, err := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); err != 0 {
+		return err
+	}
+	return nil
+}
+
+// ConfigureFD associates a loop device lfd with a regular file ffd, named
+// filename, as c says.
+//
+// It uses LOOP_CONFIGURE, or on kernels before 5.8 LOOP_SET_FD followed by
+// LOOP_SET_STATUS64, LOOP_SET_DIRECT_IO and LOOP_SET_BLOCK_SIZE. In the
+// latter case c.ReadOnly only takes effect if ffd is opened read-only.
+func ConfigureFD(lfd, ffd int, filename string, c Config) error {
+	lc := loopConfig{
+		fd:        uint32(ffd),
+		blockSize: c.BlockSize,
+		info:      c.info(filename),
+	}
+	err := ioctl(lfd, _LOOP_CONFIGURE, unsafe.Pointer(&lc))
+	if err != unix.EINVAL && err != unix.ENOTTY {
+		return err
+	}
+
+	if err := SetFD(lfd, ffd); err != nil {
+		return err
+	}
+	if err := configure(lfd, filename, c); err != nil {
+		ClearFD(lfd) //nolint:errcheck
+		return err
+	}
+	return nil
+}
+
+func configure(lfd int, filename string, c Config) error {
+	info := c.info(filename)
+	info.Flags &^= unix.LO_FLAGS_READ_ONLY | unix.LO_FLAGS_DIRECT_IO
+	if err := ioctl(lfd, _LOOP_SET_STATUS64, unsafe.Pointer(&info)); err != nil {
+		return fmt.Errorf("LOOP_SET_STATUS64: %v", err)
+	}
+	if c.DirectIO {
+		if err := unix.IoctlSetInt(lfd, _LOOP_SET_DIRECT_IO, 1); err != nil {
+			return fmt.Errorf("LOOP_SET_DIRECT_IO: %v", err)
+		}
+	}
+	if c.BlockSize != 0 {
+		if err := unix.IoctlSetInt(lfd, _LOOP_SET_BLOCK_SIZE, int(c.BlockSize)); err != nil {
+			return fmt.Errorf("LOOP_SET_BLOCK_SIZE: %v", err)
+		}
+	}
+	return nil
+}
+
+// ConfigureFile associates loop device "devicename" with regular file
+// "filename" as c says. Like SetFile, it falls back to a read-only device
+// if filename can not be written.
+func ConfigureFile(devicename, filename string, c Config) error {
+	mode := os.O_RDWR
+	if c.ReadOnly {
+		mode = os.O_RDONLY
+	}
+	file, err := os.OpenFile(filename, mode, 0644)
+	if err != nil && mode == os.O_RDWR {
+		mode = os.O_RDONLY
+		c.ReadOnly = true
+		file, err = os.OpenFile(filename, mode, 0644)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	device, err := os.OpenFile(devicename, mode, 0644)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	// The kernel keeps whatever name it is given; make it absolute as
+	// losetup(8) does.
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	if err := ConfigureFD(int(device.Fd()), int(file.Fd()), filename, c); err != nil {
+		return &os.PathError{Op: "configure", Path: devicename, Err: err}
+	}
+	return nil
+}
+
+// SetStatus changes the offset, size limit, partition scanning and
+// autoclear of the attached loop device "devicename".
+func SetStatus(devicename string, c Config) error {
+	s, err := GetStatus(devicename)
+	if err != nil {
+		return err
+	}
+	device, err := os.Open(devicename)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	return configure(int(device.Fd()), s.BackingFile, c)
+}
+
+// SetCapacity makes the loop device "devicename" pick up a change of the
+// size of its file.
+func SetCapacity(devicename string) error {
+	device, err := os.Open(devicename)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	return unix.IoctlSetInt(int(device.Fd()), _LOOP_SET_CAPACITY, 0)
+}
+
+// ErrNotAttached is returned for loop devices without a file.
+var ErrNotAttached = errors.New("loop device not attached")
+
+// Status is the state of an attached loop device.
+type Status struct {
+	// Dev is the loop device path.
+	Dev string
+
+	// Number is N of /dev/loopN.
+	Number uint32
+
+	// BackingFile is the file the device exposes.
+	BackingFile string
+
+	// Device and Inode identify BackingFile.
+	Device uint64
+	Inode  uint64
+
+	Config
+}
+
+// GetStatus returns the state of the loop device "devicename", or
+// ErrNotAttached.
+func GetStatus(devicename string) (*Status, error) {
+	device, err := os.Open(devicename)
+	if err != nil {
+		return nil, err
+	}
+	defer device.Close()
+
+	var info unix.LoopInfo64
+	if err := ioctl(int(device.Fd()), _LOOP_GET_STATUS64, unsafe.Pointer(&info)); err != nil {
+		if err == unix.ENXIO {
+			return nil, &os.PathError{Op: "status", Path: devicename, Err: ErrNotAttached}
+		}
+		return nil, &os.PathError{Op: "status", Path: devicename, Err: err}
+	}
+	s := &Status{
+		Dev:         devicename,
+		Number:      info.Number,
+		BackingFile: cstring(info.File_name[:]),
+		Device:      info.Device,
+		Inode:       info.Inode,
+		Config: Config{
+			Offset:    info.Offset,
+			SizeLimit: info.Sizelimit,
+			ReadOnly:  info.Flags&unix.LO_FLAGS_READ_ONLY != 0,
+			PartScan:  info.Flags&unix.LO_FLAGS_PARTSCAN != 0,
+			AutoClear: info.Flags&unix.LO_FLAGS_AUTOCLEAR != 0,
+			DirectIO:  info.Flags&unix.LO_FLAGS_DIRECT_IO != 0,
+		},
+	}
+	// The name in loop_info64 is cut at 64 bytes, and the block size is
+	// not in it; sysfs has both.
+	if ss, err := sysfsStatus(filepath.Join(SysfsPath, filepath.Base(devicename))); err == nil && ss.BackingFile != "" {
+		s.BackingFile = ss.BackingFile
+		s.BlockSize = ss.BlockSize
+	}
+	return s, nil
+}
+
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -5,13 +5,17 @@
 package loop
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 
 	"github.com/u-root/u-root/pkg/cp"
+	"github.com/u-root/u-root/pkg/mount/gpt"
+	"github.com/u-root/u-root/pkg/mount/mbr"
 	"golang.org/x/sys/unix"
 )
 
@@ -75,3 +79,167 @@ func TestSetFile(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func sectors(t *testing.T, dev string) string {
+	b, err := ioutil.ReadFile(filepath.Join("/sys/class/block", filepath.Base(dev), "size"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func TestConfigureFile(t *testing.T) {
+	skipIfNotRoot(t)
+
+	f, err := ioutil.TempFile("", "u-root-losetup-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if err := f.Truncate(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	loopdev, err := FindDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Config{Offset: 4096, SizeLimit: 64 << 10, ReadOnly: true}
+	if err := ConfigureFile(loopdev, f.Name(), c); err != nil {
+		t.Fatal(err)
+	}
+	defer ClearFile(loopdev) //nolint:errcheck
+
+	s, err := GetStatus(loopdev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.BackingFile != f.Name() || s.Config != c {
+		t.Errorf("GetStatus(%s) = %+v, want file %s and %+v", loopdev, s, f.Name(), c)
+	}
+	if got := sectors(t, loopdev); got != "128" {
+		t.Errorf("%s: got %s sectors, want 128", loopdev, got)
+	}
+
+	ss, err := FindFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) != 1 || ss[0].Dev != loopdev || ss[0].Config != c {
+		t.Errorf("FindFile(%s) = %+v, want %s", f.Name(), ss, loopdev)
+	}
+
+	c.SizeLimit = 0
+	c.Offset = 0
+	c.ReadOnly = false
+	if err := SetStatus(loopdev, c); err != nil {
+		t.Fatal(err)
+	}
+	if got := sectors(t, loopdev); got != "2048" {
+		t.Errorf("%s after SetStatus: got %s sectors, want 2048", loopdev, got)
+	}
+
+	if err := ClearFile(loopdev); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetStatus(loopdev); !errors.Is(err, ErrNotAttached) {
+		t.Errorf("GetStatus(%s) after ClearFile = %v, want %v", loopdev, err, ErrNotAttached)
+	}
+}
+
+// diskImages writes a disk image with an MBR and one with a GPT, both with
+// partition 2 of 64 sectors at sector 40.
+func diskImages(t *testing.T) (string, string) {
+	dir, err := ioutil.TempDir("", "u-root-losetup-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mbr.Table{}
+	m.Primary[1] = mbr.Partition{Type: mbr.TypeLinux, FirstLBA: 40, Sectors: 64}
+	dos, err := os.Create(filepath.Join(dir, "mbr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dos.Close()
+	if err := dos.Truncate(256 * 512); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write(dos); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := gpt.NewTable(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Primary.Parts[1] = gpt.Part{
+		PartGUID:   gpt.MustParseGUID("0FC63DAF-8483-4772-8E79-3D69D8477DE4"),
+		UniqueGUID: gpt.MustParseGUID("4E7E9A1C-3B2D-4C5E-8F60-718293A4B5C6"),
+		FirstLBA:   40,
+		LastLBA:    103,
+	}
+	g.SyncBackup()
+	efi, err := os.Create(filepath.Join(dir, "gpt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer efi.Close()
+	if err := efi.Truncate(256 * 512); err != nil {
+		t.Fatal(err)
+	}
+	if err := gpt.Write(efi, g); err != nil {
+		t.Fatal(err)
+	}
+	return dos.Name(), efi.Name()
+}
+
+func TestFindPartition(t *testing.T) {
+	dos, efi := diskImages(t)
+	defer os.RemoveAll(filepath.Dir(dos))
+
+	for _, image := range []string{dos, efi} {
+		f, err := os.Open(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		off, size, err := findPartition(f, 2)
+		if err != nil || off != 40*512 || size != 64*512 {
+			t.Errorf("%s: findPartition(2) = %d, %d, %v, want %d, %d, nil", image, off, size, err, 40*512, 64*512)
+		}
+		for _, part := range []int{0, 1, 3, 200} {
+			if _, _, err := findPartition(f, part); err == nil {
+				t.Errorf("%s: findPartition(%d) succeeded, want error", image, part)
+			}
+		}
+	}
+}
+
+func TestNewPartition(t *testing.T) {
+	skipIfNotRoot(t)
+
+	dos, efi := diskImages(t)
+	defer os.RemoveAll(filepath.Dir(dos))
+
+	for _, image := range []string{dos, efi} {
+		l, err := NewPartition(image, 2, "vfat", "", Config{ReadOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := GetStatus(l.Dev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (Config{Offset: 40 * 512, SizeLimit: 64 * 512, ReadOnly: true}); s.Config != want {
+			t.Errorf("%s: got %+v, want %+v", image, s.Config, want)
+		}
+		if got := sectors(t, l.Dev); got != "64" {
+			t.Errorf("%s: got %s sectors, want 64", l.Dev, got)
+		}
+		if err := l.Free(); err != nil {
+			t.Error(err)
+		}
+	}
+}
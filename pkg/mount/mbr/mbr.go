@@ -0,0 +1,354 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mbr reads and writes DOS partition tables.
+//
+// The master boot record in the first sector holds four primary
+// partitions. One of them may be an extended partition holding a chain of
+// extended boot records, each describing one logical partition and
+// pointing to the next. Logical partitions are numbered from 5 in chain
+// order, as Linux does.
+//
+// A GPT disk has a protective MBR with a single partition of type 0xee
+// covering the disk. A hybrid MBR also lists some GPT partitions for
+// systems that only understand MBRs.
+package mbr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// SectorSize is the size of the MBR and EBRs. LBAs are in units of
+	// this size.
+	SectorSize = 512
+
+	// NumPrimary is the number of primary partitions.
+	NumPrimary = 4
+
+	// FirstLogical is the number of the first logical partition.
+	FirstLogical = 5
+
+	bootCodeSize = 440
+	signatureOff = 440
+	tableOff     = 446
+	entrySize    = 16
+	bootMagicOff = 510
+
+	// maxLogical bounds the EBR chain, which can have loops.
+	maxLogical = 128
+)
+
+// Partition types.
+const (
+	TypeEmpty         = 0x00
+	TypeFAT16         = 0x06
+	TypeNTFS          = 0x07
+	TypeFAT32LBA      = 0x0c
+	TypeExtended      = 0x05
+	TypeExtendedLBA   = 0x0f
+	TypeLinuxSwap     = 0x82
+	TypeLinux         = 0x83
+	TypeLinuxExtended = 0x85
+	TypeLinuxLVM      = 0x8e
+	TypeProtective    = 0xee
+	TypeEFI           = 0xef
+	TypeLinuxRAID     = 0xfd
+)
+
+// ErrNoMBR is returned by Read if the first sector has no boot signature.
+var ErrNoMBR = errors.New("no MBR boot signature")
+
+var bootMagic = []byte{0x55, 0xaa}
+
+// Partition is a primary or logical partition. LBAs are absolute, also for
+// logical partitions.
+type Partition struct {
+	Bootable bool
+	Type     byte
+	FirstLBA uint32
+	Sectors  uint32
+
+	// EBR is the LBA of the extended boot record describing a logical
+	// partition. It must be inside the extended partition and before
+	// FirstLBA.
+	EBR uint32
+}
+
+// IsEmpty reports whether the entry is unused.
+func (p Partition) IsEmpty() bool {
+	return p.Type == TypeEmpty || p.Sectors == 0
+}
+
+// IsExtended reports whether p is an extended partition.
+func (p Partition) IsExtended() bool {
+	return p.Type == TypeExtended || p.Type == TypeExtendedLBA || p.Type == TypeLinuxExtended
+}
+
+// LastLBA returns the last sector of p.
+func (p Partition) LastLBA() uint32 {
+	return p.FirstLBA + p.Sectors - 1
+}
+
+// Table is a DOS partition table.
+type Table struct {
+	BootCode      [bootCodeSize]byte
+	DiskSignature uint32
+	Primary       [NumPrimary]Partition
+	Logical       []Partition
+}
+
+// Extended returns the index of the extended partition in t.Primary, or
+// -1.
+func (t *Table) Extended() int {
+	for i, p := range t.Primary {
+		if !p.IsEmpty() && p.IsExtended() {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsProtective reports whether t is the protective MBR of a GPT disk.
+func (t *Table) IsProtective() bool {
+	n, ee := 0, false
+	for _, p := range t.Primary {
+		if !p.IsEmpty() {
+			n++
+			ee = ee || p.Type == TypeProtective
+		}
+	}
+	return ee && n == 1
+}
+
+// IsHybrid reports whether t is a hybrid MBR: a protective partition next
+// to partitions that mirror GPT partitions.
+func (t *Table) IsHybrid() bool {
+	n, ee := 0, false
+	for _, p := range t.Primary {
+		if !p.IsEmpty() {
+			n++
+			ee = ee || p.Type == TypeProtective
+		}
+	}
+	return ee && n > 1
+}
+
+// Partition returns partition n, numbered from 1. Logical partitions start
+// at FirstLogical.
+func (t *Table) Partition(n int) (*Partition, error) {
+	switch {
+	case n >= 1 && n <= NumPrimary:
+		return &t.Primary[n-1], nil
+	case n >= FirstLogical && n < FirstLogical+len(t.Logical):
+		return &t.Logical[n-FirstLogical], nil
+	}
+	return nil, fmt.Errorf("no partition %d", n)
+}
+
+func decodeEntry(b []byte) Partition {
+	return Partition{
+		Bootable: b[0] == 0x80,
+		Type:     b[4],
+		FirstLBA: binary.LittleEndian.Uint32(b[8:]),
+		Sectors:  binary.LittleEndian.Uint32(b[12:]),
+	}
+}
+
+// Read reads the MBR and the EBR chain of any extended partition.
+func Read(r io.ReaderAt) (*Table, error) {
+	s := make([]byte, SectorSize)
+	if _, err := r.ReadAt(s, 0); err != nil {
+		return nil, fmt.Errorf("reading MBR: %v", err)
+	}
+	if s[bootMagicOff] != bootMagic[0] || s[bootMagicOff+1] != bootMagic[1] {
+		return nil, ErrNoMBR
+	}
+	t := &Table{DiskSignature: binary.LittleEndian.Uint32(s[signatureOff:])}
+	copy(t.BootCode[:], s)
+	for i := range t.Primary {
+		t.Primary[i] = decodeEntry(s[tableOff+i*entrySize:])
+	}
+	if e := t.Extended(); e >= 0 {
+		if err := t.readLogical(r, t.Primary[e]); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// readLogical follows the EBR chain. In each EBR, the first entry is the
+// logical partition relative to the EBR, the second points to the next
+// EBR relative to the start of the extended partition.
+func (t *Table) readLogical(r io.ReaderAt, ext Partition) error {
+	seen := map[uint32]bool{}
+	for ebr := ext.FirstLBA; ; {
+		if len(t.Logical) >= maxLogical || seen[ebr] {
+			return fmt.Errorf("EBR chain loops at LBA %d", ebr)
+		}
+		seen[ebr] = true
+		s := make([]byte, SectorSize)
+		if _, err := r.ReadAt(s, int64(ebr)*SectorSize); err != nil {
+			return fmt.Errorf("reading EBR at LBA %d: %v", ebr, err)
+		}
+		if s[bootMagicOff] != bootMagic[0] || s[bootMagicOff+1] != bootMagic[1] {
+			return fmt.Errorf("EBR at LBA %d has no boot signature", ebr)
+		}
+		if p := decodeEntry(s[tableOff:]); !p.IsEmpty() {
+			p.FirstLBA += ebr
+			p.EBR = ebr
+			t.Logical = append(t.Logical, p)
+		}
+		next := decodeEntry(s[tableOff+entrySize:])
+		if next.IsEmpty() {
+			return nil
+		}
+		ebr = ext.FirstLBA + next.FirstLBA
+		if ebr > ext.LastLBA() {
+			return fmt.Errorf("EBR at LBA %d is outside the extended partition", ebr)
+		}
+	}
+}
+
+// chs converts an LBA to the cylinder/head/sector address of the
+// traditional 255 head, 63 sector geometry. Addresses beyond it are
+// written as the maximum, telling readers to use the LBA.
+func chs(lba uint32) [3]byte {
+	const heads, sectors = 255, 63
+	c := lba / (heads * sectors)
+	if c > 1023 {
+		return [3]byte{0xfe, 0xff, 0xff}
+	}
+	h := (lba / sectors) % heads
+	s := lba%sectors + 1
+	return [3]byte{byte(h), byte(s) | byte(c>>8)<<6, byte(c)}
+}
+
+// encodeEntry writes p at b with firstLBA as the start, which is relative
+// for entries in EBRs.
+func encodeEntry(b []byte, p Partition, firstLBA uint32) {
+	if p.IsEmpty() {
+		copy(b[:entrySize], make([]byte, entrySize))
+		return
+	}
+	b[0] = 0
+	if p.Bootable {
+		b[0] = 0x80
+	}
+	start, end := chs(p.FirstLBA), chs(p.LastLBA())
+	copy(b[1:4], start[:])
+	b[4] = p.Type
+	copy(b[5:8], end[:])
+	binary.LittleEndian.PutUint32(b[8:], firstLBA)
+	binary.LittleEndian.PutUint32(b[12:], p.Sectors)
+}
+
+// Write writes the MBR and, if there is an extended partition, the EBR
+// chain. Logical partitions are linked in the order of t.Logical.
+func (t *Table) Write(w io.WriterAt) error {
+	s := make([]byte, SectorSize)
+	copy(s, t.BootCode[:])
+	binary.LittleEndian.PutUint32(s[signatureOff:], t.DiskSignature)
+	for i, p := range t.Primary {
+		encodeEntry(s[tableOff+i*entrySize:], p, p.FirstLBA)
+	}
+	copy(s[bootMagicOff:], bootMagic)
+	if _, err := w.WriteAt(s, 0); err != nil {
+		return fmt.Errorf("writing MBR: %v", err)
+	}
+
+	e := t.Extended()
+	if e < 0 {
+		return nil
+	}
+	ext := t.Primary[e]
+	if len(t.Logical) == 0 {
+		// An empty EBR ends any old chain.
+		s := make([]byte, SectorSize)
+		copy(s[bootMagicOff:], bootMagic)
+		_, err := w.WriteAt(s, int64(ext.FirstLBA)*SectorSize)
+		return err
+	}
+	for i, p := range t.Logical {
+		s := make([]byte, SectorSize)
+		encodeEntry(s[tableOff:], p, p.FirstLBA-p.EBR)
+		if i+1 < len(t.Logical) {
+			n := t.Logical[i+1]
+			link := Partition{Type: TypeExtended, FirstLBA: n.EBR, Sectors: n.LastLBA() - n.EBR + 1}
+			encodeEntry(s[tableOff+entrySize:], link, n.EBR-ext.FirstLBA)
+		}
+		copy(s[bootMagicOff:], bootMagic)
+		if _, err := w.WriteAt(s, int64(p.EBR)*SectorSize); err != nil {
+			return fmt.Errorf("writing EBR at LBA %d: %v", p.EBR, err)
+		}
+	}
+	// The first EBR must be at the start of the extended partition.
+	if t.Logical[0].EBR != ext.FirstLBA {
+		s := make([]byte, SectorSize)
+		n := t.Logical[0]
+		link := Partition{Type: TypeExtended, FirstLBA: n.EBR, Sectors: n.LastLBA() - n.EBR + 1}
+		encodeEntry(s[tableOff+entrySize:], link, n.EBR-ext.FirstLBA)
+		copy(s[bootMagicOff:], bootMagic)
+		if _, err := w.WriteAt(s, int64(ext.FirstLBA)*SectorSize); err != nil {
+			return fmt.Errorf("writing EBR at LBA %d: %v", ext.FirstLBA, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks that partitions fit on a disk of the given number of
+// sectors and do not overlap, that there is at most one extended
+// partition, and that logical partitions and their EBRs are inside it.
+func (t *Table) Verify(sectors uint64) error {
+	type extent struct {
+		n           int
+		first, last uint64
+	}
+	var primary, logical []extent
+	var ext *Partition
+	for i, p := range t.Primary {
+		if p.IsEmpty() {
+			continue
+		}
+		if p.FirstLBA == 0 || uint64(p.LastLBA()) >= sectors {
+			return fmt.Errorf("partition %d (%d-%d) is outside the disk of %d sectors", i+1, p.FirstLBA, p.LastLBA(), sectors)
+		}
+		if p.IsExtended() {
+			if ext != nil {
+				return fmt.Errorf("more than one extended partition")
+			}
+			ext = &t.Primary[i]
+		}
+		primary = append(primary, extent{i + 1, uint64(p.FirstLBA), uint64(p.LastLBA())})
+	}
+	if len(t.Logical) > 0 && ext == nil {
+		return fmt.Errorf("logical partitions without an extended partition")
+	}
+	for i, p := range t.Logical {
+		n := FirstLogical + i
+		if p.EBR < ext.FirstLBA || p.EBR >= p.FirstLBA || p.LastLBA() > ext.LastLBA() || p.Sectors == 0 {
+			return fmt.Errorf("logical partition %d (EBR %d, %d-%d) is outside the extended partition (%d-%d)",
+				n, p.EBR, p.FirstLBA, p.LastLBA(), ext.FirstLBA, ext.LastLBA())
+		}
+		// Write puts the head of the chain at the start of the
+		// extended partition.
+		if i > 0 && p.EBR == ext.FirstLBA {
+			return fmt.Errorf("EBR of logical partition %d is at the start of the extended partition, which belongs to partition %d", n, FirstLogical)
+		}
+		logical = append(logical, extent{n, uint64(p.EBR), uint64(p.LastLBA())})
+	}
+	for _, es := range [][]extent{primary, logical} {
+		sort.Slice(es, func(i, j int) bool { return es[i].first < es[j].first })
+		for i := 1; i < len(es); i++ {
+			if es[i].first <= es[i-1].last {
+				return fmt.Errorf("partitions %d and %d overlap", es[i-1].n, es[i].n)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mbr
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+const diskSectors = 1 << 16
+
+func tempDisk(t *testing.T) *os.File {
+	t.Helper()
+	f, err := ioutil.TempFile("", "mbr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(diskSectors * SectorSize); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func testTable() *Table {
+	t := &Table{DiskSignature: 0x675c66d6}
+	t.BootCode[0] = 0xeb
+	t.Primary[0] = Partition{Bootable: true, Type: TypeEFI, FirstLBA: 2048, Sectors: 8192}
+	t.Primary[1] = Partition{Type: TypeExtendedLBA, FirstLBA: 10240, Sectors: 40960}
+	t.Primary[3] = Partition{Type: TypeLinux, FirstLBA: 51200, Sectors: 4096}
+	t.Logical = []Partition{
+		{Type: TypeLinux, EBR: 10240, FirstLBA: 12288, Sectors: 4096},
+		// Chain order need not be disk order.
+		{Type: TypeLinuxSwap, EBR: 30719, FirstLBA: 30720, Sectors: 20480},
+		{Type: TypeLinuxLVM, EBR: 16384, FirstLBA: 16385, Sectors: 1000},
+	}
+	return t
+}
+
+func TestWriteRead(t *testing.T) {
+	f := tempDisk(t)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	want := testTable()
+	if err := want.Verify(diskSectors); err != nil {
+		t.Fatal(err)
+	}
+	if err := want.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read = %+v, want %+v", got, want)
+	}
+	if got.IsProtective() || got.IsHybrid() {
+		t.Errorf("IsProtective = %v, IsHybrid = %v, want false", got.IsProtective(), got.IsHybrid())
+	}
+	if p, err := got.Partition(6); err != nil || p.Type != TypeLinuxSwap {
+		t.Errorf("Partition(6) = %+v, %v, want the swap partition", p, err)
+	}
+	if _, err := got.Partition(8); err == nil {
+		t.Errorf("Partition(8) succeeded")
+	}
+
+	// Removing all logical partitions leaves an empty EBR.
+	want.Logical = nil
+	if err := want.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = Read(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Logical) != 0 {
+		t.Errorf("Read found logical partitions %+v, want none", got.Logical)
+	}
+}
+
+func TestFirstEBR(t *testing.T) {
+	f := tempDisk(t)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// The EBR of the first logical partition is not at the start of the
+	// extended partition, so Write adds an empty one there.
+	want := testTable()
+	want.Logical = want.Logical[1:]
+	if err := want.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Logical, want.Logical) {
+		t.Errorf("Read = %+v, want %+v", got.Logical, want.Logical)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	f := tempDisk(t)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := Read(f); err != ErrNoMBR {
+		t.Errorf("Read of zeroes = %v, want %v", err, ErrNoMBR)
+	}
+
+	// An EBR pointing to itself.
+	tab := testTable()
+	tab.Logical = nil
+	if err := tab.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	s := make([]byte, SectorSize)
+	encodeEntry(s[tableOff+entrySize:], Partition{Type: TypeExtended, FirstLBA: 10240, Sectors: 1}, 0)
+	copy(s[bootMagicOff:], bootMagic)
+	if _, err := f.WriteAt(s, 10240*SectorSize); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(f); err == nil {
+		t.Errorf("Read of a looping EBR chain succeeded")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		change func(*Table)
+	}{
+		{"beyond end", func(t *Table) { t.Primary[3].Sectors = diskSectors }},
+		{"overlapping primaries", func(t *Table) { t.Primary[3].FirstLBA = 50000 }},
+		{"two extended", func(t *Table) { t.Primary[2] = Partition{Type: TypeExtended, FirstLBA: 60000, Sectors: 100} }},
+		{"overlapping logicals", func(t *Table) { t.Logical[2].Sectors = 20000 }},
+		{"logical outside extended", func(t *Table) { t.Logical[1].Sectors = 30000 }},
+		{"EBR after partition", func(t *Table) { t.Logical[0].EBR = 12288 }},
+		{"EBR at start", func(t *Table) { t.Logical[0], t.Logical[1] = t.Logical[1], t.Logical[0] }},
+		{"no extended", func(t *Table) { t.Primary[1] = Partition{} }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tab := testTable()
+			tt.change(tab)
+			if err := tab.Verify(diskSectors); err == nil {
+				t.Errorf("Verify succeeded")
+			}
+		})
+	}
+}
+
+func TestHybrid(t *testing.T) {
+	tab := &Table{}
+	tab.Primary[0] = Partition{Type: TypeProtective, FirstLBA: 1, Sectors: diskSectors - 1}
+	if !tab.IsProtective() || tab.IsHybrid() {
+		t.Errorf("protective MBR: IsProtective = %v, IsHybrid = %v", tab.IsProtective(), tab.IsHybrid())
+	}
+	tab.Primary[0].Sectors = 2047
+	tab.Primary[1] = Partition{Type: TypeEFI, FirstLBA: 2048, Sectors: 2048}
+	if tab.IsProtective() || !tab.IsHybrid() {
+		t.Errorf("hybrid MBR: IsProtective = %v, IsHybrid = %v", tab.IsProtective(), tab.IsHybrid())
+	}
+}
+
+func TestCHS(t *testing.T) {
+	for _, tt := range []struct {
+		lba  uint32
+		want [3]byte
+	}{
+		{0, [3]byte{0, 1, 0}},
+		{2048, [3]byte{32, 33, 0}},
+		{16450559, [3]byte{254, 255, 255}},
+		{1 << 30, [3]byte{0xfe, 0xff, 0xff}},
+	} {
+		if got := chs(tt.lba); got != tt.want {
+			t.Errorf("chs(%d) = %v, want %v", tt.lba, got, tt.want)
+		}
+	}
+}
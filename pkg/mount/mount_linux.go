@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/u-root/u-root/pkg/mount/probe"
 	"golang.org/x/sys/unix"
 )
 
@@ -97,8 +98,9 @@ func Mount(dev, path, fsType, data string, flags uintptr) (*MountPoint, error) {
 	}, nil
 }
 
-// TryMount tries to mount a device on the given mountpoint, trying in order
-// the supported block device file systems on the system.
+// TryMount tries to mount a device on the given mountpoint. The file system
+// type found in the device's superblock is tried first, then in order the
+// supported block device file systems on the system.
 func TryMount(device, path, data string, flags uintptr) (*MountPoint, error) {
 	// TryMount only works on existing block devices. No weirdo devices
 	// like 9P.
@@ -110,6 +112,13 @@ func TryMount(device, path, data string, flags uintptr) (*MountPoint, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to mount %s on %s: %v", device, path, err)
 	}
+	// Try what the superblock says first. The rest of the list is for
+	// filesystems the prober does not know, and for disks where it finds
+	// something else, e.g. a stale RAID or LVM signature left behind when
+	// the disk was reformatted.
+	if r, err := probe.File(device); err == nil && r.Usage == probe.UsageFilesystem {
+		fs = preferFilesystems(fs, r.Type)
+	}
 	for _, fstype := range fs {
 		mp, err := Mount(device, path, fstype, data, flags)
 		if err != nil {
@@ -37,6 +37,12 @@ import (
 //
 //   ARM tests will load drives as virtio-blk devices (/dev/vd*)
 
+// Partition UUIDs in testdata/gptdisk and testdata/gptdisk2.
+const (
+	efiPartUUID   = "89f09307-6c38-4e47-bc0b-00f62b0c0d04"
+	linuxPartUUID = "c9865081-266c-4a23-a948-c03dab506198"
+)
+
 func TestGPT(t *testing.T) {
 	testutil.SkipIfNotRoot(t)
 
@@ -88,15 +94,15 @@ func TestFilterPartID(t *testing.T) {
 		{
 			guid: "C9865081-266C-4A23-A948-C03DAB506198",
 			want: block.BlockDevices{
-				&block.BlockDev{Name: "nvme0n1p2"},
-				&block.BlockDev{Name: devname},
+				&block.BlockDev{Name: "nvme0n1p2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
+				&block.BlockDev{Name: devname, PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 			},
 		},
 		{
 			guid: "c9865081-266c-4a23-a948-c03dab506198",
 			want: block.BlockDevices{
-				&block.BlockDev{Name: "nvme0n1p2"},
-				&block.BlockDev{Name: devname},
+				&block.BlockDev{Name: "nvme0n1p2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
+				&block.BlockDev{Name: devname, PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 			},
 		},
 		{
@@ -130,24 +136,24 @@ func TestFilterPartType(t *testing.T) {
 			// EFI system partition.
 			guid: "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
 			want: block.BlockDevices{
-				&block.BlockDev{Name: "nvme0n1p1"},
-				&block.BlockDev{Name: prefix + "c1"},
+				&block.BlockDev{Name: "nvme0n1p1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+				&block.BlockDev{Name: prefix + "c1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
 			},
 		},
 		{
 			// EFI system partition. mixed case.
 			guid: "c12a7328-f81F-11D2-BA4B-00A0C93ec93B",
 			want: block.BlockDevices{
-				&block.BlockDev{Name: "nvme0n1p1"},
-				&block.BlockDev{Name: prefix + "c1"},
+				&block.BlockDev{Name: "nvme0n1p1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+				&block.BlockDev{Name: prefix + "c1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
 			},
 		},
 		{
 			// This is some random Linux GUID.
 			guid: "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
 			want: block.BlockDevices{
-				&block.BlockDev{Name: "nvme0n1p2"},
-				&block.BlockDev{Name: prefix + "c2"},
+				&block.BlockDev{Name: "nvme0n1p2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
+				&block.BlockDev{Name: prefix + "c2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 			},
 		},
 	} {
@@ -172,16 +178,16 @@ func TestFilterBlockPCI(t *testing.T) {
 	// Check that NVME devices are present.
 	want := block.BlockDevices{
 		&block.BlockDev{Name: "nvme0n1"},
-		&block.BlockDev{Name: "nvme0n1p1"},
-		&block.BlockDev{Name: "nvme0n1p2"},
+		&block.BlockDev{Name: "nvme0n1p1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+		&block.BlockDev{Name: "nvme0n1p2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 		&block.BlockDev{Name: prefix + "a"},
-		&block.BlockDev{Name: prefix + "a1", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9"},
-		&block.BlockDev{Name: prefix + "a2", FsUUID: "ace5-5144"},
+		&block.BlockDev{Name: prefix + "a1", FSType: "ext4", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9", PartUUID: "675c66d6-01"},
+		&block.BlockDev{Name: prefix + "a2", FSType: "vfat", FsUUID: "ace5-5144", PartUUID: "675c66d6-02"},
 		&block.BlockDev{Name: prefix + "b"},
-		&block.BlockDev{Name: prefix + "b1"},
+		&block.BlockDev{Name: prefix + "b1", PartUUID: "65bf3dbf-01"},
 		&block.BlockDev{Name: prefix + "c"},
-		&block.BlockDev{Name: prefix + "c1"},
-		&block.BlockDev{Name: prefix + "c2"},
+		&block.BlockDev{Name: prefix + "c1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+		&block.BlockDev{Name: prefix + "c2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 	}
 	if !reflect.DeepEqual(devs, want) {
 		t.Fatalf("BlockDevices() = \n\t%v want\n\t%v", devs, want)
@@ -195,13 +201,13 @@ func TestFilterBlockPCI(t *testing.T) {
 
 	want = block.BlockDevices{
 		&block.BlockDev{Name: prefix + "a"},
-		&block.BlockDev{Name: prefix + "a1", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9"},
-		&block.BlockDev{Name: prefix + "a2", FsUUID: "ace5-5144"},
+		&block.BlockDev{Name: prefix + "a1", FSType: "ext4", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9", PartUUID: "675c66d6-01"},
+		&block.BlockDev{Name: prefix + "a2", FSType: "vfat", FsUUID: "ace5-5144", PartUUID: "675c66d6-02"},
 		&block.BlockDev{Name: prefix + "b"},
-		&block.BlockDev{Name: prefix + "b1"},
+		&block.BlockDev{Name: prefix + "b1", PartUUID: "65bf3dbf-01"},
 		&block.BlockDev{Name: prefix + "c"},
-		&block.BlockDev{Name: prefix + "c1"},
-		&block.BlockDev{Name: prefix + "c2"},
+		&block.BlockDev{Name: prefix + "c1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+		&block.BlockDev{Name: prefix + "c2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 	}
 	if !reflect.DeepEqual(devs, want) {
 		t.Fatalf("BlockDevices() = \n\t%v want\n\t%v", devs, want)
@@ -222,16 +228,16 @@ func TestFilterBlockPCIString(t *testing.T) {
 	// Check that NVME devices are present.
 	want := block.BlockDevices{
 		&block.BlockDev{Name: "nvme0n1"},
-		&block.BlockDev{Name: "nvme0n1p1"},
-		&block.BlockDev{Name: "nvme0n1p2"},
+		&block.BlockDev{Name: "nvme0n1p1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+		&block.BlockDev{Name: "nvme0n1p2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 		&block.BlockDev{Name: prefix + "a"},
-		&block.BlockDev{Name: prefix + "a1", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9"},
-		&block.BlockDev{Name: prefix + "a2", FsUUID: "ace5-5144"},
+		&block.BlockDev{Name: prefix + "a1", FSType: "ext4", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9", PartUUID: "675c66d6-01"},
+		&block.BlockDev{Name: prefix + "a2", FSType: "vfat", FsUUID: "ace5-5144", PartUUID: "675c66d6-02"},
 		&block.BlockDev{Name: prefix + "b"},
-		&block.BlockDev{Name: prefix + "b1"},
+		&block.BlockDev{Name: prefix + "b1", PartUUID: "65bf3dbf-01"},
 		&block.BlockDev{Name: prefix + "c"},
-		&block.BlockDev{Name: prefix + "c1"},
-		&block.BlockDev{Name: prefix + "c2"},
+		&block.BlockDev{Name: prefix + "c1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+		&block.BlockDev{Name: prefix + "c2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 	}
 	if !reflect.DeepEqual(devs, want) {
 		t.Fatalf("BlockDevices() = \n\t%v want\n\t%v", devs, want)
@@ -245,13 +251,13 @@ func TestFilterBlockPCIString(t *testing.T) {
 
 	want = block.BlockDevices{
 		&block.BlockDev{Name: prefix + "a"},
-		&block.BlockDev{Name: prefix + "a1", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9"},
-		&block.BlockDev{Name: prefix + "a2", FsUUID: "ace5-5144"},
+		&block.BlockDev{Name: prefix + "a1", FSType: "ext4", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9", PartUUID: "675c66d6-01"},
+		&block.BlockDev{Name: prefix + "a2", FSType: "vfat", FsUUID: "ace5-5144", PartUUID: "675c66d6-02"},
 		&block.BlockDev{Name: prefix + "b"},
-		&block.BlockDev{Name: prefix + "b1"},
+		&block.BlockDev{Name: prefix + "b1", PartUUID: "65bf3dbf-01"},
 		&block.BlockDev{Name: prefix + "c"},
-		&block.BlockDev{Name: prefix + "c1"},
-		&block.BlockDev{Name: prefix + "c2"},
+		&block.BlockDev{Name: prefix + "c1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+		&block.BlockDev{Name: prefix + "c2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 	}
 	if !reflect.DeepEqual(devs, want) {
 		t.Fatalf("BlockDevices() = \n\t%v want\n\t%v", devs, want)
@@ -271,16 +277,16 @@ func TestBlockDevices(t *testing.T) {
 
 	want := block.BlockDevices{
 		&block.BlockDev{Name: "nvme0n1"},
-		&block.BlockDev{Name: "nvme0n1p1"},
-		&block.BlockDev{Name: "nvme0n1p2"},
+		&block.BlockDev{Name: "nvme0n1p1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+		&block.BlockDev{Name: "nvme0n1p2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 		&block.BlockDev{Name: prefix + "a"},
-		&block.BlockDev{Name: prefix + "a1", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9"},
-		&block.BlockDev{Name: prefix + "a2", FsUUID: "ace5-5144"},
+		&block.BlockDev{Name: prefix + "a1", FSType: "ext4", FsUUID: "2183ead8-a510-4b3d-9777-19c7090f66d9", PartUUID: "675c66d6-01"},
+		&block.BlockDev{Name: prefix + "a2", FSType: "vfat", FsUUID: "ace5-5144", PartUUID: "675c66d6-02"},
 		&block.BlockDev{Name: prefix + "b"},
-		&block.BlockDev{Name: prefix + "b1"},
+		&block.BlockDev{Name: prefix + "b1", PartUUID: "65bf3dbf-01"},
 		&block.BlockDev{Name: prefix + "c"},
-		&block.BlockDev{Name: prefix + "c1"},
-		&block.BlockDev{Name: prefix + "c2"},
+		&block.BlockDev{Name: prefix + "c1", PartUUID: efiPartUUID, PartLabel: "EFI system partition"},
+		&block.BlockDev{Name: prefix + "c2", PartUUID: linuxPartUUID, PartLabel: "Linux filesystem"},
 	}
 	if !reflect.DeepEqual(devs, want) {
 		t.Fatalf("BlockDevices() = \n\t%v want\n\t%v", devs, want)
@@ -0,0 +1,150 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MountInfoPath is the mount table of this process, with the detail of
+// proc(5)'s mountinfo.
+var MountInfoPath = "/proc/self/mountinfo"
+
+// MountInfo is a line of /proc/self/mountinfo.
+type MountInfo struct {
+	ID       int
+	ParentID int
+	Major    int
+	Minor    int
+
+	// Root is the directory of the file system mounted at MountPoint:
+	// "/" unless it is a bind mount of a subdirectory.
+	Root       string
+	MountPoint string
+
+	// Options are the per mount options, e.g. "rw,nosuid,relatime".
+	Options string
+
+	// Optional are the optional fields, e.g. "shared:1" or "master:2".
+	Optional []string
+
+	FSType string
+	Source string
+
+	// SuperOptions are the per superblock options.
+	SuperOptions string
+}
+
+// Propagation returns the mount's propagation: "shared", "slave",
+// "unbindable" or "private", with peer group details.
+func (m *MountInfo) Propagation() string {
+	var s []string
+	for _, o := range m.Optional {
+		switch {
+		case strings.HasPrefix(o, "shared:"), strings.HasPrefix(o, "master:"),
+			strings.HasPrefix(o, "propagate_from:"), o == "unbindable":
+			s = append(s, o)
+		}
+	}
+	if len(s) == 0 {
+		return "private"
+	}
+	return strings.Join(s, " ")
+}
+
+// unescape undoes the octal escapes of spaces, tabs, newlines and
+// backslashes in mountinfo and fstab fields.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ParseMountInfo parses the mountinfo format.
+func ParseMountInfo(r io.Reader) ([]*MountInfo, error) {
+	var ms []*MountInfo
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 {
+			continue
+		}
+		sep := -1
+		for i := 6; i < len(f); i++ {
+			if f[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || len(f) < sep+4 {
+			return nil, fmt.Errorf("mountinfo line %d: malformed: %q", line, s.Text())
+		}
+		m := &MountInfo{
+			Root:         unescape(f[3]),
+			MountPoint:   unescape(f[4]),
+			Options:      f[5],
+			Optional:     f[6:sep],
+			FSType:       f[sep+1],
+			Source:       unescape(f[sep+2]),
+			SuperOptions: f[sep+3],
+		}
+		var err error
+		if m.ID, err = strconv.Atoi(f[0]); err == nil {
+			m.ParentID, err = strconv.Atoi(f[1])
+		}
+		if err == nil {
+			_, err = fmt.Sscanf(f[2], "%d:%d", &m.Major, &m.Minor)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("mountinfo line %d: %v", line, err)
+		}
+		if len(m.Optional) == 0 {
+			m.Optional = nil
+		}
+		ms = append(ms, m)
+	}
+	return ms, s.Err()
+}
+
+// GetMountInfo returns the mounts of this process, from MountInfoPath.
+func GetMountInfo() ([]*MountInfo, error) {
+	f, err := os.Open(MountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountInfo(f)
+}
+
+// IsMounted reports whether something is mounted at path.
+func IsMounted(path string) (bool, error) {
+	ms, err := GetMountInfo()
+	if err != nil {
+		return false, err
+	}
+	for _, m := range ms {
+		if m.MountPoint == path {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mountinfo = `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw,errors=remount-ro
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+24 22 0:5 / /dev rw,nosuid,relatime shared:2 - devtmpfs udev rw,size=8148412k,nr_inodes=2037103,mode=755
+40 22 8:2 /srv/data /mnt/my\040data ro,relatime master:1 - ext4 /dev/sda2 rw,errors=remount-ro
+41 22 0:40 / /tmp rw,nosuid,nodev - tmpfs tmpfs rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	ms, err := ParseMountInfo(strings.NewReader(mountinfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 5 {
+		t.Fatalf("got %d mounts, want 5", len(ms))
+	}
+	want := &MountInfo{
+		ID:           40,
+		ParentID:     22,
+		Major:        8,
+		Minor:        2,
+		Root:         "/srv/data",
+		MountPoint:   "/mnt/my data",
+		Options:      "ro,relatime",
+		Optional:     []string{"master:1"},
+		FSType:       "ext4",
+		Source:       "/dev/sda2",
+		SuperOptions: "rw,errors=remount-ro",
+	}
+	if !reflect.DeepEqual(ms[3], want) {
+		t.Errorf("got %+v, want %+v", ms[3], want)
+	}
+	for i, p := range []string{"shared:1", "shared:12", "shared:2", "master:1", "private"} {
+		if got := ms[i].Propagation(); got != p {
+			t.Errorf("%s: Propagation() = %q, want %q", ms[i].MountPoint, got, p)
+		}
+	}
+
+	for _, bad := range []string{
+		"22 1 8:2 / / rw,relatime shared:1 ext4 /dev/sda2 rw",
+		"22 1 8:2 / / rw - ext4 /dev/sda2",
+		"x 1 8:2 / / rw - ext4 /dev/sda2 rw",
+		"22 1 82 / / rw - ext4 /dev/sda2 rw",
+	} {
+		if _, err := ParseMountInfo(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseMountInfo(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package mtd
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -23,3 +24,22 @@ func TestOpen(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestOpenFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "mtd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	m, err := NewDev(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	// A file is not erased before it is written.
+	if n := m.(Eraser).EraseSize(); n != 0 {
+		t.Errorf("EraseSize() of a file = %#x, want 0", n)
+	}
+}
@@ -5,25 +5,62 @@
 package mtd
 
 import (
+	"fmt"
 	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 // Dev contains information about ongoing MTD status and operation.
 type Dev struct {
 	*os.File
-	devName string
+	devName   string
+	eraseSize int64
 }
 
 // DevName is the default name for the MTD device.
 var DevName = "/dev/mtd0"
 
-// NewDev creates a Dev, returning Flasher or error.
+// These are the MTD ioctls of mtd/mtd-abi.h.
+const (
+	// memGetInfo is MEMGETINFO, _IOR('M', 1, struct mtd_info_user).
+	memGetInfo = 0x80204d01
+	// memErase is MEMERASE, _IOW('M', 2, struct erase_info_user).
+	memErase = 0x40084d02
+)
+
+// mtdInfo is struct mtd_info_user.
+type mtdInfo struct {
+	Type      uint8
+	_         [3]uint8
+	Flags     uint32
+	Size      uint32
+	EraseSize uint32
+	WriteSize uint32
+	OOBSize   uint32
+	_         uint64
+}
+
+// eraseInfo is struct erase_info_user.
+type eraseInfo struct {
+	Start  uint32
+	Length uint32
+}
+
+// NewDev creates a Dev, returning Flasher or error. n may also be a
+// plain file, e.g. a flash image, which needs no erase before a write.
 func NewDev(n string) (Flasher, error) {
 	f, err := os.OpenFile(n, os.O_RDWR, 0)
 	if err != nil {
 		return nil, err
 	}
-	return &Dev{File: f, devName: n}, nil
+	m := &Dev{File: f, devName: n}
+	var i mtdInfo
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), memGetInfo, uintptr(unsafe.Pointer(&i))); errno == 0 {
+		m.eraseSize = int64(i.EraseSize)
+	}
+	return m, nil
 }
 
 // QueueWrite adds a []byte to the pending write queue.
@@ -36,6 +73,27 @@ func (m *Dev) SyncWrite() error {
 	return nil
 }
 
+// EraseSize implements Eraser.EraseSize. It is 0 if m is not an MTD
+// device.
+func (m *Dev) EraseSize() int64 {
+	return m.eraseSize
+}
+
+// Erase implements Eraser.Erase with MEMERASE.
+func (m *Dev) Erase(off, n int64) error {
+	if m.eraseSize == 0 {
+		return nil
+	}
+	if off%m.eraseSize != 0 || n%m.eraseSize != 0 {
+		return fmt.Errorf("erase of %#x bytes at %#x is not aligned to the %#x byte erase blocks", n, off, m.eraseSize)
+	}
+	e := eraseInfo{Start: uint32(off), Length: uint32(n)}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, m.Fd(), memErase, uintptr(unsafe.Pointer(&e))); errno != 0 {
+		return os.NewSyscallError("ioctl(MEMERASE)", errno)
+	}
+	return nil
+}
+
 // ReadAt implements io.ReadAT
 func (m *Dev) ReadAt(b []byte, off int64) (int, error) {
 	return m.File.ReadAt(b, off)
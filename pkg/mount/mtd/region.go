@@ -0,0 +1,127 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mtd
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Region is a named range of a flash part, such as an FMAP area or an
+// Intel flash descriptor region. Firmware updates are often limited to
+// some regions, leaving others, e.g. the ME or GbE regions, alone.
+type Region struct {
+	Name   string
+	Offset int64
+	Size   int64
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("%s@%#x+%#x", r.Name, r.Offset, r.Size)
+}
+
+// FindRegion returns the region called name.
+func FindRegion(rs []Region, name string) (Region, error) {
+	for _, r := range rs {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return Region{}, fmt.Errorf("no region %q", name)
+}
+
+// ReadRegion reads region r of f.
+func ReadRegion(f Flasher, r Region) ([]byte, error) {
+	b := make([]byte, r.Size)
+	n, err := f.ReadAt(b, r.Offset)
+	if n == len(b) {
+		return b, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("short read: %d of %d bytes", n, len(b))
+	}
+	return nil, fmt.Errorf("reading %v: %v", r, err)
+}
+
+// VerifyRegion checks that region r of f holds the same data as region r
+// of image, a whole flash image.
+func VerifyRegion(f Flasher, image []byte, r Region) error {
+	if !inImage(image, r) {
+		return fmt.Errorf("region %v is outside the %#x byte image", r, len(image))
+	}
+	b, err := ReadRegion(f, r)
+	if err != nil {
+		return err
+	}
+	want := image[r.Offset : r.Offset+r.Size]
+	if i := firstDiff(b, want); i >= 0 {
+		return fmt.Errorf("region %v differs at %#x: got %#02x, want %#02x", r, r.Offset+int64(i), b[i], want[i])
+	}
+	return nil
+}
+
+func inImage(image []byte, r Region) bool {
+	return r.Offset >= 0 && r.Size >= 0 && r.Offset+r.Size <= int64(len(image))
+}
+
+func firstDiff(a, b []byte) int {
+	if bytes.Equal(a, b) {
+		return -1
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return len(a)
+}
+
+// WriteRegions writes regions rs of image, a whole flash image, to f and
+// verifies them. The rest of f is left alone, and regions which already
+// hold the right data are not written, to save erase cycles.
+//
+// If f is an Eraser, each region is erased before it is written, and so
+// must be aligned to its erase blocks.
+func WriteRegions(f Flasher, image []byte, rs ...Region) error {
+	e, _ := f.(Eraser)
+	if e != nil && e.EraseSize() == 0 {
+		e = nil
+	}
+	for _, r := range rs {
+		if !inImage(image, r) {
+			return fmt.Errorf("region %v is outside the %#x byte image", r, len(image))
+		}
+		if e != nil && (r.Offset%e.EraseSize() != 0 || r.Size%e.EraseSize() != 0) {
+			return fmt.Errorf("region %v is not aligned to the %#x byte erase blocks", r, e.EraseSize())
+		}
+	}
+	var queued []Region
+	for _, r := range rs {
+		if VerifyRegion(f, image, r) == nil {
+			continue
+		}
+		if e != nil {
+			if err := e.Erase(r.Offset, r.Size); err != nil {
+				return fmt.Errorf("erasing %v: %v", r, err)
+			}
+		}
+		if _, err := f.QueueWrite(image[r.Offset:r.Offset+r.Size], r.Offset); err != nil {
+			return fmt.Errorf("writing %v: %v", r, err)
+		}
+		queued = append(queued, r)
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+	if err := f.SyncWrite(); err != nil {
+		return err
+	}
+	for _, r := range queued {
+		if err := VerifyRegion(f, image, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mtd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// memFlash is a Flasher in memory, whose writes land on SyncWrite.
+type memFlash struct {
+	data    []byte
+	queue   map[int64][]byte
+	syncs   int
+	corrupt bool
+}
+
+func (m *memFlash) ReadAt(b []byte, off int64) (int, error) {
+	if off >= int64(len(m.data)) {
+		return 0, fmt.Errorf("read past end")
+	}
+	return copy(b, m.data[off:]), nil
+}
+
+func (m *memFlash) QueueWrite(b []byte, off int64) (int, error) {
+	if m.queue == nil {
+		m.queue = map[int64][]byte{}
+	}
+	m.queue[off] = append([]byte(nil), b...)
+	return len(b), nil
+}
+
+func (m *memFlash) SyncWrite() error {
+	for off, b := range m.queue {
+		copy(m.data[off:], b)
+		if m.corrupt {
+			m.data[off] ^= 1
+		}
+	}
+	m.queue = nil
+	m.syncs++
+	return nil
+}
+
+func (m *memFlash) Close() error {
+	return nil
+}
+
+func TestRegions(t *testing.T) {
+	rs := []Region{
+		{Name: "SI_DESC", Offset: 0, Size: 0x1000},
+		{Name: "SI_ME", Offset: 0x1000, Size: 0x3000},
+		{Name: "SI_BIOS", Offset: 0x4000, Size: 0x4000},
+	}
+	old := bytes.Repeat([]byte{0xaa}, 0x8000)
+	image := bytes.Repeat([]byte{0x55}, 0x8000)
+	f := &memFlash{data: append([]byte(nil), old...)}
+
+	bios, err := FindRegion(rs, "SI_BIOS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindRegion(rs, "SI_EC"); err == nil {
+		t.Errorf("FindRegion(SI_EC): got nil, want error")
+	}
+	if err := VerifyRegion(f, image, bios); err == nil {
+		t.Errorf("VerifyRegion before write: got nil, want error")
+	}
+	if err := WriteRegions(f, image, bios); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.data[:0x4000], old[:0x4000]) {
+		t.Errorf("WriteRegions(SI_BIOS) changed other regions")
+	}
+	if err := VerifyRegion(f, image, bios); err != nil {
+		t.Errorf("VerifyRegion after write: %v", err)
+	}
+	b, err := ReadRegion(f, bios)
+	if err != nil || !bytes.Equal(b, image[0x4000:]) {
+		t.Errorf("ReadRegion(SI_BIOS): got %v, %v", len(b), err)
+	}
+
+	// Unchanged regions are not rewritten.
+	if err := WriteRegions(f, image, bios); err != nil || f.syncs != 1 {
+		t.Errorf("WriteRegions(unchanged): got %v after %d syncs, want nil after 1", err, f.syncs)
+	}
+
+	f.corrupt = true
+	if err := WriteRegions(f, image, rs[1]); err == nil {
+		t.Errorf("WriteRegions(corrupting flash): got nil, want error")
+	}
+	if err := WriteRegions(f, image[:0x100], rs[1]); err == nil {
+		t.Errorf("WriteRegions(short image): got nil, want error")
+	}
+	if _, err := ReadRegion(f, Region{Name: "past", Offset: 0x8000, Size: 1}); err == nil {
+		t.Errorf("ReadRegion(past end): got nil, want error")
+	}
+}
+
+// norFlash is a memFlash whose writes can only clear bits, as on NOR
+// flash, until the blocks are erased.
+type norFlash struct {
+	memFlash
+	erases []Region
+}
+
+func (n *norFlash) SyncWrite() error {
+	for off, b := range n.queue {
+		for i, c := range b {
+			n.data[off+int64(i)] &= c
+		}
+	}
+	n.queue = nil
+	n.syncs++
+	return nil
+}
+
+func (n *norFlash) EraseSize() int64 {
+	return 0x1000
+}
+
+func (n *norFlash) Erase(off, size int64) error {
+	for i := off; i < off+size; i++ {
+		n.data[i] = 0xff
+	}
+	n.erases = append(n.erases, Region{Offset: off, Size: size})
+	return nil
+}
+
+func TestWriteRegionsErase(t *testing.T) {
+	image := bytes.Repeat([]byte{0x55}, 0x8000)
+	f := &norFlash{memFlash: memFlash{data: bytes.Repeat([]byte{0xaa}, 0x8000)}}
+
+	bios := Region{Name: "SI_BIOS", Offset: 0x4000, Size: 0x4000}
+	if err := WriteRegions(f, image, bios); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.erases) != 1 || f.erases[0].Offset != bios.Offset || f.erases[0].Size != bios.Size {
+		t.Errorf("WriteRegions(SI_BIOS) erased %v, want %v", f.erases, bios)
+	}
+	if !bytes.Equal(f.data[0x4000:], image[0x4000:]) {
+		t.Errorf("WriteRegions(SI_BIOS) did not write the region")
+	}
+
+	for _, r := range []Region{
+		{Name: "offset", Offset: 0x800, Size: 0x1000},
+		{Name: "size", Offset: 0x1000, Size: 0x800},
+	} {
+		if err := WriteRegions(f, image, r); err == nil {
+			t.Errorf("WriteRegions(%v): got nil, want an alignment error", r)
+		}
+	}
+	if len(f.erases) != 1 {
+		t.Errorf("WriteRegions of unaligned regions erased %v", f.erases[1:])
+	}
+}
@@ -26,6 +26,16 @@ type Flasher interface {
 	Close() error
 }
 
+// Eraser is a Flasher whose blocks must be erased before they are
+// written, such as NOR flash, where a write can only clear bits.
+type Eraser interface {
+	// EraseSize is the size of an erase block, or 0 if nothing needs
+	// erasing.
+	EraseSize() int64
+	// Erase erases n bytes at off, which must be erase block aligned.
+	Erase(off, n int64) error
+}
+
 // VendorName is the manufacturers name
 type VendorName string
 
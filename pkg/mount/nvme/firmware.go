@@ -0,0 +1,112 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nvme
+
+import (
+	"fmt"
+	"time"
+)
+
+// CommitAction is what a firmware commit does.
+type CommitAction uint8
+
+// Firmware commit actions.
+const (
+	// CommitReplace puts the downloaded image in the slot.
+	CommitReplace CommitAction = 0
+
+	// CommitReplaceActivate puts the downloaded image in the slot and
+	// activates it at the next reset.
+	CommitReplaceActivate CommitAction = 1
+
+	// CommitActivate activates the image already in the slot at the
+	// next reset.
+	CommitActivate CommitAction = 2
+
+	// CommitActivateNow replaces and activates the image immediately,
+	// without a reset.
+	CommitActivateNow CommitAction = 3
+)
+
+// firmwareTimeout allows for slow flash writes.
+const firmwareTimeout = 2 * time.Minute
+
+// DefaultFirmwareChunk is the download chunk size used when the
+// controller does not say what it needs.
+const DefaultFirmwareChunk = 4096
+
+// DownloadFirmware sends a firmware image to the controller in chunks of
+// chunk bytes, which must be a multiple of 4. A following CommitFirmware
+// writes it to a slot.
+func (d *Device) DownloadFirmware(image []byte, chunk int) error {
+	if len(image) == 0 || len(image)%4 != 0 {
+		return fmt.Errorf("firmware image is %d bytes, not a positive multiple of 4", len(image))
+	}
+	if chunk <= 0 || chunk%4 != 0 {
+		return fmt.Errorf("firmware chunk size %d is not a positive multiple of 4", chunk)
+	}
+	for off := 0; off < len(image); off += chunk {
+		end := off + chunk
+		if end > len(image) {
+			end = len(image)
+		}
+		_, err := d.AdminCommand(&Command{
+			Opcode:  opFirmwareDownload,
+			CDW10:   uint32((end-off)/4 - 1),
+			CDW11:   uint32(off / 4),
+			Data:    image[off:end],
+			Timeout: firmwareTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("downloading firmware at %d: %w", off, err)
+		}
+	}
+	return nil
+}
+
+// FirmwareChunk returns the download chunk size c asks for.
+func (c *Controller) FirmwareChunk() int {
+	switch g := c.FirmwareGranularity; {
+	case g == 0 || g > 1<<20:
+		return DefaultFirmwareChunk
+	default:
+		return int(g)
+	}
+}
+
+// CommitFirmware commits the downloaded image, or the image already in
+// slot for CommitActivate, to slot 1 to 7, or to a slot the controller
+// chooses if slot is 0.
+//
+// Some controllers report that a reset is needed with a StatusError even
+// though the commit succeeded.
+func (d *Device) CommitFirmware(slot int, action CommitAction) error {
+	if slot < 0 || slot > 7 {
+		return fmt.Errorf("invalid firmware slot %d", slot)
+	}
+	if action > CommitActivateNow {
+		return fmt.Errorf("invalid firmware commit action %d", action)
+	}
+	_, err := d.AdminCommand(&Command{
+		Opcode:  opFirmwareCommit,
+		CDW10:   uint32(slot) | uint32(action)<<3,
+		Timeout: firmwareTimeout,
+	})
+	return err
+}
+
+// NeedsReset reports whether err is a firmware commit status saying the
+// new firmware is activated by a reset.
+func NeedsReset(err error) bool {
+	e, ok := err.(*StatusError)
+	if !ok || e.Opcode != opFirmwareCommit {
+		return false
+	}
+	switch e.Status & 0x7ff {
+	case 0x10b, 0x110, 0x111:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nvme
+
+import (
+	"fmt"
+	"time"
+)
+
+// SecureErase is the secure erase setting of a format.
+type SecureErase uint8
+
+// Secure erase settings.
+const (
+	EraseNone   SecureErase = 0
+	EraseUser   SecureErase = 1
+	EraseCrypto SecureErase = 2
+)
+
+// Format describes a Format NVM command.
+type Format struct {
+	// NSID is the namespace to format, or AllNamespaces.
+	NSID uint32
+
+	// LBAFormat is the index of the namespace's new LBA format.
+	LBAFormat int
+
+	// MetadataAtEnd puts metadata at the end of each block.
+	MetadataAtEnd bool
+
+	// ProtectionInfo is the protection information type, 0 to 3, and
+	// ProtectionFirst puts it at the start of the metadata.
+	ProtectionInfo  int
+	ProtectionFirst bool
+
+	SecureErase SecureErase
+}
+
+// formatTimeout allows for erasing a large device.
+const formatTimeout = 30 * time.Minute
+
+// Format formats a namespace, destroying its data.
+func (d *Device) Format(f *Format) error {
+	if f.LBAFormat < 0 || f.LBAFormat > 15 {
+		return fmt.Errorf("invalid LBA format %d", f.LBAFormat)
+	}
+	if f.ProtectionInfo < 0 || f.ProtectionInfo > 3 {
+		return fmt.Errorf("invalid protection information type %d", f.ProtectionInfo)
+	}
+	if f.SecureErase > EraseCrypto {
+		return fmt.Errorf("invalid secure erase setting %d", f.SecureErase)
+	}
+	cdw10 := uint32(f.LBAFormat) | uint32(f.ProtectionInfo)<<5 | uint32(f.SecureErase)<<9
+	if f.MetadataAtEnd {
+		cdw10 |= 1 << 4
+	}
+	if f.ProtectionFirst {
+		cdw10 |= 1 << 8
+	}
+	_, err := d.AdminCommand(&Command{Opcode: opFormatNVM, NSID: f.NSID, CDW10: cdw10, Timeout: formatTimeout})
+	return err
+}
+
+// SanitizeAction is the kind of sanitize.
+type SanitizeAction uint8
+
+// Sanitize actions.
+const (
+	SanitizeExitFailure SanitizeAction = 1
+	SanitizeBlockErase  SanitizeAction = 2
+	SanitizeOverwrite   SanitizeAction = 3
+	SanitizeCryptoErase SanitizeAction = 4
+)
+
+// Sanitize describes a Sanitize command.
+type Sanitize struct {
+	Action SanitizeAction
+
+	// AllowUnrestrictedExit lets the controller leave the failed state
+	// without a successful sanitize.
+	AllowUnrestrictedExit bool
+
+	// OverwritePasses is the number of overwrite passes, 1 to 16, and
+	// Pattern the 32-bit pattern written. InvertPattern inverts it
+	// between passes.
+	OverwritePasses int
+	Pattern         uint32
+	InvertPattern   bool
+
+	// NoDeallocate leaves the sanitized blocks allocated.
+	NoDeallocate bool
+}
+
+// Sanitize starts sanitizing the whole NVM subsystem, destroying all
+// data. It returns once the sanitize has started; SanitizeStatus reports
+// its progress.
+func (d *Device) Sanitize(s *Sanitize) error {
+	if s.Action < SanitizeExitFailure || s.Action > SanitizeCryptoErase {
+		return fmt.Errorf("invalid sanitize action %d", s.Action)
+	}
+	cdw10 := uint32(s.Action)
+	if s.AllowUnrestrictedExit {
+		cdw10 |= 1 << 3
+	}
+	if s.Action == SanitizeOverwrite {
+		if s.OverwritePasses < 1 || s.OverwritePasses > 16 {
+			return fmt.Errorf("invalid number of overwrite passes %d", s.OverwritePasses)
+		}
+		// 0 means 16 passes.
+		cdw10 |= uint32(s.OverwritePasses&0xf) << 4
+		if s.InvertPattern {
+			cdw10 |= 1 << 8
+		}
+	}
+	if s.NoDeallocate {
+		cdw10 |= 1 << 9
+	}
+	_, err := d.AdminCommand(&Command{Opcode: opSanitize, CDW10: cdw10, CDW11: s.Pattern})
+	return err
+}
@@ -0,0 +1,289 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Identify CNS values and the size of the data they return.
+const (
+	cnsNamespace        = 0x00
+	cnsController       = 0x01
+	cnsActiveNamespaces = 0x02
+
+	identifySize = 4096
+)
+
+// Optional admin command support bits of OACS.
+const (
+	OACSSecurity             = 1 << 0
+	OACSFormat               = 1 << 1
+	OACSFirmware             = 1 << 2
+	OACSNamespaceManage      = 1 << 3
+	OACSDeviceSelfTest       = 1 << 4
+	OACSDirectives           = 1 << 5
+	OACSNVMeMI               = 1 << 6
+	OACSVirtualization       = 1 << 7
+	OACSDoorbellBufferConfig = 1 << 8
+	OACSGetLBAStatus         = 1 << 9
+)
+
+// Sanitize capability bits of SANICAP.
+const (
+	SANICAPCryptoErase = 1 << 0
+	SANICAPBlockErase  = 1 << 1
+	SANICAPOverwrite   = 1 << 2
+)
+
+// Format attribute bits of FNA.
+const (
+	FormatAllNamespaces      = 1 << 0
+	FormatEraseAllNamespaces = 1 << 1
+	FormatCryptoErase        = 1 << 2
+)
+
+// Controller is the Identify Controller data structure. Only the fields
+// this package uses are decoded; Raw holds all of it.
+type Controller struct {
+	VendorID          uint16
+	SubsystemVendorID uint16
+	Serial            string
+	Model             string
+	Firmware          string
+
+	// IEEE is the IEEE OUI of the vendor.
+	IEEE uint32
+
+	// MDTS is the maximum data transfer size, as a power of two of the
+	// minimum memory page size. 0 means no limit.
+	MDTS uint8
+
+	ControllerID uint16
+
+	// Version is the NVMe version, e.g. 0x10400 for 1.4.
+	Version uint32
+
+	// OACS is the optional admin command support, a set of OACS* bits.
+	OACS uint16
+
+	// FirmwareSlots is the number of firmware slots, and
+	// FirmwareSlot1ReadOnly tells whether slot 1 can be written.
+	FirmwareSlots         int
+	FirmwareSlot1ReadOnly bool
+
+	// FirmwareActivateWithoutReset tells whether commit action 3 is
+	// supported.
+	FirmwareActivateWithoutReset bool
+
+	// FirmwareGranularity is the required alignment and size multiple
+	// of firmware download chunks in bytes. 0 means unknown, and
+	// math.MaxUint32 no restriction.
+	FirmwareGranularity uint32
+
+	// TotalCapacity and UnallocatedCapacity are the NVM capacity in
+	// bytes, saturated at math.MaxUint64.
+	TotalCapacity       uint64
+	UnallocatedCapacity uint64
+
+	// SANICAP is the sanitize capabilities, a set of SANICAP* bits.
+	SANICAP uint32
+
+	// Namespaces is the maximum namespace ID.
+	Namespaces uint32
+
+	// FNA is the format NVM attributes, a set of Format* bits.
+	FNA uint8
+
+	// SubsystemNQN is the NVM subsystem NVMe qualified name.
+	SubsystemNQN string
+
+	Raw []byte `json:"-"`
+}
+
+// LBAFormat is a namespace's LBA format.
+type LBAFormat struct {
+	// MetadataSize is the metadata bytes per block.
+	MetadataSize uint16
+
+	// DataSize is the block size in bytes.
+	DataSize uint32
+
+	// Performance is the relative performance: 0 is best, 3 worst.
+	Performance uint8
+}
+
+// Namespace is the Identify Namespace data structure.
+type Namespace struct {
+	ID uint32
+
+	// Size, Capacity and Utilization are in blocks.
+	Size        uint64
+	Capacity    uint64
+	Utilization uint64
+
+	// Formats are the supported LBA formats, and Format the index of
+	// the one in use.
+	Formats []LBAFormat
+	Format  int
+
+	// MetadataAtEnd tells whether metadata is sent at the end of each
+	// block rather than in a separate buffer.
+	MetadataAtEnd bool
+
+	// NGUID and EUI64 are the namespace's unique identifiers; they are
+	// zero if the controller does not provide them.
+	NGUID [16]byte
+	EUI64 [8]byte
+
+	Raw []byte `json:"-"`
+}
+
+// BlockSize returns the block size of the format in use.
+func (n *Namespace) BlockSize() uint32 {
+	if n.Format >= len(n.Formats) {
+		return 0
+	}
+	return n.Formats[n.Format].DataSize
+}
+
+// ascii decodes a space-padded ASCII field.
+func ascii(b []byte) string {
+	return string(bytes.TrimRight(bytes.TrimRight(b, "\x00"), " "))
+}
+
+// uint128 decodes a little-endian 128-bit field, saturating at
+// math.MaxUint64.
+func uint128(b []byte) uint64 {
+	if binary.LittleEndian.Uint64(b[8:]) != 0 {
+		return math.MaxUint64
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// ParseController decodes an Identify Controller page.
+func ParseController(b []byte) (*Controller, error) {
+	if len(b) < identifySize {
+		return nil, fmt.Errorf("identify controller data is %d bytes, want %d", len(b), identifySize)
+	}
+	le := binary.LittleEndian
+	frmw := b[260]
+	c := &Controller{
+		VendorID:                     le.Uint16(b[0:]),
+		SubsystemVendorID:            le.Uint16(b[2:]),
+		Serial:                       ascii(b[4:24]),
+		Model:                        ascii(b[24:64]),
+		Firmware:                     ascii(b[64:72]),
+		IEEE:                         uint32(b[73]) | uint32(b[74])<<8 | uint32(b[75])<<16,
+		MDTS:                         b[77],
+		ControllerID:                 le.Uint16(b[78:]),
+		Version:                      le.Uint32(b[80:]),
+		OACS:                         le.Uint16(b[256:]),
+		FirmwareSlot1ReadOnly:        frmw&1 != 0,
+		FirmwareSlots:                int(frmw>>1) & 7,
+		FirmwareActivateWithoutReset: frmw&(1<<4) != 0,
+		TotalCapacity:                uint128(b[280:]),
+		UnallocatedCapacity:          uint128(b[296:]),
+		SANICAP:                      le.Uint32(b[328:]),
+		Namespaces:                   le.Uint32(b[516:]),
+		FNA:                          b[524],
+		SubsystemNQN:                 ascii(b[768:1024]),
+		Raw:                          b[:identifySize],
+	}
+	switch g := b[319]; g {
+	case 0:
+	case 0xff:
+		c.FirmwareGranularity = math.MaxUint32
+	default:
+		c.FirmwareGranularity = uint32(g) * 4096
+	}
+	return c, nil
+}
+
+// ParseNamespace decodes an Identify Namespace page.
+func ParseNamespace(b []byte) (*Namespace, error) {
+	if len(b) < identifySize {
+		return nil, fmt.Errorf("identify namespace data is %d bytes, want %d", len(b), identifySize)
+	}
+	le := binary.LittleEndian
+	n := &Namespace{
+		Size:          le.Uint64(b[0:]),
+		Capacity:      le.Uint64(b[8:]),
+		Utilization:   le.Uint64(b[16:]),
+		Format:        int(b[26] & 0xf),
+		MetadataAtEnd: b[26]&(1<<4) != 0,
+		Raw:           b[:identifySize],
+	}
+	copy(n.NGUID[:], b[104:120])
+	copy(n.EUI64[:], b[120:128])
+	// NLBAF is zero-based.
+	for i := 0; i <= int(b[25]) && i < 16; i++ {
+		f := le.Uint32(b[128+4*i:])
+		ds := uint8(f >> 16)
+		if ds < 9 || ds > 31 {
+			return nil, fmt.Errorf("LBA format %d has 2^%d byte blocks", i, ds)
+		}
+		n.Formats = append(n.Formats, LBAFormat{
+			MetadataSize: uint16(f),
+			DataSize:     1 << ds,
+			Performance:  uint8(f>>24) & 3,
+		})
+	}
+	if n.Format >= len(n.Formats) {
+		return nil, fmt.Errorf("namespace uses LBA format %d of %d", n.Format, len(n.Formats))
+	}
+	return n, nil
+}
+
+func (d *Device) identify(nsid, cns uint32) ([]byte, error) {
+	b := make([]byte, identifySize)
+	if _, err := d.AdminCommand(&Command{Opcode: opIdentify, NSID: nsid, CDW10: cns, Data: b}); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// IdentifyController returns the controller's identify data.
+func (d *Device) IdentifyController() (*Controller, error) {
+	b, err := d.identify(0, cnsController)
+	if err != nil {
+		return nil, err
+	}
+	return ParseController(b)
+}
+
+// IdentifyNamespace returns the identify data of namespace nsid.
+func (d *Device) IdentifyNamespace(nsid uint32) (*Namespace, error) {
+	b, err := d.identify(nsid, cnsNamespace)
+	if err != nil {
+		return nil, err
+	}
+	n, err := ParseNamespace(b)
+	if err != nil {
+		return nil, err
+	}
+	n.ID = nsid
+	return n, nil
+}
+
+// ActiveNamespaces returns the IDs of the active namespaces.
+func (d *Device) ActiveNamespaces() ([]uint32, error) {
+	b, err := d.identify(0, cnsActiveNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint32
+	for i := 0; i < len(b); i += 4 {
+		id := binary.LittleEndian.Uint32(b[i:])
+		if id == 0 {
+			break
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Log page identifiers.
+const (
+	LogError          = 0x01
+	LogSMART          = 0x02
+	LogFirmwareSlot   = 0x03
+	LogSanitizeStatus = 0x81
+
+	logPageSize = 512
+)
+
+// Critical warning bits of the SMART/health log.
+const (
+	WarningSpare          = 1 << 0
+	WarningTemperature    = 1 << 1
+	WarningReliability    = 1 << 2
+	WarningReadOnly       = 1 << 3
+	WarningVolatileBackup = 1 << 4
+	WarningPMR            = 1 << 5
+)
+
+// GetLogPage reads len(b) bytes of log page lid for namespace nsid, which
+// is AllNamespaces for controller-wide logs, starting at offset. len(b)
+// must be a multiple of 4.
+func (d *Device) GetLogPage(lid uint8, nsid uint32, offset uint64, b []byte) error {
+	if len(b) == 0 || len(b)%4 != 0 {
+		return fmt.Errorf("log page reads must be a positive multiple of 4 bytes, not %d", len(b))
+	}
+	numd := uint32(len(b)/4 - 1)
+	_, err := d.AdminCommand(&Command{
+		Opcode: opGetLogPage,
+		NSID:   nsid,
+		CDW10:  uint32(lid) | (numd&0xffff)<<16,
+		CDW11:  numd >> 16,
+		CDW12:  uint32(offset),
+		CDW13:  uint32(offset >> 32),
+		Data:   b,
+	})
+	return err
+}
+
+// SMARTLog is the SMART/health information log.
+type SMARTLog struct {
+	// CriticalWarning is a set of Warning* bits.
+	CriticalWarning uint8
+
+	// Temperature is the composite temperature in Kelvin.
+	Temperature uint16
+
+	// AvailableSpare and its threshold, and PercentageUsed, are
+	// percentages. PercentageUsed may exceed 100.
+	AvailableSpare          uint8
+	AvailableSpareThreshold uint8
+	PercentageUsed          uint8
+
+	// DataUnitsRead and DataUnitsWritten are in thousands of 512 byte
+	// units. These and the other counters are 128 bits on the device
+	// and saturate at math.MaxUint64.
+	DataUnitsRead    uint64
+	DataUnitsWritten uint64
+	HostReads        uint64
+	HostWrites       uint64
+
+	// ControllerBusyTime is in minutes.
+	ControllerBusyTime uint64
+	PowerCycles        uint64
+	PowerOnHours       uint64
+	UnsafeShutdowns    uint64
+	MediaErrors        uint64
+	ErrorLogEntries    uint64
+
+	// WarningTemperatureTime and CriticalTemperatureTime are in
+	// minutes.
+	WarningTemperatureTime  uint32
+	CriticalTemperatureTime uint32
+
+	// Sensors are the temperature sensors that are implemented, in
+	// Kelvin.
+	Sensors []uint16
+}
+
+// ParseSMARTLog decodes a SMART/health information log page.
+func ParseSMARTLog(b []byte) (*SMARTLog, error) {
+	if len(b) < logPageSize {
+		return nil, fmt.Errorf("SMART log is %d bytes, want %d", len(b), logPageSize)
+	}
+	le := binary.LittleEndian
+	s := &SMARTLog{
+		CriticalWarning:         b[0],
+		Temperature:             le.Uint16(b[1:]),
+		AvailableSpare:          b[3],
+		AvailableSpareThreshold: b[4],
+		PercentageUsed:          b[5],
+		DataUnitsRead:           uint128(b[32:]),
+		DataUnitsWritten:        uint128(b[48:]),
+		HostReads:               uint128(b[64:]),
+		HostWrites:              uint128(b[80:]),
+		ControllerBusyTime:      uint128(b[96:]),
+		PowerCycles:             uint128(b[112:]),
+		PowerOnHours:            uint128(b[128:]),
+		UnsafeShutdowns:         uint128(b[144:]),
+		MediaErrors:             uint128(b[160:]),
+		ErrorLogEntries:         uint128(b[176:]),
+		WarningTemperatureTime:  le.Uint32(b[192:]),
+		CriticalTemperatureTime: le.Uint32(b[196:]),
+	}
+	for i := 0; i < 8; i++ {
+		if t := le.Uint16(b[200+2*i:]); t != 0 {
+			s.Sensors = append(s.Sensors, t)
+		}
+	}
+	return s, nil
+}
+
+// SMARTLog returns the SMART/health log of namespace nsid, or of the
+// controller for AllNamespaces.
+func (d *Device) SMARTLog(nsid uint32) (*SMARTLog, error) {
+	b := make([]byte, logPageSize)
+	if err := d.GetLogPage(LogSMART, nsid, 0, b); err != nil {
+		return nil, err
+	}
+	return ParseSMARTLog(b)
+}
+
+// FirmwareSlotLog is the firmware slot information log.
+type FirmwareSlotLog struct {
+	// Active is the slot of the running firmware, and Next the one that
+	// will be activated at the next reset, or 0 if it is the same.
+	Active int
+	Next   int
+
+	// Revisions are the revisions in slots 1 to 7. Empty slots have
+	// empty revisions.
+	Revisions [7]string
+}
+
+// ParseFirmwareSlotLog decodes a firmware slot information log page.
+func ParseFirmwareSlotLog(b []byte) (*FirmwareSlotLog, error) {
+	if len(b) < 64 {
+		return nil, fmt.Errorf("firmware slot log is %d bytes, want 64", len(b))
+	}
+	f := &FirmwareSlotLog{Active: int(b[0] & 7), Next: int(b[0]>>4) & 7}
+	for i := range f.Revisions {
+		f.Revisions[i] = ascii(b[8+8*i : 16+8*i])
+	}
+	return f, nil
+}
+
+// FirmwareSlots returns the firmware slot log.
+func (d *Device) FirmwareSlots() (*FirmwareSlotLog, error) {
+	b := make([]byte, logPageSize)
+	if err := d.GetLogPage(LogFirmwareSlot, AllNamespaces, 0, b); err != nil {
+		return nil, err
+	}
+	return ParseFirmwareSlotLog(b)
+}
+
+// Sanitize states in the sanitize status log.
+const (
+	SanitizeStateNever                 = 0
+	SanitizeStateCompleted             = 1
+	SanitizeStateInProgress            = 2
+	SanitizeStateFailed                = 3
+	SanitizeStateCompletedNoDeallocate = 4
+)
+
+// SanitizeStatus is the sanitize status log.
+type SanitizeStatus struct {
+	// Progress is the fraction done of a sanitize in progress, out of
+	// 65536.
+	Progress uint16
+
+	// State is one of the SanitizeState* values.
+	State int
+
+	// Passes is the number of overwrite passes completed.
+	Passes int
+
+	// GlobalDataErased tells whether no user data has been written since
+	// the last sanitize or since manufacture.
+	GlobalDataErased bool
+
+	// CDW10 is the command dword 10 of the last sanitize command.
+	CDW10 uint32
+
+	// Estimated times in seconds to sanitize with each method, or
+	// 0xffffffff if unknown.
+	OverwriteTime   uint32
+	BlockEraseTime  uint32
+	CryptoEraseTime uint32
+}
+
+// ParseSanitizeStatus decodes a sanitize status log page.
+func ParseSanitizeStatus(b []byte) (*SanitizeStatus, error) {
+	if len(b) < 20 {
+		return nil, fmt.Errorf("sanitize status log is %d bytes, want 20", len(b))
+	}
+	le := binary.LittleEndian
+	sstat := le.Uint16(b[2:])
+	return &SanitizeStatus{
+		Progress:         le.Uint16(b[0:]),
+		State:            int(sstat & 7),
+		Passes:           int(sstat>>3) & 0x1f,
+		GlobalDataErased: sstat&(1<<8) != 0,
+		CDW10:            le.Uint32(b[4:]),
+		OverwriteTime:    le.Uint32(b[8:]),
+		BlockEraseTime:   le.Uint32(b[12:]),
+		CryptoEraseTime:  le.Uint32(b[16:]),
+	}, nil
+}
+
+// SanitizeStatus returns the sanitize status log.
+func (d *Device) SanitizeStatus() (*SanitizeStatus, error) {
+	b := make([]byte, logPageSize)
+	if err := d.GetLogPage(LogSanitizeStatus, AllNamespaces, 0, b); err != nil {
+		return nil, err
+	}
+	return ParseSanitizeStatus(b)
+}
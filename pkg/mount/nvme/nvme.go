@@ -0,0 +1,133 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nvme issues admin commands to NVMe controllers.
+//
+// It supports identify, the SMART/health and firmware slot logs, firmware
+// download and commit, format, sanitize, and TCG Opal discovery through
+// security send and receive. On Linux, commands are issued with the
+// NVME_IOCTL_ADMIN_CMD ioctl on a controller (/dev/nvme0) or namespace
+// (/dev/nvme0n1) device.
+//
+// Other info:
+//       https://nvmexpress.org/ NVM Express Base Specification 1.4.
+//       https://trustedcomputinggroup.org/ TCG Storage Opal SSC 2.01.
+package nvme
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Admin command opcodes. The low two bits give the data direction.
+const (
+	opGetLogPage       = 0x02
+	opIdentify         = 0x06
+	opFirmwareCommit   = 0x10
+	opFirmwareDownload = 0x11
+	opFormatNVM        = 0x80
+	opSecuritySend     = 0x81
+	opSecurityReceive  = 0x82
+	opSanitize         = 0x84
+)
+
+// AllNamespaces is the namespace ID of commands that apply to every
+// namespace.
+const AllNamespaces = 0xffffffff
+
+// DefaultTimeout is the default timeout for admin commands.
+const DefaultTimeout = 15 * time.Second
+
+// Command is an admin command, as in Linux's struct nvme_admin_cmd.
+type Command struct {
+	Opcode uint8
+	NSID   uint32
+
+	// CDW10 to CDW15 are the command specific dwords.
+	CDW10, CDW11, CDW12, CDW13, CDW14, CDW15 uint32
+
+	// Data is sent to the controller if Opcode's low bits are 01, and
+	// filled from it if they are 10.
+	Data []byte
+
+	// Timeout is the command's timeout. If zero, the kernel's default
+	// is used.
+	Timeout time.Duration
+}
+
+// ToController reports whether c sends Data to the controller.
+func (c *Command) ToController() bool {
+	return c.Opcode&3 == 1
+}
+
+// Admin issues admin commands.
+type Admin interface {
+	// AdminCommand issues c and returns dword 0 of its completion.
+	AdminCommand(c *Command) (uint32, error)
+}
+
+// StatusError is the status of a command the controller failed.
+type StatusError struct {
+	Opcode uint8
+
+	// Status is the status field of the completion, without the phase
+	// tag: the status code in bits 7:0, the status code type in bits
+	// 10:8, and the do not retry bit in bit 14.
+	Status uint16
+}
+
+// Status codes of the generic and command specific types.
+var statusNames = map[uint16]string{
+	0x001: "invalid command opcode",
+	0x002: "invalid field in command",
+	0x004: "data transfer error",
+	0x006: "internal error",
+	0x007: "command abort requested",
+	0x00b: "invalid namespace or format",
+	0x01d: "sanitize failed",
+	0x01e: "sanitize in progress",
+	0x080: "LBA out of range",
+	0x081: "capacity exceeded",
+	0x082: "namespace not ready",
+	0x106: "invalid firmware slot",
+	0x107: "invalid firmware image",
+	0x10a: "invalid format",
+	0x10b: "firmware activation requires conventional reset",
+	0x110: "firmware activation requires NVM subsystem reset",
+	0x111: "firmware activation requires controller level reset",
+	0x112: "firmware activation requires maximum time violation",
+	0x113: "firmware activation prohibited",
+	0x114: "overlapping range",
+	0x115: "namespace insufficient capacity",
+	0x286: "access denied",
+}
+
+func (e *StatusError) Error() string {
+	sc := e.Status & 0x7ff
+	if s, ok := statusNames[sc]; ok {
+		return fmt.Sprintf("NVMe command %#02x failed: %s (status %#x)", e.Opcode, s, e.Status)
+	}
+	return fmt.Sprintf("NVMe command %#02x failed with status %#x", e.Opcode, e.Status)
+}
+
+// Device is an NVMe controller.
+type Device struct {
+	Admin
+
+	closer io.Closer
+}
+
+// New returns a Device that issues commands through a.
+func New(a Admin) *Device {
+	return &Device{Admin: a}
+}
+
+// Close closes the device, if it was opened.
+func (d *Device) Close() error {
+	if d.closer == nil {
+		return nil
+	}
+	return d.closer.Close()
+}
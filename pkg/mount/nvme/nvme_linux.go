@@ -0,0 +1,83 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nvme
+
+import (
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// nvmeIoctlAdminCmd is NVME_IOCTL_ADMIN_CMD, _IOWR('N', 0x41, struct
+// nvme_admin_cmd).
+const nvmeIoctlAdminCmd = 0xc0484e41
+
+// adminCmd is Linux's struct nvme_admin_cmd.
+type adminCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+// fileAdmin issues admin commands with ioctls on an NVMe device.
+type fileAdmin struct {
+	f *os.File
+}
+
+// Open opens the NVMe controller or namespace device at path.
+func Open(path string) (*Device, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Device{Admin: &fileAdmin{f: f}, closer: f}, nil
+}
+
+// AdminCommand implements Admin.
+func (a *fileAdmin) AdminCommand(c *Command) (uint32, error) {
+	cmd := adminCmd{
+		opcode:    c.Opcode,
+		nsid:      c.NSID,
+		cdw10:     c.CDW10,
+		cdw11:     c.CDW11,
+		cdw12:     c.CDW12,
+		cdw13:     c.CDW13,
+		cdw14:     c.CDW14,
+		cdw15:     c.CDW15,
+		timeoutMs: uint32(c.Timeout.Milliseconds()),
+	}
+	if len(c.Data) > 0 {
+		cmd.addr = uint64(uintptr(unsafe.Pointer(&c.Data[0])))
+		cmd.dataLen = uint32(len(c.Data))
+	}
+	// The ioctl returns a negative errno, or the NVMe status if the
+	// controller failed the command.
+	r, _, errno := unix.Syscall(unix.SYS_IOCTL, a.f.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd)))
+	runtime.KeepAlive(c.Data)
+	if errno != 0 {
+		return 0, &os.PathError{Op: "ioctl NVME_IOCTL_ADMIN_CMD", Path: a.f.Name(), Err: errno}
+	}
+	if r != 0 {
+		return 0, &StatusError{Opcode: c.Opcode, Status: uint16(r)}
+	}
+	return cmd.result, nil
+}
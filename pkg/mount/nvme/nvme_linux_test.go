@@ -0,0 +1,16 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nvme
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestAdminCmdSize(t *testing.T) {
+	if n := unsafe.Sizeof(adminCmd{}); n != 72 {
+		t.Errorf("struct nvme_admin_cmd is %d bytes, want 72", n)
+	}
+}
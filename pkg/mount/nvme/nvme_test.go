@@ -0,0 +1,337 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nvme
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/mount/nvme/nvmetest"
+)
+
+// fakeController serves the pages of nvmetest.
+type fakeController struct {
+	t     *testing.T
+	cmds  []Command
+	pages map[string][]byte
+
+	// fail makes commands with this opcode fail with status.
+	fail   uint8
+	status uint16
+
+	// firmware collects downloaded firmware.
+	firmware []byte
+}
+
+func newFake(t *testing.T) *fakeController {
+	return &fakeController{t: t, pages: nvmetest.Pages()}
+}
+
+func (f *fakeController) AdminCommand(c *Command) (uint32, error) {
+	f.cmds = append(f.cmds, *c)
+	if f.fail != 0 && c.Opcode == f.fail {
+		return 0, &StatusError{Opcode: c.Opcode, Status: f.status}
+	}
+	var page []byte
+	switch c.Opcode {
+	case opIdentify:
+		switch c.CDW10 {
+		case cnsController:
+			page = f.pages["id-ctrl"]
+		case cnsNamespace:
+			if c.NSID != 1 {
+				return 0, &StatusError{Opcode: c.Opcode, Status: 0x00b}
+			}
+			page = f.pages["id-ns"]
+		case cnsActiveNamespaces:
+			page = []byte{1, 0, 0, 0}
+		}
+	case opGetLogPage:
+		if numd := c.CDW10>>16 | c.CDW11<<16; numd != uint32(len(c.Data)/4-1) {
+			f.t.Errorf("log page NUMD is %d for %d bytes", numd, len(c.Data))
+		}
+		page = map[uint32][]byte{
+			LogSMART:          f.pages["smart-log"],
+			LogFirmwareSlot:   f.pages["fw-log"],
+			LogSanitizeStatus: f.pages["sanitize-log"],
+		}[c.CDW10&0xff]
+	case opSecurityReceive:
+		if c.CDW11 != uint32(len(c.Data)) {
+			f.t.Errorf("security receive allocation length is %d for %d bytes", c.CDW11, len(c.Data))
+		}
+		switch c.CDW10 {
+		case SecurityTCG<<24 | 1<<8:
+			page = f.pages["opal-discovery"]
+		case SecurityInfo << 24:
+			page = []byte{0, 0, 0, 0, 0, 0, 0, 3, 0x00, 0x01, 0xea}
+		}
+	case opFirmwareDownload:
+		if int(c.CDW11)*4 != len(f.firmware) || int(c.CDW10+1)*4 != len(c.Data) {
+			f.t.Errorf("firmware download of %d bytes at dword %d, NUMD %d, after %d bytes", len(c.Data), c.CDW11, c.CDW10, len(f.firmware))
+		}
+		f.firmware = append(f.firmware, c.Data...)
+		return 0, nil
+	case opFirmwareCommit, opFormatNVM, opSanitize, opSecuritySend:
+		return 0, nil
+	}
+	if page == nil {
+		return 0, &StatusError{Opcode: c.Opcode, Status: 0x002}
+	}
+	copy(c.Data, page)
+	return 0, nil
+}
+
+func TestIdentify(t *testing.T) {
+	d := New(newFake(t))
+	c, err := d.IdentifyController()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Raw = nil
+	want := &Controller{
+		VendorID:                     0x144d,
+		SubsystemVendorID:            0x144d,
+		Serial:                       "S4EWNX0N123456",
+		Model:                        "Example NVMe SSD 1TB",
+		Firmware:                     "2B2QEXM7",
+		IEEE:                         0x002538,
+		MDTS:                         9,
+		ControllerID:                 4,
+		Version:                      0x10300,
+		OACS:                         OACSSecurity | OACSFormat | OACSFirmware | OACSDeviceSelfTest,
+		FirmwareSlots:                3,
+		FirmwareActivateWithoutReset: true,
+		TotalCapacity:                1000204886016,
+		SANICAP:                      SANICAPCryptoErase | SANICAPBlockErase,
+		Namespaces:                   1,
+		FNA:                          FormatCryptoErase,
+		SubsystemNQN:                 "nqn.2014.08.org.nvmexpress:144d144dS4EWNX0N123456 Example NVMe SSD 1TB",
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("IdentifyController = %+v, want %+v", c, want)
+	}
+	if n := c.FirmwareChunk(); n != DefaultFirmwareChunk {
+		t.Errorf("FirmwareChunk = %d, want %d", n, DefaultFirmwareChunk)
+	}
+
+	n, err := d.IdentifyNamespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ID != 1 || n.Size != 1953525168 || n.Utilization != 480236544 || n.BlockSize() != 512 {
+		t.Errorf("IdentifyNamespace = %+v", n)
+	}
+	wantFormats := []LBAFormat{{DataSize: 512, Performance: 2}, {DataSize: 4096}}
+	if !reflect.DeepEqual(n.Formats, wantFormats) {
+		t.Errorf("LBA formats are %+v, want %+v", n.Formats, wantFormats)
+	}
+	if n.EUI64 != [8]byte{0x00, 0x25, 0x38, 0x5a, 0x91, 0xb0, 0x12, 0x34} || n.NGUID[0] != 0x10 {
+		t.Errorf("EUI64 %x, NGUID %x", n.EUI64, n.NGUID)
+	}
+	var se *StatusError
+	if _, err := d.IdentifyNamespace(2); !errors.As(err, &se) || se.Status != 0x00b {
+		t.Errorf("IdentifyNamespace(2) = %v, want invalid namespace", err)
+	}
+	if ids, err := d.ActiveNamespaces(); err != nil || !reflect.DeepEqual(ids, []uint32{1}) {
+		t.Errorf("ActiveNamespaces = %v, %v", ids, err)
+	}
+
+	if _, err := ParseController(make([]byte, 100)); err == nil {
+		t.Errorf("parsing a short controller page succeeded")
+	}
+	bad := append([]byte(nil), n.Raw...)
+	bad[26] = 5
+	if _, err := ParseNamespace(bad); err == nil {
+		t.Errorf("parsing a namespace using a missing format succeeded")
+	}
+	bad[26], bad[130] = 0, 40
+	if _, err := ParseNamespace(bad); err == nil {
+		t.Errorf("parsing a namespace with 2^40 byte blocks succeeded")
+	}
+}
+
+func TestLogs(t *testing.T) {
+	d := New(newFake(t))
+	s, err := d.SMARTLog(AllNamespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SMARTLog{
+		Temperature:             310,
+		AvailableSpare:          100,
+		AvailableSpareThreshold: 10,
+		PercentageUsed:          2,
+		DataUnitsRead:           12345678,
+		DataUnitsWritten:        23456789,
+		HostReads:               345678901,
+		HostWrites:              456789012,
+		ControllerBusyTime:      1234,
+		PowerCycles:             321,
+		PowerOnHours:            9876,
+		UnsafeShutdowns:         17,
+		ErrorLogEntries:         42,
+		Sensors:                 []uint16{310, 318},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("SMARTLog = %+v, want %+v", s, want)
+	}
+
+	fw, err := d.FirmwareSlots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw.Active != 1 || fw.Next != 2 || fw.Revisions[0] != "2B2QEXM7" || fw.Revisions[1] != "3B2QEXM7" || fw.Revisions[2] != "" {
+		t.Errorf("FirmwareSlots = %+v", fw)
+	}
+
+	ss, err := d.SanitizeStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSS := &SanitizeStatus{Progress: 0x8000, State: SanitizeStateInProgress, Passes: 1, GlobalDataErased: true, CDW10: 4, OverwriteTime: 0xffffffff, BlockEraseTime: 120, CryptoEraseTime: 10}
+	if !reflect.DeepEqual(ss, wantSS) {
+		t.Errorf("SanitizeStatus = %+v, want %+v", ss, wantSS)
+	}
+
+	if err := d.GetLogPage(LogError, AllNamespaces, 0, make([]byte, 6)); err == nil {
+		t.Errorf("reading 6 bytes of a log succeeded")
+	}
+	big := make([]byte, 1<<20)
+	if err := d.GetLogPage(LogSMART, AllNamespaces, 1<<33, big); err != nil {
+		t.Fatal(err)
+	}
+	c := d.Admin.(*fakeController).cmds
+	last := c[len(c)-1]
+	if last.CDW10>>16 != 0xffff || last.CDW11 != 3 || last.CDW12 != 0 || last.CDW13 != 2 {
+		t.Errorf("large log read has dwords %#x %#x %#x %#x", last.CDW10, last.CDW11, last.CDW12, last.CDW13)
+	}
+}
+
+func TestFirmware(t *testing.T) {
+	f := newFake(t)
+	d := New(f)
+	image := bytes.Repeat([]byte("fw!!"), 2500)
+	if err := d.DownloadFirmware(image, 4096); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.firmware, image) {
+		t.Errorf("controller got %d bytes of firmware, want %d", len(f.firmware), len(image))
+	}
+	if err := d.CommitFirmware(2, CommitReplaceActivate); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.cmds[len(f.cmds)-1]; c.CDW10 != 2|1<<3 {
+		t.Errorf("commit CDW10 = %#x", c.CDW10)
+	}
+
+	for _, tt := range []struct {
+		image []byte
+		chunk int
+	}{
+		{nil, 4096},
+		{image[:6], 4096},
+		{image, 0},
+		{image, 1023},
+	} {
+		if err := d.DownloadFirmware(tt.image, tt.chunk); err == nil {
+			t.Errorf("downloading %d bytes in %d byte chunks succeeded", len(tt.image), tt.chunk)
+		}
+	}
+	if err := d.CommitFirmware(8, CommitReplace); err == nil {
+		t.Errorf("committing to slot 8 succeeded")
+	}
+	if err := d.CommitFirmware(1, 4); err == nil {
+		t.Errorf("commit action 4 succeeded")
+	}
+
+	f.fail, f.status = opFirmwareCommit, 0x4110
+	err := d.CommitFirmware(1, CommitReplaceActivate)
+	if !NeedsReset(err) {
+		t.Errorf("NeedsReset(%v) = false", err)
+	}
+	if err.Error() != "NVMe command 0x10 failed: firmware activation requires NVM subsystem reset (status 0x4110)" {
+		t.Errorf("error is %q", err)
+	}
+	f.status = 0x106
+	if err := d.CommitFirmware(1, CommitReplaceActivate); err == nil || NeedsReset(err) {
+		t.Errorf("commit to an invalid slot = %v", err)
+	}
+}
+
+func TestFormatSanitize(t *testing.T) {
+	f := newFake(t)
+	d := New(f)
+	if err := d.Format(&Format{NSID: 1, LBAFormat: 1, SecureErase: EraseCrypto, ProtectionInfo: 1, ProtectionFirst: true}); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.cmds[0]; c.NSID != 1 || c.CDW10 != 1|1<<5|1<<8|2<<9 {
+		t.Errorf("format NSID %d CDW10 %#x", c.NSID, c.CDW10)
+	}
+	if err := d.Sanitize(&Sanitize{Action: SanitizeOverwrite, OverwritePasses: 16, Pattern: 0xdeadbeef, InvertPattern: true}); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.cmds[1]; c.CDW10 != 3|1<<8 || c.CDW11 != 0xdeadbeef {
+		t.Errorf("sanitize CDW10 %#x CDW11 %#x", c.CDW10, c.CDW11)
+	}
+	if err := d.Sanitize(&Sanitize{Action: SanitizeCryptoErase, AllowUnrestrictedExit: true, NoDeallocate: true}); err != nil {
+		t.Fatal(err)
+	}
+	if c := f.cmds[2]; c.CDW10 != 4|1<<3|1<<9 {
+		t.Errorf("sanitize CDW10 %#x", c.CDW10)
+	}
+
+	for _, bad := range []*Format{{LBAFormat: 16}, {ProtectionInfo: 4}, {SecureErase: 3}} {
+		if err := d.Format(bad); err == nil {
+			t.Errorf("Format(%+v) succeeded", bad)
+		}
+	}
+	for _, bad := range []*Sanitize{{}, {Action: 5}, {Action: SanitizeOverwrite}, {Action: SanitizeOverwrite, OverwritePasses: 17}} {
+		if err := d.Sanitize(bad); err == nil {
+			t.Errorf("Sanitize(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestOpal(t *testing.T) {
+	f := newFake(t)
+	d := New(f)
+	if p, err := d.SecurityProtocols(); err != nil || !bytes.Equal(p, []byte{0, 1, 0xea}) {
+		t.Errorf("SecurityProtocols = %x, %v", p, err)
+	}
+	disc, err := d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disc.Revision != 1 || len(disc.Features) != 4 {
+		t.Errorf("discovery revision %d with %d features", disc.Revision, len(disc.Features))
+	}
+	wantLocking := &Locking{Supported: true, Enabled: true, MediaEncryption: true, MBREnabled: true}
+	if !reflect.DeepEqual(disc.Locking, wantLocking) {
+		t.Errorf("locking is %+v, want %+v", disc.Locking, wantLocking)
+	}
+	wantSSC := &SSC{Code: FeatureOpal2, Name: "Opal 2", BaseComID: 0x1001, ComIDs: 1}
+	if !reflect.DeepEqual(disc.SSC, wantSSC) {
+		t.Errorf("SSC is %+v, want %+v", disc.SSC, wantSSC)
+	}
+	if err := d.SecuritySend(SecurityTCG, 0x1001, make([]byte, 512)); err != nil {
+		t.Error(err)
+	}
+
+	page := f.pages["opal-discovery"]
+	for _, tt := range []struct {
+		name string
+		b    []byte
+	}{
+		{"short", page[:40]},
+		{"truncated", page[:100]},
+		{"overflowing feature", append(append([]byte{0, 0, 0, 52}, page[4:51]...), 0x10, 0, 0, 0)},
+	} {
+		if _, err := ParseDiscovery(tt.b); err == nil {
+			t.Errorf("parsing a %s discovery succeeded", tt.name)
+		}
+	}
+}
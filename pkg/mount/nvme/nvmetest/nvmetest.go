@@ -0,0 +1,145 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nvmetest has admin command pages for testing NVMe code.
+//
+// The pages are those of a 1 TB controller with two LBA formats: the
+// identify controller and namespace data, the SMART, firmware slot and
+// sanitize status logs, and the TCG level 0 discovery. They are not
+// captured from a drive but encoded by hand from the NVMe 1.4 and Opal
+// 2.01 specifications.
+package nvmetest
+
+import "encoding/binary"
+
+// Pages returns the pages by their nvme-cli names: id-ctrl, id-ns,
+// smart-log, fw-log, sanitize-log and opal-discovery.
+func Pages() map[string][]byte {
+	return map[string][]byte{
+		"id-ctrl":        idCtrl(),
+		"id-ns":          idNs(),
+		"smart-log":      smart(),
+		"fw-log":         fwSlot(),
+		"sanitize-log":   sanitizeStatus(),
+		"opal-discovery": discovery(),
+	}
+}
+
+var le = binary.LittleEndian
+
+func pad(b []byte, s string) {
+	copy(b, s)
+	for i := len(s); i < len(b); i++ {
+		b[i] = ' '
+	}
+}
+
+func idCtrl() []byte {
+	b := make([]byte, 4096)
+	le.PutUint16(b[0:], 0x144d) // VID
+	le.PutUint16(b[2:], 0x144d) // SSVID
+	pad(b[4:24], "S4EWNX0N123456")
+	pad(b[24:64], "Example NVMe SSD 1TB")
+	pad(b[64:72], "2B2QEXM7")
+	b[72] = 2                              // RAB
+	b[73], b[74], b[75] = 0x38, 0x25, 0x00 // IEEE OUI 002538
+	b[77] = 9                              // MDTS: 2 MiB with 4 KiB pages
+	le.PutUint16(b[78:], 4)                // CNTLID
+	le.PutUint32(b[80:], 0x10300)          // VER 1.3
+	le.PutUint16(b[256:], 0x17)            // OACS: security, format, firmware, self-test
+	b[260] = 0x16                          // FRMW: 3 slots, activation without reset
+	b[261] = 0x0f                          // LPA
+	le.PutUint16(b[266:], 0x157)           // WCTEMP 343 K
+	le.PutUint16(b[268:], 0x160)           // CCTEMP 352 K
+	le.PutUint64(b[280:], 1000204886016)   // TNVMCAP
+	b[319] = 0                             // FWUG: no information
+	le.PutUint32(b[328:], 0x3)             // SANICAP: crypto and block erase
+	b[512], b[513] = 0x66, 0x44            // SQES, CQES
+	le.PutUint32(b[516:], 1)               // NN
+	le.PutUint16(b[520:], 0x5f)            // ONCS
+	b[524] = 0x04                          // FNA: crypto erase
+	copy(b[768:], "nqn.2014.08.org.nvmexpress:144d144dS4EWNX0N123456 Example NVMe SSD 1TB")
+	return b
+}
+
+func idNs() []byte {
+	b := make([]byte, 4096)
+	le.PutUint64(b[0:], 1953525168) // NSZE
+	le.PutUint64(b[8:], 1953525168) // NCAP
+	le.PutUint64(b[16:], 480236544) // NUSE
+	b[25] = 1                       // NLBAF: two formats
+	b[26] = 0                       // FLBAS: format 0
+	for i := range b[104:120] {
+		b[104+i] = byte(0x10 + i) // NGUID
+	}
+	copy(b[120:], []byte{0x00, 0x25, 0x38, 0x5a, 0x91, 0xb0, 0x12, 0x34}) // EUI64
+	le.PutUint32(b[128:], 9<<16|2<<24)                                    // LBAF0: 512 bytes, degraded
+	le.PutUint32(b[132:], 12<<16)                                         // LBAF1: 4096 bytes, best
+	return b
+}
+
+func put128(b []byte, v uint64) {
+	le.PutUint64(b, v)
+}
+
+func smart() []byte {
+	b := make([]byte, 512)
+	b[0] = 0
+	le.PutUint16(b[1:], 310) // 37 C
+	b[3], b[4], b[5] = 100, 10, 2
+	put128(b[32:], 12345678)
+	put128(b[48:], 23456789)
+	put128(b[64:], 345678901)
+	put128(b[80:], 456789012)
+	put128(b[96:], 1234)
+	put128(b[112:], 321)
+	put128(b[128:], 9876)
+	put128(b[144:], 17)
+	put128(b[160:], 0)
+	put128(b[176:], 42)
+	le.PutUint16(b[200:], 310)
+	le.PutUint16(b[202:], 318)
+	return b
+}
+
+func fwSlot() []byte {
+	b := make([]byte, 512)
+	b[0] = 0x21 // active slot 1, slot 2 next
+	copy(b[8:], "2B2QEXM7")
+	copy(b[16:], "3B2QEXM7")
+	return b
+}
+
+func sanitizeStatus() []byte {
+	b := make([]byte, 512)
+	le.PutUint16(b[0:], 0x8000)      // half done
+	le.PutUint16(b[2:], 2|1<<3|1<<8) // in progress, 1 pass, globally erased
+	le.PutUint32(b[4:], 4)           // crypto erase
+	le.PutUint32(b[8:], 0xffffffff)
+	le.PutUint32(b[12:], 120)
+	le.PutUint32(b[16:], 10)
+	return b
+}
+
+func discovery() []byte {
+	be := binary.BigEndian
+	b := make([]byte, 2048)
+	off := 48
+	feature := func(code uint16, version uint8, data []byte) {
+		be.PutUint16(b[off:], code)
+		b[off+2] = version << 4
+		b[off+3] = byte(len(data))
+		copy(b[off+4:], data)
+		off += 4 + len(data)
+	}
+	feature(0x0001, 1, []byte{0x11, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	// Locking supported and enabled, unlocked, media encryption, MBR
+	// enabled but not done.
+	feature(0x0002, 1, []byte{0x1b, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	feature(0x0003, 1, make([]byte, 28))
+	feature(0x0203, 2, []byte{0x10, 0x01, 0x00, 0x01, 0, 0x00, 0x04, 0x00, 0x08, 0, 0, 0, 0, 0, 0, 0})
+	be.PutUint32(b[0:], uint32(off-4))
+	be.PutUint32(b[4:], 1)
+	return b
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Security protocols.
+const (
+	// SecurityInfo lists the supported protocols.
+	SecurityInfo = 0x00
+
+	// SecurityTCG is TCG storage, used by Opal.
+	SecurityTCG = 0x01
+)
+
+// SecurityReceive reads len(b) bytes of security protocol data for
+// protocol proto and protocol specific field spsp.
+func (d *Device) SecurityReceive(proto uint8, spsp uint16, b []byte) error {
+	_, err := d.AdminCommand(&Command{
+		Opcode: opSecurityReceive,
+		CDW10:  uint32(proto)<<24 | uint32(spsp)<<8,
+		CDW11:  uint32(len(b)),
+		Data:   b,
+	})
+	return err
+}
+
+// SecuritySend sends b to security protocol proto with protocol specific
+// field spsp.
+func (d *Device) SecuritySend(proto uint8, spsp uint16, b []byte) error {
+	_, err := d.AdminCommand(&Command{
+		Opcode: opSecuritySend,
+		CDW10:  uint32(proto)<<24 | uint32(spsp)<<8,
+		CDW11:  uint32(len(b)),
+		Data:   b,
+	})
+	return err
+}
+
+// SecurityProtocols returns the supported security protocols.
+func (d *Device) SecurityProtocols() ([]uint8, error) {
+	b := make([]byte, 512)
+	if err := d.SecurityReceive(SecurityInfo, 0, b); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(b[6:]))
+	if 8+n > len(b) {
+		return nil, fmt.Errorf("security protocol list of %d entries overflows its %d byte page", n, len(b))
+	}
+	return append([]uint8(nil), b[8:8+n]...), nil
+}
+
+// Level 0 discovery feature codes.
+const (
+	FeatureTPer       = 0x0001
+	FeatureLocking    = 0x0002
+	FeatureGeometry   = 0x0003
+	FeatureEnterprise = 0x0100
+	FeatureOpal1      = 0x0200
+	FeatureSingleUser = 0x0201
+	FeatureDataStore  = 0x0202
+	FeatureOpal2      = 0x0203
+	FeatureOpalite    = 0x0301
+	FeaturePyrite1    = 0x0302
+	FeaturePyrite2    = 0x0303
+	FeatureRuby       = 0x0304
+	FeatureBlockSID   = 0x0402
+)
+
+// sscNames are the security subsystem classes.
+var sscNames = map[uint16]string{
+	FeatureEnterprise: "Enterprise",
+	FeatureOpal1:      "Opal 1.0",
+	FeatureOpal2:      "Opal 2",
+	FeatureOpalite:    "Opalite",
+	FeaturePyrite1:    "Pyrite 1",
+	FeaturePyrite2:    "Pyrite 2",
+	FeatureRuby:       "Ruby",
+}
+
+// Feature is a feature descriptor of a level 0 discovery.
+type Feature struct {
+	Code    uint16
+	Version uint8
+	Data    []byte
+}
+
+// Locking is the locking feature.
+type Locking struct {
+	Supported       bool
+	Enabled         bool
+	Locked          bool
+	MediaEncryption bool
+	MBREnabled      bool
+	MBRDone         bool
+}
+
+// SSC is a security subsystem class feature.
+type SSC struct {
+	Code uint16
+	Name string
+
+	// BaseComID and ComIDs are the range of ComIDs for sessions.
+	BaseComID uint16
+	ComIDs    uint16
+}
+
+// Discovery is the result of a TCG level 0 discovery.
+type Discovery struct {
+	// Revision is the data structure revision.
+	Revision uint32
+
+	Features []Feature
+
+	// Locking is the decoded locking feature, or nil.
+	Locking *Locking
+
+	// SSC is the first security subsystem class, or nil if the device
+	// supports none.
+	SSC *SSC
+}
+
+// ParseDiscovery decodes a level 0 discovery response.
+func ParseDiscovery(b []byte) (*Discovery, error) {
+	if len(b) < 48 {
+		return nil, fmt.Errorf("level 0 discovery of %d bytes is too short", len(b))
+	}
+	be := binary.BigEndian
+	// The length does not count itself.
+	n := int(be.Uint32(b)) + 4
+	if n < 48 || n > len(b) {
+		return nil, fmt.Errorf("level 0 discovery is %d bytes, but has room for %d", n, len(b))
+	}
+	d := &Discovery{Revision: be.Uint32(b[4:])}
+	for off := 48; off < n; {
+		if off+4 > n {
+			return nil, fmt.Errorf("feature descriptor at %d is truncated", off)
+		}
+		f := Feature{Code: be.Uint16(b[off:]), Version: b[off+2] >> 4}
+		l := int(b[off+3])
+		if off+4+l > n {
+			return nil, fmt.Errorf("feature %#04x at %d overflows the discovery", f.Code, off)
+		}
+		f.Data = append([]byte(nil), b[off+4:off+4+l]...)
+		off += 4 + l
+		d.Features = append(d.Features, f)
+
+		switch name, ok := sscNames[f.Code]; {
+		case f.Code == FeatureLocking && len(f.Data) > 0:
+			v := f.Data[0]
+			d.Locking = &Locking{
+				Supported:       v&(1<<0) != 0,
+				Enabled:         v&(1<<1) != 0,
+				Locked:          v&(1<<2) != 0,
+				MediaEncryption: v&(1<<3) != 0,
+				MBREnabled:      v&(1<<4) != 0,
+				MBRDone:         v&(1<<5) != 0,
+			}
+		case ok && d.SSC == nil && len(f.Data) >= 4:
+			d.SSC = &SSC{Code: f.Code, Name: name, BaseComID: be.Uint16(f.Data), ComIDs: be.Uint16(f.Data[2:])}
+		}
+	}
+	return d, nil
+}
+
+// discoverySize is the size of the level 0 discovery buffer. sedutil
+// uses the same.
+const discoverySize = 2048
+
+// Discover does a TCG level 0 discovery, which reports the device's Opal
+// or other TCG storage support and its locking state.
+func (d *Device) Discover() (*Discovery, error) {
+	b := make([]byte, discoverySize)
+	// Level 0 discovery is ComID 1.
+	if err := d.SecurityReceive(SecurityTCG, 1, b); err != nil {
+		return nil, err
+	}
+	return ParseDiscovery(b)
+}
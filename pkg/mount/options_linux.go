@@ -0,0 +1,260 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Options are parsed mount options, as given to mount(8) with -o or in
+// fstab: flags for mount(2), propagation changes, file system data, and
+// options mount(8) itself acts on.
+type Options struct {
+	// Flags are the mount(2) flags, such as MS_RDONLY or MS_BIND.
+	Flags uintptr
+
+	// Propagation is MS_SHARED, MS_PRIVATE, MS_SLAVE or MS_UNBINDABLE,
+	// possibly with MS_REC, or 0 to leave propagation alone.
+	Propagation uintptr
+
+	// Data are the file system specific options, e.g. "mode=0755".
+	Data []string
+
+	// Loop mounts a file through a loop device.
+	Loop bool
+
+	// NoAuto and NoFail are the fstab options: skip the entry in
+	// "mount -a", and ignore a failure to mount it.
+	NoAuto bool
+	NoFail bool
+
+	// User are other options for user space, such as "user", "_netdev"
+	// or "x-systemd.device-timeout=5", which are not passed to the
+	// kernel.
+	User []string
+}
+
+type flagOption struct {
+	set   bool
+	flags uintptr
+}
+
+// flagOptions are the options that set or clear mount(2) flags.
+var flagOptions = map[string]flagOption{
+	"ro":            {true, unix.MS_RDONLY},
+	"rw":            {false, unix.MS_RDONLY},
+	"nosuid":        {true, unix.MS_NOSUID},
+	"suid":          {false, unix.MS_NOSUID},
+	"nodev":         {true, unix.MS_NODEV},
+	"dev":           {false, unix.MS_NODEV},
+	"noexec":        {true, unix.MS_NOEXEC},
+	"exec":          {false, unix.MS_NOEXEC},
+	"sync":          {true, unix.MS_SYNCHRONOUS},
+	"async":         {false, unix.MS_SYNCHRONOUS},
+	"remount":       {true, unix.MS_REMOUNT},
+	"mand":          {true, unix.MS_MANDLOCK},
+	"nomand":        {false, unix.MS_MANDLOCK},
+	"dirsync":       {true, unix.MS_DIRSYNC},
+	"noatime":       {true, unix.MS_NOATIME},
+	"atime":         {false, unix.MS_NOATIME},
+	"nodiratime":    {true, unix.MS_NODIRATIME},
+	"diratime":      {false, unix.MS_NODIRATIME},
+	"bind":          {true, unix.MS_BIND},
+	"rbind":         {true, unix.MS_BIND | unix.MS_REC},
+	"move":          {true, unix.MS_MOVE},
+	"silent":        {true, unix.MS_SILENT},
+	"loud":          {false, unix.MS_SILENT},
+	"relatime":      {true, unix.MS_RELATIME},
+	"norelatime":    {false, unix.MS_RELATIME},
+	"iversion":      {true, unix.MS_I_VERSION},
+	"noiversion":    {false, unix.MS_I_VERSION},
+	"strictatime":   {true, unix.MS_STRICTATIME},
+	"nostrictatime": {false, unix.MS_STRICTATIME},
+	"lazytime":      {true, unix.MS_LAZYTIME},
+	"nolazytime":    {false, unix.MS_LAZYTIME},
+}
+
+// propagationOptions change a mount's propagation type.
+var propagationOptions = map[string]uintptr{
+	"shared":      unix.MS_SHARED,
+	"rshared":     unix.MS_SHARED | unix.MS_REC,
+	"private":     unix.MS_PRIVATE,
+	"rprivate":    unix.MS_PRIVATE | unix.MS_REC,
+	"slave":       unix.MS_SLAVE,
+	"rslave":      unix.MS_SLAVE | unix.MS_REC,
+	"unbindable":  unix.MS_UNBINDABLE,
+	"runbindable": unix.MS_UNBINDABLE | unix.MS_REC,
+}
+
+// userOptions are fstab options for mount(8) alone.
+var userOptions = map[string]bool{
+	"auto":    true,
+	"user":    true,
+	"nouser":  true,
+	"users":   true,
+	"owner":   true,
+	"group":   true,
+	"_netdev": true,
+}
+
+// ParseOptions parses comma separated mount options, such as
+// "ro,nosuid,noexec,relatime,bind" or "defaults,mode=0755". Later options
+// override earlier ones. Options that are not known mount(2) flags or
+// mount(8) options are file system data.
+func ParseOptions(s string) (*Options, error) {
+	o := &Options{}
+	return o, o.Parse(s)
+}
+
+// Parse adds comma separated mount options to o.
+func (o *Options) Parse(s string) error {
+	for _, opt := range strings.Split(s, ",") {
+		if f, ok := flagOptions[opt]; ok {
+			if f.set {
+				o.Flags |= f.flags
+			} else {
+				o.Flags &^= f.flags
+			}
+			continue
+		}
+		if p, ok := propagationOptions[opt]; ok {
+			if o.Propagation != 0 {
+				return fmt.Errorf("mount options %q: more than one propagation type", s)
+			}
+			o.Propagation = p
+			continue
+		}
+		switch {
+		case opt == "" || opt == "defaults":
+		case opt == "loop":
+			o.Loop = true
+		case opt == "noauto":
+			o.NoAuto = true
+		case opt == "nofail":
+			o.NoFail = true
+		case userOptions[opt] || strings.HasPrefix(opt, "x-") || strings.HasPrefix(opt, "comment="):
+			o.User = append(o.User, opt)
+		default:
+			o.Data = append(o.Data, opt)
+		}
+	}
+	return nil
+}
+
+// DataString returns the file system data for mount(2).
+func (o *Options) DataString() string {
+	return strings.Join(o.Data, ",")
+}
+
+// String returns the options in canonical form.
+func (o *Options) String() string {
+	var s []string
+	if o.Flags&unix.MS_RDONLY != 0 {
+		s = append(s, "ro")
+	} else {
+		s = append(s, "rw")
+	}
+	s = append(s, flagNames(o.Flags&^unix.MS_RDONLY)...)
+	for n, p := range propagationOptions {
+		if p == o.Propagation {
+			s = append(s, n)
+		}
+	}
+	if o.Loop {
+		s = append(s, "loop")
+	}
+	if o.NoAuto {
+		s = append(s, "noauto")
+	}
+	if o.NoFail {
+		s = append(s, "nofail")
+	}
+	s = append(s, o.Data...)
+	s = append(s, o.User...)
+	return strings.Join(s, ",")
+}
+
+// flagNameOrder is the order flag names are printed in, as in
+// /proc/mounts.
+var flagNameOrder = []string{
+	"nosuid", "nodev", "noexec", "sync", "remount", "mand", "dirsync",
+	"noatime", "nodiratime", "rbind", "bind", "move", "silent",
+	"relatime", "iversion", "strictatime", "lazytime",
+}
+
+// flagNames returns the names of set flags.
+func flagNames(flags uintptr) []string {
+	var s []string
+	for _, n := range flagNameOrder {
+		f := flagOptions[n].flags
+		if flags&f == f {
+			s = append(s, n)
+			flags &^= f
+		}
+	}
+	return s
+}
+
+// Mount mounts dev at path with the options, with the steps mount(8)
+// takes:
+//
+//   - a move (MS_MOVE) moves the mount at dev to path;
+//   - a remount (MS_REMOUNT) changes the flags and data of the mount at
+//     path, and dev is ignored;
+//   - a bind or rbind mount binds dev, a directory or file, at path, and
+//     then remounts it if flags such as MS_RDONLY are given, since the
+//     kernel ignores them on the bind itself;
+//   - otherwise dev is mounted as fsType, or, if fsType is "" or "auto",
+//     as whatever TryMount finds.
+//
+// Then the propagation type, if any, is set. With only a propagation
+// type, e.g. "rprivate", only the propagation of path is changed.
+func (o *Options) Mount(dev, path, fsType string) (*MountPoint, error) {
+	flags := o.Flags
+	mp := &MountPoint{Path: path, Device: dev, FSType: fsType, Flags: flags, Data: o.DataString()}
+	var err error
+	switch {
+	case flags&unix.MS_MOVE != 0:
+		err = rawMount(dev, path, "", unix.MS_MOVE, "")
+	case flags&unix.MS_REMOUNT != 0:
+		mp.Device = ""
+		err = rawMount("", path, "", flags, mp.Data)
+	case flags&unix.MS_BIND != 0:
+		err = rawMount(dev, path, "", flags&(unix.MS_BIND|unix.MS_REC), "")
+		if rest := flags &^ (unix.MS_BIND | unix.MS_REC); err == nil && rest != 0 {
+			err = rawMount("", path, "", unix.MS_REMOUNT|unix.MS_BIND|rest, "")
+		}
+	case dev == "" && fsType == "" && o.Propagation != 0:
+	case fsType == "" || fsType == "auto":
+		mp, err = TryMount(dev, path, mp.Data, flags)
+	default:
+		mp, err = Mount(dev, path, fsType, mp.Data, flags)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.Propagation != 0 {
+		if err := rawMount("", path, "", o.Propagation, ""); err != nil {
+			return nil, err
+		}
+	}
+	return mp, nil
+}
+
+// rawMount calls mount(2), without creating path.
+func rawMount(dev, path, fsType string, flags uintptr, data string) error {
+	if err := unix.Mount(dev, path, fsType, flags, data); err != nil {
+		return &os.PathError{
+			Op:   "mount",
+			Path: path,
+			Err:  fmt.Errorf("from device %q (fs type %q, flags %#x): %v", dev, fsType, flags, err),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseOptions(t *testing.T) {
+	for _, tt := range []struct {
+		opts string
+		want Options
+		str  string
+		err  bool
+	}{
+		{
+			opts: "defaults",
+			str:  "rw",
+		},
+		{
+			opts: "ro,nosuid,nodev,noexec,relatime",
+			want: Options{Flags: unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC | unix.MS_RELATIME},
+			str:  "ro,nosuid,nodev,noexec,relatime",
+		},
+		{
+			opts: "ro,rw,noexec,exec",
+			str:  "rw",
+		},
+		{
+			opts: "mode=0755,size=10%,nosuid",
+			want: Options{Flags: unix.MS_NOSUID, Data: []string{"mode=0755", "size=10%"}},
+			str:  "rw,nosuid,mode=0755,size=10%",
+		},
+		{
+			opts: "rbind,ro",
+			want: Options{Flags: unix.MS_BIND | unix.MS_REC | unix.MS_RDONLY},
+			str:  "ro,rbind",
+		},
+		{
+			opts: "remount,ro",
+			want: Options{Flags: unix.MS_REMOUNT | unix.MS_RDONLY},
+			str:  "ro,remount",
+		},
+		{
+			opts: "rprivate",
+			want: Options{Propagation: unix.MS_PRIVATE | unix.MS_REC},
+			str:  "rw,rprivate",
+		},
+		{
+			opts: "bind,shared",
+			want: Options{Flags: unix.MS_BIND, Propagation: unix.MS_SHARED},
+			str:  "rw,bind,shared",
+		},
+		{
+			opts: "shared,slave",
+			err:  true,
+		},
+		{
+			opts: "loop,noauto,nofail,user,x-systemd.automount,comment=foo,_netdev",
+			want: Options{Loop: true, NoAuto: true, NoFail: true, User: []string{"user", "x-systemd.automount", "comment=foo", "_netdev"}},
+			str:  "rw,loop,noauto,nofail,user,x-systemd.automount,comment=foo,_netdev",
+		},
+	} {
+		t.Run(tt.opts, func(t *testing.T) {
+			got, err := ParseOptions(tt.opts)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseOptions(%q) = %v, want error %v", tt.opts, err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseOptions(%q) = %+v, want %+v", tt.opts, *got, tt.want)
+			}
+			if s := got.String(); s != tt.str {
+				t.Errorf("String() = %q, want %q", s, tt.str)
+			}
+		})
+	}
+}
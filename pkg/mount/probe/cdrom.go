@@ -0,0 +1,184 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package probe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// ISO 9660 and UDF both start with a volume recognition sequence of
+// 2048-byte descriptors at 32KiB. UDF bridge discs have both, and are
+// reported as UDF.
+const (
+	cdSectorSize   = 2048
+	cdVRSOff       = 16 * cdSectorSize
+	cdMaxVRS       = 64
+	isoPrimary     = 1
+	isoSupplement  = 2
+	isoDateLen     = 16
+	udfAnchorBlock = 256
+)
+
+// Joliet supplementary descriptors carry one of these escape sequences.
+var jolietEscapes = []string{"%/@", "%/C", "%/E"}
+
+func probeISO9660(d *device) *Result {
+	var pvd []byte
+	var joliet string
+	udf := false
+	for i := int64(0); i < cdMaxVRS; i++ {
+		vd := d.read(cdVRSOff+i*cdSectorSize, cdSectorSize)
+		if vd == nil {
+			break
+		}
+		switch id := string(vd[1:6]); {
+		case id == "CD001":
+			switch vd[0] {
+			case isoPrimary:
+				if pvd == nil {
+					pvd = vd
+				}
+			case isoSupplement:
+				if hasPrefix(vd[88:91], jolietEscapes) {
+					joliet = utf16String(vd[40:72], true)
+				}
+			}
+		case id == "NSR02" || id == "NSR03":
+			udf = true
+		case id == "BEA01" || id == "TEA01" || id == "BOOT2" || id == "CDW02":
+		default:
+			// The first unknown descriptor ends the sequence.
+			i = cdMaxVRS
+		}
+	}
+	if udf {
+		return probeUDF(d, pvd)
+	}
+	if pvd == nil {
+		return nil
+	}
+	r := &Result{
+		Type:  "iso9660",
+		Usage: UsageFilesystem,
+		UUID:  isoDate(pvd[813:830]),
+		Label: strings.TrimRight(string(pvd[40:72]), " "),
+	}
+	if joliet != "" {
+		r.Label = jolietLabel(joliet, r.Label)
+	}
+	return r
+}
+
+// jolietLabel returns the Joliet label joliet, completed from the primary
+// label like blkid does if it is the primary one cut to the 16 characters
+// Joliet has room for.
+func jolietLabel(joliet, primary string) string {
+	if n := len(joliet); len([]rune(joliet)) == 16 && len(primary) > n && strings.EqualFold(primary[:n], joliet) {
+		return joliet + primary[n:]
+	}
+	return joliet
+}
+
+// isoDate turns a volume creation or modification time into the UUID
+// blkid reports, e.g. "2020-11-19-10-38-02-00".
+func isoDate(b []byte) string {
+	for _, c := range b[:isoDateLen] {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	if strings.Trim(string(b[:isoDateLen]), "0") == "" {
+		return ""
+	}
+	s := string(b)
+	return fmt.Sprintf("%s-%s-%s-%s-%s-%s-%s", s[0:4], s[4:6], s[6:8], s[8:10], s[10:12], s[12:14], s[14:16])
+}
+
+// UDF descriptor tag identifiers, see ECMA-167 3/7.2.1.
+const (
+	udfTagPrimary    = 1
+	udfTagAnchor     = 2
+	udfTagLogical    = 6
+	udfTagTerminator = 8
+)
+
+// udfBlockSizes are tried in turn to find the anchor volume descriptor.
+var udfBlockSizes = []int64{2048, 512, 1024, 4096}
+
+func probeUDF(d *device, pvd []byte) *Result {
+	r := &Result{Type: "udf", Usage: UsageFilesystem}
+	if pvd != nil {
+		r.Label = strings.TrimRight(string(pvd[40:72]), " ")
+	}
+	for _, bs := range udfBlockSizes {
+		avdp := d.read(udfAnchorBlock*bs, 24)
+		if avdp == nil || binary.LittleEndian.Uint16(avdp) != udfTagAnchor ||
+			binary.LittleEndian.Uint32(avdp[12:]) != udfAnchorBlock {
+			continue
+		}
+		length := int64(binary.LittleEndian.Uint32(avdp[16:]))
+		loc := int64(binary.LittleEndian.Uint32(avdp[20:]))
+		for i := int64(0); i < length/bs && i < cdMaxVRS; i++ {
+			desc := d.read((loc+i)*bs, 212)
+			if desc == nil {
+				break
+			}
+			switch binary.LittleEndian.Uint16(desc) {
+			case udfTagPrimary:
+				if l := dstring(desc[24:56]); l != "" {
+					r.Label = l
+				}
+				r.UUID = udfUUID(dstring(desc[72:200]))
+			case udfTagLogical:
+				// The logical volume identifier is what gets displayed.
+				if l := dstring(desc[84:212]); l != "" {
+					r.Label = l
+				}
+			case udfTagTerminator:
+				i = length
+			}
+		}
+		break
+	}
+	return r
+}
+
+// dstring decodes an OSTA compressed Unicode dstring: the first byte is
+// 8 for 8-bit characters or 16 for UTF-16BE, and the last byte is the
+// number of bytes used.
+func dstring(b []byte) string {
+	n := int(b[len(b)-1])
+	if n < 1 || n >= len(b) {
+		return ""
+	}
+	switch b[0] {
+	case 8:
+		r := make([]rune, 0, n-1)
+		for _, c := range b[1:n] {
+			r = append(r, rune(c))
+		}
+		return strings.TrimRight(string(r), " ")
+	case 16:
+		return utf16String(b[1:n], true)
+	}
+	return ""
+}
+
+// udfUUID derives a UUID from the volume set identifier, which starts
+// with 16 hex digits of a unique value by convention.
+func udfUUID(volSet string) string {
+	if len(volSet) < 16 {
+		return ""
+	}
+	s := strings.ToLower(volSet[:16])
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return fmt.Sprintf("%x", volSet[:8])
+		}
+	}
+	return s
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// See https://gitlab.com/cryptsetup/cryptsetup/-/wikis/Specification.
+var luksMagic = []byte{'L', 'U', 'K', 'S', 0xba, 0xbe}
+
+func probeLUKS(d *device) *Result {
+	h := d.read(0, 208)
+	if h == nil || !bytes.Equal(h[:6], luksMagic) {
+		return nil
+	}
+	r := &Result{
+		Type:  "crypto_LUKS",
+		Usage: UsageCrypto,
+		UUID:  cstring(h[168:208]),
+	}
+	switch v := binary.BigEndian.Uint16(h[6:]); v {
+	case 1:
+		r.Version = "1"
+	case 2:
+		r.Version = "2"
+		r.Label = cstring(h[24:72])
+	default:
+		return nil
+	}
+	return r
+}
+
+// See lib/format_text/layout.h in LVM2. The label may be in any of the
+// first four sectors.
+const (
+	lvmSectorSize = 512
+	lvmLabelScan  = 4
+	lvmLabelID    = "LABELONE"
+	lvmType       = "LVM2 001"
+	lvmUUIDLen    = 32
+	lvmCRCInit    = 0xf597a6cf
+)
+
+// lvmCRC is LVM2's calc_crc, like pkg/lvm's, which can not be imported
+// here as it uses pkg/mount/block.
+func lvmCRC(p []byte) uint32 {
+	return ^crc32.Update(^uint32(lvmCRCInit), crc32.IEEETable, p)
+}
+
+func probeLVM2(d *device) *Result {
+	for s := int64(0); s < lvmLabelScan; s++ {
+		l := d.read(s*lvmSectorSize, lvmSectorSize)
+		if l == nil {
+			return nil
+		}
+		if string(l[:8]) != lvmLabelID || binary.LittleEndian.Uint64(l[8:]) != uint64(s) || string(l[24:32]) != lvmType {
+			continue
+		}
+		// The checksum covers the label from its offset field on.
+		if binary.LittleEndian.Uint32(l[16:]) != lvmCRC(l[20:]) {
+			return nil
+		}
+		off := int(binary.LittleEndian.Uint32(l[20:]))
+		if off+lvmUUIDLen > len(l) {
+			return nil
+		}
+		u := string(l[off : off+lvmUUIDLen])
+		return &Result{
+			Type:    "LVM2_member",
+			Usage:   UsageRAID,
+			Version: lvmType,
+			// LVM prints its UUIDs in groups of 6-4-4-4-4-4-6.
+			UUID: strings.Join([]string{u[0:6], u[6:10], u[10:14], u[14:18], u[18:22], u[22:26], u[26:32]}, "-"),
+		}
+	}
+	return nil
+}
+
+// See https://raid.wiki.kernel.org/index.php/RAID_superblock_formats.
+const (
+	mdMagic = 0xa92b4efc
+
+	// Version 1 superblocks are 256 bytes and a role per device, at
+	// most 4KiB.
+	md1Size    = 256
+	md1MaxSize = 4096
+
+	// Version 0.90 superblocks are 4KiB at the end of the device,
+	// which has to hold at least a reserved 64KiB block.
+	md0Size     = 4096
+	md0Reserved = 64 << 10
+	md0CsumOff  = 152
+)
+
+// mdCsum sums the 32-bit words of b, leaving out the checksum at off, and
+// folds the carries back in, like the kernel's calc_sb_csum and
+// calc_sb_1_csum.
+func mdCsum(b []byte, off int, order binary.ByteOrder) uint32 {
+	var sum uint64
+	for i := 0; i+4 <= len(b); i += 4 {
+		if i != off {
+			sum += uint64(order.Uint32(b[i:]))
+		}
+	}
+	// Version 1 sizes can end in half a word.
+	if len(b)%4 == 2 {
+		sum += uint64(order.Uint16(b[len(b)-2:]))
+	}
+	return uint32(sum&0xffffffff + sum>>32)
+}
+
+func probeMDRaid(d *device) *Result {
+	// Version 1.1 is at the start, 1.2 4KiB in, and 1.0 at least 8KiB
+	// from the end, aligned to 4KiB.
+	for _, v := range []struct {
+		minor string
+		off   int64
+	}{
+		{"1", 0},
+		{"2", 4096},
+		{"0", (d.size - 8192) &^ 4095},
+	} {
+		sb := d.read(v.off, md1Size)
+		if sb == nil || binary.LittleEndian.Uint32(sb) != mdMagic || binary.LittleEndian.Uint32(sb[4:]) != 1 {
+			continue
+		}
+		// The superblock records its own offset in sectors.
+		if binary.LittleEndian.Uint64(sb[144:]) != uint64(v.off)/512 {
+			continue
+		}
+		n := md1Size + 2*int(binary.LittleEndian.Uint32(sb[220:]))
+		if n > md1MaxSize {
+			continue
+		}
+		sb = d.read(v.off, n)
+		if sb == nil || binary.LittleEndian.Uint32(sb[216:]) != mdCsum(sb, 216, binary.LittleEndian) {
+			continue
+		}
+		return &Result{
+			Type:    "linux_raid_member",
+			Usage:   UsageRAID,
+			Version: "1." + v.minor,
+			UUID:    uuid(sb[16:32]),
+			SubUUID: uuid(sb[168:184]),
+			Label:   cstring(sb[32:64]),
+		}
+	}
+
+	// Version 0.90 is in the last 64KiB-aligned 64KiB block, in host
+	// byte order.
+	if d.size < md0Reserved {
+		return nil
+	}
+	off := d.size&^(md0Reserved-1) - md0Reserved
+	sb := d.read(off, md0Size)
+	if sb == nil {
+		return nil
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		if order.Uint32(sb) != mdMagic {
+			continue
+		}
+		major, minor, patch := order.Uint32(sb[4:]), order.Uint32(sb[8:]), order.Uint32(sb[12:])
+		if major != 0 || order.Uint32(sb[md0CsumOff:]) != mdCsum(sb, md0CsumOff, order) {
+			return nil
+		}
+		// The member's data, in KiB, has to fit before the superblock.
+		if int64(order.Uint32(sb[32:]))<<10 > off {
+			return nil
+		}
+		// The set UUID is in words 5 and 13-15, printed as numbers.
+		u := make([]byte, 16)
+		for i, w := range []int{5, 13, 14, 15} {
+			binary.BigEndian.PutUint32(u[4*i:], order.Uint32(sb[4*w:]))
+		}
+		return &Result{
+			Type:    "linux_raid_member",
+			Usage:   UsageRAID,
+			Version: fmt.Sprintf("%d.%d.%d", major, minor, patch),
+			UUID:    uuid(u),
+		}
+	}
+	return nil
+}
+
+// See include/uapi/linux/bcache.h in Linux.
+const bcacheSuperblockOff = 4096
+
+var bcacheMagic = []byte{
+	0xc6, 0x85, 0x73, 0xf6, 0x4e, 0x1a, 0x45, 0xca,
+	0x82, 0x65, 0xf5, 0x7f, 0x48, 0xba, 0x6d, 0x81,
+}
+
+func probeBcache(d *device) *Result {
+	sb := d.read(bcacheSuperblockOff, 104)
+	if sb == nil || !bytes.Equal(sb[24:40], bcacheMagic) {
+		return nil
+	}
+	return &Result{
+		Type:    "bcache",
+		Usage:   UsageOther,
+		UUID:    uuid(sb[40:56]),
+		Label:   cstring(sb[72:104]),
+		Version: fmt.Sprintf("%d", binary.LittleEndian.Uint64(sb[16:])),
+	}
+}
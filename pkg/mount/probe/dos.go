@@ -0,0 +1,268 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package probe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FAT, exFAT and NTFS all start with a boot sector ending in 0x55 0xaa
+// and are told apart by the OEM name or the FAT type string.
+
+const (
+	fatAttrVolumeID = 0x08
+	fatAttrLongName = 0x0f
+	fatDeleted      = 0xe5
+	fatDirEntrySize = 32
+
+	// mkfs.fat writes this when there is no label.
+	fatNoLabel = "NO NAME"
+
+	// The most clusters a FAT of each width can address.
+	fat16MaxClusters = 0xfff4
+	fat32MaxClusters = 0x0ffffff6
+)
+
+func isPowerOf2(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+func probeVFAT(d *device) *Result {
+	bs := d.read(0, 512)
+	if bs == nil || bs[510] != 0x55 || bs[511] != 0xaa || (bs[0] != 0xeb && bs[0] != 0xe9) {
+		return nil
+	}
+	bps := int(binary.LittleEndian.Uint16(bs[11:]))
+	spc := int(bs[13])
+	reserved := int64(binary.LittleEndian.Uint16(bs[14:]))
+	nfats := int64(bs[16])
+	media := bs[21]
+	if !isPowerOf2(bps) || bps < 512 || bps > 4096 || !isPowerOf2(spc) || reserved == 0 || nfats == 0 ||
+		(media != 0xf0 && media < 0xf8) {
+		return nil
+	}
+
+	// The 16-bit sector count is 0 if it does not fit.
+	sectors := int64(binary.LittleEndian.Uint16(bs[19:]))
+	if sectors == 0 {
+		sectors = int64(binary.LittleEndian.Uint32(bs[32:]))
+	}
+	rootEntries := int64(binary.LittleEndian.Uint16(bs[17:]))
+	rootSectors := (rootEntries*fatDirEntrySize + int64(bps) - 1) / int64(bps)
+
+	r := &Result{Type: "vfat", Usage: UsageFilesystem}
+	var rootOff, fatSize, maxClusters int64
+	var rootLen int
+	if string(bs[82:90]) == "FAT32   " {
+		r.Version = "FAT32"
+		r.UUID = serial(bs[67:71])
+		r.Label = cstring(bs[71:82])
+		// Only the first cluster of the root directory is searched.
+		fatSize = int64(binary.LittleEndian.Uint32(bs[36:]))
+		rootCluster := int64(binary.LittleEndian.Uint32(bs[44:]))
+		rootOff = (reserved + nfats*fatSize + (rootCluster-2)*int64(spc)) * int64(bps)
+		rootLen = spc * bps
+		maxClusters = fat32MaxClusters
+	} else {
+		fatSize = int64(binary.LittleEndian.Uint16(bs[22:]))
+		if fatSize == 0 {
+			return nil
+		}
+		r.Version = "FAT16"
+		if string(bs[54:62]) == "FAT12   " {
+			r.Version = "FAT12"
+		}
+		// Only the extended boot record has a serial number and label.
+		if bs[38] == 0x29 {
+			r.UUID = serial(bs[39:43])
+			r.Label = cstring(bs[43:54])
+		}
+		rootOff = (reserved + nfats*fatSize) * int64(bps)
+		rootLen = int(rootEntries) * fatDirEntrySize
+		maxClusters = fat16MaxClusters
+	}
+	// The sectors after the FATs and the root directory have to make
+	// a cluster count the FAT can address.
+	data := sectors - reserved - nfats*fatSize - rootSectors
+	if data <= 0 || data/int64(spc) > maxClusters {
+		return nil
+	}
+
+	// The volume label entry in the root directory takes precedence, as
+	// not every tool updates the boot sector.
+	if l, ok := fatRootLabel(d, rootOff, rootLen); ok {
+		r.Label = l
+	}
+	if r.Label == fatNoLabel {
+		r.Label = ""
+	}
+	return r
+}
+
+func fatRootLabel(d *device, off int64, n int) (string, bool) {
+	dir := d.read(off, n)
+	for i := 0; i+fatDirEntrySize <= len(dir); i += fatDirEntrySize {
+		e := dir[i : i+fatDirEntrySize]
+		switch {
+		case e[0] == 0:
+			return "", false
+		case e[0] == fatDeleted || e[11] == fatAttrLongName:
+		case e[11]&fatAttrVolumeID != 0:
+			return cstring(e[:11]), true
+		}
+	}
+	return "", false
+}
+
+// See https://docs.microsoft.com/en-us/windows/win32/fileio/exfat-specification.
+const (
+	exfatEntryEnd   = 0x00
+	exfatEntryLabel = 0x83
+
+	// exfatMaxDir bounds the root directory search.
+	exfatMaxDir = 64 << 10
+)
+
+func probeExFAT(d *device) *Result {
+	bs := d.read(0, 512)
+	if bs == nil || string(bs[3:11]) != "EXFAT   " {
+		return nil
+	}
+	r := &Result{
+		Type:    "exfat",
+		Usage:   UsageFilesystem,
+		UUID:    serial(bs[100:104]),
+		Version: fmt.Sprintf("%d.%d", bs[105], bs[104]),
+	}
+
+	heap := int64(binary.LittleEndian.Uint32(bs[88:]))
+	root := int64(binary.LittleEndian.Uint32(bs[96:]))
+	bpsShift, spcShift := uint(bs[108]), uint(bs[109])
+	if bpsShift < 9 || bpsShift > 12 || spcShift > 25-bpsShift || root < 2 {
+		return r
+	}
+	n := 1 << (bpsShift + spcShift)
+	if n > exfatMaxDir {
+		n = exfatMaxDir
+	}
+	dir := d.read((heap+(root-2)<<spcShift)<<bpsShift, n)
+	for i := 0; i+32 <= len(dir); i += 32 {
+		switch e := dir[i : i+32]; e[0] {
+		case exfatEntryEnd:
+			return r
+		case exfatEntryLabel:
+			l := int(e[1])
+			if l > 11 {
+				l = 11
+			}
+			r.Label = utf16String(e[2:2+2*l], false)
+			return r
+		}
+	}
+	return r
+}
+
+// See https://flatcap.org/linux-ntfs/ntfs/.
+const (
+	ntfsVolumeRecord   = 3
+	ntfsAttrVolumeName = 0x60
+	ntfsAttrEnd        = 0xffffffff
+
+	ntfsMaxClusterSize = 2 << 20
+)
+
+func probeNTFS(d *device) *Result {
+	bs := d.read(0, 512)
+	if bs == nil || string(bs[3:11]) != "NTFS    " {
+		return nil
+	}
+	// The FAT fields of the boot sector are unused and must be 0.
+	if binary.LittleEndian.Uint16(bs[14:]) != 0 || bs[16] != 0 || binary.LittleEndian.Uint16(bs[17:]) != 0 ||
+		binary.LittleEndian.Uint16(bs[19:]) != 0 || binary.LittleEndian.Uint16(bs[22:]) != 0 ||
+		binary.LittleEndian.Uint32(bs[32:]) != 0 {
+		return nil
+	}
+	var s [8]byte
+	for i := range s {
+		s[i] = bs[0x48+7-i]
+	}
+	r := &Result{
+		Type:  "ntfs",
+		Usage: UsageFilesystem,
+		UUID:  fmt.Sprintf("%x", s[:]),
+	}
+
+	bps := int64(binary.LittleEndian.Uint16(bs[11:]))
+	spc := int64(bs[13])
+	// Large clusters are stored as a negative shift.
+	if spc > 0x80 {
+		spc = 1 << (256 - spc)
+	}
+	if !isPowerOf2(int(bps)) || bps < 256 || bps > 4096 || !isPowerOf2(int(spc)) || spc*bps > ntfsMaxClusterSize {
+		return nil
+	}
+	clusters := binary.LittleEndian.Uint64(bs[0x28:]) / uint64(spc)
+	mft := binary.LittleEndian.Uint64(bs[0x30:])
+	if mft > clusters || binary.LittleEndian.Uint64(bs[0x38:]) > clusters {
+		return nil
+	}
+	var recSize int64
+	if c := int8(bs[0x40]); c < 0 {
+		recSize = 1 << uint(-c)
+	} else {
+		recSize = int64(c) * spc * bps
+	}
+	if recSize < bps || recSize > 64<<10 {
+		return nil
+	}
+	// The MFT starts with its own record.
+	mftOff := int64(mft) * spc * bps
+	if rec := d.read(mftOff, 4); rec == nil || string(rec) != "FILE" {
+		return nil
+	}
+	rec := d.read(mftOff+ntfsVolumeRecord*recSize, int(recSize))
+	if rec == nil || string(rec[:4]) != "FILE" || !ntfsFixup(rec, int(bps)) {
+		return r
+	}
+	for off := int(binary.LittleEndian.Uint16(rec[0x14:])); off+0x18 <= len(rec); {
+		typ := binary.LittleEndian.Uint32(rec[off:])
+		l := int(binary.LittleEndian.Uint32(rec[off+4:]))
+		if typ == ntfsAttrEnd || l == 0 {
+			break
+		}
+		// The volume name is always resident.
+		if typ == ntfsAttrVolumeName && rec[off+8] == 0 {
+			vl := int(binary.LittleEndian.Uint32(rec[off+0x10:]))
+			vo := off + int(binary.LittleEndian.Uint16(rec[off+0x14:]))
+			if vo+vl <= len(rec) {
+				r.Label = utf16String(rec[vo:vo+vl], false)
+			}
+			break
+		}
+		off += l
+	}
+	return r
+}
+
+// ntfsFixup undoes the update sequence protection of an MFT record: the
+// last two bytes of every sector were replaced by a sequence number and
+// saved in an array in the header.
+func ntfsFixup(rec []byte, sectorSize int) bool {
+	usaOff := int(binary.LittleEndian.Uint16(rec[4:]))
+	usaCount := int(binary.LittleEndian.Uint16(rec[6:]))
+	if usaCount == 0 || usaOff+2*usaCount > len(rec) || (usaCount-1)*sectorSize > len(rec) {
+		return false
+	}
+	seq := rec[usaOff : usaOff+2]
+	for i := 1; i < usaCount; i++ {
+		end := i*sectorSize - 2
+		if rec[end] != seq[0] || rec[end+1] != seq[1] {
+			return false
+		}
+		copy(rec[end:end+2], rec[usaOff+2*i:])
+	}
+	return true
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// See https://www.kernel.org/doc/html/latest/filesystems/ext4/globals.html.
+const (
+	extSuperblockOff = 1024
+	extMagic         = 0xef53
+
+	extCompatHasJournal   = 0x4
+	extIncompatFiletype   = 0x2
+	extIncompatRecover    = 0x4
+	extIncompatJournalDev = 0x8
+	extIncompatMetaBG     = 0x10
+	extROCompatSparse     = 0x1
+	extROCompatLargeFile  = 0x2
+	extROCompatBtreeDir   = 0x4
+
+	// Features ext2 and ext3 drivers understand. Anything else needs ext4.
+	ext3Incompat = extIncompatFiletype | extIncompatRecover | extIncompatMetaBG
+	ext3ROCompat = extROCompatSparse | extROCompatLargeFile | extROCompatBtreeDir
+)
+
+func probeExt(d *device) *Result {
+	sb := d.read(extSuperblockOff, 256)
+	if sb == nil || binary.LittleEndian.Uint16(sb[56:]) != extMagic {
+		return nil
+	}
+	compat := binary.LittleEndian.Uint32(sb[92:])
+	incompat := binary.LittleEndian.Uint32(sb[96:])
+	roCompat := binary.LittleEndian.Uint32(sb[100:])
+	r := &Result{
+		Usage:   UsageFilesystem,
+		UUID:    uuid(sb[104:120]),
+		Label:   cstring(sb[120:136]),
+		Version: fmt.Sprintf("1.%d", binary.LittleEndian.Uint16(sb[62:])),
+	}
+	switch {
+	case incompat&extIncompatJournalDev != 0:
+		r.Type, r.Usage = "jbd", UsageOther
+	case incompat&^ext3Incompat != 0 || roCompat&^ext3ROCompat != 0:
+		r.Type = "ext4"
+	case compat&extCompatHasJournal != 0:
+		r.Type = "ext3"
+	default:
+		r.Type = "ext2"
+	}
+	return r
+}
+
+// See fs/xfs/libxfs/xfs_format.h in Linux. Everything is big-endian but
+// the checksum.
+const (
+	xfsMinAGBlocks = 64
+	xfsVersionMask = 0xf
+	xfsVersion5    = 5
+	xfsCRCOff      = 224
+)
+
+func probeXFS(d *device) *Result {
+	sb := d.read(0, 512)
+	if sb == nil || string(sb[:4]) != "XFSB" || !xfsValid(sb) {
+		return nil
+	}
+	// Version 5 superblocks have a CRC-32C over the whole sector.
+	if binary.BigEndian.Uint16(sb[100:])&xfsVersionMask == xfsVersion5 {
+		sb = d.read(0, int(binary.BigEndian.Uint16(sb[102:])))
+		if sb == nil {
+			return nil
+		}
+		crc := crc32.Update(0, castagnoli, sb[:xfsCRCOff])
+		crc = crc32.Update(crc, castagnoli, make([]byte, 4))
+		crc = crc32.Update(crc, castagnoli, sb[xfsCRCOff+4:])
+		if binary.LittleEndian.Uint32(sb[xfsCRCOff:]) != crc {
+			return nil
+		}
+	}
+	return &Result{
+		Type:  "xfs",
+		Usage: UsageFilesystem,
+		UUID:  uuid(sb[32:48]),
+		Label: cstring(sb[108:120]),
+	}
+}
+
+// xfsValid does the sanity checks of xfs_mount_validate_sb that libblkid
+// does too.
+func xfsValid(sb []byte) bool {
+	be := binary.BigEndian
+	blockSize := be.Uint32(sb[4:])
+	dblocks := be.Uint64(sb[8:])
+	rextSize := uint64(be.Uint32(sb[80:]))
+	agBlocks := uint64(be.Uint32(sb[84:]))
+	agCount := uint64(be.Uint32(sb[88:]))
+	sectSize := be.Uint16(sb[102:])
+	inodeSize := be.Uint16(sb[104:])
+	blockLog, sectLog, inodeLog, inopbLog := sb[120], sb[121], sb[122], sb[123]
+	return agCount != 0 &&
+		sectLog >= 9 && sectLog <= 15 && uint32(sectSize) == 1<<sectLog &&
+		blockLog >= 9 && blockLog <= 16 && blockSize == 1<<blockLog &&
+		inodeLog >= 8 && inodeLog <= 11 && uint32(inodeSize) == 1<<inodeLog &&
+		blockLog-inodeLog == inopbLog &&
+		rextSize*uint64(blockSize) >= 4096 && rextSize*uint64(blockSize) <= 1<<30 &&
+		sb[127] <= 100 &&
+		dblocks != 0 && dblocks <= agCount*agBlocks && dblocks >= (agCount-1)*agBlocks+xfsMinAGBlocks
+}
+
+// See https://btrfs.wiki.kernel.org/index.php/On-disk_Format#Superblock.
+const (
+	btrfsSuperblockOff = 0x10000
+	btrfsMagic         = "_BHRfS_M"
+
+	// The dev_item at 0xc9 holds this device's UUID at 0x42.
+	btrfsDevUUIDOff = 0xc9 + 0x42
+)
+
+func probeBtrfs(d *device) *Result {
+	sb := d.read(btrfsSuperblockOff, 0x22b)
+	if sb == nil || string(sb[0x40:0x48]) != btrfsMagic {
+		return nil
+	}
+	return &Result{
+		Type:    "btrfs",
+		Usage:   UsageFilesystem,
+		UUID:    uuid(sb[0x20:0x30]),
+		SubUUID: uuid(sb[btrfsDevUUIDOff : btrfsDevUUIDOff+16]),
+		Label:   cstring(sb[0x12b:0x22b]),
+	}
+}
+
+func probeSquashfs(d *device) *Result {
+	sb := d.read(0, 32)
+	if sb == nil {
+		return nil
+	}
+	var order binary.ByteOrder
+	switch string(sb[:4]) {
+	case "hsqs":
+		order = binary.LittleEndian
+	case "sqsh":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+	return &Result{
+		Type:    "squashfs",
+		Usage:   UsageFilesystem,
+		Version: fmt.Sprintf("%d.%d", order.Uint16(sb[28:]), order.Uint16(sb[30:])),
+	}
+}
+
+// See fs/erofs/erofs_fs.h in Linux.
+const (
+	erofsSuperblockOff = 1024
+	erofsMagic         = 0xe0f5e1e2
+)
+
+func probeEROFS(d *device) *Result {
+	sb := d.read(erofsSuperblockOff, 80)
+	if sb == nil || binary.LittleEndian.Uint32(sb) != erofsMagic {
+		return nil
+	}
+	return &Result{
+		Type:  "erofs",
+		Usage: UsageFilesystem,
+		UUID:  uuid(sb[48:64]),
+		Label: cstring(sb[64:80]),
+	}
+}
+
+// Swap signatures sit at the end of the first page, whatever the page
+// size of the system that made them was.
+var swapPageSizes = []int64{4096, 8192, 16384, 32768, 65536}
+
+// Signatures of hibernation images written over swap areas.
+var suspendMagics = []string{"S1SUSPEND", "S2SUSPEND", "ULSUSPEND", "LINHIB0001"}
+
+func probeSwap(d *device) *Result {
+	for _, ps := range swapPageSizes {
+		m := d.read(ps-10, 10)
+		if m == nil {
+			return nil
+		}
+		r := &Result{Usage: UsageOther}
+		switch {
+		case string(m) == "SWAP-SPACE":
+			// Version 0 has no header.
+			return &Result{Type: "swap", Usage: UsageOther, Version: "0"}
+		case string(m) == "SWAPSPACE2":
+			r.Type, r.Version = "swap", "1"
+		case hasPrefix(m, suspendMagics):
+			r.Type = "swsuspend"
+		default:
+			continue
+		}
+		// The version 1 header follows the boot block.
+		if h := d.read(1024, 44); h != nil {
+			r.UUID = uuid(h[12:28])
+			r.Label = cstring(h[28:44])
+		}
+		return r
+	}
+	return nil
+}
+
+func hasPrefix(b []byte, prefixes []string) bool {
+	for _, p := range prefixes {
+		if bytes.HasPrefix(b, []byte(p)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package probe identifies filesystems and containers by their superblocks.
+//
+// It is a small pure-Go counterpart of libblkid's superblock prober. Type
+// names match the ones blkid reports, so "ext4", "vfat", "crypto_LUKS",
+// "LVM2_member" or "linux_raid_member".
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrUnknown is returned when no known superblock was found.
+var ErrUnknown = errors.New("no known filesystem or container signature")
+
+// Usage says what a probed device is for, like blkid's USAGE.
+type Usage string
+
+// Usages.
+const (
+	// UsageFilesystem is a filesystem that can be mounted.
+	UsageFilesystem Usage = "filesystem"
+
+	// UsageRAID is a member of a RAID set or volume group.
+	UsageRAID Usage = "raid"
+
+	// UsageCrypto is an encrypted container.
+	UsageCrypto Usage = "crypto"
+
+	// UsageOther is anything else, e.g. swap or a bcache backing device.
+	UsageOther Usage = "other"
+)
+
+// Result describes a superblock.
+type Result struct {
+	// Type is the name of the filesystem or container.
+	Type  string
+	Usage Usage
+
+	// UUID identifies the filesystem, or for containers the set or
+	// volume the device belongs to.
+	UUID string
+
+	// SubUUID identifies this device within a multi-device filesystem
+	// or container, e.g. a btrfs device or an mdraid member.
+	SubUUID string
+
+	Label   string
+	Version string
+}
+
+// IsContainer reports whether the device is part of a container rather
+// than holding a filesystem directly.
+func (r *Result) IsContainer() bool {
+	return r.Usage == UsageRAID || r.Usage == UsageCrypto
+}
+
+// prober checks for one kind of superblock.
+type prober struct {
+	name  string
+	probe func(d *device) *Result
+}
+
+// probers are tried in order. Containers come first: RAID1 members and
+// the like also show the filesystem they contain. vfat goes last among the
+// boot sector based types as its checks are the weakest.
+var probers = []prober{
+	{"linux_raid_member", probeMDRaid},
+	{"crypto_LUKS", probeLUKS},
+	{"LVM2_member", probeLVM2},
+	{"bcache", probeBcache},
+	{"xfs", probeXFS},
+	{"ext4", probeExt},
+	{"btrfs", probeBtrfs},
+	{"squashfs", probeSquashfs},
+	{"erofs", probeEROFS},
+	{"iso9660", probeISO9660},
+	{"exfat", probeExFAT},
+	{"ntfs", probeNTFS},
+	{"vfat", probeVFAT},
+	{"swap", probeSwap},
+}
+
+// Probe returns the first known superblock found on r, which is size
+// bytes long.
+func Probe(r io.ReaderAt, size int64) (*Result, error) {
+	d := &device{r: r, size: size}
+	for _, p := range probers {
+		if res := p.probe(d); res != nil {
+			return res, nil
+		}
+		if d.err != nil {
+			return nil, fmt.Errorf("probing %s: %v", p.name, d.err)
+		}
+	}
+	return nil, ErrUnknown
+}
+
+// File probes the file or block device at path.
+func File(path string) (*Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// Stat reports 0 for block devices, seeking to the end works for both.
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Probe(f, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return r, nil
+}
+
+// device reads from the probed device. Reads past the end return nil, so
+// probers only have to check for a nil slice. Other errors are kept in err
+// and stop probing.
+type device struct {
+	r    io.ReaderAt
+	size int64
+	err  error
+}
+
+func (d *device) read(off int64, n int) []byte {
+	if d.err != nil || off < 0 || off+int64(n) > d.size {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := d.r.ReadAt(b, off); err != nil {
+		if err != io.EOF {
+			d.err = err
+		}
+		return nil
+	}
+	return b
+}
+
+// uuid formats b as a UUID. An all-zero UUID is no UUID.
+func uuid(b []byte) string {
+	if isZero(b) {
+		return ""
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// serial formats a 32-bit little-endian volume serial number the way DOS
+// does.
+func serial(b []byte) string {
+	return fmt.Sprintf("%02x%02x-%02x%02x", b[3], b[2], b[1], b[0])
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// cstring returns the NUL-terminated string in b, without trailing spaces.
+func cstring(b []byte) string {
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimRight(string(b), " ")
+}
+
+// utf16String decodes NUL-terminated UTF-16 in the given byte order.
+func utf16String(b []byte, bigEndian bool) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := uint16(b[i]) | uint16(b[i+1])<<8
+		if bigEndian {
+			c = uint16(b[i])<<8 | uint16(b[i+1])
+		}
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return strings.TrimRight(string(utf16.Decode(u)), " ")
+}
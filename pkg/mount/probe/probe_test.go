@@ -0,0 +1,150 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package probe
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	testUUID    = "2183ead8-a510-4b3d-9777-19c7090f66d9"
+	testSubUUID = "5b9e8ea8-2ae9-4c54-95bb-3e8b0a8f3e11"
+)
+
+// image returns the uncompressed contents of testdata/name.img.gz, made
+// by testdata/gen.go.
+func image(t *testing.T, name string) []byte {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name+".img.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestProbe(t *testing.T) {
+	for _, tt := range []struct {
+		image string
+		want  Result
+	}{
+		{"ext2", Result{Type: "ext2", Usage: UsageFilesystem, UUID: testUUID, Label: "myext2", Version: "1.0"}},
+		{"ext3", Result{Type: "ext3", Usage: UsageFilesystem, UUID: testUUID, Label: "myext3", Version: "1.0"}},
+		{"ext4", Result{Type: "ext4", Usage: UsageFilesystem, UUID: testUUID, Label: "myext4", Version: "1.0"}},
+		{"xfs", Result{Type: "xfs", Usage: UsageFilesystem, UUID: "6ddec983-229d-4c1a-b5fa-4681a8f6e665"}},
+		{"btrfs", Result{Type: "btrfs", Usage: UsageFilesystem, UUID: testUUID, SubUUID: testSubUUID, Label: "mybtrfs"}},
+		{"vfat12", Result{Type: "vfat", Usage: UsageFilesystem, UUID: "f1eb-bcce", Version: "FAT12"}},
+		{"vfat16", Result{Type: "vfat", Usage: UsageFilesystem, UUID: "ace5-5144", Label: "MYVFAT", Version: "FAT16"}},
+		{"vfat32", Result{Type: "vfat", Usage: UsageFilesystem, UUID: "ace5-5144", Label: "MYFAT32", Version: "FAT32"}},
+		{"exfat", Result{Type: "exfat", Usage: UsageFilesystem, UUID: "ace5-5144", Label: "MyExFAT", Version: "1.0"}},
+		{"ntfs", Result{Type: "ntfs", Usage: UsageFilesystem, UUID: "5696a7bd96a79c4d", Label: "Test Volume"}},
+		{"iso9660", Result{Type: "iso9660", Usage: UsageFilesystem, UUID: "2018-07-25-23-39-07-00", Label: "Ubuntu-Server 18.04.1 LTS amd64"}},
+		{"udf", Result{Type: "udf", Usage: UsageFilesystem, UUID: "5f8d41a2c0ffee00", Label: "My UDF Disc"}},
+		{"squashfs", Result{Type: "squashfs", Usage: UsageFilesystem, Version: "4.0"}},
+		{"erofs", Result{Type: "erofs", Usage: UsageFilesystem, UUID: testUUID, Label: "myerofs"}},
+		{"swap", Result{Type: "swap", Usage: UsageOther, UUID: testSubUUID, Label: "myswap", Version: "1"}},
+		{"luks1", Result{Type: "crypto_LUKS", Usage: UsageCrypto, UUID: testUUID, Version: "1"}},
+		{"luks2", Result{Type: "crypto_LUKS", Usage: UsageCrypto, UUID: testUUID, Label: "test-label", Version: "2"}},
+		{"lvm2", Result{Type: "LVM2_member", Usage: UsageRAID, UUID: "Zxcvbn-ASDF-ghjk-LQWE-Rtyu-ioPz-xcvbnm", Version: "LVM2 001"}},
+		{"mdraid12", Result{Type: "linux_raid_member", Usage: UsageRAID, UUID: testUUID, SubUUID: testSubUUID, Label: "myhost:0", Version: "1.2"}},
+		{"mdraid090", Result{Type: "linux_raid_member", Usage: UsageRAID, UUID: testUUID, Version: "0.90.0"}},
+		{"bcache", Result{Type: "bcache", Usage: UsageOther, UUID: testUUID, Label: "mybcache", Version: "1"}},
+	} {
+		t.Run(tt.image, func(t *testing.T) {
+			b := image(t, tt.image)
+			got, err := Probe(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Probe = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+// TestProbeChecksum checks that a superblock with a bad checksum is not
+// taken for a good one.
+func TestProbeChecksum(t *testing.T) {
+	for _, tt := range []struct {
+		image string
+		off   int
+	}{
+		{"xfs", 200},
+		{"lvm2", 512 + 100},
+		{"mdraid12", 4096 + 100},
+		{"mdraid090", 2<<20 - 64<<10 + 100},
+	} {
+		t.Run(tt.image, func(t *testing.T) {
+			b := image(t, tt.image)
+			b[tt.off] ^= 1
+			if r, err := Probe(bytes.NewReader(b), int64(len(b))); err != ErrUnknown {
+				t.Errorf("Probe = %+v, %v, want %v", r, err, ErrUnknown)
+			}
+		})
+	}
+}
+
+func TestJolietLabel(t *testing.T) {
+	for _, tt := range []struct {
+		joliet, primary, want string
+	}{
+		{"My Joliet CD", "CDROM", "My Joliet CD"},
+		{"Ubuntu-Server 18", "Ubuntu-Server 18.04.1 LTS amd64", "Ubuntu-Server 18.04.1 LTS amd64"},
+		{"Ubuntu-Server 18", "UBUNTU-SERVER 18.04.1 LTS AMD64", "Ubuntu-Server 18.04.1 LTS AMD64"},
+		{"Ubuntu-Server 18", "CDROM", "Ubuntu-Server 18"},
+	} {
+		if got := jolietLabel(tt.joliet, tt.primary); got != tt.want {
+			t.Errorf("jolietLabel(%q, %q) = %q, want %q", tt.joliet, tt.primary, got, tt.want)
+		}
+	}
+}
+
+func TestProbeUnknown(t *testing.T) {
+	for _, b := range [][]byte{nil, make([]byte, 512), make([]byte, 1<<20)} {
+		if r, err := Probe(bytes.NewReader(b), int64(len(b))); err != ErrUnknown {
+			t.Errorf("Probe(%d zero bytes) = %+v, %v, want %v", len(b), r, err, ErrUnknown)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(image(t, "mdraid090")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// Version 0.90 is found relative to the end of the device.
+	r, err := File(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != "linux_raid_member" || !r.IsContainer() {
+		t.Errorf("File = %+v, want a linux_raid_member container", r)
+	}
+
+	if _, err := File(filepath.Join(os.TempDir(), "does-not-exist")); err == nil {
+		t.Errorf("File on a missing file succeeded")
+	}
+}
@@ -0,0 +1,435 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// gen writes the test images. The ext2/3/4, swap and squashfs images are
+// made with mke2fs, mkswap and mksquashfs, and the FAT16 and FAT32 ones
+// with pkg/mkfs. Others are taken from images made by the real tools:
+//
+//   - vfat12: mkfs.vfat's pkg/mount/loop/testdata/pristine-vfat-disk
+//   - iso9660: the volume descriptors of a released Ubuntu ISO
+//   - xfs: the first block of image.xfs from the xfs/testdata directory
+//     of github.com/masahiro331/go-xfs-filesystem, made by mkfs.xfs
+//   - ntfs: the boot sector and first MFT records of test.ntfs.dd from
+//     the parser/test_data directory of www.velocidex.com/golang/go-ntfs
+//   - luks1 and luks2: the headers of pkg/luks/testdata, made with
+//     libcryptsetup
+//
+// For everything else, gen writes the superblocks the way the real tools
+// do, with the checksums libblkid checks, and images big enough for
+// "blkid -p" to look at them.
+//
+// Run it from this directory with "go run gen.go", with the ISO, XFS and
+// NTFS images in it.
+package main
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unicode/utf16"
+
+	"github.com/u-root/u-root/pkg/mkfs"
+)
+
+var (
+	testUUID = []byte{
+		0x21, 0x83, 0xea, 0xd8, 0xa5, 0x10, 0x4b, 0x3d,
+		0x97, 0x77, 0x19, 0xc7, 0x09, 0x0f, 0x66, 0xd9,
+	}
+	subUUID = []byte{
+		0x5b, 0x9e, 0x8e, 0xa8, 0x2a, 0xe9, 0x4c, 0x54,
+		0x95, 0xbb, 0x3e, 0x8b, 0x0a, 0x8f, 0x3e, 0x11,
+	}
+	le = binary.LittleEndian
+	be = binary.BigEndian
+)
+
+const (
+	uuidString    = "2183ead8-a510-4b3d-9777-19c7090f66d9"
+	subUUIDString = "5b9e8ea8-2ae9-4c54-95bb-3e8b0a8f3e11"
+
+	// ubuntuISO is read for the ISO 9660 volume descriptors, from
+	// http://releases.ubuntu.com/18.04.1/.
+	ubuntuISO = "ubuntu-18.04.1-live-server-amd64.iso"
+
+	xfsImage  = "image.xfs"
+	ntfsImage = "test.ntfs.dd"
+	fatImage  = "../../loop/testdata/pristine-vfat-disk"
+	luksDir   = "../../../luks/testdata"
+
+	// containerSize is more than the minimum size libblkid wants for
+	// RAID and LVM2 members.
+	containerSize = 2 << 20
+)
+
+func utf16le(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+func spaces(s string, n int) []byte {
+	b := []byte(s)
+	for len(b) < n {
+		b = append(b, ' ')
+	}
+	return b
+}
+
+func bootSector(b []byte) {
+	b[0], b[1], b[2] = 0xeb, 0x3c, 0x90
+	b[510], b[511] = 0x55, 0xaa
+}
+
+// extract returns size bytes of file, with only the ranges given by
+// offset and length copied and zeros elsewhere.
+func extract(file string, size int64, ranges ...[2]int64) []byte {
+	f, err := os.Open(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	b := make([]byte, size)
+	for _, r := range ranges {
+		if _, err := f.ReadAt(b[r[0]:r[0]+r[1]], r[0]); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return b
+}
+
+func vfat(size int64, o mkfs.FATOptions) []byte {
+	f, err := ioutil.TempFile("", "vfat")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		log.Fatal(err)
+	}
+	if err := mkfs.FAT(f, size, o); err != nil {
+		log.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return b
+}
+
+func exfat() []byte {
+	b := make([]byte, 64<<10)
+	bootSector(b)
+	copy(b[3:], "EXFAT   ")
+	le.PutUint32(b[88:], 32) // cluster heap offset
+	le.PutUint32(b[96:], 4)  // root directory cluster
+	copy(b[100:], []byte{0x44, 0x51, 0xe5, 0xac})
+	b[104], b[105] = 0, 1 // revision 1.0
+	b[108], b[109] = 9, 3 // 512 bytes per sector, 8 sectors per cluster
+	root := b[(32+2*8)*512:]
+	root[0] = 0x81 // allocation bitmap, skipped
+	root[32] = 0x83
+	root[33] = 7
+	copy(root[34:], utf16le("MyExFAT"))
+	return b
+}
+
+// ntfs keeps the boot sector and the MFT records up to $Volume, which is
+// record 3, of a 10MiB volume with 512 byte sectors, 2 sectors per
+// cluster, 1KiB records and the MFT at cluster 3413.
+func ntfs() []byte {
+	const mft = 3413 * 2 * 512
+	return extract(ntfsImage, 10<<20, [2]int64{0, 512}, [2]int64{mft, 4 << 10})
+}
+
+func isoDescriptor(b []byte, n int, typ byte, id string) []byte {
+	vd := b[(16+n)*2048:][:2048]
+	vd[0] = typ
+	copy(vd[1:], id)
+	vd[6] = 1
+	return vd
+}
+
+// iso9660 returns the volume descriptors of the Ubuntu 18.04.1 live
+// server ISO, sectors 16 to 19, after an empty system area.
+func iso9660() []byte {
+	return extract(ubuntuISO, 20*2048, [2]int64{16 * 2048, 4 * 2048})
+}
+
+func udfTag(b []byte, id uint16, loc uint32) {
+	le.PutUint16(b, id)
+	le.PutUint32(b[12:], loc)
+}
+
+func dstring(b []byte, s string) {
+	b[0] = 8
+	copy(b[1:], s)
+	b[len(b)-1] = byte(len(s) + 1)
+}
+
+func udf() []byte {
+	b := make([]byte, 300*2048)
+	pvd := isoDescriptor(b, 0, 1, "CD001")
+	copy(pvd[40:], spaces("UDF_BRIDGE", 32))
+	isoDescriptor(b, 1, 255, "CD001")
+	isoDescriptor(b, 2, 0, "BEA01")
+	isoDescriptor(b, 3, 0, "NSR02")
+	isoDescriptor(b, 4, 0, "TEA01")
+
+	avdp := b[256*2048:]
+	udfTag(avdp, 2, 256)
+	le.PutUint32(avdp[16:], 3*2048)
+	le.PutUint32(avdp[20:], 32)
+
+	p := b[32*2048:]
+	udfTag(p, 1, 32)
+	dstring(p[24:56], "UDFVOL")
+	dstring(p[72:200], "5f8d41a2c0ffee00 set")
+	l := b[33*2048:]
+	udfTag(l, 6, 33)
+	dstring(l[84:212], "My UDF Disc")
+	udfTag(b[34*2048:], 8, 34)
+	return b
+}
+
+func btrfs() []byte {
+	b := make([]byte, 1<<20)
+	sb := b[0x10000:][:0x1000]
+	copy(sb[0x20:], testUUID)
+	le.PutUint64(sb[0x30:], 0x10000) // this superblock's offset
+	copy(sb[0x40:], "_BHRfS_M")
+	le.PutUint32(sb[0x90:], 4096)  // sector size
+	le.PutUint32(sb[0x94:], 16384) // node size
+	copy(sb[0xc9+0x42:], subUUID)
+	copy(sb[0x12b:], "mybtrfs")
+	// A CRC-32C of the rest of the superblock.
+	le.PutUint32(sb, crc32.Checksum(sb[0x20:], crc32.MakeTable(crc32.Castagnoli)))
+	return b
+}
+
+func xfs() []byte {
+	return extract(xfsImage, 4096, [2]int64{0, 4096})
+}
+
+func squashfs() []byte {
+	dir, err := ioutil.TempDir("", "squashfs")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i := 1; i <= 300; i++ {
+		n := fmt.Sprintf("%03d", i)
+		f := filepath.Join(dir, "file_"+n)
+		if err := ioutil.WriteFile(f, nil, 0644); err != nil {
+			log.Fatal(err)
+		}
+		if err := syscall.Setxattr(f, "user.test", []byte(n), 0); err != nil {
+			log.Fatal(err)
+		}
+	}
+	file := "squashfs.img"
+	os.Remove(file)
+	run("mksquashfs", dir, file, "-comp", "zstd", "-Xcompression-level", "3", "-b", "4k", "-all-root")
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Remove(file)
+	return b
+}
+
+func erofs() []byte {
+	b := make([]byte, 4096)
+	le.PutUint32(b[1024:], 0xe0f5e1e2)
+	b[1024+12] = 12
+	copy(b[1024+48:], testUUID)
+	copy(b[1024+64:], "myerofs")
+	return b
+}
+
+// luks returns the start of a pkg/luks image: the LUKS1 header up to the
+// key material, or the LUKS2 binary header and JSON area.
+func luks(name string, n int64) []byte {
+	return extract(filepath.Join(luksDir, name), n, [2]int64{0, n})
+}
+
+// lvm2 writes a label in sector 1 like pvcreate does, with one data area
+// from 1MiB on and a metadata area from 4KiB to there.
+func lvm2() []byte {
+	b := make([]byte, containerSize)
+	l := b[512:1024]
+	copy(l, "LABELONE")
+	le.PutUint64(l[8:], 1)
+	le.PutUint32(l[20:], 32)
+	copy(l[24:], "LVM2 001")
+	pv := l[32:]
+	copy(pv, "ZxcvbnASDFghjkLQWERtyuioPzxcvbnm")
+	le.PutUint64(pv[32:], containerSize)
+	le.PutUint64(pv[40:], 1<<20)
+	le.PutUint64(pv[72:], 4096)
+	le.PutUint64(pv[80:], 1<<20-4096)
+	// LVM2's CRC of the rest of the sector.
+	le.PutUint32(l[16:], ^crc32.Update(^uint32(0xf597a6cf), crc32.IEEETable, l[20:]))
+	return b
+}
+
+// mdraid12 writes a version 1.2 superblock for the first member of a
+// two disk RAID1 like mdadm does, with data from 1MiB on.
+func mdraid12() []byte {
+	const maxDev = 384
+	b := make([]byte, containerSize)
+	sb := b[4096:][:256+2*maxDev]
+	le.PutUint32(sb, 0xa92b4efc)
+	le.PutUint32(sb[4:], 1)
+	copy(sb[16:], testUUID)
+	copy(sb[32:], "myhost:0")
+	le.PutUint64(sb[64:], 1592827200) // creation time
+	le.PutUint32(sb[72:], 1)          // level
+	le.PutUint64(sb[80:], 2048)       // array size in sectors
+	le.PutUint32(sb[92:], 2)          // RAID disks
+	le.PutUint64(sb[128:], 2048)      // data offset
+	le.PutUint64(sb[136:], 2048)      // data size
+	le.PutUint64(sb[144:], 8)         // superblock offset
+	copy(sb[168:], subUUID)
+	le.PutUint64(sb[192:], 1592827200) // update time
+	le.PutUint64(sb[200:], 1)          // events
+	le.PutUint64(sb[208:], ^uint64(0)) // in sync
+	le.PutUint32(sb[220:], maxDev)
+	for i := 0; i < maxDev; i++ {
+		role := uint16(0xffff)
+		if i < 2 {
+			role = uint16(i)
+		}
+		le.PutUint16(sb[256+2*i:], role)
+	}
+	le.PutUint32(sb[216:], mdCsum(sb, 216))
+	return b
+}
+
+// mdCsum is the kernel's superblock checksum: the sum of the little-endian
+// 32-bit words but the checksum, with the carries folded back in.
+func mdCsum(b []byte, off int) uint32 {
+	var sum uint64
+	for i := 0; i+4 <= len(b); i += 4 {
+		if i != off {
+			sum += uint64(le.Uint32(b[i:]))
+		}
+	}
+	return uint32(sum&0xffffffff + sum>>32)
+}
+
+// mdraid090 writes a version 0.90 superblock for a clean two disk RAID1
+// in the last 64KiB block.
+func mdraid090() []byte {
+	b := make([]byte, containerSize)
+	sb := b[containerSize-64<<10:][:4096]
+	w := func(i int, v uint32) { le.PutUint32(sb[4*i:], v) }
+	w(0, 0xa92b4efc)
+	w(2, 90)                    // minor version
+	w(6, 1592827200)            // creation time
+	w(7, 1)                     // level
+	w(8, containerSize/1024-64) // size in KiB, up to the superblock
+	w(9, 2)                     // disks
+	w(10, 2)                    // RAID disks
+	// The set UUID is in words 5 and 13-15.
+	for i, n := range []int{5, 13, 14, 15} {
+		w(n, be.Uint32(testUUID[4*i:]))
+	}
+	w(32, 1592827200) // update time
+	w(33, 1)          // clean
+	w(34, 2)          // active disks
+	w(35, 2)          // working disks
+	w(39, 1)          // events
+	w(38, mdCsum(sb, 152))
+	return b
+}
+
+func bcache() []byte {
+	b := make([]byte, 8192)
+	sb := b[4096:]
+	le.PutUint64(sb[8:], 8)
+	le.PutUint64(sb[16:], 1)
+	copy(sb[24:], []byte{
+		0xc6, 0x85, 0x73, 0xf6, 0x4e, 0x1a, 0x45, 0xca,
+		0x82, 0x65, 0xf5, 0x7f, 0x48, 0xba, 0x6d, 0x81,
+	})
+	copy(sb[40:], testUUID)
+	copy(sb[56:], subUUID)
+	copy(sb[72:], "mybcache")
+	return b
+}
+
+func run(name string, args ...string) {
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		log.Fatalf("%s: %v: %s", name, err, out)
+	}
+}
+
+func tool(file, size string, cmd ...string) []byte {
+	os.Remove(file)
+	run("truncate", "-s", size, file)
+	run(cmd[0], append(cmd[1:], file)...)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Remove(file)
+	return b
+}
+
+func write(name string, b []byte) {
+	f, err := os.Create(name + ".img.gz")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	z, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := z.Write(b); err != nil {
+		log.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	write("ext2", tool("ext2.img", "1M", "mke2fs", "-q", "-F", "-t", "ext2", "-L", "myext2", "-U", uuidString))
+	write("ext3", tool("ext3.img", "4M", "mke2fs", "-q", "-F", "-t", "ext3", "-L", "myext3", "-U", uuidString))
+	write("ext4", tool("ext4.img", "1M", "mke2fs", "-q", "-F", "-t", "ext4", "-L", "myext4", "-U", uuidString))
+	write("swap", tool("swap.img", "64K", "mkswap", "-L", "myswap", "-U", subUUIDString))
+	t := time.Date(2020, 6, 22, 12, 0, 0, 0, time.UTC)
+	write("vfat12", extract(fatImage, 1<<20, [2]int64{0, 1 << 20}))
+	write("vfat16", vfat(4<<20, mkfs.FATOptions{Type: 16, Label: "myvfat", Serial: 0xace55144, Time: t}))
+	write("vfat32", vfat(33<<20, mkfs.FATOptions{Type: 32, Label: "myfat32", Serial: 0xace55144, Time: t}))
+	write("exfat", exfat())
+	write("ntfs", ntfs())
+	write("iso9660", iso9660())
+	write("udf", udf())
+	write("btrfs", btrfs())
+	write("xfs", xfs())
+	write("squashfs", squashfs())
+	write("erofs", erofs())
+	write("luks1", luks("luks1.img", 4096))
+	write("luks2", luks("luks2.img", 16<<10))
+	write("lvm2", lvm2())
+	write("mdraid12", mdraid12())
+	write("mdraid090", mdraid090())
+	write("bcache", bcache())
+}
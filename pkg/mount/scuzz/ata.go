@@ -9,6 +9,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // direction is the transfer direction.
@@ -151,5 +152,37 @@ func unpackIdentify(s statusBlock, d dataBlock, w wordBlock) *Info {
 	info.MasterPasswordRev = w[92]
 	info.SecurityStatus = w[128]
 	info.TrustedComputingSupport = w[48]
+
+	sec := binary.LittleEndian.Uint16(d[256:258])
+	info.SecuritySupported = sec&securitySupported != 0
+	info.SecurityEnabled = sec&securityEnabled != 0
+	info.SecurityLocked = sec&securityLocked != 0
+	info.SecurityFrozen = sec&securityFrozen != 0
+	info.SecurityCountExpired = sec&securityCountExpired != 0
+	info.EnhancedEraseSupported = sec&securityEnhancedErase != 0
+	info.SecurityEraseTime = eraseTime(binary.LittleEndian.Uint16(d[178:180]))
+	info.EnhancedEraseTime = eraseTime(binary.LittleEndian.Uint16(d[180:182]))
 	return &info
 }
+
+// These are the bits of identify word 128, the security status.
+const (
+	securitySupported     = 1 << 0
+	securityEnabled       = 1 << 1
+	securityLocked        = 1 << 2
+	securityFrozen        = 1 << 3
+	securityCountExpired  = 1 << 4
+	securityEnhancedErase = 1 << 5
+)
+
+// eraseTime decodes identify words 89 and 90, the time to complete a
+// (enhanced) security erase. Bit 15 selects the extended format; in both
+// formats the value counts 2 minute units, and 0 means "not reported".
+func eraseTime(v uint16) time.Duration {
+	if v&0x8000 != 0 {
+		v &= 0x7fff
+	} else {
+		v &= 0xff
+	}
+	return time.Duration(v) * 2 * time.Minute
+}
@@ -6,6 +6,7 @@ package scuzz
 
 import (
 	"testing"
+	"time"
 )
 
 func TestAtaString(t *testing.T) {
@@ -51,3 +52,23 @@ func TestMustLBA(t *testing.T) {
 		t.Errorf("good mustLBA: got %v, want nil", err)
 	}
 }
+
+func TestUnpackSecurity(t *testing.T) {
+	var d dataBlock
+	// Supported, enabled, frozen, enhanced erase supported.
+	d[256] = 0x2b
+	// 60 minutes, in the short format; 3 hours in the extended format.
+	d[178] = 30
+	d[180], d[181] = 90, 0x80
+	w, err := d.toWordBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := unpackIdentify(statusBlock{}, d, w)
+	if !i.SecuritySupported || !i.SecurityEnabled || i.SecurityLocked || !i.SecurityFrozen || i.SecurityCountExpired || !i.EnhancedEraseSupported {
+		t.Errorf("security bits: got %+v", i)
+	}
+	if i.SecurityEraseTime != time.Hour || i.EnhancedEraseTime != 3*time.Hour {
+		t.Errorf("erase times: got %v, %v, want 1h, 3h", i.SecurityEraseTime, i.EnhancedEraseTime)
+	}
+}
@@ -31,6 +31,20 @@ type Info struct {
 	OrigSerial           string
 	OrigModel            string
 	OrigFirmwareRevision string
+
+	// These are the state of the ATA security feature set, from word
+	// 128 of the identify data.
+	SecuritySupported      bool
+	SecurityEnabled        bool
+	SecurityLocked         bool
+	SecurityFrozen         bool
+	SecurityCountExpired   bool
+	EnhancedEraseSupported bool
+
+	// SecurityEraseTime and EnhancedEraseTime are the drive's estimates
+	// of how long SECURITY ERASE UNIT takes, or 0 if it gives none.
+	SecurityEraseTime time.Duration
+	EnhancedEraseTime time.Duration
 }
 
 // Disk is the interface to a disk, with operations to create packets and
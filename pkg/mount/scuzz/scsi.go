@@ -0,0 +1,187 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scuzz
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// SCSI operation codes.
+const (
+	scsiRequestSense       = 0x03
+	scsiSanitize           = 0x48
+	scsiMaintenanceIn      = 0xa3
+	scsiReportSupportedOps = 0x0c // MAINTENANCE IN service action
+)
+
+// SanitizeAction is a SCSI SANITIZE service action.
+type SanitizeAction uint8
+
+// These are the SANITIZE service actions.
+const (
+	SanitizeOverwrite   SanitizeAction = 0x01
+	SanitizeBlockErase  SanitizeAction = 0x02
+	SanitizeCryptoErase SanitizeAction = 0x03
+	SanitizeExitFailure SanitizeAction = 0x1f
+)
+
+var sanitizeNames = map[SanitizeAction]string{
+	SanitizeOverwrite:   "overwrite",
+	SanitizeBlockErase:  "block erase",
+	SanitizeCryptoErase: "crypto erase",
+	SanitizeExitFailure: "exit failure mode",
+}
+
+func (a SanitizeAction) String() string {
+	if s, ok := sanitizeNames[a]; ok {
+		return s
+	}
+	return fmt.Sprintf("SanitizeAction(%#x)", uint8(a))
+}
+
+// Sanitize describes a SCSI SANITIZE command, which erases all user data
+// on a disk, including caches and reallocated blocks. The command is
+// always issued with IMMED set; use SanitizeProgress to follow it.
+type Sanitize struct {
+	Action SanitizeAction
+
+	// AllowUnrestrictedExit allows a failed sanitize to be left with
+	// SanitizeExitFailure, rather than only by another sanitize.
+	AllowUnrestrictedExit bool
+
+	// OverwritePasses is the number of passes for SanitizeOverwrite,
+	// 1 to 31. Pattern is written on each pass, inverted between passes
+	// if Invert is true. Pattern must be 1 to a block size of bytes.
+	OverwritePasses int
+	Pattern         []byte
+	Invert          bool
+}
+
+// cdb returns the SANITIZE command descriptor block and parameter list.
+func (s *Sanitize) cdb() ([]byte, []byte, error) {
+	cdb := make([]byte, 10)
+	cdb[0] = scsiSanitize
+	cdb[1] = 1<<7 | uint8(s.Action) // IMMED
+	if s.AllowUnrestrictedExit {
+		cdb[1] |= 1 << 5
+	}
+	switch s.Action {
+	case SanitizeBlockErase, SanitizeCryptoErase, SanitizeExitFailure:
+		return cdb, nil, nil
+	case SanitizeOverwrite:
+	default:
+		return nil, nil, fmt.Errorf("invalid sanitize action %v", s.Action)
+	}
+	if s.OverwritePasses < 1 || s.OverwritePasses > 31 {
+		return nil, nil, fmt.Errorf("overwrite passes %d out of range 1-31", s.OverwritePasses)
+	}
+	if len(s.Pattern) == 0 || len(s.Pattern) > 0xffff-4 {
+		return nil, nil, fmt.Errorf("invalid overwrite pattern length %d", len(s.Pattern))
+	}
+	param := make([]byte, 4+len(s.Pattern))
+	param[0] = uint8(s.OverwritePasses)
+	if s.Invert {
+		param[0] |= 1 << 7
+	}
+	binary.BigEndian.PutUint16(param[2:], uint16(len(s.Pattern)))
+	copy(param[4:], s.Pattern)
+	binary.BigEndian.PutUint16(cdb[7:], uint16(len(param)))
+	return cdb, param, nil
+}
+
+// supportedCDB returns a REPORT SUPPORTED OPERATION CODES command asking
+// about one operation code and service action.
+func supportedCDB(op uint8, sa uint16, alloc uint32) []byte {
+	cdb := make([]byte, 12)
+	cdb[0] = scsiMaintenanceIn
+	cdb[1] = scsiReportSupportedOps
+	cdb[2] = 0x02 // one command, with service action
+	cdb[3] = op
+	binary.BigEndian.PutUint16(cdb[4:], sa)
+	binary.BigEndian.PutUint32(cdb[6:], alloc)
+	return cdb
+}
+
+// supported decodes the SUPPORT field of a one command REPORT SUPPORTED
+// OPERATION CODES reply: 3 and 5 mean supported, per the standard or in
+// a vendor specific way.
+func supported(b []byte) bool {
+	if len(b) < 2 {
+		return false
+	}
+	s := b[1] & 7
+	return s == 3 || s == 5
+}
+
+// SenseError is a SCSI command failure, as described by its sense data.
+type SenseError struct {
+	Status uint8
+	Key    uint8
+	ASC    uint8
+	ASCQ   uint8
+}
+
+var senseKeys = []string{
+	"no sense", "recovered error", "not ready", "medium error",
+	"hardware error", "illegal request", "unit attention", "data protect",
+	"blank check", "vendor specific", "copy aborted", "aborted command",
+	"reserved", "volume overflow", "miscompare", "completed",
+}
+
+func (e *SenseError) Error() string {
+	return fmt.Sprintf("SCSI status %#02x: %s (ASC/ASCQ %#02x/%#02x)", e.Status, senseKeys[e.Key&0xf], e.ASC, e.ASCQ)
+}
+
+// sense is decoded sense data.
+type sense struct {
+	key, asc, ascq uint8
+
+	// progress is the sense key specific progress indication, out of
+	// 65536, or -1 if there is none.
+	progress int
+}
+
+// parseSense decodes fixed or descriptor format sense data.
+func parseSense(b []byte) (*sense, error) {
+	if len(b) < 1 {
+		return nil, fmt.Errorf("no sense data")
+	}
+	s := &sense{progress: -1}
+	switch b[0] & 0x7f {
+	case 0x70, 0x71:
+		if len(b) < 14 {
+			return nil, fmt.Errorf("fixed format sense data too short: %d bytes", len(b))
+		}
+		s.key, s.asc, s.ascq = b[2]&0xf, b[12], b[13]
+		if len(b) >= 18 && b[15]&0x80 != 0 {
+			s.progress = int(binary.BigEndian.Uint16(b[16:]))
+		}
+	case 0x72, 0x73:
+		if len(b) < 8 {
+			return nil, fmt.Errorf("descriptor format sense data too short: %d bytes", len(b))
+		}
+		s.key, s.asc, s.ascq = b[1]&0xf, b[2], b[3]
+		end := 8 + int(b[7])
+		if end > len(b) {
+			end = len(b)
+		}
+		for d := b[8:end]; len(d) >= 2 && len(d) >= 2+int(d[1]); d = d[2+int(d[1]):] {
+			// Sense key specific descriptor.
+			if d[0] == 0x02 && len(d) >= 7 && d[4]&0x80 != 0 {
+				s.progress = int(binary.BigEndian.Uint16(d[5:]))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown sense data response code %#02x", b[0])
+	}
+	return s, nil
+}
+
+// sanitizing reports whether the sense data says a sanitize is running:
+// NOT READY, "logical unit not ready, sanitize in progress".
+func (s *sense) sanitizing() bool {
+	return s.key == 0x2 && s.asc == 0x04 && s.ascq == 0x1b
+}
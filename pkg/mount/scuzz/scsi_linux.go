@@ -0,0 +1,115 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scuzz
+
+import (
+	"os"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// SCSIDisk sends SCSI commands to a disk through the Linux SCSI Generic
+// interface. It is for disks that speak SCSI natively, such as SAS disks;
+// use SGDisk for ATA commands.
+type SCSIDisk struct {
+	f *os.File
+
+	// Timeout is the timeout on a disk operation.
+	Timeout time.Duration
+}
+
+// NewSCSIDisk opens the disk at n for SCSI commands.
+func NewSCSIDisk(n string) (*SCSIDisk, error) {
+	f, err := os.OpenFile(n, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &SCSIDisk{f: f, Timeout: DefaultTimeout}, nil
+}
+
+// Close closes the disk.
+func (s *SCSIDisk) Close() error {
+	return s.f.Close()
+}
+
+// command issues cdb, transferring data in direction dir.
+func (s *SCSIDisk) command(cdb []byte, dir direction, data []byte) error {
+	var sb statusBlock
+	h := packetHeader{
+		interfaceID:       'S',
+		direction:         dir,
+		cmdLen:            uint8(len(cdb)),
+		maxStatusBlockLen: uint8(len(sb)),
+		dataLen:           uint32(len(data)),
+		cdb:               uintptr(unsafe.Pointer(&cdb[0])),
+		sb:                uintptr(unsafe.Pointer(&sb[0])),
+		timeout:           uint32(s.Timeout.Milliseconds()),
+	}
+	if len(data) > 0 {
+		h.data = uintptr(unsafe.Pointer(&data[0]))
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, s.f.Fd(), _SG_IO, uintptr(unsafe.Pointer(&h)))
+	runtime.KeepAlive(cdb)
+	runtime.KeepAlive(data)
+	runtime.KeepAlive(&sb)
+	if errno != 0 {
+		return &os.PathError{Op: "ioctl SG_IO", Path: s.f.Name(), Err: errno}
+	}
+	if h.status == 0 && h.hostStatus == 0 {
+		return nil
+	}
+	e := &SenseError{Status: h.status}
+	if sn, err := parseSense(sb[:h.sbLen]); err == nil {
+		e.Key, e.ASC, e.ASCQ = sn.key, sn.asc, sn.ascq
+	}
+	return &os.PathError{Op: "SCSI command", Path: s.f.Name(), Err: e}
+}
+
+// SanitizeSupported reports whether the disk supports SANITIZE with
+// action a.
+func (s *SCSIDisk) SanitizeSupported(a SanitizeAction) (bool, error) {
+	var b [16]byte
+	if err := s.command(supportedCDB(scsiSanitize, uint16(a), uint32(len(b))), _SG_DXFER_FROM_DEV, b[:]); err != nil {
+		return false, err
+	}
+	return supported(b[:]), nil
+}
+
+// Sanitize starts a sanitize. It returns once the disk has accepted the
+// command; use SanitizeProgress to wait for it to finish.
+func (s *SCSIDisk) Sanitize(z *Sanitize) error {
+	cdb, param, err := z.cdb()
+	if err != nil {
+		return err
+	}
+	if param == nil {
+		return s.command(cdb, _SG_DXFER_NONE, nil)
+	}
+	return s.command(cdb, _SG_DXFER_TO_DEV, param)
+}
+
+// SanitizeProgress reports whether a sanitize is still running and, if
+// the disk reports it, how far along it is, from 0 to 1.
+func (s *SCSIDisk) SanitizeProgress() (bool, float64, error) {
+	b := make([]byte, 252)
+	cdb := []byte{scsiRequestSense, 0, 0, 0, uint8(len(b)), 0}
+	if err := s.command(cdb, _SG_DXFER_FROM_DEV, b); err != nil {
+		return false, 0, err
+	}
+	sn, err := parseSense(b)
+	if err != nil {
+		return false, 0, err
+	}
+	if !sn.sanitizing() {
+		return false, 1, nil
+	}
+	if sn.progress < 0 {
+		return true, 0, nil
+	}
+	return true, float64(sn.progress) / 65536, nil
+}
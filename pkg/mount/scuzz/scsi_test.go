@@ -0,0 +1,98 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scuzz
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSanitizeCDB(t *testing.T) {
+	for _, tt := range []struct {
+		s     Sanitize
+		cdb   []byte
+		param []byte
+		err   bool
+	}{
+		{s: Sanitize{Action: SanitizeCryptoErase}, cdb: []byte{0x48, 0x83, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{s: Sanitize{Action: SanitizeBlockErase, AllowUnrestrictedExit: true}, cdb: []byte{0x48, 0xa2, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{
+			s:     Sanitize{Action: SanitizeOverwrite, OverwritePasses: 3, Pattern: []byte{0xaa, 0x55}, Invert: true},
+			cdb:   []byte{0x48, 0x81, 0, 0, 0, 0, 0, 0, 6, 0},
+			param: []byte{0x83, 0, 0, 2, 0xaa, 0x55},
+		},
+		{s: Sanitize{Action: SanitizeOverwrite, OverwritePasses: 32, Pattern: []byte{0}}, err: true},
+		{s: Sanitize{Action: SanitizeOverwrite, OverwritePasses: 1}, err: true},
+		{s: Sanitize{Action: 7}, err: true},
+	} {
+		cdb, param, err := tt.s.cdb()
+		if (err != nil) != tt.err {
+			t.Errorf("%+v: got err %v, want err %v", tt.s, err, tt.err)
+			continue
+		}
+		if !bytes.Equal(cdb, tt.cdb) || !bytes.Equal(param, tt.param) {
+			t.Errorf("%+v: got % x, % x, want % x, % x", tt.s, cdb, param, tt.cdb, tt.param)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	want := []byte{0xa3, 0x0c, 0x02, 0x48, 0x00, 0x03, 0, 0, 0, 16, 0, 0}
+	if got := supportedCDB(scsiSanitize, uint16(SanitizeCryptoErase), 16); !bytes.Equal(got, want) {
+		t.Errorf("supportedCDB: got % x, want % x", got, want)
+	}
+	for _, tt := range []struct {
+		b    []byte
+		want bool
+	}{
+		{[]byte{0, 3}, true},
+		{[]byte{0, 5}, true},
+		{[]byte{0, 1}, false},
+		{[]byte{0}, false},
+	} {
+		if got := supported(tt.b); got != tt.want {
+			t.Errorf("supported(% x): got %v, want %v", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseSense(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		b          []byte
+		sanitizing bool
+		progress   int
+	}{
+		{
+			name:       "fixed",
+			b:          []byte{0x70, 0, 0x02, 0, 0, 0, 0, 10, 0, 0, 0, 0, 0x04, 0x1b, 0, 0x80, 0x40, 0x00},
+			sanitizing: true,
+			progress:   0x4000,
+		},
+		{
+			name:     "fixed no progress",
+			b:        []byte{0x70, 0, 0x00, 0, 0, 0, 0, 10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			progress: -1,
+		},
+		{
+			name:       "descriptor",
+			b:          []byte{0x72, 0x02, 0x04, 0x1b, 0, 0, 0, 8, 0x02, 0x06, 0, 0, 0x80, 0x80, 0x00, 0},
+			sanitizing: true,
+			progress:   0x8000,
+		},
+	} {
+		s, err := parseSense(tt.b)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if s.sanitizing() != tt.sanitizing || s.progress != tt.progress {
+			t.Errorf("%s: got sanitizing %v progress %d, want %v %d", tt.name, s.sanitizing(), s.progress, tt.sanitizing, tt.progress)
+		}
+	}
+	if _, err := parseSense([]byte{0x12}); err == nil {
+		t.Errorf("parseSense(bad): got nil, want error")
+	}
+}
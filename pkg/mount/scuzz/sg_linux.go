@@ -245,6 +245,76 @@ func (s *SGDisk) Unlock(password string, admin bool) error {
 	return nil
 }
 
+func (s *SGDisk) setPasswordPacket(password string, admin bool) *packet {
+	p := s.newPacket(unix.WIN_SECURITY_SET_PASS, _SG_DXFER_TO_DEV, lba48)
+	p.genCommandDataBlock()
+	if admin {
+		p.block[0] = 1
+	}
+	copy(p.block[2:34], []byte(password))
+	return p
+}
+
+// SetPassword sets the user or admin (master) password, which enables
+// the ATA security feature set. The user password is set with high
+// security, so that the admin password can still unlock the drive.
+func (s *SGDisk) SetPassword(password string, admin bool) error {
+	return s.operate(s.setPasswordPacket(password, admin))
+}
+
+func (s *SGDisk) disablePasswordPacket(password string, admin bool) *packet {
+	p := s.newPacket(unix.WIN_SECURITY_DISABLE, _SG_DXFER_TO_DEV, lba48)
+	p.genCommandDataBlock()
+	if admin {
+		p.block[0] = 1
+	}
+	copy(p.block[2:34], []byte(password))
+	return p
+}
+
+// DisablePassword removes the user password with SECURITY DISABLE
+// PASSWORD, which disables the ATA security feature set again. password
+// is the user password, or the admin password if admin is true.
+func (s *SGDisk) DisablePassword(password string, admin bool) error {
+	return s.operate(s.disablePasswordPacket(password, admin))
+}
+
+func (s *SGDisk) erasePreparePacket() *packet {
+	p := s.newPacket(unix.WIN_SECURITY_ERASE_PREPARE, _SG_DXFER_NONE, lba48)
+	p.dataLen = 0
+	p.nsect = 0
+	p.genCommandDataBlock()
+	return p
+}
+
+func (s *SGDisk) eraseUnitPacket(password string, admin, enhanced bool, timeout time.Duration) *packet {
+	p := s.newPacket(unix.WIN_SECURITY_ERASE_UNIT, _SG_DXFER_TO_DEV, lba48)
+	p.timeout = uint32(timeout.Milliseconds())
+	p.genCommandDataBlock()
+	if admin {
+		p.block[0] |= 1
+	}
+	if enhanced {
+		p.block[0] |= 2
+	}
+	copy(p.block[2:34], []byte(password))
+	return p
+}
+
+// SecurityErase erases the whole drive with SECURITY ERASE UNIT, which
+// also disables the password. The password must have been set with
+// SetPassword first. If enhanced is true, an enhanced erase, which
+// also covers reallocated sectors, is done.
+//
+// The erase can take hours; timeout bounds the wait for it, and should
+// be taken from Info.SecurityEraseTime or Info.EnhancedEraseTime.
+func (s *SGDisk) SecurityErase(password string, admin, enhanced bool, timeout time.Duration) error {
+	if err := s.operate(s.erasePreparePacket()); err != nil {
+		return err
+	}
+	return s.operate(s.eraseUnitPacket(password, admin, enhanced, timeout))
+}
+
 func (s *SGDisk) identifyPacket() *packet {
 	p := s.newPacket(unix.WIN_IDENTIFY, _SG_DXFER_FROM_DEV, 0)
 	p.genCommandDataBlock()
@@ -8,6 +8,7 @@ package scuzz
 
 import (
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -186,3 +187,37 @@ func TestIdentify(t *testing.T) {
 	p := (&SGDisk{dev: 0x40, Timeout: DefaultTimeout}).identifyPacket()
 	check(t, p, want)
 }
+
+func TestSecurityErase(t *testing.T) {
+	d := &SGDisk{dev: 0x40, Timeout: DefaultTimeout}
+
+	p := d.setPasswordPacket("erase", false)
+	if p.command[14] != 0xf1 || p.direction != _SG_DXFER_TO_DEV || p.dataLen != 512 {
+		t.Errorf("set password: command %#x, direction %d, dataLen %d", p.command[14], p.direction, p.dataLen)
+	}
+	if p.block[0] != 0 || string(p.block[2:7]) != "erase" {
+		t.Errorf("set password block: got % x", p.block[:8])
+	}
+
+	p = d.disablePasswordPacket("erase", false)
+	if p.command[14] != 0xf6 || p.direction != _SG_DXFER_TO_DEV || p.dataLen != 512 {
+		t.Errorf("disable password: command %#x, direction %d, dataLen %d", p.command[14], p.direction, p.dataLen)
+	}
+	if p.block[0] != 0 || string(p.block[2:7]) != "erase" {
+		t.Errorf("disable password block: got % x", p.block[:8])
+	}
+
+	p = d.erasePreparePacket()
+	want := commandDataBlock{0x85, 0x07, 0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0xf3, 0}
+	if p.command != want || p.dataLen != 0 || p.direction != _SG_DXFER_NONE {
+		t.Errorf("erase prepare: got % x, dataLen %d, direction %d, want % x", p.command, p.dataLen, p.direction, want)
+	}
+
+	p = d.eraseUnitPacket("erase", true, true, 2*time.Hour)
+	if p.command[14] != 0xf4 || p.timeout != 7200000 {
+		t.Errorf("erase unit: command %#x, timeout %d", p.command[14], p.timeout)
+	}
+	if p.block[0] != 3 || string(p.block[2:7]) != "erase" {
+		t.Errorf("erase unit block: got % x", p.block[:8])
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ntp
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Key is a symmetric key as configured in an ntp.keys file.
+//
+// Packets are authenticated with a MAC appended to the header: the 32-bit
+// key ID followed by H(secret || header), as described in RFC 5905,
+// section 7.3.
+type Key struct {
+	ID uint32
+
+	// Type is the digest algorithm, "MD5" or "SHA1".
+	Type string
+
+	Secret []byte
+}
+
+func (k *Key) hash() (hash.Hash, error) {
+	switch strings.ToUpper(k.Type) {
+	case "M", "MD5":
+		return md5.New(), nil
+	case "SHA", "SHA1":
+		return sha1.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Type)
+}
+
+// mac returns the MAC to append to header.
+func (k *Key) mac(header []byte) ([]byte, error) {
+	h, err := k.hash()
+	if err != nil {
+		return nil, err
+	}
+	h.Write(k.Secret)
+	h.Write(header)
+	m := make([]byte, 4, 4+h.Size())
+	binary.BigEndian.PutUint32(m, k.ID)
+	return h.Sum(m), nil
+}
+
+// verify checks that pkt, a header followed by a MAC, was signed with k.
+func (k *Key) verify(pkt []byte) error {
+	h, err := k.hash()
+	if err != nil {
+		return err
+	}
+	if len(pkt) != headerLen+4+h.Size() {
+		return fmt.Errorf("response has no valid MAC for key %d", k.ID)
+	}
+	if id := binary.BigEndian.Uint32(pkt[headerLen:]); id != k.ID {
+		return fmt.Errorf("response signed with key %d, want %d", id, k.ID)
+	}
+	want, err := k.mac(pkt[:headerLen])
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(pkt[headerLen:], want) {
+		return fmt.Errorf("response MAC does not match key %d", k.ID)
+	}
+	return nil
+}
+
+// ParseKeys parses keys in the ntp.keys format, where each line holds
+// "keyid type secret" and "#" starts a comment.
+//
+// Secrets longer than 20 characters are hex-encoded, shorter ones are
+// used as ASCII, matching ntpd.
+func ParseKeys(r io.Reader) (map[uint32]*Key, error) {
+	keys := make(map[uint32]*Key)
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		l := s.Text()
+		if i := strings.Index(l, "#"); i >= 0 {
+			l = l[:i]
+		}
+		f := strings.Fields(l)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) < 3 {
+			return nil, fmt.Errorf("line %d: want \"keyid type secret\", got %q", line, s.Text())
+		}
+		id, err := strconv.ParseUint(f[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid key ID: %v", line, err)
+		}
+		k := &Key{ID: uint32(id), Type: f[1], Secret: []byte(f[2])}
+		if _, err := k.hash(); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if len(f[2]) > 20 {
+			if k.Secret, err = hex.DecodeString(f[2]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid hex secret: %v", line, err)
+			}
+		}
+		keys[k.ID] = k
+	}
+	return keys, s.Err()
+}
@@ -0,0 +1,322 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ntp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// DefaultTimeout is the time Query waits for a response by default.
+const DefaultTimeout = 5 * time.Second
+
+// Options configure queries.
+type Options struct {
+	// Timeout is how long to wait for each response. Zero means
+	// DefaultTimeout.
+	Timeout time.Duration
+
+	// Key, if set, is used to sign requests, and responses must be
+	// signed with it too.
+	Key *Key
+
+	// Samples is the number of queries sent to each server by Sample.
+	// Zero means 4.
+	Samples int
+
+	// Interval is the pause between queries to the same server.
+	Interval time.Duration
+}
+
+func (o *Options) timeout() time.Duration {
+	if o == nil || o.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
+}
+
+func (o *Options) key() *Key {
+	if o == nil {
+		return nil
+	}
+	return o.Key
+}
+
+func (o *Options) samples() int {
+	if o == nil || o.Samples <= 0 {
+		return 4
+	}
+	return o.Samples
+}
+
+// Response is the result of a single query.
+type Response struct {
+	// Server is the address the query was sent to.
+	Server string
+
+	// Time is the server's transmit time.
+	Time time.Time
+
+	// Offset is how far the local clock is behind the server's clock;
+	// it is the correction to add to the local clock.
+	Offset time.Duration
+
+	// Delay is the round-trip delay to the server, less the time the
+	// server spent processing the request.
+	Delay time.Duration
+
+	Stratum        uint8
+	Leap           LeapIndicator
+	ReferenceID    uint32
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+}
+
+// Validate checks that r comes from a synchronized server and has sane
+// timing.
+func (r *Response) Validate() error {
+	if r.Stratum == 0 {
+		return ErrKissOfDeath
+	}
+	if r.Stratum > 15 {
+		return fmt.Errorf("invalid stratum %d", r.Stratum)
+	}
+	if r.Leap == LeapNotInSync {
+		return errors.New("server clock is not synchronized")
+	}
+	if r.Delay < 0 {
+		return fmt.Errorf("negative round-trip delay %v", r.Delay)
+	}
+	return nil
+}
+
+// Query sends a single client request to server, an address with an
+// optional port defaulting to 123, and returns the decoded response.
+func Query(server string, opts *Options) (*Response, error) {
+	addr := server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "123")
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(opts.timeout())); err != nil {
+		return nil, err
+	}
+
+	// Per RFC 5905, section 9.1, we put a random transmit timestamp in
+	// the request rather than our time, and remember the real time
+	// locally. The server echoes it back as the origin timestamp.
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	req := &Packet{
+		Version:      defaultVersion,
+		Mode:         ModeClient,
+		TransmitTime: binary.BigEndian.Uint64(nonce[:]),
+	}
+	b := req.Marshal()
+	if k := opts.key(); k != nil {
+		mac, err := k.mac(b)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, mac...)
+	}
+
+	t1 := time.Now()
+	if _, err := conn.Write(b); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	t4 := t1.Add(time.Since(t1))
+	buf = buf[:n]
+
+	var p Packet
+	if err := p.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+	if p.Mode != ModeServer {
+		return nil, fmt.Errorf("unexpected mode %d in response, want %d", p.Mode, ModeServer)
+	}
+	if p.OriginTime != req.TransmitTime {
+		return nil, errors.New("response origin timestamp does not match request")
+	}
+	if p.TransmitTime == 0 {
+		return nil, errors.New("response has no transmit timestamp")
+	}
+	if k := opts.key(); k != nil {
+		if err := k.verify(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	t2, t3 := FromNTPTime(p.ReceiveTime), FromNTPTime(p.TransmitTime)
+	r := &Response{
+		Server:         server,
+		Time:           t3,
+		Offset:         (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:          t4.Sub(t1) - t3.Sub(t2),
+		Stratum:        p.Stratum,
+		Leap:           p.Leap,
+		ReferenceID:    p.ReferenceID,
+		RootDelay:      shortToDuration(p.RootDelay),
+		RootDispersion: shortToDuration(p.RootDispersion),
+	}
+	return r, r.Validate()
+}
+
+// ServerSample holds all responses from one server and the one selected
+// by the clock filter.
+type ServerSample struct {
+	Server    string
+	Responses []*Response
+
+	// Best is the response with the lowest delay, which is least
+	// affected by network asymmetry. It is nil if no query succeeded.
+	Best *Response
+
+	// Jitter is the RMS difference of the other offsets from Best's.
+	Jitter time.Duration
+
+	// Err is the last query error, if no query succeeded.
+	Err error
+
+	// Outlier is set if Best was rejected when combining servers.
+	Outlier bool
+}
+
+// Result is the combined result of sampling several servers.
+type Result struct {
+	// Offset is the correction to add to the local clock.
+	Offset time.Duration
+
+	Samples []*ServerSample
+}
+
+// Sample queries each server opts.Samples times, applies the clock filter
+// to each server's responses, rejects outliers among the servers, and
+// combines the rest into a single offset.
+func Sample(servers []string, opts *Options) (*Result, error) {
+	res := &Result{}
+	for _, s := range servers {
+		ss := &ServerSample{Server: s}
+		for i := 0; i < opts.samples(); i++ {
+			if i > 0 && opts != nil && opts.Interval > 0 {
+				time.Sleep(opts.Interval)
+			}
+			r, err := Query(s, opts)
+			if err == ErrKissOfDeath {
+				ss.Err = err
+				break
+			}
+			if err != nil {
+				ss.Err = err
+				continue
+			}
+			ss.Responses = append(ss.Responses, r)
+		}
+		if len(ss.Responses) > 0 {
+			ss.Err = nil
+			ss.filter()
+		}
+		res.Samples = append(res.Samples, ss)
+	}
+
+	offset, err := combine(res.Samples)
+	if err != nil {
+		return res, err
+	}
+	res.Offset = offset
+	return res, nil
+}
+
+// filter picks the response with the lowest delay and computes jitter.
+func (s *ServerSample) filter() {
+	s.Best = s.Responses[0]
+	for _, r := range s.Responses[1:] {
+		if r.Delay < s.Best.Delay {
+			s.Best = r
+		}
+	}
+	if len(s.Responses) < 2 {
+		return
+	}
+	var sum float64
+	for _, r := range s.Responses {
+		d := float64(r.Offset - s.Best.Offset)
+		sum += d * d
+	}
+	s.Jitter = time.Duration(math.Sqrt(sum / float64(len(s.Responses)-1)))
+}
+
+// combine rejects outliers among the servers' best samples and returns
+// the median offset of the survivors.
+//
+// A sample is an outlier if it is further from the median offset than
+// three times the median absolute deviation, plus half its own delay
+// (the maximum error its own measurement can have).
+func combine(samples []*ServerSample) (time.Duration, error) {
+	var good []*ServerSample
+	for _, s := range samples {
+		if s.Best != nil {
+			good = append(good, s)
+		}
+	}
+	if len(good) == 0 {
+		return 0, errors.New("no server answered")
+	}
+
+	offsets := make([]time.Duration, len(good))
+	for i, s := range good {
+		offsets[i] = s.Best.Offset
+	}
+	med := median(offsets)
+	devs := make([]time.Duration, len(good))
+	for i, o := range offsets {
+		devs[i] = abs(o - med)
+	}
+	// 1.4826 scales the MAD to estimate the standard deviation.
+	mad := time.Duration(1.4826 * float64(median(devs)))
+
+	var kept []time.Duration
+	for i, s := range good {
+		if devs[i] > 3*mad+s.Best.Delay/2 {
+			s.Outlier = true
+			continue
+		}
+		kept = append(kept, offsets[i])
+	}
+	return median(kept), nil
+}
+
+func median(d []time.Duration) time.Duration {
+	s := append([]time.Duration(nil), d...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ntp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/u-root/u-root/pkg/rtc"
+	"golang.org/x/sys/unix"
+)
+
+// adjOffsetSingleshot is ADJ_OFFSET_SINGLESHOT from <linux/timex.h>: the
+// old adjtime(2) behaviour of slewing the clock by a fixed amount.
+const adjOffsetSingleshot = 0x8001
+
+// MaxSlew is the largest offset Slew accepts. The kernel slews at 500ppm,
+// so this takes about 17 minutes to apply.
+const MaxSlew = 500 * time.Millisecond
+
+// StepThreshold is the offset above which Adjust steps the clock even if
+// slewing was requested, as ntpd does.
+const StepThreshold = 128 * time.Millisecond
+
+// Step sets the system clock forward by offset, all at once.
+func Step(offset time.Duration) error {
+	tv := unix.NsecToTimeval(time.Now().Add(offset).UnixNano())
+	return unix.Settimeofday(&tv)
+}
+
+// Slew asks the kernel to gradually speed up or slow down the system clock
+// until it has moved by offset, so that time never goes backwards.
+func Slew(offset time.Duration) error {
+	if abs(offset) > MaxSlew {
+		return fmt.Errorf("offset %v is too large to slew, the limit is %v", offset, MaxSlew)
+	}
+	tx := unix.Timex{
+		Modes:  adjOffsetSingleshot,
+		Offset: int64(offset / time.Microsecond),
+	}
+	_, err := unix.Adjtimex(&tx)
+	return err
+}
+
+// Adjust corrects the system clock by offset. If slew is set and the
+// offset is below StepThreshold, the clock is slewed, otherwise it is
+// stepped. It reports whether the clock was stepped.
+func Adjust(offset time.Duration, slew bool) (bool, error) {
+	if slew && abs(offset) <= StepThreshold {
+		return false, Slew(offset)
+	}
+	return true, Step(offset)
+}
+
+// SetRTC writes the current system time to the hardware clock, in UTC.
+func SetRTC() error {
+	r, err := rtc.OpenRTC()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return r.Set(time.Now().UTC())
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ntp
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubServer is an in-process NTP server whose clock runs offset ahead
+// of ours.
+type stubServer struct {
+	conn    net.PacketConn
+	offset  time.Duration
+	stratum uint8
+	key     *Key
+	// badMAC makes the server corrupt its signature.
+	badMAC bool
+}
+
+func newStubServer(t *testing.T, offset time.Duration) *stubServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubServer{conn: conn, offset: offset, stratum: 2}
+	go s.serve()
+	return s
+}
+
+func (s *stubServer) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *stubServer) close() {
+	s.conn.Close()
+}
+
+func (s *stubServer) serve() {
+	buf := make([]byte, 1024)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		recv := time.Now().Add(s.offset)
+		var req Packet
+		if err := req.Unmarshal(buf[:n]); err != nil {
+			continue
+		}
+		if s.key != nil && s.key.verify(buf[:n]) != nil {
+			continue
+		}
+		resp := &Packet{
+			Version:       req.Version,
+			Mode:          ModeServer,
+			Stratum:       s.stratum,
+			ReferenceID:   0x7f000001,
+			ReferenceTime: ToNTPTime(recv),
+			OriginTime:    req.TransmitTime,
+			ReceiveTime:   ToNTPTime(recv),
+			TransmitTime:  ToNTPTime(time.Now().Add(s.offset)),
+		}
+		b := resp.Marshal()
+		if s.key != nil {
+			mac, _ := s.key.mac(b)
+			if s.badMAC {
+				mac[len(mac)-1] ^= 0xff
+			}
+			b = append(b, mac...)
+		}
+		s.conn.WriteTo(b, from)
+	}
+}
+
+// closeTo reports whether got is within a generous margin of want, to
+// allow for scheduling noise on busy test machines.
+func closeTo(got, want time.Duration) bool {
+	return abs(got-want) < 50*time.Millisecond
+}
+
+func TestNTPTime(t *testing.T) {
+	for _, tt := range []time.Time{
+		time.Unix(0, 0),
+		time.Unix(1600000000, 500000000),
+		time.Date(2036, 2, 7, 6, 28, 15, 0, time.UTC),
+	} {
+		got := FromNTPTime(ToNTPTime(tt))
+		if d := abs(got.Sub(tt)); d > time.Microsecond {
+			t.Errorf("FromNTPTime(ToNTPTime(%v)) = %v, off by %v", tt, got, d)
+		}
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	p := &Packet{
+		Leap:           LeapAddSecond,
+		Version:        4,
+		Mode:           ModeServer,
+		Stratum:        3,
+		Poll:           6,
+		Precision:      -20,
+		RootDelay:      0x10000,
+		RootDispersion: 0x8000,
+		ReferenceID:    0xc0a80001,
+		ReferenceTime:  1,
+		OriginTime:     2,
+		ReceiveTime:    3,
+		TransmitTime:   4,
+	}
+	var got Packet
+	if err := got.Unmarshal(p.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if got != *p {
+		t.Errorf("round trip: got %+v, want %+v", got, *p)
+	}
+	if d := shortToDuration(p.RootDelay); d != time.Second {
+		t.Errorf("shortToDuration(%#x) = %v, want 1s", p.RootDelay, d)
+	}
+	if err := got.Unmarshal(make([]byte, 47)); err == nil {
+		t.Errorf("Unmarshal of a short packet succeeded")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s := newStubServer(t, 3*time.Second)
+	defer s.close()
+
+	r, err := Query(s.addr(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !closeTo(r.Offset, 3*time.Second) {
+		t.Errorf("offset = %v, want about 3s", r.Offset)
+	}
+	if r.Delay < 0 || r.Delay > 50*time.Millisecond {
+		t.Errorf("delay = %v, want a small positive value", r.Delay)
+	}
+	if r.Stratum != 2 {
+		t.Errorf("stratum = %d, want 2", r.Stratum)
+	}
+}
+
+func TestQueryKissOfDeath(t *testing.T) {
+	s := newStubServer(t, 0)
+	defer s.close()
+	s.stratum = 0
+
+	if _, err := Query(s.addr(), nil); err != ErrKissOfDeath {
+		t.Errorf("Query = %v, want %v", err, ErrKissOfDeath)
+	}
+}
+
+func TestQueryAuth(t *testing.T) {
+	key := &Key{ID: 7, Type: "SHA1", Secret: []byte("secret")}
+	s := newStubServer(t, time.Second)
+	defer s.close()
+	s.key = key
+
+	if _, err := Query(s.addr(), &Options{Key: key}); err != nil {
+		t.Errorf("authenticated Query: %v", err)
+	}
+
+	// The server ignores unsigned requests.
+	if _, err := Query(s.addr(), &Options{Timeout: 100 * time.Millisecond}); err == nil {
+		t.Errorf("unsigned Query succeeded")
+	}
+
+	// A bad signature must be rejected.
+	s.badMAC = true
+	if _, err := Query(s.addr(), &Options{Key: key}); err == nil {
+		t.Errorf("Query accepted a bad MAC")
+	}
+}
+
+func TestSample(t *testing.T) {
+	var servers []string
+	for _, off := range []time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second, -40 * time.Second} {
+		s := newStubServer(t, off)
+		defer s.close()
+		servers = append(servers, s.addr())
+	}
+	// A server nobody listens on.
+	dead := newStubServer(t, 0)
+	dead.close()
+	servers = append(servers, dead.addr())
+
+	res, err := Sample(servers, &Options{Samples: 3, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !closeTo(res.Offset, 2*time.Second) {
+		t.Errorf("offset = %v, want about 2s", res.Offset)
+	}
+	if len(res.Samples) != 5 {
+		t.Fatalf("got %d samples, want 5", len(res.Samples))
+	}
+	if !res.Samples[3].Outlier {
+		t.Errorf("server with offset -40s was not rejected as an outlier")
+	}
+	if res.Samples[4].Err == nil || res.Samples[4].Best != nil {
+		t.Errorf("dead server: got %+v, want an error", res.Samples[4])
+	}
+	for _, s := range res.Samples[:3] {
+		if s.Outlier || len(s.Responses) != 3 {
+			t.Errorf("server %s: outlier %t with %d responses, want false with 3", s.Server, s.Outlier, len(s.Responses))
+		}
+	}
+}
+
+func TestSampleNoServers(t *testing.T) {
+	dead := newStubServer(t, 0)
+	dead.close()
+	if _, err := Sample([]string{dead.addr()}, &Options{Samples: 1, Timeout: 100 * time.Millisecond}); err == nil {
+		t.Errorf("Sample with no live servers succeeded")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys(strings.NewReader(`# ntp.keys
+1 M short
+2 SHA1 0102030405060708090a0b0c0d0e0f1011121314 # hex
+
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(keys))
+	}
+	if string(keys[1].Secret) != "short" {
+		t.Errorf("key 1 secret = %q, want %q", keys[1].Secret, "short")
+	}
+	if len(keys[2].Secret) != 20 || keys[2].Secret[19] != 0x14 {
+		t.Errorf("key 2 secret = %x, want hex-decoded", keys[2].Secret)
+	}
+
+	for _, bad := range []string{"1 M", "x M secret", "1 CRC secret", "1 SHA1 zzzzzzzzzzzzzzzzzzzzzzzz"} {
+		if _, err := ParseKeys(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseKeys(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ntp implements an NTP (RFC 5905) / SNTP (RFC 4330) client.
+//
+// It queries one or more servers several times, filters and combines the
+// samples into a single clock offset, and can step or slew the system clock
+// and write the result to the hardware clock.
+package ntp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Mode is the association mode of an NTP packet.
+type Mode uint8
+
+// Association modes from RFC 5905, figure 10.
+const (
+	ModeClient Mode = 3
+	ModeServer Mode = 4
+)
+
+// LeapIndicator warns of an impending leap second.
+type LeapIndicator uint8
+
+// Leap indicator values from RFC 5905, figure 9.
+const (
+	LeapNone      LeapIndicator = 0
+	LeapAddSecond LeapIndicator = 1
+	LeapDelSecond LeapIndicator = 2
+	LeapNotInSync LeapIndicator = 3
+)
+
+const (
+	// headerLen is the length of an NTP packet without extensions or MAC.
+	headerLen = 48
+
+	// defaultVersion is the NTP version we send requests with.
+	defaultVersion = 4
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch
+// (1900-01-01) and the Unix epoch (1970-01-01).
+const ntpEpochOffset = 2208988800
+
+// Packet is an NTP packet header as sent on the wire.
+type Packet struct {
+	Leap           LeapIndicator
+	Version        uint8
+	Mode           Mode
+	Stratum        uint8
+	Poll           int8
+	Precision      int8
+	RootDelay      uint32
+	RootDispersion uint32
+	ReferenceID    uint32
+	ReferenceTime  uint64
+	OriginTime     uint64
+	ReceiveTime    uint64
+	TransmitTime   uint64
+}
+
+// Marshal returns the wire encoding of p.
+func (p *Packet) Marshal() []byte {
+	b := make([]byte, headerLen)
+	b[0] = byte(p.Leap)<<6 | (p.Version&0x7)<<3 | byte(p.Mode)&0x7
+	b[1] = p.Stratum
+	b[2] = byte(p.Poll)
+	b[3] = byte(p.Precision)
+	binary.BigEndian.PutUint32(b[4:], p.RootDelay)
+	binary.BigEndian.PutUint32(b[8:], p.RootDispersion)
+	binary.BigEndian.PutUint32(b[12:], p.ReferenceID)
+	binary.BigEndian.PutUint64(b[16:], p.ReferenceTime)
+	binary.BigEndian.PutUint64(b[24:], p.OriginTime)
+	binary.BigEndian.PutUint64(b[32:], p.ReceiveTime)
+	binary.BigEndian.PutUint64(b[40:], p.TransmitTime)
+	return b
+}
+
+// Unmarshal decodes the header at the start of b into p.
+func (p *Packet) Unmarshal(b []byte) error {
+	if len(b) < headerLen {
+		return fmt.Errorf("NTP packet too short: %d bytes, want at least %d", len(b), headerLen)
+	}
+	p.Leap = LeapIndicator(b[0] >> 6)
+	p.Version = (b[0] >> 3) & 0x7
+	p.Mode = Mode(b[0] & 0x7)
+	p.Stratum = b[1]
+	p.Poll = int8(b[2])
+	p.Precision = int8(b[3])
+	p.RootDelay = binary.BigEndian.Uint32(b[4:])
+	p.RootDispersion = binary.BigEndian.Uint32(b[8:])
+	p.ReferenceID = binary.BigEndian.Uint32(b[12:])
+	p.ReferenceTime = binary.BigEndian.Uint64(b[16:])
+	p.OriginTime = binary.BigEndian.Uint64(b[24:])
+	p.ReceiveTime = binary.BigEndian.Uint64(b[32:])
+	p.TransmitTime = binary.BigEndian.Uint64(b[40:])
+	return nil
+}
+
+// ToNTPTime converts t to a 64-bit NTP timestamp.
+func ToNTPTime(t time.Time) uint64 {
+	sec := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / 1e9
+	return sec<<32 | frac
+}
+
+// FromNTPTime converts a 64-bit NTP timestamp to a time.Time.
+func FromNTPTime(ts uint64) time.Time {
+	sec := int64(ts>>32) - ntpEpochOffset
+	nsec := ((ts & 0xffffffff) * 1e9) >> 32
+	return time.Unix(sec, int64(nsec))
+}
+
+// shortToDuration converts a 32-bit NTP short format value (16.16 fixed
+// point seconds) to a time.Duration.
+func shortToDuration(s uint32) time.Duration {
+	return time.Duration((uint64(s) * uint64(time.Second)) >> 16)
+}
+
+// ErrKissOfDeath is returned when a server answers with stratum 0, asking
+// the client to go away.
+var ErrKissOfDeath = errors.New("server sent a kiss-of-death packet")
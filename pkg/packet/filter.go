@@ -0,0 +1,322 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/mdlayher/ethernet"
+)
+
+// Filter selects packets. The zero value and nil match every packet.
+//
+// Filters are parsed from a subset of the pcap-filter(7) syntax:
+//
+// Primitives are "[src|dst] host ADDR", "[src|dst] net CIDR",
+// "[src|dst] port N", "ether [src|dst] host MAC", "ether proto
+// (N|arp|ip|ip6)", "vlan [ID]", the protocols "arp", "ip", "ip6", "tcp",
+// "udp", "icmp" and "icmp6", and "dhcp" for DHCPv4 or DHCPv6 messages.
+//
+// They can be combined with "not" ("!"), "and" ("&&"), "or" ("||") and
+// parentheses. "and" binds tighter than "or", and primitives written next
+// to each other, as in "udp port 67", are joined with "and".
+type Filter struct {
+	expr  string
+	match func(*Packet) bool
+}
+
+// Match reports whether p passes f.
+func (f *Filter) Match(p *Packet) bool {
+	if f == nil || f.match == nil {
+		return true
+	}
+	return f.match(p)
+}
+
+// String returns the expression f was parsed from.
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// ParseFilter parses a filter expression. An empty expression matches
+// everything.
+func ParseFilter(expr string) (*Filter, error) {
+	// Make parentheses and "!" separate tokens even without spaces.
+	e := strings.NewReplacer("(", " ( ", ")", " ) ", "!", " ! ").Replace(expr)
+	p := &parser{tokens: strings.Fields(e)}
+	if len(p.tokens) == 0 {
+		return &Filter{}, nil
+	}
+	m, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != "" {
+		return nil, fmt.Errorf("filter: unexpected %q", t)
+	}
+	return &Filter{expr: expr, match: m}, nil
+}
+
+type matcher func(*Packet) bool
+
+type parser struct {
+	tokens []string
+}
+
+func (p *parser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	if t != "" {
+		p.tokens = p.tokens[1:]
+	}
+	return t
+}
+
+func (p *parser) or() (matcher, error) {
+	m, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t == "or" || t == "||"; t = p.peek() {
+		p.next()
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := m
+		m = func(pk *Packet) bool { return l(pk) || r(pk) }
+	}
+	return m, nil
+}
+
+func (p *parser) and() (matcher, error) {
+	m, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch t := p.peek(); t {
+		case "", "or", "||", ")":
+			return m, nil
+		case "and", "&&":
+			p.next()
+		}
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := m
+		m = func(pk *Packet) bool { return l(pk) && r(pk) }
+	}
+}
+
+func (p *parser) unary() (matcher, error) {
+	switch t := p.next(); t {
+	case "":
+		return nil, fmt.Errorf("filter: unexpected end of expression")
+	case "not", "!":
+		m, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(pk *Packet) bool { return !m(pk) }, nil
+	case "(":
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("filter: missing )")
+		}
+		return m, nil
+	default:
+		return p.primitive(t)
+	}
+}
+
+// direction is a src/dst qualifier.
+type direction int
+
+const (
+	srcOrDst direction = iota
+	src
+	dst
+)
+
+func (p *parser) direction() direction {
+	switch p.peek() {
+	case "src":
+		p.next()
+		return src
+	case "dst":
+		p.next()
+		return dst
+	}
+	return srcOrDst
+}
+
+func (p *parser) arg(what string) (string, error) {
+	a := p.next()
+	if a == "" || a == "(" || a == ")" {
+		return "", fmt.Errorf("filter: %s needs an argument", what)
+	}
+	return a, nil
+}
+
+func (p *parser) primitive(t string) (matcher, error) {
+	switch t {
+	case "arp":
+		return func(pk *Packet) bool { return pk.ARP != nil }, nil
+	case "ip":
+		return func(pk *Packet) bool { return pk.IPv4 != nil }, nil
+	case "ip6":
+		return func(pk *Packet) bool { return pk.IPv6 != nil }, nil
+	case "tcp":
+		return func(pk *Packet) bool { return pk.TCP != nil }, nil
+	case "udp":
+		return func(pk *Packet) bool { return pk.UDP != nil }, nil
+	case "icmp":
+		return func(pk *Packet) bool { return pk.ICMP != nil && !pk.ICMP.V6 }, nil
+	case "icmp6":
+		return func(pk *Packet) bool { return pk.ICMP != nil && pk.ICMP.V6 }, nil
+	case "dhcp":
+		return func(pk *Packet) bool { return pk.DHCPv4 != nil || pk.DHCPv6 != nil }, nil
+	case "vlan":
+		if id, err := strconv.ParseUint(p.peek(), 10, 12); err == nil {
+			p.next()
+			return func(pk *Packet) bool {
+				return pk.Ethernet != nil && pk.Ethernet.VLAN != nil && pk.Ethernet.VLAN.ID == uint16(id)
+			}, nil
+		}
+		return func(pk *Packet) bool { return pk.Ethernet != nil && pk.Ethernet.VLAN != nil }, nil
+	case "ether":
+		return p.ether()
+	case "src", "dst", "host", "net", "port":
+		p.tokens = append([]string{t}, p.tokens...)
+		d := p.direction()
+		switch kw := p.next(); kw {
+		case "host":
+			return p.host(d)
+		case "net":
+			return p.net(d)
+		case "port":
+			return p.port(d)
+		default:
+			return nil, fmt.Errorf("filter: want host, net or port, got %q", kw)
+		}
+	}
+	return nil, fmt.Errorf("filter: unknown primitive %q", t)
+}
+
+func matchDir(d direction, s, t func(*Packet) bool) matcher {
+	switch d {
+	case src:
+		return s
+	case dst:
+		return t
+	}
+	return func(pk *Packet) bool { return s(pk) || t(pk) }
+}
+
+func (p *parser) host(d direction) (matcher, error) {
+	a, err := p.arg("host")
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(a)
+	if ip == nil {
+		return nil, fmt.Errorf("filter: invalid host address %q", a)
+	}
+	return matchDir(d,
+		func(pk *Packet) bool { return ip.Equal(pk.Src()) },
+		func(pk *Packet) bool { return ip.Equal(pk.Dst()) },
+	), nil
+}
+
+func (p *parser) net(d direction) (matcher, error) {
+	a, err := p.arg("net")
+	if err != nil {
+		return nil, err
+	}
+	_, n, err := net.ParseCIDR(a)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+	contains := func(ip net.IP) bool { return ip != nil && n.Contains(ip) }
+	return matchDir(d,
+		func(pk *Packet) bool { return contains(pk.Src()) },
+		func(pk *Packet) bool { return contains(pk.Dst()) },
+	), nil
+}
+
+func (p *parser) port(d direction) (matcher, error) {
+	a, err := p.arg("port")
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(a, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("filter: invalid port %q", a)
+	}
+	return matchDir(d,
+		func(pk *Packet) bool { s, _, ok := pk.Ports(); return ok && s == uint16(port) },
+		func(pk *Packet) bool { _, t, ok := pk.Ports(); return ok && t == uint16(port) },
+	), nil
+}
+
+var etherProtos = map[string]ethernet.EtherType{
+	"arp": ethernet.EtherTypeARP,
+	"ip":  ethernet.EtherTypeIPv4,
+	"ip6": ethernet.EtherTypeIPv6,
+}
+
+func (p *parser) ether() (matcher, error) {
+	if p.peek() == "proto" {
+		p.next()
+		a, err := p.arg("ether proto")
+		if err != nil {
+			return nil, err
+		}
+		et, ok := etherProtos[a]
+		if !ok {
+			n, err := strconv.ParseUint(a, 0, 16)
+			if err != nil {
+				return nil, fmt.Errorf("filter: invalid ether proto %q", a)
+			}
+			et = ethernet.EtherType(n)
+		}
+		return func(pk *Packet) bool { return pk.Ethernet != nil && pk.Ethernet.EtherType == et }, nil
+	}
+
+	d := p.direction()
+	if kw := p.next(); kw != "host" {
+		return nil, fmt.Errorf("filter: want ether host or ether proto, got %q", kw)
+	}
+	a, err := p.arg("ether host")
+	if err != nil {
+		return nil, err
+	}
+	mac, err := net.ParseMAC(a)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+	return matchDir(d,
+		func(pk *Packet) bool { return pk.Ethernet != nil && bytes.Equal(pk.Ethernet.Source, mac) },
+		func(pk *Packet) bool { return pk.Ethernet != nil && bytes.Equal(pk.Ethernet.Destination, mac) },
+	), nil
+}
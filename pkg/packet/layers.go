@@ -0,0 +1,349 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// IP protocol numbers.
+const (
+	ProtoICMP   = 1
+	ProtoTCP    = 6
+	ProtoUDP    = 17
+	ProtoICMPv6 = 58
+)
+
+// IPv6 extension headers that Decode skips over.
+const (
+	ipv6HopByHop    = 0
+	ipv6Routing     = 43
+	ipv6Fragment    = 44
+	ipv6DestOptions = 60
+)
+
+// ARP is an ARP packet for IPv4 over Ethernet (RFC 826).
+type ARP struct {
+	Operation uint16
+	SenderHW  net.HardwareAddr
+	SenderIP  net.IP
+	TargetHW  net.HardwareAddr
+	TargetIP  net.IP
+}
+
+// ARP operations.
+const (
+	ARPRequest = 1
+	ARPReply   = 2
+)
+
+func decodeARP(b []byte) (*ARP, error) {
+	l := uio.NewBigEndianBuffer(b)
+	htype, ptype := l.Read16(), l.Read16()
+	hlen, plen := l.Read8(), l.Read8()
+	a := &ARP{Operation: l.Read16()}
+	if err := l.Error(); err != nil {
+		return nil, fmt.Errorf("ARP: %v", err)
+	}
+	if htype != 1 || ptype != 0x0800 || hlen != 6 || plen != 4 {
+		return nil, fmt.Errorf("ARP: unsupported hardware/protocol %d/%#x", htype, ptype)
+	}
+	a.SenderHW = net.HardwareAddr(l.CopyN(6))
+	a.SenderIP = net.IP(l.CopyN(4))
+	a.TargetHW = net.HardwareAddr(l.CopyN(6))
+	a.TargetIP = net.IP(l.CopyN(4))
+	if err := l.Error(); err != nil {
+		return nil, fmt.Errorf("ARP: %v", err)
+	}
+	return a, nil
+}
+
+// IPv4 is an IPv4 header (RFC 791).
+type IPv4 struct {
+	IHL        uint8
+	TOS        uint8
+	Length     uint16
+	ID         uint16
+	Flags      uint8
+	FragOffset uint16
+	TTL        uint8
+	Protocol   uint8
+	Checksum   uint16
+	Src        net.IP
+	Dst        net.IP
+	Options    []byte
+}
+
+// decodeIPv4 returns the header and the payload it covers.
+func decodeIPv4(b []byte) (*IPv4, []byte, error) {
+	l := uio.NewBigEndianBuffer(b)
+	vihl := l.Read8()
+	ip := &IPv4{
+		IHL:    vihl & 0xf,
+		TOS:    l.Read8(),
+		Length: l.Read16(),
+		ID:     l.Read16(),
+	}
+	ff := l.Read16()
+	ip.Flags, ip.FragOffset = uint8(ff>>13), ff&0x1fff
+	ip.TTL = l.Read8()
+	ip.Protocol = l.Read8()
+	ip.Checksum = l.Read16()
+	ip.Src = net.IP(l.CopyN(4))
+	ip.Dst = net.IP(l.CopyN(4))
+	if err := l.Error(); err != nil {
+		return nil, nil, fmt.Errorf("IPv4: %v", err)
+	}
+	if v := vihl >> 4; v != 4 {
+		return nil, nil, fmt.Errorf("IPv4: bad version %d", v)
+	}
+	hlen := int(ip.IHL) * 4
+	if hlen < 20 || hlen > len(b) || int(ip.Length) < hlen {
+		return nil, nil, fmt.Errorf("IPv4: bad header length %d", hlen)
+	}
+	ip.Options = b[20:hlen]
+	end := int(ip.Length)
+	// Truncated captures are decoded as far as they go.
+	if end > len(b) {
+		end = len(b)
+	}
+	return ip, b[hlen:end], nil
+}
+
+// IPv6 is an IPv6 header (RFC 8200).
+type IPv6 struct {
+	TrafficClass  uint8
+	FlowLabel     uint32
+	PayloadLength uint16
+
+	// NextHeader is the upper layer protocol after any extension
+	// headers were skipped.
+	NextHeader uint8
+	HopLimit   uint8
+	Src        net.IP
+	Dst        net.IP
+}
+
+// decodeIPv6 returns the header and the upper layer payload.
+func decodeIPv6(b []byte) (*IPv6, []byte, error) {
+	l := uio.NewBigEndianBuffer(b)
+	vtf := l.Read32()
+	ip := &IPv6{
+		TrafficClass:  uint8(vtf >> 20),
+		FlowLabel:     vtf & 0xfffff,
+		PayloadLength: l.Read16(),
+		NextHeader:    l.Read8(),
+		HopLimit:      l.Read8(),
+		Src:           net.IP(l.CopyN(16)),
+		Dst:           net.IP(l.CopyN(16)),
+	}
+	if err := l.Error(); err != nil {
+		return nil, nil, fmt.Errorf("IPv6: %v", err)
+	}
+	if v := vtf >> 28; v != 6 {
+		return nil, nil, fmt.Errorf("IPv6: bad version %d", v)
+	}
+	p := b[40:]
+	if int(ip.PayloadLength) < len(p) {
+		p = p[:ip.PayloadLength]
+	}
+	for {
+		switch ip.NextHeader {
+		case ipv6HopByHop, ipv6Routing, ipv6DestOptions:
+			if len(p) < 8 {
+				return nil, nil, fmt.Errorf("IPv6: truncated extension header %d", ip.NextHeader)
+			}
+			n := (int(p[1]) + 1) * 8
+			if n > len(p) {
+				return nil, nil, fmt.Errorf("IPv6: truncated extension header %d", ip.NextHeader)
+			}
+			ip.NextHeader, p = p[0], p[n:]
+		case ipv6Fragment:
+			if len(p) < 8 {
+				return nil, nil, fmt.Errorf("IPv6: truncated fragment header")
+			}
+			ip.NextHeader, p = p[0], p[8:]
+		default:
+			return ip, p, nil
+		}
+	}
+}
+
+// UDP is a UDP header (RFC 768).
+type UDP struct {
+	SrcPort  uint16
+	DstPort  uint16
+	Length   uint16
+	Checksum uint16
+}
+
+func decodeUDP(b []byte) (*UDP, []byte, error) {
+	l := uio.NewBigEndianBuffer(b)
+	u := &UDP{
+		SrcPort:  l.Read16(),
+		DstPort:  l.Read16(),
+		Length:   l.Read16(),
+		Checksum: l.Read16(),
+	}
+	if err := l.Error(); err != nil {
+		return nil, nil, fmt.Errorf("UDP: %v", err)
+	}
+	end := int(u.Length)
+	if end < 8 {
+		return nil, nil, fmt.Errorf("UDP: bad length %d", u.Length)
+	}
+	if end > len(b) {
+		end = len(b)
+	}
+	return u, b[8:end], nil
+}
+
+// TCP flags.
+const (
+	TCPFin = 1 << iota
+	TCPSyn
+	TCPRst
+	TCPPsh
+	TCPAck
+	TCPUrg
+)
+
+// TCP is a TCP header (RFC 793).
+type TCP struct {
+	SrcPort    uint16
+	DstPort    uint16
+	Seq        uint32
+	Ack        uint32
+	DataOffset uint8
+	Flags      uint8
+	Window     uint16
+	Checksum   uint16
+	Urgent     uint16
+	Options    []byte
+}
+
+func decodeTCP(b []byte) (*TCP, []byte, error) {
+	l := uio.NewBigEndianBuffer(b)
+	t := &TCP{
+		SrcPort: l.Read16(),
+		DstPort: l.Read16(),
+		Seq:     l.Read32(),
+		Ack:     l.Read32(),
+	}
+	off := l.Read16()
+	t.DataOffset, t.Flags = uint8(off>>12), uint8(off)
+	t.Window = l.Read16()
+	t.Checksum = l.Read16()
+	t.Urgent = l.Read16()
+	if err := l.Error(); err != nil {
+		return nil, nil, fmt.Errorf("TCP: %v", err)
+	}
+	hlen := int(t.DataOffset) * 4
+	if hlen < 20 || hlen > len(b) {
+		return nil, nil, fmt.Errorf("TCP: bad data offset %d", t.DataOffset)
+	}
+	t.Options = b[20:hlen]
+	return t, b[hlen:], nil
+}
+
+// flagString returns the flags in tcpdump's notation, e.g. "S." for
+// SYN-ACK.
+func (t *TCP) flagString() string {
+	var s string
+	for _, f := range []struct {
+		bit uint8
+		c   string
+	}{
+		{TCPSyn, "S"}, {TCPFin, "F"}, {TCPRst, "R"}, {TCPPsh, "P"}, {TCPUrg, "U"}, {TCPAck, "."},
+	} {
+		if t.Flags&f.bit != 0 {
+			s += f.c
+		}
+	}
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// ICMP is an ICMP (RFC 792) or ICMPv6 (RFC 4443) header. For echo
+// requests and replies, ID and Seq are set.
+type ICMP struct {
+	V6       bool
+	Type     uint8
+	Code     uint8
+	Checksum uint16
+	ID       uint16
+	Seq      uint16
+}
+
+// ICMP types the summary names.
+const (
+	icmpEchoReply    = 0
+	icmpUnreachable  = 3
+	icmpEchoRequest  = 8
+	icmpTimeExceeded = 11
+
+	icmp6Unreachable     = 1
+	icmp6TimeExceeded    = 3
+	icmp6EchoRequest     = 128
+	icmp6EchoReply       = 129
+	icmp6RouterSolicit   = 133
+	icmp6RouterAdvert    = 134
+	icmp6NeighborSolicit = 135
+	icmp6NeighborAdvert  = 136
+)
+
+func decodeICMP(b []byte, v6 bool) (*ICMP, []byte, error) {
+	l := uio.NewBigEndianBuffer(b)
+	c := &ICMP{
+		V6:       v6,
+		Type:     l.Read8(),
+		Code:     l.Read8(),
+		Checksum: l.Read16(),
+		ID:       l.Read16(),
+		Seq:      l.Read16(),
+	}
+	if err := l.Error(); err != nil {
+		return nil, nil, fmt.Errorf("ICMP: %v", err)
+	}
+	return c, b[8:], nil
+}
+
+func (c *ICMP) String() string {
+	names := map[uint8]string{
+		icmpEchoReply:    "echo reply",
+		icmpUnreachable:  "destination unreachable",
+		icmpEchoRequest:  "echo request",
+		icmpTimeExceeded: "time exceeded",
+	}
+	if c.V6 {
+		names = map[uint8]string{
+			icmp6Unreachable:     "destination unreachable",
+			icmp6TimeExceeded:    "time exceeded",
+			icmp6EchoRequest:     "echo request",
+			icmp6EchoReply:       "echo reply",
+			icmp6RouterSolicit:   "router solicitation",
+			icmp6RouterAdvert:    "router advertisement",
+			icmp6NeighborSolicit: "neighbor solicitation",
+			icmp6NeighborAdvert:  "neighbor advertisement",
+		}
+	}
+	n, ok := names[c.Type]
+	if !ok {
+		n = fmt.Sprintf("type %d code %d", c.Type, c.Code)
+	}
+	proto := "ICMP"
+	if c.V6 {
+		proto = "ICMP6"
+	}
+	if n == "echo request" || n == "echo reply" {
+		return fmt.Sprintf("%s %s, id %d, seq %d", proto, n, c.ID, c.Seq)
+	}
+	return fmt.Sprintf("%s %s", proto, n)
+}
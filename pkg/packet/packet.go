@@ -0,0 +1,265 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package packet decodes captured Ethernet frames and filters them.
+//
+// Decode understands Ethernet (with 802.1Q tags), ARP, IPv4, IPv6, UDP,
+// TCP, ICMP and ICMPv6, and DHCPv4 and DHCPv6 on their well-known ports.
+// Filters use a small subset of the pcap-filter(7) syntax.
+package packet
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/mdlayher/ethernet"
+)
+
+// Well-known DHCP ports.
+const (
+	dhcpv4ServerPort = 67
+	dhcpv4ClientPort = 68
+	dhcpv6ClientPort = 546
+	dhcpv6ServerPort = 547
+)
+
+// Packet is a decoded frame. Layers that are not present are nil.
+type Packet struct {
+	Ethernet *ethernet.Frame
+	ARP      *ARP
+	IPv4     *IPv4
+	IPv6     *IPv6
+	UDP      *UDP
+	TCP      *TCP
+	ICMP     *ICMP
+	DHCPv4   *dhcpv4.DHCPv4
+	DHCPv6   dhcpv6.DHCPv6
+
+	// Payload is whatever follows the innermost decoded layer.
+	Payload []byte
+
+	// Err is set if a layer could not be decoded. The layers before it
+	// are still filled in.
+	Err error
+}
+
+// Decode decodes an Ethernet frame. It always returns a Packet, with Err
+// set if decoding stopped early.
+func Decode(b []byte) *Packet {
+	p := &Packet{}
+	p.Err = p.decode(b)
+	return p
+}
+
+func (p *Packet) decode(b []byte) error {
+	p.Ethernet = &ethernet.Frame{}
+	if err := p.Ethernet.UnmarshalBinary(b); err != nil {
+		p.Ethernet = nil
+		return fmt.Errorf("ethernet: %v", err)
+	}
+	p.Payload = p.Ethernet.Payload
+
+	var err error
+	var proto uint8
+	switch p.Ethernet.EtherType {
+	case ethernet.EtherTypeARP:
+		p.ARP, err = decodeARP(p.Payload)
+		return err
+	case ethernet.EtherTypeIPv4:
+		if p.IPv4, p.Payload, err = decodeIPv4(p.Payload); err != nil {
+			return err
+		}
+		// Only the first fragment has the transport header.
+		if p.IPv4.FragOffset != 0 {
+			return nil
+		}
+		proto = p.IPv4.Protocol
+	case ethernet.EtherTypeIPv6:
+		if p.IPv6, p.Payload, err = decodeIPv6(p.Payload); err != nil {
+			return err
+		}
+		proto = p.IPv6.NextHeader
+	default:
+		return nil
+	}
+
+	switch proto {
+	case ProtoUDP:
+		if p.UDP, p.Payload, err = decodeUDP(p.Payload); err != nil {
+			return err
+		}
+		return p.decodeDHCP()
+	case ProtoTCP:
+		p.TCP, p.Payload, err = decodeTCP(p.Payload)
+	case ProtoICMP:
+		p.ICMP, p.Payload, err = decodeICMP(p.Payload, false)
+	case ProtoICMPv6:
+		p.ICMP, p.Payload, err = decodeICMP(p.Payload, true)
+	}
+	return err
+}
+
+func (p *Packet) decodeDHCP() error {
+	var err error
+	switch {
+	case p.IPv4 != nil && isPort(p.UDP, dhcpv4ServerPort, dhcpv4ClientPort):
+		if p.DHCPv4, err = dhcpv4.FromBytes(p.Payload); err != nil {
+			return fmt.Errorf("DHCPv4: %v", err)
+		}
+	case p.IPv6 != nil && isPort(p.UDP, dhcpv6ServerPort, dhcpv6ClientPort):
+		if p.DHCPv6, err = dhcpv6.FromBytes(p.Payload); err != nil {
+			return fmt.Errorf("DHCPv6: %v", err)
+		}
+	}
+	return nil
+}
+
+// isPort reports whether u goes between ports a and b, in either direction.
+func isPort(u *UDP, a, b uint16) bool {
+	return (u.SrcPort == a && u.DstPort == b) || (u.SrcPort == b && u.DstPort == a)
+}
+
+// Src returns the network layer source address, or nil.
+func (p *Packet) Src() net.IP {
+	switch {
+	case p.IPv4 != nil:
+		return p.IPv4.Src
+	case p.IPv6 != nil:
+		return p.IPv6.Src
+	case p.ARP != nil:
+		return p.ARP.SenderIP
+	}
+	return nil
+}
+
+// Dst returns the network layer destination address, or nil.
+func (p *Packet) Dst() net.IP {
+	switch {
+	case p.IPv4 != nil:
+		return p.IPv4.Dst
+	case p.IPv6 != nil:
+		return p.IPv6.Dst
+	case p.ARP != nil:
+		return p.ARP.TargetIP
+	}
+	return nil
+}
+
+// Ports returns the transport layer ports. ok is false if there is no
+// UDP or TCP header.
+func (p *Packet) Ports() (src, dst uint16, ok bool) {
+	switch {
+	case p.UDP != nil:
+		return p.UDP.SrcPort, p.UDP.DstPort, true
+	case p.TCP != nil:
+		return p.TCP.SrcPort, p.TCP.DstPort, true
+	}
+	return 0, 0, false
+}
+
+func hostPort(ip net.IP, port uint16) string {
+	return fmt.Sprintf("%s.%d", ip, port)
+}
+
+// String returns a one-line summary of p in the style of tcpdump.
+func (p *Packet) String() string {
+	var s []string
+	switch {
+	case p.Ethernet == nil:
+		s = append(s, "truncated frame")
+	case p.ARP != nil:
+		a := p.ARP
+		switch a.Operation {
+		case ARPRequest:
+			s = append(s, fmt.Sprintf("ARP, Request who-has %s tell %s", a.TargetIP, a.SenderIP))
+		case ARPReply:
+			s = append(s, fmt.Sprintf("ARP, Reply %s is-at %s", a.SenderIP, a.SenderHW))
+		default:
+			s = append(s, fmt.Sprintf("ARP, operation %d", a.Operation))
+		}
+	case p.IPv4 == nil && p.IPv6 == nil:
+		s = append(s, fmt.Sprintf("%s > %s, ethertype %#04x, length %d",
+			p.Ethernet.Source, p.Ethernet.Destination, uint16(p.Ethernet.EtherType), len(p.Ethernet.Payload)))
+	default:
+		proto := "IP"
+		if p.IPv6 != nil {
+			proto = "IP6"
+		}
+		if sp, dp, ok := p.Ports(); ok {
+			s = append(s, fmt.Sprintf("%s %s > %s:", proto, hostPort(p.Src(), sp), hostPort(p.Dst(), dp)))
+		} else {
+			s = append(s, fmt.Sprintf("%s %s > %s:", proto, p.Src(), p.Dst()))
+		}
+		switch {
+		case p.DHCPv4 != nil:
+			s = append(s, fmt.Sprintf("DHCPv4 %s, xid %s", p.DHCPv4.MessageType(), p.DHCPv4.TransactionID))
+		case p.DHCPv6 != nil:
+			s = append(s, fmt.Sprintf("DHCPv6 %s", p.DHCPv6.Type()))
+			if m, err := p.DHCPv6.GetInnerMessage(); err == nil && p.DHCPv6.IsRelay() {
+				s = append(s, fmt.Sprintf("(relayed %s)", m.Type()))
+			}
+		case p.UDP != nil:
+			s = append(s, fmt.Sprintf("UDP, length %d", len(p.Payload)))
+		case p.TCP != nil:
+			s = append(s, fmt.Sprintf("Flags [%s], seq %d, ack %d, win %d, length %d",
+				p.TCP.flagString(), p.TCP.Seq, p.TCP.Ack, p.TCP.Window, len(p.Payload)))
+		case p.ICMP != nil:
+			s = append(s, p.ICMP.String())
+		case p.IPv4 != nil:
+			s = append(s, fmt.Sprintf("proto %d, length %d", p.IPv4.Protocol, len(p.Payload)))
+		default:
+			s = append(s, fmt.Sprintf("next header %d, length %d", p.IPv6.NextHeader, len(p.Payload)))
+		}
+	}
+	if p.Err != nil {
+		s = append(s, fmt.Sprintf("[%v]", p.Err))
+	}
+	return strings.Join(s, " ")
+}
+
+// Details returns a multi-line description of every decoded layer.
+func (p *Packet) Details() string {
+	var b strings.Builder
+	if e := p.Ethernet; e != nil {
+		fmt.Fprintf(&b, "Ethernet %s > %s, ethertype %s", e.Source, e.Destination, e.EtherType)
+		if e.VLAN != nil {
+			fmt.Fprintf(&b, ", vlan %d", e.VLAN.ID)
+		}
+		b.WriteString("\n")
+	}
+	if ip := p.IPv4; ip != nil {
+		fmt.Fprintf(&b, "IPv4 %s > %s, tos %#x, ttl %d, id %d, flags %#x, offset %d, proto %d, length %d\n",
+			ip.Src, ip.Dst, ip.TOS, ip.TTL, ip.ID, ip.Flags, ip.FragOffset, ip.Protocol, ip.Length)
+	}
+	if ip := p.IPv6; ip != nil {
+		fmt.Fprintf(&b, "IPv6 %s > %s, class %#x, flow %#x, hlim %d, next header %d, payload length %d\n",
+			ip.Src, ip.Dst, ip.TrafficClass, ip.FlowLabel, ip.HopLimit, ip.NextHeader, ip.PayloadLength)
+	}
+	if u := p.UDP; u != nil {
+		fmt.Fprintf(&b, "UDP %d > %d, length %d\n", u.SrcPort, u.DstPort, u.Length)
+	}
+	if t := p.TCP; t != nil {
+		fmt.Fprintf(&b, "TCP %d > %d, flags [%s], seq %d, ack %d, win %d\n",
+			t.SrcPort, t.DstPort, t.flagString(), t.Seq, t.Ack, t.Window)
+	}
+	if p.ICMP != nil {
+		fmt.Fprintf(&b, "%s\n", p.ICMP)
+	}
+	if p.ARP != nil {
+		fmt.Fprintf(&b, "%s\n", p.String())
+	}
+	if p.DHCPv4 != nil {
+		b.WriteString(p.DHCPv4.Summary())
+	}
+	if p.DHCPv6 != nil {
+		b.WriteString(p.DHCPv6.Summary())
+	}
+	if p.Err != nil {
+		fmt.Fprintf(&b, "error: %v\n", p.Err)
+	}
+	return b.String()
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packet
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/u-root/u-root/pkg/pcap"
+)
+
+// readCapture returns the decoded packets in testdata/capture.pcap, which
+// holds, in order: a DHCPv4 DISCOVER, an ARP request, an ICMP echo
+// request, a TCP SYN, a DHCPv6 SOLICIT behind a hop-by-hop header, a
+// VLAN-tagged UDP datagram and an ICMPv6 echo reply.
+func readCapture(t *testing.T) []*Packet {
+	t.Helper()
+	f, err := os.Open("testdata/capture.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkts []*Packet
+	for {
+		_, data, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkts = append(pkts, Decode(data))
+	}
+	if len(pkts) != 7 {
+		t.Fatalf("got %d packets, want 7", len(pkts))
+	}
+	return pkts
+}
+
+func TestDecode(t *testing.T) {
+	pkts := readCapture(t)
+	for i, p := range pkts {
+		if p.Err != nil {
+			t.Errorf("packet %d: %v", i, p.Err)
+		}
+	}
+
+	if d := pkts[0].DHCPv4; d == nil {
+		t.Errorf("packet 0: no DHCPv4 layer")
+	} else if d.MessageType() != dhcpv4.MessageTypeDiscover || d.TransactionID.String() != "0xdeadbeef" {
+		t.Errorf("packet 0: got %s xid %s, want DISCOVER xid 0xdeadbeef", d.MessageType(), d.TransactionID)
+	}
+
+	if a := pkts[1].ARP; a == nil || a.Operation != ARPRequest || a.TargetIP.String() != "192.168.1.1" {
+		t.Errorf("packet 1: got ARP %+v, want request for 192.168.1.1", a)
+	}
+
+	if c := pkts[2].ICMP; c == nil || c.V6 || c.Type != icmpEchoRequest || c.ID != 0x1234 || c.Seq != 7 {
+		t.Errorf("packet 2: got ICMP %+v, want echo request id 0x1234 seq 7", c)
+	}
+
+	if tc := pkts[3].TCP; tc == nil || tc.DstPort != 80 || tc.Flags != TCPSyn || tc.Seq != 1000 {
+		t.Errorf("packet 3: got TCP %+v, want SYN to port 80 seq 1000", tc)
+	}
+
+	if p := pkts[4]; p.IPv6 == nil || p.IPv6.NextHeader != ProtoUDP || p.DHCPv6 == nil {
+		t.Errorf("packet 4: got %+v, want DHCPv6 over UDP", p)
+	} else if p.DHCPv6.Type() != dhcpv6.MessageTypeSolicit {
+		t.Errorf("packet 4: got DHCPv6 %s, want SOLICIT", p.DHCPv6.Type())
+	}
+
+	if p := pkts[5]; p.Ethernet.VLAN == nil || p.Ethernet.VLAN.ID != 42 || string(p.Payload) != "hello" {
+		t.Errorf("packet 5: got VLAN %+v payload %q, want VLAN 42 payload hello", p.Ethernet.VLAN, p.Payload)
+	}
+
+	if c := pkts[6].ICMP; c == nil || !c.V6 || c.Type != icmp6EchoReply {
+		t.Errorf("packet 6: got ICMP %+v, want ICMPv6 echo reply", c)
+	}
+}
+
+func TestString(t *testing.T) {
+	want := []string{
+		"IP 0.0.0.0.68 > 255.255.255.255.67: DHCPv4 DISCOVER, xid 0xdeadbeef",
+		"ARP, Request who-has 192.168.1.1 tell 192.168.1.10",
+		"IP 192.168.1.10 > 192.168.1.1: ICMP echo request, id 4660, seq 7",
+		"IP 192.168.1.10.40000 > 192.168.1.1.80: Flags [S], seq 1000, ack 0, win 65535, length 0",
+		"IP6 fe80::5054:ff:fe12:3456.546 > ff02::1:2.547: DHCPv6 SOLICIT",
+		"IP 10.0.0.1.5000 > 10.0.0.2.53: UDP, length 5",
+		"IP6 2001:db8::1 > 2001:db8::2: ICMP6 echo reply, id 1, seq 2",
+	}
+	for i, p := range readCapture(t) {
+		if got := p.String(); got != want[i] {
+			t.Errorf("packet %d: got %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	pkts := readCapture(t)
+	// Cut the TCP SYN in the middle of its TCP header.
+	f, err := os.Open("testdata/capture.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := pcap.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for i := 0; i < 4; i++ {
+		if _, data, err = r.ReadPacket(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := Decode(data[:14+20+10])
+	if p.Err == nil || p.IPv4 == nil || p.TCP != nil {
+		t.Errorf("truncated TCP: got err %v, IPv4 %v, TCP %v; want an error and only IPv4", p.Err, p.IPv4 != nil, p.TCP != nil)
+	}
+	if pkts[3].TCP == nil {
+		t.Errorf("untruncated TCP did not decode")
+	}
+
+	if p := Decode([]byte{1, 2, 3}); p.Err == nil || p.Ethernet != nil {
+		t.Errorf("3-byte frame: got %+v, want an error", p)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	pkts := readCapture(t)
+	for _, tt := range []struct {
+		expr string
+		// want lists the indexes of matching packets.
+		want []int
+	}{
+		{"", []int{0, 1, 2, 3, 4, 5, 6}},
+		{"udp", []int{0, 4, 5}},
+		{"dhcp", []int{0, 4}},
+		{"udp port 67", []int{0}},
+		{"udp and port 67", []int{0}},
+		{"src port 68 or dst port 547", []int{0, 4}},
+		{"arp", []int{1}},
+		{"host 192.168.1.1", []int{1, 2, 3}},
+		{"dst host 192.168.1.1 and not arp", []int{2, 3}},
+		{"src host 192.168.1.1", nil},
+		{"icmp || icmp6", []int{2, 6}},
+		{"ip6", []int{4, 6}},
+		{"tcp and (port 80 or port 443)", []int{3}},
+		{"net 10.0.0.0/8", []int{5}},
+		{"vlan 42", []int{5}},
+		{"vlan", []int{5}},
+		{"ether proto arp", []int{1}},
+		{"ether proto 0x86dd", []int{4, 6}},
+		{"ether dst host ff:ff:ff:ff:ff:ff", []int{0, 1}},
+		{"!ip and !ip6", []int{1}},
+	} {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		var got []int
+		for i, p := range pkts {
+			if f.Match(p) {
+				got = append(got, i)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("filter %q matched %v, want %v", tt.expr, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("filter %q matched %v, want %v", tt.expr, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"host",
+		"host nope",
+		"port http",
+		"tcp and",
+		"(tcp",
+		"tcp)",
+		"bogus",
+		"ether host 1.2.3.4",
+		"net 10.0.0.0",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded", expr)
+		}
+	}
+}
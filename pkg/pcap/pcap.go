@@ -0,0 +1,179 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pcap reads and writes packet captures in the classic libpcap
+// file format, as understood by tcpdump and Wireshark.
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Magic numbers distinguish byte order and timestamp resolution.
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+)
+
+// LinkTypeEthernet is the link type of Ethernet captures.
+const LinkTypeEthernet = 1
+
+// DefaultSnapLen is the default maximum number of bytes kept per packet.
+const DefaultSnapLen = 262144
+
+const (
+	versionMajor = 2
+	versionMinor = 4
+)
+
+// fileHeader is the pcap global header.
+type fileHeader struct {
+	Magic        uint32
+	VersionMajor uint16
+	VersionMinor uint16
+	ThisZone     int32
+	SigFigs      uint32
+	SnapLen      uint32
+	LinkType     uint32
+}
+
+// recordHeader precedes each packet.
+type recordHeader struct {
+	Sec     uint32
+	Frac    uint32
+	InclLen uint32
+	OrigLen uint32
+}
+
+// CaptureInfo is the metadata recorded with each packet.
+type CaptureInfo struct {
+	Timestamp time.Time
+
+	// Length is the length of the packet on the wire, which may be
+	// more than was captured.
+	Length int
+}
+
+// Writer writes packets to a pcap file.
+type Writer struct {
+	w       io.Writer
+	snapLen uint32
+}
+
+// NewWriter writes a pcap file header for linkType to w and returns a
+// Writer for the packets. Packets longer than snapLen are truncated.
+func NewWriter(w io.Writer, snapLen uint32, linkType uint32) (*Writer, error) {
+	h := fileHeader{
+		Magic:        magicMicroseconds,
+		VersionMajor: versionMajor,
+		VersionMinor: versionMinor,
+		SnapLen:      snapLen,
+		LinkType:     linkType,
+	}
+	if err := binary.Write(w, binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, snapLen: snapLen}, nil
+}
+
+// WritePacket appends a packet to the file.
+func (w *Writer) WritePacket(ci CaptureInfo, data []byte) error {
+	if uint32(len(data)) > w.snapLen {
+		data = data[:w.snapLen]
+	}
+	if ci.Length < len(data) {
+		ci.Length = len(data)
+	}
+	ts := ci.Timestamp
+	h := recordHeader{
+		Sec:     uint32(ts.Unix()),
+		Frac:    uint32(ts.Nanosecond() / 1000),
+		InclLen: uint32(len(data)),
+		OrigLen: uint32(ci.Length),
+	}
+	if err := binary.Write(w.w, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	_, err := w.w.Write(data)
+	return err
+}
+
+// Reader reads packets from a pcap file.
+type Reader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	snapLen  uint32
+	linkType uint32
+}
+
+// NewReader reads the pcap file header from r. Files in either byte order
+// and with microsecond or nanosecond timestamps are accepted.
+func NewReader(r io.Reader) (*Reader, error) {
+	var b [24]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, fmt.Errorf("reading pcap header: %v", err)
+	}
+	rd := &Reader{r: r}
+	switch {
+	case binary.LittleEndian.Uint32(b[:]) == magicMicroseconds:
+		rd.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(b[:]) == magicMicroseconds:
+		rd.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(b[:]) == magicNanoseconds:
+		rd.order, rd.nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(b[:]) == magicNanoseconds:
+		rd.order, rd.nanos = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("not a pcap file: magic %#x", binary.LittleEndian.Uint32(b[:]))
+	}
+	if major := rd.order.Uint16(b[4:]); major != versionMajor {
+		return nil, fmt.Errorf("unsupported pcap version %d", major)
+	}
+	rd.snapLen = rd.order.Uint32(b[16:])
+	rd.linkType = rd.order.Uint32(b[20:])
+	return rd, nil
+}
+
+// LinkType returns the link type of the packets in the file.
+func (r *Reader) LinkType() uint32 {
+	return r.linkType
+}
+
+// SnapLen returns the maximum length of packets in the file.
+func (r *Reader) SnapLen() uint32 {
+	return r.snapLen
+}
+
+// ReadPacket returns the next packet. It returns io.EOF at the end of the
+// file.
+func (r *Reader) ReadPacket() (CaptureInfo, []byte, error) {
+	var h recordHeader
+	if err := binary.Read(r.r, r.order, &h); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("truncated pcap record header")
+		}
+		return CaptureInfo{}, nil, err
+	}
+	// Guard against garbage lengths allocating huge buffers.
+	if h.InclLen > r.snapLen && h.InclLen > DefaultSnapLen {
+		return CaptureInfo{}, nil, fmt.Errorf("pcap record length %d exceeds snap length %d", h.InclLen, r.snapLen)
+	}
+	data := make([]byte, h.InclLen)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return CaptureInfo{}, nil, fmt.Errorf("truncated pcap record: %v", err)
+	}
+	nsec := int64(h.Frac) * 1000
+	if r.nanos {
+		nsec = int64(h.Frac)
+	}
+	return CaptureInfo{
+		Timestamp: time.Unix(int64(h.Sec), nsec),
+		Length:    int(h.OrigLen),
+	}, data, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, 8, LinkTypeEthernet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1600000000, 123456000)
+	pkts := [][]byte{
+		[]byte("short"),
+		[]byte("longer than the snap length"),
+	}
+	for _, p := range pkts {
+		if err := w.WritePacket(CaptureInfo{Timestamp: ts, Length: len(p)}, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.LinkType() != LinkTypeEthernet || r.SnapLen() != 8 {
+		t.Errorf("got link type %d snap length %d, want %d and 8", r.LinkType(), r.SnapLen(), LinkTypeEthernet)
+	}
+	for i, p := range pkts {
+		ci, data, err := r.ReadPacket()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		want := p
+		if len(want) > 8 {
+			want = want[:8]
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("packet %d: got %q, want %q", i, data, want)
+		}
+		if ci.Length != len(p) || !ci.Timestamp.Equal(ts) {
+			t.Errorf("packet %d: got %+v, want length %d at %v", i, ci, len(p), ts)
+		}
+	}
+	if _, _, err := r.ReadPacket(); err != io.EOF {
+		t.Errorf("got %v at end of file, want io.EOF", err)
+	}
+}
+
+func TestReadBigEndianNanoseconds(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, fileHeader{
+		Magic:        magicNanoseconds,
+		VersionMajor: versionMajor,
+		VersionMinor: versionMinor,
+		SnapLen:      DefaultSnapLen,
+		LinkType:     LinkTypeEthernet,
+	})
+	binary.Write(&buf, binary.BigEndian, recordHeader{Sec: 10, Frac: 42, InclLen: 3, OrigLen: 60})
+	buf.WriteString("abc")
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci, data, err := r.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(10, 42); !ci.Timestamp.Equal(want) || ci.Length != 60 || string(data) != "abc" {
+		t.Errorf("got %+v %q, want length 60 at %v and abc", ci, data, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", make([]byte, 24)},
+	} {
+		if _, err := NewReader(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: NewReader succeeded", tt.name)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, DefaultSnapLen, LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+	binary.Write(&buf, binary.LittleEndian, recordHeader{InclLen: 10, OrigLen: 10})
+	buf.WriteString("abc")
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.ReadPacket(); err == nil || err == io.EOF {
+		t.Errorf("truncated record: got %v, want an error", err)
+	}
+}
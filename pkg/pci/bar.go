@@ -0,0 +1,166 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Resource is an address range the kernel assigned to a device, as listed
+// in its sysfs resource file: the BARs, expansion ROM, SR-IOV BARs and
+// bridge windows, in that order.
+type Resource struct {
+	Start, End, Flags uint64
+}
+
+// Size returns the size of the range, 0 if it is unused.
+func (r Resource) Size() uint64 {
+	if r.End == 0 {
+		return 0
+	}
+	return r.End - r.Start + 1
+}
+
+// Index of the expansion ROM in the resources.
+const romResource = 6
+
+// Resources reads the resources of the device.
+func (p *PCI) Resources() ([]Resource, error) {
+	b, err := ioutil.ReadFile(filepath.Join(p.FullPath, "resource"))
+	if err != nil {
+		return nil, err
+	}
+	return parseResources(string(b))
+}
+
+func parseResources(s string) ([]Resource, error) {
+	var res []Resource
+	for _, l := range strings.Split(strings.TrimSpace(s), "\n") {
+		f := strings.Fields(l)
+		if len(f) != 3 {
+			return nil, fmt.Errorf("resource %q: want start, end and flags", l)
+		}
+		var v [3]uint64
+		for i := range f {
+			var err error
+			if v[i], err = strconv.ParseUint(f[i], 0, 64); err != nil {
+				return nil, fmt.Errorf("resource %q: %v", l, err)
+			}
+		}
+		res = append(res, Resource{Start: v[0], End: v[1], Flags: v[2]})
+	}
+	return res, nil
+}
+
+// BAR is a base address register of a device, or its expansion ROM.
+type BAR struct {
+	// Index is the number of the BAR, or -1 for the expansion ROM.
+	Index int
+	Addr  uint64
+	// Size of the region. A BAR is sized by writing all ones to it, which
+	// would break a device in use, so it comes from the resources the
+	// kernel sized when it enumerated the bus. It is 0 if unknown.
+	Size         uint64
+	IO           bool
+	Is64         bool
+	Prefetchable bool
+	// Disabled is set if the device does not decode the region: the
+	// memory or I/O bit of its command register, or the enable bit of the
+	// ROM, is clear.
+	Disabled bool
+}
+
+// String formats the BAR as lspci does.
+func (b BAR) String() string {
+	var s string
+	switch {
+	case b.Index < 0:
+		s = fmt.Sprintf("Expansion ROM at %08x", b.Addr)
+	case b.IO:
+		s = fmt.Sprintf("Region %d: I/O ports at %04x", b.Index, b.Addr)
+	default:
+		bits, pref := "32-bit", "non-"
+		if b.Is64 {
+			bits = "64-bit"
+		}
+		if b.Prefetchable {
+			pref = ""
+		}
+		s = fmt.Sprintf("Region %d: Memory at %08x (%s, %sprefetchable)", b.Index, b.Addr, bits, pref)
+	}
+	if b.Disabled {
+		s += " [disabled]"
+	}
+	if b.Size != 0 {
+		s += " " + sizeString(b.Size)
+	}
+	return s
+}
+
+// BARs returns the base address registers of the device that are
+// implemented. res are its resources for the sizes; they may be nil.
+func (c Config) BARs(res []Resource) []BAR {
+	n := 6
+	switch c.HeaderType() {
+	case HeaderTypeBridge:
+		n = 2
+	case HeaderTypeCardbus:
+		n = 1
+	}
+	cmd := c.Command()
+	var bars []BAR
+	for i := 0; i < n; i++ {
+		v := c.u32(regBAR0 + 4*i)
+		b := BAR{Index: i}
+		if v&1 != 0 {
+			b.IO = true
+			b.Addr = uint64(v &^ 3)
+			b.Disabled = cmd&CommandIO == 0
+		} else {
+			b.Addr = uint64(v &^ 0xf)
+			b.Prefetchable = v&8 != 0
+			if b.Is64 = (v>>1)&3 == 2; b.Is64 && i+1 < n {
+				b.Addr |= uint64(c.u32(regBAR0+4*i+4)) << 32
+			}
+			b.Disabled = cmd&CommandMemory == 0
+		}
+		if i < len(res) {
+			b.Size = res[i].Size()
+		}
+		if b.Is64 {
+			i++
+		}
+		if v == 0 && b.Size == 0 {
+			continue
+		}
+		bars = append(bars, b)
+	}
+	return bars
+}
+
+// ROM returns the expansion ROM of the device, or nil if it has none. res
+// are its resources for the size; they may be nil.
+func (c Config) ROM(res []Resource) *BAR {
+	reg := regROM
+	switch c.HeaderType() {
+	case HeaderTypeBridge:
+		reg = regBridgeROM
+	case HeaderTypeCardbus:
+		return nil
+	}
+	v := c.u32(reg)
+	b := &BAR{Index: -1, Addr: uint64(v &^ 0x7ff), Disabled: v&1 == 0}
+	if romResource < len(res) {
+		b.Size = res[romResource].Size()
+	}
+	if b.Addr == 0 && b.Size == 0 {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Window is an address range a bridge forwards to its secondary bus.
+type Window struct {
+	Base, Limit uint64
+	Is64        bool
+}
+
+// Enabled reports whether the bridge forwards the window: a base above the
+// limit disables it.
+func (w Window) Enabled() bool {
+	return w.Base <= w.Limit
+}
+
+// Size returns the size of the window, 0 if it is disabled.
+func (w Window) Size() uint64 {
+	if !w.Enabled() {
+		return 0
+	}
+	return w.Limit - w.Base + 1
+}
+
+func (w Window) String() string {
+	s := fmt.Sprintf("%08x-%08x", w.Base, w.Limit)
+	if w.Is64 {
+		s = fmt.Sprintf("%016x-%016x", w.Base, w.Limit)
+	}
+	if !w.Enabled() {
+		return s + " [disabled]"
+	}
+	return s + " " + sizeString(w.Size())
+}
+
+// Bridge is the bus numbers and windows of a PCI-to-PCI bridge.
+type Bridge struct {
+	Primary, Secondary, Subordinate uint8
+	SecondaryLatency                uint8
+
+	IO           Window
+	Memory       Window
+	Prefetchable Window
+}
+
+// Bridge decodes the bus numbers and windows of a bridge.
+func (c Config) Bridge() (*Bridge, error) {
+	if c.HeaderType() != HeaderTypeBridge {
+		return nil, fmt.Errorf("header type %d is not a bridge", c.HeaderType())
+	}
+	b := &Bridge{
+		Primary:          c.u8(regPrimaryBus),
+		Secondary:        c.u8(regSecondaryBus),
+		Subordinate:      c.u8(regSubordinate),
+		SecondaryLatency: c.u8(regSecLatency),
+	}
+
+	// The low nibble of the base registers is the addressing capability:
+	// 1 if the upper registers extend the window to 32 or 64 bits.
+	base, limit := c.u8(regIOBase), c.u8(regIOLimit)
+	b.IO = Window{Base: uint64(base&0xf0) << 8, Limit: uint64(limit&0xf0)<<8 | 0xfff}
+	if base&0xf == 1 {
+		b.IO.Base |= uint64(c.u16(regIOBaseHi)) << 16
+		b.IO.Limit |= uint64(c.u16(regIOLimitHi)) << 16
+	}
+
+	b.Memory = Window{
+		Base:  uint64(c.u16(regMemBase)&0xfff0) << 16,
+		Limit: uint64(c.u16(regMemLimit)&0xfff0)<<16 | 0xfffff,
+	}
+
+	pbase, plimit := c.u16(regPrefBase), c.u16(regPrefLimit)
+	b.Prefetchable = Window{
+		Base:  uint64(pbase&0xfff0) << 16,
+		Limit: uint64(plimit&0xfff0)<<16 | 0xfffff,
+		Is64:  pbase&0xf == 1,
+	}
+	if b.Prefetchable.Is64 {
+		b.Prefetchable.Base |= uint64(c.u32(regPrefBaseHi)) << 32
+		b.Prefetchable.Limit |= uint64(c.u32(regPrefLimitHi)) << 32
+	}
+	return b, nil
+}
+
+// String formats the bridge as lspci -vvv does.
+func (b *Bridge) String() string {
+	return strings.Join([]string{
+		fmt.Sprintf("Bus: primary=%02x, secondary=%02x, subordinate=%02x, sec-latency=%d", b.Primary, b.Secondary, b.Subordinate, b.SecondaryLatency),
+		fmt.Sprintf("I/O behind bridge: %s", b.IO),
+		fmt.Sprintf("Memory behind bridge: %s", b.Memory),
+		fmt.Sprintf("Prefetchable memory behind bridge: %s", b.Prefetchable),
+	}, "\n")
+}
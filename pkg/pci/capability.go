@@ -0,0 +1,421 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IDs of the capabilities in the capability list.
+const (
+	CapPM          = 0x01
+	CapAGP         = 0x02
+	CapVPD         = 0x03
+	CapSlotID      = 0x04
+	CapMSI         = 0x05
+	CapHotSwap     = 0x06
+	CapPCIX        = 0x07
+	CapHT          = 0x08
+	CapVendor      = 0x09
+	CapDebug       = 0x0a
+	CapCCRC        = 0x0b
+	CapHotPlug     = 0x0c
+	CapSSVID       = 0x0d
+	CapAGP3        = 0x0e
+	CapSecure      = 0x0f
+	CapExpress     = 0x10
+	CapMSIX        = 0x11
+	CapSATA        = 0x12
+	CapAF          = 0x13
+	CapEA          = 0x14
+	CapFPB         = 0x15
+	ExtCapAER      = 0x0001
+	ExtCapVC       = 0x0002
+	ExtCapDSN      = 0x0003
+	ExtCapPwrBgt   = 0x0004
+	ExtCapRCLink   = 0x0005
+	ExtCapRCIntLnk = 0x0006
+	ExtCapRCEC     = 0x0007
+	ExtCapMFVC     = 0x0008
+	ExtCapVC9      = 0x0009
+	ExtCapRCRB     = 0x000a
+	ExtCapVendor   = 0x000b
+	ExtCapCAC      = 0x000c
+	ExtCapACS      = 0x000d
+	ExtCapARI      = 0x000e
+	ExtCapATS      = 0x000f
+	ExtCapSRIOV    = 0x0010
+	ExtCapMRIOV    = 0x0011
+	ExtCapMCast    = 0x0012
+	ExtCapPRI      = 0x0013
+	ExtCapReBAR    = 0x0015
+	ExtCapDPA      = 0x0016
+	ExtCapTPH      = 0x0017
+	ExtCapLTR      = 0x0018
+	ExtCapSecPCIe  = 0x0019
+	ExtCapPMUX     = 0x001a
+	ExtCapPASID    = 0x001b
+	ExtCapLNR      = 0x001c
+	ExtCapDPC      = 0x001d
+	ExtCapL1PM     = 0x001e
+	ExtCapPTM      = 0x001f
+	ExtCapDVSEC    = 0x0023
+	ExtCapVFReBAR  = 0x0024
+	ExtCapDLF      = 0x0025
+	ExtCapPL16     = 0x0026
+)
+
+var capNames = map[uint16]string{
+	CapPM:      "Power Management",
+	CapAGP:     "AGP",
+	CapVPD:     "Vital Product Data",
+	CapSlotID:  "Slot ID",
+	CapMSI:     "MSI",
+	CapHotSwap: "CompactPCI hot-swap",
+	CapPCIX:    "PCI-X",
+	CapHT:      "HyperTransport",
+	CapVendor:  "Vendor Specific Information",
+	CapDebug:   "Debug port",
+	CapCCRC:    "CompactPCI central resource control",
+	CapHotPlug: "Hot-plug capable",
+	CapSSVID:   "Subsystem",
+	CapAGP3:    "AGP3",
+	CapSecure:  "Secure device",
+	CapExpress: "Express",
+	CapMSIX:    "MSI-X",
+	CapSATA:    "SATA HBA",
+	CapAF:      "PCI Advanced Features",
+	CapEA:      "Enhanced Allocation (EA)",
+	CapFPB:     "Flattening Portal Bridge",
+}
+
+var extCapNames = map[uint16]string{
+	ExtCapAER:      "Advanced Error Reporting",
+	ExtCapVC:       "Virtual Channel",
+	ExtCapDSN:      "Device Serial Number",
+	ExtCapPwrBgt:   "Power Budgeting",
+	ExtCapRCLink:   "Root Complex Link",
+	ExtCapRCIntLnk: "Root Complex Internal Link",
+	ExtCapRCEC:     "Root Complex Event Collector",
+	ExtCapMFVC:     "Multi-Function Virtual Channel",
+	ExtCapVC9:      "Virtual Channel",
+	ExtCapRCRB:     "Root Complex Register Block",
+	ExtCapVendor:   "Vendor Specific Information",
+	ExtCapCAC:      "Configuration Access Correlation",
+	ExtCapACS:      "Access Control Services",
+	ExtCapARI:      "Alternative Routing-ID Interpretation (ARI)",
+	ExtCapATS:      "Address Translation Service (ATS)",
+	ExtCapSRIOV:    "Single Root I/O Virtualization (SR-IOV)",
+	ExtCapMRIOV:    "Multi-Root I/O Virtualization (MR-IOV)",
+	ExtCapMCast:    "Multicast",
+	ExtCapPRI:      "Page Request Interface (PRI)",
+	ExtCapReBAR:    "Physical Resizable BAR",
+	ExtCapDPA:      "Dynamic Power Allocation",
+	ExtCapTPH:      "Transaction Processing Hints",
+	ExtCapLTR:      "Latency Tolerance Reporting",
+	ExtCapSecPCIe:  "Secondary PCI Express",
+	ExtCapPMUX:     "Protocol Multiplexing",
+	ExtCapPASID:    "Process Address Space ID (PASID)",
+	ExtCapLNR:      "LN Requester",
+	ExtCapDPC:      "Downstream Port Containment",
+	ExtCapL1PM:     "L1 PM Substates",
+	ExtCapPTM:      "Precision Time Measurement",
+	ExtCapDVSEC:    "Designated Vendor-Specific",
+	ExtCapVFReBAR:  "VF Resizable BAR",
+	ExtCapDLF:      "Data Link Feature",
+	ExtCapPL16:     "Physical Layer 16.0 GT/s",
+}
+
+// Capability is an entry of the capability list of a device, or of its
+// extended capability list.
+type Capability struct {
+	// ID is one of the Cap constants, or of the ExtCap constants if
+	// Extended is set.
+	ID       uint16
+	Extended bool
+	// Version of an extended capability.
+	Version uint8
+	// Offset of the capability in the config space.
+	Offset int
+}
+
+// Name returns the name of the capability.
+func (cp Capability) Name() string {
+	if cp.Extended {
+		if n, ok := extCapNames[cp.ID]; ok {
+			return n
+		}
+		return fmt.Sprintf("Extended Capability ID %#x", cp.ID)
+	}
+	if n, ok := capNames[cp.ID]; ok {
+		return n
+	}
+	return fmt.Sprintf("#%02x", cp.ID)
+}
+
+// String formats the position of the capability as lspci does, e.g. [40],
+// or [100 v1] for an extended capability.
+func (cp Capability) String() string {
+	if cp.Extended {
+		return fmt.Sprintf("[%x v%d]", cp.Offset, cp.Version)
+	}
+	return fmt.Sprintf("[%02x]", cp.Offset)
+}
+
+// Size of the config space with extended capabilities, and their offset.
+const (
+	extConfigSize = 4096
+	extCapStart   = 0x100
+)
+
+// Capabilities walks the capability list and, if the config space has
+// them, the extended capabilities.
+func (c Config) Capabilities() ([]Capability, error) {
+	if c.Status()&statusCapList == 0 {
+		return nil, nil
+	}
+	ptr := regCapList
+	if c.HeaderType() == HeaderTypeCardbus {
+		ptr = regCardbusCaps
+	}
+	var caps []Capability
+	seen := map[int]bool{}
+	for off := int(c.u8(ptr) &^ 3); off != 0; off = int(c.u8(off+1) &^ 3) {
+		if off < configHeaderSize {
+			return nil, fmt.Errorf("capability at %#x is in the header", off)
+		}
+		if off+2 > len(c) {
+			return nil, fmt.Errorf("capability at %#x is past the %d bytes of config space", off, len(c))
+		}
+		if seen[off] {
+			return nil, fmt.Errorf("capability list loops at %#x", off)
+		}
+		seen[off] = true
+		caps = append(caps, Capability{ID: uint16(c[off]), Offset: off})
+	}
+	if len(c) < extConfigSize {
+		return caps, nil
+	}
+
+	// The header of an extended capability is its ID, a 4 bit version
+	// and a 12 bit offset of the next one.
+	for off := extCapStart; off != 0; {
+		h := c.u32(off)
+		if h == 0 || h == 0xffffffff {
+			break
+		}
+		if seen[off] {
+			return nil, fmt.Errorf("extended capability list loops at %#x", off)
+		}
+		seen[off] = true
+		caps = append(caps, Capability{ID: uint16(h), Extended: true, Version: uint8(field(h, 16, 4)), Offset: off})
+		off = int(h>>20) &^ 3
+		if off != 0 && off < extCapStart {
+			return nil, fmt.Errorf("extended capability at %#x is not in the extended config space", off)
+		}
+	}
+	return caps, nil
+}
+
+// FindCapability returns the first capability with id, looking in the
+// extended capabilities if extended is set.
+func (c Config) FindCapability(id uint16, extended bool) (Capability, error) {
+	caps, err := c.Capabilities()
+	if err != nil {
+		return Capability{}, err
+	}
+	for _, cp := range caps {
+		if cp.ID == id && cp.Extended == extended {
+			return cp, nil
+		}
+	}
+	return Capability{}, fmt.Errorf("no %s capability", Capability{ID: id, Extended: extended}.Name())
+}
+
+// check returns an error if cp is not id or its n bytes do not fit in c.
+func (c Config) check(cp Capability, id uint16, extended bool, n int) error {
+	if cp.ID != id || cp.Extended != extended {
+		return fmt.Errorf("capability %s at %#x is not %s", cp.Name(), cp.Offset, Capability{ID: id, Extended: extended}.Name())
+	}
+	if cp.Offset+n > len(c) {
+		return fmt.Errorf("%s at %#x: %d bytes are past the %d bytes of config space", cp.Name(), cp.Offset, n, len(c))
+	}
+	return nil
+}
+
+// DecodeCapability returns the lspci -vvv style description of cp: its
+// name and summary, and the details on further lines indented with a tab.
+// Capabilities that are not decoded are described by their name.
+func (c Config) DecodeCapability(cp Capability) (string, error) {
+	var s fmt.Stringer
+	var err error
+	switch {
+	case !cp.Extended && cp.ID == CapPM:
+		s, err = NewPM(c, cp)
+	case !cp.Extended && cp.ID == CapMSI:
+		s, err = NewMSI(c, cp)
+	case !cp.Extended && cp.ID == CapMSIX:
+		s, err = NewMSIX(c, cp)
+	case !cp.Extended && cp.ID == CapExpress:
+		s, err = NewExpress(c, cp)
+	case !cp.Extended && cp.ID == CapVendor:
+		return fmt.Sprintf("%s: Len=%02x <?>", cp.Name(), c.u8(cp.Offset+2)), nil
+	case cp.Extended && cp.ID == ExtCapAER:
+		s, err = NewAER(c, cp)
+	case cp.Extended && cp.ID == ExtCapDSN:
+		s, err = NewDSN(c, cp)
+	case cp.Extended && cp.ID == ExtCapACS:
+		s, err = NewACS(c, cp)
+	case cp.Extended && cp.ID == ExtCapSRIOV:
+		s, err = NewSRIOV(c, cp)
+	case cp.Extended && cp.ID == ExtCapReBAR:
+		s, err = NewResizableBAR(c, cp)
+	default:
+		return cp.Name(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+// PM is the power management capability.
+type PM struct {
+	Capabilities uint16
+	Control      uint16
+}
+
+// NewPM decodes the power management capability cp of c.
+func NewPM(c Config, cp Capability) (*PM, error) {
+	if err := c.check(cp, CapPM, false, 8); err != nil {
+		return nil, err
+	}
+	return &PM{Capabilities: c.u16(cp.Offset + 2), Control: c.u16(cp.Offset + 4)}, nil
+}
+
+// Version returns the version of the power management specification.
+func (p *PM) Version() int {
+	return int(p.Capabilities & 7)
+}
+
+// PowerState returns the power state of the device: 0 for D0 to 3 for
+// D3hot.
+func (p *PM) PowerState() int {
+	return int(p.Control & 3)
+}
+
+func (p *PM) String() string {
+	c, s := uint32(p.Capabilities), uint32(p.Control)
+	aux := []int{0, 55, 100, 160, 220, 270, 320, 375}[field(c, 6, 3)]
+	return strings.Join([]string{
+		fmt.Sprintf("Power Management version %d", p.Version()),
+		fmt.Sprintf("\tFlags: PMEClk%c DSI%c D1%c D2%c AuxCurrent=%dmA PME(D0%c,D1%c,D2%c,D3hot%c,D3cold%c)",
+			fl(c, 3), fl(c, 5), fl(c, 9), fl(c, 10), aux, fl(c, 11), fl(c, 12), fl(c, 13), fl(c, 14), fl(c, 15)),
+		fmt.Sprintf("\tStatus: D%d NoSoftRst%c PME-Enable%c DSel=%d DScale=%d PME%c",
+			p.PowerState(), fl(s, 3), fl(s, 8), field(s, 9, 4), field(s, 13, 2), fl(s, 15)),
+	}, "\n")
+}
+
+// MSI is the message signaled interrupts capability.
+type MSI struct {
+	Control uint16
+	Address uint64
+	Data    uint16
+	// Mask and Pending are only there if the vectors are Maskable.
+	Mask, Pending uint32
+}
+
+// NewMSI decodes the MSI capability cp of c.
+func NewMSI(c Config, cp Capability) (*MSI, error) {
+	if err := c.check(cp, CapMSI, false, 10); err != nil {
+		return nil, err
+	}
+	m := &MSI{Control: c.u16(cp.Offset + 2), Address: uint64(c.u32(cp.Offset + 4))}
+	off := cp.Offset + 8
+	if m.Is64() {
+		m.Address |= uint64(c.u32(off)) << 32
+		off += 4
+	}
+	m.Data = c.u16(off)
+	if m.Maskable() {
+		m.Mask, m.Pending = c.u32(off+4), c.u32(off+8)
+	}
+	return m, nil
+}
+
+// Enabled reports whether the device uses MSI.
+func (m *MSI) Enabled() bool {
+	return m.Control&1 != 0
+}
+
+// Vectors returns the number of vectors the device supports.
+func (m *MSI) Vectors() int {
+	return 1 << field(uint32(m.Control), 1, 3)
+}
+
+// EnabledVectors returns the number of vectors the device may use.
+func (m *MSI) EnabledVectors() int {
+	return 1 << field(uint32(m.Control), 4, 3)
+}
+
+// Is64 reports whether the message address is 64 bits.
+func (m *MSI) Is64() bool {
+	return m.Control&(1<<7) != 0
+}
+
+// Maskable reports whether the vectors can be masked.
+func (m *MSI) Maskable() bool {
+	return m.Control&(1<<8) != 0
+}
+
+func (m *MSI) String() string {
+	v := uint32(m.Control)
+	lines := []string{fmt.Sprintf("MSI: Enable%c Count=%d/%d Maskable%c 64bit%c", fl(v, 0), m.EnabledVectors(), m.Vectors(), fl(v, 8), fl(v, 7))}
+	if m.Is64() {
+		lines = append(lines, fmt.Sprintf("\tAddress: %016x  Data: %04x", m.Address, m.Data))
+	} else {
+		lines = append(lines, fmt.Sprintf("\tAddress: %08x  Data: %04x", m.Address, m.Data))
+	}
+	if m.Maskable() {
+		lines = append(lines, fmt.Sprintf("\tMasking: %08x  Pending: %08x", m.Mask, m.Pending))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MSIX is the MSI-X capability.
+type MSIX struct {
+	Control uint16
+	// Table and PBA are the BAR index in the low 3 bits and the offset in
+	// that BAR of the vector table and pending bit array.
+	Table, PBA uint32
+}
+
+// NewMSIX decodes the MSI-X capability cp of c.
+func NewMSIX(c Config, cp Capability) (*MSIX, error) {
+	if err := c.check(cp, CapMSIX, false, 12); err != nil {
+		return nil, err
+	}
+	return &MSIX{Control: c.u16(cp.Offset + 2), Table: c.u32(cp.Offset + 4), PBA: c.u32(cp.Offset + 8)}, nil
+}
+
+// Enabled reports whether the device uses MSI-X.
+func (m *MSIX) Enabled() bool {
+	return m.Control&(1<<15) != 0
+}
+
+// TableSize returns the number of vectors.
+func (m *MSIX) TableSize() int {
+	return int(m.Control&0x7ff) + 1
+}
+
+func (m *MSIX) String() string {
+	v := uint32(m.Control)
+	return strings.Join([]string{
+		fmt.Sprintf("MSI-X: Enable%c Count=%d Masked%c", fl(v, 15), m.TableSize(), fl(v, 14)),
+		fmt.Sprintf("\tVector table: BAR=%d offset=%08x", m.Table&7, m.Table&^7),
+		fmt.Sprintf("\tPBA: BAR=%d offset=%08x", m.PBA&7, m.PBA&^7),
+	}, "\n")
+}
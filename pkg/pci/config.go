@@ -0,0 +1,228 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Config is the configuration space of a device: 256 bytes, or 4096 bytes
+// for PCI Express devices. Without root, Linux only lets us read the 64
+// byte header.
+type Config []byte
+
+// Offsets of the registers in the config header.
+const (
+	regVendorID      = 0x00
+	regDeviceID      = 0x02
+	regCommand       = 0x04
+	regStatus        = 0x06
+	regRevision      = 0x08
+	regHeaderType    = 0x0e
+	regBAR0          = 0x10
+	regPrimaryBus    = 0x18
+	regSecondaryBus  = 0x19
+	regSubordinate   = 0x1a
+	regSecLatency    = 0x1b
+	regIOBase        = 0x1c
+	regIOLimit       = 0x1d
+	regMemBase       = 0x20
+	regMemLimit      = 0x22
+	regPrefBase      = 0x24
+	regPrefLimit     = 0x26
+	regPrefBaseHi    = 0x28
+	regPrefLimitHi   = 0x2c
+	regIOBaseHi      = 0x30
+	regIOLimitHi     = 0x32
+	regCapList       = 0x34
+	regCardbusCaps   = 0x14
+	regROM           = 0x30
+	regBridgeROM     = 0x38
+	configHeaderSize = 0x40
+)
+
+// Header types.
+const (
+	HeaderTypeNormal  = 0
+	HeaderTypeBridge  = 1
+	HeaderTypeCardbus = 2
+)
+
+// Bits of the command register.
+const (
+	CommandIO     = 1 << 0
+	CommandMemory = 1 << 1
+	CommandMaster = 1 << 2
+)
+
+const (
+	// statusCapList is set in the status register if there are
+	// capabilities.
+	statusCapList = 1 << 4
+
+	headerTypeMulti  = 0x80
+	headerTypeLayout = 0x7f
+)
+
+// ConfigSpace reads the config space of the device.
+func (p *PCI) ConfigSpace() (Config, error) {
+	c, err := ioutil.ReadFile(filepath.Join(p.FullPath, "config"))
+	if err != nil {
+		return nil, err
+	}
+	if len(c) < configHeaderSize {
+		return nil, fmt.Errorf("%s: config space is %d bytes, want at least %d", p.Addr, len(c), configHeaderSize)
+	}
+	return Config(c), nil
+}
+
+// The register accessors return 0 for registers past the end of c, such as
+// the capabilities when only the header could be read.
+
+func (c Config) u8(off int) uint8 {
+	if off < 0 || off+1 > len(c) {
+		return 0
+	}
+	return c[off]
+}
+
+func (c Config) u16(off int) uint16 {
+	if off < 0 || off+2 > len(c) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(c[off:])
+}
+
+func (c Config) u32(off int) uint32 {
+	if off < 0 || off+4 > len(c) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(c[off:])
+}
+
+// VendorID returns the vendor ID.
+func (c Config) VendorID() uint16 {
+	return c.u16(regVendorID)
+}
+
+// DeviceID returns the device ID.
+func (c Config) DeviceID() uint16 {
+	return c.u16(regDeviceID)
+}
+
+// Command returns the command register.
+func (c Config) Command() uint16 {
+	return c.u16(regCommand)
+}
+
+// Status returns the status register.
+func (c Config) Status() uint16 {
+	return c.u16(regStatus)
+}
+
+// Revision returns the revision ID.
+func (c Config) Revision() uint8 {
+	return c.u8(regRevision)
+}
+
+// Class returns the class code: base class, subclass and programming
+// interface.
+func (c Config) Class() uint32 {
+	return c.u32(regRevision) >> 8
+}
+
+// HeaderType returns the layout of the header, e.g. HeaderTypeBridge.
+func (c Config) HeaderType() uint8 {
+	return c.u8(regHeaderType) & headerTypeLayout
+}
+
+// MultiFunction reports whether the device has more than one function.
+func (c Config) MultiFunction() bool {
+	return c.u8(regHeaderType)&headerTypeMulti != 0
+}
+
+// fl formats bit of v as lspci does, + if it is set and - if not.
+func fl(v uint32, bit uint) byte {
+	if v&(1<<bit) != 0 {
+		return '+'
+	}
+	return '-'
+}
+
+// field returns the n bits of v starting at bit.
+func field(v uint32, bit, n uint) uint32 {
+	return (v >> bit) & (1<<n - 1)
+}
+
+// controlString formats the command register as lspci does.
+func (c Config) controlString() string {
+	v := uint32(c.Command())
+	return fmt.Sprintf("Control: I/O%c Mem%c BusMaster%c SpecCycle%c MemWINV%c VGASnoop%c ParErr%c Stepping%c SERR%c FastB2B%c DisINTx%c",
+		fl(v, 0), fl(v, 1), fl(v, 2), fl(v, 3), fl(v, 4), fl(v, 5), fl(v, 6), fl(v, 7), fl(v, 8), fl(v, 9), fl(v, 10))
+}
+
+// statusString formats the status register as lspci does.
+func (c Config) statusString() string {
+	v := uint32(c.Status())
+	devsel := []string{"fast", "medium", "slow", "??"}[field(v, 9, 2)]
+	return fmt.Sprintf("Status: Cap%c 66MHz%c UDF%c FastB2B%c ParErr%c DEVSEL=%s >TAbort%c <TAbort%c <MAbort%c >SERR%c <PERR%c INTx%c",
+		fl(v, 4), fl(v, 5), fl(v, 6), fl(v, 7), fl(v, 8), devsel, fl(v, 11), fl(v, 12), fl(v, 13), fl(v, 14), fl(v, 15), fl(v, 3))
+}
+
+// sizeString formats a size as lspci does, e.g. [size=128K].
+func sizeString(size uint64) string {
+	units := []string{"", "K", "M", "G", "T"}
+	var i int
+	for size%1024 == 0 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("[size=%d%s]", size, units[i])
+}
+
+// Decode returns the lspci -vvv style description of the device: the
+// command and status registers, BARs, bridge windows and capabilities.
+// res are the resources of the device as read by Resources, for the sizes
+// of the BARs; they may be nil.
+func (c Config) Decode(res []Resource) ([]string, error) {
+	lines := []string{c.controlString(), c.statusString()}
+	for _, b := range c.BARs(res) {
+		lines = append(lines, b.String())
+	}
+	if c.HeaderType() == HeaderTypeBridge {
+		b, err := c.Bridge()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, strings.Split(b.String(), "\n")...)
+	}
+	if r := c.ROM(res); r != nil {
+		lines = append(lines, r.String())
+	}
+	if c.Status()&statusCapList != 0 && len(c) <= configHeaderSize {
+		return append(lines, "Capabilities: <access denied>"), nil
+	}
+	caps, err := c.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range caps {
+		s, err := c.DecodeCapability(cp)
+		if err != nil {
+			return nil, err
+		}
+		for i, l := range strings.Split(s, "\n") {
+			if i == 0 {
+				l = fmt.Sprintf("Capabilities: %s %s", cp, l)
+			}
+			lines = append(lines, l)
+		}
+	}
+	return lines, nil
+}
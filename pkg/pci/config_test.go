@@ -0,0 +1,269 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The devices in testdata/sys are a firecracker VM's host bridge and
+// virtio devices, as read from sysfs, and PCI Express root ports, a NIC
+// and a GPU made up for the capabilities the VM does not have.
+func testDevices(t *testing.T, globs ...string) Devices {
+	t.Helper()
+	var devs []string
+	for _, g := range globs {
+		m, err := filepath.Glob(filepath.Join("testdata/sys", g))
+		if err != nil {
+			t.Fatal(err)
+		}
+		devs = append(devs, m...)
+	}
+	d, err := (&bus{Devices: devs}).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func testConfig(t *testing.T, addr string) Config {
+	t.Helper()
+	c, err := testDevices(t, addr)[0].ConfigSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDecode(t *testing.T) {
+	d := testDevices(t, "*")
+	if err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/decode.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.String(); got != string(want) {
+		t.Errorf("decoded devices:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDecodeHeaderOnly(t *testing.T) {
+	// Without root, sysfs only has the header.
+	c := testConfig(t, "0000:00:04.0")[:configHeaderSize]
+	lines, err := c.Decode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lines[len(lines)-1], "Capabilities: <access denied>"; got != want {
+		t.Errorf("last line %q, want %q", got, want)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	c := testConfig(t, "0000:01:00.0")
+	caps, err := c.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Capability{
+		{ID: CapPM, Offset: 0x40},
+		{ID: CapMSI, Offset: 0x50},
+		{ID: CapMSIX, Offset: 0x70},
+		{ID: CapExpress, Offset: 0xa0},
+		{ID: ExtCapAER, Extended: true, Version: 2, Offset: 0x100},
+		{ID: ExtCapDSN, Extended: true, Version: 1, Offset: 0x140},
+		{ID: ExtCapARI, Extended: true, Version: 1, Offset: 0x150},
+		{ID: ExtCapSRIOV, Extended: true, Version: 1, Offset: 0x160},
+		{ID: ExtCapTPH, Extended: true, Version: 1, Offset: 0x1a0},
+	}
+	if !reflect.DeepEqual(caps, want) {
+		t.Errorf("capabilities %v, want %v", caps, want)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(c Config) Config
+	}{
+		{"loop", func(c Config) Config {
+			c[0x71] = 0x50
+			return c
+		}},
+		{"pointer into header", func(c Config) Config {
+			c[0x71] = 0x20
+			return c
+		}},
+		{"pointer past the end", func(c Config) Config {
+			return c[:0x90]
+		}},
+		{"extended loop", func(c Config) Config {
+			// Point the last extended capability back at the first.
+			c[0x1a3] = 0x10
+			return c
+		}},
+	} {
+		if _, err := tt.modify(append(Config{}, c...)).Capabilities(); err == nil {
+			t.Errorf("%s: Capabilities succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestExpress(t *testing.T) {
+	for _, tt := range []struct {
+		addr       string
+		port       PortType
+		speed      LinkSpeed
+		width      int
+		downgraded bool
+	}{
+		{"0000:00:1c.0", PortRoot, 1, 1, true},
+		{"0000:01:00.0", PortEndpoint, 1, 1, true},
+		{"0000:02:00.0", PortEndpoint, 3, 16, false},
+	} {
+		c := testConfig(t, tt.addr)
+		cp, err := c.FindCapability(CapExpress, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := NewExpress(c, cp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.PortType() != tt.port || e.LinkSpeed() != tt.speed || e.LinkWidth() != tt.width || e.Downgraded() != tt.downgraded {
+			t.Errorf("%s: %v link %v x%d downgraded %v, want %v link %v x%d downgraded %v", tt.addr,
+				e.PortType(), e.LinkSpeed(), e.LinkWidth(), e.Downgraded(), tt.port, tt.speed, tt.width, tt.downgraded)
+		}
+	}
+
+	if _, err := NewExpress(testConfig(t, "0000:02:00.0"), Capability{ID: CapPM, Offset: 0x60}); err == nil {
+		t.Errorf("NewExpress of the PM capability succeeded, want error")
+	}
+	if _, err := testConfig(t, "0000:00:02.0").FindCapability(CapExpress, false); err == nil {
+		t.Errorf("FindCapability found a PCI Express capability on a PCI device")
+	}
+}
+
+func TestAER(t *testing.T) {
+	c := testConfig(t, "0000:01:00.0")
+	cp, err := c.FindCapability(ExtCapAER, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAER(c, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := a.UncorrectableErrors(), []string{"CmpltTO"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uncorrectable errors %v, want %v", got, want)
+	}
+	if got, want := a.CorrectableErrors(), []string{"BadTLP", "BadDLLP"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("correctable errors %v, want %v", got, want)
+	}
+}
+
+func TestBARs(t *testing.T) {
+	d := testDevices(t, "0000:02:00.0")[0]
+	c, err := d.ConfigSpace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := d.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BAR{
+		{Index: 0, Addr: 0xf6000000, Size: 16 << 20},
+		{Index: 1, Addr: 0x400000000, Size: 256 << 20, Is64: true, Prefetchable: true},
+		{Index: 3, Addr: 0x410000000, Size: 32 << 20, Is64: true, Prefetchable: true},
+	}
+	if got := c.BARs(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("BARs %+v, want %+v", got, want)
+	}
+	if r := c.ROM(res); r != nil {
+		t.Errorf("ROM %v, want none", r)
+	}
+
+	cp, err := c.FindCapability(ExtCapReBAR, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewResizableBAR(c, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.BARs) != 1 || r.BARs[0].Index() != 1 || r.BARs[0].Size() != 256<<20 {
+		t.Fatalf("resizable BARs %+v, want BAR 1 of 256M", r.BARs)
+	}
+	if s := r.BARs[0].SupportedSizes(); len(s) != 8 || s[0] != 64<<20 || s[7] != 8<<30 {
+		t.Errorf("supported sizes %v, want 64M to 8G", s)
+	}
+}
+
+func TestBridge(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want Bridge
+	}{
+		{"0000:00:01.0", Bridge{
+			Secondary: 2, Subordinate: 2,
+			IO:           Window{Base: 0xd000, Limit: 0xdfff},
+			Memory:       Window{Base: 0xf6000000, Limit: 0xf70fffff},
+			Prefetchable: Window{Base: 0x400000000, Limit: 0x41fffffff, Is64: true},
+		}},
+		{"0000:00:1d.0", Bridge{
+			Secondary: 3, Subordinate: 3,
+			IO:           Window{Base: 0xf000, Limit: 0xfff},
+			Memory:       Window{Base: 0xfff00000, Limit: 0xfffff},
+			Prefetchable: Window{Base: 0xfff00000, Limit: 0xfffff, Is64: true},
+		}},
+	} {
+		b, err := testConfig(t, tt.addr).Bridge()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *b != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.addr, *b, tt.want)
+		}
+	}
+	if _, err := testConfig(t, "0000:01:00.0").Bridge(); err == nil {
+		t.Errorf("Bridge of an endpoint succeeded, want error")
+	}
+}
+
+func TestTree(t *testing.T) {
+	d := testDevices(t, "*")
+	got, err := d.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `-[0000:00]-+-00.0
+           +-01.0-[02]----00.0
+           +-02.0
+           +-04.0
+           +-1c.0-[01]--+-00.0
+           |            \-00.1
+           \-1d.0-[03]--
+`
+	if got != want {
+		t.Errorf("tree:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A bus without its bridge hangs off the host bridge.
+	d = testDevices(t, "0000:00:02.0", "0000:01:*")
+	if got, err = d.Tree(); err != nil {
+		t.Fatal(err)
+	}
+	want = `-+-[0000:00]---02.0
+ \-[0000:01]-+-00.0
+             \-00.1
+`
+	if got != want {
+		t.Errorf("tree:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sleep is a variable so the tests do not wait for resets.
+var sleep = time.Sleep
+
+// writeAttr writes s to a sysfs attribute. The kernel does the work in the
+// write, so its error says why it failed.
+func writeAttr(file, s string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(s)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readAttr(file string) (string, error) {
+	b, err := ioutil.ReadFile(file)
+	return strings.TrimSpace(string(b)), err
+}
+
+func readIntAttr(file string) (int, error) {
+	s, err := readAttr(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(s)
+}
+
+// Rescan makes the kernel enumerate all the buses again, e.g. to find
+// devices that were hot-plugged or removed with Remove.
+func Rescan() error {
+	return writeAttr(filepath.Join(busPath, "rescan"), "1")
+}
+
+// Rescan makes the kernel enumerate the buses below a bridge again.
+func (p *PCI) Rescan() error {
+	return writeAttr(filepath.Join(p.FullPath, "rescan"), "1")
+}
+
+// Remove removes the device from the kernel, unbinding its driver. It
+// comes back on the next Rescan.
+func (p *PCI) Remove() error {
+	return writeAttr(filepath.Join(p.FullPath, "remove"), "1")
+}
+
+// Reset resets the function with the first reset method the kernel finds
+// that works: function level, power management or secondary bus reset.
+// The kernel saves and restores the config space around it.
+func (p *PCI) Reset() error {
+	if err := writeAttr(filepath.Join(p.FullPath, "reset"), "1"); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s can not be reset", p.Addr)
+		}
+		return err
+	}
+	return nil
+}
+
+// ResetWith is Reset with methods instead of the kernel's choice, e.g.
+// "flr" for function level or "bus" for secondary bus reset. It needs
+// Linux 5.15 or later.
+func (p *PCI) ResetWith(methods ...string) error {
+	file := filepath.Join(p.FullPath, "reset_method")
+	old, err := readAttr(file)
+	if err != nil {
+		return err
+	}
+	if err := writeAttr(file, strings.Join(methods, " ")); err != nil {
+		return fmt.Errorf("%s: reset methods %q: %v", p.Addr, methods, err)
+	}
+	err = p.Reset()
+	if rerr := writeAttr(file, old); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Bridge control register and its secondary bus reset bit.
+const (
+	regBridgeControl   = 0x3e
+	bridgeControlReset = 1 << 6
+)
+
+// SecondaryBusReset resets all the devices below a bridge by toggling the
+// reset bit of its bridge control register. Unlike ResetWith("bus") on a
+// device below, their state is lost, so it is for when they are wedged or
+// gone; Remove them first and Rescan after.
+func (p *PCI) SecondaryBusReset() error {
+	c, err := p.ConfigSpace()
+	if err != nil {
+		return err
+	}
+	if c.HeaderType() != HeaderTypeBridge {
+		return fmt.Errorf("%s is not a bridge", p.Addr)
+	}
+	ctl, err := p.ReadConfigRegister(regBridgeControl, 16)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteConfigRegister(regBridgeControl, 16, ctl|bridgeControlReset); err != nil {
+		return err
+	}
+	// Linux holds the reset for 2ms, and gives the devices a second to
+	// come back after it.
+	sleep(2 * time.Millisecond)
+	if err := p.WriteConfigRegister(regBridgeControl, 16, ctl&^bridgeControlReset); err != nil {
+		return err
+	}
+	sleep(time.Second)
+	return nil
+}
+
+// Driver returns the name of the driver bound to the device, "" if there
+// is none.
+func (p *PCI) Driver() (string, error) {
+	l, err := os.Readlink(filepath.Join(p.FullPath, "driver"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(l), nil
+}
+
+// Unbind unbinds the driver of the device, if it has one.
+func (p *PCI) Unbind() error {
+	d, err := p.Driver()
+	if err != nil || d == "" {
+		return err
+	}
+	return writeAttr(filepath.Join(p.FullPath, "driver", "unbind"), p.Addr)
+}
+
+// Bind binds driver to the device, which must not have a driver.
+func (p *PCI) Bind(driver string) error {
+	if err := writeAttr(filepath.Join(busPath, "drivers", driver, "bind"), p.Addr); err != nil {
+		return fmt.Errorf("binding %s to %s: %v", driver, p.Addr, err)
+	}
+	return nil
+}
+
+// SetDriverOverride makes driver the only one that binds to the device,
+// even if it does not know the device ID; "" lets all drivers bind again.
+// It does not change the driver the device has.
+func (p *PCI) SetDriverOverride(driver string) error {
+	if driver == "" {
+		driver = "\n"
+	}
+	return writeAttr(filepath.Join(p.FullPath, "driver_override"), driver)
+}
+
+// Probe makes the kernel bind a driver to the device.
+func (p *PCI) Probe() error {
+	return writeAttr(filepath.Join(busPath, "drivers_probe"), p.Addr)
+}
+
+// Rebind moves the device to driver, e.g. vfio-pci: it overrides the
+// driver, unbinds the one it has and probes.
+func (p *PCI) Rebind(driver string) error {
+	if err := p.SetDriverOverride(driver); err != nil {
+		return err
+	}
+	if err := p.Unbind(); err != nil {
+		return err
+	}
+	return p.Probe()
+}
+
+// TotalVFs returns the number of SR-IOV virtual functions the device
+// supports.
+func (p *PCI) TotalVFs() (int, error) {
+	n, err := readIntAttr(filepath.Join(p.FullPath, "sriov_totalvfs"))
+	if os.IsNotExist(err) {
+		return 0, fmt.Errorf("%s does not support SR-IOV", p.Addr)
+	}
+	return n, err
+}
+
+// NumVFs returns the number of SR-IOV virtual functions that are enabled.
+func (p *PCI) NumVFs() (int, error) {
+	return readIntAttr(filepath.Join(p.FullPath, "sriov_numvfs"))
+}
+
+// SetNumVFs enables n SR-IOV virtual functions, or disables them for 0.
+// The driver of the device must support SR-IOV.
+func (p *PCI) SetNumVFs(n int) error {
+	total, err := p.TotalVFs()
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > total {
+		return fmt.Errorf("%s: %d virtual functions, want 0 to %d", p.Addr, n, total)
+	}
+	cur, err := p.NumVFs()
+	if err != nil || cur == n {
+		return err
+	}
+	file := filepath.Join(p.FullPath, "sriov_numvfs")
+	// The kernel only changes the number from or to 0.
+	if cur != 0 && n != 0 {
+		if err := writeAttr(file, "0"); err != nil {
+			return err
+		}
+	}
+	return writeAttr(file, strconv.Itoa(n))
+}
+
+// VirtualFunctions returns the addresses of the enabled SR-IOV virtual
+// functions.
+func (p *PCI) VirtualFunctions() ([]string, error) {
+	links, err := filepath.Glob(filepath.Join(p.FullPath, "virtfn*"))
+	if err != nil {
+		return nil, err
+	}
+	vfs := make([]string, len(links))
+	for _, l := range links {
+		i, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(l), "virtfn"))
+		if err != nil || i < 0 || i >= len(links) {
+			return nil, fmt.Errorf("%s: unexpected virtual function %s", p.Addr, l)
+		}
+		t, err := os.Readlink(l)
+		if err != nil {
+			return nil, err
+		}
+		vfs[i] = filepath.Base(t)
+	}
+	return vfs, nil
+}
+
+// NewDevice returns the device with address addr, e.g. 0000:01:00.0. The
+// domain may be left out.
+func NewDevice(addr string) (*PCI, error) {
+	if strings.Count(addr, ":") == 1 {
+		addr = "0000:" + addr
+	}
+	d, err := (&bus{Devices: []string{filepath.Join(pciPath, addr)}}).Read()
+	if err != nil {
+		return nil, fmt.Errorf("no device %s: %v", addr, err)
+	}
+	return d[0], nil
+}
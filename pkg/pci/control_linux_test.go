@@ -0,0 +1,241 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeSysfs makes a sysfs PCI bus with the root port at 0000:00:1c.0 and
+// the NIC below it from testdata/sys, with igb bound to the NIC and two of
+// its virtual functions enabled. It returns the bus directory and a
+// function that removes it.
+func fakeSysfs(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "pci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldBus, oldPCI := busPath, pciPath
+	busPath, pciPath = dir, filepath.Join(dir, "devices")
+	cleanup := func() {
+		busPath, pciPath = oldBus, oldPCI
+		os.RemoveAll(dir)
+	}
+
+	files := map[string]string{
+		"rescan":                               "",
+		"drivers_probe":                        "",
+		"drivers/igb/bind":                     "",
+		"drivers/igb/unbind":                   "",
+		"drivers/vfio-pci/bind":                "",
+		"drivers/vfio-pci/unbind":              "",
+		"devices/0000:00:1c.0/rescan":          "",
+		"devices/0000:01:00.0/remove":          "",
+		"devices/0000:01:00.0/reset":           "",
+		"devices/0000:01:00.0/reset_method":    "flr bus\n",
+		"devices/0000:01:00.0/driver_override": "(null)\n",
+		"devices/0000:01:00.0/sriov_totalvfs":  "8\n",
+		"devices/0000:01:00.0/sriov_numvfs":    "2\n",
+	}
+	for _, d := range []string{"0000:00:1c.0", "0000:01:00.0"} {
+		for _, f := range []string{"vendor", "device", "config"} {
+			b, err := ioutil.ReadFile(filepath.Join("testdata/sys", d, f))
+			if err != nil {
+				cleanup()
+				t.Fatal(err)
+			}
+			files[filepath.Join("devices", d, f)] = string(b)
+		}
+	}
+	links := map[string]string{
+		"devices/0000:01:00.0/driver":  "../../drivers/igb",
+		"devices/0000:01:00.0/virtfn1": "../0000:01:10.4",
+		"devices/0000:01:00.0/virtfn0": "../0000:01:10.0",
+	}
+	for f, s := range files {
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(s), 0644); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	for l, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, l)); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	return dir, cleanup
+}
+
+func checkAttr(t *testing.T, dir, file, want string) {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("%s is %q, want %q", file, b, want)
+	}
+}
+
+func TestRescanRemove(t *testing.T) {
+	dir, cleanup := fakeSysfs(t)
+	defer cleanup()
+
+	if err := Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	checkAttr(t, dir, "rescan", "1")
+	rp, err := NewDevice("00:1c.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rp.Rescan(); err != nil {
+		t.Fatal(err)
+	}
+	checkAttr(t, dir, "devices/0000:00:1c.0/rescan", "1")
+	nic, err := NewDevice("0000:01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nic.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	checkAttr(t, dir, "devices/0000:01:00.0/remove", "1")
+
+	if _, err := NewDevice("02:00.0"); err == nil {
+		t.Errorf("NewDevice(02:00.0) succeeded, want error")
+	}
+}
+
+func TestReset(t *testing.T) {
+	dir, cleanup := fakeSysfs(t)
+	defer cleanup()
+
+	nic, err := NewDevice("01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nic.ResetWith("bus"); err != nil {
+		t.Fatal(err)
+	}
+	checkAttr(t, dir, "devices/0000:01:00.0/reset", "1")
+	checkAttr(t, dir, "devices/0000:01:00.0/reset_method", "flr bus")
+	rp, err := NewDevice("00:1c.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rp.Reset(); err == nil {
+		t.Errorf("Reset of a port without a reset file succeeded, want error")
+	}
+	if err := nic.SecondaryBusReset(); err == nil {
+		t.Errorf("SecondaryBusReset of an endpoint succeeded, want error")
+	}
+
+	// The reset bit must be set while the bus is held in reset and clear
+	// after.
+	defer func() { sleep = time.Sleep }()
+	var ctls []uint64
+	sleep = func(time.Duration) {
+		v, err := rp.ReadConfigRegister(regBridgeControl, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctls = append(ctls, v)
+	}
+	if err := rp.SecondaryBusReset(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{bridgeControlReset, 0}; !reflect.DeepEqual(ctls, want) {
+		t.Errorf("bridge control during and after reset %#x, want %#x", ctls, want)
+	}
+}
+
+func TestDriver(t *testing.T) {
+	dir, cleanup := fakeSysfs(t)
+	defer cleanup()
+
+	nic, err := NewDevice("01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := nic.Driver(); err != nil || d != "igb" {
+		t.Errorf("Driver() = %q, %v, want igb", d, err)
+	}
+	if err := nic.Rebind("vfio-pci"); err != nil {
+		t.Fatal(err)
+	}
+	checkAttr(t, dir, "devices/0000:01:00.0/driver_override", "vfio-pci")
+	checkAttr(t, dir, "drivers/igb/unbind", "0000:01:00.0")
+	checkAttr(t, dir, "drivers_probe", "0000:01:00.0")
+	if err := nic.SetDriverOverride(""); err != nil {
+		t.Fatal(err)
+	}
+	checkAttr(t, dir, "devices/0000:01:00.0/driver_override", "\n")
+	if err := nic.Bind("vfio-pci"); err != nil {
+		t.Fatal(err)
+	}
+	checkAttr(t, dir, "drivers/vfio-pci/bind", "0000:01:00.0")
+	if err := nic.Bind("nouveau"); err == nil {
+		t.Errorf("Bind to a driver that does not exist succeeded, want error")
+	}
+
+	rp, err := NewDevice("00:1c.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := rp.Driver(); err != nil || d != "" {
+		t.Errorf("Driver() = %q, %v, want none", d, err)
+	}
+	if err := rp.Unbind(); err != nil {
+		t.Errorf("Unbind without a driver: %v", err)
+	}
+}
+
+func TestSRIOV(t *testing.T) {
+	dir, cleanup := fakeSysfs(t)
+	defer cleanup()
+
+	nic, err := NewDevice("01:00.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := nic.TotalVFs(); err != nil || n != 8 {
+		t.Errorf("TotalVFs() = %d, %v, want 8", n, err)
+	}
+	vfs, err := nic.VirtualFunctions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"0000:01:10.0", "0000:01:10.4"}; !reflect.DeepEqual(vfs, want) {
+		t.Errorf("VirtualFunctions() = %v, want %v", vfs, want)
+	}
+	if err := nic.SetNumVFs(4); err != nil {
+		t.Fatal(err)
+	}
+	checkAttr(t, dir, "devices/0000:01:00.0/sriov_numvfs", "4")
+	if err := nic.SetNumVFs(9); err == nil {
+		t.Errorf("SetNumVFs(9) of 8 succeeded, want error")
+	}
+
+	rp, err := NewDevice("00:1c.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rp.SetNumVFs(1); err == nil {
+		t.Errorf("SetNumVFs on a port without SR-IOV succeeded, want error")
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"bytes"
 )
 
-//Devices contains a slice of one or more PCI devices
+// Devices contains a slice of one or more PCI devices
 type Devices []*PCI
 
 // String stringifies the PCI devices. Currently it just calls the device String().
@@ -39,6 +39,17 @@ func (d Devices) ReadConfig() error {
 	return nil
 }
 
+// Decode adds the lspci -vvv style description of all the devices to
+// their ExtraInfo.
+func (d Devices) Decode() error {
+	for _, p := range d {
+		if err := p.Decode(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ReadConfigRegister reads the config info for all the devices.
 func (d Devices) ReadConfigRegister(offset, size int64) ([]uint64, error) {
 	var vals []uint64
@@ -0,0 +1,333 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PortType is the device or port type of a PCI Express function.
+type PortType uint8
+
+// Port types.
+const (
+	PortEndpoint       PortType = 0x0
+	PortLegacyEndpoint PortType = 0x1
+	PortRoot           PortType = 0x4
+	PortUpstream       PortType = 0x5
+	PortDownstream     PortType = 0x6
+	PortPCIeToPCI      PortType = 0x7
+	PortPCIToPCIe      PortType = 0x8
+	PortRCEndpoint     PortType = 0x9
+	PortRCEC           PortType = 0xa
+)
+
+func (t PortType) String() string {
+	names := map[PortType]string{
+		PortEndpoint:       "Endpoint",
+		PortLegacyEndpoint: "Legacy Endpoint",
+		PortRoot:           "Root Port",
+		PortUpstream:       "Upstream Port",
+		PortDownstream:     "Downstream Port",
+		PortPCIeToPCI:      "PCI-Express to PCI/PCI-X Bridge",
+		PortPCIToPCIe:      "PCI/PCI-X to PCI-Express Bridge",
+		PortRCEndpoint:     "Root Complex Integrated Endpoint",
+		PortRCEC:           "Root Complex Event Collector",
+	}
+	if n, ok := names[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("Unknown type %d", t)
+}
+
+// LinkSpeed is the speed of a PCI Express link.
+type LinkSpeed uint8
+
+func (s LinkSpeed) String() string {
+	names := []string{"", "2.5GT/s", "5GT/s", "8GT/s", "16GT/s", "32GT/s", "64GT/s"}
+	if s == 0 || int(s) >= len(names) {
+		return "unknown"
+	}
+	return names[s]
+}
+
+// Express is the PCI Express capability. The link registers are 0 for
+// functions without a link, and the slot registers for ports without a
+// slot.
+type Express struct {
+	Capabilities uint16
+
+	DevCap uint32
+	DevCtl uint16
+	DevSta uint16
+
+	LnkCap uint32
+	LnkCtl uint16
+	LnkSta uint16
+
+	SltCap uint32
+	SltCtl uint16
+	SltSta uint16
+
+	// The version 2 link registers.
+	LnkCap2 uint32
+	LnkCtl2 uint16
+	LnkSta2 uint16
+}
+
+// Sizes of the version 1 and 2 capability.
+const (
+	expressSize  = 0x24
+	express2Size = 0x3c
+)
+
+// NewExpress decodes the PCI Express capability cp of c.
+func NewExpress(c Config, cp Capability) (*Express, error) {
+	if err := c.check(cp, CapExpress, false, expressSize); err != nil {
+		return nil, err
+	}
+	off := cp.Offset
+	e := &Express{
+		Capabilities: c.u16(off + 0x02),
+		DevCap:       c.u32(off + 0x04),
+		DevCtl:       c.u16(off + 0x08),
+		DevSta:       c.u16(off + 0x0a),
+	}
+	if e.HasLink() {
+		e.LnkCap, e.LnkCtl, e.LnkSta = c.u32(off+0x0c), c.u16(off+0x10), c.u16(off+0x12)
+	}
+	if e.SlotImplemented() {
+		e.SltCap, e.SltCtl, e.SltSta = c.u32(off+0x14), c.u16(off+0x18), c.u16(off+0x1a)
+	}
+	if e.Version() >= 2 && e.HasLink() && off+express2Size <= len(c) {
+		e.LnkCap2, e.LnkCtl2, e.LnkSta2 = c.u32(off+0x2c), c.u16(off+0x30), c.u16(off+0x32)
+	}
+	return e, nil
+}
+
+// Version returns the version of the capability.
+func (e *Express) Version() int {
+	return int(e.Capabilities & 0xf)
+}
+
+// PortType returns the device or port type.
+func (e *Express) PortType() PortType {
+	return PortType(field(uint32(e.Capabilities), 4, 4))
+}
+
+// SlotImplemented reports whether the port is connected to a slot.
+func (e *Express) SlotImplemented() bool {
+	t := e.PortType()
+	return (t == PortRoot || t == PortDownstream) && e.Capabilities&(1<<8) != 0
+}
+
+// HasLink reports whether the function has a link; root complex
+// integrated ones do not.
+func (e *Express) HasLink() bool {
+	t := e.PortType()
+	return t != PortRCEndpoint && t != PortRCEC
+}
+
+// MaxLinkSpeed returns the maximum speed of the link.
+func (e *Express) MaxLinkSpeed() LinkSpeed {
+	return LinkSpeed(e.LnkCap & 0xf)
+}
+
+// MaxLinkWidth returns the maximum number of lanes of the link.
+func (e *Express) MaxLinkWidth() int {
+	return int(field(e.LnkCap, 4, 6))
+}
+
+// LinkSpeed returns the negotiated speed of the link.
+func (e *Express) LinkSpeed() LinkSpeed {
+	return LinkSpeed(e.LnkSta & 0xf)
+}
+
+// LinkWidth returns the negotiated number of lanes of the link, 0 if it
+// is down.
+func (e *Express) LinkWidth() int {
+	return int(field(uint32(e.LnkSta), 4, 6))
+}
+
+// Downgraded reports whether the link trained to a lower speed or width
+// than it is capable of, e.g. because of a bad slot or riser. The far end
+// may be the limit: a root port to an x4 device is downgraded too.
+func (e *Express) Downgraded() bool {
+	return e.HasLink() && (e.LinkSpeed() < e.MaxLinkSpeed() || e.LinkWidth() < e.MaxLinkWidth())
+}
+
+// linkCompare annotates a link status value like lspci. Ports above the
+// link are not compared, as the device below them may be the limit.
+func (e *Express) linkCompare(sta, max int) string {
+	switch t := e.PortType(); {
+	case sta > max:
+		return " (overdriven)"
+	case sta == max, t == PortRoot, t == PortDownstream, t == PortPCIeToPCI:
+		return ""
+	}
+	return " (downgraded)"
+}
+
+var (
+	latencyL0s = []string{"<64ns", "<128ns", "<256ns", "<512ns", "<1us", "<2us", "<4us", "unlimited"}
+	latencyL1  = []string{"<1us", "<2us", "<4us", "<8us", "<16us", "<32us", "<64us", "unlimited"}
+)
+
+// powerLimit returns a slot power limit in watts.
+func powerLimit(value, scale uint32) float64 {
+	return float64(value) * []float64{1, 0.1, 0.01, 0.001}[scale]
+}
+
+func (e *Express) String() string {
+	t := e.PortType()
+	name := t.String()
+	if t == PortRoot || t == PortDownstream {
+		name = fmt.Sprintf("%s (Slot%c)", name, fl(uint32(e.Capabilities), 8))
+	}
+	lines := []string{fmt.Sprintf("Express (v%d) %s, MSI %02x", e.Version(), name, field(uint32(e.Capabilities), 9, 5))}
+	lines = append(lines, e.devLines()...)
+	if e.HasLink() {
+		lines = append(lines, e.linkLines()...)
+	}
+	if e.SlotImplemented() {
+		lines = append(lines, e.slotLines()...)
+	}
+	if e.Version() >= 2 && e.HasLink() {
+		lines = append(lines, e.link2Lines()...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *Express) devLines() []string {
+	t := e.PortType()
+	endpoint := t == PortEndpoint || t == PortLegacyEndpoint
+	flr := endpoint || t == PortRCEndpoint
+
+	c := e.DevCap
+	cap1 := fmt.Sprintf("\tDevCap:\tMaxPayload %d bytes, PhantFunc %d", 128<<(c&7), 1<<field(c, 3, 2)-1)
+	if endpoint {
+		cap1 += fmt.Sprintf(", Latency L0s %s, L1 %s", latencyL0s[field(c, 6, 3)], latencyL1[field(c, 9, 3)])
+	}
+	cap2 := fmt.Sprintf("\t\tExtTag%c", fl(c, 5))
+	if endpoint || t == PortUpstream || t == PortPCIeToPCI {
+		cap2 += fmt.Sprintf(" AttnBtn%c AttnInd%c PwrInd%c", fl(c, 12), fl(c, 13), fl(c, 14))
+	}
+	cap2 += fmt.Sprintf(" RBE%c", fl(c, 15))
+	if flr {
+		cap2 += fmt.Sprintf(" FLReset%c", fl(c, 28))
+	}
+	if t == PortEndpoint || t == PortUpstream || t == PortPCIeToPCI {
+		cap2 += fmt.Sprintf(" SlotPowerLimit %.3fW", powerLimit(field(c, 18, 8), field(c, 26, 2)))
+	}
+
+	w := uint32(e.DevCtl)
+	ctl2 := fmt.Sprintf("\t\tRlxdOrd%c ExtTag%c PhantFunc%c AuxPwr%c NoSnoop%c", fl(w, 4), fl(w, 8), fl(w, 9), fl(w, 10), fl(w, 11))
+	if t == PortPCIeToPCI {
+		ctl2 += fmt.Sprintf(" BrConfRtry%c", fl(w, 15))
+	}
+	if flr && c&(1<<28) != 0 {
+		ctl2 += fmt.Sprintf(" FLReset%c", fl(w, 15))
+	}
+
+	s := uint32(e.DevSta)
+	return []string{
+		cap1,
+		cap2,
+		fmt.Sprintf("\tDevCtl:\tCorrErr%c NonFatalErr%c FatalErr%c UnsupReq%c", fl(w, 0), fl(w, 1), fl(w, 2), fl(w, 3)),
+		ctl2,
+		fmt.Sprintf("\t\tMaxPayload %d bytes, MaxReadReq %d bytes", 128<<field(w, 5, 3), 128<<field(w, 12, 3)),
+		fmt.Sprintf("\tDevSta:\tCorrErr%c NonFatalErr%c FatalErr%c UnsupReq%c AuxPwr%c TransPend%c", fl(s, 0), fl(s, 1), fl(s, 2), fl(s, 3), fl(s, 4), fl(s, 5)),
+	}
+}
+
+func (e *Express) linkLines() []string {
+	t := e.PortType()
+	c := e.LnkCap
+	aspm := field(c, 10, 2)
+	cap1 := fmt.Sprintf("\tLnkCap:\tPort #%d, Speed %s, Width x%d, ASPM %s", c>>24, e.MaxLinkSpeed(), e.MaxLinkWidth(),
+		[]string{"not supported", "L0s", "L1", "L0s L1"}[aspm])
+	if aspm != 0 {
+		cap1 += ", Exit Latency "
+		if aspm&1 != 0 {
+			cap1 += "L0s " + latencyL0s[field(c, 12, 3)]
+		}
+		if aspm&2 != 0 {
+			if aspm&1 != 0 {
+				cap1 += ", "
+			}
+			cap1 += "L1 " + latencyL1[field(c, 15, 3)]
+		}
+	}
+
+	w := uint32(e.LnkCtl)
+	ctl1 := fmt.Sprintf("\tLnkCtl:\tASPM %s;", []string{"Disabled", "L0s Enabled", "L1 Enabled", "L0s L1 Enabled"}[w&3])
+	if t == PortRoot || t == PortEndpoint || t == PortLegacyEndpoint || t == PortPCIeToPCI {
+		rcb := 64
+		if w&(1<<3) != 0 {
+			rcb = 128
+		}
+		ctl1 += fmt.Sprintf(" RCB %d bytes,", rcb)
+	}
+	ctl1 += fmt.Sprintf(" Disabled%c CommClk%c", fl(w, 4), fl(w, 6))
+
+	s := uint32(e.LnkSta)
+	return []string{
+		cap1,
+		fmt.Sprintf("\t\tClockPM%c Surprise%c LLActRep%c BwNot%c ASPMOptComp%c", fl(c, 18), fl(c, 19), fl(c, 20), fl(c, 21), fl(c, 22)),
+		ctl1,
+		fmt.Sprintf("\t\tExtSynch%c ClockPM%c AutWidDis%c BWInt%c AutBWInt%c", fl(w, 7), fl(w, 8), fl(w, 9), fl(w, 10), fl(w, 11)),
+		fmt.Sprintf("\tLnkSta:\tSpeed %s%s, Width x%d%s", e.LinkSpeed(), e.linkCompare(int(e.LinkSpeed()), int(e.MaxLinkSpeed())),
+			e.LinkWidth(), e.linkCompare(e.LinkWidth(), e.MaxLinkWidth())),
+		fmt.Sprintf("\t\tTrErr%c Train%c SlotClk%c DLActive%c BWMgmt%c ABWMgmt%c", fl(s, 10), fl(s, 11), fl(s, 12), fl(s, 13), fl(s, 14), fl(s, 15)),
+	}
+}
+
+func (e *Express) slotLines() []string {
+	c, w, s := e.SltCap, uint32(e.SltCtl), uint32(e.SltSta)
+	indicator := []string{"Unknown", "On", "Blink", "Off"}
+	return []string{
+		fmt.Sprintf("\tSltCap:\tAttnBtn%c PwrCtrl%c MRL%c AttnInd%c PwrInd%c HotPlug%c Surprise%c", fl(c, 0), fl(c, 1), fl(c, 2), fl(c, 3), fl(c, 4), fl(c, 6), fl(c, 5)),
+		fmt.Sprintf("\t\tSlot #%d, PowerLimit %.3fW; Interlock%c NoCompl%c", c>>19, powerLimit(field(c, 7, 8), field(c, 15, 2)), fl(c, 17), fl(c, 18)),
+		fmt.Sprintf("\tSltCtl:\tEnable: AttnBtn%c PwrFlt%c MRL%c PresDet%c CmdCplt%c HPIrq%c LinkChg%c", fl(w, 0), fl(w, 1), fl(w, 2), fl(w, 3), fl(w, 4), fl(w, 5), fl(w, 12)),
+		fmt.Sprintf("\t\tControl: AttnInd %s, PwrInd %s, Power%c Interlock%c", indicator[field(w, 6, 2)], indicator[field(w, 8, 2)], fl(w, 10), fl(w, 11)),
+		fmt.Sprintf("\tSltSta:\tStatus: AttnBtn%c PowerFlt%c MRL%c CmdCplt%c PresDet%c Interlock%c", fl(s, 0), fl(s, 1), fl(s, 5), fl(s, 4), fl(s, 6), fl(s, 7)),
+		fmt.Sprintf("\t\tChanged: MRL%c PresDet%c LinkState%c", fl(s, 2), fl(s, 3), fl(s, 8)),
+	}
+}
+
+// supportedSpeeds formats the supported link speeds vector of LnkCap2.
+// Devices support all the speeds up to their fastest.
+func supportedSpeeds(v uint32) string {
+	switch {
+	case v&0x60 != 0:
+		return "RsvdP"
+	case v&0x10 != 0:
+		return "2.5-32GT/s"
+	case v&0x08 != 0:
+		return "2.5-16GT/s"
+	case v&0x04 != 0:
+		return "2.5-8GT/s"
+	case v&0x02 != 0:
+		return "2.5-5GT/s"
+	case v&0x01 != 0:
+		return "2.5GT/s"
+	}
+	return "Unknown"
+}
+
+func (e *Express) link2Lines() []string {
+	c, w, s := e.LnkCap2, uint32(e.LnkCtl2), uint32(e.LnkSta2)
+	deemphasis := "-6dB"
+	if s&1 != 0 {
+		deemphasis = "-3.5dB"
+	}
+	return []string{
+		fmt.Sprintf("\tLnkCap2: Supported Link Speeds: %s, Crosslink%c Retimer%c 2Retimers%c DRS%c", supportedSpeeds(field(c, 1, 7)), fl(c, 8), fl(c, 23), fl(c, 24), fl(c, 31)),
+		fmt.Sprintf("\tLnkCtl2: Target Link Speed: %s, EnterCompliance%c SpeedDis%c", LinkSpeed(w&0xf), fl(w, 4), fl(w, 5)),
+		fmt.Sprintf("\tLnkSta2: Current De-emphasis Level: %s, EqualizationComplete%c EqualizationPhase1%c", deemphasis, fl(s, 1), fl(s, 2)),
+		fmt.Sprintf("\t\t EqualizationPhase2%c EqualizationPhase3%c LinkEqualizationRequest%c", fl(s, 3), fl(s, 4), fl(s, 5)),
+	}
+}
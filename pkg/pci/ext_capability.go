@@ -0,0 +1,311 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// aerBit is an error of the advanced error reporting registers.
+type aerBit struct {
+	bit  uint
+	name string
+}
+
+var (
+	aerUncorrectable = []aerBit{
+		{4, "DLP"}, {5, "SDES"}, {12, "TLP"}, {13, "FCP"}, {14, "CmpltTO"}, {15, "CmpltAbrt"},
+		{16, "UnxCmplt"}, {17, "RxOF"}, {18, "MalfTLP"}, {19, "ECRC"}, {20, "UnsupReq"}, {21, "ACSViol"},
+	}
+	aerCorrectable = []aerBit{
+		{0, "RxErr"}, {6, "BadTLP"}, {7, "BadDLLP"}, {8, "Rollover"}, {12, "Timeout"}, {13, "AdvNonFatalErr"},
+	}
+)
+
+func aerFlags(v uint32, bits []aerBit) string {
+	var s []string
+	for _, b := range bits {
+		s = append(s, fmt.Sprintf("%s%c", b.name, fl(v, b.bit)))
+	}
+	return strings.Join(s, " ")
+}
+
+func aerErrors(v uint32, bits []aerBit) []string {
+	var s []string
+	for _, b := range bits {
+		if v&(1<<b.bit) != 0 {
+			s = append(s, b.name)
+		}
+	}
+	return s
+}
+
+// AER is the advanced error reporting capability.
+type AER struct {
+	UncorrectableStatus   uint32
+	UncorrectableMask     uint32
+	UncorrectableSeverity uint32
+	CorrectableStatus     uint32
+	CorrectableMask       uint32
+	Control               uint32
+	// HeaderLog is the header of the TLP of the first error.
+	HeaderLog [4]uint32
+}
+
+// NewAER decodes the advanced error reporting capability cp of c.
+func NewAER(c Config, cp Capability) (*AER, error) {
+	if err := c.check(cp, ExtCapAER, true, 0x2c); err != nil {
+		return nil, err
+	}
+	off := cp.Offset
+	a := &AER{
+		UncorrectableStatus:   c.u32(off + 0x04),
+		UncorrectableMask:     c.u32(off + 0x08),
+		UncorrectableSeverity: c.u32(off + 0x0c),
+		CorrectableStatus:     c.u32(off + 0x10),
+		CorrectableMask:       c.u32(off + 0x14),
+		Control:               c.u32(off + 0x18),
+	}
+	for i := range a.HeaderLog {
+		a.HeaderLog[i] = c.u32(off + 0x1c + 4*i)
+	}
+	return a, nil
+}
+
+// UncorrectableErrors returns the names of the uncorrectable errors that
+// were logged, as lspci names them.
+func (a *AER) UncorrectableErrors() []string {
+	return aerErrors(a.UncorrectableStatus, aerUncorrectable)
+}
+
+// CorrectableErrors returns the names of the correctable errors that were
+// logged, as lspci names them.
+func (a *AER) CorrectableErrors() []string {
+	return aerErrors(a.CorrectableStatus, aerCorrectable)
+}
+
+func (a *AER) String() string {
+	v := a.Control
+	return strings.Join([]string{
+		"Advanced Error Reporting",
+		"\tUESta:\t" + aerFlags(a.UncorrectableStatus, aerUncorrectable),
+		"\tUEMsk:\t" + aerFlags(a.UncorrectableMask, aerUncorrectable),
+		"\tUESvrt:\t" + aerFlags(a.UncorrectableSeverity, aerUncorrectable),
+		"\tCESta:\t" + aerFlags(a.CorrectableStatus, aerCorrectable),
+		"\tCEMsk:\t" + aerFlags(a.CorrectableMask, aerCorrectable),
+		fmt.Sprintf("\tAERCap:\tFirst Error Pointer: %02x, ECRCGenCap%c ECRCGenEn%c ECRCChkCap%c ECRCChkEn%c", v&0x1f, fl(v, 5), fl(v, 6), fl(v, 7), fl(v, 8)),
+		fmt.Sprintf("\t\tMultHdrRecCap%c MultHdrRecEn%c TLPPfxPres%c HdrLogCap%c", fl(v, 9), fl(v, 10), fl(v, 11), fl(v, 12)),
+		fmt.Sprintf("\tHeaderLog: %08x %08x %08x %08x", a.HeaderLog[0], a.HeaderLog[1], a.HeaderLog[2], a.HeaderLog[3]),
+	}, "\n")
+}
+
+// DSN is the device serial number capability.
+type DSN struct {
+	Serial uint64
+}
+
+// NewDSN decodes the device serial number capability cp of c.
+func NewDSN(c Config, cp Capability) (*DSN, error) {
+	if err := c.check(cp, ExtCapDSN, true, 12); err != nil {
+		return nil, err
+	}
+	return &DSN{Serial: uint64(c.u32(cp.Offset+8))<<32 | uint64(c.u32(cp.Offset+4))}, nil
+}
+
+func (d *DSN) String() string {
+	var b []string
+	for i := 7; i >= 0; i-- {
+		b = append(b, fmt.Sprintf("%02x", uint8(d.Serial>>(8*uint(i)))))
+	}
+	return "Device Serial Number " + strings.Join(b, "-")
+}
+
+// ACS is the access control services capability.
+type ACS struct {
+	Capabilities uint16
+	Control      uint16
+}
+
+// NewACS decodes the access control services capability cp of c.
+func NewACS(c Config, cp Capability) (*ACS, error) {
+	if err := c.check(cp, ExtCapACS, true, 8); err != nil {
+		return nil, err
+	}
+	return &ACS{Capabilities: c.u16(cp.Offset + 4), Control: c.u16(cp.Offset + 6)}, nil
+}
+
+func acsFlags(v uint32) string {
+	return fmt.Sprintf("SrcValid%c TransBlk%c ReqRedir%c CmpltRedir%c UpstreamFwd%c EgressCtrl%c DirectTrans%c",
+		fl(v, 0), fl(v, 1), fl(v, 2), fl(v, 3), fl(v, 4), fl(v, 5), fl(v, 6))
+}
+
+func (a *ACS) String() string {
+	return strings.Join([]string{
+		"Access Control Services",
+		"\tACSCap:\t" + acsFlags(uint32(a.Capabilities)),
+		"\tACSCtl:\t" + acsFlags(uint32(a.Control)),
+	}, "\n")
+}
+
+// SRIOV is the single root I/O virtualization capability.
+type SRIOV struct {
+	Capabilities           uint32
+	Control                uint16
+	Status                 uint16
+	InitialVFs             uint16
+	TotalVFs               uint16
+	NumVFs                 uint16
+	FunctionDependencyLink uint8
+	// VFOffset and VFStride give the routing IDs of the virtual
+	// functions relative to the physical function.
+	VFOffset           uint16
+	VFStride           uint16
+	VFDeviceID         uint16
+	SupportedPageSizes uint32
+	SystemPageSize     uint32
+	// BARs are the BARs of the virtual functions.
+	BARs           [6]uint32
+	MigrationState uint32
+}
+
+// NewSRIOV decodes the SR-IOV capability cp of c.
+func NewSRIOV(c Config, cp Capability) (*SRIOV, error) {
+	if err := c.check(cp, ExtCapSRIOV, true, 0x40); err != nil {
+		return nil, err
+	}
+	off := cp.Offset
+	s := &SRIOV{
+		Capabilities:           c.u32(off + 0x04),
+		Control:                c.u16(off + 0x08),
+		Status:                 c.u16(off + 0x0a),
+		InitialVFs:             c.u16(off + 0x0c),
+		TotalVFs:               c.u16(off + 0x0e),
+		NumVFs:                 c.u16(off + 0x10),
+		FunctionDependencyLink: c.u8(off + 0x12),
+		VFOffset:               c.u16(off + 0x14),
+		VFStride:               c.u16(off + 0x16),
+		VFDeviceID:             c.u16(off + 0x1a),
+		SupportedPageSizes:     c.u32(off + 0x1c),
+		SystemPageSize:         c.u32(off + 0x20),
+		MigrationState:         c.u32(off + 0x3c),
+	}
+	for i := range s.BARs {
+		s.BARs[i] = c.u32(off + 0x24 + 4*i)
+	}
+	return s, nil
+}
+
+// Enabled reports whether the virtual functions are enabled.
+func (s *SRIOV) Enabled() bool {
+	return s.Control&1 != 0
+}
+
+func (s *SRIOV) String() string {
+	c, w := s.Capabilities, uint32(s.Control)
+	lines := []string{
+		"Single Root I/O Virtualization (SR-IOV)",
+		fmt.Sprintf("\tIOVCap:\tMigration%c, Interrupt Message Number: %03x", fl(c, 0), c>>21),
+		fmt.Sprintf("\tIOVCtl:\tEnable%c Migration%c Interrupt%c MSE%c ARIHierarchy%c", fl(w, 0), fl(w, 1), fl(w, 2), fl(w, 3), fl(w, 4)),
+		fmt.Sprintf("\tIOVSta:\tMigration%c", fl(uint32(s.Status), 0)),
+		fmt.Sprintf("\tInitial VFs: %d, Total VFs: %d, Number of VFs: %d, Function Dependency Link: %02x", s.InitialVFs, s.TotalVFs, s.NumVFs, s.FunctionDependencyLink),
+		fmt.Sprintf("\tVF offset: %d, stride: %d, Device ID: %04x", s.VFOffset, s.VFStride, s.VFDeviceID),
+		fmt.Sprintf("\tSupported Page Size: %08x, System Page Size: %08x", s.SupportedPageSizes, s.SystemPageSize),
+	}
+	for i := 0; i < len(s.BARs); i++ {
+		v := s.BARs[i]
+		if v == 0 {
+			continue
+		}
+		pref := "non-"
+		if v&8 != 0 {
+			pref = ""
+		}
+		if (v>>1)&3 == 2 && i+1 < len(s.BARs) {
+			lines = append(lines, fmt.Sprintf("\tRegion %d: Memory at %016x (64-bit, %sprefetchable)", i, uint64(s.BARs[i+1])<<32|uint64(v&^0xf), pref))
+			i++
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("\tRegion %d: Memory at %08x (32-bit, %sprefetchable)", i, v&^0xf, pref))
+	}
+	lines = append(lines, fmt.Sprintf("\tVF Migration: offset: %08x, BIR: %d", s.MigrationState&^7, s.MigrationState&7))
+	return strings.Join(lines, "\n")
+}
+
+// ResizableBAR is the resizable BAR capability.
+type ResizableBAR struct {
+	BARs []ResizableBAREntry
+}
+
+// ResizableBAREntry is a BAR in the resizable BAR capability.
+type ResizableBAREntry struct {
+	// Capabilities has a bit for each supported size, bit 4 for 1 MB up
+	// to bit 23 for 512 GB.
+	Capabilities uint32
+	// Control has the index of the BAR and its current size.
+	Control uint32
+}
+
+// NewResizableBAR decodes the resizable BAR capability cp of c.
+func NewResizableBAR(c Config, cp Capability) (*ResizableBAR, error) {
+	if err := c.check(cp, ExtCapReBAR, true, 12); err != nil {
+		return nil, err
+	}
+	// The first control register has the number of BARs.
+	n := int(field(c.u32(cp.Offset+8), 5, 3))
+	if err := c.check(cp, ExtCapReBAR, true, 4+8*n); err != nil {
+		return nil, err
+	}
+	r := &ResizableBAR{}
+	for i := 0; i < n; i++ {
+		off := cp.Offset + 4 + 8*i
+		r.BARs = append(r.BARs, ResizableBAREntry{Capabilities: c.u32(off), Control: c.u32(off + 4)})
+	}
+	return r, nil
+}
+
+// Index returns the index of the BAR.
+func (e ResizableBAREntry) Index() int {
+	return int(e.Control & 7)
+}
+
+// Size returns the current size of the BAR.
+func (e ResizableBAREntry) Size() uint64 {
+	return 1 << (20 + field(e.Control, 8, 6))
+}
+
+// SupportedSizes returns the sizes the BAR can be resized to.
+func (e ResizableBAREntry) SupportedSizes() []uint64 {
+	var s []uint64
+	for bit := uint(4); bit < 24; bit++ {
+		if e.Capabilities&(1<<bit) != 0 {
+			s = append(s, 1<<(20+bit-4))
+		}
+	}
+	return s
+}
+
+// rebarSize formats a BAR size as lspci does, e.g. 256MB.
+func rebarSize(size uint64) string {
+	for _, u := range []string{"MB", "GB"} {
+		if size < 1<<30 {
+			return fmt.Sprintf("%d%s", size>>20, u)
+		}
+		size >>= 10
+	}
+	return fmt.Sprintf("%dTB", size>>20)
+}
+
+func (r *ResizableBAR) String() string {
+	lines := []string{"Physical Resizable BAR"}
+	for _, b := range r.BARs {
+		var sizes []string
+		for _, s := range b.SupportedSizes() {
+			sizes = append(sizes, rebarSize(s))
+		}
+		lines = append(lines, fmt.Sprintf("\tBAR %d: current size: %s, supported: %s", b.Index(), rebarSize(b.Size()), strings.Join(sizes, " ")))
+	}
+	return strings.Join(lines, "\n")
+}
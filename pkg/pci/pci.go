@@ -49,6 +49,28 @@ func (p *PCI) ReadConfig() error {
 	return nil
 }
 
+// Decode adds the lspci -vvv style description of the device to
+// ExtraInfo: the command and status registers, BARs, bridge windows and
+// capabilities. Only root can read the capabilities.
+func (p *PCI) Decode() error {
+	c, err := p.ConfigSpace()
+	if err != nil {
+		return err
+	}
+	res, err := p.Resources()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines, err := c.Decode(res)
+	if err != nil {
+		return fmt.Errorf("%s: %v", p.Addr, err)
+	}
+	for _, l := range lines {
+		p.ExtraInfo = append(p.ExtraInfo, "\t"+l)
+	}
+	return nil
+}
+
 type barreg struct {
 	offset int64
 	*os.File
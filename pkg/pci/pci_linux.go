@@ -13,7 +13,9 @@ import (
 	"sort"
 )
 
-const (
+// busPath and pciPath are variables so the tests can use a fake sysfs.
+var (
+	busPath = "/sys/bus/pci"
 	pciPath = "/sys/bus/pci/devices"
 )
 
@@ -0,0 +1,210 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The tree is built and drawn as lspci -t does: bridges are nested by their
+// bus numbers, devices hang off the bus of the innermost bridge whose
+// range has their bus, and each line is drawn on top of the previous one.
+
+type treeDev struct {
+	p                     *PCI
+	domain, bus, dev, fun int
+	bridge                *treeBridge
+}
+
+type treeBus struct {
+	domain, number int
+	devs           []*treeDev
+}
+
+type treeBridge struct {
+	// domain is -1 for the host bridge, which has all the root buses.
+	domain                          int
+	primary, secondary, subordinate int
+	children                        []*treeBridge
+	buses                           []*treeBus
+}
+
+func (b *treeBridge) bus(domain, number int) *treeBus {
+	for _, u := range b.buses {
+		if u.domain == domain && u.number == number {
+			return u
+		}
+	}
+	u := &treeBus{domain: domain, number: number}
+	b.buses = append(b.buses, u)
+	return u
+}
+
+// Tree returns the devices as a tree of buses and bridges, like lspci -t.
+// Devices are named if SetVendorDeviceName was called.
+func (d Devices) Tree() (string, error) {
+	var devs []*treeDev
+	for _, p := range d {
+		t := &treeDev{p: p}
+		if _, err := fmt.Sscanf(p.Addr, "%x:%x:%x.%x", &t.domain, &t.bus, &t.dev, &t.fun); err != nil {
+			return "", fmt.Errorf("address %q: %v", p.Addr, err)
+		}
+		devs = append(devs, t)
+	}
+	sort.Slice(devs, func(i, j int) bool {
+		a, b := devs[i], devs[j]
+		if a.domain != b.domain {
+			return a.domain < b.domain
+		}
+		if a.bus != b.bus {
+			return a.bus < b.bus
+		}
+		if a.dev != b.dev {
+			return a.dev < b.dev
+		}
+		return a.fun < b.fun
+	})
+
+	host := &treeBridge{domain: -1, subordinate: 0xff}
+	bridges := []*treeBridge{host}
+	for _, t := range devs {
+		c, err := t.p.ConfigSpace()
+		if err != nil {
+			return "", err
+		}
+		if ht := c.HeaderType(); c.Class()>>16 != 0x06 || (ht != HeaderTypeBridge && ht != HeaderTypeCardbus) {
+			continue
+		}
+		// Cardbus bridges have their bus numbers at the same offsets.
+		t.bridge = &treeBridge{
+			domain:      t.domain,
+			primary:     int(c.u8(regPrimaryBus)),
+			secondary:   int(c.u8(regSecondaryBus)),
+			subordinate: int(c.u8(regSubordinate)),
+		}
+		bridges = append(bridges, t.bridge)
+	}
+	// A bridge is behind the bridge with the narrowest range that has its
+	// primary bus.
+	for _, b := range bridges[1:] {
+		var best *treeBridge
+		for _, c := range bridges {
+			if c != b && (c == host || c.domain == b.domain) && c.secondary <= b.primary && b.primary <= c.subordinate &&
+				(best == nil || best.subordinate-best.secondary > c.subordinate-c.secondary) {
+				best = c
+			}
+		}
+		best.children = append(best.children, b)
+	}
+	for _, b := range bridges[1:] {
+		b.bus(b.domain, b.secondary)
+	}
+	for _, t := range devs {
+		b := host
+		for {
+			var next *treeBridge
+			for _, c := range b.children {
+				if c.domain == t.domain && c.secondary <= t.bus && t.bus <= c.subordinate {
+					next = c
+					break
+				}
+			}
+			if next == nil {
+				break
+			}
+			b = next
+		}
+		u := b.bus(t.domain, t.bus)
+		u.devs = append(u.devs, t)
+	}
+
+	var w treeWriter
+	if len(host.buses) != 0 {
+		w.bridge(host, 0)
+	}
+	return w.out.String(), nil
+}
+
+// treeWriter draws the tree. line is the current line; after it is
+// printed, it is kept with everything but the vertical lines blanked, so
+// the lines below continue them.
+type treeWriter struct {
+	out  strings.Builder
+	line []byte
+}
+
+// put writes s at p in the line and returns the position after it.
+func (w *treeWriter) put(p int, s string) int {
+	w.line = append(w.line[:p], s...)
+	return len(w.line)
+}
+
+func (w *treeWriter) print(p int) {
+	w.line = w.line[:p]
+	w.out.Write(w.line)
+	w.out.WriteByte('\n')
+	for i, c := range w.line {
+		if c == '+' || c == '|' {
+			w.line[i] = '|'
+		} else {
+			w.line[i] = ' '
+		}
+	}
+}
+
+func (w *treeWriter) bridge(b *treeBridge, p int) {
+	p = w.put(p, "-")
+	if len(b.buses) == 1 {
+		if b.domain == -1 {
+			u := b.buses[0]
+			p = w.put(p, fmt.Sprintf("[%04x:%02x]-", u.domain, u.number))
+		}
+		w.bus(b.buses[0], p)
+		return
+	}
+	for i, u := range b.buses {
+		branch := "+-"
+		if i == len(b.buses)-1 {
+			branch = `\-`
+		}
+		w.bus(u, w.put(p, fmt.Sprintf("%s[%04x:%02x]-", branch, u.domain, u.number)))
+	}
+}
+
+func (w *treeWriter) bus(u *treeBus, p int) {
+	switch len(u.devs) {
+	case 0:
+		w.print(p)
+	case 1:
+		w.dev(u.devs[0], w.put(p, "--"))
+	default:
+		for i, d := range u.devs {
+			branch := "+-"
+			if i == len(u.devs)-1 {
+				branch = `\-`
+			}
+			w.dev(d, w.put(p, branch))
+		}
+	}
+}
+
+func (w *treeWriter) dev(d *treeDev, p int) {
+	p = w.put(p, fmt.Sprintf("%02x.%x", d.dev, d.fun))
+	if b := d.bridge; b != nil {
+		if b.secondary == b.subordinate {
+			p = w.put(p, fmt.Sprintf("-[%02x]-", b.secondary))
+		} else {
+			p = w.put(p, fmt.Sprintf("-[%02x-%02x]-", b.secondary, b.subordinate))
+		}
+		w.bridge(b, p)
+		return
+	}
+	if d.p.VendorName != d.p.Vendor || d.p.DeviceName != d.p.Device {
+		p = w.put(p, fmt.Sprintf("  %s %s", d.p.VendorName, d.p.DeviceName))
+	}
+	w.print(p)
+}
@@ -35,6 +35,11 @@ func OpenRTC() (*RTC, error) {
 	return nil, errors.New("no RTC device found")
 }
 
+// Close closes the RTC device.
+func (r *RTC) Close() error {
+	return r.file.Close()
+}
+
 func (r *RTC) Read() (time.Time, error) {
 	rt, err := unix.IoctlGetRTCTime(int(r.file.Fd()))
 	if err != nil {
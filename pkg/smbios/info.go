@@ -9,6 +9,7 @@
 package smbios
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -43,6 +44,61 @@ func ParseInfo(entryData, tableData []byte) (*Info, error) {
 	return info, nil
 }
 
+// MarshalTables encodes the tables of i into a structure table.
+func (i *Info) MarshalTables() ([]byte, error) {
+	var b []byte
+	for _, t := range i.Tables {
+		tb, err := t.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("table 0x%04X: %v", t.Handle, err)
+		}
+		b = append(b, tb...)
+	}
+	return b, nil
+}
+
+// Marshal encodes i into an entry point structure and a structure table,
+// e.g. to pass modified tables to the next kernel. The 64-bit entry point is
+// used if there is one. Its size and address fields are set to describe the
+// structure table at physical address addr.
+func (i *Info) Marshal(addr uint64) ([]byte, []byte, error) {
+	table, err := i.MarshalTables()
+	if err != nil {
+		return nil, nil, err
+	}
+	var entry []byte
+	switch {
+	case i.Entry64 != nil:
+		e := *i.Entry64
+		e.StructMaxSize = uint32(len(table))
+		e.StructTableAddr = addr
+		entry, err = e.MarshalBinary()
+	case i.Entry32 != nil:
+		if addr+uint64(len(table)) > 0x100000000 || len(table) > 0xffff {
+			return nil, nil, fmt.Errorf("a 32-bit entry point can not describe %d bytes at %#x", len(table), addr)
+		}
+		e := *i.Entry32
+		e.StructTableLength = uint16(len(table))
+		e.StructTableAddr = uint32(addr)
+		e.NumberOfStructs = uint16(len(i.Tables))
+		// Firmwares disagree on what the largest structure size
+		// covers, so it is only ever raised.
+		for _, t := range i.Tables {
+			tb, _ := t.MarshalBinary()
+			if len(tb) > int(e.StructMaxSize) {
+				e.StructMaxSize = uint16(len(tb))
+			}
+		}
+		entry, err = e.MarshalBinary()
+	default:
+		return nil, nil, errors.New("no entry point structure")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, table, nil
+}
+
 // MajorVersion return major version of the SMBIOS spec.
 func (i *Info) MajorVersion() uint8 {
 	if i.Entry64 != nil {
@@ -143,6 +199,19 @@ func (i *Info) GetProcessorInfo() ([]*ProcessorInfo, error) {
 	return res, nil
 }
 
+// GetMemoryControllerInfo returns all the Memory Controller Information (type 5) tables present.
+func (i *Info) GetMemoryControllerInfo() ([]*MemoryControllerInfo, error) {
+	var res []*MemoryControllerInfo
+	for _, t := range i.GetTablesByType(TableTypeMemoryControllerInfo) {
+		mc, err := NewMemoryControllerInfo(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, mc)
+	}
+	return res, nil
+}
+
 // GetCacheInfo returns all the Cache Info (type 7) tables present.
 func (i *Info) GetCacheInfo() ([]*CacheInfo, error) {
 	var res []*CacheInfo
@@ -156,6 +225,110 @@ func (i *Info) GetCacheInfo() ([]*CacheInfo, error) {
 	return res, nil
 }
 
+// GetPortConnectorInfo returns all the Port Connector Info (type 8) tables present.
+func (i *Info) GetPortConnectorInfo() ([]*PortConnectorInfo, error) {
+	var res []*PortConnectorInfo
+	for _, t := range i.GetTablesByType(TableTypePortConnectorInfo) {
+		pi, err := NewPortConnectorInfo(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, pi)
+	}
+	return res, nil
+}
+
+// GetSystemSlots returns all the System Slots (type 9) tables present.
+func (i *Info) GetSystemSlots() ([]*SystemSlots, error) {
+	var res []*SystemSlots
+	for _, t := range i.GetTablesByType(TableTypeSystemSlots) {
+		ss, err := NewSystemSlots(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ss)
+	}
+	return res, nil
+}
+
+// GetOnBoardDevicesInfo returns all the On Board Devices Info (type 10) tables present.
+func (i *Info) GetOnBoardDevicesInfo() ([]*OnBoardDevicesInfo, error) {
+	var res []*OnBoardDevicesInfo
+	for _, t := range i.GetTablesByType(TableTypeOnBoardDevicesInfo) {
+		di, err := NewOnBoardDevicesInfo(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, di)
+	}
+	return res, nil
+}
+
+// GetOEMStrings returns all the OEM Strings (type 11) tables present.
+func (i *Info) GetOEMStrings() ([]*OEMStrings, error) {
+	var res []*OEMStrings
+	for _, t := range i.GetTablesByType(TableTypeOEMStrings) {
+		ot, err := NewOEMStrings(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ot)
+	}
+	return res, nil
+}
+
+// GetSystemConfigurationOptions returns all the System Configuration Options (type 12) tables present.
+func (i *Info) GetSystemConfigurationOptions() ([]*SystemConfigurationOptions, error) {
+	var res []*SystemConfigurationOptions
+	for _, t := range i.GetTablesByType(TableTypeSystemConfigurationOptions) {
+		co, err := NewSystemConfigurationOptions(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, co)
+	}
+	return res, nil
+}
+
+// GetBIOSLanguageInfo returns all the BIOS Language Info (type 13) tables present.
+func (i *Info) GetBIOSLanguageInfo() ([]*BIOSLanguageInfo, error) {
+	var res []*BIOSLanguageInfo
+	for _, t := range i.GetTablesByType(TableTypeBIOSLanguageInfo) {
+		li, err := NewBIOSLanguageInfo(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, li)
+	}
+	return res, nil
+}
+
+// GetGroupAssociations returns all the Group Associations (type 14) tables present.
+func (i *Info) GetGroupAssociations() ([]*GroupAssociations, error) {
+	var res []*GroupAssociations
+	for _, t := range i.GetTablesByType(TableTypeGroupAssociations) {
+		ga, err := NewGroupAssociations(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ga)
+	}
+	return res, nil
+}
+
+// GetPhysicalMemoryArrays returns all the Physical Memory Array (type 16) tables present.
+func (i *Info) GetPhysicalMemoryArrays() ([]*PhysicalMemoryArray, error) {
+	var res []*PhysicalMemoryArray
+	for _, t := range i.GetTablesByType(TableTypePhysicalMemoryArray) {
+		ma, err := NewPhysicalMemoryArray(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ma)
+	}
+	return res, nil
+}
+
 // GetMemoryDevices returns all the Memory Device (type 17) tables present.
 func (i *Info) GetMemoryDevices() ([]*MemoryDevice, error) {
 	var res []*MemoryDevice
@@ -169,6 +342,97 @@ func (i *Info) GetMemoryDevices() ([]*MemoryDevice, error) {
 	return res, nil
 }
 
+// GetMemoryErrorInfo32 returns all the 32-bit Memory Error Info (type 18) tables present.
+func (i *Info) GetMemoryErrorInfo32() ([]*MemoryErrorInfo32, error) {
+	var res []*MemoryErrorInfo32
+	for _, t := range i.GetTablesByType(TableTypeMemoryErrorInfo32) {
+		ei, err := NewMemoryErrorInfo32(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ei)
+	}
+	return res, nil
+}
+
+// GetMemoryArrayMappedAddresses returns all the Memory Array Mapped Address (type 19) tables present.
+func (i *Info) GetMemoryArrayMappedAddresses() ([]*MemoryArrayMappedAddress, error) {
+	var res []*MemoryArrayMappedAddress
+	for _, t := range i.GetTablesByType(TableTypeMemoryArrayMappedAddress) {
+		ma, err := NewMemoryArrayMappedAddress(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ma)
+	}
+	return res, nil
+}
+
+// GetMemoryDeviceMappedAddresses returns all the Memory Device Mapped Address (type 20) tables present.
+func (i *Info) GetMemoryDeviceMappedAddresses() ([]*MemoryDeviceMappedAddress, error) {
+	var res []*MemoryDeviceMappedAddress
+	for _, t := range i.GetTablesByType(TableTypeMemoryDeviceMappedAddress) {
+		da, err := NewMemoryDeviceMappedAddress(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, da)
+	}
+	return res, nil
+}
+
+// GetPortableBatteries returns all the Portable Battery (type 22) tables present.
+func (i *Info) GetPortableBatteries() ([]*PortableBattery, error) {
+	var res []*PortableBattery
+	for _, t := range i.GetTablesByType(TableTypePortableBattery) {
+		pb, err := NewPortableBattery(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, pb)
+	}
+	return res, nil
+}
+
+// GetHardwareSecurity returns all the Hardware Security (type 24) tables present.
+func (i *Info) GetHardwareSecurity() ([]*HardwareSecurity, error) {
+	var res []*HardwareSecurity
+	for _, t := range i.GetTablesByType(TableTypeHardwareSecurity) {
+		hs, err := NewHardwareSecurity(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, hs)
+	}
+	return res, nil
+}
+
+// GetSystemBootInfo returns all the System Boot Info (type 32) tables present.
+func (i *Info) GetSystemBootInfo() ([]*SystemBootInfo, error) {
+	var res []*SystemBootInfo
+	for _, t := range i.GetTablesByType(TableTypeSystemBootInfo) {
+		bi, err := NewSystemBootInfo(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, bi)
+	}
+	return res, nil
+}
+
+// GetManagementDevices returns all the Management Device (type 34) tables present.
+func (i *Info) GetManagementDevices() ([]*ManagementDevice, error) {
+	var res []*ManagementDevice
+	for _, t := range i.GetTablesByType(TableTypeManagementDevice) {
+		md, err := NewManagementDevice(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, md)
+	}
+	return res, nil
+}
+
 // GetIPMIDeviceInfo returns all the IPMI Device Info (type 38) tables present.
 func (i *Info) GetIPMIDeviceInfo() ([]*IPMIDeviceInfo, error) {
 	var res []*IPMIDeviceInfo
@@ -182,6 +446,32 @@ func (i *Info) GetIPMIDeviceInfo() ([]*IPMIDeviceInfo, error) {
 	return res, nil
 }
 
+// GetSystemPowerSupplies returns all the System Power Supply (type 39) tables present.
+func (i *Info) GetSystemPowerSupplies() ([]*SystemPowerSupply, error) {
+	var res []*SystemPowerSupply
+	for _, t := range i.GetTablesByType(TableTypeSystemPowerSupply) {
+		ps, err := NewSystemPowerSupply(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ps)
+	}
+	return res, nil
+}
+
+// GetOnboardDevicesExtendedInfo returns all the Onboard Devices Extended Info (type 41) tables present.
+func (i *Info) GetOnboardDevicesExtendedInfo() ([]*OnboardDevicesExtendedInfo, error) {
+	var res []*OnboardDevicesExtendedInfo
+	for _, t := range i.GetTablesByType(TableTypeOnboardDevicesExtendedInfo) {
+		di, err := NewOnboardDevicesExtendedInfo(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, di)
+	}
+	return res, nil
+}
+
 // GetTPMDevices returns all the TPM Device (type 43) tables present.
 func (i *Info) GetTPMDevices() ([]*TPMDevice, error) {
 	var res []*TPMDevice
@@ -194,3 +484,16 @@ func (i *Info) GetTPMDevices() ([]*TPMDevice, error) {
 	}
 	return res, nil
 }
+
+// GetProcessorAdditionalInfo returns all the Processor Additional Info (type 44) tables present.
+func (i *Info) GetProcessorAdditionalInfo() ([]*ProcessorAdditionalInfo, error) {
+	var res []*ProcessorAdditionalInfo
+	for _, t := range i.GetTablesByType(TableTypeProcessorAdditionalInfo) {
+		pi, err := NewProcessorAdditionalInfo(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, pi)
+	}
+	return res, nil
+}
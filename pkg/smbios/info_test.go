@@ -0,0 +1,147 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// The dumps of dmidecode --dump-bin have the entry point at 0 and the
+// structure table at 0x20.
+func readDumps(t *testing.T) map[string][]byte {
+	files, err := filepath.Glob("../../cmds/exp/dmidecode/testdata/*.bin")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no dumps found: %v", err)
+	}
+	dumps := map[string][]byte{}
+	for _, f := range files {
+		d, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dumps[filepath.Base(f)] = d
+	}
+	return dumps
+}
+
+func TestEncodeDumps(t *testing.T) {
+	for name, d := range readDumps(t) {
+		info, err := ParseInfo(d[:0x20], d[0x20:])
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, tab := range info.Tables {
+			pt, err := ParseTypedTable(tab)
+			if err == ErrUnsupportedTableType {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: table 0x%04X: %v", name, tab.Handle, err)
+			}
+			enc, err := EncodeTypedTable(pt)
+			if err != nil {
+				t.Fatalf("%s: table 0x%04X: %v", name, tab.Handle, err)
+			}
+			want, _ := tab.MarshalBinary()
+			if got, _ := enc.MarshalBinary(); !bytes.Equal(got, want) {
+				t.Errorf("%s: table 0x%04X encodes to\n% x, want\n% x", name, tab.Handle, got, want)
+			}
+		}
+
+		entry, table, err := info.Marshal(0x20)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.HasPrefix(d[0x20:], table) {
+			t.Errorf("%s: structure table changed", name)
+		}
+		e32, e64, err := ParseEntry(entry)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		switch {
+		case e32 != nil:
+			if e32.StructMaxSize < info.Entry32.StructMaxSize {
+				t.Errorf("%s: largest structure size lowered from %d to %d", name, info.Entry32.StructMaxSize, e32.StructMaxSize)
+			}
+			e32.StructMaxSize, e32.Checksum = info.Entry32.StructMaxSize, info.Entry32.Checksum
+			if *e32 != *info.Entry32 {
+				t.Errorf("%s: entry point is %+v, want %+v", name, e32, info.Entry32)
+			}
+		case e64 != nil:
+			if *e64 != *info.Entry64 {
+				t.Errorf("%s: entry point is %+v, want %+v", name, e64, info.Entry64)
+			}
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	var tabs []*Table
+	for _, tt := range []struct {
+		tt      TableType
+		data    []byte
+		strings []string
+	}{
+		{TableTypeBIOSInfo, []byte{1, 2, 0, 0xe8, 3, 0, 0x08, 0, 0, 0, 0, 0, 0, 0}, []string{"u-root", "1.0"}},
+		{TableTypeOEMStrings, []byte{2}, []string{"a", "b"}},
+		{TableTypeEndOfTable, nil, nil},
+	} {
+		tab, err := NewTable(tt.tt, uint16(len(tabs)), tt.data, tt.strings...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tabs = append(tabs, tab)
+	}
+
+	for _, tt := range []struct {
+		name string
+		info *Info
+		addr uint64
+	}{
+		{"32-bit", &Info{Entry32: &Entry32{Anchor: [4]uint8{'_', 'S', 'M', '_'}, Length: 0x1f, SMBIOSMajorVersion: 2, SMBIOSMinorVersion: 8, IntAnchor: [5]uint8{'_', 'D', 'M', 'I', '_'}, BCDRevision: 0x28}, Tables: tabs}, 0xf0000},
+		{"64-bit", &Info{Entry64: &Entry64{Anchor: [5]uint8{'_', 'S', 'M', '3', '_'}, Length: 0x18, SMBIOSMajorVersion: 3, SMBIOSMinorVersion: 2, Revision: 1}, Tables: tabs}, 0x100000000},
+	} {
+		entry, table, err := tt.info.Marshal(tt.addr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		info, err := ParseInfo(entry, table)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if info.String() != tt.info.String() {
+			t.Errorf("%s: parsed %s, want %s", tt.name, info, tt.info)
+		}
+		oem, err := info.GetOEMStrings()
+		if err != nil || len(oem) != 1 || len(oem[0].Strings) != 2 || oem[0].Strings[1] != "b" {
+			t.Errorf("%s: OEM strings %v, %v", tt.name, oem, err)
+		}
+		switch {
+		case info.Entry32 != nil:
+			e := info.Entry32
+			if e.StructTableAddr != uint32(tt.addr) || int(e.StructTableLength) != len(table) || e.NumberOfStructs != 3 || e.StructMaxSize != 30 {
+				t.Errorf("%s: entry point %+v", tt.name, e)
+			}
+		case info.Entry64 != nil:
+			e := info.Entry64
+			if e.StructTableAddr != tt.addr || int(e.StructMaxSize) != len(table) {
+				t.Errorf("%s: entry point %+v", tt.name, e)
+			}
+		}
+	}
+
+	info := &Info{Entry32: &Entry32{}, Tables: tabs}
+	if _, _, err := info.Marshal(0xffffffe0); err == nil {
+		t.Errorf("32-bit entry point above 4G: Marshal succeeded, want error")
+	}
+	info = &Info{Tables: tabs}
+	if _, _, err := info.Marshal(0); err == nil {
+		t.Errorf("no entry point: Marshal succeeded, want error")
+	}
+}
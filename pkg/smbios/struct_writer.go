@@ -0,0 +1,181 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type fieldWriter interface {
+	WriteField(t *Table, off int) (int, error)
+}
+
+var fieldWriterInterfaceType = reflect.TypeOf((*fieldWriter)(nil)).Elem()
+
+// typedTable is implemented by Table and, through embedding, by the typed tables.
+type typedTable interface {
+	table() *Table
+}
+
+func (t *Table) table() *Table {
+	return t
+}
+
+// EncodeTypedTable encodes a typed table returned by ParseTypedTable, such as
+// a *SystemInfo, into a generic Table again, e.g. to modify a table: parse
+// it, change it and encode it.
+//
+// The fields are written where they were parsed from, so the table keeps its
+// length. Fields the parser does not read from the structured part, like
+// ContainedObjectHandles, are not written; the original data is kept. A
+// changed string replaces the one it was parsed from, or is added if there
+// was none.
+func EncodeTypedTable(v fmt.Stringer) (*Table, error) {
+	tt, ok := v.(typedTable)
+	if !ok {
+		return nil, fmt.Errorf("can not encode %T", v)
+	}
+	orig := tt.table()
+	t := &Table{
+		Header:  orig.Header,
+		data:    append([]byte{}, orig.data...),
+		strings: append([]string{}, orig.strings...),
+	}
+	if _, err := writeStruct(t, 0 /* off */, v); err != nil {
+		return nil, err
+	}
+	if err := t.Header.Parse(t.data); err != nil {
+		return nil, err
+	}
+	if int(t.Length) != len(t.data) {
+		return nil, fmt.Errorf("length %d does not match the structured part of %d bytes", t.Length, len(t.data))
+	}
+	return t, nil
+}
+
+// writeStruct is the reverse of parseStruct: it writes the fields of the
+// struct sp to the structured part of t, starting at off, for as long as
+// there is room.
+func writeStruct(t *Table, off int, sp interface{}) (int, error) {
+	var err error
+	var ok bool
+	var sv reflect.Value
+	if sv, ok = sp.(reflect.Value); !ok {
+		sv = reflect.Indirect(reflect.ValueOf(sp))
+	}
+	svtn := sv.Type().Name()
+	for i := 0; i < sv.NumField() && off < t.Len(); i++ {
+		f := sv.Type().Field(i)
+		fv := sv.Field(i)
+		ignore := false
+		for _, tag := range strings.Split(f.Tag.Get(fieldTagKey), ",") {
+			tp := strings.Split(tag, "=")
+			switch tp[0] {
+			case "-":
+				ignore = true
+			case "skip":
+				numBytes, _ := strconv.Atoi(tp[1])
+				off += numBytes
+			}
+		}
+		if ignore {
+			continue
+		}
+		var werr error
+		switch fv.Kind() {
+		case reflect.Uint8:
+			werr = t.setBytesAt(off, []byte{uint8(fv.Uint())})
+			off++
+		case reflect.Uint16:
+			b := make([]byte, 2)
+			binary.LittleEndian.PutUint16(b, uint16(fv.Uint()))
+			werr = t.setBytesAt(off, b)
+			off += 2
+		case reflect.Uint32:
+			b := make([]byte, 4)
+			binary.LittleEndian.PutUint32(b, uint32(fv.Uint()))
+			werr = t.setBytesAt(off, b)
+			off += 4
+		case reflect.Uint64:
+			b := make([]byte, 8)
+			binary.LittleEndian.PutUint64(b, fv.Uint())
+			werr = t.setBytesAt(off, b)
+			off += 8
+		case reflect.String:
+			werr = t.setStringAt(off, fv.String())
+			off++
+		default:
+			if reflect.PtrTo(fv.Type()).Implements(fieldWriterInterfaceType) {
+				// fv may not be addressable if sp was not a pointer.
+				p := reflect.New(fv.Type())
+				p.Elem().Set(fv)
+				if off, err = p.Interface().(fieldWriter).WriteField(t, off); err != nil {
+					return off, err
+				}
+				break
+			}
+			if fv.Kind() == reflect.Struct {
+				if off, err = writeStruct(t, off, fv); err != nil {
+					return off, err
+				}
+				break
+			}
+			return off, fmt.Errorf("%s.%s: unsupported type %s", svtn, f.Name, fv.Kind())
+		}
+		if werr != nil {
+			return off, fmt.Errorf("failed to write %s.%s: %s", svtn, f.Name, werr)
+		}
+	}
+	return off, nil
+}
+
+// setBytesAt overwrites the structured part at the specified offset.
+func (t *Table) setBytesAt(offset int, b []byte) error {
+	if offset > len(t.data)-len(b) {
+		return fmt.Errorf("invalid offset %d", offset)
+	}
+	copy(t.data[offset:], b)
+	return nil
+}
+
+// setStringAt sets the string pointed to by the byte at the specified offset
+// to s, the reverse of GetStringAt.
+func (t *Table) setStringAt(offset int, s string) error {
+	if offset >= len(t.data) {
+		return fmt.Errorf("invalid offset %d", offset)
+	}
+	if old, _ := t.GetStringAt(offset); old == s {
+		return nil
+	}
+	if s == "" {
+		t.data[offset] = 0
+		return nil
+	}
+	if strings.IndexByte(s, 0) >= 0 {
+		return fmt.Errorf("invalid string %q", s)
+	}
+	if i := int(t.data[offset]); i > 0 && i <= len(t.strings) {
+		t.strings[i-1] = s
+		return nil
+	}
+	if len(t.strings) >= 0xff {
+		return fmt.Errorf("too many strings")
+	}
+	t.strings = append(t.strings, s)
+	t.data[offset] = uint8(len(t.strings))
+	return nil
+}
+
+// WriteField writes UUID field within a table.
+func (u *UUID) WriteField(t *Table, off int) (int, error) {
+	if err := t.setBytesAt(off, u[:]); err != nil {
+		return off, err
+	}
+	return off + 16, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeTypedTable(t *testing.T) {
+	tab, err := NewTable(TableTypeSystemInfo, 1,
+		[]byte{
+			1, 2, 0, 3, // Manufacturer, Product Name, Version, Serial Number
+			0x10, 0x32, 0x54, 0x76, 0x98, 0xba, 0xdc, 0xfe, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+			6,    // Wake-up Type
+			0, 0, // SKU Number, Family
+		},
+		"u-root", "qemu", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	si, err := ParseSystemInfo(tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged, it encodes to the same table.
+	got, err := EncodeTypedTable(si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, tab) {
+		t.Errorf("EncodeTypedTable = %v, want %v", got, tab)
+	}
+
+	si.Handle = 2
+	si.SerialNumber = "5678"
+	si.Version = "1.0"
+	si.ProductName = ""
+	si.UUID[0] = 0xff
+	si.WakeupType = WakeupTypeLANRemote
+	got, err = EncodeTypedTable(si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := got.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		1, 0x1b, 2, 0,
+		1, 0, 4, 3,
+		0xff, 0x32, 0x54, 0x76, 0x98, 0xba, 0xdc, 0xfe, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+		5,
+		0, 0,
+	}
+	want = append(want, "u-root\x00qemu\x005678\x001.0\x00\x00"...)
+	if !bytes.Equal(b, want) {
+		t.Errorf("modified table is\n% x, want\n% x", b, want)
+	}
+	si2, err := ParseSystemInfo(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if si2.SerialNumber != "5678" || si2.Version != "1.0" || si2.ProductName != "Not Specified" || si2.Handle != 2 {
+		t.Errorf("modified table parses to %+v", si2)
+	}
+
+	if _, err := EncodeTypedTable(WakeupTypeLANRemote); err == nil {
+		t.Errorf("EncodeTypedTable(WakeupType) succeeded, want error")
+	}
+}
@@ -90,15 +90,60 @@ func (t *Table) GetStringAt(offset int) (string, error) {
 	if offset >= len(t.data) {
 		return "", fmt.Errorf("invalid offset %d", offset)
 	}
-	stringIndex := t.data[offset]
+	return t.getString(t.data[offset])
+}
+
+// getString returns the string with the specified index, starting at 1;
+// 0 means there is none.
+func (t *Table) getString(index uint8) (string, error) {
 	switch {
-	case stringIndex == 0:
+	case index == 0:
 		return "Not Specified", nil
-	case int(stringIndex) <= len(t.strings):
-		return t.strings[stringIndex-1], nil
+	case int(index) <= len(t.strings):
+		return t.strings[index-1], nil
 	default:
-		return "<BAD INDEX>", fmt.Errorf("invalid string index %d", stringIndex)
+		return "<BAD INDEX>", fmt.Errorf("invalid string index %d", index)
+	}
+}
+
+// NewTable returns a table of type tt with the given handle, structured part
+// data (not including the 4 byte header) and strings, e.g. to build test
+// fixtures. The structured part refers to the strings by their index,
+// starting at 1.
+func NewTable(tt TableType, handle uint16, data []byte, strings ...string) (*Table, error) {
+	if 4+len(data) > 0xff {
+		return nil, fmt.Errorf("structured part of %d bytes is too long", len(data))
+	}
+	for _, s := range strings {
+		if s == "" || bytes.IndexByte([]byte(s), 0) >= 0 {
+			return nil, fmt.Errorf("invalid string %q", s)
+		}
+	}
+	h := Header{Type: tt, Length: uint8(4 + len(data)), Handle: handle}
+	d := make([]byte, 4, h.Length)
+	d[0], d[1] = uint8(h.Type), h.Length
+	binary.LittleEndian.PutUint16(d[2:], h.Handle)
+	return &Table{Header: h, data: append(d, data...), strings: strings}, nil
+}
+
+// MarshalBinary encodes the table as it appears in the structure table: the
+// structured part, using the type and handle of the header, followed by the
+// strings.
+func (t *Table) MarshalBinary() ([]byte, error) {
+	if len(t.data) < 4 || int(t.Length) != len(t.data) {
+		return nil, fmt.Errorf("header length %d does not match the structured part of %d bytes", t.Length, len(t.data))
+	}
+	b := append([]byte{}, t.data...)
+	b[0] = uint8(t.Type)
+	binary.LittleEndian.PutUint16(b[2:], t.Handle)
+	for _, s := range t.strings {
+		b = append(b, s...)
+		b = append(b, 0)
+	}
+	if len(t.strings) == 0 {
+		b = append(b, 0)
 	}
+	return append(b, 0), nil
 }
 
 func (t *Table) String() string {
@@ -0,0 +1,67 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNewTable(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		strings []string
+		want    []byte
+	}{
+		{
+			name: "no strings",
+			data: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want: []byte{0x20, 0x0a, 0x34, 0x12, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:    "strings",
+			data:    []byte{0x02, 0x01},
+			strings: []string{"one", "two"},
+			want:    []byte{0x20, 0x06, 0x34, 0x12, 0x02, 0x01, 'o', 'n', 'e', 0, 't', 'w', 'o', 0, 0},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tab, err := NewTable(TableTypeSystemBootInfo, 0x1234, tt.data, tt.strings...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tab.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("MarshalBinary = % x, want % x", got, tt.want)
+			}
+			pt, rest, err := ParseTable(append(got, 0xaa))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pt, tab) || !bytes.Equal(rest, []byte{0xaa}) {
+				t.Errorf("ParseTable = %v, % x, want %v, aa", pt, rest, tab)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		strings []string
+	}{
+		{"too long", make([]byte, 252), nil},
+		{"empty string", nil, []string{""}},
+		{"NUL in string", nil, []string{"a\x00b"}},
+	} {
+		if _, err := NewTable(TableTypeOEMStrings, 0, tt.data, tt.strings...); err == nil {
+			t.Errorf("%s: NewTable succeeded, want error", tt.name)
+		}
+	}
+}
@@ -14,17 +14,36 @@ type TableType uint8
 
 // Supported table types.
 const (
-	TableTypeBIOSInfo       TableType = 0
-	TableTypeSystemInfo     TableType = 1
-	TableTypeBaseboardInfo  TableType = 2
-	TableTypeChassisInfo    TableType = 3
-	TableTypeProcessorInfo  TableType = 4
-	TableTypeCacheInfo      TableType = 7
-	TableTypeMemoryDevice   TableType = 17
-	TableTypeIPMIDeviceInfo TableType = 38
-	TableTypeTPMDevice      TableType = 43
-	TableTypeInactive       TableType = 126
-	TableTypeEndOfTable     TableType = 127
+	TableTypeBIOSInfo                   TableType = 0
+	TableTypeSystemInfo                 TableType = 1
+	TableTypeBaseboardInfo              TableType = 2
+	TableTypeChassisInfo                TableType = 3
+	TableTypeProcessorInfo              TableType = 4
+	TableTypeMemoryControllerInfo       TableType = 5
+	TableTypeCacheInfo                  TableType = 7
+	TableTypePortConnectorInfo          TableType = 8
+	TableTypeSystemSlots                TableType = 9
+	TableTypeOnBoardDevicesInfo         TableType = 10
+	TableTypeOEMStrings                 TableType = 11
+	TableTypeSystemConfigurationOptions TableType = 12
+	TableTypeBIOSLanguageInfo           TableType = 13
+	TableTypeGroupAssociations          TableType = 14
+	TableTypePhysicalMemoryArray        TableType = 16
+	TableTypeMemoryDevice               TableType = 17
+	TableTypeMemoryErrorInfo32          TableType = 18
+	TableTypeMemoryArrayMappedAddress   TableType = 19
+	TableTypeMemoryDeviceMappedAddress  TableType = 20
+	TableTypePortableBattery            TableType = 22
+	TableTypeHardwareSecurity           TableType = 24
+	TableTypeSystemBootInfo             TableType = 32
+	TableTypeManagementDevice           TableType = 34
+	TableTypeIPMIDeviceInfo             TableType = 38
+	TableTypeSystemPowerSupply          TableType = 39
+	TableTypeOnboardDevicesExtendedInfo TableType = 41
+	TableTypeTPMDevice                  TableType = 43
+	TableTypeProcessorAdditionalInfo    TableType = 44
+	TableTypeInactive                   TableType = 126
+	TableTypeEndOfTable                 TableType = 127
 )
 
 func (t TableType) String() string {
@@ -39,14 +58,52 @@ func (t TableType) String() string {
 		return "Chassis Information"
 	case TableTypeProcessorInfo:
 		return "Processor Information"
+	case TableTypeMemoryControllerInfo:
+		return "Memory Controller Information"
 	case TableTypeCacheInfo:
 		return "Cache Information"
+	case TableTypePortConnectorInfo:
+		return "Port Connector Information"
+	case TableTypeSystemSlots:
+		return "System Slot Information"
+	case TableTypeOnBoardDevicesInfo:
+		return "On Board Devices Information"
+	case TableTypeOEMStrings:
+		return "OEM Strings"
+	case TableTypeSystemConfigurationOptions:
+		return "System Configuration Options"
+	case TableTypeBIOSLanguageInfo:
+		return "BIOS Language Information"
+	case TableTypeGroupAssociations:
+		return "Group Associations"
+	case TableTypePhysicalMemoryArray:
+		return "Physical Memory Array"
 	case TableTypeMemoryDevice:
 		return "Memory Device"
+	case TableTypeMemoryErrorInfo32:
+		return "32-bit Memory Error Information"
+	case TableTypeMemoryArrayMappedAddress:
+		return "Memory Array Mapped Address"
+	case TableTypeMemoryDeviceMappedAddress:
+		return "Memory Device Mapped Address"
+	case TableTypePortableBattery:
+		return "Portable Battery"
+	case TableTypeHardwareSecurity:
+		return "Hardware Security"
+	case TableTypeSystemBootInfo:
+		return "System Boot Information"
+	case TableTypeManagementDevice:
+		return "Management Device"
 	case TableTypeIPMIDeviceInfo:
 		return "IPMI Device Information"
+	case TableTypeSystemPowerSupply:
+		return "System Power Supply"
+	case TableTypeOnboardDevicesExtendedInfo:
+		return "Onboard Device"
 	case TableTypeTPMDevice:
 		return "TPM Device"
+	case TableTypeProcessorAdditionalInfo:
+		return "Processor Additional Information"
 	case TableTypeInactive:
 		return "Inactive"
 	case TableTypeEndOfTable:
@@ -72,14 +129,52 @@ func ParseTypedTable(t *Table) (fmt.Stringer, error) {
 		return ParseChassisInfo(t)
 	case TableTypeProcessorInfo: // 4
 		return ParseProcessorInfo(t)
+	case TableTypeMemoryControllerInfo: // 5
+		return NewMemoryControllerInfo(t)
 	case TableTypeCacheInfo: // 7
 		return ParseCacheInfo(t)
+	case TableTypePortConnectorInfo: // 8
+		return NewPortConnectorInfo(t)
+	case TableTypeSystemSlots: // 9
+		return NewSystemSlots(t)
+	case TableTypeOnBoardDevicesInfo: // 10
+		return NewOnBoardDevicesInfo(t)
+	case TableTypeOEMStrings: // 11
+		return NewOEMStrings(t)
+	case TableTypeSystemConfigurationOptions: // 12
+		return NewSystemConfigurationOptions(t)
+	case TableTypeBIOSLanguageInfo: // 13
+		return NewBIOSLanguageInfo(t)
+	case TableTypeGroupAssociations: // 14
+		return NewGroupAssociations(t)
+	case TableTypePhysicalMemoryArray: // 16
+		return NewPhysicalMemoryArray(t)
 	case TableTypeMemoryDevice: // 17
 		return NewMemoryDevice(t)
+	case TableTypeMemoryErrorInfo32: // 18
+		return NewMemoryErrorInfo32(t)
+	case TableTypeMemoryArrayMappedAddress: // 19
+		return NewMemoryArrayMappedAddress(t)
+	case TableTypeMemoryDeviceMappedAddress: // 20
+		return NewMemoryDeviceMappedAddress(t)
+	case TableTypePortableBattery: // 22
+		return NewPortableBattery(t)
+	case TableTypeHardwareSecurity: // 24
+		return NewHardwareSecurity(t)
+	case TableTypeSystemBootInfo: // 32
+		return NewSystemBootInfo(t)
+	case TableTypeManagementDevice: // 34
+		return NewManagementDevice(t)
 	case TableTypeIPMIDeviceInfo: // 38
 		return ParseIPMIDeviceInfo(t)
+	case TableTypeSystemPowerSupply: // 39
+		return NewSystemPowerSupply(t)
+	case TableTypeOnboardDevicesExtendedInfo: // 41
+		return NewOnboardDevicesExtendedInfo(t)
 	case TableTypeTPMDevice: // 43
 		return NewTPMDevice(t)
+	case TableTypeProcessorAdditionalInfo: // 44
+		return NewProcessorAdditionalInfo(t)
 	case TableTypeInactive: // 126
 		return NewInactiveTable(t)
 	case TableTypeEndOfTable: // 127
@@ -0,0 +1,115 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Much of this is auto-generated. If adding a new type, see README for instructions.
+
+// OnBoardDevicesInfo is defined in DSP0134 7.11. It is obsolete, replaced by
+// OnboardDevicesExtendedInfo (type 41), but still found in many firmwares.
+type OnBoardDevicesInfo struct {
+	Table
+	Devices []OnBoardDevice `smbios:"-"` // 04h
+}
+
+// OnBoardDevice is an entry of OnBoardDevicesInfo.
+type OnBoardDevice struct {
+	Type        OnboardDeviceType // 00h
+	Description string            // 01h
+}
+
+// NewOnBoardDevicesInfo parses a generic Table into OnBoardDevicesInfo.
+func NewOnBoardDevicesInfo(t *Table) (*OnBoardDevicesInfo, error) {
+	if t.Type != TableTypeOnBoardDevicesInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	di := &OnBoardDevicesInfo{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, di)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < (t.Len()-4)/2; i++ {
+		var d OnBoardDevice
+		if off, err = parseStruct(t, off, true /* complete */, &d); err != nil {
+			return nil, err
+		}
+		di.Devices = append(di.Devices, d)
+	}
+	return di, nil
+}
+
+func (di *OnBoardDevicesInfo) String() string {
+	lines := []string{
+		fmt.Sprintf("Handle 0x%04X, DMI type %d, %d bytes", di.Handle, di.Type, di.Length),
+	}
+	for i, d := range di.Devices {
+		name := fmt.Sprintf("On Board Device %d Information", i+1)
+		if len(di.Devices) == 1 {
+			name = "On Board Device Information"
+		}
+		lines = append(lines,
+			name,
+			fmt.Sprintf("\tType: %s", d.Type),
+			fmt.Sprintf("\tStatus: %s", d.Type.status()),
+			fmt.Sprintf("\tDescription: %s", d.Description),
+		)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// OnboardDeviceType is defined in DSP0134 7.11.1.
+type OnboardDeviceType uint8
+
+// OnboardDeviceType values are defined in DSP0134 7.11.1.
+const (
+	OnboardDeviceTypeOther          OnboardDeviceType = 0x01 // Other
+	OnboardDeviceTypeUnknown        OnboardDeviceType = 0x02 // Unknown
+	OnboardDeviceTypeVideo          OnboardDeviceType = 0x03 // Video
+	OnboardDeviceTypeSCSIController OnboardDeviceType = 0x04 // SCSI Controller
+	OnboardDeviceTypeEthernet       OnboardDeviceType = 0x05 // Ethernet
+	OnboardDeviceTypeTokenRing      OnboardDeviceType = 0x06 // Token Ring
+	OnboardDeviceTypeSound          OnboardDeviceType = 0x07 // Sound
+	OnboardDeviceTypePATAController OnboardDeviceType = 0x08 // PATA Controller
+	OnboardDeviceTypeSATAController OnboardDeviceType = 0x09 // SATA Controller
+	OnboardDeviceTypeSASController  OnboardDeviceType = 0x0a // SAS Controller
+)
+
+func (v OnboardDeviceType) String() string {
+	names := map[OnboardDeviceType]string{
+		OnboardDeviceTypeOther:          "Other",
+		OnboardDeviceTypeUnknown:        "Unknown",
+		OnboardDeviceTypeVideo:          "Video",
+		OnboardDeviceTypeSCSIController: "SCSI Controller",
+		OnboardDeviceTypeEthernet:       "Ethernet",
+		OnboardDeviceTypeTokenRing:      "Token Ring",
+		OnboardDeviceTypeSound:          "Sound",
+		OnboardDeviceTypePATAController: "PATA Controller",
+		OnboardDeviceTypeSATAController: "SATA Controller",
+		OnboardDeviceTypeSASController:  "SAS Controller",
+	}
+	if name, ok := names[v&0x7f]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// OnboardDeviceTypeEnabled is set in OnboardDeviceType if the device is enabled.
+const OnboardDeviceTypeEnabled OnboardDeviceType = 1 << 7
+
+// Enabled returns whether the device is enabled.
+func (v OnboardDeviceType) Enabled() bool {
+	return v&OnboardDeviceTypeEnabled != 0
+}
+
+func (v OnboardDeviceType) status() string {
+	if v.Enabled() {
+		return "Enabled"
+	}
+	return "Disabled"
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OEMStrings is defined in DSP0134 7.12.
+type OEMStrings struct {
+	Table
+	Count   uint8    // 04h
+	Strings []string `smbios:"-"`
+}
+
+// NewOEMStrings parses a generic Table into OEMStrings.
+func NewOEMStrings(t *Table) (*OEMStrings, error) {
+	if t.Type != TableTypeOEMStrings {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x5 {
+		return nil, errors.New("required fields missing")
+	}
+	ot := &OEMStrings{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ot); err != nil {
+		return nil, err
+	}
+	for i := 1; i <= int(ot.Count); i++ {
+		s, _ := t.getString(uint8(i))
+		ot.Strings = append(ot.Strings, s)
+	}
+	return ot, nil
+}
+
+func (ot *OEMStrings) String() string {
+	lines := []string{
+		ot.Header.String(),
+	}
+	for i, s := range ot.Strings {
+		lines = append(lines, fmt.Sprintf("String %d: %s", i+1, s))
+	}
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemConfigurationOptions is defined in DSP0134 7.13.
+type SystemConfigurationOptions struct {
+	Table
+	Count   uint8    // 04h
+	Options []string `smbios:"-"`
+}
+
+// NewSystemConfigurationOptions parses a generic Table into SystemConfigurationOptions.
+func NewSystemConfigurationOptions(t *Table) (*SystemConfigurationOptions, error) {
+	if t.Type != TableTypeSystemConfigurationOptions {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x5 {
+		return nil, errors.New("required fields missing")
+	}
+	co := &SystemConfigurationOptions{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, co); err != nil {
+		return nil, err
+	}
+	for i := 1; i <= int(co.Count); i++ {
+		s, _ := t.getString(uint8(i))
+		co.Options = append(co.Options, s)
+	}
+	return co, nil
+}
+
+func (co *SystemConfigurationOptions) String() string {
+	lines := []string{
+		co.Header.String(),
+	}
+	for i, s := range co.Options {
+		lines = append(lines, fmt.Sprintf("Option %d: %s", i+1, s))
+	}
+	return strings.Join(lines, "\n\t")
+}
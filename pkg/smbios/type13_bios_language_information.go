@@ -0,0 +1,58 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BIOSLanguageInfo is defined in DSP0134 7.14.
+type BIOSLanguageInfo struct {
+	Table
+	InstallableLanguages uint8    // 04h
+	Flags                uint8    // 05h
+	CurrentLanguage      string   `smbios:"skip=15"` // 15h
+	Languages            []string `smbios:"-"`
+}
+
+// NewBIOSLanguageInfo parses a generic Table into BIOSLanguageInfo.
+func NewBIOSLanguageInfo(t *Table) (*BIOSLanguageInfo, error) {
+	if t.Type != TableTypeBIOSLanguageInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x16 {
+		return nil, errors.New("required fields missing")
+	}
+	li := &BIOSLanguageInfo{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, li); err != nil {
+		return nil, err
+	}
+	for i := 1; i <= int(li.InstallableLanguages); i++ {
+		s, _ := t.getString(uint8(i))
+		li.Languages = append(li.Languages, s)
+	}
+	return li, nil
+}
+
+func (li *BIOSLanguageInfo) String() string {
+	// Bit 0 of Flags selects the abbreviated format, e.g. "enUS"
+	// instead of "en|US|iso8859-1".
+	format := "Long"
+	if li.Flags&1 != 0 {
+		format = "Abbreviated"
+	}
+	lines := []string{
+		li.Header.String(),
+		fmt.Sprintf("Language Description Format: %s", format),
+		fmt.Sprintf("Installable Languages: %d", li.InstallableLanguages),
+	}
+	for _, l := range li.Languages {
+		lines = append(lines, "\t"+l)
+	}
+	lines = append(lines, fmt.Sprintf("Currently Installed Language: %s", li.CurrentLanguage))
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GroupAssociations is defined in DSP0134 7.15.
+type GroupAssociations struct {
+	Table
+	GroupName string      // 04h
+	Items     []GroupItem `smbios:"-"` // 05h
+}
+
+// GroupItem is a member of GroupAssociations.
+type GroupItem struct {
+	Type   TableType // 00h
+	Handle uint16    // 01h
+}
+
+// NewGroupAssociations parses a generic Table into GroupAssociations.
+func NewGroupAssociations(t *Table) (*GroupAssociations, error) {
+	if t.Type != TableTypeGroupAssociations {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x5 {
+		return nil, errors.New("required fields missing")
+	}
+	ga := &GroupAssociations{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, ga)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < (t.Len()-5)/3; i++ {
+		var gi GroupItem
+		if off, err = parseStruct(t, off, true /* complete */, &gi); err != nil {
+			return nil, err
+		}
+		ga.Items = append(ga.Items, gi)
+	}
+	return ga, nil
+}
+
+func (ga *GroupAssociations) String() string {
+	lines := []string{
+		ga.Header.String(),
+		fmt.Sprintf("Name: %s", ga.GroupName),
+		fmt.Sprintf("Items: %d", len(ga.Items)),
+	}
+	for _, gi := range ga.Items {
+		lines = append(lines, fmt.Sprintf("\t0x%04X (%s)", gi.Handle, structureTypeName(gi.Type)))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// structureTypeName returns the short name dmidecode(8) uses for a structure type.
+func structureTypeName(t TableType) string {
+	names := []string{
+		"BIOS",
+		"System",
+		"Base Board",
+		"Chassis",
+		"Processor",
+		"Memory Controller",
+		"Memory Module",
+		"Cache",
+		"Port Connector",
+		"System Slots",
+		"On Board Devices",
+		"OEM Strings",
+		"System Configuration Options",
+		"BIOS Language",
+		"Group Associations",
+		"System Event Log",
+		"Physical Memory Array",
+		"Memory Device",
+		"32-bit Memory Error",
+		"Memory Array Mapped Address",
+		"Memory Device Mapped Address",
+		"Built-in Pointing Device",
+		"Portable Battery",
+		"System Reset",
+		"Hardware Security",
+		"System Power Controls",
+		"Voltage Probe",
+		"Cooling Device",
+		"Temperature Probe",
+		"Electrical Current Probe",
+		"Out-of-band Remote Access",
+		"Boot Integrity Services",
+		"System Boot",
+		"64-bit Memory Error",
+		"Management Device",
+		"Management Device Component",
+		"Management Device Threshold Data",
+		"Memory Channel",
+		"IPMI Device",
+		"Power Supply",
+		"Additional Information",
+		"Onboard Device",
+		"Management Controller Host Interface",
+		"TPM Device",
+		"Processor Additional Information",
+	}
+	switch {
+	case int(t) < len(names):
+		return names[t]
+	case t >= 0x80:
+		return "OEM-specific"
+	}
+	return outOfSpec
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Much of this is auto-generated. If adding a new type, see README for instructions.
+
+// PhysicalMemoryArray is defined in DSP0134 7.17.
+type PhysicalMemoryArray struct {
+	Table
+	Location                MemoryArrayLocation        // 04h
+	Use                     MemoryArrayUse             // 05h
+	ErrorCorrection         MemoryArrayErrorCorrection // 06h
+	MaximumCapacity         uint32                     // 07h
+	ErrorInfoHandle         uint16                     // 0Bh
+	NumberOfMemoryDevices   uint16                     // 0Dh
+	ExtendedMaximumCapacity uint64                     // 0Fh
+}
+
+// NewPhysicalMemoryArray parses a generic Table into PhysicalMemoryArray.
+func NewPhysicalMemoryArray(t *Table) (*PhysicalMemoryArray, error) {
+	if t.Type != TableTypePhysicalMemoryArray {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xf {
+		return nil, errors.New("required fields missing")
+	}
+	ma := &PhysicalMemoryArray{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ma); err != nil {
+		return nil, err
+	}
+	return ma, nil
+}
+
+// GetMaximumCapacityBytes returns the maximum memory capacity of the array,
+// in bytes, or 0 if it is unknown.
+func (ma *PhysicalMemoryArray) GetMaximumCapacityBytes() uint64 {
+	if ma.MaximumCapacity != 0x80000000 {
+		return uint64(ma.MaximumCapacity) * 1024
+	}
+	if ma.Len() >= 0x17 {
+		return ma.ExtendedMaximumCapacity
+	}
+	return 0
+}
+
+func (ma *PhysicalMemoryArray) String() string {
+	capStr := "Unknown"
+	if c := ma.GetMaximumCapacityBytes(); c != 0 {
+		capStr = kmgt(c)
+	}
+	ehStr := ""
+	switch ma.ErrorInfoHandle {
+	case 0xffff:
+		ehStr = "No Error"
+	case 0xfffe:
+		ehStr = "Not Provided"
+	default:
+		ehStr = fmt.Sprintf("0x%04X", ma.ErrorInfoHandle)
+	}
+	lines := []string{
+		ma.Header.String(),
+		fmt.Sprintf("Location: %s", ma.Location),
+		fmt.Sprintf("Use: %s", ma.Use),
+		fmt.Sprintf("Error Correction Type: %s", ma.ErrorCorrection),
+		fmt.Sprintf("Maximum Capacity: %s", capStr),
+		fmt.Sprintf("Error Information Handle: %s", ehStr),
+		fmt.Sprintf("Number Of Devices: %d", ma.NumberOfMemoryDevices),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// MemoryArrayLocation is defined in DSP0134 7.17.1.
+type MemoryArrayLocation uint8
+
+// MemoryArrayLocation values are defined in DSP0134 7.17.1.
+const (
+	MemoryArrayLocationOther                    MemoryArrayLocation = 0x01 // Other
+	MemoryArrayLocationUnknown                  MemoryArrayLocation = 0x02 // Unknown
+	MemoryArrayLocationSystemBoardOrMotherboard MemoryArrayLocation = 0x03 // System board or motherboard
+	MemoryArrayLocationISAAddOnCard             MemoryArrayLocation = 0x04 // ISA add-on card
+	MemoryArrayLocationEISAAddOnCard            MemoryArrayLocation = 0x05 // EISA add-on card
+	MemoryArrayLocationPCIAddOnCard             MemoryArrayLocation = 0x06 // PCI add-on card
+	MemoryArrayLocationMCAAddOnCard             MemoryArrayLocation = 0x07 // MCA add-on card
+	MemoryArrayLocationPCMCIAAddOnCard          MemoryArrayLocation = 0x08 // PCMCIA add-on card
+	MemoryArrayLocationProprietaryAddOnCard     MemoryArrayLocation = 0x09 // Proprietary add-on card
+	MemoryArrayLocationNuBus                    MemoryArrayLocation = 0x0a // NuBus
+	MemoryArrayLocationPC98C20AddOnCard         MemoryArrayLocation = 0xa0 // PC-98/C20 add-on card
+	MemoryArrayLocationPC98C24AddOnCard         MemoryArrayLocation = 0xa1 // PC-98/C24 add-on card
+	MemoryArrayLocationPC98EAddOnCard           MemoryArrayLocation = 0xa2 // PC-98/E add-on card
+	MemoryArrayLocationPC98LocalBusAddOnCard    MemoryArrayLocation = 0xa3 // PC-98/Local bus add-on card
+)
+
+func (v MemoryArrayLocation) String() string {
+	names := map[MemoryArrayLocation]string{
+		MemoryArrayLocationOther:                    "Other",
+		MemoryArrayLocationUnknown:                  "Unknown",
+		MemoryArrayLocationSystemBoardOrMotherboard: "System Board Or Motherboard",
+		MemoryArrayLocationISAAddOnCard:             "ISA Add-on Card",
+		MemoryArrayLocationEISAAddOnCard:            "EISA Add-on Card",
+		MemoryArrayLocationPCIAddOnCard:             "PCI Add-on Card",
+		MemoryArrayLocationMCAAddOnCard:             "MCA Add-on Card",
+		MemoryArrayLocationPCMCIAAddOnCard:          "PCMCIA Add-on Card",
+		MemoryArrayLocationProprietaryAddOnCard:     "Proprietary Add-on Card",
+		MemoryArrayLocationNuBus:                    "NuBus",
+		MemoryArrayLocationPC98C20AddOnCard:         "PC-98/C20 Add-on Card",
+		MemoryArrayLocationPC98C24AddOnCard:         "PC-98/C24 Add-on Card",
+		MemoryArrayLocationPC98EAddOnCard:           "PC-98/E Add-on Card",
+		MemoryArrayLocationPC98LocalBusAddOnCard:    "PC-98/Local Bus Add-on Card",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryArrayUse is defined in DSP0134 7.17.2.
+type MemoryArrayUse uint8
+
+// MemoryArrayUse values are defined in DSP0134 7.17.2.
+const (
+	MemoryArrayUseOther          MemoryArrayUse = 0x01 // Other
+	MemoryArrayUseUnknown        MemoryArrayUse = 0x02 // Unknown
+	MemoryArrayUseSystemMemory   MemoryArrayUse = 0x03 // System memory
+	MemoryArrayUseVideoMemory    MemoryArrayUse = 0x04 // Video memory
+	MemoryArrayUseFlashMemory    MemoryArrayUse = 0x05 // Flash memory
+	MemoryArrayUseNonVolatileRAM MemoryArrayUse = 0x06 // Non-volatile RAM
+	MemoryArrayUseCacheMemory    MemoryArrayUse = 0x07 // Cache memory
+)
+
+func (v MemoryArrayUse) String() string {
+	names := map[MemoryArrayUse]string{
+		MemoryArrayUseOther:          "Other",
+		MemoryArrayUseUnknown:        "Unknown",
+		MemoryArrayUseSystemMemory:   "System Memory",
+		MemoryArrayUseVideoMemory:    "Video Memory",
+		MemoryArrayUseFlashMemory:    "Flash Memory",
+		MemoryArrayUseNonVolatileRAM: "Non-volatile RAM",
+		MemoryArrayUseCacheMemory:    "Cache Memory",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryArrayErrorCorrection is defined in DSP0134 7.17.3.
+type MemoryArrayErrorCorrection uint8
+
+// MemoryArrayErrorCorrection values are defined in DSP0134 7.17.3.
+const (
+	MemoryArrayErrorCorrectionOther        MemoryArrayErrorCorrection = 0x01 // Other
+	MemoryArrayErrorCorrectionUnknown      MemoryArrayErrorCorrection = 0x02 // Unknown
+	MemoryArrayErrorCorrectionNone         MemoryArrayErrorCorrection = 0x03 // None
+	MemoryArrayErrorCorrectionParity       MemoryArrayErrorCorrection = 0x04 // Parity
+	MemoryArrayErrorCorrectionSingleBitECC MemoryArrayErrorCorrection = 0x05 // Single-bit ECC
+	MemoryArrayErrorCorrectionMultiBitECC  MemoryArrayErrorCorrection = 0x06 // Multi-bit ECC
+	MemoryArrayErrorCorrectionCRC          MemoryArrayErrorCorrection = 0x07 // CRC
+)
+
+func (v MemoryArrayErrorCorrection) String() string {
+	names := map[MemoryArrayErrorCorrection]string{
+		MemoryArrayErrorCorrectionOther:        "Other",
+		MemoryArrayErrorCorrectionUnknown:      "Unknown",
+		MemoryArrayErrorCorrectionNone:         "None",
+		MemoryArrayErrorCorrectionParity:       "Parity",
+		MemoryArrayErrorCorrectionSingleBitECC: "Single-bit ECC",
+		MemoryArrayErrorCorrectionMultiBitECC:  "Multi-bit ECC",
+		MemoryArrayErrorCorrectionCRC:          "CRC",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryErrorInfo32 is defined in DSP0134 7.19.
+type MemoryErrorInfo32 struct {
+	Table
+	ErrorType               MemoryErrorType        // 04h
+	ErrorGranularity        MemoryErrorGranularity // 05h
+	ErrorOperation          MemoryErrorOperation   // 06h
+	VendorSyndrome          uint32                 // 07h
+	MemoryArrayErrorAddress uint32                 // 0Bh
+	DeviceErrorAddress      uint32                 // 0Fh
+	ErrorResolution         uint32                 // 13h
+}
+
+// NewMemoryErrorInfo32 parses a generic Table into MemoryErrorInfo32.
+func NewMemoryErrorInfo32(t *Table) (*MemoryErrorInfo32, error) {
+	if t.Type != TableTypeMemoryErrorInfo32 {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x17 {
+		return nil, errors.New("required fields missing")
+	}
+	ei := &MemoryErrorInfo32{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ei); err != nil {
+		return nil, err
+	}
+	return ei, nil
+}
+
+func (ei *MemoryErrorInfo32) String() string {
+	// 0x80000000 means unknown.
+	addrStr := func(v uint32) string {
+		if v == 0x80000000 {
+			return "Unknown"
+		}
+		return fmt.Sprintf("0x%08X", v)
+	}
+	syndrome := "Unknown"
+	if ei.VendorSyndrome != 0 {
+		syndrome = fmt.Sprintf("0x%08X", ei.VendorSyndrome)
+	}
+	lines := []string{
+		ei.Header.String(),
+		fmt.Sprintf("Type: %s", ei.ErrorType),
+		fmt.Sprintf("Granularity: %s", ei.ErrorGranularity),
+		fmt.Sprintf("Operation: %s", ei.ErrorOperation),
+		fmt.Sprintf("Vendor Syndrome: %s", syndrome),
+		fmt.Sprintf("Memory Array Address: %s", addrStr(ei.MemoryArrayErrorAddress)),
+		fmt.Sprintf("Device Address: %s", addrStr(ei.DeviceErrorAddress)),
+		fmt.Sprintf("Resolution: %s", addrStr(ei.ErrorResolution)),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// MemoryErrorType is defined in DSP0134 7.19.1.
+type MemoryErrorType uint8
+
+// MemoryErrorType values are defined in DSP0134 7.19.1.
+const (
+	MemoryErrorTypeOther                   MemoryErrorType = 0x01 // Other
+	MemoryErrorTypeUnknown                 MemoryErrorType = 0x02 // Unknown
+	MemoryErrorTypeOK                      MemoryErrorType = 0x03 // OK
+	MemoryErrorTypeBadRead                 MemoryErrorType = 0x04 // Bad read
+	MemoryErrorTypeParityError             MemoryErrorType = 0x05 // Parity error
+	MemoryErrorTypeSingleBitError          MemoryErrorType = 0x06 // Single-bit error
+	MemoryErrorTypeDoubleBitError          MemoryErrorType = 0x07 // Double-bit error
+	MemoryErrorTypeMultiBitError           MemoryErrorType = 0x08 // Multi-bit error
+	MemoryErrorTypeNibbleError             MemoryErrorType = 0x09 // Nibble error
+	MemoryErrorTypeChecksumError           MemoryErrorType = 0x0a // Checksum error
+	MemoryErrorTypeCRCError                MemoryErrorType = 0x0b // CRC error
+	MemoryErrorTypeCorrectedSingleBitError MemoryErrorType = 0x0c // Corrected single-bit error
+	MemoryErrorTypeCorrectedError          MemoryErrorType = 0x0d // Corrected error
+	MemoryErrorTypeUncorrectableError      MemoryErrorType = 0x0e // Uncorrectable error
+)
+
+func (v MemoryErrorType) String() string {
+	names := map[MemoryErrorType]string{
+		MemoryErrorTypeOther:                   "Other",
+		MemoryErrorTypeUnknown:                 "Unknown",
+		MemoryErrorTypeOK:                      "OK",
+		MemoryErrorTypeBadRead:                 "Bad Read",
+		MemoryErrorTypeParityError:             "Parity Error",
+		MemoryErrorTypeSingleBitError:          "Single-bit Error",
+		MemoryErrorTypeDoubleBitError:          "Double-bit Error",
+		MemoryErrorTypeMultiBitError:           "Multi-bit Error",
+		MemoryErrorTypeNibbleError:             "Nibble Error",
+		MemoryErrorTypeChecksumError:           "Checksum Error",
+		MemoryErrorTypeCRCError:                "CRC Error",
+		MemoryErrorTypeCorrectedSingleBitError: "Corrected Single-bit Error",
+		MemoryErrorTypeCorrectedError:          "Corrected Error",
+		MemoryErrorTypeUncorrectableError:      "Uncorrectable Error",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryErrorGranularity is defined in DSP0134 7.19.2.
+type MemoryErrorGranularity uint8
+
+// MemoryErrorGranularity values are defined in DSP0134 7.19.2.
+const (
+	MemoryErrorGranularityOther                MemoryErrorGranularity = 0x01 // Other
+	MemoryErrorGranularityUnknown              MemoryErrorGranularity = 0x02 // Unknown
+	MemoryErrorGranularityDeviceLevel          MemoryErrorGranularity = 0x03 // Device level
+	MemoryErrorGranularityMemoryPartitionLevel MemoryErrorGranularity = 0x04 // Memory partition level
+)
+
+func (v MemoryErrorGranularity) String() string {
+	names := map[MemoryErrorGranularity]string{
+		MemoryErrorGranularityOther:                "Other",
+		MemoryErrorGranularityUnknown:              "Unknown",
+		MemoryErrorGranularityDeviceLevel:          "Device Level",
+		MemoryErrorGranularityMemoryPartitionLevel: "Memory Partition Level",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryErrorOperation is defined in DSP0134 7.19.3.
+type MemoryErrorOperation uint8
+
+// MemoryErrorOperation values are defined in DSP0134 7.19.3.
+const (
+	MemoryErrorOperationOther        MemoryErrorOperation = 0x01 // Other
+	MemoryErrorOperationUnknown      MemoryErrorOperation = 0x02 // Unknown
+	MemoryErrorOperationRead         MemoryErrorOperation = 0x03 // Read
+	MemoryErrorOperationWrite        MemoryErrorOperation = 0x04 // Write
+	MemoryErrorOperationPartialWrite MemoryErrorOperation = 0x05 // Partial write
+)
+
+func (v MemoryErrorOperation) String() string {
+	names := map[MemoryErrorOperation]string{
+		MemoryErrorOperationOther:        "Other",
+		MemoryErrorOperationUnknown:      "Unknown",
+		MemoryErrorOperationRead:         "Read",
+		MemoryErrorOperationWrite:        "Write",
+		MemoryErrorOperationPartialWrite: "Partial Write",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
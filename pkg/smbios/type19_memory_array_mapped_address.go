@@ -0,0 +1,93 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryArrayMappedAddress is defined in DSP0134 7.20.
+type MemoryArrayMappedAddress struct {
+	Table
+	StartingAddress         uint32 // 04h
+	EndingAddress           uint32 // 08h
+	MemoryArrayHandle       uint16 // 0Ch
+	PartitionWidth          uint8  // 0Eh
+	ExtendedStartingAddress uint64 // 0Fh
+	ExtendedEndingAddress   uint64 // 17h
+}
+
+// NewMemoryArrayMappedAddress parses a generic Table into MemoryArrayMappedAddress.
+func NewMemoryArrayMappedAddress(t *Table) (*MemoryArrayMappedAddress, error) {
+	if t.Type != TableTypeMemoryArrayMappedAddress {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xf {
+		return nil, errors.New("required fields missing")
+	}
+	ma := &MemoryArrayMappedAddress{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ma); err != nil {
+		return nil, err
+	}
+	return ma, nil
+}
+
+// GetStartingAddress returns the physical address of the first byte of the range.
+func (ma *MemoryArrayMappedAddress) GetStartingAddress() uint64 {
+	return mappedAddress(ma.StartingAddress, ma.ExtendedStartingAddress, ma.Len() >= 0x1f, 0)
+}
+
+// GetEndingAddress returns the physical address of the last byte of the range.
+func (ma *MemoryArrayMappedAddress) GetEndingAddress() uint64 {
+	return mappedAddress(ma.EndingAddress, ma.ExtendedEndingAddress, ma.Len() >= 0x1f, 0x3ff)
+}
+
+func (ma *MemoryArrayMappedAddress) String() string {
+	lines := []string{
+		ma.Header.String(),
+	}
+	lines = append(lines, mappedAddressLines(ma.StartingAddress, ma.EndingAddress, ma.ExtendedStartingAddress, ma.ExtendedEndingAddress, ma.Len() >= 0x1f)...)
+	lines = append(lines,
+		fmt.Sprintf("Physical Array Handle: 0x%04X", ma.MemoryArrayHandle),
+		fmt.Sprintf("Partition Width: %d", ma.PartitionWidth),
+	)
+	return strings.Join(lines, "\n\t")
+}
+
+// mappedAddress returns the address in bytes of a mapped address range
+// boundary, which is given in kB unless the extended address is used.
+// lowBits are the bits below 1 kB.
+func mappedAddress(addr uint32, extAddr uint64, haveExt bool, lowBits uint64) uint64 {
+	if haveExt && addr == 0xffffffff {
+		return extAddr
+	}
+	return uint64(addr)<<10 | lowBits
+}
+
+// mappedAddressLines returns the address and size lines of types 19 and 20.
+func mappedAddressLines(start, end uint32, extStart, extEnd uint64, haveExt bool) []string {
+	if haveExt && start == 0xffffffff {
+		size := "Invalid"
+		if extStart != extEnd {
+			size = kmgt(extEnd - extStart + 1)
+		}
+		return []string{
+			fmt.Sprintf("Starting Address: 0x%016X", extStart),
+			fmt.Sprintf("Ending Address: 0x%016X", extEnd),
+			fmt.Sprintf("Range Size: %s", size),
+		}
+	}
+	size := "Invalid"
+	if end-start+1 != 0 {
+		size = kmgt(uint64(end-start+1) * 1024)
+	}
+	return []string{
+		fmt.Sprintf("Starting Address: 0x%08X%03X", start>>2, (start&3)<<10),
+		fmt.Sprintf("Ending Address: 0x%08X%03X", end>>2, (end&3)<<10+0x3ff),
+		fmt.Sprintf("Range Size: %s", size),
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryDeviceMappedAddress is defined in DSP0134 7.21.
+type MemoryDeviceMappedAddress struct {
+	Table
+	StartingAddress                uint32 // 04h
+	EndingAddress                  uint32 // 08h
+	MemoryDeviceHandle             uint16 // 0Ch
+	MemoryArrayMappedAddressHandle uint16 // 0Eh
+	PartitionRowPosition           uint8  // 10h
+	InterleavePosition             uint8  // 11h
+	InterleavedDataDepth           uint8  // 12h
+	ExtendedStartingAddress        uint64 // 13h
+	ExtendedEndingAddress          uint64 // 1Bh
+}
+
+// NewMemoryDeviceMappedAddress parses a generic Table into MemoryDeviceMappedAddress.
+func NewMemoryDeviceMappedAddress(t *Table) (*MemoryDeviceMappedAddress, error) {
+	if t.Type != TableTypeMemoryDeviceMappedAddress {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x13 {
+		return nil, errors.New("required fields missing")
+	}
+	da := &MemoryDeviceMappedAddress{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, da); err != nil {
+		return nil, err
+	}
+	return da, nil
+}
+
+// GetStartingAddress returns the physical address of the first byte of the range.
+func (da *MemoryDeviceMappedAddress) GetStartingAddress() uint64 {
+	return mappedAddress(da.StartingAddress, da.ExtendedStartingAddress, da.Len() >= 0x23, 0)
+}
+
+// GetEndingAddress returns the physical address of the last byte of the range.
+func (da *MemoryDeviceMappedAddress) GetEndingAddress() uint64 {
+	return mappedAddress(da.EndingAddress, da.ExtendedEndingAddress, da.Len() >= 0x23, 0x3ff)
+}
+
+func (da *MemoryDeviceMappedAddress) String() string {
+	lines := []string{
+		da.Header.String(),
+	}
+	lines = append(lines, mappedAddressLines(da.StartingAddress, da.EndingAddress, da.ExtendedStartingAddress, da.ExtendedEndingAddress, da.Len() >= 0x23)...)
+	lines = append(lines,
+		fmt.Sprintf("Physical Device Handle: 0x%04X", da.MemoryDeviceHandle),
+		fmt.Sprintf("Memory Array Mapped Address Handle: 0x%04X", da.MemoryArrayMappedAddressHandle),
+	)
+	switch da.PartitionRowPosition {
+	case 0:
+		lines = append(lines, fmt.Sprintf("Partition Row Position: %s", outOfSpec))
+	case 0xff:
+		lines = append(lines, "Partition Row Position: Unknown")
+	default:
+		lines = append(lines, fmt.Sprintf("Partition Row Position: %d", da.PartitionRowPosition))
+	}
+	// Neither is printed if the device is not interleaved.
+	for _, f := range []struct {
+		name string
+		v    uint8
+	}{
+		{"Interleave Position", da.InterleavePosition},
+		{"Interleaved Data Depth", da.InterleavedDataDepth},
+	} {
+		switch f.v {
+		case 0:
+		case 0xff:
+			lines = append(lines, fmt.Sprintf("%s: Unknown", f.name))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: %d", f.name, f.v))
+		}
+	}
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PortableBattery is defined in DSP0134 7.23.
+type PortableBattery struct {
+	Table
+	Location                  string                 // 04h
+	Manufacturer              string                 // 05h
+	ManufactureDate           string                 // 06h
+	SerialNumber              string                 // 07h
+	DeviceName                string                 // 08h
+	DeviceChemistry           BatteryDeviceChemistry // 09h
+	DesignCapacity            uint16                 // 0Ah
+	DesignVoltage             uint16                 // 0Ch
+	SBDSVersionNumber         string                 // 0Eh
+	MaximumErrorInBatteryData uint8                  // 0Fh
+	SBDSSerialNumber          uint16                 // 10h
+	SBDSManufactureDate       uint16                 // 12h
+	SBDSDeviceChemistry       string                 // 14h
+	DesignCapacityMultiplier  uint8                  // 15h
+	OEMSpecific               uint32                 // 16h
+}
+
+// NewPortableBattery parses a generic Table into PortableBattery.
+func NewPortableBattery(t *Table) (*PortableBattery, error) {
+	if t.Type != TableTypePortableBattery {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x10 {
+		return nil, errors.New("required fields missing")
+	}
+	pb := &PortableBattery{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, pb); err != nil {
+		return nil, err
+	}
+	return pb, nil
+}
+
+// GetDesignCapacityMWh returns the design capacity of the battery in mWh,
+// or 0 if it is unknown.
+func (pb *PortableBattery) GetDesignCapacityMWh() uint32 {
+	if pb.Len() < 0x16 {
+		return uint32(pb.DesignCapacity)
+	}
+	return uint32(pb.DesignCapacity) * uint32(pb.DesignCapacityMultiplier)
+}
+
+func (pb *PortableBattery) String() string {
+	// The SBDS fields are used in place of strings that are not
+	// specified and of the Unknown chemistry.
+	sbds := pb.Len() >= 0x1a
+	noDate, noSerial := pb.stringUnset(0x06), pb.stringUnset(0x07)
+	lines := []string{
+		pb.Header.String(),
+		fmt.Sprintf("Location: %s", pb.Location),
+		fmt.Sprintf("Manufacturer: %s", pb.Manufacturer),
+	}
+	if !noDate || !sbds {
+		lines = append(lines, fmt.Sprintf("Manufacture Date: %s", pb.ManufactureDate))
+	}
+	if !noSerial || !sbds {
+		lines = append(lines, fmt.Sprintf("Serial Number: %s", pb.SerialNumber))
+	}
+	lines = append(lines, fmt.Sprintf("Name: %s", pb.DeviceName))
+	if pb.DeviceChemistry != BatteryDeviceChemistryUnknown || !sbds {
+		lines = append(lines, fmt.Sprintf("Chemistry: %s", pb.DeviceChemistry))
+	}
+	capStr, voltStr, errStr := "Unknown", "Unknown", "Unknown"
+	if c := pb.GetDesignCapacityMWh(); c != 0 {
+		capStr = fmt.Sprintf("%d mWh", c)
+	}
+	if pb.DesignVoltage != 0 {
+		voltStr = fmt.Sprintf("%d mV", pb.DesignVoltage)
+	}
+	if pb.MaximumErrorInBatteryData != 0xff {
+		errStr = fmt.Sprintf("%d%%", pb.MaximumErrorInBatteryData)
+	}
+	lines = append(lines,
+		fmt.Sprintf("Design Capacity: %s", capStr),
+		fmt.Sprintf("Design Voltage: %s", voltStr),
+		fmt.Sprintf("SBDS Version: %s", pb.SBDSVersionNumber),
+		fmt.Sprintf("Maximum Error: %s", errStr),
+	)
+	if !sbds {
+		return strings.Join(lines, "\n\t")
+	}
+	if noSerial {
+		lines = append(lines, fmt.Sprintf("SBDS Serial Number: %04X", pb.SBDSSerialNumber))
+	}
+	if noDate {
+		// Bits 15:9 are the year since 1980, 8:5 the month and 4:0 the day.
+		d := pb.SBDSManufactureDate
+		lines = append(lines, fmt.Sprintf("SBDS Manufacture Date: %d-%02d-%02d", 1980+int(d>>9), (d>>5)&0xf, d&0x1f))
+	}
+	if pb.DeviceChemistry == BatteryDeviceChemistryUnknown {
+		lines = append(lines, fmt.Sprintf("SBDS Chemistry: %s", pb.SBDSDeviceChemistry))
+	}
+	lines = append(lines, fmt.Sprintf("OEM-specific Information: 0x%08X", pb.OEMSpecific))
+	return strings.Join(lines, "\n\t")
+}
+
+// stringUnset returns whether the string at offset is not specified.
+func (pb *PortableBattery) stringUnset(offset int) bool {
+	b, err := pb.GetByteAt(offset)
+	return err == nil && b == 0
+}
+
+// BatteryDeviceChemistry is defined in DSP0134 7.23.1.
+type BatteryDeviceChemistry uint8
+
+// BatteryDeviceChemistry values are defined in DSP0134 7.23.1.
+const (
+	BatteryDeviceChemistryOther              BatteryDeviceChemistry = 0x01 // Other
+	BatteryDeviceChemistryUnknown            BatteryDeviceChemistry = 0x02 // Unknown
+	BatteryDeviceChemistryLeadAcid           BatteryDeviceChemistry = 0x03 // Lead Acid
+	BatteryDeviceChemistryNickelCadmium      BatteryDeviceChemistry = 0x04 // Nickel Cadmium
+	BatteryDeviceChemistryNickelMetalHydride BatteryDeviceChemistry = 0x05 // Nickel metal hydride
+	BatteryDeviceChemistryLithiumIon         BatteryDeviceChemistry = 0x06 // Lithium-ion
+	BatteryDeviceChemistryZincAir            BatteryDeviceChemistry = 0x07 // Zinc air
+	BatteryDeviceChemistryLithiumPolymer     BatteryDeviceChemistry = 0x08 // Lithium Polymer
+)
+
+func (v BatteryDeviceChemistry) String() string {
+	names := map[BatteryDeviceChemistry]string{
+		BatteryDeviceChemistryOther:              "Other",
+		BatteryDeviceChemistryUnknown:            "Unknown",
+		BatteryDeviceChemistryLeadAcid:           "Lead Acid",
+		BatteryDeviceChemistryNickelCadmium:      "Nickel Cadmium",
+		BatteryDeviceChemistryNickelMetalHydride: "Nickel Metal Hydride",
+		BatteryDeviceChemistryLithiumIon:         "Lithium Ion",
+		BatteryDeviceChemistryZincAir:            "Zinc Air",
+		BatteryDeviceChemistryLithiumPolymer:     "Lithium Polymer",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// HardwareSecurity is defined in DSP0134 7.25.
+type HardwareSecurity struct {
+	Table
+	Settings HardwareSecuritySettings // 04h
+}
+
+// NewHardwareSecurity parses a generic Table into HardwareSecurity.
+func NewHardwareSecurity(t *Table) (*HardwareSecurity, error) {
+	if t.Type != TableTypeHardwareSecurity {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x5 {
+		return nil, errors.New("required fields missing")
+	}
+	hs := &HardwareSecurity{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, hs); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+func (hs *HardwareSecurity) String() string {
+	lines := []string{
+		hs.Header.String(),
+		fmt.Sprintf("Power-On Password Status: %s", hs.Settings.PowerOnPassword()),
+		fmt.Sprintf("Keyboard Password Status: %s", hs.Settings.KeyboardPassword()),
+		fmt.Sprintf("Administrator Password Status: %s", hs.Settings.AdministratorPassword()),
+		fmt.Sprintf("Front Panel Reset Status: %s", hs.Settings.FrontPanelReset()),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// HardwareSecuritySettings is defined in DSP0134 7.25.
+type HardwareSecuritySettings uint8
+
+// PowerOnPassword returns the power-on password status, bits 7:6.
+func (v HardwareSecuritySettings) PowerOnPassword() HardwareSecurityStatus {
+	return HardwareSecurityStatus(v>>6) & 3
+}
+
+// KeyboardPassword returns the keyboard password status, bits 5:4.
+func (v HardwareSecuritySettings) KeyboardPassword() HardwareSecurityStatus {
+	return HardwareSecurityStatus(v>>4) & 3
+}
+
+// AdministratorPassword returns the administrator password status, bits 3:2.
+func (v HardwareSecuritySettings) AdministratorPassword() HardwareSecurityStatus {
+	return HardwareSecurityStatus(v>>2) & 3
+}
+
+// FrontPanelReset returns the front panel reset status, bits 1:0.
+func (v HardwareSecuritySettings) FrontPanelReset() HardwareSecurityStatus {
+	return HardwareSecurityStatus(v) & 3
+}
+
+// HardwareSecurityStatus is defined in DSP0134 7.25.
+type HardwareSecurityStatus uint8
+
+// HardwareSecurityStatus values are defined in DSP0134 7.25.
+const (
+	HardwareSecurityStatusDisabled       HardwareSecurityStatus = 0x00 // Disabled
+	HardwareSecurityStatusEnabled        HardwareSecurityStatus = 0x01 // Enabled
+	HardwareSecurityStatusNotImplemented HardwareSecurityStatus = 0x02 // Not Implemented
+	HardwareSecurityStatusUnknown        HardwareSecurityStatus = 0x03 // Unknown
+)
+
+func (v HardwareSecurityStatus) String() string {
+	names := map[HardwareSecurityStatus]string{
+		HardwareSecurityStatusDisabled:       "Disabled",
+		HardwareSecurityStatusEnabled:        "Enabled",
+		HardwareSecurityStatusNotImplemented: "Not Implemented",
+		HardwareSecurityStatusUnknown:        "Unknown",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ManagementDevice is defined in DSP0134 7.35.
+type ManagementDevice struct {
+	Table
+	Description string                      // 04h
+	DeviceType  ManagementDeviceType        // 05h
+	Address     uint32                      // 06h
+	AddressType ManagementDeviceAddressType // 0Ah
+}
+
+// NewManagementDevice parses a generic Table into ManagementDevice.
+func NewManagementDevice(t *Table) (*ManagementDevice, error) {
+	if t.Type != TableTypeManagementDevice {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xb {
+		return nil, errors.New("required fields missing")
+	}
+	md := &ManagementDevice{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, md); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+func (md *ManagementDevice) String() string {
+	lines := []string{
+		md.Header.String(),
+		fmt.Sprintf("Description: %s", md.description()),
+		fmt.Sprintf("Type: %s", md.DeviceType),
+		fmt.Sprintf("Address: 0x%08X", md.Address),
+		fmt.Sprintf("Address Type: %s", md.AddressType),
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// description returns the description as dmidecode(8) shows it. Some
+// Supermicro boards claim 10h bytes for the 0Bh of the structure, so
+// their strings start at 0Bh and what is parsed as the first string lacks
+// its first 5 characters.
+func (md *ManagementDevice) description() string {
+	if index, _ := md.GetByteAt(0x04); md.Length != 0x10 || index != 1 {
+		return md.Description
+	}
+	hidden, err := md.GetBytesAt(0xb, 0x10-0xb)
+	if err != nil {
+		return md.Description
+	}
+	for _, c := range hidden {
+		if c < 0x20 || c > 0x7e {
+			return md.Description
+		}
+	}
+	return string(hidden) + md.Description
+}
+
+// ManagementDeviceType is defined in DSP0134 7.35.1.
+type ManagementDeviceType uint8
+
+// ManagementDeviceType values are defined in DSP0134 7.35.1.
+const (
+	ManagementDeviceTypeOther    ManagementDeviceType = 0x01 // Other
+	ManagementDeviceTypeUnknown  ManagementDeviceType = 0x02 // Unknown
+	ManagementDeviceTypeLM75     ManagementDeviceType = 0x03 // National Semiconductor LM75
+	ManagementDeviceTypeLM78     ManagementDeviceType = 0x04 // National Semiconductor LM78
+	ManagementDeviceTypeLM79     ManagementDeviceType = 0x05 // National Semiconductor LM79
+	ManagementDeviceTypeLM80     ManagementDeviceType = 0x06 // National Semiconductor LM80
+	ManagementDeviceTypeLM81     ManagementDeviceType = 0x07 // National Semiconductor LM81
+	ManagementDeviceTypeADM9240  ManagementDeviceType = 0x08 // Analog Devices ADM9240
+	ManagementDeviceTypeDS1780   ManagementDeviceType = 0x09 // Dallas Semiconductor DS1780
+	ManagementDeviceTypeMAX1617  ManagementDeviceType = 0x0a // Maxim 1617
+	ManagementDeviceTypeGL518SM  ManagementDeviceType = 0x0b // Genesys GL518SM
+	ManagementDeviceTypeW83781D  ManagementDeviceType = 0x0c // Winbond W83781D
+	ManagementDeviceTypeHT82H791 ManagementDeviceType = 0x0d // Holtek HT82H791
+)
+
+func (v ManagementDeviceType) String() string {
+	names := map[ManagementDeviceType]string{
+		ManagementDeviceTypeOther:    "Other",
+		ManagementDeviceTypeUnknown:  "Unknown",
+		ManagementDeviceTypeLM75:     "LM75",
+		ManagementDeviceTypeLM78:     "LM78",
+		ManagementDeviceTypeLM79:     "LM79",
+		ManagementDeviceTypeLM80:     "LM80",
+		ManagementDeviceTypeLM81:     "LM81",
+		ManagementDeviceTypeADM9240:  "ADM9240",
+		ManagementDeviceTypeDS1780:   "DS1780",
+		ManagementDeviceTypeMAX1617:  "MAX1617",
+		ManagementDeviceTypeGL518SM:  "GL518SM",
+		ManagementDeviceTypeW83781D:  "W83781D",
+		ManagementDeviceTypeHT82H791: "HT82H791",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// ManagementDeviceAddressType is defined in DSP0134 7.35.2.
+type ManagementDeviceAddressType uint8
+
+// ManagementDeviceAddressType values are defined in DSP0134 7.35.2.
+const (
+	ManagementDeviceAddressTypeOther   ManagementDeviceAddressType = 0x01 // Other
+	ManagementDeviceAddressTypeUnknown ManagementDeviceAddressType = 0x02 // Unknown
+	ManagementDeviceAddressTypeIOPort  ManagementDeviceAddressType = 0x03 // I/O Port
+	ManagementDeviceAddressTypeMemory  ManagementDeviceAddressType = 0x04 // Memory
+	ManagementDeviceAddressTypeSMBus   ManagementDeviceAddressType = 0x05 // SM Bus
+)
+
+func (v ManagementDeviceAddressType) String() string {
+	names := map[ManagementDeviceAddressType]string{
+		ManagementDeviceAddressTypeOther:   "Other",
+		ManagementDeviceAddressTypeUnknown: "Unknown",
+		ManagementDeviceAddressTypeIOPort:  "I/O Port",
+		ManagementDeviceAddressTypeMemory:  "Memory",
+		ManagementDeviceAddressTypeSMBus:   "SMBus",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
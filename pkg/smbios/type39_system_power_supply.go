@@ -0,0 +1,136 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemPowerSupply is defined in DSP0134 7.40.
+type SystemPowerSupply struct {
+	Table
+	PowerUnitGroup             uint8                      // 04h
+	Location                   string                     // 05h
+	DeviceName                 string                     // 06h
+	Manufacturer               string                     // 07h
+	SerialNumber               string                     // 08h
+	AssetTagNumber             string                     // 09h
+	ModelPartNumber            string                     // 0Ah
+	RevisionLevel              string                     // 0Bh
+	MaxPowerCapacity           uint16                     // 0Ch
+	PowerSupplyCharacteristics PowerSupplyCharacteristics // 0Eh
+	InputVoltageProbeHandle    uint16                     // 10h
+	CoolingDeviceHandle        uint16                     // 12h
+	InputCurrentProbeHandle    uint16                     // 14h
+}
+
+// NewSystemPowerSupply parses a generic Table into SystemPowerSupply.
+func NewSystemPowerSupply(t *Table) (*SystemPowerSupply, error) {
+	if t.Type != TableTypeSystemPowerSupply {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x10 {
+		return nil, errors.New("required fields missing")
+	}
+	ps := &SystemPowerSupply{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, ps); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func (ps *SystemPowerSupply) String() string {
+	lines := []string{
+		ps.Header.String(),
+	}
+	if ps.PowerUnitGroup != 0 {
+		lines = append(lines, fmt.Sprintf("Power Unit Group: %d", ps.PowerUnitGroup))
+	}
+	capStr := "Unknown"
+	if ps.MaxPowerCapacity != 0x8000 {
+		capStr = fmt.Sprintf("%d W", ps.MaxPowerCapacity)
+	}
+	lines = append(lines,
+		fmt.Sprintf("Location: %s", ps.Location),
+		fmt.Sprintf("Name: %s", ps.DeviceName),
+		fmt.Sprintf("Manufacturer: %s", ps.Manufacturer),
+		fmt.Sprintf("Serial Number: %s", ps.SerialNumber),
+		fmt.Sprintf("Asset Tag: %s", ps.AssetTagNumber),
+		fmt.Sprintf("Model Part Number: %s", ps.ModelPartNumber),
+		fmt.Sprintf("Revision: %s", ps.RevisionLevel),
+		fmt.Sprintf("Max Power Capacity: %s", capStr),
+		ps.PowerSupplyCharacteristics.String(),
+	)
+	if ps.Len() >= 0x16 {
+		for _, h := range []struct {
+			name string
+			v    uint16
+		}{
+			{"Input Voltage Probe Handle", ps.InputVoltageProbeHandle},
+			{"Cooling Device Handle", ps.CoolingDeviceHandle},
+			{"Input Current Probe Handle", ps.InputCurrentProbeHandle},
+		} {
+			if h.v != 0xffff {
+				lines = append(lines, fmt.Sprintf("%s: 0x%04X", h.name, h.v))
+			}
+		}
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// PowerSupplyCharacteristics is defined in DSP0134 7.40.1.
+type PowerSupplyCharacteristics uint16
+
+// PowerSupplyCharacteristics flags are defined in DSP0134 7.40.1.
+const (
+	PowerSupplyCharacteristicsHotReplaceable PowerSupplyCharacteristics = 1 << 0 // Power supply is hot-replaceable.
+	PowerSupplyCharacteristicsPresent        PowerSupplyCharacteristics = 1 << 1 // Power supply is present.
+	PowerSupplyCharacteristicsUnplugged      PowerSupplyCharacteristics = 1 << 2 // Power supply is unplugged from the wall.
+)
+
+// Type returns the DMTF power supply type, bits 13:10.
+func (v PowerSupplyCharacteristics) Type() uint8 {
+	return uint8(v>>10) & 0xf
+}
+
+// Status returns the status, bits 9:7.
+func (v PowerSupplyCharacteristics) Status() uint8 {
+	return uint8(v>>7) & 0x7
+}
+
+// InputVoltageRangeSwitching returns the DMTF input voltage range switching, bits 6:3.
+func (v PowerSupplyCharacteristics) InputVoltageRangeSwitching() uint8 {
+	return uint8(v>>3) & 0xf
+}
+
+func (v PowerSupplyCharacteristics) String() string {
+	name := func(names []string, i uint8) string {
+		if i >= 1 && int(i) <= len(names) {
+			return names[i-1]
+		}
+		return outOfSpec
+	}
+	status := "Not Present"
+	if v&PowerSupplyCharacteristicsPresent != 0 {
+		status = "Present, " + name([]string{"Other", "Unknown", "OK", "Non-critical", "Critical"}, v.Status())
+	}
+	plugged, hotReplaceable := "Yes", "No"
+	if v&PowerSupplyCharacteristicsUnplugged != 0 {
+		plugged = "No"
+	}
+	if v&PowerSupplyCharacteristicsHotReplaceable != 0 {
+		hotReplaceable = "Yes"
+	}
+	lines := []string{
+		fmt.Sprintf("Status: %s", status),
+		fmt.Sprintf("Type: %s", name([]string{"Other", "Unknown", "Linear", "Switching", "Battery", "UPS", "Converter", "Regulator"}, v.Type())),
+		fmt.Sprintf("Input Voltage Range Switching: %s", name([]string{"Other", "Unknown", "Manual", "Auto-switch", "Wide Range", "N/A"}, v.InputVoltageRangeSwitching())),
+		fmt.Sprintf("Plugged: %s", plugged),
+		fmt.Sprintf("Hot Replaceable: %s", hotReplaceable),
+	}
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OnboardDevicesExtendedInfo is defined in DSP0134 7.42.
+type OnboardDevicesExtendedInfo struct {
+	Table
+	ReferenceDesignation string            // 04h
+	DeviceType           OnboardDeviceType // 05h
+	DeviceTypeInstance   uint8             // 06h
+	SegmentGroupNumber   uint16            // 07h
+	BusNumber            uint8             // 09h
+	DeviceFunctionNumber uint8             // 0Ah
+}
+
+// NewOnboardDevicesExtendedInfo parses a generic Table into OnboardDevicesExtendedInfo.
+func NewOnboardDevicesExtendedInfo(t *Table) (*OnboardDevicesExtendedInfo, error) {
+	if t.Type != TableTypeOnboardDevicesExtendedInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xb {
+		return nil, errors.New("required fields missing")
+	}
+	di := &OnboardDevicesExtendedInfo{Table: *t}
+	if _, err := parseStruct(t, 0 /* off */, false /* complete */, di); err != nil {
+		return nil, err
+	}
+	return di, nil
+}
+
+func (di *OnboardDevicesExtendedInfo) String() string {
+	lines := []string{
+		di.Header.String(),
+		fmt.Sprintf("Reference Designation: %s", di.ReferenceDesignation),
+		fmt.Sprintf("Type: %s", di.DeviceType),
+		fmt.Sprintf("Status: %s", di.DeviceType.status()),
+		fmt.Sprintf("Type Instance: %d", di.DeviceTypeInstance),
+	}
+	if s := busAddress(di.SegmentGroupNumber, di.BusNumber, di.DeviceFunctionNumber); s != "" {
+		lines = append(lines, s)
+	}
+	return strings.Join(lines, "\n\t")
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ProcessorAdditionalInfo is defined in DSP0134 7.45.
+type ProcessorAdditionalInfo struct {
+	Table
+	ReferencedHandle uint16                    // 04h
+	BlockLength      uint8                     // 06h
+	ProcessorType    ProcessorArchitectureType // 07h
+	Data             []byte                    `smbios:"-"` // 08h
+}
+
+// NewProcessorAdditionalInfo parses a generic Table into ProcessorAdditionalInfo.
+func NewProcessorAdditionalInfo(t *Table) (*ProcessorAdditionalInfo, error) {
+	if t.Type != TableTypeProcessorAdditionalInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0x8 {
+		return nil, errors.New("required fields missing")
+	}
+	pi := &ProcessorAdditionalInfo{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, pi)
+	if err != nil {
+		return nil, err
+	}
+	// The block length includes its own two byte header.
+	if pi.BlockLength < 2 {
+		return nil, fmt.Errorf("invalid block length %d", pi.BlockLength)
+	}
+	if pi.Data, err = t.GetBytesAt(off, int(pi.BlockLength)-2); err != nil {
+		return nil, err
+	}
+	return pi, nil
+}
+
+func (pi *ProcessorAdditionalInfo) String() string {
+	lines := []string{
+		pi.Header.String(),
+		fmt.Sprintf("Referenced Handle: 0x%04X", pi.ReferencedHandle),
+		fmt.Sprintf("Block Length: %d", pi.BlockLength),
+		fmt.Sprintf("Processor Type: %s", pi.ProcessorType),
+	}
+	if len(pi.Data) > 0 {
+		lines = append(lines, "Processor-specific Data:")
+		for data := pi.Data; len(data) > 0; {
+			ld := data
+			if len(ld) > 16 {
+				ld = ld[:16]
+			}
+			ls := make([]string, len(ld))
+			for i, d := range ld {
+				ls[i] = fmt.Sprintf("%02X", d)
+			}
+			lines = append(lines, "\t"+strings.Join(ls, " "))
+			data = data[len(ld):]
+		}
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// ProcessorArchitectureType is defined in DSP0134 7.45.2.
+type ProcessorArchitectureType uint8
+
+// ProcessorArchitectureType values are defined in DSP0134 7.45.2.
+const (
+	ProcessorArchitectureTypeReserved ProcessorArchitectureType = 0x00 // Reserved
+	ProcessorArchitectureTypeIA32     ProcessorArchitectureType = 0x01 // IA32 (x86)
+	ProcessorArchitectureTypeX64      ProcessorArchitectureType = 0x02 // x64 (x86-64, Intel64, AMD64, EM64T)
+	ProcessorArchitectureTypeItanium  ProcessorArchitectureType = 0x03 // Intel Itanium architecture
+	ProcessorArchitectureTypeARM32    ProcessorArchitectureType = 0x04 // 32-bit ARM (Aarch32)
+	ProcessorArchitectureTypeARM64    ProcessorArchitectureType = 0x05 // 64-bit ARM (Aarch64)
+	ProcessorArchitectureTypeRISCV32  ProcessorArchitectureType = 0x06 // 32-bit RISC-V (RV32)
+	ProcessorArchitectureTypeRISCV64  ProcessorArchitectureType = 0x07 // 64-bit RISC-V (RV64)
+	ProcessorArchitectureTypeRISCV128 ProcessorArchitectureType = 0x08 // 128-bit RISC-V (RV128)
+)
+
+func (v ProcessorArchitectureType) String() string {
+	names := map[ProcessorArchitectureType]string{
+		ProcessorArchitectureTypeReserved: "Reserved",
+		ProcessorArchitectureTypeIA32:     "IA32 (x86)",
+		ProcessorArchitectureTypeX64:      "x64 (x86-64, Intel64, AMD64, EM64T)",
+		ProcessorArchitectureTypeItanium:  "Intel Itanium architecture",
+		ProcessorArchitectureTypeARM32:    "32-bit ARM (Aarch32)",
+		ProcessorArchitectureTypeARM64:    "64-bit ARM (Aarch64)",
+		ProcessorArchitectureTypeRISCV32:  "32-bit RISC-V (RV32)",
+		ProcessorArchitectureTypeRISCV64:  "64-bit RISC-V (RV64)",
+		ProcessorArchitectureTypeRISCV128: "128-bit RISC-V (RV128)",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,262 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MemoryControllerInfo is defined in DSP0134 7.6. The structure is obsolete
+// as of SMBIOS 2.1 but still found in older firmware.
+type MemoryControllerInfo struct {
+	Table
+	ErrorDetectingMethod               ErrorDetectingMethod       // 04h
+	ErrorCorrectingCapability          ErrorCorrectingCapability  // 05h
+	SupportedInterleave                MemoryControllerInterleave // 06h
+	CurrentInterleave                  MemoryControllerInterleave // 07h
+	MaximumMemoryModuleSize            uint8                      // 08h
+	SupportedSpeeds                    MemoryControllerSpeeds     // 09h
+	SupportedMemoryTypes               MemoryModuleTypes          // 0Bh
+	MemoryModuleVoltage                MemoryModuleVoltage        // 0Dh
+	NumberOfAssociatedMemorySlots      uint8                      // 0Eh
+	MemoryModuleConfigurationHandles   []uint16                   `smbios:"-"` // 0Fh
+	EnabledErrorCorrectingCapabilities ErrorCorrectingCapability  `smbios:"-"` // 0Fh + 2*0Eh
+}
+
+// NewMemoryControllerInfo parses a generic Table into MemoryControllerInfo.
+func NewMemoryControllerInfo(t *Table) (*MemoryControllerInfo, error) {
+	if t.Type != TableTypeMemoryControllerInfo {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xf {
+		return nil, errors.New("required fields missing")
+	}
+	mc := &MemoryControllerInfo{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, mc)
+	if err != nil {
+		return nil, err
+	}
+	if t.Len() < off+2*int(mc.NumberOfAssociatedMemorySlots) {
+		// dmidecode(8) shows what there is of such tables.
+		return mc, nil
+	}
+	for i := 0; i < int(mc.NumberOfAssociatedMemorySlots); i++ {
+		h, _ := t.GetWordAt(off)
+		mc.MemoryModuleConfigurationHandles = append(mc.MemoryModuleConfigurationHandles, h)
+		off += 2
+	}
+	if v, err := t.GetByteAt(off); err == nil {
+		mc.EnabledErrorCorrectingCapabilities = ErrorCorrectingCapability(v)
+	}
+	return mc, nil
+}
+
+// GetMaximumMemoryModuleSizeBytes returns the size of the largest memory
+// module supported per slot, in bytes.
+func (mc *MemoryControllerInfo) GetMaximumMemoryModuleSizeBytes() uint64 {
+	return 1 << (uint(mc.MaximumMemoryModuleSize) + 20)
+}
+
+func (mc *MemoryControllerInfo) String() string {
+	moduleSize := mc.GetMaximumMemoryModuleSizeBytes() >> 20
+	lines := []string{
+		mc.Header.String(),
+		fmt.Sprintf("Error Detecting Method: %s", mc.ErrorDetectingMethod),
+		fmt.Sprintf("Error Correcting Capabilities:%s", mc.ErrorCorrectingCapability),
+		fmt.Sprintf("Supported Interleave: %s", mc.SupportedInterleave),
+		fmt.Sprintf("Current Interleave: %s", mc.CurrentInterleave),
+		fmt.Sprintf("Maximum Memory Module Size: %d MB", moduleSize),
+		fmt.Sprintf("Maximum Total Memory Size: %d MB", uint64(mc.NumberOfAssociatedMemorySlots)*moduleSize),
+		fmt.Sprintf("Supported Speeds:%s", mc.SupportedSpeeds),
+		fmt.Sprintf("Supported Memory Types:%s", mc.SupportedMemoryTypes),
+		fmt.Sprintf("Memory Module Voltage: %s", mc.MemoryModuleVoltage),
+		fmt.Sprintf("Associated Memory Slots: %d", mc.NumberOfAssociatedMemorySlots),
+	}
+	if mc.Len() < 0xf+2*int(mc.NumberOfAssociatedMemorySlots) {
+		return strings.Join(lines, "\n\t")
+	}
+	for _, h := range mc.MemoryModuleConfigurationHandles {
+		lines = append(lines, fmt.Sprintf("\t0x%04X", h))
+	}
+	if mc.Len() >= 0x10+2*int(mc.NumberOfAssociatedMemorySlots) {
+		lines = append(lines, fmt.Sprintf("Enabled Error Correcting Capabilities:%s", mc.EnabledErrorCorrectingCapabilities))
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// ErrorDetectingMethod is defined in DSP0134 7.6.1.
+type ErrorDetectingMethod uint8
+
+// ErrorDetectingMethod values are defined in DSP0134 7.6.1.
+const (
+	ErrorDetectingMethodOther      ErrorDetectingMethod = 0x01 // Other
+	ErrorDetectingMethodUnknown    ErrorDetectingMethod = 0x02 // Unknown
+	ErrorDetectingMethodNone       ErrorDetectingMethod = 0x03 // None
+	ErrorDetectingMethod8bitParity ErrorDetectingMethod = 0x04 // 8-bit Parity
+	ErrorDetectingMethod32bitECC   ErrorDetectingMethod = 0x05 // 32-bit ECC
+	ErrorDetectingMethod64bitECC   ErrorDetectingMethod = 0x06 // 64-bit ECC
+	ErrorDetectingMethod128bitECC  ErrorDetectingMethod = 0x07 // 128-bit ECC
+	ErrorDetectingMethodCRC        ErrorDetectingMethod = 0x08 // CRC
+)
+
+func (v ErrorDetectingMethod) String() string {
+	names := map[ErrorDetectingMethod]string{
+		ErrorDetectingMethodOther:      "Other",
+		ErrorDetectingMethodUnknown:    "Unknown",
+		ErrorDetectingMethodNone:       "None",
+		ErrorDetectingMethod8bitParity: "8-bit Parity",
+		ErrorDetectingMethod32bitECC:   "32-bit ECC",
+		ErrorDetectingMethod64bitECC:   "64-bit ECC",
+		ErrorDetectingMethod128bitECC:  "128-bit ECC",
+		ErrorDetectingMethodCRC:        "CRC",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// ErrorCorrectingCapability is defined in DSP0134 7.6.2.
+type ErrorCorrectingCapability uint8
+
+// ErrorCorrectingCapability fields are defined in DSP0134 7.6.2.
+const (
+	ErrorCorrectingCapabilityOther                    ErrorCorrectingCapability = 1 << 0 // Other
+	ErrorCorrectingCapabilityUnknown                  ErrorCorrectingCapability = 1 << 1 // Unknown
+	ErrorCorrectingCapabilityNone                     ErrorCorrectingCapability = 1 << 2 // None
+	ErrorCorrectingCapabilitySingleBitErrorCorrecting ErrorCorrectingCapability = 1 << 3 // Single-Bit Error Correcting
+	ErrorCorrectingCapabilityDoubleBitErrorCorrecting ErrorCorrectingCapability = 1 << 4 // Double-Bit Error Correcting
+	ErrorCorrectingCapabilityErrorScrubbing           ErrorCorrectingCapability = 1 << 5 // Error Scrubbing
+)
+
+// String returns the capabilities one per line, each preceded by a line
+// break, or " None" if there are none, as dmidecode(8) prints them after
+// the name of the field.
+func (v ErrorCorrectingCapability) String() string {
+	return bitNames(uint64(v), []string{
+		"Other",
+		"Unknown",
+		"None",
+		"Single-bit Error Correcting",
+		"Double-bit Error Correcting",
+		"Error Scrubbing",
+	})
+}
+
+// MemoryControllerInterleave is defined in DSP0134 7.6.3.
+type MemoryControllerInterleave uint8
+
+// MemoryControllerInterleave values are defined in DSP0134 7.6.3.
+const (
+	MemoryControllerInterleaveOther      MemoryControllerInterleave = 0x01 // Other
+	MemoryControllerInterleaveUnknown    MemoryControllerInterleave = 0x02 // Unknown
+	MemoryControllerInterleaveOneWay     MemoryControllerInterleave = 0x03 // One-Way Interleave
+	MemoryControllerInterleaveTwoWay     MemoryControllerInterleave = 0x04 // Two-Way Interleave
+	MemoryControllerInterleaveFourWay    MemoryControllerInterleave = 0x05 // Four-Way Interleave
+	MemoryControllerInterleaveEightWay   MemoryControllerInterleave = 0x06 // Eight-Way Interleave
+	MemoryControllerInterleaveSixteenWay MemoryControllerInterleave = 0x07 // Sixteen-Way Interleave
+)
+
+func (v MemoryControllerInterleave) String() string {
+	names := map[MemoryControllerInterleave]string{
+		MemoryControllerInterleaveOther:      "Other",
+		MemoryControllerInterleaveUnknown:    "Unknown",
+		MemoryControllerInterleaveOneWay:     "One-way Interleave",
+		MemoryControllerInterleaveTwoWay:     "Two-way Interleave",
+		MemoryControllerInterleaveFourWay:    "Four-way Interleave",
+		MemoryControllerInterleaveEightWay:   "Eight-way Interleave",
+		MemoryControllerInterleaveSixteenWay: "Sixteen-way Interleave",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// MemoryControllerSpeeds is defined in DSP0134 7.6.4.
+type MemoryControllerSpeeds uint16
+
+// MemoryControllerSpeeds fields are defined in DSP0134 7.6.4.
+const (
+	MemoryControllerSpeedsOther   MemoryControllerSpeeds = 1 << 0 // Other
+	MemoryControllerSpeedsUnknown MemoryControllerSpeeds = 1 << 1 // Unknown
+	MemoryControllerSpeeds70ns    MemoryControllerSpeeds = 1 << 2 // 70ns
+	MemoryControllerSpeeds60ns    MemoryControllerSpeeds = 1 << 3 // 60ns
+	MemoryControllerSpeeds50ns    MemoryControllerSpeeds = 1 << 4 // 50ns
+)
+
+// String returns the speeds like ErrorCorrectingCapability.String.
+func (v MemoryControllerSpeeds) String() string {
+	return bitNames(uint64(v), []string{"Other", "Unknown", "70 ns", "60 ns", "50 ns"})
+}
+
+// MemoryModuleTypes is defined in DSP0134 7.7.1.
+type MemoryModuleTypes uint16
+
+// MemoryModuleTypes fields are defined in DSP0134 7.7.1.
+const (
+	MemoryModuleTypesOther    MemoryModuleTypes = 1 << 0  // Other
+	MemoryModuleTypesUnknown  MemoryModuleTypes = 1 << 1  // Unknown
+	MemoryModuleTypesStandard MemoryModuleTypes = 1 << 2  // Standard
+	MemoryModuleTypesFPM      MemoryModuleTypes = 1 << 3  // Fast Page Mode
+	MemoryModuleTypesEDO      MemoryModuleTypes = 1 << 4  // EDO
+	MemoryModuleTypesParity   MemoryModuleTypes = 1 << 5  // Parity
+	MemoryModuleTypesECC      MemoryModuleTypes = 1 << 6  // ECC
+	MemoryModuleTypesSIMM     MemoryModuleTypes = 1 << 7  // SIMM
+	MemoryModuleTypesDIMM     MemoryModuleTypes = 1 << 8  // DIMM
+	MemoryModuleTypesBurstEDO MemoryModuleTypes = 1 << 9  // Burst EDO
+	MemoryModuleTypesSDRAM    MemoryModuleTypes = 1 << 10 // SDRAM
+)
+
+// String returns the types like ErrorCorrectingCapability.String.
+func (v MemoryModuleTypes) String() string {
+	return bitNames(uint64(v), []string{
+		"Other", "Unknown", "Standard", "FPM", "EDO", "Parity", "ECC",
+		"SIMM", "DIMM", "Burst EDO", "SDRAM",
+	})
+}
+
+// MemoryModuleVoltage is defined in DSP0134 7.6.
+type MemoryModuleVoltage uint8
+
+// MemoryModuleVoltage fields are defined in DSP0134 7.6.
+const (
+	MemoryModuleVoltage5V  MemoryModuleVoltage = 1 << 0 // 5V
+	MemoryModuleVoltage33V MemoryModuleVoltage = 1 << 1 // 3.3V
+	MemoryModuleVoltage29V MemoryModuleVoltage = 1 << 2 // 2.9V
+)
+
+func (v MemoryModuleVoltage) String() string {
+	if v&0x80 != 0 {
+		return fmt.Sprintf("%.1f V", float32(v&0x7f)/10)
+	}
+	var vs []string
+	for i, s := range []string{"5.0 V", "3.3 V", "2.9 V"} {
+		if v&(1<<uint(i)) != 0 {
+			vs = append(vs, s)
+		}
+	}
+	if len(vs) == 0 {
+		return "Unknown"
+	}
+	return strings.Join(vs, " ")
+}
+
+// bitNames returns the names of the bits set in v, one per line, each
+// preceded by a line break, or " None" if none of them is set.
+func bitNames(v uint64, names []string) string {
+	var s string
+	for i, name := range names {
+		if v&(1<<uint(i)) != 0 {
+			s += "\n\t\t" + name
+		}
+	}
+	if s == "" {
+		return " None"
+	}
+	return s
+}
@@ -0,0 +1,453 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbios
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Much of this is auto-generated. If adding a new type, see README for instructions.
+
+// SystemSlots is defined in DSP0134 7.10.
+type SystemSlots struct {
+	Table
+	SlotDesignation      string               // 04h
+	SlotType             SlotType             // 05h
+	SlotDataBusWidth     SlotDataBusWidth     // 06h
+	CurrentUsage         SlotUsage            // 07h
+	SlotLength           SlotLength           // 08h
+	SlotID               uint16               // 09h
+	SlotCharacteristics1 SlotCharacteristics1 // 0Bh
+	SlotCharacteristics2 SlotCharacteristics2 // 0Ch
+	SegmentGroupNumber   uint16               // 0Dh
+	BusNumber            uint8                // 0Fh
+	DeviceFunctionNumber uint8                // 10h
+	DataBusWidth         uint8                // 11h
+	PeerGroupingCount    uint8                // 12h
+	PeerGroups           []SlotPeerGroup      `smbios:"-"` // 13h
+}
+
+// SlotPeerGroup is defined in DSP0134 7.10.9.
+type SlotPeerGroup struct {
+	SegmentGroupNumber   uint16 // 00h
+	BusNumber            uint8  // 02h
+	DeviceFunctionNumber uint8  // 03h
+	DataBusWidth         uint8  // 04h
+}
+
+// NewSystemSlots parses a generic Table into SystemSlots.
+func NewSystemSlots(t *Table) (*SystemSlots, error) {
+	if t.Type != TableTypeSystemSlots {
+		return nil, fmt.Errorf("invalid table type %d", t.Type)
+	}
+	if t.Len() < 0xc {
+		return nil, errors.New("required fields missing")
+	}
+	ss := &SystemSlots{Table: *t}
+	off, err := parseStruct(t, 0 /* off */, false /* complete */, ss)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(ss.PeerGroupingCount); i++ {
+		var pg SlotPeerGroup
+		if off, err = parseStruct(t, off, true /* complete */, &pg); err != nil {
+			return nil, err
+		}
+		ss.PeerGroups = append(ss.PeerGroups, pg)
+	}
+	return ss, nil
+}
+
+func (ss *SystemSlots) String() string {
+	width := ""
+	if ss.SlotDataBusWidth != SlotDataBusWidthOther && ss.SlotDataBusWidth != SlotDataBusWidthUnknown {
+		width = ss.SlotDataBusWidth.String()
+		if width != outOfSpec {
+			width += " "
+		}
+	}
+	lines := []string{
+		ss.Header.String(),
+		fmt.Sprintf("Designation: %s", ss.SlotDesignation),
+		fmt.Sprintf("Type: %s%s", width, ss.SlotType),
+		fmt.Sprintf("Current Usage: %s", ss.CurrentUsage),
+		fmt.Sprintf("Length: %s", ss.SlotLength),
+	}
+	switch ss.SlotType {
+	case SlotTypeMCA, SlotTypeEISA, SlotTypePCI, SlotTypePCI66MHzCapable, SlotTypeAGP, SlotTypeAGP2X,
+		SlotTypeAGP4X, SlotTypePCIX, SlotTypeAGP8X, SlotTypePCIExpressGen2SFF8639, SlotTypePCIExpressGen3SFF8639,
+		SlotTypePCIExpressMini52PinWithBottomSideKeepOuts, SlotTypePCIExpressMini52PinWithoutBottomSideKeepOuts,
+		SlotTypePCIExpressMini76Pin:
+		lines = append(lines, fmt.Sprintf("ID: %d", ss.SlotID&0xff))
+	case SlotTypePCCardPCMCIA:
+		lines = append(lines, fmt.Sprintf("ID: Adapter %d, Socket %d", ss.SlotID&0xff, ss.SlotID>>8))
+	default:
+		if ss.SlotType >= SlotTypePCIExpress && ss.SlotType <= SlotTypePCIExpressGen4X16 {
+			lines = append(lines, fmt.Sprintf("ID: %d", ss.SlotID&0xff))
+		}
+	}
+	var c2 SlotCharacteristics2
+	if ss.Len() >= 0xd {
+		c2 = ss.SlotCharacteristics2
+	}
+	switch {
+	case ss.SlotCharacteristics1&SlotCharacteristics1Unknown != 0:
+		lines = append(lines, "Characteristics: Unknown")
+	case ss.SlotCharacteristics1 == 0 && c2 == 0:
+		lines = append(lines, "Characteristics: None")
+	default:
+		var cs []string
+		for _, c := range []fmt.Stringer{ss.SlotCharacteristics1, c2} {
+			if s := c.String(); s != "" {
+				cs = append(cs, s)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("Characteristics:\n%s", strings.Join(cs, "\n")))
+	}
+	if ss.Len() >= 0x11 {
+		if s := busAddress(ss.SegmentGroupNumber, ss.BusNumber, ss.DeviceFunctionNumber); s != "" {
+			lines = append(lines, s)
+		}
+	}
+	if ss.Len() >= 0x12 {
+		lines = append(lines, fmt.Sprintf("Data Bus Width: %d", ss.DataBusWidth))
+	}
+	if len(ss.PeerGroups) > 0 {
+		lines = append(lines, fmt.Sprintf("Peer Devices: %d", len(ss.PeerGroups)))
+		for i, pg := range ss.PeerGroups {
+			lines = append(lines, fmt.Sprintf("\tDevice %d: %04x:%02x:%02x.%x (Width %d)", i+1,
+				pg.SegmentGroupNumber, pg.BusNumber, pg.DeviceFunctionNumber>>3, pg.DeviceFunctionNumber&7, pg.DataBusWidth))
+		}
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+// busAddress returns the Bus Address line for a PCI segment, bus and
+// device/function number, or an empty string if they are not provided.
+func busAddress(seg uint16, bus, devfn uint8) string {
+	if seg == 0xffff && bus == 0xff && devfn == 0xff {
+		return ""
+	}
+	return fmt.Sprintf("Bus Address: %04x:%02x:%02x.%x", seg, bus, devfn>>3, devfn&7)
+}
+
+// SlotCharacteristics1 is defined in DSP0134 7.10.6.
+type SlotCharacteristics1 uint8
+
+// SlotCharacteristics1 fields are defined in DSP0134 7.10.6.
+const (
+	SlotCharacteristics1Unknown                       SlotCharacteristics1 = 1 << 0 // Characteristics unknown.
+	SlotCharacteristics1Provides50Volts               SlotCharacteristics1 = 1 << 1 // Provides 5.0 volts.
+	SlotCharacteristics1Provides33Volts               SlotCharacteristics1 = 1 << 2 // Provides 3.3 volts.
+	SlotCharacteristics1OpeningIsShared               SlotCharacteristics1 = 1 << 3 // Slot’s opening is shared with another slot.
+	SlotCharacteristics1PCCardSupportsPCCard16        SlotCharacteristics1 = 1 << 4 // PC Card slot supports PC Card-16.
+	SlotCharacteristics1PCCardSupportsCardBus         SlotCharacteristics1 = 1 << 5 // PC Card slot supports CardBus.
+	SlotCharacteristics1PCCardSupportsZoomVideo       SlotCharacteristics1 = 1 << 6 // PC Card slot supports Zoom Video.
+	SlotCharacteristics1PCCardSupportsModemRingResume SlotCharacteristics1 = 1 << 7 // PC Card slot supports Modem Ring Resume.
+)
+
+func (v SlotCharacteristics1) String() string {
+	var lines []string
+	if v&SlotCharacteristics1Provides50Volts != 0 {
+		lines = append(lines, "5.0 V is provided")
+	}
+	if v&SlotCharacteristics1Provides33Volts != 0 {
+		lines = append(lines, "3.3 V is provided")
+	}
+	if v&SlotCharacteristics1OpeningIsShared != 0 {
+		lines = append(lines, "Opening is shared")
+	}
+	if v&SlotCharacteristics1PCCardSupportsPCCard16 != 0 {
+		lines = append(lines, "PC Card-16 is supported")
+	}
+	if v&SlotCharacteristics1PCCardSupportsCardBus != 0 {
+		lines = append(lines, "Cardbus is supported")
+	}
+	if v&SlotCharacteristics1PCCardSupportsZoomVideo != 0 {
+		lines = append(lines, "Zoom Video is supported")
+	}
+	if v&SlotCharacteristics1PCCardSupportsModemRingResume != 0 {
+		lines = append(lines, "Modem ring resume is supported")
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\t\t" + strings.Join(lines, "\n\t\t")
+}
+
+// SlotCharacteristics2 is defined in DSP0134 7.10.7.
+type SlotCharacteristics2 uint8
+
+// SlotCharacteristics2 fields are defined in DSP0134 7.10.7.
+const (
+	SlotCharacteristics2PCISlotSupportsPME          SlotCharacteristics2 = 1 << 0 // PCI slot supports Power Management Event (PME#) signal.
+	SlotCharacteristics2SlotSupportsHotPlug         SlotCharacteristics2 = 1 << 1 // Slot supports hot-plug devices.
+	SlotCharacteristics2PCISlotSupportsSMBus        SlotCharacteristics2 = 1 << 2 // PCI slot supports SMBus signal.
+	SlotCharacteristics2PCIeSlotSupportsBifurcation SlotCharacteristics2 = 1 << 3 // PCIe slot supports bifurcation.
+)
+
+func (v SlotCharacteristics2) String() string {
+	var lines []string
+	if v&SlotCharacteristics2PCISlotSupportsPME != 0 {
+		lines = append(lines, "PME signal is supported")
+	}
+	if v&SlotCharacteristics2SlotSupportsHotPlug != 0 {
+		lines = append(lines, "Hot-plug devices are supported")
+	}
+	if v&SlotCharacteristics2PCISlotSupportsSMBus != 0 {
+		lines = append(lines, "SMBus signal is supported")
+	}
+	if v&SlotCharacteristics2PCIeSlotSupportsBifurcation != 0 {
+		lines = append(lines, "PCIe slot bifurcation is supported")
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\t\t" + strings.Join(lines, "\n\t\t")
+}
+
+// SlotType is defined in DSP0134 7.10.1.
+type SlotType uint8
+
+// SlotType values are defined in DSP0134 7.10.1.
+const (
+	SlotTypeOther                                        SlotType = 0x01 // Other
+	SlotTypeUnknown                                      SlotType = 0x02 // Unknown
+	SlotTypeISA                                          SlotType = 0x03 // ISA
+	SlotTypeMCA                                          SlotType = 0x04 // MCA
+	SlotTypeEISA                                         SlotType = 0x05 // EISA
+	SlotTypePCI                                          SlotType = 0x06 // PCI
+	SlotTypePCCardPCMCIA                                 SlotType = 0x07 // PC Card (PCMCIA)
+	SlotTypeVLVESA                                       SlotType = 0x08 // VL-VESA
+	SlotTypeProprietary                                  SlotType = 0x09 // Proprietary
+	SlotTypeProcessorCardSlot                            SlotType = 0x0a // Processor Card Slot
+	SlotTypeProprietaryMemoryCardSlot                    SlotType = 0x0b // Proprietary Memory Card Slot
+	SlotTypeIORiserCardSlot                              SlotType = 0x0c // I/O Riser Card Slot
+	SlotTypeNuBus                                        SlotType = 0x0d // NuBus
+	SlotTypePCI66MHzCapable                              SlotType = 0x0e // PCI - 66MHz Capable
+	SlotTypeAGP                                          SlotType = 0x0f // AGP
+	SlotTypeAGP2X                                        SlotType = 0x10 // AGP 2X
+	SlotTypeAGP4X                                        SlotType = 0x11 // AGP 4X
+	SlotTypePCIX                                         SlotType = 0x12 // PCI-X
+	SlotTypeAGP8X                                        SlotType = 0x13 // AGP 8X
+	SlotTypeM2Socket1DPMechanicalKeyA                    SlotType = 0x14 // M.2 Socket 1-DP (Mechanical Key A)
+	SlotTypeM2Socket1SDMechanicalKeyE                    SlotType = 0x15 // M.2 Socket 1-SD (Mechanical Key E)
+	SlotTypeM2Socket2MechanicalKeyB                      SlotType = 0x16 // M.2 Socket 2 (Mechanical Key B)
+	SlotTypeM2Socket3MechanicalKeyM                      SlotType = 0x17 // M.2 Socket 3 (Mechanical Key M)
+	SlotTypeMXMTypeI                                     SlotType = 0x18 // MXM Type I
+	SlotTypeMXMTypeII                                    SlotType = 0x19 // MXM Type II
+	SlotTypeMXMTypeIIIStandardConnector                  SlotType = 0x1a // MXM Type III (standard connector)
+	SlotTypeMXMTypeIIIHEConnector                        SlotType = 0x1b // MXM Type III (HE connector)
+	SlotTypeMXMTypeIV                                    SlotType = 0x1c // MXM Type IV
+	SlotTypeMXM30TypeA                                   SlotType = 0x1d // MXM 3.0 Type A
+	SlotTypeMXM30TypeB                                   SlotType = 0x1e // MXM 3.0 Type B
+	SlotTypePCIExpressGen2SFF8639                        SlotType = 0x1f // PCI Express Gen 2 SFF-8639
+	SlotTypePCIExpressGen3SFF8639                        SlotType = 0x20 // PCI Express Gen 3 SFF-8639
+	SlotTypePCIExpressMini52PinWithBottomSideKeepOuts    SlotType = 0x21 // PCI Express Mini 52-pin with bottom-side keep-outs
+	SlotTypePCIExpressMini52PinWithoutBottomSideKeepOuts SlotType = 0x22 // PCI Express Mini 52-pin without bottom-side keep-outs
+	SlotTypePCIExpressMini76Pin                          SlotType = 0x23 // PCI Express Mini 76-pin
+	SlotTypePC98C20                                      SlotType = 0xa0 // PC-98/C20
+	SlotTypePC98C24                                      SlotType = 0xa1 // PC-98/C24
+	SlotTypePC98E                                        SlotType = 0xa2 // PC-98/E
+	SlotTypePC98LocalBus                                 SlotType = 0xa3 // PC-98/Local Bus
+	SlotTypePC98Card                                     SlotType = 0xa4 // PC-98/Card
+	SlotTypePCIExpress                                   SlotType = 0xa5 // PCI Express
+	SlotTypePCIExpressX1                                 SlotType = 0xa6 // PCI Express x1
+	SlotTypePCIExpressX2                                 SlotType = 0xa7 // PCI Express x2
+	SlotTypePCIExpressX4                                 SlotType = 0xa8 // PCI Express x4
+	SlotTypePCIExpressX8                                 SlotType = 0xa9 // PCI Express x8
+	SlotTypePCIExpressX16                                SlotType = 0xaa // PCI Express x16
+	SlotTypePCIExpressGen2                               SlotType = 0xab // PCI Express Gen 2
+	SlotTypePCIExpressGen2X1                             SlotType = 0xac // PCI Express Gen 2 x1
+	SlotTypePCIExpressGen2X2                             SlotType = 0xad // PCI Express Gen 2 x2
+	SlotTypePCIExpressGen2X4                             SlotType = 0xae // PCI Express Gen 2 x4
+	SlotTypePCIExpressGen2X8                             SlotType = 0xaf // PCI Express Gen 2 x8
+	SlotTypePCIExpressGen2X16                            SlotType = 0xb0 // PCI Express Gen 2 x16
+	SlotTypePCIExpressGen3                               SlotType = 0xb1 // PCI Express Gen 3
+	SlotTypePCIExpressGen3X1                             SlotType = 0xb2 // PCI Express Gen 3 x1
+	SlotTypePCIExpressGen3X2                             SlotType = 0xb3 // PCI Express Gen 3 x2
+	SlotTypePCIExpressGen3X4                             SlotType = 0xb4 // PCI Express Gen 3 x4
+	SlotTypePCIExpressGen3X8                             SlotType = 0xb5 // PCI Express Gen 3 x8
+	SlotTypePCIExpressGen3X16                            SlotType = 0xb6 // PCI Express Gen 3 x16
+	SlotTypePCIExpressGen4                               SlotType = 0xb8 // PCI Express Gen 4
+	SlotTypePCIExpressGen4X1                             SlotType = 0xb9 // PCI Express Gen 4 x1
+	SlotTypePCIExpressGen4X2                             SlotType = 0xba // PCI Express Gen 4 x2
+	SlotTypePCIExpressGen4X4                             SlotType = 0xbb // PCI Express Gen 4 x4
+	SlotTypePCIExpressGen4X8                             SlotType = 0xbc // PCI Express Gen 4 x8
+	SlotTypePCIExpressGen4X16                            SlotType = 0xbd // PCI Express Gen 4 x16
+)
+
+func (v SlotType) String() string {
+	names := map[SlotType]string{
+		SlotTypeOther:                       "Other",
+		SlotTypeUnknown:                     "Unknown",
+		SlotTypeISA:                         "ISA",
+		SlotTypeMCA:                         "MCA",
+		SlotTypeEISA:                        "EISA",
+		SlotTypePCI:                         "PCI",
+		SlotTypePCCardPCMCIA:                "PC Card (PCMCIA)",
+		SlotTypeVLVESA:                      "VLB",
+		SlotTypeProprietary:                 "Proprietary",
+		SlotTypeProcessorCardSlot:           "Processor Card",
+		SlotTypeProprietaryMemoryCardSlot:   "Proprietary Memory Card",
+		SlotTypeIORiserCardSlot:             "I/O Riser Card",
+		SlotTypeNuBus:                       "NuBus",
+		SlotTypePCI66MHzCapable:             "PCI-66",
+		SlotTypeAGP:                         "AGP",
+		SlotTypeAGP2X:                       "AGP 2x",
+		SlotTypeAGP4X:                       "AGP 4x",
+		SlotTypePCIX:                        "PCI-X",
+		SlotTypeAGP8X:                       "AGP 8x",
+		SlotTypeM2Socket1DPMechanicalKeyA:   "M.2 Socket 1-DP",
+		SlotTypeM2Socket1SDMechanicalKeyE:   "M.2 Socket 1-SD",
+		SlotTypeM2Socket2MechanicalKeyB:     "M.2 Socket 2",
+		SlotTypeM2Socket3MechanicalKeyM:     "M.2 Socket 3",
+		SlotTypeMXMTypeI:                    "MXM Type I",
+		SlotTypeMXMTypeII:                   "MXM Type II",
+		SlotTypeMXMTypeIIIStandardConnector: "MXM Type III",
+		SlotTypeMXMTypeIIIHEConnector:       "MXM Type III-HE",
+		SlotTypeMXMTypeIV:                   "MXM Type IV",
+		SlotTypeMXM30TypeA:                  "MXM 3.0 Type A",
+		SlotTypeMXM30TypeB:                  "MXM 3.0 Type B",
+		SlotTypePCIExpressGen2SFF8639:       "PCI Express 2 SFF-8639",
+		SlotTypePCIExpressGen3SFF8639:       "PCI Express 3 SFF-8639",
+		SlotTypePCIExpressMini52PinWithBottomSideKeepOuts:    "PCI Express Mini 52-pin with bottom-side keep-outs",
+		SlotTypePCIExpressMini52PinWithoutBottomSideKeepOuts: "PCI Express Mini 52-pin without bottom-side keep-outs",
+		SlotTypePCIExpressMini76Pin:                          "PCI Express Mini 76-pin",
+		SlotTypePC98C20:                                      "PC-98/C20",
+		SlotTypePC98C24:                                      "PC-98/C24",
+		SlotTypePC98E:                                        "PC-98/E",
+		SlotTypePC98LocalBus:                                 "PC-98/Local Bus",
+		SlotTypePC98Card:                                     "PC-98/Card",
+		SlotTypePCIExpress:                                   "PCI Express",
+		SlotTypePCIExpressX1:                                 "PCI Express x1",
+		SlotTypePCIExpressX2:                                 "PCI Express x2",
+		SlotTypePCIExpressX4:                                 "PCI Express x4",
+		SlotTypePCIExpressX8:                                 "PCI Express x8",
+		SlotTypePCIExpressX16:                                "PCI Express x16",
+		SlotTypePCIExpressGen2:                               "PCI Express 2",
+		SlotTypePCIExpressGen2X1:                             "PCI Express 2 x1",
+		SlotTypePCIExpressGen2X2:                             "PCI Express 2 x2",
+		SlotTypePCIExpressGen2X4:                             "PCI Express 2 x4",
+		SlotTypePCIExpressGen2X8:                             "PCI Express 2 x8",
+		SlotTypePCIExpressGen2X16:                            "PCI Express 2 x16",
+		SlotTypePCIExpressGen3:                               "PCI Express 3",
+		SlotTypePCIExpressGen3X1:                             "PCI Express 3 x1",
+		SlotTypePCIExpressGen3X2:                             "PCI Express 3 x2",
+		SlotTypePCIExpressGen3X4:                             "PCI Express 3 x4",
+		SlotTypePCIExpressGen3X8:                             "PCI Express 3 x8",
+		SlotTypePCIExpressGen3X16:                            "PCI Express 3 x16",
+		SlotTypePCIExpressGen4:                               "PCI Express 4",
+		SlotTypePCIExpressGen4X1:                             "PCI Express 4 x1",
+		SlotTypePCIExpressGen4X2:                             "PCI Express 4 x2",
+		SlotTypePCIExpressGen4X4:                             "PCI Express 4 x4",
+		SlotTypePCIExpressGen4X8:                             "PCI Express 4 x8",
+		SlotTypePCIExpressGen4X16:                            "PCI Express 4 x16",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// SlotDataBusWidth is defined in DSP0134 7.10.2.
+type SlotDataBusWidth uint8
+
+// SlotDataBusWidth values are defined in DSP0134 7.10.2.
+const (
+	SlotDataBusWidthOther   SlotDataBusWidth = 0x01 // Other
+	SlotDataBusWidthUnknown SlotDataBusWidth = 0x02 // Unknown
+	SlotDataBusWidth8Bit    SlotDataBusWidth = 0x03 // 8 bit
+	SlotDataBusWidth16Bit   SlotDataBusWidth = 0x04 // 16 bit
+	SlotDataBusWidth32Bit   SlotDataBusWidth = 0x05 // 32 bit
+	SlotDataBusWidth64Bit   SlotDataBusWidth = 0x06 // 64 bit
+	SlotDataBusWidth128Bit  SlotDataBusWidth = 0x07 // 128 bit
+	SlotDataBusWidthX1      SlotDataBusWidth = 0x08 // x1
+	SlotDataBusWidthX2      SlotDataBusWidth = 0x09 // x2
+	SlotDataBusWidthX4      SlotDataBusWidth = 0x0a // x4
+	SlotDataBusWidthX8      SlotDataBusWidth = 0x0b // x8
+	SlotDataBusWidthX12     SlotDataBusWidth = 0x0c // x12
+	SlotDataBusWidthX16     SlotDataBusWidth = 0x0d // x16
+	SlotDataBusWidthX32     SlotDataBusWidth = 0x0e // x32
+)
+
+func (v SlotDataBusWidth) String() string {
+	names := map[SlotDataBusWidth]string{
+		SlotDataBusWidthOther:   "Other",
+		SlotDataBusWidthUnknown: "Unknown",
+		SlotDataBusWidth8Bit:    "8-bit",
+		SlotDataBusWidth16Bit:   "16-bit",
+		SlotDataBusWidth32Bit:   "32-bit",
+		SlotDataBusWidth64Bit:   "64-bit",
+		SlotDataBusWidth128Bit:  "128-bit",
+		SlotDataBusWidthX1:      "x1",
+		SlotDataBusWidthX2:      "x2",
+		SlotDataBusWidthX4:      "x4",
+		SlotDataBusWidthX8:      "x8",
+		SlotDataBusWidthX12:     "x12",
+		SlotDataBusWidthX16:     "x16",
+		SlotDataBusWidthX32:     "x32",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// SlotUsage is defined in DSP0134 7.10.3.
+type SlotUsage uint8
+
+// SlotUsage values are defined in DSP0134 7.10.3.
+const (
+	SlotUsageOther       SlotUsage = 0x01 // Other
+	SlotUsageUnknown     SlotUsage = 0x02 // Unknown
+	SlotUsageAvailable   SlotUsage = 0x03 // Available
+	SlotUsageInUse       SlotUsage = 0x04 // In use
+	SlotUsageUnavailable SlotUsage = 0x05 // Unavailable
+)
+
+func (v SlotUsage) String() string {
+	names := map[SlotUsage]string{
+		SlotUsageOther:       "Other",
+		SlotUsageUnknown:     "Unknown",
+		SlotUsageAvailable:   "Available",
+		SlotUsageInUse:       "In Use",
+		SlotUsageUnavailable: "Unavailable",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
+
+// SlotLength is defined in DSP0134 7.10.4.
+type SlotLength uint8
+
+// SlotLength values are defined in DSP0134 7.10.4.
+const (
+	SlotLengthOther             SlotLength = 0x01 // Other
+	SlotLengthUnknown           SlotLength = 0x02 // Unknown
+	SlotLengthShortLength       SlotLength = 0x03 // Short Length
+	SlotLengthLongLength        SlotLength = 0x04 // Long Length
+	SlotLength25DriveFormFactor SlotLength = 0x05 // 2.5" drive form factor
+	SlotLength35DriveFormFactor SlotLength = 0x06 // 3.5" drive form factor
+)
+
+func (v SlotLength) String() string {
+	names := map[SlotLength]string{
+		SlotLengthOther:             "Other",
+		SlotLengthUnknown:           "Unknown",
+		SlotLengthShortLength:       "Short",
+		SlotLengthLongLength:        "Long",
+		SlotLength25DriveFormFactor: "2.5\" drive form factor",
+		SlotLength35DriveFormFactor: "3.5\" drive form factor",
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return outOfSpec
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+)
+
+// Measurement is a digest extended into a PCR, as an event log records it.
+type Measurement struct {
+	PCR    int
+	Digest []byte
+}
+
+// ReplayLog returns the values of the PCRs that the measurements are
+// extended into, in order, with the hash h, starting from zeros.
+func ReplayLog(h crypto.Hash, log []Measurement) (map[int][]byte, error) {
+	pcrs := map[int][]byte{}
+	for i, m := range log {
+		if len(m.Digest) != h.Size() {
+			return nil, fmt.Errorf("measurement %d into PCR %d has %d bytes, want %d for %v", i, m.PCR, len(m.Digest), h.Size(), h)
+		}
+		v, ok := pcrs[m.PCR]
+		if !ok {
+			v = make([]byte, h.Size())
+		}
+		d := h.New()
+		d.Write(v)
+		d.Write(m.Digest)
+		pcrs[m.PCR] = d.Sum(nil)
+	}
+	return pcrs, nil
+}
+
+// PCRMismatch is a PCR whose value differs from the one replaying the
+// event log gives.
+type PCRMismatch struct {
+	PCR      int
+	Replayed []byte
+	Actual   []byte
+}
+
+func (m PCRMismatch) String() string {
+	return fmt.Sprintf("PCR %d is %x, the log gives %x", m.PCR, m.Actual, m.Replayed)
+}
+
+// ValidateLog replays the log with the hash of the PCRs ReadPCRs reads,
+// SHA1 for TPM 1.2 and SHA256 for TPM 2.0, and returns the PCRs in the log
+// that have other values.
+func (t *TPM) ValidateLog(log []Measurement) ([]PCRMismatch, error) {
+	pcrs, err := t.ReadPCRs()
+	if err != nil {
+		return nil, err
+	}
+	if len(pcrs) == 0 {
+		return nil, fmt.Errorf("no PCRs")
+	}
+	replayed, err := ReplayLog(pcrs[0].DigestAlg, log)
+	if err != nil {
+		return nil, err
+	}
+	var bad []PCRMismatch
+	for _, p := range pcrs {
+		if v, ok := replayed[p.Index]; ok && !bytes.Equal(v, p.Digest) {
+			bad = append(bad, PCRMismatch{PCR: p.Index, Replayed: v, Actual: p.Digest})
+		}
+	}
+	return bad, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"crypto"
+	"encoding/hex"
+	"testing"
+)
+
+func TestReplayLog(t *testing.T) {
+	zero1 := make([]byte, 20)
+	for _, tt := range []struct {
+		name string
+		h    crypto.Hash
+		log  []Measurement
+		want map[int]string
+	}{
+		{
+			name: "no events",
+			h:    crypto.SHA1,
+			want: map[int]string{},
+		},
+		{
+			name: "zero digest",
+			h:    crypto.SHA1,
+			log:  []Measurement{{PCR: 4, Digest: zero1}},
+			want: map[int]string{4: "b80de5d138758541c5f05265ad144ab9fa86d1db"},
+		},
+		{
+			name: "two PCRs",
+			h:    crypto.SHA1,
+			log:  []Measurement{{PCR: 4, Digest: zero1}, {PCR: 5, Digest: zero1}, {PCR: 4, Digest: zero1}},
+			want: map[int]string{
+				4: "850659b18eb6fb4ccdcb113ca4266eb945449466",
+				5: "b80de5d138758541c5f05265ad144ab9fa86d1db",
+			},
+		},
+		{
+			name: "sha256",
+			h:    crypto.SHA256,
+			log:  []Measurement{{PCR: 0, Digest: make([]byte, 32)}},
+			want: map[int]string{0: "f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReplayLog(tt.h, tt.log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("ReplayLog() = %x, want %v", got, tt.want)
+			}
+			for pcr, v := range tt.want {
+				if hex.EncodeToString(got[pcr]) != v {
+					t.Errorf("PCR %d = %x, want %s", pcr, got[pcr], v)
+				}
+			}
+		})
+	}
+
+	if _, err := ReplayLog(crypto.SHA256, []Measurement{{PCR: 0, Digest: zero1}}); err == nil {
+		t.Errorf("ReplayLog() of a SHA1 digest with SHA256 succeeded")
+	}
+}
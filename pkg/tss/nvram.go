@@ -46,3 +46,83 @@ func nvRead12(rwc io.ReadWriteCloser, index, offset, len uint32, auth string) ([
 func nvRead20(rwc io.ReadWriteCloser, index, authHandle tpmutil.Handle, password string, blocksize int) ([]byte, error) {
 	return tpm2.NVReadEx(rwc, index, authHandle, password, blocksize)
 }
+
+// NVDefine defines the TPM 2.0 NV index with size bytes and the TPMA_NV
+// attributes, e.g. tpm2.AttrAuthWrite|tpm2.AttrAuthRead|tpm2.AttrWriteSTClear
+// for an index that is written and read with the password, and that
+// NVLock locks until the next reboot.
+func (t *TPM) NVDefine(index uint32, size uint16, attributes uint32, ownerPassword, password string) error {
+	switch t.Version {
+	case TPMVersion20:
+		return nvDefine20(t.RWC, tpmutil.Handle(index), size, tpm2.NVAttr(attributes), ownerPassword, password)
+	}
+	return fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// NVUndefine deletes a TPM 2.0 NV index.
+func (t *TPM) NVUndefine(index uint32, ownerPassword string) error {
+	switch t.Version {
+	case TPMVersion20:
+		return tpm2.NVUndefineSpace(t.RWC, ownerPassword, tpm2.HandleOwner, tpmutil.Handle(index))
+	}
+	return fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// NVWriteValue writes data to a TPM 2.0 NV index at offset, authorized by
+// the password of authHandle, the index itself or the owner hierarchy.
+func (t *TPM) NVWriteValue(index, authHandle uint32, password string, data []byte, offset uint16) error {
+	switch t.Version {
+	case TPMVersion20:
+		return nvWrite20(t.RWC, tpmutil.Handle(index), tpmutil.Handle(authHandle), password, data, offset)
+	}
+	return fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// NVLock locks a TPM 2.0 NV index for writing, until the next reboot if it
+// has AttrWriteSTClear, or forever if it has AttrWriteDefine.
+func (t *TPM) NVLock(index, authHandle uint32, password string) error {
+	switch t.Version {
+	case TPMVersion20:
+		return tpm2.NVWriteLock(t.RWC, tpmutil.Handle(authHandle), tpmutil.Handle(index), password)
+	}
+	return fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+func nvDefine20(rwc io.ReadWriter, index tpmutil.Handle, size uint16, attributes tpm2.NVAttr, ownerPassword, password string) error {
+	if err := tpm2.NVDefineSpace(rwc, tpm2.HandleOwner, index, ownerPassword, password, nil, attributes, size); err != nil {
+		return fmt.Errorf("defining NV index %#x: %v", index, err)
+	}
+	return nil
+}
+
+// nvBufferSize returns how much the TPM reads or writes at once.
+func nvBufferSize(rwc io.ReadWriter) (int, error) {
+	caps, _, err := tpm2.GetCapability(rwc, tpm2.CapabilityTPMProperties, 1, uint32(tpm2.NVMaxBufferSize))
+	if err != nil {
+		return 0, fmt.Errorf("tpm2.GetCapability(PT_NV_BUFFER_MAX) failed: %v", err)
+	}
+	p, ok := caps[0].(tpm2.TaggedProperty)
+	if !ok {
+		return 0, fmt.Errorf("got capability of type %T, want tpm2.TaggedProperty", caps[0])
+	}
+	return int(p.Value), nil
+}
+
+func nvWrite20(rwc io.ReadWriter, index, authHandle tpmutil.Handle, password string, data []byte, offset uint16) error {
+	max, err := nvBufferSize(rwc)
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		n := len(data)
+		if n > max {
+			n = max
+		}
+		if err := tpm2.NVWrite(rwc, authHandle, index, password, data[:n], offset); err != nil {
+			return fmt.Errorf("writing NV index %#x at %d: %v", index, offset, err)
+		}
+		data = data[n:]
+		offset += uint16(n)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// akTemplate is an RSA 2048 restricted signing key, which signs quotes
+// with RSASSA-PKCS1-v1_5 and SHA256.
+var akTemplate = tpm2.Public{
+	Type:       tpm2.AlgRSA,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagSignerDefault | tpm2.FlagNoDA,
+	RSAParameters: &tpm2.RSAParams{
+		Sign:    &tpm2.SigScheme{Alg: tpm2.AlgRSASSA, Hash: tpm2.AlgSHA256},
+		KeyBits: 2048,
+	},
+}
+
+// AK is a TPM 2.0 attestation key, which signs quotes.
+type AK struct {
+	t      *TPM
+	handle tpmutil.Handle
+
+	// Public is the public key, which verifies the quotes.
+	Public crypto.PublicKey
+	// PublicArea is the TPM2B_PUBLIC of the key, without the size, for
+	// verifiers that check its attributes.
+	PublicArea []byte
+}
+
+// CreateAK creates an attestation key, a primary key in the endorsement
+// hierarchy. A TPM creates the same key until the hierarchy is cleared.
+func (t *TPM) CreateAK(endorsementPassword string) (*AK, error) {
+	if t.Version != TPMVersion20 {
+		return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+	}
+	h, pub, err := tpm2.CreatePrimary(t.RWC, tpm2.HandleEndorsement, tpm2.PCRSelection{}, endorsementPassword, "", akTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("creating the attestation key: %v", err)
+	}
+	p, _, _, err := tpm2.ReadPublic(t.RWC, h)
+	if err != nil {
+		tpm2.FlushContext(t.RWC, h)
+		return nil, fmt.Errorf("reading the attestation key: %v", err)
+	}
+	area, err := p.Encode()
+	if err != nil {
+		tpm2.FlushContext(t.RWC, h)
+		return nil, err
+	}
+	return &AK{t: t, handle: h, Public: pub, PublicArea: area}, nil
+}
+
+// Close unloads the key from the TPM.
+func (a *AK) Close() error {
+	return tpm2.FlushContext(a.t.RWC, a.handle)
+}
+
+// Quote is a TPM 2.0 quote: a signed digest of the values of PCRs.
+type Quote struct {
+	// Attest is the TPMS_ATTEST that is signed, which has the nonce and
+	// the PCR digest.
+	Attest []byte
+	// Signature is the TPMT_SIGNATURE of Attest.
+	Signature []byte
+	// PCRs are the SHA256 values of the quoted PCRs.
+	PCRs map[int][]byte
+}
+
+// Quote quotes the SHA256 PCRs pcrs. The nonce, from the verifier, shows
+// that the quote is fresh.
+func (a *AK) Quote(nonce []byte, pcrs []int) (*Quote, error) {
+	sel := tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: pcrs}
+	attest, sig, err := tpm2.QuoteRaw(a.t.RWC, a.handle, "", "", nonce, sel, tpm2.AlgNull)
+	if err != nil {
+		return nil, fmt.Errorf("quoting: %v", err)
+	}
+	q := &Quote{Attest: attest, Signature: sig, PCRs: map[int][]byte{}}
+	for _, i := range pcrs {
+		if q.PCRs[i], err = tpm2.ReadPCR(a.t.RWC, i, tpm2.AlgSHA256); err != nil {
+			return nil, fmt.Errorf("reading PCR %d: %v", i, err)
+		}
+	}
+	// The PCRs are read after quoting, so they may have changed.
+	if err := q.checkPCRs(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// checkPCRs checks that the PCR values are those quoted.
+func (q *Quote) checkPCRs() error {
+	ad, err := tpm2.DecodeAttestationData(q.Attest)
+	if err != nil {
+		return err
+	}
+	if ad.Type != tpm2.TagAttestQuote || ad.AttestedQuoteInfo == nil {
+		return fmt.Errorf("attestation data of type %#x is not a quote", ad.Type)
+	}
+	sel := ad.AttestedQuoteInfo.PCRSelection
+	h, err := sel.Hash.Hash()
+	if err != nil {
+		return err
+	}
+	pcrs := append([]int{}, sel.PCRs...)
+	sort.Ints(pcrs)
+	d := h.New()
+	for _, i := range pcrs {
+		v, ok := q.PCRs[i]
+		if !ok {
+			return fmt.Errorf("no value for quoted PCR %d", i)
+		}
+		d.Write(v)
+	}
+	if !bytes.Equal(d.Sum(nil), ad.AttestedQuoteInfo.PCRDigest) {
+		return errors.New("PCR values do not match the quoted digest")
+	}
+	return nil
+}
+
+// Verify checks that the quote is signed by the attestation key pub, for
+// the nonce, and that the PCR values are those quoted.
+func (q *Quote) Verify(pub crypto.PublicKey, nonce []byte) error {
+	ad, err := tpm2.DecodeAttestationData(q.Attest)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(ad.ExtraData, nonce) != 1 {
+		return errors.New("quote is for another nonce")
+	}
+
+	sig, err := tpm2.DecodeSignature(bytes.NewBuffer(q.Signature))
+	if err != nil {
+		return err
+	}
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		if sig.RSA == nil {
+			return fmt.Errorf("signature of algorithm %#x, want RSA", sig.Alg)
+		}
+		h, err := sig.RSA.HashAlg.Hash()
+		if err != nil {
+			return err
+		}
+		d := h.New()
+		d.Write(q.Attest)
+		if sig.Alg == tpm2.AlgRSAPSS {
+			err = rsa.VerifyPSS(p, h, d.Sum(nil), sig.RSA.Signature, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(p, h, d.Sum(nil), sig.RSA.Signature)
+		}
+		if err != nil {
+			return fmt.Errorf("bad quote signature: %v", err)
+		}
+	case *ecdsa.PublicKey:
+		if sig.ECC == nil {
+			return fmt.Errorf("signature of algorithm %#x, want ECDSA", sig.Alg)
+		}
+		h, err := sig.ECC.HashAlg.Hash()
+		if err != nil {
+			return err
+		}
+		d := h.New()
+		d.Write(q.Attest)
+		if !ecdsa.Verify(p, d.Sum(nil), sig.ECC.R, sig.ECC.S) {
+			return errors.New("bad quote signature")
+		}
+	default:
+		return fmt.Errorf("unsupported attestation key type %T", pub)
+	}
+	return q.checkPCRs()
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// fakeQuote builds the quote a TPM with the key would make.
+func fakeQuote(t *testing.T, key *rsa.PrivateKey, nonce []byte, pcrs map[int][]byte) *Quote {
+	d := sha256.New()
+	var bitmap [3]byte
+	for i := 0; i < 24; i++ {
+		if v, ok := pcrs[i]; ok {
+			d.Write(v)
+			bitmap[i/8] |= 1 << uint(i%8)
+		}
+	}
+	attest, err := tpmutil.Pack(
+		uint32(0xff544347), tpm2.TagAttestQuote,
+		tpmutil.U16Bytes{}, tpmutil.U16Bytes(nonce),
+		tpm2.ClockInfo{Clock: 1000, Safe: 1}, uint64(0x20191023),
+		uint32(1), tpm2.AlgSHA256, byte(3), bitmap,
+		tpmutil.U16Bytes(d.Sum(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.Sum256(attest)
+	s, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := tpmutil.Pack(tpm2.AlgRSASSA, tpm2.AlgSHA256, tpmutil.U16Bytes(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Quote{Attest: attest, Signature: sig, PCRs: pcrs}
+}
+
+func TestQuoteVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("nonce")
+	pcrs := func() map[int][]byte {
+		return map[int][]byte{
+			0: bytes.Repeat([]byte{0xaa}, 32),
+			7: bytes.Repeat([]byte{0x77}, 32),
+		}
+	}
+
+	q := fakeQuote(t, key, nonce, pcrs())
+	if err := q.Verify(&key.PublicKey, nonce); err != nil {
+		t.Errorf("Verify() = %v, want nil", err)
+	}
+	if err := q.Verify(&other.PublicKey, nonce); err == nil {
+		t.Errorf("Verify() with another key succeeded")
+	}
+	if err := q.Verify(&key.PublicKey, []byte("replay")); err == nil {
+		t.Errorf("Verify() with another nonce succeeded")
+	}
+
+	q.PCRs[7][0] = 0
+	if err := q.Verify(&key.PublicKey, nonce); err == nil {
+		t.Errorf("Verify() with another PCR value succeeded")
+	}
+	delete(q.PCRs, 7)
+	if err := q.Verify(&key.PublicKey, nonce); err == nil {
+		t.Errorf("Verify() without a quoted PCR succeeded")
+	}
+
+	q = fakeQuote(t, key, nonce, pcrs())
+	q.Attest[len(q.Attest)-1] ^= 1
+	if err := q.Verify(&key.PublicKey, nonce); err == nil {
+		t.Errorf("Verify() of modified attestation data succeeded")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !tpmsimulator
+
+package tss
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// replayTPM is a TPM that answers the commands in a transcript recorded
+// from the simulator, and fails the test on any other command.
+type replayTPM struct {
+	t        *testing.T
+	ex       []exchange
+	next     int
+	response []byte
+}
+
+// testTPM replays the transcript of the test.
+func testTPM(t *testing.T) *TPM {
+	ex, err := readTranscript(transcriptPath(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TPM{Version: TPMVersion20, RWC: &replayTPM{t: t, ex: ex}}
+}
+
+func (r *replayTPM) Write(b []byte) (int, error) {
+	if r.next == len(r.ex) {
+		r.t.Errorf("command %d is not in the transcript: %x", r.next, b)
+		return 0, fmt.Errorf("command not in transcript")
+	}
+	e := r.ex[r.next]
+	if !bytes.Equal(b, e.command) {
+		r.t.Errorf("command %d differs from the transcript:\ngot  %x\nwant %x", r.next, b, e.command)
+		return 0, fmt.Errorf("command not in transcript")
+	}
+	r.next++
+	r.response = e.response
+	return len(b), nil
+}
+
+func (r *replayTPM) Read(b []byte) (int, error) {
+	if len(r.response) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, r.response)
+	r.response = r.response[n:]
+	return n, nil
+}
+
+func (r *replayTPM) Close() error {
+	if r.next != len(r.ex) {
+		r.t.Errorf("%d of the %d commands in the transcript were not sent", len(r.ex)-r.next, len(r.ex))
+	}
+	return nil
+}
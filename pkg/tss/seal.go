@@ -0,0 +1,202 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+
+	tpm1 "github.com/google/go-tpm/tpm"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// srkTemplate is tpm2_createprimary's default template, an RSA 2048
+// storage key. Sealed objects are created under the primary key it makes,
+// unless they have a persistent parent.
+var srkTemplate = tpm2.Public{
+	Type:       tpm2.AlgRSA,
+	NameAlg:    tpm2.AlgSHA256,
+	Attributes: tpm2.FlagStorageDefault | tpm2.FlagNoDA,
+	RSAParameters: &tpm2.RSAParams{
+		Symmetric: &tpm2.SymScheme{Alg: tpm2.AlgAES, KeyBits: 128, Mode: tpm2.AlgCFB},
+		KeyBits:   2048,
+	},
+}
+
+// SealedData is data sealed by a TPM, which only it can unseal, and only
+// while the PCRs have the values they had when the data was sealed.
+type SealedData struct {
+	// PCRs are the PCRs the data is sealed to, the SHA1 ones for TPM 1.2
+	// and the SHA256 ones for TPM 2.0.
+	PCRs []int
+
+	// Blob is the TPM 1.2 TPM_STORED_DATA.
+	Blob []byte
+
+	// Public and Private are the TPM 2.0 TPM2B_PUBLIC and TPM2B_PRIVATE
+	// of the sealed object, without the size, as tpm2_create writes
+	// them with -u and -r.
+	Public  []byte
+	Private []byte
+	// Parent is the persistent handle of the TPM 2.0 object's parent,
+	// or 0 for a primary key made from tpm2_createprimary's default
+	// template in the owner hierarchy.
+	Parent uint32
+}
+
+// Seal seals data to the current values of pcrs. The password is the SRK
+// password for TPM 1.2, and the owner password for TPM 2.0.
+func (t *TPM) Seal(data []byte, pcrs []int, password string) (*SealedData, error) {
+	switch t.Version {
+	case TPMVersion12:
+		return seal12(t.RWC, data, pcrs, password)
+	case TPMVersion20:
+		return seal20(t.RWC, data, pcrs, password)
+	}
+	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// Unseal unseals data sealed by Seal, or by tpm2_create with a PCR policy.
+func (t *TPM) Unseal(s *SealedData, password string) ([]byte, error) {
+	switch t.Version {
+	case TPMVersion12:
+		return unseal12(t.RWC, s, password)
+	case TPMVersion20:
+		return unseal20(t.RWC, s, password)
+	}
+	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+func srkAuth12(password string) []byte {
+	var auth [20]byte
+	if password != "" {
+		auth = sha1.Sum([]byte(password))
+	}
+	return auth[:]
+}
+
+func seal12(rwc io.ReadWriter, data []byte, pcrs []int, password string) (*SealedData, error) {
+	// Locality 0.
+	blob, err := tpm1.Seal(rwc, tpm1.Locality(1), pcrs, data, srkAuth12(password))
+	if err != nil {
+		return nil, fmt.Errorf("sealing: %v", err)
+	}
+	return &SealedData{PCRs: pcrs, Blob: blob}, nil
+}
+
+func unseal12(rwc io.ReadWriter, s *SealedData, password string) ([]byte, error) {
+	if s.Blob == nil {
+		return nil, fmt.Errorf("no TPM 1.2 sealed data")
+	}
+	data, err := tpm1.Unseal(rwc, s.Blob, srkAuth12(password))
+	if err != nil {
+		return nil, fmt.Errorf("unsealing: %v", err)
+	}
+	return data, nil
+}
+
+// parent20 returns the handle of the parent of a sealed object, and a
+// function that flushes it if it is a primary key created for the purpose.
+func parent20(rw io.ReadWriter, parent uint32, ownerPassword string) (tpmutil.Handle, func(), error) {
+	if parent != 0 {
+		return tpmutil.Handle(parent), func() {}, nil
+	}
+	srk, _, err := tpm2.CreatePrimary(rw, tpm2.HandleOwner, tpm2.PCRSelection{}, ownerPassword, "", srkTemplate)
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating the primary key: %v", err)
+	}
+	return srk, func() { tpm2.FlushContext(rw, srk) }, nil
+}
+
+// pcrSession starts a policy session, a trial session if trial is set,
+// and satisfies the policy that the SHA256 PCRs pcrs have their current
+// values.
+func pcrSession(rw io.ReadWriter, pcrs []int, trial bool) (tpmutil.Handle, error) {
+	typ := tpm2.SessionPolicy
+	if trial {
+		typ = tpm2.SessionTrial
+	}
+	session, _, err := tpm2.StartAuthSession(rw, tpm2.HandleNull, tpm2.HandleNull, make([]byte, 16), nil, typ, tpm2.AlgNull, tpm2.AlgSHA256)
+	if err != nil {
+		return 0, fmt.Errorf("starting a policy session: %v", err)
+	}
+	if err := tpm2.PolicyPCR(rw, session, nil, tpm2.PCRSelection{Hash: tpm2.AlgSHA256, PCRs: pcrs}); err != nil {
+		tpm2.FlushContext(rw, session)
+		return 0, fmt.Errorf("PCR policy: %v", err)
+	}
+	return session, nil
+}
+
+func seal20(rw io.ReadWriter, data []byte, pcrs []int, password string) (*SealedData, error) {
+	var policy []byte
+	if len(pcrs) > 0 {
+		session, err := pcrSession(rw, pcrs, true)
+		if err != nil {
+			return nil, err
+		}
+		policy, err = tpm2.PolicyGetDigest(rw, session)
+		tpm2.FlushContext(rw, session)
+		if err != nil {
+			return nil, fmt.Errorf("getting the policy digest: %v", err)
+		}
+	}
+
+	parent, flush, err := parent20(rw, 0, password)
+	if err != nil {
+		return nil, err
+	}
+	defer flush()
+	tmpl := tpm2.Public{
+		Type:       tpm2.AlgKeyedHash,
+		NameAlg:    tpm2.AlgSHA256,
+		Attributes: tpm2.FlagFixedTPM | tpm2.FlagFixedParent,
+		AuthPolicy: policy,
+	}
+	// Without a policy, the object is unsealed with its empty password.
+	if policy == nil {
+		tmpl.Attributes |= tpm2.FlagUserWithAuth
+	}
+	priv, pub, _, _, _, err := tpm2.CreateKeyWithSensitive(rw, parent, tpm2.PCRSelection{}, "", "", tmpl, data)
+	if err != nil {
+		return nil, fmt.Errorf("sealing: %v", err)
+	}
+	return &SealedData{PCRs: pcrs, Public: pub, Private: priv}, nil
+}
+
+func unseal20(rw io.ReadWriter, s *SealedData, password string) ([]byte, error) {
+	if s.Public == nil || s.Private == nil {
+		return nil, fmt.Errorf("no TPM 2.0 sealed object")
+	}
+	parent, flush, err := parent20(rw, s.Parent, password)
+	if err != nil {
+		return nil, err
+	}
+	defer flush()
+	obj, _, err := tpm2.Load(rw, parent, "", s.Public, s.Private)
+	if err != nil {
+		return nil, fmt.Errorf("loading the sealed object: %v", err)
+	}
+	defer tpm2.FlushContext(rw, obj)
+
+	if len(s.PCRs) == 0 {
+		data, err := tpm2.Unseal(rw, obj, "")
+		if err != nil {
+			return nil, fmt.Errorf("unsealing: %v", err)
+		}
+		return data, nil
+	}
+	session, err := pcrSession(rw, s.PCRs, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tpm2.FlushContext(rw, session)
+	data, err := tpm2.UnsealWithSession(rw, session, obj, "")
+	if err != nil {
+		return nil, fmt.Errorf("unsealing: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build tpmsimulator
+
+package tss
+
+import (
+	"io"
+	"testing"
+
+	"github.com/google/go-tpm-tools/simulator"
+)
+
+// recorder records the commands sent to a TPM and its responses, and
+// writes them to the transcript of the test when it is closed.
+type recorder struct {
+	t   *testing.T
+	rwc io.ReadWriteCloser
+	ex  []exchange
+}
+
+// testTPM runs the test against the simulator, and records the
+// transcript that is replayed without the tpmsimulator tag.
+func testTPM(t *testing.T) *TPM {
+	s, err := simulator.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TPM{Version: TPMVersion20, RWC: &recorder{t: t, rwc: s}}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.ex = append(r.ex, exchange{command: append([]byte(nil), b...)})
+	return r.rwc.Write(b)
+}
+
+func (r *recorder) Read(b []byte) (int, error) {
+	n, err := r.rwc.Read(b)
+	if len(r.ex) > 0 {
+		e := &r.ex[len(r.ex)-1]
+		e.response = append(e.response, b[:n]...)
+	}
+	return n, err
+}
+
+func (r *recorder) Close() error {
+	if err := writeTranscript(transcriptPath(r.t), r.ex); err != nil {
+		r.t.Error(err)
+	}
+	return r.rwc.Close()
+}
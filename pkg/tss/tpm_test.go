@@ -0,0 +1,234 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// The tests of this file run against the TPM of testTPM: by default, the
+// transcripts in testdata, which the go-tpm-tools simulator records when
+// they run in this directory with
+//     go test -mod=mod -tags tpmsimulator
+// -mod=mod is needed as vendor has not the C sources of the simulator,
+// which needs cgo and OpenSSL 1.1. With OpenSSL 3, whose bignum_st is that
+// of 1.1, copy go-tpm-tools out of the module cache, make the version check
+//     #if OPENSSL_VERSION_NUMBER >= 0x10200000L
+// of simulator/ms-tpm-20-ref/TPMCmd/tpm/include/Ossl/TpmToOsslMath.h read
+// 0x40000000L, and use the copy with
+//     go mod edit -replace github.com/google/go-tpm-tools=/path/to/copy
+// while recording. Seal and quote transcripts change with every recording
+// as the simulator makes up new keys.
+
+// Resettable PCRs, which tests may extend.
+const (
+	debugPCR       = 16
+	applicationPCR = 23
+)
+
+func TestInfo(t *testing.T) {
+	tpm := testTPM(t)
+	defer tpm.Close()
+
+	info, err := tpm.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Manufacturer.String() != "Microsoft" || info.VendorInfo != "xCG fTPM" {
+		t.Errorf("Info() = %+v, want Microsoft's xCG fTPM", info)
+	}
+}
+
+// pcrPolicy is the digest of the policy that the SHA256 PCRs pcrs have
+// the values values, as TPM2_PolicyPCR makes it.
+func pcrPolicy(t *testing.T, pcrs []int, values []byte) []byte {
+	var bitmap [3]byte
+	for _, p := range pcrs {
+		bitmap[p/8] |= 1 << uint(p%8)
+	}
+	pcrDigest := sha256.Sum256(values)
+	b, err := tpmutil.Pack(make([]byte, 32), tpm2.CmdPolicyPCR, uint32(1), tpm2.AlgSHA256, byte(3), bitmap, pcrDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := sha256.Sum256(b)
+	return d[:]
+}
+
+func TestSealUnseal(t *testing.T) {
+	tpm := testTPM(t)
+	defer tpm.Close()
+	secret := []byte("disk key")
+
+	s, err := tpm.Seal(secret, []int{debugPCR, applicationPCR}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := tpm2.DecodePublic(s.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The PCRs are still zero.
+	if want := pcrPolicy(t, []int{debugPCR, applicationPCR}, make([]byte, 64)); !bytes.Equal(pub.AuthPolicy, want) {
+		t.Errorf("sealed object has policy %x, want %x", pub.AuthPolicy, want)
+	}
+	got, err := tpm.Unseal(s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("Unseal() = %q, want %q", got, secret)
+	}
+
+	// Not sealed to PCRs.
+	u, err := tpm.Seal(secret, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tpm.Measure([]byte("something else"), applicationPCR); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tpm.Unseal(s, ""); err == nil {
+		t.Errorf("Unseal() after extending a PCR = %q, want error", got)
+	}
+	if got, err := tpm.Unseal(u, ""); err != nil || !bytes.Equal(got, secret) {
+		t.Errorf("Unseal() without PCRs = %q, %v, want %q", got, err, secret)
+	}
+}
+
+func TestNV(t *testing.T) {
+	tpm := testTPM(t)
+	defer tpm.Close()
+	const index = 0x1500016
+	data := bytes.Repeat([]byte("0123456789abcdef"), 100)
+
+	attr := tpm2.AttrAuthWrite | tpm2.AttrAuthRead | tpm2.AttrWriteSTClear
+	if err := tpm.NVDefine(index, uint16(len(data)), uint32(attr), "", "nv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tpm.NVWriteValue(index, index, "nv", data, 0); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tpm.NVReadValue(index, "nv", 0, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("NVReadValue() = %q, want %q", got, data)
+	}
+	if err := tpm.NVWriteValue(index, index, "wrong", data[:1], 0); err == nil {
+		t.Errorf("NVWriteValue() with the wrong password succeeded")
+	}
+
+	if err := tpm.NVLock(index, index, "nv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tpm.NVWriteValue(index, index, "nv", data[:1], 0); err == nil {
+		t.Errorf("NVWriteValue() after NVLock() succeeded")
+	}
+	if err := tpm.NVUndefine(index, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpm.NVReadValue(index, "nv", 0, index); err == nil {
+		t.Errorf("NVReadValue() after NVUndefine() succeeded")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tpm := testTPM(t)
+	defer tpm.Close()
+
+	ak, err := tpm.CreateAK("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ak.Close()
+	if err := tpm.Measure([]byte("kernel"), applicationPCR); err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := []byte("fresh")
+	q, err := ak.Quote(nonce, []int{0, debugPCR, applicationPCR})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Verify(ak.Public, nonce); err != nil {
+		t.Errorf("Verify() = %v, want nil", err)
+	}
+	if err := q.Verify(ak.Public, []byte("stale")); err == nil {
+		t.Errorf("Verify() with another nonce succeeded")
+	}
+	pub, err := tpm2.DecodePublic(ak.PublicArea)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.Attributes&tpm2.FlagRestricted == 0 {
+		t.Errorf("attestation key is not restricted")
+	}
+}
+
+func TestValidateLog(t *testing.T) {
+	tpm := testTPM(t)
+	defer tpm.Close()
+
+	var log []Measurement
+	for _, e := range []struct {
+		pcr  int
+		data string
+	}{
+		{debugPCR, "bootloader"},
+		{applicationPCR, "kernel"},
+		{debugPCR, "initramfs"},
+	} {
+		if err := tpm.Measure([]byte(e.data), uint32(e.pcr)); err != nil {
+			t.Fatal(err)
+		}
+		d := sha256.Sum256([]byte(e.data))
+		log = append(log, Measurement{PCR: e.pcr, Digest: d[:]})
+	}
+	bad, err := tpm.ValidateLog(log)
+	if err != nil || bad != nil {
+		t.Errorf("ValidateLog() = %v, %v, want no mismatches", bad, err)
+	}
+
+	// A measurement that is not logged.
+	if err := tpm.Measure([]byte("rootkit"), applicationPCR); err != nil {
+		t.Fatal(err)
+	}
+	bad, err = tpm.ValidateLog(log)
+	if err != nil || len(bad) != 1 || bad[0].PCR != applicationPCR {
+		t.Errorf("ValidateLog() = %v, %v, want a mismatch of PCR %d", bad, err, applicationPCR)
+	}
+}
+
+func TestReadPCRBank(t *testing.T) {
+	tpm := testTPM(t)
+	defer tpm.Close()
+
+	for _, h := range []crypto.Hash{crypto.SHA1, crypto.SHA256} {
+		pcrs, err := tpm.ReadPCRBank(h)
+		if err != nil {
+			t.Fatalf("ReadPCRBank(%v) = %v", h, err)
+		}
+		if len(pcrs) != 24 {
+			t.Errorf("ReadPCRBank(%v) read %d PCRs, want 24", h, len(pcrs))
+		}
+		for i, p := range pcrs {
+			if p.Index != i || p.DigestAlg != h || len(p.Digest) != h.Size() {
+				t.Errorf("ReadPCRBank(%v)[%d] = %+v", h, i, p)
+			}
+		}
+	}
+	if _, err := tpm.ReadPCRBank(crypto.MD5); err == nil {
+		t.Errorf("ReadPCRBank(MD5) succeeded")
+	}
+}
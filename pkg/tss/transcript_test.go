@@ -0,0 +1,103 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tss
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exchange is a command sent to a TPM and its response.
+type exchange struct {
+	command  []byte
+	response []byte
+}
+
+// transcriptPath is the transcript of the TPM commands of test t. Each
+// line is "> " and a command in hex, or "< " and the response to it.
+func transcriptPath(t *testing.T) string {
+	return filepath.Join("testdata", t.Name()+".txt")
+}
+
+func readTranscript(path string) ([]exchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ex []exchange
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		l := s.Text()
+		if len(l) < 2 || (l[:2] != "> " && l[:2] != "< ") {
+			return nil, fmt.Errorf("%s:%d: want \"> \" or \"< \" and hex", path, line)
+		}
+		b, err := hex.DecodeString(l[2:])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		switch {
+		case l[0] == '>':
+			ex = append(ex, exchange{command: b})
+		case len(ex) == 0 || ex[len(ex)-1].response != nil:
+			return nil, fmt.Errorf("%s:%d: response without a command", path, line)
+		default:
+			ex[len(ex)-1].response = b
+		}
+	}
+	return ex, s.Err()
+}
+
+func writeTranscript(path string, ex []exchange) error {
+	var b bytes.Buffer
+	for _, e := range ex {
+		fmt.Fprintf(&b, "> %x\n", e.command)
+		if e.response != nil {
+			fmt.Fprintf(&b, "< %x\n", e.response)
+		}
+	}
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
+
+func TestReadTranscript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "t.txt")
+
+	want := []exchange{
+		{command: []byte{0x80, 0x01}, response: []byte{0x80, 0x01, 0, 0}},
+		{command: []byte{0x80, 0x02}},
+	}
+	if err := writeTranscript(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readTranscript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("readTranscript() = %x, want %x", got, want)
+	}
+
+	for _, bad := range []string{"< 8001\n", "> 8001\n< zz\n", "8001\n"} {
+		if err := ioutil.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readTranscript(path); err == nil {
+			t.Errorf("readTranscript(%q) = nil, want an error", strings.TrimSpace(bad))
+		}
+	}
+}
@@ -67,24 +67,49 @@ func (t *TPM) Close() error {
 
 // ReadPCRs reads all PCRs into the PCR structure
 func (t *TPM) ReadPCRs() ([]PCR, error) {
+	switch t.Version {
+	case TPMVersion12:
+		return t.ReadPCRBank(crypto.SHA1)
+	case TPMVersion20:
+		return t.ReadPCRBank(crypto.SHA256)
+	}
+	return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
+}
+
+// ReadPCRBank reads all PCRs of the bank of the hash h. TPM 1.2 only has
+// the SHA1 bank; a TPM 2.0 has the banks that are allocated.
+func (t *TPM) ReadPCRBank(h crypto.Hash) ([]PCR, error) {
 	var PCRs map[uint32][]byte
 	var err error
-	var alg crypto.Hash
 
 	switch t.Version {
 	case TPMVersion12:
+		if h != crypto.SHA1 {
+			return nil, fmt.Errorf("TPM 1.2 has no %v PCRs", h)
+		}
 		PCRs, err = readAllPCRs12(t.RWC)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read PCRs: %v", err)
 		}
-		alg = crypto.SHA1
 
 	case TPMVersion20:
-		PCRs, err = readAllPCRs20(t.RWC, tpm2.AlgSHA256)
+		var alg tpm2.Algorithm
+		switch h {
+		case crypto.SHA1:
+			alg = tpm2.AlgSHA1
+		case crypto.SHA256:
+			alg = tpm2.AlgSHA256
+		case crypto.SHA384:
+			alg = tpm2.AlgSHA384
+		case crypto.SHA512:
+			alg = tpm2.AlgSHA512
+		default:
+			return nil, fmt.Errorf("unsupported PCR bank %v", h)
+		}
+		PCRs, err = readAllPCRs20(t.RWC, alg)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read PCRs: %v", err)
+			return nil, fmt.Errorf("failed to read %v PCRs: %v", h, err)
 		}
-		alg = crypto.SHA1
 
 	default:
 		return nil, fmt.Errorf("unsupported TPM version: %x", t.Version)
@@ -95,7 +120,7 @@ func (t *TPM) ReadPCRs() ([]PCR, error) {
 		out[int(index)] = PCR{
 			Index:     int(index),
 			Digest:    digest,
-			DigestAlg: alg,
+			DigestAlg: h,
 		}
 	}
 
@@ -0,0 +1,334 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package txtlog
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/u-root/u-root/pkg/uefivars/boot"
+)
+
+var errUnknownEvent = errors.New("Event type couldn't get parsed")
+
+// startupLocalitySignature starts the data of the EV_NO_ACTION event that
+// records the startup locality, see [4].
+const startupLocalitySignature = "StartupLocality\x00"
+
+// EventData decodes the data of an event of type eventType. It returns
+// one of the EFI* and TCG* structures for events that have them, and a
+// string for events that record text, such as EV_EFI_ACTION and EV_IPL.
+func EventData(eventType uint32, eventData []byte) (interface{}, error) {
+	if eventType < uint32(EvEFIEventBase) {
+		switch BIOSLogID(eventType) {
+		case EvNoAction:
+			if l, ok := parseStartupLocality(eventData); ok {
+				return l, nil
+			}
+			// The SpecID events are only decoded to read the log.
+			if len(eventData) >= 16 && bytes.HasPrefix(eventData, []byte("Spec ID Event")) {
+				return string(bytes.Trim(eventData[:16], "\x00")), nil
+			}
+			return string(bytes.Trim(eventData, "\x00")), nil
+		case EvSeparator:
+			return fmt.Sprintf("%x", eventData), nil
+		case EvAction, EvPostCode, EvSCRTMContents, EvIPL:
+			return string(bytes.Trim(eventData, "\x00")), nil
+		case EvSCRTMVersion:
+			// The version is a UCS-2 string, or a GUID.
+			if len(eventData)%2 == 0 && len(eventData) != 16 {
+				version := make([]uint16, len(eventData)/2)
+				binary.Read(bytes.NewReader(eventData), binary.LittleEndian, version)
+				return strings.TrimRight(string(utf16.Decode(version)), "\x00"), nil
+			}
+			return fmt.Sprintf("%x", eventData), nil
+		case EvOmitBootDeviceEvents:
+			return "BOOT ATTEMPTS OMITTED", nil
+		case EvEventTag:
+			return parseTaggedEvent(eventData)
+		}
+	} else {
+		switch EFILogID(eventType) {
+		case EvEFIHCRTMEvent:
+			return HCRTM, nil
+		case EvEFIAction:
+			return string(bytes.Trim(eventData, "\x00")), nil
+		case EvEFIVariableDriverConfig, EvEFIVariableBoot, EvEFIVariableAuthority:
+			return parseVariableData(EFILogID(eventType), eventData)
+		case EvEFIRuntimeServicesDriver, EvEFIBootServicesDriver, EvEFIBootServicesApplication:
+			return parseImageLoadEvent(eventData)
+		case EvEFIGPTEvent:
+			return parseGPTEvent(eventData)
+		case EvEFIPlatformFirmwareBlob:
+			return parsePlatformFirmwareBlob(eventData)
+		case EvEFIHandoffTables:
+			return parseHandoffTablePointers(eventData)
+		}
+	}
+	return nil, errUnknownEvent
+}
+
+// readBytes reads n bytes of event data, which must have them.
+func readBytes(r *bytes.Reader, n uint64) ([]byte, error) {
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+func parseStartupLocality(eventData []byte) (*TCGStartupLocality, bool) {
+	if len(eventData) != len(startupLocalitySignature)+1 || !bytes.HasPrefix(eventData, []byte(startupLocalitySignature)) {
+		return nil, false
+	}
+	return &TCGStartupLocality{StartupLocality: eventData[len(eventData)-1]}, true
+}
+
+func (l *TCGStartupLocality) String() string {
+	return fmt.Sprintf("Startup locality - %d", l.StartupLocality)
+}
+
+func parseTaggedEvent(eventData []byte) (*TCGPCClientTaggedEvent, error) {
+	var eventReader = bytes.NewReader(eventData)
+	var taggedEvent TCGPCClientTaggedEvent
+	var size uint32
+
+	if err := binary.Read(eventReader, binary.LittleEndian, &taggedEvent.TaggedEventID); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(eventReader, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	data, err := readBytes(eventReader, uint64(size))
+	if err != nil {
+		return nil, err
+	}
+	taggedEvent.TaggedEventData = data
+	return &taggedEvent, nil
+}
+
+func (e *TCGPCClientTaggedEvent) String() string {
+	return fmt.Sprintf("Tag ID - %d - %s", e.TaggedEventID, string(e.TaggedEventData))
+}
+
+func parseHandoffTablePointers(eventData []byte) (*EFIHandoffTablePointers, error) {
+	var eventReader = bytes.NewReader(eventData)
+	var numberOfTables uint64
+
+	if err := binary.Read(eventReader, binary.LittleEndian, &numberOfTables); err != nil {
+		return nil, err
+	}
+	// Each table is a GUID and a pointer.
+	if numberOfTables > uint64(eventReader.Len()/24) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	handoffTablePointers := &EFIHandoffTablePointers{Tables: make([]EFIConfigurationTable, numberOfTables)}
+	for i := range handoffTablePointers.Tables {
+		if err := binary.Read(eventReader, binary.LittleEndian, &handoffTablePointers.Tables[i]); err != nil {
+			return nil, err
+		}
+	}
+	return handoffTablePointers, nil
+}
+
+func (e *EFIHandoffTablePointers) String() string {
+	tables := make([]string, len(e.Tables))
+	for i, table := range e.Tables {
+		tables[i] = fmt.Sprintf("At address 0x%x with Guid %s", table.VendorTable, table.VendorGUID)
+	}
+	return "Tables: " + strings.Join(tables, ", ")
+}
+
+func parsePlatformFirmwareBlob(eventData []byte) (*EFIPlatformFirmwareBlob, error) {
+	var platformFirmwareBlob EFIPlatformFirmwareBlob
+
+	if err := binary.Read(bytes.NewReader(eventData), binary.LittleEndian, &platformFirmwareBlob); err != nil {
+		return nil, err
+	}
+	return &platformFirmwareBlob, nil
+}
+
+func (e *EFIPlatformFirmwareBlob) String() string {
+	return fmt.Sprintf("Blob address - 0x%x - with size - %db", e.BlobBase, e.BlobLength)
+}
+
+// gptHeader is the EFI_PARTITION_TABLE_HEADER, which is measured with
+// its size rather than HeaderSize, see [1].
+type gptHeader struct {
+	Signature                [8]byte
+	Revision                 uint32
+	HeaderSize               uint32
+	HeaderCRC32              uint32
+	Reserved                 uint32
+	MyLBA                    uint64
+	AlternateLBA             uint64
+	FirstUsableLBA           uint64
+	LastUsableLBA            uint64
+	DiskGUID                 EFIGuid
+	PartitionEntryLBA        uint64
+	NumberOfPartitionEntries uint32
+	SizeOfPartitionEntry     uint32
+	PartitionEntryArrayCRC32 uint32
+}
+
+// gptEntry is the start of an EFI_PARTITION_ENTRY; entries may be longer.
+type gptEntry struct {
+	PartitionTypeGUID   EFIGuid
+	UniquePartitionGUID EFIGuid
+	StartingLBA         uint64
+	EndingLBA           uint64
+	Attributes          uint64
+	PartitionName       [36]uint16
+}
+
+func parseGPTEvent(eventData []byte) (*EFIGptData, error) {
+	var eventReader = bytes.NewReader(eventData)
+	var header gptHeader
+	var numberOfPartitions uint64
+
+	if err := binary.Read(eventReader, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Signature[:]) != "EFI PART" {
+		return nil, fmt.Errorf("bad GPT signature %q", header.Signature[:])
+	}
+	if err := binary.Read(eventReader, binary.LittleEndian, &numberOfPartitions); err != nil {
+		return nil, err
+	}
+	entrySize := uint64(header.SizeOfPartitionEntry)
+	if entrySize < uint64(binary.Size(gptEntry{})) {
+		return nil, fmt.Errorf("GPT partition entries of %d bytes are too small", entrySize)
+	}
+	if numberOfPartitions > uint64(eventReader.Len())/entrySize {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	gptEvent := &EFIGptData{DiskGUID: header.DiskGUID, Partitions: make([]EFIGptPartition, numberOfPartitions)}
+	for i := range gptEvent.Partitions {
+		b, err := readBytes(eventReader, entrySize)
+		if err != nil {
+			return nil, err
+		}
+		var entry gptEntry
+		if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &entry); err != nil {
+			return nil, err
+		}
+		name := entry.PartitionName[:]
+		for j, c := range name {
+			if c == 0 {
+				name = name[:j]
+				break
+			}
+		}
+		gptEvent.Partitions[i] = EFIGptPartition{
+			TypeGUID:      entry.PartitionTypeGUID,
+			PartitionGUID: entry.UniquePartitionGUID,
+			StartingLBA:   entry.StartingLBA,
+			EndingLBA:     entry.EndingLBA,
+			Attributes:    entry.Attributes,
+			Name:          string(utf16.Decode(name)),
+		}
+	}
+	return gptEvent, nil
+}
+
+func (e *EFIGptData) String() string {
+	return fmt.Sprintf("Disk Guid - %s - with %d partitions", e.DiskGUID, len(e.Partitions))
+}
+
+func parseImageLoadEvent(eventData []byte) (*EFIImageLoadEvent, error) {
+	var eventReader = bytes.NewReader(eventData)
+	var imageLoadEvent struct {
+		ImageLocationInMemory uint64
+		ImageLengthInMemory   uint64
+		ImageLinkTimeAddress  uint64
+		LengthOfDevicePath    uint64
+	}
+
+	if err := binary.Read(eventReader, binary.LittleEndian, &imageLoadEvent); err != nil {
+		return nil, err
+	}
+	devicePath, err := readBytes(eventReader, imageLoadEvent.LengthOfDevicePath)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &EFIImageLoadEvent{
+		ImageLocationInMemory: imageLoadEvent.ImageLocationInMemory,
+		ImageLengthInMemory:   imageLoadEvent.ImageLengthInMemory,
+		ImageLinkTimeAddress:  imageLoadEvent.ImageLinkTimeAddress,
+		RawDevicePath:         devicePath,
+	}
+	if list, err := boot.ParseFilePathList(devicePath); err == nil {
+		e.DevicePath = list.String()
+	}
+	return e, nil
+}
+
+func (e *EFIImageLoadEvent) String() string {
+	s := fmt.Sprintf("Image loaded at address 0x%x with %db", e.ImageLocationInMemory, e.ImageLengthInMemory)
+	if e.DevicePath != "" {
+		s += " from " + e.DevicePath
+	}
+	return s
+}
+
+func parseVariableData(eventType EFILogID, eventData []byte) (*EFIVariableData, error) {
+	var eventReader = bytes.NewReader(eventData)
+	var variableData EFIVariableData
+	var unicodeNameLength, variableDataLength uint64
+
+	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.VariableName); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(eventReader, binary.LittleEndian, &unicodeNameLength); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(eventReader, binary.LittleEndian, &variableDataLength); err != nil {
+		return nil, err
+	}
+	if unicodeNameLength > uint64(eventReader.Len()/2) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	unicodeName := make([]uint16, unicodeNameLength)
+	if err := binary.Read(eventReader, binary.LittleEndian, &unicodeName); err != nil {
+		return nil, err
+	}
+	variableData.UnicodeName = string(utf16.Decode(unicodeName))
+	data, err := readBytes(eventReader, variableDataLength)
+	if err != nil {
+		return nil, err
+	}
+	variableData.VariableData = data
+
+	// EV_EFI_VARIABLE_AUTHORITY events measure the EFI_SIGNATURE_DATA
+	// that authorized an image: the owner's GUID and, mostly, a
+	// certificate.
+	if eventType == EvEFIVariableAuthority && len(data) >= 16 {
+		s := &EFISignatureData{SignatureData: data[16:]}
+		copy(s.SignatureOwner[:], data)
+		if cert, err := x509.ParseCertificate(s.SignatureData); err == nil {
+			s.Subject = cert.Subject.String()
+		}
+		variableData.Signature = s
+	}
+	return &variableData, nil
+}
+
+func (e *EFIVariableData) String() string {
+	s := fmt.Sprintf("Variable - %s - %s", e.VariableName, e.UnicodeName)
+	if e.Signature != nil && e.Signature.Subject != "" {
+		s += " - " + e.Signature.Subject
+	}
+	return s
+}
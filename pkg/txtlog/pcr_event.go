@@ -6,7 +6,8 @@ package txtlog
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -177,6 +178,10 @@ func (e *TcgPcrEvent) PcrEventData() string {
 	return ""
 }
 
+func (e *TcgPcrEvent) PcrEventRaw() []byte {
+	return e.event
+}
+
 func (e *TcgPcrEvent) Digests() *[]PCRDigestValue {
 	d := make([]PCRDigestValue, 1)
 	d[0].DigestAlg = TPMAlgSha
@@ -197,8 +202,13 @@ func (e *TcgPcrEvent) String() string {
 	return b.String()
 }
 
+// MarshalJSON implements json.Marshaler
+func (e *TcgPcrEvent) MarshalJSON() ([]byte, error) {
+	return marshalEvent(e)
+}
+
 // TcgPcrEvent2 parser and PCREvent interface implementation
-func parseTcgPcrEvent2(handle io.Reader) (*TcgPcrEvent2, error) {
+func parseTcgPcrEvent2(handle io.Reader, digestSizes map[IAlgHash]uint16) (*TcgPcrEvent2, error) {
 	var endianess binary.ByteOrder = binary.LittleEndian
 	var pcrEvent TcgPcrEvent2
 
@@ -218,7 +228,11 @@ func parseTcgPcrEvent2(handle io.Reader) (*TcgPcrEvent2, error) {
 			return nil, err
 		}
 
-		pcrEvent.digests.digests[i].digest.hash = make([]byte, HashAlgoToSize[pcrEvent.digests.digests[i].hashAlg])
+		size, ok := digestSizes[pcrEvent.digests.digests[i].hashAlg]
+		if !ok {
+			return nil, fmt.Errorf("digest of algorithm %v is not in the SpecID event", pcrEvent.digests.digests[i].hashAlg)
+		}
+		pcrEvent.digests.digests[i].digest.hash = make([]byte, size)
 		if err := binary.Read(handle, endianess, &pcrEvent.digests.digests[i].digest.hash); err != nil {
 			return nil, err
 		}
@@ -270,11 +284,15 @@ func (e *TcgPcrEvent2) PcrEventData() string {
 	return ""
 }
 
+func (e *TcgPcrEvent2) PcrEventRaw() []byte {
+	return e.event
+}
+
 func (e *TcgPcrEvent2) Digests() *[]PCRDigestValue {
 	d := make([]PCRDigestValue, e.digests.count)
 	for i := uint32(0); i < e.digests.count; i++ {
 		d[i].DigestAlg = e.digests.digests[i].hashAlg
-		d[i].Digest = make([]byte, HashAlgoToSize[e.digests.digests[i].hashAlg])
+		d[i].Digest = make([]byte, len(e.digests.digests[i].digest.hash))
 		copy(d[i].Digest, e.digests.digests[i].digest.hash)
 	}
 	return &d
@@ -288,20 +306,7 @@ func (e *TcgPcrEvent2) String() string {
 	fmt.Fprintf(&b, "Event Data: %s\n", stripControlSequences(e.PcrEventData()))
 	for i := uint32(0); i < e.digests.count; i++ {
 		d := &e.digests.digests[i]
-		switch d.hashAlg {
-		case TPMAlgSha:
-			b.WriteString("SHA1 Digest: ")
-		case TPMAlgSha256:
-			b.WriteString("SHA256 Digest: ")
-		case TPMAlgSha384:
-			b.WriteString("SHA384 Digest: ")
-		case TPMAlgSha512:
-			b.WriteString("SHA512 Digest: ")
-		case TPMAlgSm3s256:
-			b.WriteString("SM3 Digest: ")
-		}
-
-		fmt.Fprintf(&b, "%x\n", d.digest.hash)
+		fmt.Fprintf(&b, "%v Digest: %x\n", d.hashAlg, d.digest.hash)
 	}
 
 	return b.String()
@@ -342,76 +347,16 @@ func readTxtEventLogContainer(handle io.Reader) (*TxtEventLogContainer, error) {
 }
 
 func getEventDataString(eventType uint32, eventData []byte) (*string, error) {
-	if eventType < uint32(EvEFIEventBase) {
-		switch BIOSLogID(eventType) {
-		case EvSeparator:
-			eventInfo := fmt.Sprintf("%x", eventData)
-			return &eventInfo, nil
-		case EvAction:
-			eventInfo := string(bytes.Trim(eventData, "\x00"))
-			return &eventInfo, nil
-		case EvOmitBootDeviceEvents:
-			eventInfo := string("BOOT ATTEMPTS OMITTED")
-			return &eventInfo, nil
-		case EvPostCode:
-			eventInfo := string(bytes.Trim(eventData, "\x00"))
-			return &eventInfo, nil
-		case EvEventTag:
-			eventInfo, err := getTaggedEvent(eventData)
-			if err != nil {
-				return nil, err
-			}
-			return eventInfo, nil
-		case EvSCRTMContents:
-			eventInfo := string(bytes.Trim(eventData, "\x00"))
-			return &eventInfo, nil
-		case EvIPL:
-			eventInfo := string(bytes.Trim(eventData, "\x00"))
-			return &eventInfo, nil
-		}
-	} else {
-		switch EFILogID(eventType) {
-		case EvEFIHCRTMEvent:
-			eventInfo := "HCRTM"
-			return &eventInfo, nil
-		case EvEFIAction:
-			eventInfo := string(bytes.Trim(eventData, "\x00"))
-			return &eventInfo, nil
-		case EvEFIVariableDriverConfig, EvEFIVariableBoot, EvEFIVariableAuthority:
-			eventInfo, err := getVariableDataString(eventData)
-			if err != nil {
-				return nil, err
-			}
-			return eventInfo, nil
-		case EvEFIRuntimeServicesDriver, EvEFIBootServicesDriver, EvEFIBootServicesApplication:
-			eventInfo, err := getImageLoadEventString(eventData)
-			if err != nil {
-				return nil, err
-			}
-			return eventInfo, nil
-		case EvEFIGPTEvent:
-			eventInfo, err := getGPTEventString(eventData)
-			if err != nil {
-				return nil, err
-			}
-			return eventInfo, nil
-		case EvEFIPlatformFirmwareBlob:
-			eventInfo, err := getPlatformFirmwareBlob(eventData)
-			if err != nil {
-				return nil, err
-			}
-			return eventInfo, nil
-		case EvEFIHandoffTables:
-			eventInfo, err := getHandoffTablePointers(eventData)
-			if err != nil {
-				return nil, err
-			}
-			return eventInfo, nil
-		}
+	data, err := EventData(eventType, eventData)
+	if err == errUnknownEvent {
+		eventInfo := string(bytes.Trim(eventData, "\x00"))
+		return &eventInfo, err
 	}
-
-	eventInfo := string(bytes.Trim(eventData, "\x00"))
-	return &eventInfo, errors.New("Event type couldn't get parsed")
+	if err != nil {
+		return nil, err
+	}
+	eventInfo := fmt.Sprint(data)
+	return &eventInfo, nil
 }
 
 func stripControlSequences(str string) string {
@@ -426,3 +371,36 @@ func stripControlSequences(str string) string {
 	}
 	return string(b[:bl])
 }
+
+// MarshalJSON implements json.Marshaler
+func (e *TcgPcrEvent2) MarshalJSON() ([]byte, error) {
+	return marshalEvent(e)
+}
+
+// jsonEvent is the JSON form of a PCREvent.
+type jsonEvent struct {
+	PCR     int                 `json:"pcr"`
+	Type    uint32              `json:"type"`
+	Name    string              `json:"name,omitempty"`
+	Digests map[IAlgHash]string `json:"digests"`
+	// Data is the decoded event data, see EventData.
+	Data interface{} `json:"data,omitempty"`
+	Raw  []byte      `json:"raw"`
+}
+
+func marshalEvent(e PCREvent) ([]byte, error) {
+	j := jsonEvent{
+		PCR:     e.PcrIndex(),
+		Type:    e.PcrEventType(),
+		Name:    e.PcrEventName(),
+		Digests: map[IAlgHash]string{},
+		Raw:     e.PcrEventRaw(),
+	}
+	for _, d := range *e.Digests() {
+		j.Digests[d.DigestAlg] = hex.EncodeToString(d.Digest)
+	}
+	if data, err := EventData(e.PcrEventType(), j.Raw); err == nil {
+		j.Data = data
+	}
+	return json.Marshal(j)
+}
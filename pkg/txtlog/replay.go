@@ -0,0 +1,135 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package txtlog
+
+import (
+	"bytes"
+	"crypto"
+	// The hashes of the PCR banks.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	tss "github.com/u-root/u-root/pkg/tss"
+)
+
+var algHashes = map[IAlgHash]crypto.Hash{
+	TPMAlgSha:    crypto.SHA1,
+	TPMAlgSha256: crypto.SHA256,
+	TPMAlgSha384: crypto.SHA384,
+	TPMAlgSha512: crypto.SHA512,
+}
+
+// Hash returns the hash of the algorithm, or 0 if there is none in Go,
+// as for SM3.
+func (a IAlgHash) Hash() crypto.Hash {
+	return algHashes[a]
+}
+
+// PCRBanks are PCR values by bank and PCR index.
+type PCRBanks map[IAlgHash]map[int][]byte
+
+// Replay extends the digests of the events of the log into PCRs, which
+// start from zeros, bank by bank, and returns the values the PCRs must
+// have. Banks of algorithms that have no hash in Go are left out.
+//
+// EV_NO_ACTION events are not extended, but a StartupLocality event sets
+// the initial value of PCR 0 to the locality, see [4].
+func (l *PCRLog) Replay() (PCRBanks, error) {
+	banks := PCRBanks{}
+	var locality byte
+	for i, e := range l.PcrList {
+		if BIOSLogID(e.PcrEventType()) == EvNoAction {
+			if s, ok := parseStartupLocality(e.PcrEventRaw()); ok {
+				locality = s.StartupLocality
+			}
+			continue
+		}
+		for _, d := range *e.Digests() {
+			h := d.DigestAlg.Hash()
+			if h == 0 || !h.Available() {
+				continue
+			}
+			if len(d.Digest) != h.Size() {
+				return nil, fmt.Errorf("event %d has a %v digest of %d bytes, want %d", i, d.DigestAlg, len(d.Digest), h.Size())
+			}
+			pcrs, ok := banks[d.DigestAlg]
+			if !ok {
+				pcrs = map[int][]byte{}
+				banks[d.DigestAlg] = pcrs
+			}
+			v, ok := pcrs[e.PcrIndex()]
+			if !ok {
+				v = make([]byte, h.Size())
+				if e.PcrIndex() == 0 {
+					v[len(v)-1] = locality
+				}
+			}
+			x := h.New()
+			x.Write(v)
+			x.Write(d.Digest)
+			pcrs[e.PcrIndex()] = x.Sum(nil)
+		}
+	}
+	return banks, nil
+}
+
+// PCRMismatch is a PCR of a bank whose value differs from the one
+// replaying the log gives.
+type PCRMismatch struct {
+	Bank     IAlgHash
+	PCR      int
+	Replayed []byte
+	Actual   []byte
+}
+
+func (m PCRMismatch) String() string {
+	return fmt.Sprintf("%v PCR %d is %x, the log gives %x", m.Bank, m.PCR, m.Actual, m.Replayed)
+}
+
+// MarshalJSON implements json.Marshaler
+func (m PCRMismatch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Bank     IAlgHash `json:"bank"`
+		PCR      int      `json:"pcr"`
+		Replayed string   `json:"replayed"`
+		Actual   string   `json:"actual"`
+	}{m.Bank, m.PCR, hex.EncodeToString(m.Replayed), hex.EncodeToString(m.Actual)})
+}
+
+// Verify replays the log and compares the PCRs it extends with pcrs, which
+// may be of several banks, as (*tss.TPM).ReadPCRBank reads them. It
+// returns the PCRs that differ, by bank and PCR index.
+//
+// PCRs the log does not extend are not compared, nor are those of banks
+// the log has no digests for.
+func (l *PCRLog) Verify(pcrs []tss.PCR) ([]PCRMismatch, error) {
+	banks, err := l.Replay()
+	if err != nil {
+		return nil, err
+	}
+	var bad []PCRMismatch
+	for _, p := range pcrs {
+		for alg, h := range algHashes {
+			if h != p.DigestAlg {
+				continue
+			}
+			if v, ok := banks[alg][p.Index]; ok && !bytes.Equal(v, p.Digest) {
+				bad = append(bad, PCRMismatch{Bank: alg, PCR: p.Index, Replayed: v, Actual: p.Digest})
+			}
+		}
+	}
+	sort.Slice(bad, func(i, j int) bool {
+		if bad[i].Bank != bad[j].Bank {
+			return bad[i].Bank < bad[j].Bank
+		}
+		return bad[i].PCR < bad[j].PCR
+	})
+	return bad, nil
+}
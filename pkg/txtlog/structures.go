@@ -4,7 +4,9 @@
 package txtlog
 
 import (
-	"github.com/rekby/gpt"
+	"fmt"
+
+	"github.com/u-root/u-root/pkg/uefivars"
 )
 
 // IAlgHash is the TPM hash algorithm
@@ -22,6 +24,27 @@ const (
 	TPMAlgSm3s256 IAlgHash = 0x0012
 )
 
+var algHashNames = map[IAlgHash]string{
+	TPMAlgSha:     "SHA1",
+	TPMAlgSha256:  "SHA256",
+	TPMAlgSha384:  "SHA384",
+	TPMAlgSha512:  "SHA512",
+	TPMAlgSm3s256: "SM3",
+}
+
+func (a IAlgHash) String() string {
+	if n, ok := algHashNames[a]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%04x", uint16(a))
+}
+
+// MarshalText implements encoding.TextMarshaler, so that algorithms, and
+// the banks they name, are strings in JSON.
+func (a IAlgHash) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
 // IAlgHashSize is the TPM hash algorithm length
 type IAlgHashSize uint8
 
@@ -40,70 +63,93 @@ const (
 
 // [1] https://members.uefi.org/kws/documents/UEFI_Spec_2_7_A_Sept_6.pdf
 
-// EFIGuid is the EFI Guid format
-type EFIGuid struct {
-	blockA uint32
-	blockB uint16
-	blockC uint16
-	blockD uint16
-	blockE [6]uint8
+// EFIGuid is the EFI Guid format, in the mixed endianness UEFI uses.
+type EFIGuid uefivars.MixedGUID
+
+func (g EFIGuid) String() string {
+	return uefivars.MixedGUID(g).String()
 }
 
-// EFIConfigurationTable is an internal UEFI structure see [1]
-type EFIConfigurationTable struct {
-	vendorGUID  EFIGuid
-	vendorTable uint64
+// MarshalText implements encoding.TextMarshaler, so that GUIDs are strings
+// in JSON.
+func (g EFIGuid) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
 }
 
-// EFIDevicePath is an internal UEFI structure see [1]
-type EFIDevicePath struct {
-	pathType    uint8
-	pathSubType uint8
-	length      [2]uint8
+// EFIConfigurationTable is an internal UEFI structure see [1]
+type EFIConfigurationTable struct {
+	VendorGUID  EFIGuid `json:"vendor_guid"`
+	VendorTable uint64  `json:"vendor_table"`
 }
 
 // TCGPCClientTaggedEvent is an legacy tag structure
 type TCGPCClientTaggedEvent struct {
-	taggedEventID       uint32
-	taggedEventDataSize uint32
-	taggedEventData     []byte
+	TaggedEventID   uint32 `json:"tagged_event_id"`
+	TaggedEventData []byte `json:"tagged_event_data"`
 }
 
 // EFIImageLoadEvent is an internal UEFI structure see [1]
 type EFIImageLoadEvent struct {
-	imageLocationInMemory uint64
-	imageLengthInMemory   uint64
-	imageLinkTimeAddress  uint64
-	lengthOfDevicePath    uint64
-	devicePath            []EFIDevicePath
+	ImageLocationInMemory uint64 `json:"image_location_in_memory"`
+	ImageLengthInMemory   uint64 `json:"image_length_in_memory"`
+	ImageLinkTimeAddress  uint64 `json:"image_link_time_address"`
+	// DevicePath is the decoded device path of the image, or empty if
+	// it could not be decoded.
+	DevicePath    string `json:"device_path,omitempty"`
+	RawDevicePath []byte `json:"raw_device_path"`
 }
 
-// EFIGptData is the GPT structure
+// EFIGptData is the GPT structure: the GPT header and the partitions of
+// the boot disk.
 type EFIGptData struct {
-	uefiPartitionHeader gpt.Header
-	numberOfPartitions  uint64
-	uefiPartitions      []gpt.Partition
+	DiskGUID   EFIGuid           `json:"disk_guid"`
+	Partitions []EFIGptPartition `json:"partitions"`
+}
+
+// EFIGptPartition is a GPT partition entry.
+type EFIGptPartition struct {
+	TypeGUID      EFIGuid `json:"type_guid"`
+	PartitionGUID EFIGuid `json:"partition_guid"`
+	StartingLBA   uint64  `json:"starting_lba"`
+	EndingLBA     uint64  `json:"ending_lba"`
+	Attributes    uint64  `json:"attributes"`
+	Name          string  `json:"name"`
 }
 
 // EFIHandoffTablePointers is an internal UEFI structure see [1]
 type EFIHandoffTablePointers struct {
-	numberOfTables uint64
-	tableEntry     []EFIConfigurationTable
+	Tables []EFIConfigurationTable `json:"tables"`
 }
 
 // EFIPlatformFirmwareBlob is an internal UEFI structure see [1]
 type EFIPlatformFirmwareBlob struct {
-	blobBase   uint64
-	blobLength uint64
+	BlobBase   uint64 `json:"blob_base"`
+	BlobLength uint64 `json:"blob_length"`
 }
 
 // EFIVariableData representing UEFI vars
 type EFIVariableData struct {
-	variableName       EFIGuid
-	unicodeNameLength  uint64
-	variableDataLength uint64
-	unicodeName        []uint16
-	variableData       []byte
+	VariableName EFIGuid `json:"variable_name"`
+	UnicodeName  string  `json:"unicode_name"`
+	VariableData []byte  `json:"variable_data"`
+	// Signature is the decoded VariableData of EV_EFI_VARIABLE_AUTHORITY
+	// events, the entry of a signature database that authorized an image.
+	Signature *EFISignatureData `json:"signature,omitempty"`
+}
+
+// EFISignatureData is an entry of a UEFI signature database, such as db.
+type EFISignatureData struct {
+	SignatureOwner EFIGuid `json:"signature_owner"`
+	SignatureData  []byte  `json:"signature_data"`
+	// Subject is the subject of SignatureData, if it is an X.509
+	// certificate.
+	Subject string `json:"subject,omitempty"`
+}
+
+// TCGStartupLocality is the EV_NO_ACTION event that records the locality
+// the TPM was started from, which is the initial value of PCR 0.
+type TCGStartupLocality struct {
+	StartupLocality uint8 `json:"startup_locality"`
 }
 
 // IHA is a TPM2 structure
@@ -185,14 +231,15 @@ type PCREvent interface {
 	PcrEventType() uint32
 	PcrEventName() string
 	PcrEventData() string
+	PcrEventRaw() []byte
 	Digests() *[]PCRDigestValue
 	String() string
 }
 
 // PCRLog is a generic PCR eventlog structure
 type PCRLog struct {
-	Firmware FirmwareType
-	PcrList  []PCREvent
+	Firmware FirmwareType `json:"firmware"`
+	PcrList  []PCREvent   `json:"events"`
 }
 
 // [2] http://kib.kiev.ua/x86docs/SDMs/315168-011.pdf (Pre-TrEE MLE Guide)
@@ -0,0 +1,346 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build ignore
+
+// gen writes event logs laid out like those of a few platforms, and the
+// values of the PCRs at the end of the boot they record:
+//
+//   - uefi.bin: the crypto-agile log of a UEFI machine, with SHA1, SHA256
+//     and SHA384 banks, started from locality 3, that boots shim and grub
+//     from a GPT disk with Secure Boot on.
+//   - bios.bin: the SHA1 log of a BIOS machine with a TPM 1.2.
+//   - txt.bin: the TXT event container of an Intel TXT launch with a
+//     TPM 1.2.
+//
+// The PCR values are in the .pcrs files, a "bank PCR value" line per PCR
+// the log extends. The certificate of uefi.bin is made with a new key,
+// so it changes each time the logs are written.
+//
+// Run it from this directory with "go run gen.go".
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+func le(vs ...interface{}) []byte {
+	var b bytes.Buffer
+	for _, v := range vs {
+		switch v := v.(type) {
+		case string:
+			b.WriteString(v)
+		case []byte:
+			b.Write(v)
+		default:
+			if err := binary.Write(&b, binary.LittleEndian, v); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+// guid encodes a GUID in UEFI's mixed endianness.
+func guid(s string) []byte {
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(b) != 16 {
+		log.Fatalf("bad GUID %q", s)
+	}
+	b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	b[4], b[5] = b[5], b[4]
+	b[6], b[7] = b[7], b[6]
+	return b
+}
+
+func ucs2(s string) []byte {
+	return le(utf16.Encode([]rune(s)))
+}
+
+type bank struct {
+	alg  uint16
+	name string
+	h    crypto.Hash
+}
+
+var (
+	sha1Bank   = bank{0x0004, "SHA1", crypto.SHA1}
+	sha256Bank = bank{0x000b, "SHA256", crypto.SHA256}
+	sha384Bank = bank{0x000c, "SHA384", crypto.SHA384}
+)
+
+// eventLog builds a log and keeps the PCR values it gives.
+type eventLog struct {
+	b      bytes.Buffer
+	agile  bool
+	banks  []bank
+	pcrs   map[string]map[int][]byte
+	locals map[int]byte
+}
+
+func newLog(agile bool, banks ...bank) *eventLog {
+	return &eventLog{agile: agile, banks: banks, pcrs: map[string]map[int][]byte{}, locals: map[int]byte{}}
+}
+
+// noAction logs an EV_NO_ACTION event, which is not extended, in the
+// TCG_PCClientPCREvent format of the first event.
+func (l *eventLog) noAction(pcr uint32, data []byte) {
+	if !l.agile || l.b.Len() == 0 {
+		l.b.Write(le(pcr, uint32(3), [20]byte{}, uint32(len(data)), data))
+		return
+	}
+	l.b.Write(le(pcr, uint32(3), uint32(len(l.banks))))
+	for _, k := range l.banks {
+		l.b.Write(le(k.alg, make([]byte, k.h.Size())))
+	}
+	l.b.Write(le(uint32(len(data)), data))
+}
+
+// event logs and extends an event, whose digest is that of measured.
+func (l *eventLog) event(pcr, typ uint32, measured, data []byte) {
+	digest := func(k bank) []byte {
+		d := k.h.New()
+		d.Write(measured)
+		return d.Sum(nil)
+	}
+	if l.agile {
+		l.b.Write(le(pcr, typ, uint32(len(l.banks))))
+		for _, k := range l.banks {
+			l.b.Write(le(k.alg, digest(k)))
+		}
+	} else {
+		l.b.Write(le(pcr, typ, digest(sha1Bank)))
+	}
+	l.b.Write(le(uint32(len(data)), data))
+
+	for _, k := range l.banks {
+		if l.pcrs[k.name] == nil {
+			l.pcrs[k.name] = map[int][]byte{}
+		}
+		v, ok := l.pcrs[k.name][int(pcr)]
+		if !ok {
+			v = make([]byte, k.h.Size())
+			v[len(v)-1] = l.locals[int(pcr)]
+		}
+		d := k.h.New()
+		d.Write(v)
+		d.Write(digest(k))
+		l.pcrs[k.name][int(pcr)] = d.Sum(nil)
+	}
+}
+
+// measure logs and extends an event that measures its own data.
+func (l *eventLog) measure(pcr, typ uint32, data []byte) {
+	l.event(pcr, typ, data, data)
+}
+
+func (l *eventLog) write(name string) {
+	if err := ioutil.WriteFile(name+".bin", l.b.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	l.writePCRs(name)
+}
+
+func (l *eventLog) writePCRs(name string) {
+	var lines []string
+	for bank, pcrs := range l.pcrs {
+		for pcr, v := range pcrs {
+			lines = append(lines, fmt.Sprintf("%s %d %x", bank, pcr, v))
+		}
+	}
+	sort.Strings(lines)
+	if err := ioutil.WriteFile(name+".pcrs", []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+const (
+	evPostCode         = 0x1
+	evSeparator        = 0x4
+	evAction           = 0x5
+	evEventTag         = 0x6
+	evSCRTMVersion     = 0x8
+	evIPL              = 0xd
+	evIPLPartitionData = 0xe
+
+	evEFIVariableDriverConfig    = 0x80000001
+	evEFIVariableBoot            = 0x80000002
+	evEFIBootServicesApplication = 0x80000003
+	evEFIGPTEvent                = 0x80000006
+	evEFIAction                  = 0x80000007
+	evEFIPlatformFirmwareBlob    = 0x80000008
+	evEFIHandoffTables           = 0x80000009
+	evEFIVariableAuthority       = 0x800000e0
+
+	globalVariable   = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+	imageSecurityDB  = "d719b2cb-3d3a-4596-a3bc-dad00e67656f"
+	certX509         = "a5c059a1-94e4-4aa7-87b5-ab155c2bf072"
+	microsoftOwner   = "77fa9abd-0359-4d32-bd60-28f4e78f784b"
+	smbiosTable      = "eb9d2d31-2d88-11d3-9a16-0090273fc14d"
+	espType          = "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"
+	linuxType        = "0fc63daf-8483-4772-8e79-3d69d8477de4"
+	diskGUID         = "6dbd4cb7-5ad4-4cc4-8a2e-1c4b4f2ad2a1"
+	espPartitionGUID = "8c3ae9c5-4e0c-4d5b-b1b5-c80e8a1d7e61"
+	rootGUID         = "1b5d5bd0-49a2-4a40-a4b6-1ef21c9e57a0"
+)
+
+// variable is a UEFI_VARIABLE_DATA.
+func variable(vendor, name string, data []byte) []byte {
+	return le(guid(vendor), uint64(len([]rune(name))), uint64(len(data)), ucs2(name), data)
+}
+
+func certificate() []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "u-root test UEFI CA"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return der
+}
+
+func gpt() []byte {
+	entry := func(typ, id string, first, last uint64, name string) []byte {
+		n := make([]byte, 72)
+		copy(n, ucs2(name))
+		return le(guid(typ), guid(id), first, last, uint64(0), n)
+	}
+	return le("EFI PART", uint32(0x10000), uint32(92), uint32(0x3a8a5c4f), uint32(0),
+		uint64(1), uint64(41943039), uint64(34), uint64(41943006), guid(diskGUID),
+		uint64(2), uint32(128), uint32(128), uint32(0x5e1f0e2b),
+		uint64(2),
+		entry(espType, espPartitionGUID, 2048, 1050623, "EFI System Partition"),
+		entry(linuxType, rootGUID, 1050624, 41940991, "root"))
+}
+
+// devicePath is the path of a file on the EFI system partition.
+func devicePath(file string) []byte {
+	hdd := le(uint8(4), uint8(1), uint16(42), uint32(1), uint64(2048), uint64(1048576), guid(espPartitionGUID), uint8(2), uint8(2))
+	name := ucs2(file + "\x00")
+	path := le(uint8(4), uint8(4), uint16(4+len(name)), name)
+	return le(hdd, path, uint8(0x7f), uint8(0xff), uint16(4))
+}
+
+func uefi() {
+	l := newLog(true, sha1Bank, sha256Bank, sha384Bank)
+	spec := le("Spec ID Event03\x00", uint32(0), uint8(0), uint8(2), uint8(0), uint8(2), uint32(len(l.banks)))
+	for _, k := range l.banks {
+		spec = append(spec, le(k.alg, uint16(k.h.Size()))...)
+	}
+	l.noAction(0, append(spec, 0))
+	l.noAction(0, le("StartupLocality\x00", uint8(3)))
+	l.locals[0] = 3
+
+	l.measure(0, evSCRTMVersion, ucs2("1.0.0\x00"))
+	l.event(0, evEFIPlatformFirmwareBlob, []byte("PEIFV"), le(uint64(0x820000), uint64(0x40000)))
+	l.event(0, evEFIPlatformFirmwareBlob, []byte("DXEFV"), le(uint64(0x900000), uint64(0xc00000)))
+	l.event(1, evEFIHandoffTables, []byte("SMBIOS"), le(uint64(1), guid(smbiosTable), uint64(0x7f8f8000)))
+
+	l.measure(7, evEFIVariableDriverConfig, variable(globalVariable, "SecureBoot", []byte{1}))
+	l.measure(7, evEFIVariableDriverConfig, variable(globalVariable, "PK", []byte("PK signature list")))
+	l.measure(7, evEFIVariableDriverConfig, variable(globalVariable, "KEK", []byte("KEK signature list")))
+	cert := certificate()
+	db := le(guid(certX509), uint32(28+16+len(cert)), uint32(0), uint32(16+len(cert)), guid(microsoftOwner), cert)
+	l.measure(7, evEFIVariableDriverConfig, variable(imageSecurityDB, "db", db))
+	l.measure(7, evEFIVariableDriverConfig, variable(imageSecurityDB, "dbx", []byte("dbx signature list")))
+
+	bootOption := le(uint32(1), uint16(len(devicePath(`\EFI\BOOT\BOOTX64.EFI`))), ucs2("Linux\x00"), devicePath(`\EFI\BOOT\BOOTX64.EFI`))
+	l.measure(1, evEFIVariableBoot, variable(globalVariable, "BootOrder", le(uint16(0))))
+	l.measure(1, evEFIVariableBoot, variable(globalVariable, "Boot0000", bootOption))
+
+	l.measure(4, evEFIAction, []byte("Calling EFI Application from Boot Option"))
+	for pcr := uint32(0); pcr < 8; pcr++ {
+		l.measure(pcr, evSeparator, make([]byte, 4))
+	}
+
+	l.measure(5, evEFIGPTEvent, gpt())
+	l.measure(7, evEFIVariableAuthority, variable(imageSecurityDB, "db", le(guid(microsoftOwner), cert)))
+	image := func(file string) []byte {
+		p := devicePath(file)
+		return le(uint64(0x7e5a3000), uint64(0xe7c98), uint64(0), uint64(len(p)), p)
+	}
+	l.event(4, evEFIBootServicesApplication, []byte("shimx64.efi"), image(`\EFI\BOOT\BOOTX64.EFI`))
+	l.event(4, evEFIBootServicesApplication, []byte("grubx64.efi"), image(`\EFI\BOOT\grubx64.efi`))
+
+	l.measure(8, evIPL, []byte("grub_cmd: linux /vmlinuz root=/dev/sda2\x00"))
+	l.measure(8, evIPL, []byte("kernel_cmdline: /vmlinuz root=/dev/sda2\x00"))
+	l.event(9, evIPL, []byte("vmlinuz"), []byte("/vmlinuz\x00"))
+	l.measure(5, evEFIAction, []byte("Exit Boot Services Invocation"))
+	l.measure(5, evEFIAction, []byte("Exit Boot Services Returned with Success"))
+	l.write("uefi")
+}
+
+func bios() {
+	l := newLog(false, sha1Bank)
+	l.measure(0, evSCRTMVersion, ucs2("1.00\x00"))
+	l.event(0, evPostCode, []byte("BIOS"), []byte("POST CODE\x00"))
+	l.measure(1, evEventTag, le(uint32(0x4), uint32(6), "SMBIOS"))
+	for pcr := uint32(0); pcr < 8; pcr++ {
+		l.measure(pcr, evSeparator, make([]byte, 4))
+	}
+	l.measure(4, evAction, []byte("Calling INT 19h"))
+	l.event(4, evIPL, []byte("MBR"), []byte("IPL\x00"))
+	l.event(5, evIPLPartitionData, []byte("MBR partition table"), make([]byte, 64))
+	l.measure(4, evAction, []byte("Booting BCV Device 80h, HDD"))
+	l.write("bios")
+}
+
+func txt() {
+	const (
+		hashStart    = 0x402
+		mleHash      = 0x404
+		biosAcRegDat = 0x40a
+		lcpHash      = 0x40f
+	)
+	l := newLog(false, sha1Bank)
+	l.event(17, hashStart, []byte("SINIT"), le(uint32(0), make([]byte, 16)))
+	l.event(17, biosAcRegDat, []byte("BIOSAC_REG_DATA"), nil)
+	l.event(17, lcpHash, []byte("LCP"), nil)
+	l.event(18, mleHash, []byte("MLE"), nil)
+	events := l.b.Bytes()
+
+	// The container has room for more events.
+	const header = 48
+	size := uint32(header + len(events) + 64)
+	container := le("TXT Event Container\x00", make([]byte, 12), uint8(1), uint8(0), uint8(1), uint8(0),
+		size, uint32(header), uint32(header+len(events)), events, make([]byte, 64))
+	if err := ioutil.WriteFile("txt.bin", container, 0644); err != nil {
+		log.Fatal(err)
+	}
+	l.writePCRs("txt")
+}
+
+func main() {
+	uefi()
+	bios()
+	txt()
+}
@@ -8,12 +8,10 @@ package txtlog
 
 import (
 	"bytes"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"unicode/utf16"
 
 	tss "github.com/u-root/u-root/pkg/tss"
 )
@@ -91,9 +89,7 @@ func readTPM1Log(firmware FirmwareType) (*PCRLog, error) {
 
 	pcrLog.Firmware = firmware
 
-	if firmware == "TXT" {
-		var pcrLog PCRLog
-
+	if firmware == Txt {
 		container, err := readTxtEventLogContainer(file)
 		if err != nil {
 			return nil, err
@@ -154,7 +150,8 @@ func readTPM2Log(firmware FirmwareType) (*PCRLog, error) {
 	if pcrEvent, err = parseTcgPcrEvent(file); err != nil {
 		return nil, err
 	}
-	if efiSpecID, err := parseEfiSpecEvent(bytes.NewBuffer(pcrEvent.event)); efiSpecID == nil {
+	efiSpecID, err := parseEfiSpecEvent(bytes.NewBuffer(pcrEvent.event))
+	if efiSpecID == nil {
 		if err != nil {
 			return nil, err
 		}
@@ -163,8 +160,15 @@ func readTPM2Log(firmware FirmwareType) (*PCRLog, error) {
 
 	pcrLog.PcrList = append(pcrLog.PcrList, pcrEvent)
 
+	// The SpecID event has the sizes of the digests of all banks in
+	// the log, including those of algorithms we do not know.
+	digestSizes := map[IAlgHash]uint16{}
+	for _, d := range efiSpecID.digestSizes {
+		digestSizes[IAlgHash(d.algorithID)] = d.digestSize
+	}
+
 	for {
-		pcrEvent, err := parseTcgPcrEvent2(file)
+		pcrEvent, err := parseTcgPcrEvent2(file, digestSizes)
 		if err == io.EOF {
 			break
 		} else if err != nil {
@@ -181,208 +185,3 @@ func readTPM2Log(firmware FirmwareType) (*PCRLog, error) {
 
 	return &pcrLog, nil
 }
-
-func getTaggedEvent(eventData []byte) (*string, error) {
-	var eventReader = bytes.NewReader(eventData)
-	var taggedEvent TCGPCClientTaggedEvent
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &taggedEvent.taggedEventID); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &taggedEvent.taggedEventDataSize); err != nil {
-		return nil, err
-	}
-
-	taggedEvent.taggedEventData = make([]byte, taggedEvent.taggedEventDataSize)
-	if err := binary.Read(eventReader, binary.LittleEndian, &taggedEvent.taggedEventData); err != nil {
-		return nil, err
-	}
-
-	eventInfo := fmt.Sprintf("Tag ID - %d - %s", taggedEvent.taggedEventID, string(taggedEvent.taggedEventData))
-	return &eventInfo, nil
-}
-
-func getHandoffTablePointers(eventData []byte) (*string, error) {
-	var eventReader = bytes.NewReader(eventData)
-	var handoffTablePointers EFIHandoffTablePointers
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &handoffTablePointers.numberOfTables); err != nil {
-		return nil, err
-	}
-
-	handoffTablePointers.tableEntry = make([]EFIConfigurationTable, handoffTablePointers.numberOfTables)
-	for i := uint64(0); i < handoffTablePointers.numberOfTables; i++ {
-		if err := binary.Read(eventReader, binary.LittleEndian, &handoffTablePointers.tableEntry[i].vendorGUID.blockA); err != nil {
-			return nil, err
-		}
-
-		if err := binary.Read(eventReader, binary.LittleEndian, &handoffTablePointers.tableEntry[i].vendorGUID.blockB); err != nil {
-			return nil, err
-		}
-
-		if err := binary.Read(eventReader, binary.LittleEndian, &handoffTablePointers.tableEntry[i].vendorGUID.blockC); err != nil {
-			return nil, err
-		}
-
-		if err := binary.Read(eventReader, binary.LittleEndian, &handoffTablePointers.tableEntry[i].vendorGUID.blockD); err != nil {
-			return nil, err
-		}
-
-		if err := binary.Read(eventReader, binary.LittleEndian, &handoffTablePointers.tableEntry[i].vendorGUID.blockE); err != nil {
-			return nil, err
-		}
-
-		if err := binary.Read(eventReader, binary.LittleEndian, &handoffTablePointers.tableEntry[i].vendorTable); err != nil {
-			return nil, err
-		}
-	}
-
-	eventInfo := fmt.Sprint("Tables: ")
-	for _, table := range handoffTablePointers.tableEntry {
-		guid := fmt.Sprintf("%x-%x-%x-%x-%x", table.vendorGUID.blockA, table.vendorGUID.blockB, table.vendorGUID.blockC, table.vendorGUID.blockD, table.vendorGUID.blockE)
-		eventInfo += fmt.Sprintf("At address 0x%d with Guid %s", table.vendorTable, guid)
-	}
-	return &eventInfo, nil
-}
-
-func getPlatformFirmwareBlob(eventData []byte) (*string, error) {
-	var eventReader = bytes.NewReader(eventData)
-	var platformFirmwareBlob EFIPlatformFirmwareBlob
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &platformFirmwareBlob.blobBase); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &platformFirmwareBlob.blobLength); err != nil {
-		return nil, err
-	}
-
-	eventInfo := fmt.Sprintf("Blob address - 0x%d - with size - %db", platformFirmwareBlob.blobBase, platformFirmwareBlob.blobLength)
-	return &eventInfo, nil
-}
-
-func getGPTEventString(eventData []byte) (*string, error) {
-	var eventReader = bytes.NewReader(eventData)
-	var gptEvent EFIGptData
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.Signature); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.Revision); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.Size); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.CRC); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.HeaderStartLBA); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.HeaderCopyStartLBA); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.FirstUsableLBA); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.LastUsableLBA); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &gptEvent.uefiPartitionHeader.DiskGUID); err != nil {
-		return nil, err
-	}
-
-	// Stop here we only want to know which device was used here.
-
-	eventInfo := fmt.Sprint("Disk Guid - ")
-	eventInfo += gptEvent.uefiPartitionHeader.DiskGUID.String()
-	return &eventInfo, nil
-}
-
-func getImageLoadEventString(eventData []byte) (*string, error) {
-	var eventReader = bytes.NewReader(eventData)
-	var imageLoadEvent EFIImageLoadEvent
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &imageLoadEvent.imageLocationInMemory); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &imageLoadEvent.imageLengthInMemory); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &imageLoadEvent.imageLinkTimeAddress); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &imageLoadEvent.lengthOfDevicePath); err != nil {
-		return nil, err
-	}
-
-	// Stop here we only want to know which device was used here.
-
-	eventInfo := fmt.Sprintf("Image loaded at address 0x%d ", imageLoadEvent.imageLocationInMemory)
-	eventInfo += fmt.Sprintf("with %db", imageLoadEvent.imageLengthInMemory)
-
-	return &eventInfo, nil
-}
-
-func getVariableDataString(eventData []byte) (*string, error) {
-	var eventReader = bytes.NewReader(eventData)
-	var variableData EFIVariableData
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.variableName.blockA); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.variableName.blockB); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.variableName.blockC); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.variableName.blockD); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.variableName.blockE); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.unicodeNameLength); err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.variableDataLength); err != nil {
-		return nil, err
-	}
-
-	variableData.unicodeName = make([]uint16, variableData.unicodeNameLength)
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.unicodeName); err != nil {
-		return nil, err
-	}
-
-	variableData.variableData = make([]byte, variableData.variableDataLength)
-	if err := binary.Read(eventReader, binary.LittleEndian, &variableData.variableData); err != nil {
-		return nil, err
-	}
-
-	guid := fmt.Sprintf("Variable - %x-%x-%x-%x-%x - ", variableData.variableName.blockA, variableData.variableName.blockB, variableData.variableName.blockC, variableData.variableName.blockD, variableData.variableName.blockE)
-	eventInfo := guid
-	utf16String := utf16.Decode(variableData.unicodeName)
-	eventInfo += fmt.Sprintf("%s", string(utf16String))
-
-	return &eventInfo, nil
-}
@@ -0,0 +1,298 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package txtlog
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	tss "github.com/u-root/u-root/pkg/tss"
+)
+
+var testLogs = []struct {
+	name     string
+	firmware FirmwareType
+	version  tss.TPMVersion
+	events   int
+}{
+	{name: "uefi", firmware: Uefi, version: tss.TPMVersion20, events: 31},
+	{name: "bios", firmware: Bios, version: tss.TPMVersion12, events: 15},
+	{name: "txt", firmware: Txt, version: tss.TPMVersion12, events: 4},
+	{name: "arch-linux-workstation", firmware: Uefi, version: tss.TPMVersion20, events: 25},
+	{name: "linux-tpm12", firmware: Bios, version: tss.TPMVersion12, events: 40},
+}
+
+func readTestLog(t *testing.T, name string, firmware FirmwareType, version tss.TPMVersion) *PCRLog {
+	defer func(f string) { DefaultTCPABinaryLog = f }(DefaultTCPABinaryLog)
+	DefaultTCPABinaryLog = filepath.Join("testdata", name+".bin")
+	l, err := ParseLog(firmware, version)
+	if err != nil {
+		t.Fatalf("ParseLog(%s) = %v", name, err)
+	}
+	return l
+}
+
+// readTestPCRs reads the values of the PCRs a log extends at the end of
+// the boot it records.
+func readTestPCRs(t *testing.T, name string) PCRBanks {
+	f, err := os.Open(filepath.Join("testdata", name+".pcrs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	algs := map[string]IAlgHash{}
+	for alg, n := range algHashNames {
+		algs[n] = alg
+	}
+	banks := PCRBanks{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 3 {
+			t.Fatalf("bad line %q", s.Text())
+		}
+		alg := algs[fields[0]]
+		pcr, err := strconv.Atoi(fields[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := hex.DecodeString(fields[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if banks[alg] == nil {
+			banks[alg] = map[int][]byte{}
+		}
+		banks[alg][pcr] = v
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return banks
+}
+
+func TestReplay(t *testing.T) {
+	for _, tt := range testLogs {
+		t.Run(tt.name, func(t *testing.T) {
+			l := readTestLog(t, tt.name, tt.firmware, tt.version)
+			if len(l.PcrList) != tt.events {
+				t.Errorf("log has %d events, want %d", len(l.PcrList), tt.events)
+			}
+			got, err := l.Replay()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := readTestPCRs(t, tt.name); !reflect.DeepEqual(got, want) {
+				t.Errorf("Replay() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	l := readTestLog(t, "uefi", Uefi, tss.TPMVersion20)
+	var pcrs []tss.PCR
+	for alg, bank := range readTestPCRs(t, "uefi") {
+		for i, v := range bank {
+			pcrs = append(pcrs, tss.PCR{Index: i, Digest: v, DigestAlg: alg.Hash()})
+		}
+	}
+	// The log does not extend PCR 10, so it is not compared.
+	pcrs = append(pcrs, tss.PCR{Index: 10, Digest: make([]byte, 32), DigestAlg: TPMAlgSha256.Hash()})
+
+	bad, err := l.Verify(pcrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bad) != 0 {
+		t.Errorf("Verify() = %v, want no mismatches", bad)
+	}
+
+	corrupt := func(alg IAlgHash, pcr int) PCRMismatch {
+		for i, p := range pcrs {
+			if p.DigestAlg == alg.Hash() && p.Index == pcr {
+				actual := append([]byte{}, p.Digest...)
+				actual[0] ^= 0xff
+				pcrs[i].Digest = actual
+				return PCRMismatch{Bank: alg, PCR: pcr, Replayed: p.Digest, Actual: actual}
+			}
+		}
+		t.Fatalf("no %v PCR %d", alg, pcr)
+		return PCRMismatch{}
+	}
+	// Mismatches are by bank, then PCR.
+	want := []PCRMismatch{corrupt(TPMAlgSha, 4), corrupt(TPMAlgSha384, 7)}
+	bad, err = l.Verify(pcrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bad, want) {
+		t.Errorf("Verify() = %v, want %v", bad, want)
+	}
+}
+
+func TestEventData(t *testing.T) {
+	l := readTestLog(t, "uefi", Uefi, tss.TPMVersion20)
+	data := map[EFILogID][]interface{}{}
+	for _, e := range l.PcrList {
+		d, err := EventData(e.PcrEventType(), e.PcrEventRaw())
+		if err != nil {
+			t.Errorf("EventData(%s) = %v", e.PcrEventName(), err)
+			continue
+		}
+		data[EFILogID(e.PcrEventType())] = append(data[EFILogID(e.PcrEventType())], d)
+	}
+
+	if got, want := data[EFILogID(EvNoAction)][1], (&TCGStartupLocality{StartupLocality: 3}); !reflect.DeepEqual(got, want) {
+		t.Errorf("startup locality = %v, want %v", got, want)
+	}
+
+	gpt := data[EvEFIGPTEvent][0].(*EFIGptData)
+	if got, want := gpt.DiskGUID.String(), "6dbd4cb7-5ad4-4cc4-8a2e-1c4b4f2ad2a1"; got != want {
+		t.Errorf("GPT disk GUID = %s, want %s", got, want)
+	}
+	wantPartitions := []EFIGptPartition{
+		{StartingLBA: 2048, EndingLBA: 1050623, Name: "EFI System Partition"},
+		{StartingLBA: 1050624, EndingLBA: 41940991, Name: "root"},
+	}
+	if len(gpt.Partitions) != len(wantPartitions) {
+		t.Fatalf("GPT has %d partitions, want %d", len(gpt.Partitions), len(wantPartitions))
+	}
+	for i, p := range gpt.Partitions {
+		w := wantPartitions[i]
+		if p.StartingLBA != w.StartingLBA || p.EndingLBA != w.EndingLBA || p.Name != w.Name {
+			t.Errorf("GPT partition %d = %+v, want %+v", i, p, w)
+		}
+	}
+	if got, want := gpt.Partitions[0].TypeGUID.String(), "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"; got != want {
+		t.Errorf("GPT partition type = %s, want %s", got, want)
+	}
+
+	v := data[EvEFIVariableDriverConfig][0].(*EFIVariableData)
+	if v.VariableName.String() != "8be4df61-93ca-11d2-aa0d-00e098032b8c" || v.UnicodeName != "SecureBoot" || string(v.VariableData) != "\x01" {
+		t.Errorf("EV_EFI_VARIABLE_DRIVER_CONFIG = %+v, want SecureBoot enabled", v)
+	}
+	a := data[EvEFIVariableAuthority][0].(*EFIVariableData)
+	if a.UnicodeName != "db" || a.Signature == nil {
+		t.Fatalf("EV_EFI_VARIABLE_AUTHORITY = %+v, want a db entry", a)
+	}
+	if got, want := a.Signature.SignatureOwner.String(), "77fa9abd-0359-4d32-bd60-28f4e78f784b"; got != want {
+		t.Errorf("signature owner = %s, want %s", got, want)
+	}
+	if got, want := a.Signature.Subject, "CN=u-root test UEFI CA"; got != want {
+		t.Errorf("signature subject = %q, want %q", got, want)
+	}
+
+	image := data[EvEFIBootServicesApplication][1].(*EFIImageLoadEvent)
+	if image.ImageLocationInMemory != 0x7e5a3000 || image.ImageLengthInMemory != 0xe7c98 {
+		t.Errorf("image = %+v, want one at 0x7e5a3000 of 0xe7c98 bytes", image)
+	}
+	if got, want := image.DevicePath, "HD(1,GPT,8c3ae9c5-4e0c-4d5b-b1b5-c80e8a1d7e61,0x800,0x100000)/File(/EFI/BOOT/grubx64.efi)"; got != want {
+		t.Errorf("image device path = %q, want %q", got, want)
+	}
+
+	tables := data[EvEFIHandoffTables][0].(*EFIHandoffTablePointers)
+	if len(tables.Tables) != 1 || tables.Tables[0].VendorGUID.String() != "eb9d2d31-2d88-11d3-9a16-0090273fc14d" || tables.Tables[0].VendorTable != 0x7f8f8000 {
+		t.Errorf("handoff tables = %+v, want SMBIOS at 0x7f8f8000", tables)
+	}
+	if got, want := data[EFILogID(EvSCRTMVersion)][0], "1.0.0"; got != want {
+		t.Errorf("EV_S_CRTM_VERSION = %q, want %q", got, want)
+	}
+	if got, want := data[EvEFIAction][0], "Calling EFI Application from Boot Option"; got != want {
+		t.Errorf("EV_EFI_ACTION = %q, want %q", got, want)
+	}
+}
+
+func TestEventDataReal(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		firmware FirmwareType
+		version  tss.TPMVersion
+		crtm     string
+	}{
+		{name: "arch-linux-workstation", firmware: Uefi, version: tss.TPMVersion20, crtm: "1efb6b540c1d5540a4ad4ef4bf17b83a"},
+		{name: "linux-tpm12", firmware: Bios, version: tss.TPMVersion12, crtm: "N1FET43W"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l := readTestLog(t, tt.name, tt.firmware, tt.version)
+			for i, e := range l.PcrList {
+				d, err := EventData(e.PcrEventType(), e.PcrEventRaw())
+				if err != nil {
+					t.Errorf("EventData(event %d, %s) = %v", i, e.PcrEventName(), err)
+				}
+				if e.PcrEventType() == uint32(EvSCRTMVersion) && strings.TrimSpace(fmt.Sprint(d)) != tt.crtm {
+					t.Errorf("EV_S_CRTM_VERSION = %q, want %q", d, tt.crtm)
+				}
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	l := readTestLog(t, "uefi", Uefi, tss.TPMVersion20)
+	b, err := json.Marshal(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Firmware string
+		Events   []struct {
+			PCR     int
+			Type    uint32
+			Name    string
+			Digests map[string]string
+			Data    json.RawMessage
+			Raw     []byte
+		}
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Firmware != "UEFI" || len(got.Events) != len(l.PcrList) {
+		t.Fatalf("JSON log has firmware %q and %d events, want UEFI and %d", got.Firmware, len(got.Events), len(l.PcrList))
+	}
+	for i, e := range got.Events {
+		p := l.PcrList[i]
+		if e.PCR != p.PcrIndex() || e.Type != p.PcrEventType() || e.Name != p.PcrEventName() || string(e.Raw) != string(p.PcrEventRaw()) {
+			t.Errorf("JSON event %d = %+v, want %v", i, e, p)
+		}
+		for _, d := range *p.Digests() {
+			if e.Digests[d.DigestAlg.String()] != hex.EncodeToString(d.Digest) {
+				t.Errorf("JSON event %d has %v digest %s, want %x", i, d.DigestAlg, e.Digests[d.DigestAlg.String()], d.Digest)
+			}
+		}
+		if p.PcrEventType() == uint32(EvEFIGPTEvent) {
+			var gpt struct {
+				DiskGUID   string `json:"disk_guid"`
+				Partitions []struct{ Name string }
+			}
+			if err := json.Unmarshal(e.Data, &gpt); err != nil {
+				t.Fatal(err)
+			}
+			if gpt.DiskGUID != "6dbd4cb7-5ad4-4cc4-8a2e-1c4b4f2ad2a1" || len(gpt.Partitions) != 2 || gpt.Partitions[1].Name != "root" {
+				t.Errorf("JSON GPT event data = %s", e.Data)
+			}
+		}
+	}
+	if len(got.Events[2].Digests) != 3 {
+		t.Errorf("JSON event has digests %v, want SHA1, SHA256 and SHA384 ones", got.Events[2].Digests)
+	}
+
+	b, err = json.Marshal(PCRMismatch{Bank: TPMAlgSha256, PCR: 7, Replayed: []byte{1}, Actual: []byte{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"bank":"SHA256","pcr":7,"replayed":"01","actual":"02"}`; got != want {
+		t.Errorf("JSON mismatch = %s, want %s", got, want)
+	}
+}
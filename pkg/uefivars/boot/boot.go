@@ -47,6 +47,27 @@ type EfiLoadOption struct {
 }
 type BootEntryVars []*BootEntryVar
 
+// Attributes of an EfiLoadOption, UEFI spec v2.8A pg 76.
+const (
+	LoadOptionActive         uint32 = 0x00000001
+	LoadOptionForceReconnect uint32 = 0x00000002
+	LoadOptionHidden         uint32 = 0x00000008
+	LoadOptionCategoryApp    uint32 = 0x00000100
+)
+
+// Bytes encodes the load option as the data of a BootXXXX var. The
+// FilePathListLength is that of the encoded FilePathList, whatever the
+// field is.
+func (e *EfiLoadOption) Bytes() []byte {
+	fpl := e.FilePathList.Bytes()
+	b := make([]byte, 6)
+	binary.LittleEndian.PutUint32(b[:4], e.Attributes)
+	binary.LittleEndian.PutUint16(b[4:6], uint16(len(fpl)))
+	b = append(b, uefivars.EncodeUTF16(e.Description+"\000")...)
+	b = append(b, fpl...)
+	return append(b, e.OptionalData...)
+}
+
 // Gets BootXXXX var, if it exists
 func ReadBootVar(num uint16) (*BootEntryVar, error) {
 	v, err := uefivars.ReadVar(BootUUID, fmt.Sprintf("Boot%04X", num))
@@ -8,6 +8,7 @@
 package boot
 
 import (
+	"bytes"
 	"os"
 	"testing"
 
@@ -110,3 +111,33 @@ func TestBootCurrent(t *testing.T) {
 		t.Errorf("want %d got %d", want, bc.Current)
 	}
 }
+
+//func (e *EfiLoadOption) Bytes() []byte
+func TestEfiLoadOptionBytes(t *testing.T) {
+	for _, v := range uefivars.ReadVars(BootEntryFilter) {
+		if got := BootVar(v).Bytes(); !bytes.Equal(got, v.Data) {
+			t.Errorf("%s: Bytes() = %x, want %x", v.Name, got, v.Data)
+		}
+	}
+
+	// Boot0007 of efiDevicePathProtocol_test.go, from scratch.
+	o := EfiLoadOption{
+		Attributes:  LoadOptionActive,
+		Description: "UEFI OS",
+		FilePathList: EfiDevicePathProtocolList{
+			&DppMediaHDD{
+				PartNum:   1,
+				PartStart: 0x40,
+				PartSize:  0xf000,
+				PartSig:   uefivars.MixedGUID{0xcd, 0x5c, 0x63, 0x81, 0x4f, 0x1b, 0x3f, 0x4d, 0xb7, 0xb8, 0xf7, 0x8a, 0x5b, 0x02, 0x9f, 0x35},
+				PartFmt:   2,
+				SigType:   2,
+			},
+			&DppMediaFilePath{PathNameDecoded: "/EFI/BOOT/BOOTX64.EFI"},
+		},
+		OptionalData: []byte{0, 0, 0x42, 0x4f},
+	}
+	if got := o.Bytes(); !bytes.Equal(got, boot7) {
+		t.Errorf("Bytes() = %x, want %x", got, boot7)
+	}
+}
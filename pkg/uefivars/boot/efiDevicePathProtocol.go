@@ -8,6 +8,7 @@
 package boot
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
@@ -174,6 +175,11 @@ type EfiDevicePathProtocol interface {
 	// String returns the path as human readable.
 	String() string
 
+	// Bytes encodes the path as found in a FilePathList, header included.
+	// The type and length in the header are those of the encoding, not
+	// those of Header().
+	Bytes() []byte
+
 	// Resolver returns an EfiPathSegmentResolver. In the case of filesystems,
 	// this locates and mounts the device.
 	Resolver() (EfiPathSegmentResolver, error)
@@ -193,6 +199,25 @@ func (list EfiDevicePathProtocolList) String() string {
 	return strings.Trim(res, "/")
 }
 
+// Bytes encodes the list as a FilePathList, terminating it with an end of
+// entire path node.
+func (list EfiDevicePathProtocolList) Bytes() []byte {
+	var b []byte
+	for _, dpp := range list {
+		b = append(b, dpp.Bytes()...)
+	}
+	return append(b, encodeDpp(DppTypeEnd, EfiDevPathProtoSubType(DppETypeEndEntire), nil)...)
+}
+
+// encodeDpp encodes a device path node of the type and subtype.
+func encodeDpp(t EfiDevPathProtoType, st EfiDevPathProtoSubType, data []byte) []byte {
+	b := make([]byte, 4, 4+len(data))
+	b[0] = byte(t)
+	b[1] = byte(st)
+	binary.LittleEndian.PutUint16(b[2:], uint16(4+len(data)))
+	return append(b, data...)
+}
+
 // EfiDevicePathProtocolHdr is three one-byte fields that all DevicePathProtocol
 // entries begin with.
 //
@@ -280,6 +305,10 @@ func (e *EfiDevPathEnd) ProtoSubTypeStr() string {
 
 func (e *EfiDevPathEnd) String() string { return "" }
 
+func (e *EfiDevPathEnd) Bytes() []byte {
+	return encodeDpp(DppTypeEnd, e.Hdr.ProtoSubType, nil)
+}
+
 func (e *EfiDevPathEnd) Resolver() (EfiPathSegmentResolver, error) {
 	return nil, nil
 }
@@ -300,6 +329,10 @@ func (e *EfiDevPathRaw) String() string {
 	return fmt.Sprintf("RAW(%s,0x%x,%d,0x%x)", e.Hdr.ProtoType, e.Hdr.ProtoSubType, e.Hdr.Length, e.Raw)
 }
 
+func (e *EfiDevPathRaw) Bytes() []byte {
+	return encodeDpp(e.Hdr.ProtoType, e.Hdr.ProtoSubType, e.Raw)
+}
+
 func (e *EfiDevPathRaw) Resolver() (EfiPathSegmentResolver, error) {
 	return nil, ErrParse
 }
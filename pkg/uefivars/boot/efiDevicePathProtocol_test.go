@@ -8,6 +8,7 @@
 package boot
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/u-root/u-root/pkg/uefivars"
@@ -75,3 +76,39 @@ func TestParseFilePathList(t *testing.T) {
 		t.Errorf("\nwant %s\n got %s", expectedOutput, output)
 	}
 }
+
+//func (list EfiDevicePathProtocolList) Bytes() []byte
+func TestFilePathListBytes(t *testing.T) {
+	// Header()s are mostly zero values; Bytes() must not use their lengths.
+	list := EfiDevicePathProtocolList{
+		&DppAcpiDevPath{HID: []byte{0xd0, 0x41, 0x03, 0x0a}, UID: []byte{0, 0, 0, 0}},
+		&DppAcpiExDevPath{HID: []byte{0, 0, 0, 1}, UID: []byte{0, 0, 0, 2}, CID: []byte{0, 0, 0, 3}, HIDSTR: "HIDSTR", UIDSTR: "", CIDSTR: "CIDSTR"},
+		&DppHwPci{Function: 2, Device: 0x1f},
+		&DppMsgATAPI{Primary: true, Master: false, LUN: 3},
+		&DppMsgMAC{Mac: [32]byte{0x52, 0x54, 0, 0x12, 0x34, 0x56}, IfType: 1},
+		&DppMediaHDD{PartNum: 1, PartStart: 0x800, PartSize: 0x100000, PartSig: uefivars.MixedGUID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, PartFmt: 2, SigType: 2},
+		&DppMediaPIWGFV{Fv: bytes.Repeat([]byte{0xaa}, 16)},
+		&DppMediaPIWGFF{Ff: bytes.Repeat([]byte{0xbb}, 16)},
+		&DppMediaFilePath{PathNameDecoded: "/EFI/BOOT/BOOTX64.EFI"},
+		&EfiDevPathRaw{Hdr: EfiDevicePathProtocolHdr{ProtoType: DppTypeBBS, ProtoSubType: 1, Length: 7}, Raw: []byte{1, 2, 3}},
+	}
+	b := list.Bytes()
+	if end := b[len(b)-4:]; !bytes.Equal(end, []byte{0x7f, 0xff, 0x04, 0x00}) {
+		t.Errorf("list ends with %x, want an end of entire path node", end)
+	}
+	got, err := ParseFilePathList(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(list) {
+		t.Fatalf("ParseFilePathList(Bytes()) has %d nodes, want %d", len(got), len(list))
+	}
+	for i := range list {
+		if got[i].String() != list[i].String() {
+			t.Errorf("node %d: ParseFilePathList(Bytes()) = %s, want %s", i, got[i], list[i])
+		}
+		if h := got[i].Header(); int(h.Length) != len(list[i].Bytes()) {
+			t.Errorf("node %d: length %d, want %d", i, h.Length, len(list[i].Bytes()))
+		}
+	}
+}
@@ -65,6 +65,11 @@ func (e *DppAcpiDevPath) ProtoSubTypeStr() string {
 
 func (e *DppAcpiDevPath) String() string { return fmt.Sprintf("ACPI(0x%x,0x%x)", e.HID, e.UID) }
 
+func (e *DppAcpiDevPath) Bytes() []byte {
+	b := append(append([]byte{}, e.HID...), e.UID...)
+	return encodeDpp(DppTypeACPI, EfiDevPathProtoSubType(DppAcpiTypeDevPath), b)
+}
+
 // Resolver returns a nil EfiPathSegmentResolver and ErrUnimpl. See the comment
 // associated with ErrUnimpl.
 func (e *DppAcpiDevPath) Resolver() (EfiPathSegmentResolver, error) { return nil, ErrUnimpl }
@@ -119,6 +124,14 @@ func (e *DppAcpiExDevPath) String() string {
 	return fmt.Sprintf("ACPI_EX(0x%x,0x%x,0x%x,%s,%s,%s)", e.HID, e.UID, e.CID, e.HIDSTR, e.UIDSTR, e.CIDSTR)
 }
 
+func (e *DppAcpiExDevPath) Bytes() []byte {
+	b := append(append(append([]byte{}, e.HID...), e.UID...), e.CID...)
+	for _, s := range []string{e.HIDSTR, e.UIDSTR, e.CIDSTR} {
+		b = append(append(b, s...), 0)
+	}
+	return encodeDpp(DppTypeACPI, EfiDevPathProtoSubType(DppAcpiTypeExpandedDevPath), b)
+}
+
 // Resolver returns a nil EfiPathSegmentResolver and ErrUnimpl. See the comment
 // associated with ErrUnimpl.
 func (e *DppAcpiExDevPath) Resolver() (EfiPathSegmentResolver, error) {
@@ -70,6 +70,10 @@ func (e *DppHwPci) String() string {
 	return fmt.Sprintf("PCI(0x%x,0x%x)", e.Function, e.Device)
 }
 
+func (e *DppHwPci) Bytes() []byte {
+	return encodeDpp(DppTypeHw, EfiDevPathProtoSubType(DppHTypePCI), []byte{e.Function, e.Device})
+}
+
 // Resolver returns a nil EfiPathSegmentResolver and ErrUnimpl. See the comment
 // associated with ErrUnimpl.
 func (e *DppHwPci) Resolver() (EfiPathSegmentResolver, error) {
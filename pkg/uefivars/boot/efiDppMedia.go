@@ -97,6 +97,43 @@ func (e *DppMediaHDD) String() string {
 	return fmt.Sprintf("HD(%d,%s,%s,0x%x,0x%x)", e.PartNum, e.pttype(), e.sig(), e.PartStart, e.PartSize)
 }
 
+func (e *DppMediaHDD) Bytes() []byte {
+	b := make([]byte, 38)
+	binary.LittleEndian.PutUint32(b[:4], e.PartNum)
+	binary.LittleEndian.PutUint64(b[4:12], e.PartStart)
+	binary.LittleEndian.PutUint64(b[12:20], e.PartSize)
+	copy(b[20:36], e.PartSig[:])
+	b[36] = e.PartFmt
+	b[37] = e.SigType
+	return encodeDpp(DppTypeMedia, EfiDevPathProtoSubType(DppMTypeHdd), b)
+}
+
+// NewDppMediaHDD returns the DppMediaHDD describing partition partNum, from
+// 1, of the GPT of the block device.
+func NewDppMediaHDD(b *block.BlockDev, partNum uint32) (*DppMediaHDD, error) {
+	table, err := b.GPTTable()
+	if err != nil {
+		return nil, fmt.Errorf("reading GPT of %s: %w", b.Name, err)
+	}
+	if partNum == 0 || int(partNum) > len(table.Partitions) || table.Partitions[partNum-1].IsEmpty() {
+		return nil, fmt.Errorf("%s has no partition %d", b.Name, partNum)
+	}
+	p := table.Partitions[partNum-1]
+	return &DppMediaHDD{
+		Hdr: EfiDevicePathProtocolHdr{
+			ProtoType:    DppTypeMedia,
+			ProtoSubType: EfiDevPathProtoSubType(DppMTypeHdd),
+			Length:       42,
+		},
+		PartNum:   partNum,
+		PartStart: p.FirstLBA,
+		PartSize:  p.LastLBA - p.FirstLBA + 1,
+		PartSig:   uefivars.MixedGUID(p.Id),
+		PartFmt:   2,
+		SigType:   2,
+	}, nil
+}
+
 // Resolver returns an EfiPathSegmentResolver which can find and mount the
 // partition described by DppMediaHDD.
 func (e *DppMediaHDD) Resolver() (EfiPathSegmentResolver, error) {
@@ -189,6 +226,13 @@ func (e *DppMediaFilePath) String() string {
 	return fmt.Sprintf("File(%s)", e.PathNameDecoded)
 }
 
+// Bytes encodes the path with windows slashes and null terminated, as
+// ParseDppMediaFilePath expects.
+func (e *DppMediaFilePath) Bytes() []byte {
+	path := strings.Replace(e.PathNameDecoded, string(os.PathSeparator), "\\", -1)
+	return encodeDpp(DppTypeMedia, EfiDevPathProtoSubType(DppMTypeFilePath), uefivars.EncodeUTF16(path+"\000"))
+}
+
 // Resolver returns an EfiPathSegmentResolver decoding the DppMediaFilePath.
 func (e *DppMediaFilePath) Resolver() (EfiPathSegmentResolver, error) {
 	fp.Clean(e.PathNameDecoded)
@@ -228,6 +272,10 @@ func (e *DppMediaPIWGFV) String() string {
 	return fmt.Sprintf("Fv(%s)", g.ToStdEnc().String())
 }
 
+func (e *DppMediaPIWGFV) Bytes() []byte {
+	return encodeDpp(DppTypeMedia, EfiDevPathProtoSubType(DppMTypePIWGFV), e.Fv)
+}
+
 // Resolver returns a nil EfiPathSegmentResolver and ErrUnimpl. See the comment
 // associated with ErrUnimpl.
 func (e *DppMediaPIWGFV) Resolver() (EfiPathSegmentResolver, error) {
@@ -267,6 +315,10 @@ func (e *DppMediaPIWGFF) String() string {
 	return fmt.Sprintf("FvFile(%s)", g.ToStdEnc().String())
 }
 
+func (e *DppMediaPIWGFF) Bytes() []byte {
+	return encodeDpp(DppTypeMedia, EfiDevPathProtoSubType(DppMTypePIWGFF), e.Ff)
+}
+
 // Resolver returns a nil EfiPathSegmentResolver and ErrUnimpl. See the comment
 // associated with ErrUnimpl.
 func (e *DppMediaPIWGFF) Resolver() (EfiPathSegmentResolver, error) {
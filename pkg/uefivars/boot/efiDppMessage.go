@@ -125,6 +125,18 @@ func (e *DppMsgATAPI) String() string {
 	return fmt.Sprintf("ATAPI(pri=%t,master=%t,lun=%d)", e.Primary, e.Master, e.LUN)
 }
 
+func (e *DppMsgATAPI) Bytes() []byte {
+	b := make([]byte, 4)
+	if !e.Primary {
+		b[0] = 1
+	}
+	if !e.Master {
+		b[1] = 1
+	}
+	binary.LittleEndian.PutUint16(b[2:], e.LUN)
+	return encodeDpp(DppTypeMessaging, EfiDevPathProtoSubType(DppMsgTypeATAPI), b)
+}
+
 // Resolver returns a nil EfiPathSegmentResolver and ErrUnimpl. See the comment
 // associated with ErrUnimpl.
 func (e *DppMsgATAPI) Resolver() (EfiPathSegmentResolver, error) {
@@ -169,6 +181,10 @@ func (e *DppMsgMAC) String() string {
 	}
 }
 
+func (e *DppMsgMAC) Bytes() []byte {
+	return encodeDpp(DppTypeMessaging, EfiDevPathProtoSubType(DppMsgTypeMAC), append(e.Mac[:], e.IfType))
+}
+
 // Resolver returns a nil EfiPathSegmentResolver and ErrUnimpl. See the comment
 // associated with ErrUnimpl.
 func (e *DppMsgMAC) Resolver() (EfiPathSegmentResolver, error) {
@@ -0,0 +1,156 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// SPDX-License-Identifier: BSD-3-Clause
+//
+
+package boot
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/u-root/u-root/pkg/uefivars"
+)
+
+// ErrNoFreeEntry is returned when all the BootXXXX numbers are in use.
+var ErrNoFreeEntry = errors.New("no free boot entry number")
+
+func bootVarName(num uint16) string { return fmt.Sprintf("Boot%04X", num) }
+
+// GetBootVar reads BootXXXX from efivarfs.
+func GetBootVar(num uint16) (*BootEntryVar, error) {
+	v, _, err := uefivars.GetVar(BootUUID, bootVarName(num))
+	if err != nil {
+		return nil, fmt.Errorf("reading var %s: %w", bootVarName(num), err)
+	}
+	return BootVar(v), nil
+}
+
+// GetBootEntryVars returns the boot entries (BootXXXX) in efivarfs.
+func GetBootEntryVars() (BootEntryVars, error) {
+	vars, err := uefivars.GetVars(BootEntryFilter)
+	if err != nil {
+		return nil, err
+	}
+	return BootEntries(vars), nil
+}
+
+// SetBootVar writes the boot entry to efivarfs as BootXXXX, creating or
+// replacing it.
+func SetBootVar(b *BootEntryVar) error {
+	v := uefivars.EfiVar{UUID: BootUUID, Name: bootVarName(b.Number), Data: b.EfiLoadOption.Bytes()}
+	return uefivars.SetVar(v, uefivars.AttrDefault)
+}
+
+// DeleteBootVar removes BootXXXX from efivarfs. It does not change BootOrder,
+// see RemoveBootVar.
+func DeleteBootVar(num uint16) error {
+	return uefivars.DeleteVar(BootUUID, bootVarName(num))
+}
+
+// AddBootVar writes the load option as the lowest numbered BootXXXX not in
+// use, and puts it first in BootOrder.
+func AddBootVar(o EfiLoadOption) (*BootEntryVar, error) {
+	vars, err := uefivars.GetVars(BootEntryFilter)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, v := range vars {
+		used[v.Name] = true
+	}
+	b := &BootEntryVar{EfiLoadOption: o}
+	for used[bootVarName(b.Number)] {
+		if b.Number == 0xffff {
+			return nil, ErrNoFreeEntry
+		}
+		b.Number++
+	}
+	if err := SetBootVar(b); err != nil {
+		return nil, err
+	}
+	order, err := GetBootOrder()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return b, SetBootOrder(append([]uint16{b.Number}, order...))
+}
+
+// RemoveBootVar removes BootXXXX from efivarfs and from BootOrder.
+func RemoveBootVar(num uint16) error {
+	if err := DeleteBootVar(num); err != nil {
+		return err
+	}
+	order, err := GetBootOrder()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var newOrder []uint16
+	for _, n := range order {
+		if n != num {
+			newOrder = append(newOrder, n)
+		}
+	}
+	if len(newOrder) == len(order) {
+		return nil
+	}
+	return SetBootOrder(newOrder)
+}
+
+// GetBootOrder reads the BootXXXX numbers in BootOrder.
+func GetBootOrder() ([]uint16, error) {
+	v, _, err := uefivars.GetVar(BootUUID, "BootOrder")
+	if err != nil {
+		return nil, err
+	}
+	if len(v.Data)%2 != 0 {
+		return nil, fmt.Errorf("BootOrder has odd length %d", len(v.Data))
+	}
+	order := make([]uint16, len(v.Data)/2)
+	for i := range order {
+		order[i] = binary.LittleEndian.Uint16(v.Data[2*i:])
+	}
+	return order, nil
+}
+
+// SetBootOrder writes BootOrder.
+func SetBootOrder(order []uint16) error {
+	b := make([]byte, 2*len(order))
+	for i, n := range order {
+		binary.LittleEndian.PutUint16(b[2*i:], n)
+	}
+	return uefivars.SetVar(uefivars.EfiVar{UUID: BootUUID, Name: "BootOrder", Data: b}, uefivars.AttrDefault)
+}
+
+// GetBootNext reads the BootXXXX number in BootNext. The error satisfies
+// os.IsNotExist if there is no BootNext.
+func GetBootNext() (uint16, error) {
+	v, _, err := uefivars.GetVar(BootUUID, "BootNext")
+	if err != nil {
+		return 0, err
+	}
+	if len(v.Data) != 2 {
+		return 0, fmt.Errorf("BootNext has length %d, want 2", len(v.Data))
+	}
+	return binary.LittleEndian.Uint16(v.Data), nil
+}
+
+// SetBootNext writes BootNext, so that the firmware tries BootXXXX first on
+// the next boot only.
+func SetBootNext(num uint16) error {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, num)
+	return uefivars.SetVar(uefivars.EfiVar{UUID: BootUUID, Name: "BootNext", Data: b}, uefivars.AttrDefault)
+}
+
+// DeleteBootNext removes BootNext.
+func DeleteBootNext() error {
+	return uefivars.DeleteVar(BootUUID, "BootNext")
+}
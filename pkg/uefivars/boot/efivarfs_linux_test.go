@@ -0,0 +1,167 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// SPDX-License-Identifier: BSD-3-Clause
+//
+
+package boot
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/uefivars"
+	"github.com/u-root/u-root/pkg/uefivars/vartest"
+)
+
+// setupVarFs points uefivars.EfiVarFs at a copy of the testdata laid out as
+// efivarfs.
+func setupVarFs(t *testing.T) func() {
+	dir, cleanup, err := vartest.SetupVarFs("../testdata/sys_fw_efi_vars.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := uefivars.EfiVarFs
+	uefivars.EfiVarFs = dir
+	return func() {
+		uefivars.EfiVarFs = old
+		cleanup()
+	}
+}
+
+// testdata BootOrder
+var testBootOrder = []uint16{10, 7, 8, 0, 1, 2, 3, 5, 9, 4, 6}
+
+func TestGetBootEntryVars(t *testing.T) {
+	defer setupVarFs(t)()
+
+	got, err := GetBootEntryVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AllBootEntryVars()
+	if len(got) != len(want) {
+		t.Fatalf("GetBootEntryVars() returned %d entries, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].String() != want[i].String() {
+			t.Errorf("GetBootEntryVars()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+	b, err := GetBootVar(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Number != 7 || b.String() != want[7].String() {
+		t.Errorf("GetBootVar(7) = %s, want %s", b, want[7])
+	}
+}
+
+func TestAddRemoveBootVar(t *testing.T) {
+	defer setupVarFs(t)()
+
+	o := EfiLoadOption{
+		Attributes:   LoadOptionActive,
+		Description:  "u-root",
+		FilePathList: EfiDevicePathProtocolList{&DppMediaFilePath{PathNameDecoded: "/EFI/u-root/bootx64.efi"}},
+	}
+	b, err := AddBootVar(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Boot0000 to Boot000A are in use.
+	if b.Number != 0xb {
+		t.Errorf("AddBootVar() = Boot%04X, want Boot000B", b.Number)
+	}
+	got, err := GetBootVar(b.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Description != o.Description || got.Attributes != o.Attributes || got.FilePathList.String() != o.FilePathList.String() {
+		t.Errorf("GetBootVar(%d) = %s, want %s", b.Number, got, b)
+	}
+	order, err := GetBootOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append([]uint16{0xb}, testBootOrder...); !reflect.DeepEqual(order, want) {
+		t.Errorf("BootOrder after AddBootVar() = %v, want %v", order, want)
+	}
+
+	// A removed number is reused.
+	if err := RemoveBootVar(7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetBootVar(7); err == nil {
+		t.Errorf("GetBootVar(7) after RemoveBootVar(7) = nil, want an error")
+	}
+	b, err = AddBootVar(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Number != 7 {
+		t.Errorf("AddBootVar() = Boot%04X, want Boot0007", b.Number)
+	}
+	order, err = GetBootOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{7, 0xb, 10, 8, 0, 1, 2, 3, 5, 9, 4, 6}; !reflect.DeepEqual(order, want) {
+		t.Errorf("BootOrder = %v, want %v", order, want)
+	}
+
+	if err := RemoveBootVar(0); err != nil {
+		t.Fatal(err)
+	}
+	order, err = GetBootOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{7, 0xb, 10, 8, 1, 2, 3, 5, 9, 4, 6}; !reflect.DeepEqual(order, want) {
+		t.Errorf("BootOrder = %v, want %v", order, want)
+	}
+	if err := RemoveBootVar(0); !os.IsNotExist(err) {
+		t.Errorf("RemoveBootVar(0) of a removed entry = %v, want a not exist error", err)
+	}
+}
+
+func TestBootOrder(t *testing.T) {
+	defer setupVarFs(t)()
+
+	order, err := GetBootOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, testBootOrder) {
+		t.Errorf("GetBootOrder() = %v, want %v", order, testBootOrder)
+	}
+	want := []uint16{1, 0xabcd}
+	if err := SetBootOrder(want); err != nil {
+		t.Fatal(err)
+	}
+	if order, err = GetBootOrder(); err != nil || !reflect.DeepEqual(order, want) {
+		t.Errorf("GetBootOrder() = %v, %v, want %v", order, err, want)
+	}
+}
+
+func TestBootNext(t *testing.T) {
+	defer setupVarFs(t)()
+
+	if _, err := GetBootNext(); !os.IsNotExist(err) {
+		t.Errorf("GetBootNext() = %v, want a not exist error", err)
+	}
+	if err := SetBootNext(0x1234); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := GetBootNext(); err != nil || n != 0x1234 {
+		t.Errorf("GetBootNext() = %#x, %v, want 0x1234", n, err)
+	}
+	if err := DeleteBootNext(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetBootNext(); !os.IsNotExist(err) {
+		t.Errorf("GetBootNext() after DeleteBootNext() = %v, want a not exist error", err)
+	}
+}
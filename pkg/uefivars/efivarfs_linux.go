@@ -0,0 +1,171 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// SPDX-License-Identifier: BSD-3-Clause
+//
+
+package uefivars
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	fp "path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// EfiVarFs is the efivarfs mount point, which can be overridden for testing.
+//
+// Unlike the sysfs vars in EfiVarDir, the vars there can be written. Each is
+// a file named <name>-<uuid>, whose first 4 bytes are the attributes of the
+// var, little endian, and the rest its data.
+var EfiVarFs = "/sys/firmware/efi/efivars"
+
+// Attributes of efi vars, UEFI spec v2.8A pg 227.
+const (
+	AttrNonVolatile                       uint32 = 0x01
+	AttrBootServiceAccess                 uint32 = 0x02
+	AttrRuntimeAccess                     uint32 = 0x04
+	AttrHardwareErrorRecord               uint32 = 0x08
+	AttrAuthenticatedWriteAccess          uint32 = 0x10
+	AttrTimeBasedAuthenticatedWriteAccess uint32 = 0x20
+	AttrAppendWrite                       uint32 = 0x40
+
+	// AttrDefault are the attributes of vars such as BootXXXX and BootOrder.
+	AttrDefault = AttrNonVolatile | AttrBootServiceAccess | AttrRuntimeAccess
+)
+
+// fsImmutableFl is FS_IMMUTABLE_FL of linux/fs.h, which efivarfs sets on
+// vars that the firmware may not cope with being removed.
+const fsImmutableFl = 0x10
+
+// ErrShortVar is returned when an efivarfs file is too short to hold the
+// attributes of the var.
+var ErrShortVar = errors.New("efivarfs var shorter than its attributes")
+
+func varFsPath(uuid, name string) string {
+	return fp.Join(EfiVarFs, name+"-"+uuid)
+}
+
+// GetVar reads a var and its attributes from efivarfs.
+func GetVar(uuid, name string) (e EfiVar, attrs uint32, err error) {
+	b, err := ioutil.ReadFile(varFsPath(uuid, name))
+	if err != nil {
+		return e, 0, err
+	}
+	if len(b) < 4 {
+		return e, 0, fmt.Errorf("%s-%s: %w", name, uuid, ErrShortVar)
+	}
+	return EfiVar{UUID: uuid, Name: name, Data: b[4:]}, binary.LittleEndian.Uint32(b[:4]), nil
+}
+
+// GetVars returns the efivarfs vars matching filter, or all of them if it is
+// nil.
+func GetVars(filt VarFilter) (EfiVars, error) {
+	entries, err := ioutil.ReadDir(EfiVarFs)
+	if err != nil {
+		return nil, err
+	}
+	var vars EfiVars
+	for _, entry := range entries {
+		base := entry.Name()
+		// The name may contain dashes, the uuid is the last 36 bytes.
+		if entry.IsDir() || len(base) < 38 || base[len(base)-37] != '-' || strings.Count(base[len(base)-36:], "-") != 4 {
+			continue
+		}
+		name, uuid := base[:len(base)-37], base[len(base)-36:]
+		if filt != nil && !filt(uuid, name) {
+			continue
+		}
+		v, _, err := GetVar(uuid, name)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, v)
+	}
+	return vars, nil
+}
+
+// SetVar creates or replaces a var in efivarfs, or appends to its data if
+// attrs has AttrAppendWrite.
+//
+// The immutable flag the kernel sets on some vars is cleared for the write
+// and set again after it.
+func SetVar(e EfiVar, attrs uint32) error {
+	path := varFsPath(e.UUID, e.Name)
+	immutable, err := clearImmutable(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE
+	if attrs&AttrAppendWrite != 0 {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	// efivarfs takes the attributes and data in a single write.
+	b := make([]byte, 4, 4+len(e.Data))
+	binary.LittleEndian.PutUint32(b, attrs)
+	_, err = f.Write(append(b, e.Data...))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s-%s: %w", e.Name, e.UUID, err)
+	}
+	if immutable {
+		return setFlags(path, func(fl uint32) uint32 { return fl | fsImmutableFl })
+	}
+	return nil
+}
+
+// DeleteVar removes a var from efivarfs, clearing its immutable flag first.
+func DeleteVar(uuid, name string) error {
+	path := varFsPath(uuid, name)
+	if _, err := clearImmutable(path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// clearImmutable clears the immutable flag of the file, and returns whether
+// it was set.
+func clearImmutable(path string) (immutable bool, err error) {
+	err = setFlags(path, func(fl uint32) uint32 {
+		immutable = fl&fsImmutableFl != 0
+		return fl &^ fsImmutableFl
+	})
+	return immutable, err
+}
+
+// setFlags changes the inode flags of the file. It does nothing if the
+// filesystem has no such flags.
+func setFlags(path string, f func(uint32) uint32) error {
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	fl, err := unix.IoctlGetUint32(int(fd.Fd()), unix.FS_IOC_GETFLAGS)
+	if err == unix.ENOTTY || err == unix.EOPNOTSUPP || err == unix.EINVAL {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting flags of %s: %w", path, err)
+	}
+	if nfl := f(fl); nfl != fl {
+		if err := unix.IoctlSetPointerInt(int(fd.Fd()), unix.FS_IOC_SETFLAGS, int(nfl)); err != nil {
+			return fmt.Errorf("setting flags of %s: %w", path, err)
+		}
+	}
+	return nil
+}
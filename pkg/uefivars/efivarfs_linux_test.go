@@ -0,0 +1,166 @@
+// Copyright 2020 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+//
+// SPDX-License-Identifier: BSD-3-Clause
+//
+
+package uefivars
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/uefivars/vartest"
+)
+
+const globalUUID = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+// setupVarFs points EfiVarFs at a copy of the testdata laid out as efivarfs.
+func setupVarFs(t *testing.T) func() {
+	dir, cleanup, err := vartest.SetupVarFs("testdata/sys_fw_efi_vars.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := EfiVarFs
+	EfiVarFs = dir
+	return func() {
+		EfiVarFs = old
+		cleanup()
+	}
+}
+
+func TestGetVar(t *testing.T) {
+	defer setupVarFs(t)()
+
+	want, err := ReadVar(globalUUID, "BootOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, attrs, err := GetVar(globalUUID, "BootOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UUID != want.UUID || got.Name != want.Name || !bytes.Equal(got.Data, want.Data) {
+		t.Errorf("GetVar(BootOrder) = %v, want %v", got, want)
+	}
+	if attrs != AttrDefault {
+		t.Errorf("GetVar(BootOrder) has attributes 0x%x, want 0x%x", attrs, AttrDefault)
+	}
+
+	if _, _, err := GetVar(globalUUID, "NoSuchVar"); !os.IsNotExist(err) {
+		t.Errorf("GetVar(NoSuchVar) = %v, want a not exist error", err)
+	}
+	if err := ioutil.WriteFile(varFsPath(globalUUID, "Short"), []byte{7, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GetVar(globalUUID, "Short"); err == nil {
+		t.Errorf("GetVar(Short) = nil, want an error")
+	}
+}
+
+func TestGetVars(t *testing.T) {
+	defer setupVarFs(t)()
+
+	vars, err := GetVars(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(vars), len(AllVars()); got != want {
+		t.Errorf("GetVars(nil) returned %d vars, want %d", got, want)
+	}
+	vars, err = GetVars(func(uuid, name string) bool { return uuid == globalUUID && name == "BootCurrent" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 1 || vars[0].Name != "BootCurrent" {
+		t.Errorf("GetVars(BootCurrent) = %v, want BootCurrent", vars)
+	}
+}
+
+func TestSetVar(t *testing.T) {
+	defer setupVarFs(t)()
+
+	for _, tt := range []struct {
+		name  string
+		data  []byte
+		attrs uint32
+		want  []byte
+	}{
+		{name: "create", data: []byte{1, 2, 3, 4}, attrs: AttrDefault, want: []byte{1, 2, 3, 4}},
+		{name: "replace with less", data: []byte{5}, attrs: AttrDefault, want: []byte{5}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetVar(EfiVar{UUID: globalUUID, Name: "Test", Data: tt.data}, tt.attrs); err != nil {
+				t.Fatal(err)
+			}
+			v, attrs, err := GetVar(globalUUID, "Test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(v.Data, tt.want) {
+				t.Errorf("var has data %x, want %x", v.Data, tt.want)
+			}
+			if attrs != AttrDefault {
+				t.Errorf("var has attributes 0x%x, want 0x%x", attrs, AttrDefault)
+			}
+		})
+	}
+
+	// efivarfs takes the attributes of an append write and appends the
+	// rest, the stand-in for it in a temp dir appends both.
+	if err := SetVar(EfiVar{UUID: globalUUID, Name: "Test", Data: []byte{6, 7}}, AttrDefault|AttrAppendWrite); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(varFsPath(globalUUID, "Test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{7, 0, 0, 0, 5, 0x47, 0, 0, 0, 6, 7}; !bytes.Equal(b, want) {
+		t.Errorf("var file after an append write = %x, want %x", b, want)
+	}
+
+	if err := DeleteVar(globalUUID, "Test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GetVar(globalUUID, "Test"); !os.IsNotExist(err) {
+		t.Errorf("GetVar() after DeleteVar() = %v, want a not exist error", err)
+	}
+	if err := DeleteVar(globalUUID, "Test"); !os.IsNotExist(err) {
+		t.Errorf("DeleteVar() of a deleted var = %v, want a not exist error", err)
+	}
+}
+
+func TestImmutableVar(t *testing.T) {
+	defer setupVarFs(t)()
+
+	path := varFsPath(globalUUID, "BootOrder")
+	if err := setFlags(path, func(fl uint32) uint32 { return fl | fsImmutableFl }); err != nil {
+		t.Skipf("cannot make var immutable: %v", err)
+	}
+	if immutable, err := clearImmutable(path); err != nil || !immutable {
+		t.Skipf("the filesystem has no immutable flag")
+	}
+	if err := setFlags(path, func(fl uint32) uint32 { return fl | fsImmutableFl }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetVar(EfiVar{UUID: globalUUID, Name: "BootOrder", Data: []byte{1, 0}}, AttrDefault); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := GetVar(globalUUID, "BootOrder"); err != nil || !bytes.Equal(v.Data, []byte{1, 0}) {
+		t.Errorf("GetVar(BootOrder) = %v, %v, want 0100", v, err)
+	}
+	if err := os.Remove(path); err == nil {
+		t.Fatalf("SetVar() left the var mutable")
+	}
+
+	if err := DeleteVar(globalUUID, "BootOrder"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("DeleteVar() left the var: %v", err)
+	}
+}
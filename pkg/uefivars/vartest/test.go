@@ -11,10 +11,12 @@ package vartest
 
 import (
 	"archive/zip"
+	"encoding/binary"
 	"io"
 	"io/ioutil"
 	"os"
 	fp "path/filepath"
+	"strings"
 )
 
 // Extracts testdata zip for use as efivars in tests. Used in uefivars and subpackages.
@@ -61,3 +63,62 @@ func SetupVarZip(path string) (efiVarDir string, cleanup func(), err error) {
 	cleanup = func() { os.RemoveAll(efiVarDir) }
 	return
 }
+
+// sysfsAttrs are the attributes of vars as the sysfs attributes file names
+// them.
+var sysfsAttrs = map[string]uint32{
+	"EFI_VARIABLE_NON_VOLATILE":                          0x01,
+	"EFI_VARIABLE_BOOTSERVICE_ACCESS":                    0x02,
+	"EFI_VARIABLE_RUNTIME_ACCESS":                        0x04,
+	"EFI_VARIABLE_HARDWARE_ERROR_RECORD":                 0x08,
+	"EFI_VARIABLE_AUTHENTICATED_WRITE_ACCESS":            0x10,
+	"EFI_VARIABLE_TIME_BASED_AUTHENTICATED_WRITE_ACCESS": 0x20,
+	"EFI_VARIABLE_APPEND_WRITE":                          0x40,
+}
+
+// SetupVarFs extracts the same testdata zip as SetupVarZip, but lays it out
+// as efivarfs does, for use as uefivars.EfiVarFs in tests: each var is a
+// file holding its attributes followed by its data.
+func SetupVarFs(path string) (efiVarFs string, cleanup func(), err error) {
+	efiVarDir, cleanupZip, err := SetupVarZip(path)
+	if err != nil {
+		return
+	}
+	defer cleanupZip()
+	efiVarFs, err = ioutil.TempDir("", "gotest-efivarfs")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(efiVarFs)
+		}
+	}()
+	entries, err := ioutil.ReadDir(efiVarDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		var data, attrNames []byte
+		data, err = ioutil.ReadFile(fp.Join(efiVarDir, entry.Name(), "data"))
+		if err != nil {
+			return
+		}
+		attrNames, err = ioutil.ReadFile(fp.Join(efiVarDir, entry.Name(), "attributes"))
+		if err != nil {
+			return
+		}
+		var attrs uint32
+		for _, a := range strings.Fields(string(attrNames)) {
+			attrs |= sysfsAttrs[a]
+		}
+		b := make([]byte, 4, 4+len(data))
+		binary.LittleEndian.PutUint32(b, attrs)
+		err = ioutil.WriteFile(fp.Join(efiVarFs, entry.Name()), append(b, data...), 0644)
+		if err != nil {
+			return
+		}
+	}
+	cleanup = func() { os.RemoveAll(efiVarFs) }
+	return
+}